@@ -17,6 +17,7 @@ import (
 	"payverge/internal/handlers"
 	"payverge/internal/health"
 	"payverge/internal/logger"
+	"payverge/internal/logic"
 	"payverge/internal/middleware"
 	"payverge/internal/s3"
 
@@ -26,6 +27,7 @@ import (
 	"payverge/internal/notifications"
 	"payverge/internal/server"
 	"payverge/internal/services"
+	"payverge/internal/siwe"
 	"payverge/internal/structs"
 	"payverge/internal/telegram"
 	"payverge/internal/websocket"
@@ -38,6 +40,12 @@ func main() {
 	// Get flags and initialize the database
 	var (
 		databasePath           = flag.String("database-path", "./data/app.db", "SQLite database file path")
+		databaseDriver         = flag.String("database-driver", database.DriverSQLite, "Database driver: sqlite or postgres")
+		databaseDSN            = flag.String("database-dsn", "", "Postgres connection string, used when database-driver is postgres")
+		databaseMaxOpenConns   = flag.Int("database-max-open-conns", 25, "Maximum open database connections")
+		databaseMaxIdleConns   = flag.Int("database-max-idle-conns", 10, "Maximum idle database connections kept in the pool")
+		databaseMaxLifetime    = flag.Duration("database-conn-max-lifetime", 30*time.Minute, "Maximum time a database connection is reused")
+		databaseMaxIdleTime    = flag.Duration("database-conn-max-idle-time", 5*time.Minute, "Maximum time a database connection stays idle before it is closed")
 		production             = flag.Bool("production", false, "Production mode")
 		faucetPrivateKey       = flag.String("faucet-private-key", "", "Private key for Faucet Address")
 		rpcUrl                 = flag.String("rpc-url", "", "RPC URL for the Ethereum node")
@@ -59,6 +67,13 @@ func main() {
 		fromEmailNews          = flag.String("from-email-news", "", "From email news")
 		fromEmailUpdates       = flag.String("from-email-updates", "", "From email updates")
 		googleTranslateAPIKey  = flag.String("google-translate-api-key", "", "Google Translate API Key")
+		indexerStartBlock      = flag.Uint64("indexer-start-block", 0, "Block the payments contract was deployed in; the indexer starts there and requires it until it has a checkpoint")
+		indexerConfirmations   = flag.Uint64("indexer-confirmations", 5, "Confirmations required before a payment event is processed")
+		webhookSecrets         = flag.String("payment-webhook-secrets", "", "Comma separated provider:secret pairs allowed to sign payment webhooks")
+		webhookTolerance       = flag.Duration("payment-webhook-tolerance", handlers.DefaultWebhookTolerance, "Maximum clock skew accepted for payment webhook timestamps")
+		signInDomains          = flag.String("siwe-domains", "localhost:3000", "Comma separated hosts, with port if any, users sign in with Ethereum from")
+		signInChainIDs         = flag.String("siwe-chain-ids", "", "Comma separated chain IDs users may sign in on, defaults to chain-id")
+		sharedState            = flag.String("shared-state", "memory", "Where sign-in challenges and rate limits are kept: memory for a single replica, sql to share them through the database")
 	)
 	flag.Parse()
 	if *production {
@@ -105,7 +120,15 @@ func main() {
 	}
 
 	// Initialize the database
-	config := database.NewConfig(*databasePath)
+	config := &database.DbConfig{
+		Driver:          *databaseDriver,
+		DatabasePath:    *databasePath,
+		DSN:             *databaseDSN,
+		MaxOpenConns:    *databaseMaxOpenConns,
+		MaxIdleConns:    *databaseMaxIdleConns,
+		ConnMaxLifetime: *databaseMaxLifetime,
+		ConnMaxIdleTime: *databaseMaxIdleTime,
+	}
 	database.InitDB(config)
 
 	// Initialize database wrapper and blockchain service
@@ -115,6 +138,17 @@ func main() {
 		log.Fatalf("Failed to initialize blockchain service: %v", err)
 	}
 
+	// Sign-in messages must come from our own sites and allowed chains; smart contract
+	// wallets are asked to confirm their signatures on the connected chain
+	if *signInChainIDs == "" {
+		*signInChainIDs = fmt.Sprint(*chainId)
+	}
+	signInConfig, err := siwe.ParseConfig(*signInDomains, *signInChainIDs)
+	if err != nil {
+		log.Fatalf("Invalid sign-in configuration: %v", err)
+	}
+	server.ConfigureSignIn(signInConfig, siwe.NewVerifier(*chainId, blockchainService.Client()))
+
 	// Create admin user if it doesn't exist
 	adminAddress := "0xe287a52a3ce43c480c7247d10242ee7227afb90f"
 	if err := createAdminUserIfNotExists(adminAddress); err != nil {
@@ -148,16 +182,40 @@ func main() {
 
 	// Initialize WebSocket Hub
 	wsHub := websocket.NewHub()
+	wsHub.SetAuthorization(server.VerifyWebSocketToken, server.CanAccessBusiness)
+	wsHub.SetKitchenAuthorization(server.CanAccessKitchen)
+	wsHub.SetBillAuthorization(server.CanWatchBill)
 	go wsHub.Run()
+	handlers.SetKitchenNotifier(websocket.NewKitchenFeed(wsHub))
+	tableFeed := websocket.NewTableFeed(wsHub)
+	server.SetBillTransferNotifier(tableFeed)
+	database.SetTableStatusNotifier(tableFeed)
+	handlers.SetLowStockNotifier(notifications.NewLowStockNotifier(notificationManager))
 
 	// Initialize Payment Monitor
-	paymentMonitor := websocket.NewPaymentMonitor(wsHub, db, blockchainService)
+	indexerConfig := blockchain.DefaultIndexerConfig()
+	indexerConfig.StartBlock = *indexerStartBlock
+	indexerConfig.Confirmations = *indexerConfirmations
+	paymentMonitor := websocket.NewPaymentMonitor(wsHub, db, blockchainService, indexerConfig)
 	if err := paymentMonitor.Start(); err != nil {
 		log.Printf("Warning: Failed to start payment monitor: %v", err)
 	}
 
 	// Initialize payment handler
 	paymentHandler := handlers.NewPaymentHandler(db, blockchainService)
+	paymentHandler.SetConfirmations(indexerConfig.Confirmations)
+	paymentWebhookSecrets, err := handlers.ParseWebhookSecrets(*webhookSecrets)
+	if err != nil {
+		log.Fatalf("Invalid payment webhook secrets: %v", err)
+	}
+	if len(paymentWebhookSecrets) == 0 {
+		log.Printf("Warning: no payment webhook secrets configured, payment webhooks are disabled")
+	}
+	paymentHandler.ConfigureWebhooks(handlers.WebhookConfig{
+		Secrets:   paymentWebhookSecrets,
+		Tolerance: *webhookTolerance,
+	})
+	paymentHandler.SetSplitNotifier(paymentMonitor)
 
 	// Initialize S3
 	if err := s3.InitS3(*s3Bucket, *awsAccessKey, *awsSecretKey, *awsRegion, *s3EndpointURL); err != nil {
@@ -181,8 +239,22 @@ func main() {
 		defer metrics.ClosePostHogClient()
 	}
 
-	// Create rate limiter (60 requests per minute)
-	rateLimiter := middleware.NewSimpleRateLimiter(60)
+	// Create rate limiter (60 requests per minute). Replicas behind a load balancer keep
+	// challenges and rate limits in the database so that they see the same ones.
+	var rateLimiter middleware.RateLimiter
+	var cleanupSharedState []func() error
+	switch *sharedState {
+	case "memory":
+		rateLimiter = middleware.NewSimpleRateLimiter(60)
+	case "sql":
+		sqlRateLimiter := middleware.NewSQLRateLimiter("api", 60, time.Minute)
+		rateLimiter = sqlRateLimiter
+		server.ChallengeStore = logic.NewSQLChallengeStore()
+		cleanupSharedState = append(cleanupSharedState, sqlRateLimiter.Cleanup)
+	default:
+		log.Fatalf("Invalid shared-state %q, expected memory or sql", *sharedState)
+	}
+	cleanupSharedState = append(cleanupSharedState, server.ChallengeStore.Cleanup)
 
 	r := gin.Default()
 	r.Use(gin.Recovery())
@@ -190,7 +262,7 @@ func main() {
 	r.Use(middleware.SecurityHeaders())
 	r.Use(middleware.InputValidation())
 	r.Use(middleware.JSONSizeLimit(10 << 20)) // 10MB limit
-	r.Use(rateLimiter.RateLimit())
+	r.Use(middleware.Limit(rateLimiter, middleware.ByClientIP, "Rate limit exceeded. Please try again later."))
 	r.Use(server.PrometheusMiddleware())
 
 	// Configure Gin to handle larger file uploads
@@ -205,9 +277,13 @@ func main() {
 		auth.POST("/challenge", server.GenerateChallenge)
 		auth.GET("/session", server.GetSession)
 		auth.POST("/signin", server.SignIn)
+		auth.POST("/refresh", server.RefreshSession)
 		auth.POST("/signout", server.SignOut)
 	}
 
+	// Guest routes that pay or claim part of a bill need the bill's table session
+	billSession := server.RequireBillTableSession()
+
 	// Public routes (do not require authentication)
 	publicRoutes := r.Group("/api/v1/")
 	{
@@ -240,6 +316,9 @@ func main() {
 		publicRoutes.GET("/guest/table/:code/business", server.GetBusinessByTableCode)
 		publicRoutes.GET("/guest/table/:code/menu", server.GetMenuByTableCode)
 		publicRoutes.GET("/guest/table/:code/status", server.GetTableStatusByCode)
+		publicRoutes.POST("/guest/table/:code/session", server.StartTableSession)
+		publicRoutes.GET("/guest/table/:code/session", server.GetTableSession)
+		publicRoutes.GET("/guest/counter/:code", server.GetCounterByCodePublic)
 		publicRoutes.GET("/guest/bill/:bill_number", server.GetBillByNumberPublic)
 
 		// Phase 4: Payment processing endpoints
@@ -250,24 +329,30 @@ func main() {
 
 		// Phase 5: Bill Splitting routes (public for guests)
 		splittingHandler := handlers.NewSplittingHandler(database.GetDBWrapper(), blockchainService)
+		splittingHandler.SetNotifier(paymentMonitor)
 		publicRoutes.GET("/bills/:bill_id/split/options", splittingHandler.GetBillSplitOptions)
 		publicRoutes.POST("/bills/:bill_id/split/equal", splittingHandler.CalculateEqualSplit)
 		publicRoutes.POST("/bills/:bill_id/split/custom", splittingHandler.CalculateCustomSplit)
 		publicRoutes.POST("/bills/:bill_id/split/items", splittingHandler.CalculateItemSplit)
+		publicRoutes.POST("/bills/:bill_id/split/weighted", splittingHandler.CalculateWeightedSplit)
+		publicRoutes.POST("/bills/:bill_id/split/percentage", splittingHandler.CalculatePercentageSplit)
+		publicRoutes.POST("/bills/:bill_id/split/mixed", splittingHandler.CalculateMixedSplit)
 		publicRoutes.POST("/bills/:bill_id/split/validate", splittingHandler.ValidateSplit)
 
 		// Blockchain integration routes for split payments (public for guests)
 		publicRoutes.GET("/bills/:bill_id/participants", splittingHandler.GetBillParticipants)
 		publicRoutes.GET("/bills/:bill_id/participants/:address", splittingHandler.GetParticipantInfo)
 		publicRoutes.GET("/bills/:bill_id/summary", splittingHandler.GetBillSummaryWithParticipants)
-		publicRoutes.POST("/bills/:bill_id/split/execute", splittingHandler.ExecuteSplitPayment)
+		publicRoutes.POST("/bills/:bill_id/split/execute", billSession, splittingHandler.ExecuteSplitPayment)
+		publicRoutes.GET("/bills/:bill_id/split/session", splittingHandler.GetSplitSession)
+		publicRoutes.POST("/bills/:bill_id/split/session/claim", billSession, splittingHandler.ClaimSplitShare)
 		publicRoutes.POST("/payments/webhook", paymentHandler.WebhookPaymentConfirmation)
 
 		// WebSocket endpoint for real-time updates
 		publicRoutes.GET("/ws", gin.WrapH(http.HandlerFunc(wsHub.ServeWS)))
 
 		// Alternative Payment routes (public for guests)
-		publicRoutes.POST("/bills/:bill_id/request-alternative-payment", paymentHandler.RequestAlternativePayment)
+		publicRoutes.POST("/bills/:bill_id/request-alternative-payment", billSession, paymentHandler.RequestAlternativePayment)
 		publicRoutes.GET("/bills/:bill_id/alternative-payments", paymentHandler.GetBillAlternativePayments)
 		publicRoutes.GET("/bills/:bill_id/payment-breakdown", paymentHandler.GetBillPaymentBreakdown)
 
@@ -303,6 +388,13 @@ func main() {
 	protectedRoutes := r.Group("/api/v1/inside")
 	protectedRoutes.Use(server.AuthenticationMiddleware())
 	{
+		// Business routes let through the owner and active staff whose role has the
+		// permission; the resolver finds the business from the route's path
+		can := middleware.RequirePermission
+		byBusiness := middleware.BusinessParam("id")
+		byBill := middleware.BillParam("bill_id")
+		byTable := middleware.TableParam("id")
+
 		// Faucet endpoint
 		protectedRoutes.POST("/faucet", server.CheckAndTopUp)
 		protectedRoutes.GET("/faucet/check/:address", server.CheckFaucetAvailability)
@@ -323,6 +415,11 @@ func main() {
 		protectedRoutes.PUT("/set_language", server.SetLanguage)
 		protectedRoutes.POST("/set_referrer", server.SetReferrer)
 
+		// Signed-in devices of the caller
+		protectedRoutes.GET("/sessions", server.GetAuthSessions)
+		protectedRoutes.DELETE("/sessions", server.RevokeOtherAuthSessions)
+		protectedRoutes.DELETE("/sessions/:sessionId", server.RevokeAuthSession)
+
 		// User settings routes
 		protectedRoutes.PUT("/settings/notifications", server.UpdateNotificationPreferences)
 		protectedRoutes.GET("/settings/notifications", server.GetNotificationPreferences)
@@ -330,86 +427,137 @@ func main() {
 		// Payverge Business routes
 		protectedRoutes.POST("/businesses", server.CreateBusiness)
 		protectedRoutes.GET("/businesses", server.GetMyBusinesses)
-		protectedRoutes.GET("/businesses/:id", server.GetBusiness)
-		protectedRoutes.PUT("/businesses/:id", server.UpdateBusiness)
-		protectedRoutes.DELETE("/businesses/:id", server.DeleteBusiness)
+		protectedRoutes.GET("/businesses/:id", can(database.PermissionBusinessRead, byBusiness), server.GetBusiness)
+		protectedRoutes.PUT("/businesses/:id", can(database.PermissionBusinessWrite, byBusiness), server.UpdateBusiness)
+		protectedRoutes.DELETE("/businesses/:id", can(database.PermissionBusinessWrite, byBusiness), server.DeleteBusiness)
 		protectedRoutes.GET("/businesses/check-url", server.CheckCustomURLAvailability)
 
 		// Google Places API routes
 		protectedRoutes.POST("/google/businesses/search", server.SearchGoogleBusinesses)
-		protectedRoutes.PUT("/businesses/:id/google", server.UpdateBusinessGoogleInfo)
-		protectedRoutes.DELETE("/businesses/:id/google", server.RemoveBusinessGoogleInfo)
+		protectedRoutes.PUT("/businesses/:id/google", can(database.PermissionBusinessWrite, byBusiness), server.UpdateBusinessGoogleInfo)
+		protectedRoutes.DELETE("/businesses/:id/google", can(database.PermissionBusinessWrite, byBusiness), server.RemoveBusinessGoogleInfo)
 
 		// Subscription management routes
-		protectedRoutes.PUT("/businesses/:id/subscription/sync", server.SyncBusinessSubscriptionData)
-		protectedRoutes.POST("/businesses/:id/subscription/renewal", server.RecordSubscriptionRenewal)
-		protectedRoutes.GET("/businesses/:id/subscription/payments", server.GetSubscriptionPaymentHistory)
+		protectedRoutes.PUT("/businesses/:id/subscription/sync", can(database.PermissionFinancialWrite, byBusiness), server.SyncBusinessSubscriptionData)
+		protectedRoutes.POST("/businesses/:id/subscription/renewal", can(database.PermissionFinancialWrite, byBusiness), server.RecordSubscriptionRenewal)
+		protectedRoutes.GET("/businesses/:id/subscription/payments", can(database.PermissionFinancialRead, byBusiness), server.GetSubscriptionPaymentHistory)
 
 		// Counter management routes
-		protectedRoutes.PUT("/businesses/:id/counters/settings", server.UpdateCounterSettings)
-		protectedRoutes.GET("/businesses/:id/counters", server.GetBusinessCounters)
-		protectedRoutes.GET("/businesses/:id/counters/available", server.GetAvailableCounters)
+		protectedRoutes.PUT("/businesses/:id/counters/settings", can(database.PermissionBusinessWrite, byBusiness), server.UpdateCounterSettings)
+		protectedRoutes.GET("/businesses/:id/counters", can(database.PermissionTablesRead, byBusiness), server.GetBusinessCounters)
+		protectedRoutes.GET("/businesses/:id/counters/available", can(database.PermissionTablesRead, byBusiness), server.GetAvailableCounters)
+		protectedRoutes.GET("/businesses/:id/counters/:counterId/qr", can(database.PermissionTablesRead, byBusiness), server.GetCounterQRCode)
+		protectedRoutes.POST("/businesses/:id/counters/:counterId/rotate-code", can(database.PermissionTablesWrite, byBusiness), server.RotateCounterCode)
 
 		// Menu routes
-		protectedRoutes.POST("/businesses/:id/menu", server.CreateMenu)
-		protectedRoutes.GET("/businesses/:id/menu", server.GetMenu)
-		protectedRoutes.POST("/businesses/:id/menu/translate", server.TranslateMenu)
+		protectedRoutes.POST("/businesses/:id/menu", can(database.PermissionMenuWrite, byBusiness), server.CreateMenu)
+		protectedRoutes.GET("/businesses/:id/menu", can(database.PermissionMenuRead, byBusiness), server.GetMenu)
+		protectedRoutes.POST("/businesses/:id/menu/translate", can(database.PermissionMenuWrite, byBusiness), server.TranslateMenu)
 
 		// Phase 2: Enhanced Menu Management routes
-		protectedRoutes.POST("/businesses/:id/menu/categories", server.AddMenuCategory)
-		protectedRoutes.PUT("/businesses/:id/menu/categories/:category_index", server.UpdateMenuCategory)
-		protectedRoutes.DELETE("/businesses/:id/menu/categories/:category_index", server.DeleteMenuCategory)
-		protectedRoutes.POST("/businesses/:id/menu/items", server.AddMenuItem)
-		protectedRoutes.PUT("/businesses/:id/menu/items", server.UpdateMenuItem)
-		protectedRoutes.DELETE("/businesses/:id/menu/categories/:category_index/items/:item_index", server.DeleteMenuItem)
+		protectedRoutes.POST("/businesses/:id/menu/categories", can(database.PermissionMenuWrite, byBusiness), server.AddMenuCategory)
+		protectedRoutes.PUT("/businesses/:id/menu/categories/:category_id", can(database.PermissionMenuWrite, byBusiness), server.UpdateMenuCategory)
+		protectedRoutes.DELETE("/businesses/:id/menu/categories/:category_id", can(database.PermissionMenuWrite, byBusiness), server.DeleteMenuCategory)
+		protectedRoutes.POST("/businesses/:id/menu/items", can(database.PermissionMenuWrite, byBusiness), server.AddMenuItem)
+		protectedRoutes.PUT("/businesses/:id/menu/items/:item_id", can(database.PermissionMenuWrite, byBusiness), server.UpdateMenuItem)
+		protectedRoutes.DELETE("/businesses/:id/menu/items/:item_id", can(database.PermissionMenuWrite, byBusiness), server.DeleteMenuItem)
+		protectedRoutes.PUT("/businesses/:id/menu/items/:item_id/sold-out", can(database.PermissionMenuWrite, byBusiness), server.MarkMenuItemSoldOut)
+		protectedRoutes.DELETE("/businesses/:id/menu/items/:item_id/sold-out", can(database.PermissionMenuWrite, byBusiness), server.ClearMenuItemSoldOut)
+		protectedRoutes.GET("/businesses/:id/menu/preview", can(database.PermissionMenuRead, byBusiness), server.PreviewMenu)
+		protectedRoutes.GET("/businesses/:id/menu/export", can(database.PermissionMenuRead, byBusiness), server.ExportMenuFile)
+		protectedRoutes.POST("/businesses/:id/menu/import", can(database.PermissionMenuWrite, byBusiness), server.ImportMenuFile)
+
+		// Time-based menus (breakfast, lunch, happy hour)
+		protectedRoutes.GET("/businesses/:id/menus", can(database.PermissionMenuRead, byBusiness), server.ListMenus)
+		protectedRoutes.POST("/businesses/:id/menus", can(database.PermissionMenuWrite, byBusiness), server.AddMenu)
+		protectedRoutes.PUT("/businesses/:id/menus/:menu_id", can(database.PermissionMenuWrite, byBusiness), server.UpdateMenuByID)
+		protectedRoutes.DELETE("/businesses/:id/menus/:menu_id", can(database.PermissionMenuWrite, byBusiness), server.DeleteMenuByID)
 
 		// Table routes (Phase 2: Enhanced Table Management)
-		protectedRoutes.POST("/businesses/:id/tables", server.CreateTableWithQR)
-		protectedRoutes.GET("/businesses/:id/tables", server.GetBusinessTables)
-		protectedRoutes.GET("/businesses/:id/tables/:tableId", server.GetTable)
-		protectedRoutes.PUT("/tables/:id", server.UpdateTableDetails)
-		protectedRoutes.DELETE("/tables/:id", server.DeleteTableSoft)
+		protectedRoutes.POST("/businesses/:id/tables", can(database.PermissionTablesWrite, byBusiness), server.CreateTableWithQR)
+		protectedRoutes.GET("/businesses/:id/tables", can(database.PermissionTablesRead, byBusiness), server.GetBusinessTables)
+		protectedRoutes.GET("/businesses/:id/tables/:tableId", can(database.PermissionTablesRead, byBusiness), server.GetTable)
+		protectedRoutes.GET("/businesses/:id/tables/:tableId/qr", can(database.PermissionTablesRead, byBusiness), server.GetTableQRCode)
+		protectedRoutes.POST("/businesses/:id/tables/:tableId/rotate-code", can(database.PermissionTablesWrite, byBusiness), server.RotateTableCode)
+		protectedRoutes.GET("/businesses/:id/tables/:tableId/sessions", can(database.PermissionTablesRead, byBusiness), server.GetTableSessions)
+		protectedRoutes.DELETE("/businesses/:id/tables/:tableId/sessions/:sessionId", can(database.PermissionTablesSeat, byBusiness), server.RevokeTableSession)
+		protectedRoutes.POST("/businesses/:id/tables/:tableId/seat", can(database.PermissionTablesSeat, byBusiness), server.SeatTable)
+		protectedRoutes.POST("/businesses/:id/tables/:tableId/clean", can(database.PermissionTablesSeat, byBusiness), server.MarkTableClean)
+		protectedRoutes.GET("/businesses/:id/floor-plan", can(database.PermissionTablesRead, byBusiness), server.GetFloorPlan)
+		protectedRoutes.PUT("/businesses/:id/floor-plan", can(database.PermissionTablesWrite, byBusiness), server.UpdateFloorPlan)
+		protectedRoutes.GET("/businesses/:id/qr/sheet", can(database.PermissionTablesRead, byBusiness), server.GetQRCodeSheet)
+		protectedRoutes.PUT("/tables/:id", can(database.PermissionTablesWrite, byTable), server.UpdateTableDetails)
+		protectedRoutes.DELETE("/tables/:id", can(database.PermissionTablesWrite, byTable), server.DeleteTableSoft)
 
 		// Phase 3: Bill Management routes
-		protectedRoutes.POST("/businesses/:id/bills", server.CreateBill)
-		protectedRoutes.GET("/businesses/:id/bills", server.GetBusinessBills)
-		protectedRoutes.GET("/businesses/:id/bills/open", server.GetOpenBusinessBills)
-		protectedRoutes.GET("/bills/:bill_id", server.GetBill)
-		protectedRoutes.PUT("/bills/:bill_id", server.UpdateBill)
-		protectedRoutes.POST("/bills/:bill_id/items", server.AddBillItem)
-		protectedRoutes.DELETE("/bills/:bill_id/items/:item_id", server.RemoveBillItem)
-		protectedRoutes.POST("/bills/:bill_id/close", server.CloseBill)
+		protectedRoutes.POST("/businesses/:id/bills", can(database.PermissionBillsWrite, byBusiness), server.CreateBill)
+		protectedRoutes.GET("/businesses/:id/bills", can(database.PermissionBillsRead, byBusiness), server.GetBusinessBills)
+		protectedRoutes.GET("/businesses/:id/bills/open", can(database.PermissionBillsRead, byBusiness), server.GetOpenBusinessBills)
+		protectedRoutes.GET("/bills/:bill_id", can(database.PermissionBillsRead, byBill), server.GetBill)
+		protectedRoutes.PUT("/bills/:bill_id", can(database.PermissionBillsWrite, byBill), server.UpdateBill)
+		protectedRoutes.POST("/bills/:bill_id/items", can(database.PermissionBillsWrite, byBill), server.AddBillItem)
+		protectedRoutes.DELETE("/bills/:bill_id/items/:item_id", can(database.PermissionBillsWrite, byBill), server.RemoveBillItem)
+		protectedRoutes.POST("/bills/:bill_id/close", can(database.PermissionBillsClose, byBill), server.CloseBill)
+		protectedRoutes.POST("/bills/:bill_id/void", can(database.PermissionBillsVoid, byBill), server.VoidBill)
+		protectedRoutes.POST("/bills/:bill_id/refund", can(database.PermissionBillsVoid, byBill), server.RefundBill)
+		protectedRoutes.POST("/bills/:bill_id/move", can(database.PermissionBillsWrite, byBill), server.MoveBill)
+		protectedRoutes.POST("/bills/:bill_id/merge", can(database.PermissionBillsWrite, byBill), server.MergeBill)
+		protectedRoutes.POST("/bills/:bill_id/split-items", can(database.PermissionBillsWrite, byBill), server.SplitBillItems)
+		protectedRoutes.GET("/bills/:bill_id/events", can(database.PermissionBillsRead, byBill), server.GetBillEvents)
 
 		// Crypto Payment routes (public for guests)
 		publicRoutes.POST("/guest/bills/:bill_id/create-onchain", paymentHandler.CreateOnChainBill)
-		publicRoutes.POST("/guest/bills/:bill_id/crypto-payment", paymentHandler.ProcessCryptoPayment)
+		publicRoutes.POST("/guest/bills/:bill_id/crypto-payment", billSession, paymentHandler.ProcessCryptoPayment)
 
 		// Phase 6: Analytics and Dashboard routes
 		analyticsHandler := handlers.NewAnalyticsHandler(database.GetDBWrapper())
-		protectedRoutes.GET("/businesses/:id/analytics/sales", analyticsHandler.GetSalesAnalytics)
-		protectedRoutes.GET("/businesses/:id/analytics/tips", analyticsHandler.GetTipAnalytics)
-		protectedRoutes.GET("/businesses/:id/analytics/items", analyticsHandler.GetItemAnalytics)
-		protectedRoutes.GET("/businesses/:id/analytics/dashboard", analyticsHandler.GetDashboardSummary)
+		protectedRoutes.GET("/businesses/:id/analytics/sales", can(database.PermissionFinancialRead, byBusiness), analyticsHandler.GetSalesAnalytics)
+		protectedRoutes.GET("/businesses/:id/analytics/tips", can(database.PermissionFinancialRead, byBusiness), analyticsHandler.GetTipAnalytics)
+		protectedRoutes.GET("/businesses/:id/analytics/items", can(database.PermissionFinancialRead, byBusiness), analyticsHandler.GetItemAnalytics)
+		protectedRoutes.GET("/businesses/:id/analytics/dashboard", can(database.PermissionFinancialRead, byBusiness), analyticsHandler.GetDashboardSummary)
 
 		// Phase 7: Order Management routes
-		protectedRoutes.POST("/businesses/:id/orders", handlers.CreateOrder)
-		protectedRoutes.GET("/businesses/:id/orders", handlers.GetOrders)
-		protectedRoutes.GET("/businesses/:id/orders/:orderId", handlers.GetOrder)
-		protectedRoutes.GET("/businesses/:id/bills/:billId/orders", handlers.GetOrdersByBillID)
-		protectedRoutes.PUT("/businesses/:id/orders/:orderId/status", handlers.UpdateOrderStatus)
-		protectedRoutes.GET("/businesses/:id/analytics/live-bills", analyticsHandler.GetLiveBills)
-		protectedRoutes.GET("/businesses/:id/reports/export", analyticsHandler.ExportSalesData)
+		protectedRoutes.POST("/businesses/:id/orders", can(database.PermissionOrdersWrite, byBusiness), handlers.CreateOrder)
+		protectedRoutes.GET("/businesses/:id/orders", can(database.PermissionOrdersRead, byBusiness), handlers.GetOrders)
+		protectedRoutes.GET("/businesses/:id/orders/:orderId", can(database.PermissionOrdersRead, byBusiness), handlers.GetOrder)
+		protectedRoutes.GET("/businesses/:id/bills/:billId/orders", can(database.PermissionOrdersRead, byBusiness), handlers.GetOrdersByBillID)
+		protectedRoutes.PUT("/businesses/:id/orders/:orderId/status", can(database.PermissionOrdersUpdate, byBusiness), handlers.UpdateOrderStatus)
+
+		// Kitchen display routes
+		protectedRoutes.GET("/businesses/:id/kitchen/stations", can(database.PermissionKitchenRead, byBusiness), handlers.GetKitchenStations)
+		protectedRoutes.POST("/businesses/:id/kitchen/stations", can(database.PermissionKitchenWrite, byBusiness), handlers.CreateKitchenStation)
+		protectedRoutes.PUT("/businesses/:id/kitchen/stations/:stationId", can(database.PermissionKitchenWrite, byBusiness), handlers.UpdateKitchenStation)
+		protectedRoutes.DELETE("/businesses/:id/kitchen/stations/:stationId", can(database.PermissionKitchenWrite, byBusiness), handlers.DeleteKitchenStation)
+		protectedRoutes.GET("/businesses/:id/kitchen/routes", can(database.PermissionKitchenRead, byBusiness), handlers.GetKitchenRoutes)
+		protectedRoutes.PUT("/businesses/:id/kitchen/routes", can(database.PermissionKitchenWrite, byBusiness), handlers.SetKitchenRoutes)
+		protectedRoutes.GET("/businesses/:id/kitchen/tickets", can(database.PermissionKitchenRead, byBusiness), handlers.GetKitchenTickets)
+		protectedRoutes.POST("/businesses/:id/kitchen/tickets/:ticketId/start", can(database.PermissionKitchenUpdate, byBusiness), handlers.StartKitchenTicket)
+		protectedRoutes.POST("/businesses/:id/kitchen/tickets/:ticketId/bump", can(database.PermissionKitchenUpdate, byBusiness), handlers.BumpKitchenTicket)
+
+		// Inventory routes
+		protectedRoutes.GET("/businesses/:id/inventory/items", can(database.PermissionInventoryRead, byBusiness), handlers.GetStockItems)
+		protectedRoutes.POST("/businesses/:id/inventory/items", can(database.PermissionInventoryWrite, byBusiness), handlers.CreateStockItem)
+		protectedRoutes.PUT("/businesses/:id/inventory/items/:stockItemId", can(database.PermissionInventoryWrite, byBusiness), handlers.UpdateStockItem)
+		protectedRoutes.DELETE("/businesses/:id/inventory/items/:stockItemId", can(database.PermissionInventoryWrite, byBusiness), handlers.DeleteStockItem)
+		protectedRoutes.POST("/businesses/:id/inventory/items/:stockItemId/adjustments", can(database.PermissionInventoryWrite, byBusiness), handlers.AdjustStockItem)
+		protectedRoutes.GET("/businesses/:id/inventory/adjustments", can(database.PermissionInventoryRead, byBusiness), handlers.GetStockAdjustments)
+		protectedRoutes.GET("/businesses/:id/inventory/reconciliation", can(database.PermissionInventoryRead, byBusiness), handlers.GetStockReconciliation)
+		protectedRoutes.GET("/businesses/:id/inventory/recipes/:menuItemId", can(database.PermissionInventoryRead, byBusiness), handlers.GetMenuItemRecipe)
+		protectedRoutes.PUT("/businesses/:id/inventory/recipes/:menuItemId", can(database.PermissionInventoryWrite, byBusiness), handlers.SetMenuItemRecipe)
+		protectedRoutes.PUT("/businesses/:id/inventory/recipes/:menuItemId/stock", can(database.PermissionInventoryWrite, byBusiness), handlers.TrackMenuItemStock)
+		protectedRoutes.GET("/businesses/:id/analytics/live-bills", can(database.PermissionBillsRead, byBusiness), analyticsHandler.GetLiveBills)
+		protectedRoutes.GET("/businesses/:id/reports/export", can(database.PermissionFinancialRead, byBusiness), analyticsHandler.ExportSalesData)
 
 		// Alternative Payment routes (business owner functions)
-		protectedRoutes.POST("/bills/:bill_id/alternative-payment", paymentHandler.MarkAlternativePayment)
-		protectedRoutes.GET("/bills/:bill_id/pending-alternative-payments", paymentHandler.GetPendingAlternativePayments)
+		protectedRoutes.POST("/bills/:bill_id/alternative-payment", can(database.PermissionBillsClose, byBill), paymentHandler.MarkAlternativePayment)
+		protectedRoutes.GET("/bills/:bill_id/pending-alternative-payments", can(database.PermissionBillsRead, byBill), paymentHandler.GetPendingAlternativePayments)
 
 		// Staff Management routes (business owner functions)
-		protectedRoutes.POST("/businesses/:id/staff/invite", server.InviteStaff)
-		protectedRoutes.POST("/businesses/:id/staff/invitations/:invitationId/resend", server.ResendInvitation)
-		protectedRoutes.GET("/businesses/:id/staff", server.GetBusinessStaff)
-		protectedRoutes.PUT("/businesses/:id/staff/:staffId/role", server.UpdateStaffRole)
-		protectedRoutes.DELETE("/businesses/:id/staff/:staffId", server.RemoveStaff)
+		protectedRoutes.POST("/businesses/:id/staff/invite", can(database.PermissionStaffManage, byBusiness), server.InviteStaff)
+		protectedRoutes.POST("/businesses/:id/staff/invitations/:invitationId/resend", can(database.PermissionStaffManage, byBusiness), server.ResendInvitation)
+		protectedRoutes.GET("/businesses/:id/staff", can(database.PermissionStaffManage, byBusiness), server.GetBusinessStaff)
+		protectedRoutes.PUT("/businesses/:id/staff/:staffId/role", can(database.PermissionStaffManage, byBusiness), server.UpdateStaffRole)
+		protectedRoutes.DELETE("/businesses/:id/staff/:staffId", can(database.PermissionStaffManage, byBusiness), server.RemoveStaff)
 
 		// Referral system routes (protected - require authentication)
 		protectedRoutes.POST("/referrals/register", server.RegisterReferrer)
@@ -421,24 +569,25 @@ func main() {
 
 		// Withdrawal History routes (protected - require authentication)
 		withdrawalHandler := handlers.NewWithdrawalHandler(database.GetDBWrapper())
-		protectedRoutes.POST("/businesses/:id/withdrawals", withdrawalHandler.CreateWithdrawal)
-		protectedRoutes.GET("/businesses/:id/withdrawals", withdrawalHandler.GetWithdrawalHistory)
-		protectedRoutes.GET("/businesses/:id/withdrawals/:withdrawalId", withdrawalHandler.GetWithdrawal)
-		protectedRoutes.PUT("/businesses/:id/withdrawals/:withdrawalId/status", withdrawalHandler.UpdateWithdrawalStatus)
+		protectedRoutes.POST("/businesses/:id/withdrawals", can(database.PermissionFinancialWrite, byBusiness), withdrawalHandler.CreateWithdrawal)
+		protectedRoutes.GET("/businesses/:id/withdrawals", can(database.PermissionFinancialRead, byBusiness), withdrawalHandler.GetWithdrawalHistory)
+		protectedRoutes.GET("/businesses/:id/withdrawals/:withdrawalId", can(database.PermissionFinancialRead, byBusiness), withdrawalHandler.GetWithdrawal)
+		protectedRoutes.PUT("/businesses/:id/withdrawals/:withdrawalId/status", can(database.PermissionFinancialWrite, byBusiness), withdrawalHandler.UpdateWithdrawalStatus)
 
 		// Multi-currency and multilingual routes (protected - require authentication)
 		currencyHandler := handlers.NewCurrencyHandler(database.GetDBWrapper(), exchangeRateService, translationService)
-		protectedRoutes.GET("/businesses/:id/currencies", currencyHandler.GetBusinessCurrencies)
-		protectedRoutes.PUT("/businesses/:id/currencies", currencyHandler.UpdateBusinessCurrencies)
-		protectedRoutes.GET("/businesses/:id/languages", currencyHandler.GetBusinessLanguages)
-		protectedRoutes.PUT("/businesses/:id/languages", currencyHandler.UpdateBusinessLanguages)
-		protectedRoutes.GET("/translations", currencyHandler.GetTranslatedContent)
-		protectedRoutes.PUT("/translations", currencyHandler.UpdateTranslation)
-		protectedRoutes.GET("/menu-translations", currencyHandler.GetMenuTranslations)
+		protectedRoutes.GET("/businesses/:id/currencies", can(database.PermissionBusinessRead, byBusiness), currencyHandler.GetBusinessCurrencies)
+		protectedRoutes.PUT("/businesses/:id/currencies", can(database.PermissionBusinessWrite, byBusiness), currencyHandler.UpdateBusinessCurrencies)
+		protectedRoutes.GET("/businesses/:id/languages", can(database.PermissionBusinessRead, byBusiness), currencyHandler.GetBusinessLanguages)
+		protectedRoutes.PUT("/businesses/:id/languages", can(database.PermissionBusinessWrite, byBusiness), currencyHandler.UpdateBusinessLanguages)
+		byTranslatedEntity := middleware.TranslationEntity()
+		protectedRoutes.GET("/translations", can(database.PermissionMenuRead, byTranslatedEntity), currencyHandler.GetTranslatedContent)
+		protectedRoutes.PUT("/translations", can(database.PermissionMenuWrite, byTranslatedEntity), currencyHandler.UpdateTranslation)
+		protectedRoutes.GET("/menu-translations", can(database.PermissionMenuRead, middleware.BusinessQuery("business_id")), currencyHandler.GetMenuTranslations)
 
 		// Batch translation routes
-		protectedRoutes.POST("/businesses/:id/translate", server.TranslateEntireMenu)
-		protectedRoutes.GET("/translation-jobs/:jobId/status", server.GetTranslationStatus)
+		protectedRoutes.POST("/businesses/:id/translate", can(database.PermissionMenuWrite, byBusiness), server.TranslateEntireMenu)
+		protectedRoutes.GET("/translation-jobs/:jobId/status", can(database.PermissionMenuRead, middleware.TranslationJobParam("jobId")), server.GetTranslationStatus)
 	}
 
 	// Admin routes (require authentication and admin role)
@@ -481,11 +630,15 @@ func main() {
 		adminRoutes.PUT("/referrals/referrer/:wallet_address/deactivate", server.DeactivateReferrer)
 	}
 
-	// Go routine to clean up the challenge store every 5 minutes
+	// Go routine to clean up expired challenges and rate limit windows every 5 minutes
 	go func() {
 		for {
 			time.Sleep(5 * time.Minute)
-			server.ChallengeStore.Cleanup()
+			for _, cleanup := range cleanupSharedState {
+				if err := cleanup(); err != nil {
+					log.Printf("Failed to clean up shared state: %v", err)
+				}
+			}
 		}
 	}()
 
@@ -2,9 +2,11 @@ package analytics
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"payverge/internal/database"
+	"payverge/internal/money"
 )
 
 type AnalyticsService struct {
@@ -17,64 +19,64 @@ func NewAnalyticsService(db *database.DB) *AnalyticsService {
 
 // SalesReport represents daily sales data
 type SalesReport struct {
-	Date            time.Time `json:"date"`
-	BusinessID      uint      `json:"business_id"`
-	TotalRevenue    float64   `json:"total_revenue"`
-	TotalTips       float64   `json:"total_tips"`
-	TransactionCount int      `json:"transaction_count"`
-	BillCount       int       `json:"bill_count"`
-	AverageTicket   float64   `json:"average_ticket"`
-	PaymentMethods  map[string]int `json:"payment_methods"`
-	HourlyBreakdown []HourlySales `json:"hourly_breakdown"`
+	Date             time.Time      `json:"date"`
+	BusinessID       uint           `json:"business_id"`
+	TotalRevenue     money.Amount   `json:"total_revenue"`
+	TotalTips        money.Amount   `json:"total_tips"`
+	TransactionCount int            `json:"transaction_count"`
+	BillCount        int            `json:"bill_count"`
+	AverageTicket    money.Amount   `json:"average_ticket"`
+	PaymentMethods   map[string]int `json:"payment_methods"`
+	HourlyBreakdown  []HourlySales  `json:"hourly_breakdown"`
 }
 
 type HourlySales struct {
-	Hour     int     `json:"hour"`
-	Revenue  float64 `json:"revenue"`
-	Tips     float64 `json:"tips"`
-	BillCount int    `json:"bill_count"`
+	Hour      int          `json:"hour"`
+	Revenue   money.Amount `json:"revenue"`
+	Tips      money.Amount `json:"tips"`
+	BillCount int          `json:"bill_count"`
 }
 
 // ItemStats represents menu item performance
 type ItemStats struct {
-	ItemID       string  `json:"item_id"`
-	ItemName     string  `json:"item_name"`
-	Category     string  `json:"category"`
-	TotalSold    int     `json:"total_sold"`
-	Revenue      float64 `json:"revenue"`
-	AveragePrice float64 `json:"average_price"`
-	Popularity   float64 `json:"popularity"` // percentage of bills containing this item
+	ItemID       string       `json:"item_id"`
+	ItemName     string       `json:"item_name"`
+	Category     string       `json:"category"`
+	TotalSold    int          `json:"total_sold"`
+	Revenue      money.Amount `json:"revenue"`
+	AveragePrice money.Amount `json:"average_price"`
+	Popularity   float64      `json:"popularity"` // percentage of bills containing this item
 }
 
 // TipReport represents tip analytics
 type TipReport struct {
-	BusinessID      uint    `json:"business_id"`
-	Period          string  `json:"period"`
-	TotalTips       float64 `json:"total_tips"`
-	AverageTip      float64 `json:"average_tip"`
-	AverageTipRate  float64 `json:"average_tip_rate"` // percentage of bill
+	BusinessID      uint           `json:"business_id"`
+	Period          string         `json:"period"`
+	TotalTips       money.Amount   `json:"total_tips"`
+	AverageTip      money.Amount   `json:"average_tip"`
+	AverageTipRate  float64        `json:"average_tip_rate"` // percentage of bill
 	TipDistribution map[string]int `json:"tip_distribution"` // tip ranges
-	TopTippers      []TipperInfo `json:"top_tippers"`
+	TopTippers      []TipperInfo   `json:"top_tippers"`
 }
 
 type TipperInfo struct {
-	PayerAddress string  `json:"payer_address"`
-	TotalTips    float64 `json:"total_tips"`
-	TipCount     int     `json:"tip_count"`
-	AverageTip   float64 `json:"average_tip"`
+	PayerAddress string       `json:"payer_address"`
+	TotalTips    money.Amount `json:"total_tips"`
+	TipCount     int          `json:"tip_count"`
+	AverageTip   money.Amount `json:"average_tip"`
 }
 
 // PeriodReport represents analytics for a specific time period
 type PeriodReport struct {
-	StartDate       time.Time `json:"start_date"`
-	EndDate         time.Time `json:"end_date"`
-	TotalRevenue    float64   `json:"total_revenue"`
-	TotalTips       float64   `json:"total_tips"`
-	TransactionCount int      `json:"transaction_count"`
-	BillCount       int       `json:"bill_count"`
-	UniqueCustomers int       `json:"unique_customers"`
-	AverageTicket   float64   `json:"average_ticket"`
-	GrowthRate      float64   `json:"growth_rate"` // compared to previous period
+	StartDate        time.Time    `json:"start_date"`
+	EndDate          time.Time    `json:"end_date"`
+	TotalRevenue     money.Amount `json:"total_revenue"`
+	TotalTips        money.Amount `json:"total_tips"`
+	TransactionCount int          `json:"transaction_count"`
+	BillCount        int          `json:"bill_count"`
+	UniqueCustomers  int          `json:"unique_customers"`
+	AverageTicket    money.Amount `json:"average_ticket"`
+	GrowthRate       float64      `json:"growth_rate"` // compared to previous period
 }
 
 // GetDailySales returns sales data for a specific date
@@ -130,13 +132,14 @@ func (s *AnalyticsService) GetDailySales(businessID uint, date time.Time) (*Sale
 
 	// Calculate average ticket
 	if report.BillCount > 0 {
-		report.AverageTicket = report.TotalRevenue / float64(report.BillCount)
+		report.AverageTicket = report.TotalRevenue.Div(report.BillCount)
 	}
 
 	return report, nil
 }
 
-// GetPopularItems returns item performance statistics
+// GetPopularItems returns item performance statistics. Items are grouped by menu item ID
+// and named after the current menu; items without a menu item are grouped by name.
 func (s *AnalyticsService) GetPopularItems(businessID uint, period string) ([]ItemStats, error) {
 	startDate, endDate, err := s.parsePeriod(period)
 	if err != nil {
@@ -148,7 +151,13 @@ func (s *AnalyticsService) GetPopularItems(businessID uint, period string) ([]It
 		return nil, fmt.Errorf("failed to get bills: %w", err)
 	}
 
+	menuItems, err := s.db.GetMenuItemSummaries(businessID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get menu items: %w", err)
+	}
+
 	itemStats := make(map[string]*ItemStats)
+	billsWithItem := make(map[string]int)
 	totalBills := len(bills)
 
 	// Process each bill's items
@@ -161,17 +170,26 @@ func (s *AnalyticsService) GetPopularItems(businessID uint, period string) ([]It
 		billItemMap := make(map[string]bool) // Track unique items per bill
 
 		for _, item := range billItems {
-			itemKey := fmt.Sprintf("%s", item.Name)
-			
-			if _, exists := itemStats[itemKey]; !exists {
-				itemStats[itemKey] = &ItemStats{
-					ItemID:   item.ID,
-					ItemName: item.Name,
-					Category: "General", // Default category since BillItem doesn't have Category field
+			itemKey := "name:" + item.Name
+			stats := &ItemStats{
+				ItemID:   item.ID,
+				ItemName: item.Name,
+				Category: "General", // Items no longer on the menu have no category
+			}
+			if id, err := strconv.ParseUint(item.MenuItemID, 10, 32); err == nil {
+				itemKey = item.MenuItemID
+				stats.ItemID = item.MenuItemID
+				if menuItem, ok := menuItems[uint(id)]; ok {
+					stats.ItemName = menuItem.Name
+					stats.Category = menuItem.CategoryName
 				}
 			}
 
-			stats := itemStats[itemKey]
+			if _, exists := itemStats[itemKey]; !exists {
+				itemStats[itemKey] = stats
+			}
+
+			stats = itemStats[itemKey]
 			stats.TotalSold += item.Quantity
 			stats.Revenue += item.Subtotal
 
@@ -179,34 +197,19 @@ func (s *AnalyticsService) GetPopularItems(businessID uint, period string) ([]It
 			billItemMap[itemKey] = true
 		}
 
-		// Update popularity count (bills containing each item)
 		for itemKey := range billItemMap {
-			if stats, exists := itemStats[itemKey]; exists {
-				// We'll calculate popularity after processing all bills
-				stats.AveragePrice = stats.Revenue / float64(stats.TotalSold)
-			}
+			billsWithItem[itemKey]++
 		}
 	}
 
-	// Calculate popularity percentages and convert to slice
+	// Calculate averages and popularity percentages and convert to slice
 	var result []ItemStats
-	for _, stats := range itemStats {
+	for itemKey, stats := range itemStats {
+		if stats.TotalSold > 0 {
+			stats.AveragePrice = stats.Revenue.Div(stats.TotalSold)
+		}
 		if totalBills > 0 {
-			// Count bills containing this item
-			billsWithItem := 0
-			for _, bill := range bills {
-				billItems, err := s.db.GetBillItems(bill.ID)
-				if err != nil {
-					continue
-				}
-				for _, item := range billItems {
-					if item.Name == stats.ItemName {
-						billsWithItem++
-						break
-					}
-				}
-			}
-			stats.Popularity = (float64(billsWithItem) / float64(totalBills)) * 100
+			stats.Popularity = (float64(billsWithItem[itemKey]) / float64(totalBills)) * 100
 		}
 		result = append(result, *stats)
 	}
@@ -238,7 +241,7 @@ func (s *AnalyticsService) GetTipAnalytics(businessID uint, period string) (*Tip
 
 	for _, payment := range payments {
 		if payment.TipAmount > 0 {
-			report.TotalTips += float64(payment.TipAmount)
+			report.TotalTips += payment.TipAmount
 			tipCount++
 
 			// Calculate tip rate
@@ -255,12 +258,12 @@ func (s *AnalyticsService) GetTipAnalytics(businessID uint, period string) (*Tip
 					}
 				}
 				tipper := tipperMap[payment.PayerAddr]
-				tipper.TotalTips += float64(payment.TipAmount)
+				tipper.TotalTips += payment.TipAmount
 				tipper.TipCount++
 			}
 
 			// Categorize tip amounts
-			tipAmount := float64(payment.TipAmount)
+			tipAmount := payment.TipAmount.Float64()
 			switch {
 			case tipAmount < 5:
 				report.TipDistribution["$0-5"]++
@@ -278,14 +281,14 @@ func (s *AnalyticsService) GetTipAnalytics(businessID uint, period string) (*Tip
 
 	// Calculate averages
 	if tipCount > 0 {
-		report.AverageTip = report.TotalTips / float64(tipCount)
+		report.AverageTip = report.TotalTips.Div(tipCount)
 		report.AverageTipRate = totalTipRate / float64(tipCount)
 	}
 
 	// Calculate average tips for each tipper and sort
 	for _, tipper := range tipperMap {
 		if tipper.TipCount > 0 {
-			tipper.AverageTip = tipper.TotalTips / float64(tipper.TipCount)
+			tipper.AverageTip = tipper.TotalTips.Div(tipper.TipCount)
 		}
 		report.TopTippers = append(report.TopTippers, *tipper)
 	}
@@ -333,7 +336,7 @@ func (s *AnalyticsService) GetPeriodReport(businessID uint, period string) (*Per
 
 	// Calculate average ticket
 	if report.BillCount > 0 {
-		report.AverageTicket = report.TotalRevenue / float64(report.BillCount)
+		report.AverageTicket = report.TotalRevenue.Div(report.BillCount)
 	}
 
 	// TODO: Calculate growth rate compared to previous period
@@ -400,7 +403,7 @@ func (s *AnalyticsService) exportToCSV(bills []database.Bill) ([]byte, error) {
 	csv := "Date,Bill Number,Total Amount,Tip Amount,Status,Items\n"
 	
 	for _, bill := range bills {
-		csv += fmt.Sprintf("%s,%s,%.2f,%.2f,%s,%s\n",
+		csv += fmt.Sprintf("%s,%s,%s,%s,%s,%s\n",
 			bill.CreatedAt.Format("2006-01-02 15:04:05"),
 			bill.BillNumber,
 			bill.TotalAmount,
@@ -426,8 +429,8 @@ func (s *AnalyticsService) exportToJSON(bills []database.Bill) ([]byte, error) {
 		json += fmt.Sprintf(`{
 			"date": "%s",
 			"bill_number": "%s",
-			"total_amount": %.2f,
-			"tip_amount": %.2f,
+			"total_amount": %s,
+			"tip_amount": %s,
 			"status": "%s"
 		}`, 
 			bill.CreatedAt.Format("2006-01-02T15:04:05Z"),
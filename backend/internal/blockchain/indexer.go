@@ -0,0 +1,280 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ChainReader is the subset of the Ethereum client the indexer needs
+type ChainReader interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error)
+}
+
+// CheckpointStore persists the last block an indexer has fully processed
+type CheckpointStore interface {
+	LoadIndexerCheckpoint(name string) (uint64, bool, error)
+	SaveIndexerCheckpoint(name string, block uint64) error
+}
+
+// PaymentHandler processes a confirmed payment event. Returning an error keeps the
+// checkpoint before the event's batch, so the batch is redelivered on the next sync;
+// handlers must therefore be idempotent.
+type PaymentHandler func(Payment) error
+
+// IndexerConfig configures a PaymentIndexer
+type IndexerConfig struct {
+	Name          string        // Checkpoint key
+	StartBlock    uint64        // First block to scan when no checkpoint exists, normally the contract's deployment block; required until then
+	Confirmations uint64        // Blocks to wait before an event is considered final
+	BatchSize     uint64        // Maximum block range per FilterLogs call
+	PollInterval  time.Duration // Head polling interval when subscriptions are unavailable
+	MaxBackoff    time.Duration // Upper bound for retry backoff
+}
+
+// DefaultIndexerConfig returns sensible defaults for the payments indexer
+func DefaultIndexerConfig() IndexerConfig {
+	return IndexerConfig{
+		Name:          "payverge_payments",
+		Confirmations: 5,
+		BatchSize:     2000,
+		PollInterval:  15 * time.Second,
+		MaxBackoff:    2 * time.Minute,
+	}
+}
+
+// ErrStartBlockRequired is returned when an indexer without a checkpoint has no start block
+var ErrStartBlockRequired = errors.New("indexer start block is required until a checkpoint exists")
+
+// PaymentIndexer follows PaymentMade events, persisting progress so that events
+// emitted while the server was down are backfilled on the next start
+type PaymentIndexer struct {
+	chain   ChainReader
+	store   CheckpointStore
+	config  IndexerConfig
+	query   ethereum.FilterQuery
+	parse   func(types.Log) (Payment, error)
+	handler PaymentHandler
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPaymentIndexer creates an indexer for the payment contract
+func (s *BlockchainService) NewPaymentIndexer(store CheckpointStore, config IndexerConfig, handler PaymentHandler) *PaymentIndexer {
+	return newPaymentIndexer(s.client, store, config, s.contractAddress, s.paymentMadeTopic(), s.parsePaymentEvent, handler)
+}
+
+func newPaymentIndexer(chain ChainReader, store CheckpointStore, config IndexerConfig, contract common.Address, topic common.Hash, parse func(types.Log) (Payment, error), handler PaymentHandler) *PaymentIndexer {
+	defaults := DefaultIndexerConfig()
+	if config.Name == "" {
+		config.Name = defaults.Name
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = defaults.BatchSize
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaults.PollInterval
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = defaults.MaxBackoff
+	}
+
+	return &PaymentIndexer{
+		chain:  chain,
+		store:  store,
+		config: config,
+		query: ethereum.FilterQuery{
+			Addresses: []common.Address{contract},
+			Topics:    [][]common.Hash{{topic}},
+		},
+		parse:   parse,
+		handler: handler,
+	}
+}
+
+// Start begins indexing in the background
+func (idx *PaymentIndexer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	idx.cancel = cancel
+	idx.done = make(chan struct{})
+	go idx.run(ctx)
+}
+
+// Stop halts the indexer and waits for the current batch to finish
+func (idx *PaymentIndexer) Stop() {
+	if idx.cancel == nil {
+		return
+	}
+	idx.cancel()
+	<-idx.done
+}
+
+// run catches up to the confirmed head, then waits for new blocks, retrying with backoff on errors
+func (idx *PaymentIndexer) run(ctx context.Context) {
+	defer close(idx.done)
+
+	heads := make(chan *types.Header, 16)
+	var sub ethereum.Subscription
+	defer func() {
+		if sub != nil {
+			sub.Unsubscribe()
+		}
+	}()
+
+	backoff := time.Second
+	for {
+		// (Re)subscribe to new heads; fall back to polling when unsupported
+		if sub == nil {
+			s, err := idx.chain.SubscribeNewHead(ctx, heads)
+			if err == nil {
+				sub = s
+			}
+		}
+
+		if err := idx.Sync(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Payment indexer sync failed, retrying in %s: %v", backoff, err)
+			if !sleepContext(ctx, backoff) {
+				return
+			}
+			backoff *= 2
+			if backoff > idx.config.MaxBackoff {
+				backoff = idx.config.MaxBackoff
+			}
+			continue
+		}
+		backoff = time.Second
+
+		var subErr <-chan error
+		if sub != nil {
+			subErr = sub.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-heads:
+		case err := <-subErr:
+			log.Printf("Payment indexer head subscription dropped: %v", err)
+			sub.Unsubscribe()
+			sub = nil
+		case <-time.After(idx.config.PollInterval):
+		}
+	}
+}
+
+// Sync processes every confirmed block after the checkpoint, in bounded ranges
+func (idx *PaymentIndexer) Sync(ctx context.Context) error {
+	head, err := idx.chain.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get block number: %v", err)
+	}
+	if head < idx.config.Confirmations {
+		return nil
+	}
+	safeHead := head - idx.config.Confirmations
+
+	next, err := idx.nextBlock()
+	if err != nil {
+		return err
+	}
+
+	for next <= safeHead {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		to := next + idx.config.BatchSize - 1
+		if to > safeHead {
+			to = safeHead
+		}
+
+		if err := idx.processRange(ctx, next, to); err != nil {
+			return err
+		}
+		if err := idx.store.SaveIndexerCheckpoint(idx.config.Name, to); err != nil {
+			return err
+		}
+		next = to + 1
+	}
+
+	return nil
+}
+
+// nextBlock returns the first block that has not been processed yet
+func (idx *PaymentIndexer) nextBlock() (uint64, error) {
+	last, ok, err := idx.store.LoadIndexerCheckpoint(idx.config.Name)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		// Scanning from genesis would take days of FilterLogs calls for a contract
+		// deployed long after it, so the first block must be given explicitly
+		if idx.config.StartBlock == 0 {
+			return 0, ErrStartBlockRequired
+		}
+		return idx.config.StartBlock, nil
+	}
+	return last + 1, nil
+}
+
+// processRange fetches and handles the events in [from, to] in chain order. A log that
+// cannot be parsed fails the range, so the checkpoint never moves past an event that
+// was not handled.
+func (idx *PaymentIndexer) processRange(ctx context.Context, from, to uint64) error {
+	query := idx.query
+	query.FromBlock = new(big.Int).SetUint64(from)
+	query.ToBlock = new(big.Int).SetUint64(to)
+
+	logs, err := idx.chain.FilterLogs(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to filter logs for blocks %d-%d: %v", from, to, err)
+	}
+
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+
+	for _, vLog := range logs {
+		if vLog.Removed {
+			continue
+		}
+		payment, err := idx.parse(vLog)
+		if err != nil {
+			return fmt.Errorf("failed to parse payment event in tx %s: %v", vLog.TxHash.Hex(), err)
+		}
+		if err := idx.handler(payment); err != nil {
+			return fmt.Errorf("failed to handle payment %s: %v", payment.TransactionHash, err)
+		}
+	}
+
+	return nil
+}
+
+// sleepContext waits for d or until ctx is cancelled; it reports whether the full duration elapsed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,203 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"payverge/internal/money"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeChain struct {
+	head    uint64
+	logs    []types.Log
+	queries [][2]uint64
+}
+
+func (f *fakeChain) BlockNumber(ctx context.Context) (uint64, error) { return f.head, nil }
+
+func (f *fakeChain) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	f.queries = append(f.queries, [2]uint64{from, to})
+	var out []types.Log
+	for _, l := range f.logs {
+		if l.BlockNumber >= from && l.BlockNumber <= to {
+			out = append(out, l)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeChain) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return nil, errors.New("notifications not supported")
+}
+
+type memoryCheckpoints map[string]uint64
+
+func (m memoryCheckpoints) LoadIndexerCheckpoint(name string) (uint64, bool, error) {
+	block, ok := m[name]
+	return block, ok, nil
+}
+
+func (m memoryCheckpoints) SaveIndexerCheckpoint(name string, block uint64) error {
+	m[name] = block
+	return nil
+}
+
+func testService(t *testing.T) *BlockchainService {
+	parsed, err := abi.JSON(strings.NewReader(PayvergePaymentsABI))
+	require.NoError(t, err)
+	return &BlockchainService{contractABI: parsed, contractAddress: common.HexToAddress("0x1")}
+}
+
+func paymentLog(t *testing.T, s *BlockchainService, billID int64, block uint64, index uint, amount int64) types.Log {
+	event := s.contractABI.Events["PaymentMade"]
+	data, err := event.Inputs.NonIndexed().Pack(
+		big.NewInt(amount),
+		big.NewInt(0),
+		common.HexToAddress("0x00000000000000000000000000000000000000cc"),
+		big.NewInt(0),
+		big.NewInt(1700000000),
+	)
+	require.NoError(t, err)
+
+	return types.Log{
+		Address: s.contractAddress,
+		Topics: []common.Hash{
+			event.ID,
+			common.BytesToHash(big.NewInt(billID).Bytes()),
+			common.BytesToHash(common.HexToAddress("0x00000000000000000000000000000000000000aa").Bytes()),
+			common.BytesToHash(common.HexToAddress("0x00000000000000000000000000000000000000bb").Bytes()),
+		},
+		Data:        data,
+		BlockNumber: block,
+		Index:       index,
+		TxHash:      common.BigToHash(big.NewInt(int64(block*100) + int64(index))),
+	}
+}
+
+func TestParsePaymentEventReadsIndexedTopics(t *testing.T) {
+	s := testService(t)
+
+	payment, err := s.parsePaymentEvent(paymentLog(t, s, 42, 10, 3, 1500000))
+	require.NoError(t, err)
+	assert.Equal(t, "42", payment.BillID)
+	assert.True(t, strings.EqualFold("0x00000000000000000000000000000000000000aa", payment.Payer))
+	assert.Equal(t, money.FromCents(150), payment.Amount, "USDC base units are converted to cents")
+	assert.Equal(t, uint(3), payment.LogIndex)
+	assert.Equal(t, uint64(10), payment.BlockNumber)
+}
+
+func TestParsePaymentEventFlagsSubCentAmounts(t *testing.T) {
+	s := testService(t)
+
+	payment, err := s.parsePaymentEvent(paymentLog(t, s, 42, 10, 3, 1500001))
+	require.NoError(t, err, "the event itself is well formed")
+	assert.Equal(t, "42", payment.BillID)
+	assert.Contains(t, payment.AmountError, money.ErrSubCentUSDC.Error())
+	assert.Equal(t, money.Zero, payment.Amount)
+}
+
+func TestIndexerBackfillsConfirmedBlocksInBatches(t *testing.T) {
+	s := testService(t)
+	chain := &fakeChain{
+		head: 30,
+		logs: []types.Log{
+			paymentLog(t, s, 2, 12, 1, 2000000),
+			paymentLog(t, s, 1, 12, 0, 1000000),
+			paymentLog(t, s, 3, 27, 0, 3000000), // not yet confirmed
+		},
+	}
+	store := memoryCheckpoints{}
+
+	var seen []string
+	idx := newPaymentIndexer(chain, store, IndexerConfig{StartBlock: 5, Confirmations: 5, BatchSize: 10},
+		s.contractAddress, s.paymentMadeTopic(), s.parsePaymentEvent,
+		func(p Payment) error {
+			seen = append(seen, p.BillID)
+			return nil
+		})
+
+	require.NoError(t, idx.Sync(context.Background()))
+	assert.Equal(t, []string{"1", "2"}, seen, "events are delivered in log order")
+	assert.Equal(t, [][2]uint64{{5, 14}, {15, 24}, {25, 25}}, chain.queries)
+	assert.Equal(t, uint64(25), store["payverge_payments"])
+
+	// Once more blocks are mined the pending event becomes final
+	chain.head = 32
+	chain.queries = nil
+	require.NoError(t, idx.Sync(context.Background()))
+	assert.Equal(t, []string{"1", "2", "3"}, seen)
+	assert.Equal(t, [][2]uint64{{26, 27}}, chain.queries)
+}
+
+func TestIndexerDoesNotAdvancePastFailedEvent(t *testing.T) {
+	s := testService(t)
+	chain := &fakeChain{head: 20, logs: []types.Log{paymentLog(t, s, 7, 8, 0, 1000000)}}
+	store := memoryCheckpoints{"payverge_payments": 4}
+
+	fail := true
+	calls := 0
+	idx := newPaymentIndexer(chain, store, IndexerConfig{Confirmations: 2, BatchSize: 100},
+		s.contractAddress, s.paymentMadeTopic(), s.parsePaymentEvent,
+		func(p Payment) error {
+			calls++
+			if fail {
+				return errors.New("database unavailable")
+			}
+			return nil
+		})
+
+	assert.Error(t, idx.Sync(context.Background()))
+	assert.Equal(t, uint64(4), store["payverge_payments"])
+
+	fail = false
+	require.NoError(t, idx.Sync(context.Background()))
+	assert.Equal(t, 2, calls, "failed batch is redelivered")
+	assert.Equal(t, uint64(18), store["payverge_payments"])
+}
+
+func TestIndexerDoesNotAdvancePastUnparseableEvent(t *testing.T) {
+	s := testService(t)
+	broken := paymentLog(t, s, 7, 8, 0, 1000000)
+	broken.Data = broken.Data[:10]
+	chain := &fakeChain{head: 20, logs: []types.Log{broken}}
+	store := memoryCheckpoints{"payverge_payments": 4}
+
+	idx := newPaymentIndexer(chain, store, IndexerConfig{Confirmations: 2, BatchSize: 100},
+		s.contractAddress, s.paymentMadeTopic(), s.parsePaymentEvent,
+		func(p Payment) error {
+			t.Fatalf("unparseable event was delivered: %+v", p)
+			return nil
+		})
+
+	assert.Error(t, idx.Sync(context.Background()))
+	assert.Equal(t, uint64(4), store["payverge_payments"])
+}
+
+func TestIndexerRequiresStartBlockWithoutCheckpoint(t *testing.T) {
+	s := testService(t)
+	chain := &fakeChain{head: 20}
+	store := memoryCheckpoints{}
+
+	idx := newPaymentIndexer(chain, store, IndexerConfig{Confirmations: 2},
+		s.contractAddress, s.paymentMadeTopic(), s.parsePaymentEvent,
+		func(p Payment) error { return nil })
+
+	assert.ErrorIs(t, idx.Sync(context.Background()), ErrStartBlockRequired)
+	assert.Empty(t, chain.queries, "nothing is scanned from genesis")
+
+	// A checkpoint is enough on its own
+	store["payverge_payments"] = 10
+	require.NoError(t, idx.Sync(context.Background()))
+	assert.Equal(t, uint64(18), store["payverge_payments"])
+}
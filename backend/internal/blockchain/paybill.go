@@ -0,0 +1,93 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"payverge/internal/money"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// PayBillCall is a payBill contract call prepared for a guest's wallet to sign. The
+// contract sends the tip to the tip address in the same transaction as the payment.
+type PayBillCall struct {
+	Contract        string       `json:"contract,omitempty"`
+	BillID          string       `json:"bill_id"` // bytes32 bill ID as the contract expects it
+	Amount          money.Amount `json:"amount"`
+	TipAmount       money.Amount `json:"tip_amount"`
+	AmountUnits     string       `json:"amount_units"`     // USDC base units
+	TipAmountUnits  string       `json:"tip_amount_units"` // USDC base units
+	BusinessAddress string       `json:"business_address"`
+	TipAddress      string       `json:"tip_address"`
+	Data            string       `json:"data"` // ABI-encoded call data
+}
+
+// NewPayBillCall encodes a payBill call paying amount to the business and tip to the
+// tip address
+func NewPayBillCall(billID string, amount, tip money.Amount, businessAddress, tipAddress string) (*PayBillCall, error) {
+	if amount < 0 || tip < 0 {
+		return nil, errors.New("payment amounts cannot be negative")
+	}
+	if !common.IsHexAddress(businessAddress) {
+		return nil, fmt.Errorf("invalid business address %q", businessAddress)
+	}
+	if !common.IsHexAddress(tipAddress) {
+		if tip > 0 {
+			return nil, fmt.Errorf("invalid tip address %q", tipAddress)
+		}
+		// Without a tip the address is unused; the contract accepts the zero address
+		tipAddress = common.Address{}.Hex()
+	}
+
+	billIDBytes, err := billIDHash(billID)
+	if err != nil {
+		return nil, err
+	}
+
+	contractABI, err := abi.JSON(strings.NewReader(PayvergePaymentsABI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse contract ABI: %v", err)
+	}
+	business := common.HexToAddress(businessAddress)
+	tipTo := common.HexToAddress(tipAddress)
+	data, err := contractABI.Pack("payBill", billIDBytes, amount.USDCBig(), tip.USDCBig(), business, tipTo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack function call: %v", err)
+	}
+
+	return &PayBillCall{
+		BillID:          billIDBytes.Hex(),
+		Amount:          amount,
+		TipAmount:       tip,
+		AmountUnits:     amount.USDCBig().String(),
+		TipAmountUnits:  tip.USDCBig().String(),
+		BusinessAddress: business.Hex(),
+		TipAddress:      tipTo.Hex(),
+		Data:            hexutil.Encode(data),
+	}, nil
+}
+
+// NewPayBillCall encodes a payBill call addressed to the service's contract
+func (s *BlockchainService) NewPayBillCall(billID string, amount, tip money.Amount, businessAddress, tipAddress string) (*PayBillCall, error) {
+	call, err := NewPayBillCall(billID, amount, tip, businessAddress, tipAddress)
+	if err != nil {
+		return nil, err
+	}
+	call.Contract = s.contractAddress.Hex()
+	return call, nil
+}
+
+// billIDHash converts a numeric bill ID to the left-padded bytes32 the contract uses
+func billIDHash(billID string) (common.Hash, error) {
+	billIDInt, err := strconv.ParseUint(billID, 10, 64)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("invalid bill ID format: %v", err)
+	}
+	return common.BigToHash(new(big.Int).SetUint64(billIDInt)), nil
+}
@@ -0,0 +1,54 @@
+package blockchain
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"payverge/internal/money"
+)
+
+func TestNewPayBillCall(t *testing.T) {
+	business := "0x1111111111111111111111111111111111111111"
+	tipping := "0x2222222222222222222222222222222222222222"
+
+	call, err := NewPayBillCall("42", money.MustParse("12.50"), money.MustParse("2.25"), business, tipping)
+	require.NoError(t, err)
+	assert.Equal(t, "12500000", call.AmountUnits)
+	assert.Equal(t, "2250000", call.TipAmountUnits)
+	assert.Equal(t, common.BigToHash(big.NewInt(42)).Hex(), call.BillID)
+
+	parsed, err := abi.JSON(strings.NewReader(PayvergePaymentsABI))
+	require.NoError(t, err)
+	data, err := hexutil.Decode(call.Data)
+	require.NoError(t, err)
+	method, err := parsed.MethodById(data[:4])
+	require.NoError(t, err)
+	assert.Equal(t, "payBill", method.Name)
+
+	args, err := method.Inputs.Unpack(data[4:])
+	require.NoError(t, err)
+	assert.Equal(t, big.NewInt(12_500_000), args[1])
+	assert.Equal(t, big.NewInt(2_250_000), args[2])
+	assert.Equal(t, common.HexToAddress(business), args[3])
+	assert.Equal(t, common.HexToAddress(tipping), args[4])
+}
+
+func TestNewPayBillCallRequiresTipAddressForTips(t *testing.T) {
+	business := "0x1111111111111111111111111111111111111111"
+
+	_, err := NewPayBillCall("1", money.MustParse("5.00"), money.MustParse("1.00"), business, "")
+	assert.Error(t, err)
+
+	call, err := NewPayBillCall("1", money.MustParse("5.00"), money.Zero, business, "")
+	require.NoError(t, err)
+	assert.Equal(t, common.Address{}.Hex(), call.TipAddress)
+
+	_, err = NewPayBillCall("1", money.MustParse("5.00"), money.Zero, "not-an-address", "")
+	assert.Error(t, err)
+}
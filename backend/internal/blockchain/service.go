@@ -4,12 +4,12 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"fmt"
-	"log"
 	"math/big"
-	"strconv"
 	"strings"
 	"time"
 
+	"payverge/internal/money"
+
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -36,23 +36,27 @@ type PaymentResult struct {
 	Timestamp       time.Time `json:"timestamp"`
 }
 
-// Payment represents a payment record from the blockchain
+// Payment represents a payment record from the blockchain. Amounts are converted
+// from USDC base units when the event is parsed.
 type Payment struct {
-	BillID          string    `json:"bill_id"`
-	Payer           string    `json:"payer"`
-	Amount          int64     `json:"amount"`
-	TipAmount       int64     `json:"tip_amount"`
-	PlatformFee     int64     `json:"platform_fee"`
-	Timestamp       time.Time `json:"timestamp"`
-	TransactionHash string    `json:"transaction_hash"`
+	BillID          string       `json:"bill_id"`
+	Payer           string       `json:"payer"`
+	Amount          money.Amount `json:"amount"`
+	TipAmount       money.Amount `json:"tip_amount"`
+	PlatformFee     money.Amount `json:"platform_fee"`
+	Timestamp       time.Time    `json:"timestamp"`
+	TransactionHash string       `json:"transaction_hash"`
+	LogIndex        uint         `json:"log_index"`
+	BlockNumber     uint64       `json:"block_number"`
+	AmountError     string       `json:"amount_error,omitempty"` // Why the amounts could not be converted to cents; they are left zero
 }
 
 // Participant represents a bill participant from the unified payment system
 type Participant struct {
-	Address      string `json:"address"`
-	PaidAmount   int64  `json:"paid_amount"`
-	PaymentCount int    `json:"payment_count"`
-	LastPayment  int64  `json:"last_payment"`
+	Address      string       `json:"address"`
+	PaidAmount   money.Amount `json:"paid_amount"`
+	PaymentCount int          `json:"payment_count"`
+	LastPayment  int64        `json:"last_payment"`
 }
 
 // BlockchainService handles blockchain interactions
@@ -246,19 +250,18 @@ func NewBlockchainService(rpcURL, contractAddress, privateKeyHex string) (*Block
 }
 
 // CreateBill creates a bill record on the blockchain (unified payment system)
-func (s *BlockchainService) CreateBill(billID string, businessAddress string, totalAmount int64, metadata string, nonce string) (*PaymentResult, error) {
+func (s *BlockchainService) CreateBill(billID string, businessAddress string, totalAmount money.Amount, metadata string, nonce string) (*PaymentResult, error) {
 	// Convert bill ID to bytes32 (padded format to match frontend)
-	billIDInt, err := strconv.ParseUint(billID, 10, 64)
+	billIDBytes, err := billIDHash(billID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid bill ID format: %v", err)
+		return nil, err
 	}
-	billIDBytes := common.BytesToHash(big.NewInt(int64(billIDInt)).Bytes())
 
 	// Convert business address
 	businessAddr := common.HexToAddress(businessAddress)
 
-	// Convert amount to wei (USDC has 6 decimals)
-	amountWei := big.NewInt(totalAmount)
+	// Convert amount to USDC base units (6 decimals)
+	amountWei := totalAmount.USDCBig()
 
 	// Convert nonce to bytes32
 	nonceBytes := crypto.Keccak256Hash([]byte(nonce))
@@ -374,7 +377,7 @@ func (s *BlockchainService) GetBillPayments(ctx context.Context, billID string)
 }
 
 // GetBillTotalPaid gets the total amount paid for a bill
-func (s *BlockchainService) GetBillTotalPaid(billID string) (int64, error) {
+func (s *BlockchainService) GetBillTotalPaid(billID string) (money.Amount, error) {
 	// Convert bill ID to bytes32
 	billIDBytes := crypto.Keccak256Hash([]byte(billID))
 
@@ -400,60 +403,28 @@ func (s *BlockchainService) GetBillTotalPaid(billID string) (int64, error) {
 		return 0, fmt.Errorf("failed to unpack result: %v", err)
 	}
 
-	return totalPaid.Int64(), nil
+	return money.FromUSDCBig(totalPaid)
 }
 
-// MonitorPayments starts monitoring for payment events
-func (s *BlockchainService) MonitorPayments(callback func(Payment)) error {
-	// Create filter for PaymentMade events
-	query := ethereum.FilterQuery{
-		Addresses: []common.Address{s.contractAddress},
-		Topics: [][]common.Hash{
-			{crypto.Keccak256Hash([]byte("PaymentMade(bytes32,address,uint256,uint256,address,address,uint256,uint256)"))},
-		},
-	}
-
-	// Subscribe to logs
-	logs := make(chan types.Log)
-	sub, err := s.client.SubscribeFilterLogs(context.Background(), query, logs)
-	if err != nil {
-		return fmt.Errorf("failed to subscribe to logs: %v", err)
-	}
-
-	// Process logs
-	go func() {
-		defer sub.Unsubscribe()
-		for {
-			select {
-			case err := <-sub.Err():
-				log.Printf("Subscription error: %v", err)
-				return
-			case vLog := <-logs:
-				payment, err := s.parsePaymentEvent(vLog)
-				if err != nil {
-					log.Printf("Failed to parse payment event: %v", err)
-					continue
-				}
-				callback(payment)
-			}
-		}
-	}()
-
-	return nil
+// paymentMadeTopic returns the event signature hash of PaymentMade
+func (s *BlockchainService) paymentMadeTopic() common.Hash {
+	return s.contractABI.Events["PaymentMade"].ID
 }
 
-// parsePaymentEvent parses a PaymentMade event log
+// parsePaymentEvent parses a PaymentMade event log. The bill ID, payer and business
+// address are indexed and therefore read from the topics, not the data. Only a log
+// that is not a well-formed PaymentMade event is an error.
 func (s *BlockchainService) parsePaymentEvent(vLog types.Log) (Payment, error) {
-	// Parse the event
+	if len(vLog.Topics) < 4 {
+		return Payment{}, fmt.Errorf("unexpected topic count %d", len(vLog.Topics))
+	}
+
 	event := struct {
-		BillID          [32]byte
-		Payer           common.Address
-		Amount          *big.Int
-		TipAmount       *big.Int
-		BusinessAddress common.Address
-		TipAddress      common.Address
-		PlatformFee     *big.Int
-		Timestamp       *big.Int
+		Amount      *big.Int
+		TipAmount   *big.Int
+		TipAddress  common.Address
+		PlatformFee *big.Int
+		Timestamp   *big.Int
 	}{}
 
 	err := s.contractABI.UnpackIntoInterface(&event, "PaymentMade", vLog.Data)
@@ -461,15 +432,37 @@ func (s *BlockchainService) parsePaymentEvent(vLog types.Log) (Payment, error) {
 		return Payment{}, fmt.Errorf("failed to unpack event: %v", err)
 	}
 
-	return Payment{
-		BillID:          string(event.BillID[:]),
-		Payer:           event.Payer.Hex(),
-		Amount:          event.Amount.Int64(),
-		TipAmount:       event.TipAmount.Int64(),
-		PlatformFee:     event.PlatformFee.Int64(),
+	payment := Payment{
+		BillID:          new(big.Int).SetBytes(vLog.Topics[1].Bytes()).String(),
+		Payer:           common.BytesToAddress(vLog.Topics[2].Bytes()).Hex(),
 		Timestamp:       time.Unix(event.Timestamp.Int64(), 0),
 		TransactionHash: vLog.TxHash.Hex(),
-	}, nil
+		LogIndex:        vLog.Index,
+		BlockNumber:     vLog.BlockNumber,
+	}
+
+	// The event is final whatever its amounts are, so amounts the ledger cannot hold
+	// are reported on the payment for staff to reconcile rather than failing the log
+	amount, err := money.FromUSDCBig(event.Amount)
+	if err != nil {
+		payment.AmountError = fmt.Sprintf("invalid payment amount: %v", err)
+		return payment, nil
+	}
+	tip, err := money.FromUSDCBig(event.TipAmount)
+	if err != nil {
+		payment.AmountError = fmt.Sprintf("invalid tip amount: %v", err)
+		return payment, nil
+	}
+	fee, err := money.FromUSDCBig(event.PlatformFee)
+	if err != nil {
+		payment.AmountError = fmt.Sprintf("invalid platform fee: %v", err)
+		return payment, nil
+	}
+
+	payment.Amount = amount
+	payment.TipAmount = tip
+	payment.PlatformFee = fee
+	return payment, nil
 }
 
 // getAuth creates transaction auth
@@ -576,9 +569,14 @@ func (s *BlockchainService) GetParticipantInfo(ctx context.Context, billID strin
 		return nil, fmt.Errorf("failed to unpack result: %v", err)
 	}
 
+	paidAmount, err := money.FromUSDCBig(info.PaidAmount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid paid amount: %w", err)
+	}
+
 	return &Participant{
 		Address:      participant,
-		PaidAmount:   info.PaidAmount.Int64(),
+		PaidAmount:   paidAmount,
 		PaymentCount: int(info.PaymentCount),
 		LastPayment:  int64(info.LastPayment),
 	}, nil
@@ -612,15 +610,29 @@ func (s *BlockchainService) GetBillSummary(ctx context.Context, billID string) (
 		return nil, fmt.Errorf("failed to unpack result: %v", err)
 	}
 
+	totalAmount, err := money.FromUSDCBig(summary.TotalAmount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid total amount: %w", err)
+	}
+	paidAmount, err := money.FromUSDCBig(summary.PaidAmount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid paid amount: %w", err)
+	}
+
 	return map[string]interface{}{
-		"total_amount":      summary.TotalAmount.Int64(),
-		"paid_amount":       summary.PaidAmount.Int64(),
+		"total_amount":      totalAmount,
+		"paid_amount":       paidAmount,
 		"participant_count": int(summary.ParticipantCount),
 		"is_paid":           summary.IsPaid,
-		"remaining_amount":  summary.TotalAmount.Int64() - summary.PaidAmount.Int64(),
+		"remaining_amount":  totalAmount - paidAmount,
 	}, nil
 }
 
+// Client returns the Ethereum client the service is connected with
+func (s *BlockchainService) Client() *ethclient.Client {
+	return s.client
+}
+
 // Close closes the blockchain service
 func (s *BlockchainService) Close() {
 	if s.client != nil {
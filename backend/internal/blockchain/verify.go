@@ -0,0 +1,84 @@
+package blockchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	// ErrTransactionNotFound is returned when a transaction has not been mined
+	ErrTransactionNotFound = errors.New("transaction not found on chain")
+	// ErrTransactionFailed is returned when a transaction was mined but reverted
+	ErrTransactionFailed = errors.New("transaction reverted on chain")
+	// ErrPaymentNotFound is returned when a transaction emitted no PaymentMade event for the bill
+	ErrPaymentNotFound = errors.New("transaction has no payment event for this bill")
+)
+
+// ReceiptReader is the subset of the Ethereum client needed to inspect mined transactions
+type ReceiptReader interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// PaymentEventsForBill returns the PaymentMade events that a successful transaction
+// emitted for billID, in log order. The flag reports whether the transaction's block
+// has at least confirmations blocks on top of it, the depth the indexer waits for;
+// events that are not final yet may still be dropped by a reorg.
+func (s *BlockchainService) PaymentEventsForBill(ctx context.Context, txHash string, billID string, confirmations uint64) ([]Payment, bool, error) {
+	return s.paymentEventsForBill(ctx, s.client, txHash, billID, confirmations)
+}
+
+func (s *BlockchainService) paymentEventsForBill(ctx context.Context, reader ReceiptReader, txHash string, billID string, confirmations uint64) ([]Payment, bool, error) {
+	raw, err := hexutil.Decode(strings.TrimSpace(txHash))
+	if err != nil || len(raw) != common.HashLength {
+		return nil, false, fmt.Errorf("invalid transaction hash %q", txHash)
+	}
+
+	receipt, err := reader.TransactionReceipt(ctx, common.BytesToHash(raw))
+	if errors.Is(err, ethereum.NotFound) {
+		return nil, false, ErrTransactionNotFound
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get transaction receipt: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, false, ErrTransactionFailed
+	}
+
+	head, err := reader.BlockNumber(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get block number: %v", err)
+	}
+	final := receipt.BlockNumber != nil && head >= confirmations && receipt.BlockNumber.Uint64() <= head-confirmations
+
+	topic := s.paymentMadeTopic()
+	var payments []Payment
+	for _, vLog := range receipt.Logs {
+		if vLog == nil || vLog.Removed || vLog.Address != s.contractAddress {
+			continue
+		}
+		if len(vLog.Topics) == 0 || vLog.Topics[0] != topic {
+			continue
+		}
+
+		payment, err := s.parsePaymentEvent(*vLog)
+		if err != nil {
+			return nil, false, err
+		}
+		if payment.BillID == billID {
+			payments = append(payments, payment)
+		}
+	}
+
+	if len(payments) == 0 {
+		return nil, false, ErrPaymentNotFound
+	}
+	return payments, final, nil
+}
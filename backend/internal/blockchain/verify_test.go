@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReceipts struct {
+	head     uint64
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (f fakeReceipts) BlockNumber(ctx context.Context) (uint64, error) { return f.head, nil }
+
+func (f fakeReceipts) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	receipt, ok := f.receipts[txHash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func TestPaymentEventsForBill(t *testing.T) {
+	s := testService(t)
+
+	billLog := paymentLog(t, s, 7, 10, 1, 2500000)
+	otherBill := paymentLog(t, s, 8, 10, 2, 1000000)
+	foreign := paymentLog(t, s, 7, 10, 3, 1000000)
+	foreign.Address = common.HexToAddress("0x2")
+
+	txHash := common.HexToHash("0xabc")
+	reverted := common.HexToHash("0xdef")
+	receipts := fakeReceipts{head: 14, receipts: map[common.Hash]*types.Receipt{
+		txHash:   {Status: types.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10), Logs: []*types.Log{&otherBill, &billLog, &foreign}},
+		reverted: {Status: types.ReceiptStatusFailed, BlockNumber: big.NewInt(10), Logs: []*types.Log{&billLog}},
+	}}
+
+	payments, final, err := s.paymentEventsForBill(context.Background(), receipts, txHash.Hex(), "7", 5)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, uint(1), payments[0].LogIndex)
+	assert.Equal(t, int64(250), payments[0].Amount.Cents())
+	assert.False(t, final, "four blocks on top are not enough")
+
+	receipts.head = 15
+	_, final, err = s.paymentEventsForBill(context.Background(), receipts, txHash.Hex(), "7", 5)
+	require.NoError(t, err)
+	assert.True(t, final)
+
+	_, _, err = s.paymentEventsForBill(context.Background(), receipts, txHash.Hex(), "9", 5)
+	assert.ErrorIs(t, err, ErrPaymentNotFound)
+
+	_, _, err = s.paymentEventsForBill(context.Background(), receipts, reverted.Hex(), "7", 5)
+	assert.ErrorIs(t, err, ErrTransactionFailed)
+
+	_, _, err = s.paymentEventsForBill(context.Background(), receipts, common.HexToHash("0x123").Hex(), "7", 5)
+	assert.ErrorIs(t, err, ErrTransactionNotFound)
+
+	for _, bad := range []string{"", "0x1234", fmt.Sprintf("%s00", txHash.Hex())} {
+		_, _, err = s.paymentEventsForBill(context.Background(), receipts, bad, "7", 5)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// How long a sign-in lasts before the device has to sign in again, however often it
+// refreshes: a month for wallet users, about one shift for staff
+const (
+	UserSessionLifetime  = 30 * 24 * time.Hour
+	StaffSessionLifetime = 12 * time.Hour
+)
+
+// Reasons recorded when an auth session is revoked
+const (
+	AuthSessionRevokedSignOut       = "signed_out"
+	AuthSessionRevokedByUser        = "revoked"
+	AuthSessionRevokedTokenReused   = "refresh_token_reused"
+	AuthSessionRevokedStaffInactive = "staff_inactive"
+)
+
+var (
+	// ErrAuthSessionInvalid is returned for a refresh token or session that is unknown,
+	// expired or revoked
+	ErrAuthSessionInvalid = errors.New("session is invalid or has ended")
+	// ErrRefreshTokenReused is returned when a refresh token is presented after it was
+	// rotated. Only a copy of the token can do that, so the session is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+)
+
+func newRefreshToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// CreateAuthSession signs in a device for the session's address or staff member and
+// returns the refresh token the device must keep
+func CreateAuthSession(session *AuthSession) (string, error) {
+	token, err := newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	lifetime := UserSessionLifetime
+	if session.StaffID != nil {
+		lifetime = StaffSessionLifetime
+	}
+	session.RefreshTokenHash = hashToken(token)
+	session.ExpiresAt = now.Add(lifetime)
+	session.LastUsedAt = now
+
+	if err := db.Create(session).Error; err != nil {
+		return "", fmt.Errorf("failed to create auth session: %w", err)
+	}
+	return token, nil
+}
+
+// RefreshAuthSession trades a refresh token for the next one and returns the session
+// it belongs to. A token that was already traded revokes the session.
+func RefreshAuthSession(token, userAgent, ipAddress string) (*AuthSession, string, error) {
+	if token == "" {
+		return nil, "", ErrAuthSessionInvalid
+	}
+	next, err := newRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	var session AuthSession
+	reused := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		hash := hashToken(token)
+		err := tx.Where("refresh_token_hash = ?", hash).First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result := tx.Model(&AuthSession{}).
+				Where("previous_token_hash = ? AND revoked_at IS NULL", hash).
+				Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": AuthSessionRevokedTokenReused})
+			if result.Error != nil {
+				return fmt.Errorf("failed to revoke auth session: %w", result.Error)
+			}
+			reused = result.RowsAffected > 0
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get auth session: %w", err)
+		}
+
+		now := time.Now()
+		if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+			return ErrAuthSessionInvalid
+		}
+
+		updates := map[string]interface{}{
+			"refresh_token_hash":  hashToken(next),
+			"previous_token_hash": hash,
+			"user_agent":          userAgent,
+			"ip_address":          ipAddress,
+			"last_used_at":        now,
+		}
+		if err := tx.Model(&session).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if reused {
+		return nil, "", ErrRefreshTokenReused
+	}
+	if session.ID == 0 {
+		return nil, "", ErrAuthSessionInvalid
+	}
+	return &session, next, nil
+}
+
+// EndAuthSession signs out the session a refresh token belongs to
+func EndAuthSession(token string) (*AuthSession, error) {
+	var session AuthSession
+	err := db.Where("refresh_token_hash = ? AND revoked_at IS NULL", hashToken(token)).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAuthSessionInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth session: %w", err)
+	}
+
+	now := time.Now()
+	if err := db.Model(&session).Updates(map[string]interface{}{"revoked_at": now, "revoke_reason": AuthSessionRevokedSignOut}).Error; err != nil {
+		return nil, fmt.Errorf("failed to end auth session: %w", err)
+	}
+	session.RevokedAt = &now
+	return &session, nil
+}
+
+// GetActiveAuthSession returns a session that is still signed in
+func GetActiveAuthSession(id uint) (*AuthSession, error) {
+	var session AuthSession
+	err := db.Where("id = ? AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAuthSessionInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth session: %w", err)
+	}
+	return &session, nil
+}
+
+// authSessionsOf narrows a query to the sessions of a staff member, or of a wallet
+// address when staffID is zero
+func authSessionsOf(query *gorm.DB, address string, staffID uint) *gorm.DB {
+	if staffID != 0 {
+		return query.Where("staff_id = ?", staffID)
+	}
+	return query.Where("address = ? AND staff_id IS NULL", address)
+}
+
+// GetAuthSessions returns the signed-in sessions of an address or staff member, most
+// recently used first
+func GetAuthSessions(address string, staffID uint) ([]AuthSession, error) {
+	query := authSessionsOf(db.Model(&AuthSession{}), address, staffID).
+		Where("revoked_at IS NULL AND expires_at > ?", time.Now())
+	var sessions []AuthSession
+	if err := query.Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get auth sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeAuthSession signs out one session of an address or staff member
+func RevokeAuthSession(id uint, address string, staffID uint, reason string) error {
+	result := authSessionsOf(db.Model(&AuthSession{}), address, staffID).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke auth session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("auth session not found")
+	}
+	return nil
+}
+
+// RevokeOtherAuthSessions signs out every session of an address or staff member but
+// the one given, returning how many were signed out
+func RevokeOtherAuthSessions(address string, staffID uint, keepID uint) (int64, error) {
+	result := authSessionsOf(db.Model(&AuthSession{}), address, staffID).
+		Where("id <> ? AND revoked_at IS NULL", keepID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": AuthSessionRevokedByUser})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to revoke auth sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
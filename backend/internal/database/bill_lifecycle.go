@@ -0,0 +1,157 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Actors recorded for transitions that are not performed by a user
+const (
+	BillActorSystem  = "system"
+	BillActorIndexer = "indexer"
+	BillActorGuest   = "guest"
+)
+
+// ErrBillBalanceOutstanding is returned when closing a bill that has not been paid in full
+var ErrBillBalanceOutstanding = errors.New("bill has an outstanding balance")
+
+// InvalidBillTransitionError is returned when a bill cannot move between two statuses
+type InvalidBillTransitionError struct {
+	From BillStatus
+	To   BillStatus
+}
+
+func (e *InvalidBillTransitionError) Error() string {
+	return fmt.Sprintf("bill cannot move from %s to %s", e.From, e.To)
+}
+
+// billTransitions lists the statuses each status may move to. Voided and refunded
+// bills are final.
+var billTransitions = map[BillStatus][]BillStatus{
+	BillStatusOpen:          {BillStatusPartiallyPaid, BillStatusPaid, BillStatusClosed, BillStatusVoided},
+	BillStatusPartiallyPaid: {BillStatusOpen, BillStatusPaid, BillStatusRefunded},
+	BillStatusPaid:          {BillStatusClosed, BillStatusRefunded},
+	BillStatusClosed:        {BillStatusRefunded},
+}
+
+// ActiveBillStatuses are the statuses of bills still being served at a table
+var ActiveBillStatuses = []BillStatus{BillStatusOpen, BillStatusPartiallyPaid}
+
+// CanTransitionTo reports whether a bill in status s may move to status to
+func (s BillStatus) CanTransitionTo(to BillStatus) bool {
+	for _, allowed := range billTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive reports whether the bill can still take items and payments
+func (s BillStatus) IsActive() bool {
+	return s == BillStatusOpen || s == BillStatusPartiallyPaid
+}
+
+// TransitionBill validates and applies a status change and records it in the bill's
+// audit trail. Moving a bill to its current status is a no-op.
+func TransitionBill(billID uint, to BillStatus, actor, reason string) (*Bill, error) {
+	var bill Bill
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&bill, billID).Error; err != nil {
+			return fmt.Errorf("failed to get bill: %w", err)
+		}
+		return transitionBill(tx, &bill, to, actor, reason)
+	})
+	if err != nil {
+		return nil, err
+	}
+	notifyTableStatus(bill.BusinessID, bill.TableID)
+	return &bill, nil
+}
+
+// transitionBill is the only place a bill's status is written after creation
+func transitionBill(tx *gorm.DB, bill *Bill, to BillStatus, actor, reason string) error {
+	from := bill.Status
+	if from == to {
+		return nil
+	}
+	// A paid bill is settled even if staff accepted less than the total
+	if to == BillStatusClosed && from.IsActive() && bill.PaidAmount < bill.TotalAmount {
+		return ErrBillBalanceOutstanding
+	}
+	if !from.CanTransitionTo(to) {
+		return &InvalidBillTransitionError{From: from, To: to}
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":     to,
+		"updated_at": now,
+	}
+	switch to {
+	case BillStatusPaid, BillStatusClosed, BillStatusVoided:
+		if bill.ClosedAt == nil {
+			updates["closed_at"] = &now
+			bill.ClosedAt = &now
+		}
+	}
+
+	// Guard on the previous status so concurrent transitions cannot both apply
+	result := tx.Model(&Bill{}).Where("id = ? AND status = ?", bill.ID, from).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update bill status: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("bill %d changed status concurrently", bill.ID)
+	}
+
+	if err := tx.Create(&BillEvent{
+		BillID:     bill.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     reason,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to record bill event: %w", err)
+	}
+
+	// Guests who joined the table for this bill cannot order on it any more
+	if !to.IsActive() {
+		if err := endTableSessions(tx, TableSessionEndedBillClosed, "bill_id = ?", bill.ID); err != nil {
+			return err
+		}
+	}
+
+	bill.Status = to
+	bill.UpdatedAt = now
+	return nil
+}
+
+// ledgerBillStatus derives the status an active bill should have from its paid amount
+func ledgerBillStatus(bill *Bill) BillStatus {
+	switch {
+	case bill.TotalAmount > 0 && bill.PaidAmount >= bill.TotalAmount:
+		return BillStatusPaid
+	case bill.PaidAmount > 0:
+		return BillStatusPartiallyPaid
+	default:
+		return BillStatusOpen
+	}
+}
+
+// GetBillEvents returns a bill's status history, oldest first
+func GetBillEvents(billID uint) ([]BillEvent, error) {
+	var events []BillEvent
+	if err := db.Where("bill_id = ?", billID).Order("id").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to get bill events: %w", err)
+	}
+	return events, nil
+}
+
+// TransitionBill validates and applies a bill status change
+func (d *DB) TransitionBill(billID uint, to BillStatus, actor, reason string) (*Bill, error) {
+	return TransitionBill(billID, to, actor, reason)
+}
@@ -0,0 +1,477 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"payverge/internal/money"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidBillTransfer is returned for a move, merge or split that cannot be applied
+	ErrInvalidBillTransfer = errors.New("invalid bill transfer")
+	// ErrTableHasOpenBill is returned when moving a bill to a table that is already billing
+	ErrTableHasOpenBill = errors.New("table already has an open bill")
+	// ErrCounterHasOpenBill is returned when moving a bill to a counter that is already billing
+	ErrCounterHasOpenBill = errors.New("counter already has an open bill")
+)
+
+// Bill transfer actions
+const (
+	BillTransferMove  = "move"
+	BillTransferMerge = "merge"
+	BillTransferSplit = "split"
+)
+
+// BillTransfer is the outcome of moving, merging or splitting bills
+type BillTransfer struct {
+	Action string `json:"action"`
+	// Bills are the bills changed: the moved bill, the merged bill then the one merged
+	// away, or the split bill then the new one
+	Bills []Bill `json:"bills"`
+	// Tables are the tables whose bills changed, so their guests can be told
+	Tables []Table `json:"-"`
+}
+
+func (t *BillTransfer) tableIDs() []uint {
+	ids := make([]uint, len(t.Tables))
+	for i, table := range t.Tables {
+		ids[i] = table.ID
+	}
+	return ids
+}
+
+// BillTransferTarget is where a bill moves to: either a table or a counter
+type BillTransferTarget struct {
+	TableID   *uint `json:"table_id"`
+	CounterID *uint `json:"counter_id"`
+}
+
+// BillItemSplit picks a quantity of a bill item to split off
+type BillItemSplit struct {
+	ID       string `json:"id"`
+	Quantity int    `json:"quantity"`
+}
+
+// MoveBill moves an open bill to another table or counter. Guests who joined the bill
+// at its old table have to scan the new table's code.
+func MoveBill(businessID, billID uint, target BillTransferTarget, actor string) (*BillTransfer, error) {
+	if (target.TableID == nil) == (target.CounterID == nil) {
+		return nil, fmt.Errorf("%w: move the bill to either a table or a counter", ErrInvalidBillTransfer)
+	}
+
+	transfer := &BillTransfer{Action: BillTransferMove}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		bill, items, err := activeTransferBill(tx, businessID, billID)
+		if err != nil {
+			return err
+		}
+		business, err := transferBusiness(tx, businessID)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"updated_at": time.Now()}
+		if target.TableID != nil {
+			table, err := transferTable(tx, businessID, *target.TableID)
+			if err != nil {
+				return err
+			}
+			if bill.CounterID == nil && bill.TableID == table.ID {
+				return fmt.Errorf("%w: the bill is already at this table", ErrInvalidBillTransfer)
+			}
+			var count int64
+			if err := tx.Model(&Bill{}).Where("table_id = ? AND status IN ? AND id <> ?", table.ID, ActiveBillStatuses, bill.ID).
+				Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check the table's bills: %w", err)
+			}
+			if count > 0 {
+				return ErrTableHasOpenBill
+			}
+			updates["table_id"] = table.ID
+			updates["counter_id"] = nil
+		} else {
+			counter, err := transferCounter(tx, businessID, *target.CounterID)
+			if err != nil {
+				return err
+			}
+			if bill.CounterID != nil && *bill.CounterID == counter.ID {
+				return fmt.Errorf("%w: the bill is already at this counter", ErrInvalidBillTransfer)
+			}
+			if counter.CurrentBillID != nil {
+				var current Bill
+				if err := tx.Select("id", "status").First(&current, *counter.CurrentBillID).Error; err == nil && current.Status.IsActive() {
+					return ErrCounterHasOpenBill
+				}
+			}
+			if err := tx.Model(&Counter{}).Where("id = ?", counter.ID).Update("current_bill_id", bill.ID).Error; err != nil {
+				return fmt.Errorf("failed to update counter bill: %w", err)
+			}
+			updates["table_id"] = 0
+			updates["counter_id"] = counter.ID
+		}
+
+		if err := releaseCounter(tx, bill); err != nil {
+			return err
+		}
+		if err := tx.Model(&Bill{}).Where("id = ?", bill.ID).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to move bill: %w", err)
+		}
+		if err := endTableSessions(tx, TableSessionEndedBillMoved, "bill_id = ?", bill.ID); err != nil {
+			return err
+		}
+
+		oldTableID := bill.TableID
+		if err := tx.First(bill, bill.ID).Error; err != nil {
+			return fmt.Errorf("failed to get bill: %w", err)
+		}
+		if err := saveTransferItems(tx, bill, items, business, actor, "bill moved"); err != nil {
+			return err
+		}
+
+		transfer.Bills = []Bill{*bill}
+		transfer.Tables, err = transferTables(tx, oldTableID, bill.TableID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyTableStatus(businessID, transfer.tableIDs()...)
+	return transfer, nil
+}
+
+// MergeBills moves every item and order of the source bill onto the target bill and
+// voids the source. A source bill that has taken payments cannot be merged away, as
+// its payments are tied to it; merge the other way round instead.
+func MergeBills(businessID, targetID, sourceID uint, actor string) (*BillTransfer, error) {
+	if targetID == sourceID {
+		return nil, fmt.Errorf("%w: a bill cannot be merged with itself", ErrInvalidBillTransfer)
+	}
+
+	transfer := &BillTransfer{Action: BillTransferMerge}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		target, targetItems, err := activeTransferBill(tx, businessID, targetID)
+		if err != nil {
+			return err
+		}
+		source, sourceItems, err := activeTransferBill(tx, businessID, sourceID)
+		if err != nil {
+			return err
+		}
+		business, err := transferBusiness(tx, businessID)
+		if err != nil {
+			return err
+		}
+
+		var payments, alternativePayments int64
+		if err := tx.Model(&Payment{}).Where("bill_id = ?", source.ID).Count(&payments).Error; err != nil {
+			return fmt.Errorf("failed to check bill payments: %w", err)
+		}
+		if err := tx.Model(&AlternativePayment{}).Where("bill_id = ?", source.ID).Count(&alternativePayments).Error; err != nil {
+			return fmt.Errorf("failed to check bill payments: %w", err)
+		}
+		if source.PaidAmount > 0 || payments > 0 || alternativePayments > 0 {
+			return fmt.Errorf("%w: bill %s has payments, merge the other bill into it instead", ErrInvalidBillTransfer, source.BillNumber)
+		}
+		if err := releaseSplitSession(tx, source.ID); err != nil {
+			return err
+		}
+
+		ids := make(map[string]bool, len(targetItems))
+		for _, item := range targetItems {
+			ids[item.ID] = true
+		}
+		for _, item := range sourceItems {
+			if ids[item.ID] {
+				item.ID = fmt.Sprintf("%s-%d", item.ID, source.ID)
+			}
+			ids[item.ID] = true
+			targetItems = append(targetItems, item)
+		}
+
+		// Orders and their kitchen tickets follow the items
+		if err := tx.Model(&Order{}).Where("bill_id = ?", source.ID).Update("bill_id", target.ID).Error; err != nil {
+			return fmt.Errorf("failed to move orders: %w", err)
+		}
+		if err := tx.Model(&KitchenTicket{}).Where("bill_id = ?", source.ID).Update("bill_id", target.ID).Error; err != nil {
+			return fmt.Errorf("failed to move kitchen tickets: %w", err)
+		}
+
+		if source.Notes != "" {
+			notes := source.Notes
+			if target.Notes != "" {
+				notes = target.Notes + "\n" + notes
+			}
+			if err := tx.Model(&Bill{}).Where("id = ?", target.ID).Update("notes", notes).Error; err != nil {
+				return fmt.Errorf("failed to merge bill notes: %w", err)
+			}
+			target.Notes = notes
+		}
+		if err := saveTransferItems(tx, target, targetItems, business, actor, "bill merged"); err != nil {
+			return err
+		}
+
+		// The source keeps no items, so nothing is counted twice
+		if err := saveTransferItems(tx, source, []BillItem{}, business, actor, "bill merged"); err != nil {
+			return err
+		}
+		if err := releaseCounter(tx, source); err != nil {
+			return err
+		}
+		if err := transitionBill(tx, source, BillStatusVoided, actor, fmt.Sprintf("merged into bill %s", target.BillNumber)); err != nil {
+			return err
+		}
+
+		transfer.Bills = []Bill{*target, *source}
+		transfer.Tables, err = transferTables(tx, target.TableID, source.TableID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyTableStatus(businessID, transfer.tableIDs()...)
+	return transfer, nil
+}
+
+// SplitBill moves some of a bill's items onto a new bill at the same table or counter.
+// Part of an item's quantity can be split off. Orders stay with the original bill.
+func SplitBill(businessID, billID uint, splits []BillItemSplit, actor string) (*BillTransfer, error) {
+	if len(splits) == 0 {
+		return nil, fmt.Errorf("%w: choose the items to split off", ErrInvalidBillTransfer)
+	}
+
+	transfer := &BillTransfer{Action: BillTransferSplit}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		bill, items, err := activeTransferBill(tx, businessID, billID)
+		if err != nil {
+			return err
+		}
+		business, err := transferBusiness(tx, businessID)
+		if err != nil {
+			return err
+		}
+
+		quantities := make(map[string]int, len(splits))
+		for _, split := range splits {
+			if split.Quantity < 1 {
+				return fmt.Errorf("%w: quantity of item %q must be at least 1", ErrInvalidBillTransfer, split.ID)
+			}
+			quantities[split.ID] += split.Quantity
+		}
+
+		var kept, splitOff []BillItem
+		for _, item := range items {
+			quantity, ok := quantities[item.ID]
+			if !ok {
+				kept = append(kept, item)
+				continue
+			}
+			delete(quantities, item.ID)
+			if quantity > item.Quantity {
+				return fmt.Errorf("%w: item %q only has a quantity of %d", ErrInvalidBillTransfer, item.ID, item.Quantity)
+			}
+
+			moved := item
+			moved.Quantity = quantity
+			moved.Subtotal = UnitPrice(item.Price, item.Options, item.Modifiers).Mul(quantity)
+			if quantity < item.Quantity {
+				moved.ID = fmt.Sprintf("%s-split-%d", item.ID, time.Now().UnixNano())
+				item.Quantity -= quantity
+				item.Subtotal = UnitPrice(item.Price, item.Options, item.Modifiers).Mul(item.Quantity)
+				kept = append(kept, item)
+			}
+			splitOff = append(splitOff, moved)
+		}
+		for id := range quantities {
+			return fmt.Errorf("%w: item %q is not on the bill", ErrInvalidBillTransfer, id)
+		}
+		if len(kept) == 0 {
+			return fmt.Errorf("%w: leave at least one item on the bill, or move the whole bill instead", ErrInvalidBillTransfer)
+		}
+
+		billNumber, err := generateUniqueBillNumber(tx, businessID)
+		if err != nil {
+			return fmt.Errorf("failed to generate bill number: %w", err)
+		}
+		split := &Bill{
+			BusinessID:     businessID,
+			TableID:        bill.TableID,
+			CounterID:      bill.CounterID,
+			BillNumber:     billNumber,
+			Notes:          fmt.Sprintf("Split from bill %s", bill.BillNumber),
+			Items:          "[]",
+			Status:         BillStatusOpen,
+			SettlementAddr: business.SettlementAddr,
+			TippingAddr:    business.TippingAddr,
+		}
+		if err := tx.Create(split).Error; err != nil {
+			return fmt.Errorf("failed to create bill: %w", err)
+		}
+
+		if err := saveTransferItems(tx, bill, kept, business, actor, "bill split"); err != nil {
+			return err
+		}
+		if err := saveTransferItems(tx, split, splitOff, business, actor, "bill split"); err != nil {
+			return err
+		}
+
+		transfer.Bills = []Bill{*bill, *split}
+		transfer.Tables, err = transferTables(tx, bill.TableID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyTableStatus(businessID, transfer.tableIDs()...)
+	return transfer, nil
+}
+
+// activeTransferBill loads an active bill of the business with its items
+func activeTransferBill(tx *gorm.DB, businessID, billID uint) (*Bill, []BillItem, error) {
+	var bill Bill
+	if err := tx.Where("id = ? AND business_id = ?", billID, businessID).First(&bill).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrBillNotFound
+		}
+		return nil, nil, fmt.Errorf("failed to get bill: %w", err)
+	}
+	if !bill.Status.IsActive() {
+		return nil, nil, fmt.Errorf("%w: bill %s is %s", ErrInvalidBillTransfer, bill.BillNumber, bill.Status)
+	}
+
+	items := []BillItem{}
+	if bill.Items != "" {
+		if err := json.Unmarshal([]byte(bill.Items), &items); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal items: %w", err)
+		}
+	}
+	return &bill, items, nil
+}
+
+func transferBusiness(tx *gorm.DB, businessID uint) (*Business, error) {
+	var business Business
+	if err := tx.First(&business, businessID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get business for tax/service fee rates: %w", err)
+	}
+	return &business, nil
+}
+
+func transferTable(tx *gorm.DB, businessID, tableID uint) (*Table, error) {
+	var table Table
+	if err := tx.Where("id = ? AND business_id = ? AND is_active = ?", tableID, businessID, true).First(&table).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTableNotFound
+		}
+		return nil, fmt.Errorf("failed to get table: %w", err)
+	}
+	return &table, nil
+}
+
+func transferCounter(tx *gorm.DB, businessID, counterID uint) (*Counter, error) {
+	var counter Counter
+	if err := tx.Where("id = ? AND business_id = ? AND is_active = ?", counterID, businessID, true).First(&counter).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("counter not found")
+		}
+		return nil, fmt.Errorf("failed to get counter: %w", err)
+	}
+	return &counter, nil
+}
+
+// transferTables loads the tables with the given IDs, skipping counter bills' zero IDs
+func transferTables(tx *gorm.DB, tableIDs ...uint) ([]Table, error) {
+	var ids []uint
+	for _, id := range tableIDs {
+		if id != 0 {
+			ids = append(ids, id)
+		}
+	}
+	tables := []Table{}
+	if len(ids) == 0 {
+		return tables, nil
+	}
+	if err := tx.Where("id IN ?", ids).Find(&tables).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tables: %w", err)
+	}
+	return tables, nil
+}
+
+// releaseCounter frees the counter a bill is leaving
+func releaseCounter(tx *gorm.DB, bill *Bill) error {
+	if bill.CounterID == nil {
+		return nil
+	}
+	if err := tx.Model(&Counter{}).Where("id = ? AND current_bill_id = ?", *bill.CounterID, bill.ID).
+		Update("current_bill_id", nil).Error; err != nil {
+		return fmt.Errorf("failed to update counter bill: %w", err)
+	}
+	return nil
+}
+
+// releaseSplitSession cancels a bill's split once its total changes. A split that
+// guests started paying cannot be undone.
+func releaseSplitSession(tx *gorm.DB, billID uint) error {
+	session, err := currentSplitSession(tx, billID)
+	if err != nil || session == nil {
+		return err
+	}
+	if session.Status != SplitSessionActive {
+		return ErrSplitSessionLocked
+	}
+	if err := tx.Model(&SplitSession{}).Where("id = ?", session.ID).Update("status", SplitSessionCancelled).Error; err != nil {
+		return fmt.Errorf("failed to cancel split session: %w", err)
+	}
+	return nil
+}
+
+// saveTransferItems stores a bill's new items with its tax and service fee recomputed
+// from the business rates. The total cannot fall below what was already paid.
+func saveTransferItems(tx *gorm.DB, bill *Bill, items []BillItem, business *Business, actor, reason string) error {
+	var subtotal money.Amount
+	for _, item := range items {
+		subtotal += item.Subtotal
+	}
+	taxAmount := subtotal.Percent(business.TaxRate)
+	serviceFeeAmount := subtotal.Percent(business.ServiceFeeRate)
+	totalAmount := subtotal + taxAmount + serviceFeeAmount
+	if totalAmount < bill.PaidAmount {
+		return fmt.Errorf("%w: bill %s would total less than the %s already paid", ErrInvalidBillTransfer, bill.BillNumber, bill.PaidAmount)
+	}
+
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("failed to marshal items: %w", err)
+	}
+	totalChanged := totalAmount != bill.TotalAmount
+	if err := tx.Model(&Bill{}).Where("id = ?", bill.ID).Updates(map[string]interface{}{
+		"items":              string(itemsJSON),
+		"subtotal":           subtotal,
+		"tax_amount":         taxAmount,
+		"service_fee_amount": serviceFeeAmount,
+		"total_amount":       totalAmount,
+		"updated_at":         time.Now(),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update bill totals: %w", err)
+	}
+	bill.Items = JSON(itemsJSON)
+	bill.Subtotal = subtotal
+	bill.TaxAmount = taxAmount
+	bill.ServiceFeeAmount = serviceFeeAmount
+	bill.TotalAmount = totalAmount
+
+	if !totalChanged {
+		return nil
+	}
+	if err := releaseSplitSession(tx, bill.ID); err != nil {
+		return err
+	}
+	// A smaller total may now be covered by what was paid
+	return transitionBill(tx, bill, ledgerBillStatus(bill), actor, reason)
+}
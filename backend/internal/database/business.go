@@ -10,9 +10,20 @@ import (
 	"strings"
 	"time"
 
+	"payverge/internal/money"
+
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrBusinessNotFound is returned when a business does not exist
+	ErrBusinessNotFound = errors.New("business not found")
+	// ErrTableNotFound is returned when a table does not exist
+	ErrTableNotFound = errors.New("table not found")
+	// ErrBillNotFound is returned when a bill does not exist
+	ErrBillNotFound = errors.New("bill not found")
+)
+
 // Business operations
 
 // CreateBusiness creates a new business in the database
@@ -36,7 +47,7 @@ func GetBusinessByID(id uint) (*Business, error) {
 	var business Business
 	if err := db.First(&business, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("business not found")
+			return nil, ErrBusinessNotFound
 		}
 		return nil, fmt.Errorf("failed to get business: %w", err)
 	}
@@ -134,194 +145,13 @@ func GetBusinessDefaults(businessID uint) (string, string, error) {
 	var business Business
 	if err := db.Select("default_currency, default_language").Where("id = ?", businessID).First(&business).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return "", "", fmt.Errorf("business not found")
+			return "", "", ErrBusinessNotFound
 		}
 		return "", "", fmt.Errorf("failed to get business defaults: %w", err)
 	}
 	return business.DefaultCurrency, business.DefaultLanguage, nil
 }
 
-// Menu operations
-
-// CreateMenu creates a new menu for a business
-func CreateMenu(menu *Menu, categories []MenuCategory) error {
-	// Convert categories to JSON string for SQLite storage
-	categoriesJSON, err := json.Marshal(categories)
-	if err != nil {
-		return fmt.Errorf("failed to marshal categories: %w", err)
-	}
-	menu.Categories = string(categoriesJSON)
-
-	if err := db.Create(menu).Error; err != nil {
-		return fmt.Errorf("failed to create menu: %w", err)
-	}
-	return nil
-}
-
-// GetMenuByBusinessID retrieves the active menu for a business
-func GetMenuByBusinessID(businessID uint) (*Menu, []MenuCategory, error) {
-	var menu Menu
-	if err := db.Where("business_id = ? AND is_active = ?", businessID, true).First(&menu).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil, fmt.Errorf("menu not found")
-		}
-		return nil, nil, fmt.Errorf("failed to get menu: %w", err)
-	}
-
-	// Parse categories from JSON
-	var categories []MenuCategory
-	if menu.Categories != "" {
-		if err := json.Unmarshal([]byte(menu.Categories), &categories); err != nil {
-			return nil, nil, fmt.Errorf("failed to unmarshal categories: %w", err)
-		}
-	}
-
-	return &menu, categories, nil
-}
-
-// UpdateMenu updates an existing menu
-func UpdateMenu(menu *Menu, categories []MenuCategory) error {
-	// Convert categories to JSON string for SQLite storage
-	categoriesJSON, err := json.Marshal(categories)
-	if err != nil {
-		return fmt.Errorf("failed to marshal categories: %w", err)
-	}
-	menu.Categories = string(categoriesJSON)
-
-	if err := db.Save(menu).Error; err != nil {
-		return fmt.Errorf("failed to update menu: %w", err)
-	}
-	return nil
-}
-
-// AddMenuCategory adds a new category to an existing menu, creating the menu if it doesn't exist
-func AddMenuCategory(businessID uint, category MenuCategory) error {
-	menu, categories, err := GetMenuByBusinessID(businessID)
-	if err != nil {
-		// If menu doesn't exist, create a new one
-		if strings.Contains(err.Error(), "menu not found") {
-			newMenu := &Menu{
-				BusinessID: businessID,
-				Categories: "",
-				IsActive:   true,
-			}
-			emptyCategories := []MenuCategory{}
-			if err := CreateMenu(newMenu, emptyCategories); err != nil {
-				return fmt.Errorf("failed to create new menu: %w", err)
-			}
-			menu = newMenu
-			categories = emptyCategories
-		} else {
-			return fmt.Errorf("failed to get menu: %w", err)
-		}
-	}
-
-	// Add new category
-	categories = append(categories, category)
-
-	// Update menu with new categories
-	return UpdateMenu(menu, categories)
-}
-
-// UpdateMenuCategory updates a specific category in a menu
-func UpdateMenuCategory(businessID uint, categoryIndex int, updatedCategory MenuCategory) error {
-	menu, categories, err := GetMenuByBusinessID(businessID)
-	if err != nil {
-		return fmt.Errorf("failed to get menu: %w", err)
-	}
-
-	if categoryIndex < 0 || categoryIndex >= len(categories) {
-		return fmt.Errorf("category index out of range")
-	}
-
-	// Update the category
-	categories[categoryIndex] = updatedCategory
-
-	// Update menu with modified categories
-	return UpdateMenu(menu, categories)
-}
-
-// DeleteMenuCategory removes a category from a menu
-func DeleteMenuCategory(businessID uint, categoryIndex int) error {
-	menu, categories, err := GetMenuByBusinessID(businessID)
-	if err != nil {
-		return fmt.Errorf("failed to get menu: %w", err)
-	}
-
-	if categoryIndex < 0 || categoryIndex >= len(categories) {
-		return fmt.Errorf("category index out of range")
-	}
-
-	// Remove the category
-	categories = append(categories[:categoryIndex], categories[categoryIndex+1:]...)
-
-	// Update menu with modified categories
-	return UpdateMenu(menu, categories)
-}
-
-// AddMenuItem adds a new item to a specific category
-func AddMenuItem(businessID uint, categoryIndex int, item MenuItem) error {
-	menu, categories, err := GetMenuByBusinessID(businessID)
-	if err != nil {
-		return fmt.Errorf("failed to get menu: %w", err)
-	}
-
-	if categoryIndex < 0 || categoryIndex >= len(categories) {
-		return fmt.Errorf("category index out of range")
-	}
-
-	// Add item to category
-	categories[categoryIndex].Items = append(categories[categoryIndex].Items, item)
-
-	// Update menu with modified categories
-	return UpdateMenu(menu, categories)
-}
-
-// UpdateMenuItem updates a specific menu item
-func UpdateMenuItem(businessID uint, categoryIndex, itemIndex int, updatedItem MenuItem) error {
-	menu, categories, err := GetMenuByBusinessID(businessID)
-	if err != nil {
-		return fmt.Errorf("failed to get menu: %w", err)
-	}
-
-	if categoryIndex < 0 || categoryIndex >= len(categories) {
-		return fmt.Errorf("category index out of range")
-	}
-
-	if itemIndex < 0 || itemIndex >= len(categories[categoryIndex].Items) {
-		return fmt.Errorf("item index out of range")
-	}
-
-	// Update the item
-	categories[categoryIndex].Items[itemIndex] = updatedItem
-
-	// Update menu with modified categories
-	return UpdateMenu(menu, categories)
-}
-
-// DeleteMenuItem removes an item from a category
-func DeleteMenuItem(businessID uint, categoryIndex, itemIndex int) error {
-	menu, categories, err := GetMenuByBusinessID(businessID)
-	if err != nil {
-		return fmt.Errorf("failed to get menu: %w", err)
-	}
-
-	if categoryIndex < 0 || categoryIndex >= len(categories) {
-		return fmt.Errorf("category index out of range")
-	}
-
-	if itemIndex < 0 || itemIndex >= len(categories[categoryIndex].Items) {
-		return fmt.Errorf("item index out of range")
-	}
-
-	// Remove the item
-	items := categories[categoryIndex].Items
-	categories[categoryIndex].Items = append(items[:itemIndex], items[itemIndex+1:]...)
-
-	// Update menu with modified categories
-	return UpdateMenu(menu, categories)
-}
-
 // Table operations
 
 // CreateTable creates a new table for a business
@@ -346,7 +176,7 @@ func GetTableByCode(tableCode string) (*Table, error) {
 	var table Table
 	if err := db.Where("table_code = ? AND is_active = ?", tableCode, true).First(&table).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("table not found")
+			return nil, ErrTableNotFound
 		}
 		return nil, fmt.Errorf("failed to get table: %w", err)
 	}
@@ -358,7 +188,7 @@ func GetTableByID(id uint) (*Table, error) {
 	var table Table
 	if err := db.First(&table, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("table not found")
+			return nil, ErrTableNotFound
 		}
 		return nil, fmt.Errorf("failed to get table: %w", err)
 	}
@@ -383,39 +213,85 @@ func DeleteTable(id uint) error {
 
 // GenerateUniqueTableCode generates a unique 10-character random table code for a business
 func GenerateUniqueTableCode(businessID uint, baseName string) (string, error) {
+	code, err := generateUniqueCode(db, &Table{}, "table_code")
+	if err != nil {
+		return "", fmt.Errorf("failed to generate unique table code: %w", err)
+	}
+	return code, nil
+}
+
+// generateUniqueCode generates a 10-character random code not yet used in the column
+func generateUniqueCode(tx *gorm.DB, model interface{}, column string) (string, error) {
 	const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	const codeLength = 10
 
 	// Try up to 10 times to generate a unique code
 	for attempts := 0; attempts < 10; attempts++ {
-		// Generate random 10-character string
-		tableCode := make([]byte, codeLength)
-		for i := range tableCode {
-			tableCode[i] = charset[rand.Intn(len(charset))]
+		code := make([]byte, codeLength)
+		for i := range code {
+			code[i] = charset[rand.Intn(len(charset))]
+		}
+
+		var count int64
+		if err := tx.Model(model).Where(column+" = ?", string(code)).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return string(code), nil
 		}
+	}
+
+	return "", fmt.Errorf("no unique code after 10 attempts")
+}
 
-		codeStr := string(tableCode)
+// SetTableQRCode stores the URL a table's QR code encodes
+func SetTableQRCode(tableID uint, url string) error {
+	if err := db.Model(&Table{}).Where("id = ?", tableID).Update("qr_code", url).Error; err != nil {
+		return fmt.Errorf("failed to update table QR code: %w", err)
+	}
+	return nil
+}
 
-		// Check if code already exists
-		var existing Table
-		if err := db.Where("table_code = ?", codeStr).First(&existing).Error; err != nil {
-			// Code doesn't exist (GORM returns error when not found), so it's unique
+// RotateTableCode replaces the code of a business's table, so printed codes and links
+// to the old one stop working. guestURL gives the URL the new QR code encodes. With
+// endSessions, guests who already joined the table have to scan the new code too.
+func RotateTableCode(businessID, tableID uint, guestURL func(code string) string, endSessions bool) (*Table, error) {
+	var table Table
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND business_id = ?", tableID, businessID).First(&table).Error; err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return codeStr, nil
+				return ErrTableNotFound
 			}
-			// Some other database error occurred
-			return "", err
+			return fmt.Errorf("failed to get table: %w", err)
 		}
-		// Code exists, try again
-	}
 
-	return "", fmt.Errorf("failed to generate unique table code after 10 attempts")
+		code, err := generateUniqueCode(tx, &Table{}, "table_code")
+		if err != nil {
+			return fmt.Errorf("failed to generate unique table code: %w", err)
+		}
+		table.TableCode = code
+		table.QRCode = guestURL(code)
+		if err := tx.Model(&table).Updates(map[string]interface{}{
+			"table_code": table.TableCode,
+			"qr_code":    table.QRCode,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to rotate table code: %w", err)
+		}
+		if endSessions {
+			return endTableSessions(tx, TableSessionEndedRotated, "table_id = ?", table.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &table, nil
 }
 
 // Bill operations
 
 // generateUniqueBillNumber generates a unique bill number for a business
-func generateUniqueBillNumber(businessID uint) (string, error) {
+func generateUniqueBillNumber(conn *gorm.DB, businessID uint) (string, error) {
 	for i := 0; i < 10; i++ {
 		// Generate bill number with format: B{businessID}-{timestamp}-{random}
 		timestamp := time.Now().Format("20060102150405")
@@ -429,7 +305,7 @@ func generateUniqueBillNumber(businessID uint) (string, error) {
 		
 		// Check if bill number already exists
 		var count int64
-		if err := db.Model(&Bill{}).Where("bill_number = ?", billNumber).Count(&count).Error; err != nil {
+		if err := conn.Model(&Bill{}).Where("bill_number = ?", billNumber).Count(&count).Error; err != nil {
 			return "", err
 		}
 		
@@ -448,23 +324,24 @@ func generateUniqueBillNumber(businessID uint) (string, error) {
 func CreateBill(bill *Bill, items []BillItem) error {
 	// Generate unique bill number if not provided
 	if bill.BillNumber == "" {
-		billNumber, err := generateUniqueBillNumber(bill.BusinessID)
+		billNumber, err := generateUniqueBillNumber(db, bill.BusinessID)
 		if err != nil {
 			return fmt.Errorf("failed to generate bill number: %w", err)
 		}
 		bill.BillNumber = billNumber
 	}
 
-	// Convert items to JSON string for SQLite storage
+	// Convert items to JSON for storage
 	itemsJSON, err := json.Marshal(items)
 	if err != nil {
 		return fmt.Errorf("failed to marshal items: %w", err)
 	}
-	bill.Items = string(itemsJSON)
+	bill.Items = JSON(itemsJSON)
 
 	if err := db.Create(bill).Error; err != nil {
 		return fmt.Errorf("failed to create bill: %w", err)
 	}
+	notifyTableStatus(bill.BusinessID, bill.TableID)
 	return nil
 }
 
@@ -473,7 +350,7 @@ func GetBillByID(id uint) (*Bill, []BillItem, error) {
 	var bill Bill
 	if err := db.Preload("Business").Preload("Table").Preload("Payments").First(&bill, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil, fmt.Errorf("bill not found")
+			return nil, nil, fmt.Errorf("bill not found: %w", err)
 		}
 		return nil, nil, fmt.Errorf("failed to get bill: %w", err)
 	}
@@ -494,7 +371,7 @@ func GetBillByNumber(billNumber string) (*Bill, []BillItem, error) {
 	var bill Bill
 	if err := db.Preload("Business").Preload("Table").Preload("Payments").Where("bill_number = ?", billNumber).First(&bill).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil, fmt.Errorf("bill not found")
+			return nil, nil, ErrBillNotFound
 		}
 		return nil, nil, fmt.Errorf("failed to get bill: %w", err)
 	}
@@ -510,10 +387,10 @@ func GetBillByNumber(billNumber string) (*Bill, []BillItem, error) {
 	return &bill, items, nil
 }
 
-// GetOpenBillsByBusinessID retrieves all open bills for a business
+// GetOpenBillsByBusinessID retrieves all active (open or partially paid) bills for a business
 func GetOpenBillsByBusinessID(businessID uint) ([]Bill, error) {
 	var bills []Bill
-	if err := db.Where("business_id = ? AND status = ?", businessID, BillStatusOpen).Find(&bills).Error; err != nil {
+	if err := db.Where("business_id = ? AND status IN ?", businessID, ActiveBillStatuses).Find(&bills).Error; err != nil {
 		return nil, fmt.Errorf("failed to get open bills: %w", err)
 	}
 	return bills, nil
@@ -528,10 +405,10 @@ func GetAllBillsByBusinessID(businessID uint) ([]Bill, error) {
 	return bills, nil
 }
 
-// GetOpenBillByTableID retrieves the open bill for a specific table
+// GetOpenBillByTableID retrieves the active (open or partially paid) bill for a specific table
 func GetOpenBillByTableID(tableID uint) (*Bill, []BillItem, error) {
 	var bill Bill
-	if err := db.Where("table_id = ? AND status = ?", tableID, BillStatusOpen).First(&bill).Error; err != nil {
+	if err := db.Where("table_id = ? AND status IN ?", tableID, ActiveBillStatuses).First(&bill).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil, fmt.Errorf("no open bill found for table")
 		}
@@ -551,26 +428,25 @@ func GetOpenBillByTableID(tableID uint) (*Bill, []BillItem, error) {
 
 // UpdateBill updates an existing bill
 func UpdateBill(bill *Bill, items []BillItem) error {
-	// Convert items to JSON string for SQLite storage
+	// Convert items to JSON for storage
 	itemsJSON, err := json.Marshal(items)
 	if err != nil {
 		return fmt.Errorf("failed to marshal items: %w", err)
 	}
-	bill.Items = string(itemsJSON)
+	bill.Items = JSON(itemsJSON)
 
-	if err := db.Save(bill).Error; err != nil {
+	// Status and paid amounts are owned by TransitionBill and the payment ledger
+	if err := db.Omit("status", "paid_amount", "tip_amount").Save(bill).Error; err != nil {
 		return fmt.Errorf("failed to update bill: %w", err)
 	}
+	notifyTableStatus(bill.BusinessID, bill.TableID)
 	return nil
 }
 
-// CloseBill closes a bill and sets the closed timestamp
-func CloseBill(billID uint) error {
-	now := time.Now()
-	if err := db.Model(&Bill{}).Where("id = ?", billID).Updates(map[string]interface{}{
-		"status":    BillStatusClosed,
-		"closed_at": &now,
-	}).Error; err != nil {
+// CloseBill closes a bill and sets the closed timestamp. Bills with an outstanding
+// balance cannot be closed.
+func CloseBill(billID uint, actor string) error {
+	if _, err := TransitionBill(billID, BillStatusClosed, actor, "closed by staff"); err != nil {
 		return fmt.Errorf("failed to close bill: %w", err)
 	}
 	return nil
@@ -598,7 +474,7 @@ func GetPaymentsByBillID(billID uint) ([]Payment, error) {
 // GetPaymentByTxHash retrieves a payment by its transaction hash
 func GetPaymentByTxHash(txHash string) (*Payment, error) {
 	var payment Payment
-	if err := db.Where("tx_hash = ?", txHash).First(&payment).Error; err != nil {
+	if err := db.Where("tx_hash = ?", normalizeTxHash(txHash)).First(&payment).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, fmt.Errorf("payment not found")
 		}
@@ -616,7 +492,7 @@ func UpdatePaymentStatus(paymentID uint, status PaymentStatus) error {
 }
 
 // UpdateBillPaidAmount updates the paid amount on a bill (called when payments are confirmed)
-func UpdateBillPaidAmount(billID uint, paidAmount, tipAmount float64) error {
+func UpdateBillPaidAmount(billID uint, paidAmount, tipAmount money.Amount) error {
 	if err := db.Model(&Bill{}).Where("id = ?", billID).Updates(map[string]interface{}{
 		"paid_amount": paidAmount,
 		"tip_amount":  tipAmount,
@@ -629,16 +505,19 @@ func UpdateBillPaidAmount(billID uint, paidAmount, tipAmount float64) error {
 // CheckBillFullyPaid checks if a bill is fully paid and updates status if needed
 func CheckBillFullyPaid(billID uint) error {
 	var bill Bill
-	if err := db.First(&bill, billID).Error; err != nil {
-		return fmt.Errorf("failed to get bill: %w", err)
-	}
-
-	if bill.PaidAmount >= bill.TotalAmount {
-		if err := db.Model(&Bill{}).Where("id = ?", billID).Update("status", BillStatusPaid).Error; err != nil {
-			return fmt.Errorf("failed to update bill status to paid: %w", err)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&bill, billID).Error; err != nil {
+			return fmt.Errorf("failed to get bill: %w", err)
+		}
+		if !bill.Status.IsActive() {
+			return nil
 		}
+		return transitionBill(tx, &bill, ledgerBillStatus(&bill), BillActorSystem, "paid amount checked")
+	})
+	if err != nil {
+		return err
 	}
-
+	notifyTableStatus(bill.BusinessID, bill.TableID)
 	return nil
 }
 
@@ -646,16 +525,17 @@ func CheckBillFullyPaid(billID uint) error {
 
 // CreateOrder creates a new order within a bill
 func CreateOrder(order *Order, items []OrderItem) error {
-	// Convert items to JSON string for SQLite storage
+	// Convert items to JSON for storage
 	itemsJSON, err := json.Marshal(items)
 	if err != nil {
 		return fmt.Errorf("failed to marshal items: %w", err)
 	}
-	order.Items = string(itemsJSON)
+	order.Items = JSON(itemsJSON)
 
 	if err := db.Create(order).Error; err != nil {
 		return fmt.Errorf("failed to create order: %w", err)
 	}
+	notifyBillTableStatus(order.BillID)
 	return nil
 }
 
@@ -703,110 +583,222 @@ func GetOrdersByBusinessID(businessID uint, status string) ([]Order, error) {
 	return orders, nil
 }
 
-// UpdateOrderStatus updates the status of an order
-func UpdateOrderStatus(orderID uint, status OrderStatus, approvedBy string) error {
-	// Start a transaction
-	tx := db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+// orderTransitions lists the statuses each order status may move to. Delivered and
+// cancelled orders are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:    {OrderStatusApproved, OrderStatusOrderCancelled},
+	OrderStatusApproved:   {OrderStatusInKitchen, OrderStatusOrderReady, OrderStatusOrderCancelled},
+	OrderStatusInKitchen:  {OrderStatusOrderReady, OrderStatusOrderCancelled},
+	OrderStatusOrderReady: {OrderStatusOrderDelivered, OrderStatusOrderCancelled},
+}
 
-	updates := map[string]interface{}{
-		"status":     status,
-		"updated_at": time.Now(),
-	}
-	
-	if status == OrderStatusApproved && approvedBy != "" {
-		updates["approved_by"] = approvedBy
-		updates["approved_at"] = time.Now()
-	}
-	
-	// Update order status
-	if err := tx.Model(&Order{}).Where("id = ?", orderID).Updates(updates).Error; err != nil {
-		tx.Rollback()
-		return fmt.Errorf("failed to update order status: %w", err)
+// InvalidOrderTransitionError is returned when an order cannot move between two statuses
+type InvalidOrderTransitionError struct {
+	From OrderStatus
+	To   OrderStatus
+}
+
+func (e *InvalidOrderTransitionError) Error() string {
+	return fmt.Sprintf("order cannot move from %s to %s", e.From, e.To)
+}
+
+// CanTransitionTo reports whether an order in status s may move to status to
+func (s OrderStatus) CanTransitionTo(to OrderStatus) bool {
+	for _, allowed := range orderTransitions[s] {
+		if allowed == to {
+			return true
+		}
 	}
+	return false
+}
 
-	// If order is approved, add items to the bill
-	if status == OrderStatusApproved {
-		// Get the order with its items
-		order, orderItems, err := GetOrderByID(orderID)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to get order for bill integration: %w", err)
+// UpdateOrderStatus moves an order to a new status. Approving adds the order's items to
+// its bill, sends it to the kitchen and takes its stock, and records the actor as the
+// approver. Cancelling an approved order takes its items off the bill, withdraws its
+// tickets and returns its stock. Moving an order to its current status is a no-op.
+func UpdateOrderStatus(orderID uint, status OrderStatus, actor string) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var order Order
+		if err := tx.First(&order, orderID).Error; err != nil {
+			return fmt.Errorf("failed to get order: %w", err)
+		}
+		from := order.Status
+		if from == status {
+			return nil
+		}
+		if !from.CanTransitionTo(status) {
+			return &InvalidOrderTransitionError{From: from, To: status}
 		}
 
-		// Get the current bill
-		bill, billItems, err := GetBillByID(order.BillID)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to get bill for integration: %w", err)
-		}
-
-		// Convert order items to bill items
-		for _, orderItem := range orderItems {
-			billItem := BillItem{
-				ID:         orderItem.ID,
-				MenuItemID: orderItem.MenuItemName, // Using name as ID for now
-				Name:       orderItem.MenuItemName,
-				Price:      orderItem.Price,
-				Quantity:   orderItem.Quantity,
-				Options:    []MenuItemOption{}, // Empty options for now
-				Subtotal:   orderItem.Subtotal,
-			}
-			billItems = append(billItems, billItem)
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":     status,
+			"updated_at": now,
+		}
+		if status == OrderStatusApproved && actor != "" {
+			updates["approved_by"] = actor
+			updates["approved_at"] = now
+			order.ApprovedBy = actor
+			order.ApprovedAt = &now
 		}
 
-		// Calculate new bill totals
-		newSubtotal := bill.Subtotal
-		for _, orderItem := range orderItems {
-			newSubtotal += orderItem.Subtotal
+		// Guard on the previous status so concurrent updates cannot both apply
+		result := tx.Model(&Order{}).Where("id = ? AND status = ?", orderID, from).Updates(updates)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update order status: %w", result.Error)
 		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("order %d changed status concurrently", orderID)
+		}
+		order.Status = status
+		order.UpdatedAt = now
 
-		// Update bill with new items and totals
-		billItemsJSON, err := json.Marshal(billItems)
-		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to marshal bill items: %w", err)
+		var orderItems []OrderItem
+		if order.Items != "" {
+			if err := json.Unmarshal([]byte(order.Items), &orderItems); err != nil {
+				return fmt.Errorf("failed to unmarshal order items: %w", err)
+			}
 		}
 
-		// Get business to use configured tax and service fee rates
-		var business Business
-		if err := tx.First(&business, order.BusinessID).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to get business for tax/service fee rates: %w", err)
+		switch status {
+		case OrderStatusApproved:
+			if err := addOrderToBill(tx, &order, orderItems); err != nil {
+				return err
+			}
+			// Send the order to the kitchen stations
+			if _, err := fanOutOrder(tx, &order, orderItems); err != nil {
+				return err
+			}
+			// Take the stock the order uses
+			return consumeOrderStock(tx, &order, orderItems)
+
+		case OrderStatusOrderCancelled:
+			// Only approved orders reached the bill, the kitchen and the stock
+			if from == OrderStatusPending {
+				return nil
+			}
+			if err := removeOrderFromBill(tx, &order, orderItems, actor); err != nil {
+				return err
+			}
+			if err := cancelKitchenTickets(tx, orderID); err != nil {
+				return err
+			}
+			return restoreOrderStock(tx, orderID)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		// Use business configured rates
-		taxRate := business.TaxRate
-		serviceFeeRate := business.ServiceFeeRate
-		
-		newTaxAmount := newSubtotal * taxRate
-		newServiceFeeAmount := newSubtotal * serviceFeeRate
-		newTotalAmount := newSubtotal + newTaxAmount + newServiceFeeAmount
+	notifyOrderTableStatus(orderID)
+	return nil
+}
 
-		billUpdates := map[string]interface{}{
-			"items":               string(billItemsJSON),
-			"subtotal":           newSubtotal,
-			"tax_amount":         newTaxAmount,
-			"service_fee_amount": newServiceFeeAmount,
-			"total_amount":       newTotalAmount,
-			"updated_at":         time.Now(),
+// orderBill returns the bill of an order with its items
+func orderBill(tx *gorm.DB, order *Order) (*Bill, []BillItem, error) {
+	var bill Bill
+	if err := tx.First(&bill, order.BillID).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to get bill for order: %w", err)
+	}
+	var billItems []BillItem
+	if bill.Items != "" {
+		if err := json.Unmarshal([]byte(bill.Items), &billItems); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal bill items: %w", err)
 		}
+	}
+	return &bill, billItems, nil
+}
 
-		if err := tx.Model(&Bill{}).Where("id = ?", order.BillID).Updates(billUpdates).Error; err != nil {
-			tx.Rollback()
-			return fmt.Errorf("failed to update bill with approved order items: %w", err)
+// addOrderToBill appends the items of an approved order to its bill
+func addOrderToBill(tx *gorm.DB, order *Order, orderItems []OrderItem) error {
+	bill, billItems, err := orderBill(tx, order)
+	if err != nil {
+		return err
+	}
+	if !bill.Status.IsActive() {
+		return fmt.Errorf("cannot add items to a %s bill", bill.Status)
+	}
+
+	subtotal := bill.Subtotal
+	for _, orderItem := range orderItems {
+		billItems = append(billItems, BillItem{
+			ID:         orderItem.ID,
+			MenuItemID: orderItem.MenuItemID,
+			Name:       orderItem.MenuItemName,
+			Price:      orderItem.Price,
+			Quantity:   orderItem.Quantity,
+			Options:    orderItem.Options,
+			Modifiers:  orderItem.Modifiers,
+			Subtotal:   orderItem.Subtotal,
+		})
+		subtotal += orderItem.Subtotal
+	}
+	return setBillItems(tx, bill, billItems, subtotal)
+}
+
+// removeOrderFromBill takes the items of a cancelled order off its bill and settles
+// the bill against what has been paid for the rest
+func removeOrderFromBill(tx *gorm.DB, order *Order, orderItems []OrderItem, actor string) error {
+	bill, billItems, err := orderBill(tx, order)
+	if err != nil {
+		return err
+	}
+	if !bill.Status.IsActive() {
+		return fmt.Errorf("cannot remove items from a %s bill", bill.Status)
+	}
+
+	ordered := make(map[string]bool, len(orderItems))
+	for _, orderItem := range orderItems {
+		ordered[orderItem.ID] = true
+	}
+	kept := make([]BillItem, 0, len(billItems))
+	subtotal := bill.Subtotal
+	for _, item := range billItems {
+		if ordered[item.ID] {
+			subtotal -= item.Subtotal
+			continue
 		}
+		kept = append(kept, item)
 	}
 
-	// Commit transaction
-	if err := tx.Commit().Error; err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
+	if err := setBillItems(tx, bill, kept, subtotal); err != nil {
+		return err
 	}
+	return recalculateBillPayments(tx, bill, actor)
+}
 
+// setBillItems stores a bill's items and subtotal with the tax and service fee the
+// business charges on it
+func setBillItems(tx *gorm.DB, bill *Bill, items []BillItem, subtotal money.Amount) error {
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return fmt.Errorf("failed to marshal bill items: %w", err)
+	}
+
+	// Get business to use configured tax and service fee rates
+	var business Business
+	if err := tx.First(&business, bill.BusinessID).Error; err != nil {
+		return fmt.Errorf("failed to get business for tax/service fee rates: %w", err)
+	}
+
+	bill.Items = JSON(itemsJSON)
+	bill.Subtotal = subtotal
+	bill.TaxAmount = subtotal.Percent(business.TaxRate)
+	bill.ServiceFeeAmount = subtotal.Percent(business.ServiceFeeRate)
+	bill.TotalAmount = bill.Subtotal + bill.TaxAmount + bill.ServiceFeeAmount
+	bill.UpdatedAt = time.Now()
+
+	if err := tx.Model(&Bill{}).Where("id = ?", bill.ID).Updates(map[string]interface{}{
+		"items":              bill.Items,
+		"subtotal":           bill.Subtotal,
+		"tax_amount":         bill.TaxAmount,
+		"service_fee_amount": bill.ServiceFeeAmount,
+		"total_amount":       bill.TotalAmount,
+		"updated_at":         bill.UpdatedAt,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update bill items: %w", err)
+	}
 	return nil
 }
 
@@ -816,10 +808,15 @@ func UpdateOrderStatus(orderID uint, status OrderStatus, approvedBy string) erro
 func CreateCountersForBusiness(businessID uint, count int, prefix string) error {
 	var counters []Counter
 	for i := 1; i <= count; i++ {
+		code, err := generateUniqueCode(db, &Counter{}, "counter_code")
+		if err != nil {
+			return fmt.Errorf("failed to generate counter code: %w", err)
+		}
 		counter := Counter{
 			BusinessID:    businessID,
 			CounterNumber: i,
 			Name:          fmt.Sprintf("%s%d", prefix, i),
+			CounterCode:   code,
 			IsActive:      true,
 		}
 		counters = append(counters, counter)
@@ -901,10 +898,16 @@ func UpdateBusinessCounters(businessID uint, enabled bool, count int, prefix str
 			
 			if errors.Is(err, gorm.ErrRecordNotFound) {
 				// Create new counter
+				code, err := generateUniqueCode(tx, &Counter{}, "counter_code")
+				if err != nil {
+					tx.Rollback()
+					return fmt.Errorf("failed to generate code for counter %d: %w", i, err)
+				}
 				newCounter := Counter{
 					BusinessID:    businessID,
 					CounterNumber: i,
 					Name:          fmt.Sprintf("%s%d", prefix, i),
+					CounterCode:   code,
 					IsActive:      true,
 				}
 				if err := tx.Create(&newCounter).Error; err != nil {
@@ -948,33 +951,145 @@ func GetCounterByID(id uint) (*Counter, error) {
 	return &counter, nil
 }
 
-// MarkBillAsPaid updates a bill's payment status and details
-func MarkBillAsPaid(billID uint, amountPaid, tipAmount float64, paymentMethod, notes string) error {
-	now := time.Now()
-	
-	updates := map[string]interface{}{
-		"paid_amount":    amountPaid,
-		"tip_amount":     tipAmount,
-		"status":         BillStatusPaid,
-		"updated_at":     now,
-		"closed_at":      &now,
+// GetCounterByCode retrieves an active counter by its code
+func GetCounterByCode(code string) (*Counter, error) {
+	var counter Counter
+	if code == "" {
+		return nil, fmt.Errorf("counter not found")
 	}
-	
-	// Add payment method and notes to the bill's notes field if provided
-	if notes != "" {
-		var existingNotes string
-		if err := db.Model(&Bill{}).Where("id = ?", billID).Select("notes").Scan(&existingNotes).Error; err == nil {
-			if existingNotes != "" {
-				updates["notes"] = fmt.Sprintf("%s\n\nPayment: %s via %s - %s", existingNotes, 
-					fmt.Sprintf("$%.2f", amountPaid), paymentMethod, notes)
-			} else {
-				updates["notes"] = fmt.Sprintf("Payment: %s via %s - %s", 
-					fmt.Sprintf("$%.2f", amountPaid), paymentMethod, notes)
+	if err := db.Where("counter_code = ? AND is_active = ?", code, true).First(&counter).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("counter not found")
+		}
+		return nil, fmt.Errorf("failed to get counter: %w", err)
+	}
+	return &counter, nil
+}
+
+// SetCounterQRCode stores the URL a counter's QR code encodes
+func SetCounterQRCode(counterID uint, url string) error {
+	if err := db.Model(&Counter{}).Where("id = ?", counterID).Update("qr_code", url).Error; err != nil {
+		return fmt.Errorf("failed to update counter QR code: %w", err)
+	}
+	return nil
+}
+
+// RotateCounterCode replaces the code of a business's counter. guestURL gives the URL
+// the new QR code encodes.
+func RotateCounterCode(businessID, counterID uint, guestURL func(code string) string) (*Counter, error) {
+	var counter Counter
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND business_id = ?", counterID, businessID).First(&counter).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("counter not found")
 			}
+			return fmt.Errorf("failed to get counter: %w", err)
 		}
+
+		code, err := generateUniqueCode(tx, &Counter{}, "counter_code")
+		if err != nil {
+			return fmt.Errorf("failed to generate counter code: %w", err)
+		}
+		counter.CounterCode = code
+		counter.QRCode = guestURL(code)
+		if err := tx.Model(&counter).Updates(map[string]interface{}{
+			"counter_code": counter.CounterCode,
+			"qr_code":      counter.QRCode,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to rotate counter code: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &counter, nil
+}
+
+// MarkBillAsPaid records a staff-confirmed payment in the ledger and settles the bill
+func MarkBillAsPaid(billID uint, amountPaid, tipAmount money.Amount, paymentMethod, notes, confirmedBy string) error {
+	now := time.Now()
+
+	var bill Bill
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&bill, billID).Error; err != nil {
+			return fmt.Errorf("failed to get bill: %w", err)
+		}
+
+		totals, err := billPaymentTotals(tx, billID)
+		if err != nil {
+			return err
+		}
+
+		// Record whatever the ledger does not already cover as an alternative payment,
+		// so the bill's paid amount ends up equal to amountPaid
+		outstanding := amountPaid - totals.PaidAmount()
+		if outstanding < 0 {
+			outstanding = 0
+		}
+		outstandingTip := tipAmount - totals.TipAmount()
+		if outstandingTip < 0 {
+			outstandingTip = 0
+		}
+		if outstanding > 0 || outstandingTip > 0 {
+			altPayment := &AlternativePayment{
+				BillID:          billID,
+				ParticipantAddr: confirmedBy,
+				Amount:          outstanding,
+				TipAmount:       outstandingTip,
+				PaymentMethod:   alternativeMethodFor(paymentMethod),
+				Status:          AltPaymentStatusConfirmed,
+				ConfirmedBy:     confirmedBy,
+				ConfirmedAt:     &now,
+			}
+			if err := tx.Create(altPayment).Error; err != nil {
+				return fmt.Errorf("failed to record payment: %w", err)
+			}
+		}
+
+		if err := recalculateBillPayments(tx, &bill, confirmedBy); err != nil {
+			return err
+		}
+
+		// Staff confirmation settles the bill even if the ledger falls short of the total
+		if err := transitionBill(tx, &bill, BillStatusPaid, confirmedBy, "marked as paid via "+paymentMethod); err != nil {
+			return err
+		}
+
+		// Add payment method and notes to the bill's notes field if provided
+		if notes == "" {
+			return nil
+		}
+		updates := map[string]interface{}{"updated_at": now}
+		if bill.Notes != "" {
+			updates["notes"] = fmt.Sprintf("%s\n\nPayment: %s via %s - %s", bill.Notes,
+				"$"+amountPaid.String(), paymentMethod, notes)
+		} else {
+			updates["notes"] = fmt.Sprintf("Payment: %s via %s - %s",
+				"$"+amountPaid.String(), paymentMethod, notes)
+		}
+
+		return tx.Model(&Bill{}).Where("id = ?", billID).Updates(updates).Error
+	})
+	if err != nil {
+		return err
+	}
+	notifyTableStatus(bill.BusinessID, bill.TableID)
+	return nil
+}
+
+// alternativeMethodFor maps a staff-selected payment method to an alternative payment method
+func alternativeMethodFor(paymentMethod string) AlternativePaymentMethod {
+	switch paymentMethod {
+	case "cash":
+		return PaymentMethodCash
+	case "card":
+		return PaymentMethodCard
+	case "venmo":
+		return PaymentMethodVenmo
+	default:
+		return PaymentMethodOther
 	}
-	
-	return db.Model(&Bill{}).Where("id = ?", billID).Updates(updates).Error
 }
 
 // CreateSubscriptionPayment creates a new subscription payment record
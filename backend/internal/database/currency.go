@@ -1,7 +1,11 @@
 package database
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -204,6 +208,36 @@ func (s *LanguageService) SetBusinessLanguages(businessID uint, languageCodes []
 	return tx.Commit().Error
 }
 
+// ErrTranslationEntityNotFound is returned for a translation naming an entity that does not exist
+var ErrTranslationEntityNotFound = errors.New("translated entity not found")
+
+// translationEntities maps each entity type translations are kept for to a query listing
+// every such entity's ID with the business that owns it
+var translationEntities = map[string]string{
+	"business":  "SELECT id, id AS business_id FROM businesses",
+	"category":  "SELECT id, business_id FROM menu_categories",
+	"menu_item": "SELECT id, business_id FROM menu_items",
+	"menu_item_option": "SELECT menu_item_options.id, menu_items.business_id FROM menu_item_options " +
+		"JOIN menu_items ON menu_items.id = menu_item_options.menu_item_id",
+}
+
+// GetTranslationEntityBusinessID returns the ID of the business that owns the entity a
+// translation is kept for
+func GetTranslationEntityBusinessID(entityType string, entityID uint) (uint, error) {
+	owners, ok := translationEntities[entityType]
+	if !ok {
+		return 0, ErrTranslationEntityNotFound
+	}
+	var businessIDs []uint
+	if err := db.Raw("SELECT business_id FROM ("+owners+") AS owners WHERE id = ?", entityID).Scan(&businessIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to get translated entity: %w", err)
+	}
+	if len(businessIDs) == 0 {
+		return 0, ErrTranslationEntityNotFound
+	}
+	return businessIDs[0], nil
+}
+
 // TranslationService handles translation-related database operations
 type TranslationService struct {
 	db *gorm.DB
@@ -227,6 +261,23 @@ func (s *TranslationService) SaveTranslation(translation *Translation) error {
 	return s.db.Save(translation).Error
 }
 
+// GetBusinessTranslations gets the translations into a language of a business and of
+// its menu
+func (s *TranslationService) GetBusinessTranslations(businessID uint, languageCode string) ([]Translation, error) {
+	conditions := make([]string, 0, len(translationEntities))
+	args := make([]interface{}, 0, 2*len(translationEntities)+1)
+	for entityType, owners := range translationEntities {
+		conditions = append(conditions, "(entity_type = ? AND entity_id IN (SELECT id FROM ("+owners+") AS owners WHERE business_id = ?))")
+		args = append(args, entityType, businessID)
+	}
+
+	var translations []Translation
+	err := s.db.Where("language_code = ?", languageCode).
+		Where(strings.Join(conditions, " OR "), args...).
+		Find(&translations).Error
+	return translations, err
+}
+
 // GetEntityTranslations gets all translations for a specific entity
 func (s *TranslationService) GetEntityTranslations(entityType string, entityID uint) ([]Translation, error) {
 	var translations []Translation
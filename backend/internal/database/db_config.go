@@ -8,21 +8,96 @@ import (
 	"path/filepath"
 	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
+// Database drivers DbConfig can select
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
 type DbConfig struct {
-	DatabasePath string `json:"database_path"`
+	Driver       string `json:"driver"`        // sqlite or postgres, sqlite when empty
+	DatabasePath string `json:"database_path"` // SQLite database file
+	DSN          string `json:"dsn"`           // Postgres connection string
+
+	// Connection pool limits, left to database/sql defaults when zero
+	MaxOpenConns    int           `json:"max_open_conns"`
+	MaxIdleConns    int           `json:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time"`
 }
 
+// NewConfig returns the configuration of a SQLite database file
 func NewConfig(databasePath string) *DbConfig {
 	return &DbConfig{
+		Driver:       DriverSQLite,
 		DatabasePath: databasePath,
 	}
 }
 
+// NewPostgresConfig returns the configuration of a Postgres database
+func NewPostgresConfig(dsn string) *DbConfig {
+	return &DbConfig{
+		Driver: DriverPostgres,
+		DSN:    dsn,
+	}
+}
+
+// dialector returns the GORM driver for the configured database
+func (config *DbConfig) dialector() (gorm.Dialector, error) {
+	switch config.Driver {
+	case DriverSQLite, "":
+		if config.DatabasePath != ":memory:" {
+			if err := os.MkdirAll(filepath.Dir(config.DatabasePath), 0755); err != nil {
+				return nil, fmt.Errorf("failed to create database directory: %w", err)
+			}
+		}
+		return sqlite.Open(config.DatabasePath), nil
+	case DriverPostgres:
+		if config.DSN == "" {
+			return nil, fmt.Errorf("postgres requires a DSN")
+		}
+		return postgres.Open(config.DSN), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", config.Driver)
+	}
+}
+
+// Open connects to the configured database and applies its pool limits
+func Open(config *DbConfig, gormConfig *gorm.Config) (*gorm.DB, error) {
+	dialector, err := config.dialector()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := gorm.Open(dialector, gormConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	sqlDB, err := conn.DB()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database handle: %w", err)
+	}
+	if config.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	if config.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+	return conn, nil
+}
+
 var (
 	db *gorm.DB
 )
@@ -56,14 +131,7 @@ func InitTestDB(testDB *gorm.DB) {
 }
 
 func InitDB(config *DbConfig) {
-	// Ensure the directory exists
-	dir := filepath.Dir(config.DatabasePath)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		log.Fatal("Failed to create database directory:", err)
-	}
-
-	// Open SQLite database
-	database, err := gorm.Open(sqlite.Open(config.DatabasePath), &gorm.Config{
+	database, err := Open(config, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
 	if err != nil {
@@ -72,8 +140,7 @@ func InitDB(config *DbConfig) {
 
 	db = database
 
-	// Auto-migrate all models
-	if err := autoMigrate(); err != nil {
+	if err := Migrate(db); err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
@@ -144,10 +211,10 @@ func (d *DB) GetTable(id uint) (*Table, error) {
 	return GetTableByID(id)
 }
 
-// GetBillsByStatus retrieves bills by status
-func (d *DB) GetBillsByStatus(status BillStatus) ([]*Bill, error) {
+// GetBillsByStatus retrieves bills in any of the given statuses
+func (d *DB) GetBillsByStatus(statuses ...BillStatus) ([]*Bill, error) {
 	var bills []Bill
-	if err := d.conn.Where("status = ?", status).Find(&bills).Error; err != nil {
+	if err := d.conn.Where("status IN ?", statuses).Find(&bills).Error; err != nil {
 		return nil, err
 	}
 	result := make([]*Bill, len(bills))
@@ -157,10 +224,9 @@ func (d *DB) GetBillsByStatus(status BillStatus) ([]*Bill, error) {
 	return result, nil
 }
 
-// GetBillItems retrieves bill items by bill ID
+// GetBillItems retrieves bill items by bill ID. Items are stored as JSON on the bill.
 func (db *DB) GetBillItems(billID uint) ([]BillItem, error) {
-	var items []BillItem
-	err := db.conn.Where("bill_id = ?", billID).Find(&items).Error
+	_, items, err := GetBillByID(billID)
 	return items, err
 }
 
@@ -172,10 +238,36 @@ func (db *DB) GetBillsByDateRange(businessID uint, startDate, endDate time.Time)
 	return bills, err
 }
 
-// GetBillsByBusinessAndStatus gets bills by business ID and status
-func (db *DB) GetBillsByBusinessAndStatus(businessID uint, status BillStatus) ([]Bill, error) {
+// MenuItemSummary is a menu item's name with the name of its category
+type MenuItemSummary struct {
+	ID           uint
+	Name         string
+	CategoryName string
+}
+
+// GetMenuItemSummaries returns the business's menu items with their category names, by item ID
+func (db *DB) GetMenuItemSummaries(businessID uint) (map[uint]MenuItemSummary, error) {
+	var rows []MenuItemSummary
+	err := db.conn.Table("menu_items").
+		Select("menu_items.id, menu_items.name, menu_categories.name AS category_name").
+		Joins("JOIN menu_categories ON menu_categories.id = menu_items.category_id").
+		Where("menu_items.business_id = ?", businessID).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[uint]MenuItemSummary, len(rows))
+	for _, row := range rows {
+		summaries[row.ID] = row
+	}
+	return summaries, nil
+}
+
+// GetBillsByBusinessAndStatus gets bills by business ID in any of the given statuses
+func (db *DB) GetBillsByBusinessAndStatus(businessID uint, statuses ...BillStatus) ([]Bill, error) {
 	var bills []Bill
-	err := db.conn.Where("business_id = ? AND status = ?", businessID, status).Find(&bills).Error
+	err := db.conn.Where("business_id = ? AND status IN ?", businessID, statuses).Find(&bills).Error
 	return bills, err
 }
 
@@ -206,43 +298,3 @@ func (db *DB) GetTableByID(id uint) (*Table, error) {
 	}
 	return &table, nil
 }
-
-// autoMigrate creates all necessary tables
-func autoMigrate() error {
-	return db.AutoMigrate(
-		&User{},
-		&Code{},
-		&ErrorLog{},
-		&FaucetTransaction{},
-		&Subscriber{},
-		&MultisigTx{},
-		// Payverge models
-		&Business{},
-		&Menu{},
-		&Table{},
-		&Bill{},
-		&Payment{},
-		&AlternativePayment{},
-		// Staff management models
-		&Staff{},
-		&StaffInvitation{},
-		&StaffLoginCode{},
-		// Order management models
-		&Order{},
-		// Referral system models
-		&Referrer{},
-		&ReferralRecord{},
-		&ReferralCommissionClaim{},
-		// Withdrawal history model
-		&WithdrawalHistory{},
-		// Subscription payment model
-		&SubscriptionPayment{},
-		// Multi-currency and multilingual models
-		&SupportedCurrency{},
-		&ExchangeRate{},
-		&BusinessCurrency{},
-		&SupportedLanguage{},
-		&BusinessLanguage{},
-		&Translation{},
-	)
-}
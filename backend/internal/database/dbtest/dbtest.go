@@ -0,0 +1,104 @@
+// Package dbtest opens the database test suites run against: SQLite in memory, or
+// Postgres when PAYVERGE_TEST_DATABASE_DSN names one.
+package dbtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"payverge/internal/database"
+)
+
+// DSNEnv is the environment variable holding the Postgres database to test against
+const DSNEnv = "PAYVERGE_TEST_DATABASE_DSN"
+
+// Postgres reports whether tests run against Postgres
+func Postgres() bool {
+	return os.Getenv(DSNEnv) != ""
+}
+
+// Open returns a database built by the versioned migrations, so tests fail when the
+// migrations drift from the models. It is closed when the test finishes.
+func Open(tb testing.TB) *gorm.DB {
+	tb.Helper()
+
+	conn := OpenEmpty(tb)
+	if err := database.Migrate(conn); err != nil {
+		tb.Fatalf("failed to migrate test database: %v", err)
+	}
+	return conn
+}
+
+// OpenEmpty returns a database without any tables that is closed when the test
+// finishes. On Postgres each call gets a schema of its own, dropped afterwards, so test
+// packages running in parallel do not see each other's rows.
+func OpenEmpty(tb testing.TB) *gorm.DB {
+	tb.Helper()
+
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		conn, err := database.Open(database.NewConfig(":memory:"), &gorm.Config{})
+		if err != nil {
+			tb.Fatal(err)
+		}
+		tb.Cleanup(func() { closeDB(conn) })
+		return conn
+	}
+
+	admin, err := database.Open(database.NewPostgresConfig(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	schema := newSchemaName(tb)
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		closeDB(admin)
+		tb.Fatalf("failed to create test schema: %v", err)
+	}
+
+	// The migrations leave foreign keys unenforced on Postgres, as SQLite always has
+	conn, err := database.Open(database.NewPostgresConfig(withSearchPath(dsn, schema)), &gorm.Config{
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		closeDB(conn)
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		closeDB(admin)
+	})
+	return conn
+}
+
+func newSchemaName(tb testing.TB) string {
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		tb.Fatal(err)
+	}
+	return "test_" + hex.EncodeToString(suffix)
+}
+
+// withSearchPath points every connection of a DSN, in URL or keyword form, at a schema
+func withSearchPath(dsn, schema string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		if u, err := url.Parse(dsn); err == nil {
+			query := u.Query()
+			query.Set("search_path", schema)
+			u.RawQuery = query.Encode()
+			return u.String()
+		}
+	}
+	return dsn + " search_path=" + schema
+}
+
+func closeDB(conn *gorm.DB) {
+	if sqlDB, err := conn.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
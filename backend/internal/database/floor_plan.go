@@ -0,0 +1,367 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"payverge/internal/money"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidTableLayout is returned for a floor plan position that cannot be saved
+var ErrInvalidTableLayout = errors.New("invalid table layout")
+
+// TableState is the live state of a table, derived from its bills and orders
+type TableState string
+
+const (
+	TableStateFree            TableState = "free"             // Ready for new guests
+	TableStateSeated          TableState = "seated"           // Bill open, nothing ordered yet
+	TableStateOrdered         TableState = "ordered"          // Orders waiting for approval, in the kitchen or not yet served
+	TableStateAwaitingPayment TableState = "awaiting_payment" // Everything served or part of the bill paid
+	TableStatePaid            TableState = "paid"             // Bill paid, guests may still be seated
+	TableStateNeedsCleaning   TableState = "needs_cleaning"   // Bill closed, not yet marked clean
+)
+
+// settledBillStatuses are the statuses of bills whose guests have finished at the table.
+// Voided bills never had guests pay at the table and are left out.
+var settledBillStatuses = []BillStatus{BillStatusPaid, BillStatusClosed, BillStatusRefunded}
+
+// TableStatus is one table on the status board: its floor plan layout and live state
+type TableStatus struct {
+	TableID     uint         `json:"table_id"`
+	Name        string       `json:"name"`
+	TableCode   string       `json:"table_code"`
+	Section     string       `json:"section"`
+	PositionX   float64      `json:"position_x"`
+	PositionY   float64      `json:"position_y"`
+	Seats       int          `json:"seats"`
+	Shape       TableShape   `json:"shape"`
+	Status      TableState   `json:"status"`
+	BillIDs     []uint       `json:"bill_ids"`
+	SeatedAt    *time.Time   `json:"seated_at"`   // When the oldest open bill was opened
+	Outstanding money.Amount `json:"outstanding"` // Unpaid balance of the open bills
+}
+
+// TableLayout places a table on the floor plan
+type TableLayout struct {
+	TableID   uint       `json:"table_id" binding:"required"`
+	Section   string     `json:"section"`
+	PositionX float64    `json:"position_x"`
+	PositionY float64    `json:"position_y"`
+	Seats     int        `json:"seats"`
+	Shape     TableShape `json:"shape"`
+}
+
+// TableStatusNotifier receives a business's status board whenever a table may have
+// changed state
+type TableStatusNotifier interface {
+	NotifyTableStatus(businessID uint, board []TableStatus)
+}
+
+var tableStatusNotifier TableStatusNotifier
+
+// SetTableStatusNotifier sets where status board updates are sent. Bills, orders and
+// payments are written from several packages, so the board is pushed from here.
+func SetTableStatusNotifier(notifier TableStatusNotifier) {
+	tableStatusNotifier = notifier
+}
+
+// notifyTableStatus pushes the business's status board if any of the tables is a real
+// table; counter bills carry a zero table ID. It must be called after the change is
+// committed.
+func notifyTableStatus(businessID uint, tableIDs ...uint) {
+	if tableStatusNotifier == nil {
+		return
+	}
+	atTable := false
+	for _, id := range tableIDs {
+		atTable = atTable || id != 0
+	}
+	if !atTable {
+		return
+	}
+
+	board, err := GetTableBoard(businessID)
+	if err != nil {
+		log.Printf("Failed to build table status board for business %d: %v", businessID, err)
+		return
+	}
+	tableStatusNotifier.NotifyTableStatus(businessID, board)
+}
+
+// notifyBillTableStatus pushes the status board of the table a bill belongs to
+func notifyBillTableStatus(billID uint) {
+	if tableStatusNotifier == nil {
+		return
+	}
+	var bill Bill
+	if err := db.Select("id", "business_id", "table_id").First(&bill, billID).Error; err != nil {
+		return
+	}
+	notifyTableStatus(bill.BusinessID, bill.TableID)
+}
+
+// notifyOrderTableStatus pushes the status board of the table an order was placed at
+func notifyOrderTableStatus(orderID uint) {
+	if tableStatusNotifier == nil {
+		return
+	}
+	var order Order
+	if err := db.Select("id", "bill_id").First(&order, orderID).Error; err != nil {
+		return
+	}
+	notifyBillTableStatus(order.BillID)
+}
+
+// GetTableBoard returns the active tables of a business with their layout and live
+// state, ordered by section and name
+func GetTableBoard(businessID uint) ([]TableStatus, error) {
+	var tables []Table
+	if err := db.Where("business_id = ? AND is_active = ?", businessID, true).
+		Order("section, name, id").Find(&tables).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tables: %w", err)
+	}
+	board := make([]TableStatus, 0, len(tables))
+	if len(tables) == 0 {
+		return board, nil
+	}
+
+	var active []Bill
+	if err := db.Where("business_id = ? AND table_id <> 0 AND status IN ?", businessID, ActiveBillStatuses).
+		Order("created_at, id").Find(&active).Error; err != nil {
+		return nil, fmt.Errorf("failed to get open bills: %w", err)
+	}
+	billsByTable := make(map[uint][]Bill)
+	billIDs := make([]uint, 0, len(active))
+	for _, bill := range active {
+		billsByTable[bill.TableID] = append(billsByTable[bill.TableID], bill)
+		billIDs = append(billIDs, bill.ID)
+	}
+
+	ordersByBill := make(map[uint][]Order)
+	if len(billIDs) > 0 {
+		var orders []Order
+		if err := db.Select("id", "bill_id", "status").
+			Where("bill_id IN ? AND status <> ?", billIDs, OrderStatusOrderCancelled).Find(&orders).Error; err != nil {
+			return nil, fmt.Errorf("failed to get orders: %w", err)
+		}
+		for _, order := range orders {
+			ordersByBill[order.BillID] = append(ordersByBill[order.BillID], order)
+		}
+	}
+
+	// The most recently settled bill of each table tells whether it still needs clearing
+	var settled []Bill
+	if err := db.Where("business_id = ? AND table_id <> 0 AND status IN ? AND closed_at IS NOT NULL", businessID, settledBillStatuses).
+		Where("closed_at = (SELECT MAX(latest.closed_at) FROM bills latest WHERE latest.table_id = bills.table_id AND latest.status IN ?)", settledBillStatuses).
+		Find(&settled).Error; err != nil {
+		return nil, fmt.Errorf("failed to get settled bills: %w", err)
+	}
+	lastSettled := make(map[uint]*Bill, len(settled))
+	for i := range settled {
+		lastSettled[settled[i].TableID] = &settled[i]
+	}
+
+	for i := range tables {
+		table := &tables[i]
+		bills := billsByTable[table.ID]
+		var orders []Order
+		for _, bill := range bills {
+			orders = append(orders, ordersByBill[bill.ID]...)
+		}
+
+		status := TableStatus{
+			TableID:   table.ID,
+			Name:      table.Name,
+			TableCode: table.TableCode,
+			Section:   table.Section,
+			PositionX: table.PositionX,
+			PositionY: table.PositionY,
+			Seats:     table.Seats,
+			Shape:     table.Shape,
+			Status:    deriveTableState(table, bills, orders, lastSettled[table.ID]),
+			BillIDs:   []uint{},
+		}
+		for _, bill := range bills {
+			status.BillIDs = append(status.BillIDs, bill.ID)
+			if balance := bill.TotalAmount - bill.PaidAmount; balance > 0 {
+				status.Outstanding += balance
+			}
+		}
+		if len(bills) > 0 {
+			seatedAt := bills[0].CreatedAt
+			status.SeatedAt = &seatedAt
+		}
+		board = append(board, status)
+	}
+	return board, nil
+}
+
+// deriveTableState works out a table's state from its open bills, their orders and
+// the last bill settled at the table
+func deriveTableState(table *Table, open []Bill, orders []Order, lastSettled *Bill) TableState {
+	if len(open) == 0 {
+		if lastSettled == nil || lastSettled.ClosedAt == nil ||
+			(table.CleanedAt != nil && !lastSettled.ClosedAt.After(*table.CleanedAt)) {
+			return TableStateFree
+		}
+		if lastSettled.Status == BillStatusPaid {
+			return TableStatePaid
+		}
+		return TableStateNeedsCleaning
+	}
+
+	served := false
+	for _, order := range orders {
+		switch order.Status {
+		case OrderStatusOrderDelivered:
+			served = true
+		case OrderStatusOrderCancelled:
+		default:
+			return TableStateOrdered
+		}
+	}
+
+	ordered := false
+	for _, bill := range open {
+		served = served || bill.PaidAmount > 0
+		ordered = ordered || bill.Subtotal > 0
+	}
+	switch {
+	case served:
+		return TableStateAwaitingPayment
+	case ordered:
+		// Items staff added straight to the bill have no order to serve
+		return TableStateOrdered
+	default:
+		return TableStateSeated
+	}
+}
+
+// UpdateFloorPlan saves the layout of some or all of a business's tables
+func UpdateFloorPlan(businessID uint, layouts []TableLayout) error {
+	now := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, layout := range layouts {
+			if layout.Shape == "" {
+				layout.Shape = TableShapeSquare
+			}
+			if !layout.Shape.IsValid() {
+				return fmt.Errorf("%w: unknown shape %q", ErrInvalidTableLayout, layout.Shape)
+			}
+			if layout.Seats < 0 {
+				return fmt.Errorf("%w: seats cannot be negative", ErrInvalidTableLayout)
+			}
+
+			result := tx.Model(&Table{}).Where("id = ? AND business_id = ?", layout.TableID, businessID).
+				Updates(map[string]interface{}{
+					"section":    layout.Section,
+					"position_x": layout.PositionX,
+					"position_y": layout.PositionY,
+					"seats":      layout.Seats,
+					"shape":      layout.Shape,
+					"updated_at": now,
+				})
+			if result.Error != nil {
+				return fmt.Errorf("failed to update table layout: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return fmt.Errorf("table %d not found", layout.TableID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	notifyTableStatus(businessID, tableLayoutIDs(layouts)...)
+	return nil
+}
+
+func tableLayoutIDs(layouts []TableLayout) []uint {
+	ids := make([]uint, len(layouts))
+	for i, layout := range layouts {
+		ids[i] = layout.TableID
+	}
+	return ids
+}
+
+// SeatTable opens an empty bill at a free table for newly seated guests. Seating a
+// table that was waiting to be cleared marks it clean.
+func SeatTable(businessID, tableID uint) (*Bill, error) {
+	var bill Bill
+	err := db.Transaction(func(tx *gorm.DB) error {
+		table, err := transferTable(tx, businessID, tableID)
+		if err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&Bill{}).Where("table_id = ? AND status IN ?", table.ID, ActiveBillStatuses).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to check the table's bills: %w", err)
+		}
+		if count > 0 {
+			return ErrTableHasOpenBill
+		}
+
+		business, err := transferBusiness(tx, businessID)
+		if err != nil {
+			return err
+		}
+		number, err := generateUniqueBillNumber(tx, businessID)
+		if err != nil {
+			return fmt.Errorf("failed to generate bill number: %w", err)
+		}
+
+		bill = Bill{
+			BusinessID:     businessID,
+			TableID:        table.ID,
+			BillNumber:     number,
+			Items:          "[]",
+			Status:         BillStatusOpen,
+			SettlementAddr: business.SettlementAddr,
+			TippingAddr:    business.TippingAddr,
+		}
+		if err := tx.Create(&bill).Error; err != nil {
+			return fmt.Errorf("failed to create bill: %w", err)
+		}
+		return markTableClean(tx, table.ID, bill.CreatedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	notifyTableStatus(businessID, tableID)
+	return &bill, nil
+}
+
+// MarkTableClean records that a table has been cleared and is ready for new guests
+func MarkTableClean(businessID, tableID uint) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		table, err := transferTable(tx, businessID, tableID)
+		if err != nil {
+			return err
+		}
+		return markTableClean(tx, table.ID, time.Now())
+	})
+	if err != nil {
+		return err
+	}
+
+	notifyTableStatus(businessID, tableID)
+	return nil
+}
+
+func markTableClean(tx *gorm.DB, tableID uint, at time.Time) error {
+	if err := tx.Model(&Table{}).Where("id = ?", tableID).
+		Updates(map[string]interface{}{"cleaned_at": &at, "updated_at": at}).Error; err != nil {
+		return fmt.Errorf("failed to mark table clean: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoadIndexerCheckpoint returns the last processed block for the named indexer.
+// The boolean is false when the indexer has never stored a checkpoint.
+func (d *DB) LoadIndexerCheckpoint(name string) (uint64, bool, error) {
+	var checkpoint IndexerCheckpoint
+	err := d.conn.Where("name = ?", name).First(&checkpoint).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to load indexer checkpoint: %w", err)
+	}
+	return checkpoint.LastBlock, true, nil
+}
+
+// SaveIndexerCheckpoint stores the last processed block for the named indexer
+func (d *DB) SaveIndexerCheckpoint(name string, block uint64) error {
+	checkpoint := IndexerCheckpoint{Name: name, LastBlock: block}
+	err := d.conn.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"last_block", "updated_at"}),
+	}).Create(&checkpoint).Error
+	if err != nil {
+		return fmt.Errorf("failed to save indexer checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,565 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrStockItemNotFound is returned when a stock item does not exist for the business
+	ErrStockItemNotFound = errors.New("stock item not found")
+	// ErrInvalidStockAdjustment is returned when a manual stock change does not fit its reason
+	ErrInvalidStockAdjustment = errors.New("invalid stock adjustment")
+	// ErrInvalidRecipe is returned when a recipe refers to unknown stock items or quantities
+	ErrInvalidRecipe = errors.New("invalid recipe")
+)
+
+// StockReconciliation totals a stock item's adjustments over a period by reason, so
+// waste can be compared with sales. Sales are net of cancelled orders.
+type StockReconciliation struct {
+	StockItem StockItem `json:"stock_item"`
+	Sold      int64     `json:"sold"`
+	Wasted    int64     `json:"wasted"`
+	Restocked int64     `json:"restocked"`
+	Corrected int64     `json:"corrected"` // Net change from stock takes
+}
+
+// CreateStockItem adds a stock item to a business. Its starting quantity is recorded as
+// a stock count.
+func CreateStockItem(item *StockItem, createdBy string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		quantity := item.Quantity
+		item.ID = 0
+		item.Quantity = 0
+		item.LowStockAlertedAt = nil
+		if item.Unit == "" {
+			item.Unit = "pcs"
+		}
+		if err := tx.Create(item).Error; err != nil {
+			return fmt.Errorf("failed to create stock item: %w", err)
+		}
+		if quantity == 0 {
+			return nil
+		}
+		return changeStock(tx, item, StockReasonCount, quantity, nil, "Opening stock", createdBy)
+	})
+}
+
+// GetStockItems returns the stock items of a business by name
+func GetStockItems(businessID uint) ([]StockItem, error) {
+	items := []StockItem{}
+	if err := db.Where("business_id = ?", businessID).Order("name, id").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to get stock items: %w", err)
+	}
+	return items, nil
+}
+
+// UpdateStockItem updates a stock item's name, unit and low-stock threshold. Quantities
+// only change through adjustments so that every change is audited.
+func UpdateStockItem(businessID, stockItemID uint, item *StockItem) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		stored, err := getStockItem(tx, businessID, stockItemID)
+		if err != nil {
+			return err
+		}
+
+		stored.Name = item.Name
+		if item.Unit != "" {
+			stored.Unit = item.Unit
+		}
+		stored.LowStockThreshold = item.LowStockThreshold
+		if stored.Quantity > stored.LowStockThreshold {
+			stored.LowStockAlertedAt = nil
+		}
+		if err := tx.Model(stored).Select("name", "unit", "low_stock_threshold", "low_stock_alerted_at", "updated_at").
+			Updates(stored).Error; err != nil {
+			return fmt.Errorf("failed to update stock item: %w", err)
+		}
+		*item = *stored
+		return nil
+	})
+}
+
+// DeleteStockItem removes a stock item and the recipe components using it. Its
+// adjustments are kept for the audit trail.
+func DeleteStockItem(businessID, stockItemID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if _, err := getStockItem(tx, businessID, stockItemID); err != nil {
+			return err
+		}
+		if err := tx.Where("stock_item_id = ?", stockItemID).Delete(&RecipeComponent{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recipe components: %w", err)
+		}
+		if err := tx.Delete(&StockItem{}, stockItemID).Error; err != nil {
+			return fmt.Errorf("failed to delete stock item: %w", err)
+		}
+		return nil
+	})
+}
+
+// AdjustStock records a delivery (restock, positive change) or a loss (waste, negative
+// change) of a stock item
+func AdjustStock(businessID, stockItemID uint, reason StockAdjustmentReason, change int64, note, createdBy string) (*StockItem, error) {
+	switch {
+	case reason == StockReasonRestock && change > 0:
+	case reason == StockReasonWaste && change < 0:
+	default:
+		return nil, fmt.Errorf("%w: a %s cannot change stock by %d", ErrInvalidStockAdjustment, reason, change)
+	}
+
+	var item *StockItem
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if item, err = getStockItem(tx, businessID, stockItemID); err != nil {
+			return err
+		}
+		return changeStock(tx, item, reason, change, nil, note, createdBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// CountStock sets a stock item to the quantity found in a stock take, recording the
+// difference as a correction
+func CountStock(businessID, stockItemID uint, counted int64, note, createdBy string) (*StockItem, error) {
+	if counted < 0 {
+		return nil, fmt.Errorf("%w: a counted quantity cannot be negative", ErrInvalidStockAdjustment)
+	}
+
+	var item *StockItem
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if item, err = getStockItem(tx, businessID, stockItemID); err != nil {
+			return err
+		}
+		return changeStock(tx, item, StockReasonCount, counted-item.Quantity, nil, note, createdBy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// GetStockAdjustments returns the newest first adjustments of a business in a period,
+// optionally for one stock item. Zero times leave the period open.
+func GetStockAdjustments(businessID, stockItemID uint, from, to time.Time) ([]StockAdjustment, error) {
+	query := db.Where("business_id = ?", businessID)
+	if stockItemID != 0 {
+		query = query.Where("stock_item_id = ?", stockItemID)
+	}
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+
+	adjustments := []StockAdjustment{}
+	if err := query.Order("created_at DESC, id DESC").Find(&adjustments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get stock adjustments: %w", err)
+	}
+	return adjustments, nil
+}
+
+// GetStockReconciliation totals the adjustments of every stock item of a business in
+// a period. Zero times leave the period open.
+func GetStockReconciliation(businessID uint, from, to time.Time) ([]StockReconciliation, error) {
+	items, err := GetStockItems(businessID)
+	if err != nil {
+		return nil, err
+	}
+	adjustments, err := GetStockAdjustments(businessID, 0, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[uint]int, len(items))
+	report := make([]StockReconciliation, len(items))
+	for i, item := range items {
+		index[item.ID] = i
+		report[i].StockItem = item
+	}
+	for _, adjustment := range adjustments {
+		i, ok := index[adjustment.StockItemID]
+		if !ok {
+			continue // the stock item was deleted
+		}
+		switch adjustment.Reason {
+		case StockReasonSale, StockReasonSaleCancelled:
+			report[i].Sold -= adjustment.Change
+		case StockReasonWaste:
+			report[i].Wasted -= adjustment.Change
+		case StockReasonRestock:
+			report[i].Restocked += adjustment.Change
+		case StockReasonCount:
+			report[i].Corrected += adjustment.Change
+		}
+	}
+	return report, nil
+}
+
+// GetMenuItemRecipe returns the stock used to make one of a business's menu items
+func GetMenuItemRecipe(businessID, itemID uint) ([]RecipeComponent, error) {
+	if err := checkMenuItem(db, businessID, itemID); err != nil {
+		return nil, err
+	}
+	components := []RecipeComponent{}
+	if err := db.Where("menu_item_id = ?", itemID).Order("id").Find(&components).Error; err != nil {
+		return nil, fmt.Errorf("failed to get recipe: %w", err)
+	}
+	return components, nil
+}
+
+// SetMenuItemRecipe replaces the stock used to make one of a menu item. An empty recipe
+// stops tracking stock for the item.
+func SetMenuItemRecipe(businessID, itemID uint, components []RecipeComponent) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := checkMenuItem(tx, businessID, itemID); err != nil {
+			return err
+		}
+		return saveRecipe(tx, businessID, itemID, components)
+	})
+}
+
+// TrackMenuItemStock counts a menu item in portions: the item gets a stock item of its
+// own, set to quantity, and a recipe using one portion of it
+func TrackMenuItemStock(businessID, itemID uint, quantity, lowStockThreshold int64, createdBy string) (*StockItem, error) {
+	if quantity < 0 || lowStockThreshold < 0 {
+		return nil, fmt.Errorf("%w: stock quantities cannot be negative", ErrInvalidStockAdjustment)
+	}
+
+	var stock StockItem
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var item MenuItem
+		err := tx.Where("id = ? AND business_id = ?", itemID, businessID).First(&item).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMenuItemNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get menu item: %w", err)
+		}
+
+		err = tx.Where("business_id = ? AND menu_item_id = ?", businessID, itemID).First(&stock).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			stock = StockItem{BusinessID: businessID, MenuItemID: &item.ID, Name: item.Name, Unit: "portions"}
+			err = tx.Create(&stock).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get stock item: %w", err)
+		}
+
+		stock.LowStockThreshold = lowStockThreshold
+		if err := tx.Model(&stock).Update("low_stock_threshold", lowStockThreshold).Error; err != nil {
+			return fmt.Errorf("failed to update stock item: %w", err)
+		}
+		if err := changeStock(tx, &stock, StockReasonCount, quantity-stock.Quantity, nil, "", createdBy); err != nil {
+			return err
+		}
+		return saveRecipe(tx, businessID, itemID, []RecipeComponent{{StockItemID: stock.ID, Quantity: 1}})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
+
+// ClaimLowStockAlerts returns the stock items of a business at or below their low-stock
+// threshold that have not been alerted on yet, and marks them as alerted. They are
+// alerted on again once they have been restocked above the threshold and run low again.
+func ClaimLowStockAlerts(businessID uint) ([]StockItem, error) {
+	var items []StockItem
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("business_id = ? AND quantity <= low_stock_threshold AND low_stock_alerted_at IS NULL", businessID).
+			Order("name, id").Find(&items).Error; err != nil {
+			return fmt.Errorf("failed to get low stock items: %w", err)
+		}
+		if len(items) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		ids := make([]uint, len(items))
+		for i := range items {
+			ids[i] = items[i].ID
+			items[i].LowStockAlertedAt = &now
+		}
+		if err := tx.Model(&StockItem{}).Where("id IN ?", ids).Update("low_stock_alerted_at", now).Error; err != nil {
+			return fmt.Errorf("failed to mark low stock alerts: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// getStockItem returns a stock item of the business
+func getStockItem(tx *gorm.DB, businessID, stockItemID uint) (*StockItem, error) {
+	var item StockItem
+	err := tx.Where("id = ? AND business_id = ?", stockItemID, businessID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrStockItemNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stock item: %w", err)
+	}
+	return &item, nil
+}
+
+// checkMenuItem returns ErrMenuItemNotFound unless the item belongs to the business
+func checkMenuItem(tx *gorm.DB, businessID, itemID uint) error {
+	var count int64
+	if err := tx.Model(&MenuItem{}).Where("id = ? AND business_id = ?", itemID, businessID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to get menu item: %w", err)
+	}
+	if count == 0 {
+		return ErrMenuItemNotFound
+	}
+	return nil
+}
+
+// saveRecipe replaces the recipe of a menu item after checking its components
+func saveRecipe(tx *gorm.DB, businessID, itemID uint, components []RecipeComponent) error {
+	seen := make(map[uint]bool, len(components))
+	for i := range components {
+		component := &components[i]
+		if component.Quantity <= 0 {
+			return fmt.Errorf("%w: quantities must be positive", ErrInvalidRecipe)
+		}
+		if seen[component.StockItemID] {
+			return fmt.Errorf("%w: stock item %d is used twice", ErrInvalidRecipe, component.StockItemID)
+		}
+		seen[component.StockItemID] = true
+		if _, err := getStockItem(tx, businessID, component.StockItemID); err != nil {
+			if errors.Is(err, ErrStockItemNotFound) {
+				return fmt.Errorf("%w: stock item %d not found", ErrInvalidRecipe, component.StockItemID)
+			}
+			return err
+		}
+		component.ID = 0
+		component.MenuItemID = itemID
+	}
+
+	if err := tx.Where("menu_item_id = ?", itemID).Delete(&RecipeComponent{}).Error; err != nil {
+		return fmt.Errorf("failed to delete recipe: %w", err)
+	}
+	if len(components) > 0 {
+		if err := tx.Create(&components).Error; err != nil {
+			return fmt.Errorf("failed to create recipe: %w", err)
+		}
+	}
+	return nil
+}
+
+// changeStock changes a stock item's quantity and records the adjustment. A stock item
+// back above its threshold can be alerted on again.
+func changeStock(tx *gorm.DB, item *StockItem, reason StockAdjustmentReason, change int64, orderID *uint, note, createdBy string) error {
+	now := time.Now()
+	if err := tx.Model(&StockItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+		"quantity":   gorm.Expr("quantity + ?", change),
+		"updated_at": now,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update stock: %w", err)
+	}
+	if err := tx.First(item, item.ID).Error; err != nil {
+		return fmt.Errorf("failed to get stock item: %w", err)
+	}
+	if item.Quantity > item.LowStockThreshold && item.LowStockAlertedAt != nil {
+		item.LowStockAlertedAt = nil
+		if err := tx.Model(item).Update("low_stock_alerted_at", nil).Error; err != nil {
+			return fmt.Errorf("failed to update stock item: %w", err)
+		}
+	}
+
+	adjustment := StockAdjustment{
+		BusinessID:    item.BusinessID,
+		StockItemID:   item.ID,
+		OrderID:       orderID,
+		Reason:        reason,
+		Change:        change,
+		QuantityAfter: item.Quantity,
+		Note:          note,
+		CreatedBy:     createdBy,
+		CreatedAt:     now,
+	}
+	if err := tx.Create(&adjustment).Error; err != nil {
+		return fmt.Errorf("failed to record stock adjustment: %w", err)
+	}
+	return nil
+}
+
+// recipeDemand is the stock used to make the given quantities of menu items, by stock
+// item ID
+func recipeDemand(tx *gorm.DB, quantities map[uint]int64) (map[uint]int64, error) {
+	demand := make(map[uint]int64)
+	if len(quantities) == 0 {
+		return demand, nil
+	}
+	itemIDs := make([]uint, 0, len(quantities))
+	for id := range quantities {
+		itemIDs = append(itemIDs, id)
+	}
+
+	var components []RecipeComponent
+	if err := tx.Where("menu_item_id IN ?", itemIDs).Find(&components).Error; err != nil {
+		return nil, fmt.Errorf("failed to get recipes: %w", err)
+	}
+	for _, component := range components {
+		demand[component.StockItemID] += component.Quantity * quantities[component.MenuItemID]
+	}
+	return demand, nil
+}
+
+// sortedStockIDs returns the stock item IDs of a demand in order, so that stock rows are
+// always changed in the same order
+func sortedStockIDs(demand map[uint]int64) []uint {
+	ids := make([]uint, 0, len(demand))
+	for id := range demand {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// consumeOrderStock takes the stock used by an approved order. Only what the order
+// does not already hold is taken, so approving an order again takes nothing unless its
+// stock was returned in the meantime.
+func consumeOrderStock(tx *gorm.DB, order *Order, items []OrderItem) error {
+	taken, err := orderStockTaken(tx, order.ID)
+	if err != nil {
+		return err
+	}
+
+	quantities := make(map[uint]int64)
+	for _, item := range items {
+		// Items ordered before menu items had stable IDs cannot be matched to a recipe
+		id, err := strconv.ParseUint(item.MenuItemID, 10, 32)
+		if err != nil {
+			continue
+		}
+		quantities[uint(id)] += int64(item.Quantity)
+	}
+	demand, err := recipeDemand(tx, quantities)
+	if err != nil {
+		return err
+	}
+
+	orderID := order.ID
+	for _, stockID := range sortedStockIDs(demand) {
+		missing := demand[stockID] - taken[stockID]
+		if missing <= 0 {
+			continue
+		}
+		stock := StockItem{ID: stockID}
+		if err := changeStock(tx, &stock, StockReasonSale, -missing, &orderID, order.OrderNumber, order.ApprovedBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderStockTaken returns the net quantity an order holds of each stock item: what
+// its sales took minus what was returned
+func orderStockTaken(tx *gorm.DB, orderID uint) (map[uint]int64, error) {
+	var adjustments []StockAdjustment
+	if err := tx.Where("order_id = ?", orderID).Find(&adjustments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get order stock: %w", err)
+	}
+	taken := make(map[uint]int64)
+	for _, adjustment := range adjustments {
+		taken[adjustment.StockItemID] -= adjustment.Change
+	}
+	return taken, nil
+}
+
+// restoreOrderStock returns the stock taken by a cancelled order, if it was taken and
+// not returned yet
+func restoreOrderStock(tx *gorm.DB, orderID uint) error {
+	taken, err := orderStockTaken(tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	var order Order
+	if err := tx.Select("id", "order_number").First(&order, orderID).Error; err != nil {
+		return fmt.Errorf("failed to get order: %w", err)
+	}
+
+	for _, stockID := range sortedStockIDs(taken) {
+		if taken[stockID] <= 0 {
+			continue
+		}
+		stock := StockItem{ID: stockID}
+		err := changeStock(tx, &stock, StockReasonSaleCancelled, taken[stockID], &orderID, order.OrderNumber, "")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue // the stock item was deleted
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// outOfStockItems returns the menu items, among the given ones, that some stock item
+// cannot cover one more of
+func outOfStockItems(tx *gorm.DB, itemIDs []uint) (map[uint]bool, error) {
+	out := make(map[uint]bool)
+	if len(itemIDs) == 0 {
+		return out, nil
+	}
+
+	var rows []struct {
+		MenuItemID uint
+		Needed     int64
+		Quantity   int64
+	}
+	err := tx.Table("menu_item_recipes").
+		Select("menu_item_recipes.menu_item_id, menu_item_recipes.quantity AS needed, stock_items.quantity").
+		Joins("JOIN stock_items ON stock_items.id = menu_item_recipes.stock_item_id").
+		Where("menu_item_recipes.menu_item_id IN ?", itemIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get menu item stock: %w", err)
+	}
+	for _, row := range rows {
+		if row.Quantity < row.Needed {
+			out[row.MenuItemID] = true
+		}
+	}
+	return out, nil
+}
+
+// checkStock rejects selections needing more of a stock item than is left
+func checkStock(tx *gorm.DB, priced []pricedSelection) error {
+	quantities := make(map[uint]int64)
+	for _, p := range priced {
+		quantities[p.item.ID] += int64(p.quantity)
+	}
+	demand, err := recipeDemand(tx, quantities)
+	if err != nil {
+		return err
+	}
+	if len(demand) == 0 {
+		return nil
+	}
+
+	var stock []StockItem
+	if err := tx.Where("id IN ?", sortedStockIDs(demand)).Find(&stock).Error; err != nil {
+		return fmt.Errorf("failed to get stock: %w", err)
+	}
+	for _, item := range stock {
+		if demand[item.ID] > item.Quantity {
+			return fmt.Errorf("%w: not enough %s left", ErrInvalidMenuSelection, item.Name)
+		}
+	}
+	return nil
+}
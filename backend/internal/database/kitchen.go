@@ -0,0 +1,442 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrKitchenStationNotFound is returned when a station does not exist for the business
+	ErrKitchenStationNotFound = errors.New("kitchen station not found")
+	// ErrKitchenTicketNotFound is returned when a ticket does not exist for the business
+	ErrKitchenTicketNotFound = errors.New("kitchen ticket not found")
+	// ErrKitchenTicketClosed is returned when starting or bumping a finished ticket
+	ErrKitchenTicketClosed = errors.New("kitchen ticket is already closed")
+	// ErrKitchenRouteTargetNotFound is returned when a route names a menu item or
+	// category the business does not have
+	ErrKitchenRouteTargetNotFound = errors.New("menu item or category not found")
+)
+
+// CreateKitchenStation adds a station to a business's kitchen display system
+func CreateKitchenStation(station *KitchenStation) error {
+	station.Name = strings.TrimSpace(station.Name)
+	if station.Name == "" {
+		return fmt.Errorf("station name is required")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if station.IsDefault {
+			if err := clearDefaultStation(tx, station.BusinessID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(station).Error; err != nil {
+			return fmt.Errorf("failed to create kitchen station: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetKitchenStations returns a business's stations in display order
+func GetKitchenStations(businessID uint) ([]KitchenStation, error) {
+	var stations []KitchenStation
+	if err := db.Where("business_id = ?", businessID).Order("sort_order, id").Find(&stations).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kitchen stations: %w", err)
+	}
+	return stations, nil
+}
+
+// UpdateKitchenStation saves a station's name, order, default flag and active state
+func UpdateKitchenStation(station *KitchenStation) error {
+	station.Name = strings.TrimSpace(station.Name)
+	if station.Name == "" {
+		return fmt.Errorf("station name is required")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if station.IsDefault {
+			if err := clearDefaultStation(tx, station.BusinessID); err != nil {
+				return err
+			}
+		}
+		result := tx.Model(&KitchenStation{}).
+			Where("id = ? AND business_id = ?", station.ID, station.BusinessID).
+			Updates(map[string]interface{}{
+				"name":       station.Name,
+				"is_default": station.IsDefault,
+				"is_active":  station.IsActive,
+				"sort_order": station.SortOrder,
+				"updated_at": time.Now(),
+			})
+		if result.Error != nil {
+			return fmt.Errorf("failed to update kitchen station: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrKitchenStationNotFound
+		}
+		return nil
+	})
+}
+
+// DeleteKitchenStation removes a station and its routes. Tickets already sent to it are kept.
+func DeleteKitchenStation(businessID, stationID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND business_id = ?", stationID, businessID).Delete(&KitchenStation{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete kitchen station: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrKitchenStationNotFound
+		}
+		if err := tx.Where("station_id = ?", stationID).Delete(&KitchenRoute{}).Error; err != nil {
+			return fmt.Errorf("failed to delete kitchen routes: %w", err)
+		}
+		return nil
+	})
+}
+
+// clearDefaultStation unsets the default flag on all of a business's stations
+func clearDefaultStation(tx *gorm.DB, businessID uint) error {
+	if err := tx.Model(&KitchenStation{}).Where("business_id = ? AND is_default = ?", businessID, true).
+		Update("is_default", false).Error; err != nil {
+		return fmt.Errorf("failed to update default kitchen station: %w", err)
+	}
+	return nil
+}
+
+// GetKitchenRoutes returns the routes of a business
+func GetKitchenRoutes(businessID uint) ([]KitchenRoute, error) {
+	var routes []KitchenRoute
+	if err := db.Where("business_id = ?", businessID).Order("id").Find(&routes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kitchen routes: %w", err)
+	}
+	return routes, nil
+}
+
+// SetKitchenRoutes replaces all of a business's routes
+func SetKitchenRoutes(businessID uint, routes []KitchenRoute) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var stationIDs []uint
+		if err := tx.Model(&KitchenStation{}).Where("business_id = ?", businessID).Pluck("id", &stationIDs).Error; err != nil {
+			return fmt.Errorf("failed to get kitchen stations: %w", err)
+		}
+		owned := make(map[uint]bool, len(stationIDs))
+		for _, id := range stationIDs {
+			owned[id] = true
+		}
+
+		var itemIDs, categoryIDs []uint
+		for i := range routes {
+			route := &routes[i]
+			route.ID = 0
+			route.BusinessID = businessID
+			if !owned[route.StationID] {
+				return ErrKitchenStationNotFound
+			}
+			if (route.CategoryID == 0) == (route.MenuItemID == 0) {
+				return fmt.Errorf("a route needs either a category or a menu item")
+			}
+			if route.MenuItemID != 0 {
+				itemIDs = append(itemIDs, route.MenuItemID)
+			} else {
+				categoryIDs = append(categoryIDs, route.CategoryID)
+			}
+		}
+		if err := checkBusinessRecords(tx, &MenuItem{}, businessID, itemIDs); err != nil {
+			return err
+		}
+		if err := checkBusinessRecords(tx, &MenuCategory{}, businessID, categoryIDs); err != nil {
+			return err
+		}
+
+		if err := tx.Where("business_id = ?", businessID).Delete(&KitchenRoute{}).Error; err != nil {
+			return fmt.Errorf("failed to clear kitchen routes: %w", err)
+		}
+		if len(routes) == 0 {
+			return nil
+		}
+		if err := tx.Create(&routes).Error; err != nil {
+			return fmt.Errorf("failed to create kitchen routes: %w", err)
+		}
+		return nil
+	})
+}
+
+// checkBusinessRecords ensures every ID names a record of the model owned by the business
+func checkBusinessRecords(tx *gorm.DB, model interface{}, businessID uint, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	distinct := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		distinct[id] = true
+	}
+	var count int64
+	if err := tx.Model(model).Where("business_id = ? AND id IN ?", businessID, ids).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check route targets: %w", err)
+	}
+	if count != int64(len(distinct)) {
+		return ErrKitchenRouteTargetNotFound
+	}
+	return nil
+}
+
+// fanOutOrder splits an approved order into one ticket per station. An item goes to the
+// station its menu item is routed to, else the station its menu category is routed to,
+// else the default station. Businesses without active stations do not use the KDS and
+// get no tickets. Orders are approved only once, from pending, so this runs once per order.
+func fanOutOrder(tx *gorm.DB, order *Order, items []OrderItem) ([]KitchenTicket, error) {
+	var stations []KitchenStation
+	if err := tx.Where("business_id = ? AND is_active = ?", order.BusinessID, true).
+		Order("sort_order, id").Find(&stations).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kitchen stations: %w", err)
+	}
+	if len(stations) == 0 || len(items) == 0 {
+		return nil, nil
+	}
+
+	byID := make(map[uint]*KitchenStation, len(stations))
+	fallback := &stations[0]
+	for i := range stations {
+		byID[stations[i].ID] = &stations[i]
+		if stations[i].IsDefault {
+			fallback = &stations[i]
+		}
+	}
+
+	var routes []KitchenRoute
+	if err := tx.Where("business_id = ?", order.BusinessID).Find(&routes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kitchen routes: %w", err)
+	}
+	itemRoutes := make(map[uint]uint)
+	categoryRoutes := make(map[uint]uint)
+	for _, route := range routes {
+		if _, active := byID[route.StationID]; !active {
+			continue
+		}
+		if route.MenuItemID != 0 {
+			itemRoutes[route.MenuItemID] = route.StationID
+		} else {
+			categoryRoutes[route.CategoryID] = route.StationID
+		}
+	}
+
+	categories, err := menuItemCategories(tx, order.BusinessID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Group items by station, keeping stations in display order
+	grouped := make(map[uint][]OrderItem)
+	for _, item := range items {
+		stationID, routed := uint(0), false
+		// Items ordered before menu items had stable IDs go to the default station
+		if id, err := strconv.ParseUint(item.MenuItemID, 10, 32); err == nil {
+			stationID, routed = itemRoutes[uint(id)]
+			if !routed {
+				if categoryID, ok := categories[uint(id)]; ok {
+					stationID, routed = categoryRoutes[categoryID]
+				}
+			}
+		}
+		if !routed {
+			stationID = fallback.ID
+		}
+		grouped[stationID] = append(grouped[stationID], item)
+	}
+
+	var tickets []KitchenTicket
+	for _, station := range stations {
+		stationItems, ok := grouped[station.ID]
+		if !ok {
+			continue
+		}
+		itemsJSON, err := json.Marshal(stationItems)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal ticket items: %w", err)
+		}
+		tickets = append(tickets, KitchenTicket{
+			BusinessID:  order.BusinessID,
+			OrderID:     order.ID,
+			BillID:      order.BillID,
+			StationID:   station.ID,
+			StationName: station.Name,
+			OrderNumber: order.OrderNumber,
+			Notes:       order.Notes,
+			Items:       JSON(itemsJSON),
+			Status:      KitchenTicketPending,
+		})
+	}
+
+	if err := tx.Create(&tickets).Error; err != nil {
+		return nil, fmt.Errorf("failed to create kitchen tickets: %w", err)
+	}
+	return tickets, nil
+}
+
+// menuItemCategories maps the IDs of a business's menu items to their category IDs
+func menuItemCategories(tx *gorm.DB, businessID uint) (map[uint]uint, error) {
+	var rows []struct {
+		ID         uint
+		CategoryID uint
+	}
+	if err := tx.Model(&MenuItem{}).Select("id, category_id").Where("business_id = ?", businessID).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get menu categories: %w", err)
+	}
+
+	categories := make(map[uint]uint, len(rows))
+	for _, row := range rows {
+		categories[row.ID] = row.CategoryID
+	}
+	return categories, nil
+}
+
+// GetKitchenTickets returns a business's tickets oldest first, optionally for one station
+// and limited to some statuses
+func GetKitchenTickets(businessID, stationID uint, statuses ...KitchenTicketStatus) ([]KitchenTicket, error) {
+	query := db.Where("business_id = ?", businessID)
+	if stationID != 0 {
+		query = query.Where("station_id = ?", stationID)
+	}
+	if len(statuses) > 0 {
+		query = query.Where("status IN ?", statuses)
+	}
+
+	var tickets []KitchenTicket
+	if err := query.Order("created_at, id").Find(&tickets).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kitchen tickets: %w", err)
+	}
+	return tickets, nil
+}
+
+// GetKitchenTicketsByOrder returns the tickets an order was split into
+func GetKitchenTicketsByOrder(orderID uint) ([]KitchenTicket, error) {
+	var tickets []KitchenTicket
+	if err := db.Where("order_id = ?", orderID).Order("id").Find(&tickets).Error; err != nil {
+		return nil, fmt.Errorf("failed to get kitchen tickets: %w", err)
+	}
+	return tickets, nil
+}
+
+// StartKitchenTicket marks a ticket as being prepared; the order moves to in_kitchen
+func StartKitchenTicket(businessID, ticketID uint) (*KitchenTicket, *Order, error) {
+	return updateKitchenTicket(businessID, ticketID, KitchenTicketInProgress, "")
+}
+
+// BumpKitchenTicket marks a ticket as done. The order becomes ready once all of its
+// tickets are done.
+func BumpKitchenTicket(businessID, ticketID uint, bumpedBy string) (*KitchenTicket, *Order, error) {
+	return updateKitchenTicket(businessID, ticketID, KitchenTicketDone, bumpedBy)
+}
+
+// updateKitchenTicket moves a ticket forward and derives its order's status from all
+// of the order's tickets
+func updateKitchenTicket(businessID, ticketID uint, to KitchenTicketStatus, actor string) (*KitchenTicket, *Order, error) {
+	var ticket KitchenTicket
+	var order Order
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("id = ? AND business_id = ?", ticketID, businessID).First(&ticket).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrKitchenTicketNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get kitchen ticket: %w", err)
+		}
+
+		switch {
+		case ticket.Status == KitchenTicketDone || ticket.Status == KitchenTicketCancelled:
+			return ErrKitchenTicketClosed
+		case ticket.Status == to:
+			// Starting a started ticket is a no-op
+		default:
+			now := time.Now()
+			updates := map[string]interface{}{"status": to, "updated_at": now}
+			if ticket.StartedAt == nil {
+				updates["started_at"] = &now
+				ticket.StartedAt = &now
+			}
+			if to == KitchenTicketDone {
+				updates["bumped_at"] = &now
+				updates["bumped_by"] = actor
+				ticket.BumpedAt = &now
+				ticket.BumpedBy = actor
+			}
+			// Guard on the previous status so a ticket cannot be bumped twice concurrently
+			result := tx.Model(&KitchenTicket{}).Where("id = ? AND status = ?", ticket.ID, ticket.Status).Updates(updates)
+			if result.Error != nil {
+				return fmt.Errorf("failed to update kitchen ticket: %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return ErrKitchenTicketClosed
+			}
+			ticket.Status = to
+			ticket.UpdatedAt = now
+		}
+
+		if err := tx.First(&order, ticket.OrderID).Error; err != nil {
+			return fmt.Errorf("failed to get order: %w", err)
+		}
+		return syncOrderWithTickets(tx, &order)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return &ticket, &order, nil
+}
+
+// syncOrderWithTickets moves an order in the kitchen to in_kitchen once a ticket is
+// started and to ready once every ticket is done
+func syncOrderWithTickets(tx *gorm.DB, order *Order) error {
+	if order.Status != OrderStatusApproved && order.Status != OrderStatusInKitchen {
+		return nil
+	}
+
+	var tickets []KitchenTicket
+	if err := tx.Where("order_id = ? AND status <> ?", order.ID, KitchenTicketCancelled).Find(&tickets).Error; err != nil {
+		return fmt.Errorf("failed to get kitchen tickets: %w", err)
+	}
+	if len(tickets) == 0 {
+		return nil
+	}
+
+	status := OrderStatusApproved
+	allDone := true
+	for _, ticket := range tickets {
+		if ticket.Status != KitchenTicketPending {
+			status = OrderStatusInKitchen
+		}
+		allDone = allDone && ticket.Status == KitchenTicketDone
+	}
+	if allDone {
+		status = OrderStatusOrderReady
+	}
+	if status == order.Status {
+		return nil
+	}
+
+	now := time.Now()
+	if err := tx.Model(&Order{}).Where("id = ? AND status = ?", order.ID, order.Status).
+		Updates(map[string]interface{}{"status": status, "updated_at": now}).Error; err != nil {
+		return fmt.Errorf("failed to update order status: %w", err)
+	}
+	order.Status = status
+	order.UpdatedAt = now
+	return nil
+}
+
+// cancelKitchenTickets withdraws the open tickets of a cancelled order
+func cancelKitchenTickets(tx *gorm.DB, orderID uint) error {
+	if err := tx.Model(&KitchenTicket{}).
+		Where("order_id = ? AND status IN ?", orderID, []KitchenTicketStatus{KitchenTicketPending, KitchenTicketInProgress}).
+		Updates(map[string]interface{}{"status": KitchenTicketCancelled, "updated_at": time.Now()}).Error; err != nil {
+		return fmt.Errorf("failed to cancel kitchen tickets: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,738 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrMenuNotFound is returned when a business has no such menu, or no menu is served
+	ErrMenuNotFound = errors.New("menu not found")
+	// ErrMenuCategoryNotFound is returned when a category does not exist for the business
+	ErrMenuCategoryNotFound = errors.New("menu category not found")
+	// ErrMenuItemNotFound is returned when a menu item does not exist for the business
+	ErrMenuItemNotFound = errors.New("menu item not found")
+)
+
+// menuItemColumns are the columns written when a menu item is updated
+var menuItemColumns = []string{
+	"category_id", "name", "description", "price", "currency", "image",
+	"allergens", "dietary_tags", "is_available", "sold_out_until", "sort_order", "updated_at",
+}
+
+// CreateMenu creates a new menu for a business, after its other menus, with its
+// schedules, categories and items
+func CreateMenu(menu *Menu, categories []MenuCategory) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&Menu{}).Where("business_id = ?", menu.BusinessID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count menus: %w", err)
+		}
+		menu.SortOrder = int(count)
+		if err := tx.Omit(clause.Associations).Create(menu).Error; err != nil {
+			return fmt.Errorf("failed to create menu: %w", err)
+		}
+		if err := saveMenuSchedules(tx, menu); err != nil {
+			return err
+		}
+		if err := saveMenuCategories(tx, menu, categories); err != nil {
+			return err
+		}
+		menu.Categories = categories
+		return nil
+	})
+}
+
+// GetMenuByBusinessID retrieves the main menu of a business: its first active menu
+func GetMenuByBusinessID(businessID uint) (*Menu, []MenuCategory, error) {
+	var menu Menu
+	err := db.Where("business_id = ? AND is_active = ?", businessID, true).Order("sort_order, id").First(&menu).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrMenuNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get menu: %w", err)
+	}
+	if err := loadMenu(db, &menu); err != nil {
+		return nil, nil, err
+	}
+	return &menu, menu.Categories, nil
+}
+
+// GetMenus returns all menus of a business in sort order, with their schedules,
+// categories and items
+func GetMenus(businessID uint) ([]Menu, error) {
+	menus := []Menu{}
+	if err := db.Where("business_id = ?", businessID).Order("sort_order, id").Find(&menus).Error; err != nil {
+		return nil, fmt.Errorf("failed to get menus: %w", err)
+	}
+	for i := range menus {
+		if err := loadMenu(db, &menus[i]); err != nil {
+			return nil, err
+		}
+	}
+	return menus, nil
+}
+
+// GetAllMenuCategories returns the categories of all of a business's menus
+func GetAllMenuCategories(businessID uint) ([]MenuCategory, error) {
+	menus, err := GetMenus(businessID)
+	if err != nil {
+		return nil, err
+	}
+	categories := []MenuCategory{}
+	for _, menu := range menus {
+		categories = append(categories, menu.Categories...)
+	}
+	return categories, nil
+}
+
+// GetMenu returns a menu of the business with its schedules, categories and items
+func GetMenu(businessID, menuID uint) (*Menu, error) {
+	var menu Menu
+	err := db.Where("id = ? AND business_id = ?", menuID, businessID).First(&menu).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMenuNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get menu: %w", err)
+	}
+	if err := loadMenu(db, &menu); err != nil {
+		return nil, err
+	}
+	return &menu, nil
+}
+
+// GetMenuAt returns the menu a business serves at the given time, as guests see it
+// then: items outside their schedules, sold out at that time or out of stock are marked
+// unavailable
+func GetMenuAt(businessID uint, at time.Time) (*Menu, []MenuCategory, error) {
+	loc, err := businessLocation(db, businessID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var menus []Menu
+	err = db.Where("business_id = ? AND is_active = ?", businessID, true).Order("sort_order, id").
+		Preload("Schedules", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		Find(&menus).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get menus: %w", err)
+	}
+	menu := menuServedAt(menus, at.In(loc))
+	if menu == nil {
+		return nil, nil, ErrMenuNotFound
+	}
+
+	categories, err := loadMenuCategories(db, menu.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var itemIDs []uint
+	for _, category := range categories {
+		for _, item := range category.Items {
+			itemIDs = append(itemIDs, item.ID)
+		}
+	}
+	outOfStock, err := outOfStockItems(db, itemIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range categories {
+		for j := range categories[i].Items {
+			item := &categories[i].Items[j]
+			item.IsAvailable = itemAvailableAt(*item, at, loc) && !outOfStock[item.ID]
+		}
+	}
+	menu.Categories = categories
+	return menu, categories, nil
+}
+
+// UpdateMenu updates a menu's name, order, state and schedules and replaces its
+// categories. Categories, items and options sent with their ID keep it; the ones left
+// out are deleted. Nil categories leave the menu's content as it is.
+func UpdateMenu(menu *Menu, categories []MenuCategory) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		menu.UpdatedAt = time.Now()
+		if err := tx.Model(menu).Omit(clause.Associations).Updates(map[string]interface{}{
+			"name":       menu.Name,
+			"is_active":  menu.IsActive,
+			"sort_order": menu.SortOrder,
+			"updated_at": menu.UpdatedAt,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update menu: %w", err)
+		}
+		if err := saveMenuSchedules(tx, menu); err != nil {
+			return err
+		}
+		if categories == nil {
+			var err error
+			menu.Categories, err = loadMenuCategories(tx, menu.ID)
+			return err
+		}
+		if err := saveMenuCategories(tx, menu, categories); err != nil {
+			return err
+		}
+		menu.Categories = categories
+		return nil
+	})
+}
+
+// DeleteMenu removes a menu with its schedules, categories and items
+func DeleteMenu(businessID, menuID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var menu Menu
+		err := tx.Where("id = ? AND business_id = ?", menuID, businessID).First(&menu).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMenuNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get menu: %w", err)
+		}
+
+		var categoryIDs, itemIDs []uint
+		if err := tx.Model(&MenuCategory{}).Where("menu_id = ?", menuID).Pluck("id", &categoryIDs).Error; err != nil {
+			return fmt.Errorf("failed to get menu categories: %w", err)
+		}
+		if len(categoryIDs) > 0 {
+			if err := tx.Model(&MenuItem{}).Where("category_id IN ?", categoryIDs).Pluck("id", &itemIDs).Error; err != nil {
+				return fmt.Errorf("failed to get menu items: %w", err)
+			}
+		}
+		if err := deleteMenuItems(tx, itemIDs); err != nil {
+			return err
+		}
+		if err := tx.Where("menu_id = ?", menuID).Delete(&MenuCategory{}).Error; err != nil {
+			return fmt.Errorf("failed to delete menu categories: %w", err)
+		}
+		if err := tx.Where("menu_id = ?", menuID).Delete(&MenuSchedule{}).Error; err != nil {
+			return fmt.Errorf("failed to delete menu schedules: %w", err)
+		}
+		if err := tx.Delete(&menu).Error; err != nil {
+			return fmt.Errorf("failed to delete menu: %w", err)
+		}
+		return nil
+	})
+}
+
+// AddMenuCategory adds a new category at the end of one of the business's menus. A zero
+// menuID adds it to the main menu, creating that menu if the business has none.
+func AddMenuCategory(businessID, menuID uint, category *MenuCategory) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var menu Menu
+		var err error
+		if menuID != 0 {
+			err = tx.Where("id = ? AND business_id = ?", menuID, businessID).First(&menu).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMenuNotFound
+			}
+		} else {
+			err = tx.Where("business_id = ? AND is_active = ?", businessID, true).Order("sort_order, id").First(&menu).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				menu = Menu{BusinessID: businessID, IsActive: true}
+				err = tx.Omit(clause.Associations).Create(&menu).Error
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get menu: %w", err)
+		}
+
+		var count int64
+		if err := tx.Model(&MenuCategory{}).Where("menu_id = ?", menu.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count menu categories: %w", err)
+		}
+
+		category.ID = 0
+		category.MenuID = menu.ID
+		category.BusinessID = businessID
+		category.SortOrder = int(count)
+		items := category.Items
+		if err := tx.Omit(clause.Associations).Create(category).Error; err != nil {
+			return fmt.Errorf("failed to create menu category: %w", err)
+		}
+		return saveCategoryItems(tx, category, items)
+	})
+}
+
+// UpdateMenuCategory updates a category's name and description. When items are given,
+// they replace the items of the category.
+func UpdateMenuCategory(businessID, categoryID uint, category *MenuCategory) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		stored, err := getMenuCategory(tx, businessID, categoryID)
+		if err != nil {
+			return err
+		}
+
+		stored.Name = category.Name
+		stored.Description = category.Description
+		if err := tx.Model(stored).Omit(clause.Associations).
+			Select("name", "description", "updated_at").Updates(stored).Error; err != nil {
+			return fmt.Errorf("failed to update menu category: %w", err)
+		}
+
+		items := category.Items
+		*category = *stored
+		if items == nil {
+			return nil
+		}
+		return saveCategoryItems(tx, category, items)
+	})
+}
+
+// DeleteMenuCategory removes a category and its items
+func DeleteMenuCategory(businessID, categoryID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if _, err := getMenuCategory(tx, businessID, categoryID); err != nil {
+			return err
+		}
+
+		var itemIDs []uint
+		if err := tx.Model(&MenuItem{}).Where("category_id = ?", categoryID).Pluck("id", &itemIDs).Error; err != nil {
+			return fmt.Errorf("failed to get menu items: %w", err)
+		}
+		if err := deleteMenuItems(tx, itemIDs); err != nil {
+			return err
+		}
+		if err := tx.Delete(&MenuCategory{}, categoryID).Error; err != nil {
+			return fmt.Errorf("failed to delete menu category: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetMenuItem returns a business's menu item with its options and images
+func GetMenuItem(businessID, itemID uint) (*MenuItem, error) {
+	var item MenuItem
+	err := menuItemQuery(db).Where("id = ? AND business_id = ?", itemID, businessID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMenuItemNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get menu item: %w", err)
+	}
+	fillItemImages(&item)
+	if err := loadModifierGroups(db, []*MenuItem{&item}); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// AddMenuItem adds a new item at the end of a category
+func AddMenuItem(businessID, categoryID uint, item *MenuItem) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if _, err := getMenuCategory(tx, businessID, categoryID); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&MenuItem{}).Where("category_id = ?", categoryID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count menu items: %w", err)
+		}
+
+		item.ID = 0
+		item.SortOrder = int(count)
+		return saveMenuItem(tx, businessID, categoryID, item, nil)
+	})
+}
+
+// UpdateMenuItem updates a menu item, its options and its images. A zero CategoryID
+// leaves the item in its category; another category moves it to the end of that one.
+func UpdateMenuItem(businessID, itemID uint, item *MenuItem) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var stored MenuItem
+		err := tx.Where("id = ? AND business_id = ?", itemID, businessID).First(&stored).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMenuItemNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get menu item: %w", err)
+		}
+
+		item.ID = stored.ID
+		item.SortOrder = stored.SortOrder
+		if item.CategoryID == 0 || item.CategoryID == stored.CategoryID {
+			item.CategoryID = stored.CategoryID
+		} else {
+			if _, err := getMenuCategory(tx, businessID, item.CategoryID); err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&MenuItem{}).Where("category_id = ?", item.CategoryID).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to count menu items: %w", err)
+			}
+			item.SortOrder = int(count)
+		}
+		return saveMenuItem(tx, businessID, item.CategoryID, item, map[uint]bool{stored.ID: true})
+	})
+}
+
+// SetMenuItemSoldOut marks a menu item as sold out until the given time, after which
+// it is back on sale by itself. A nil time puts it back on sale now.
+func SetMenuItemSoldOut(businessID, itemID uint, until *time.Time) (*MenuItem, error) {
+	result := db.Model(&MenuItem{}).Where("id = ? AND business_id = ?", itemID, businessID).
+		Updates(map[string]interface{}{"sold_out_until": until, "updated_at": time.Now()})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update menu item: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrMenuItemNotFound
+	}
+	return GetMenuItem(businessID, itemID)
+}
+
+// DeleteMenuItem removes a menu item with its options and images
+func DeleteMenuItem(businessID, itemID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&MenuItem{}).Where("id = ? AND business_id = ?", itemID, businessID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to get menu item: %w", err)
+		}
+		if count == 0 {
+			return ErrMenuItemNotFound
+		}
+		return deleteMenuItems(tx, []uint{itemID})
+	})
+}
+
+// getMenuCategory returns a category of the business
+func getMenuCategory(tx *gorm.DB, businessID, categoryID uint) (*MenuCategory, error) {
+	var category MenuCategory
+	err := tx.Where("id = ? AND business_id = ?", categoryID, businessID).First(&category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMenuCategoryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get menu category: %w", err)
+	}
+	return &category, nil
+}
+
+// menuItemQuery preloads the options and images of menu items in display order
+func menuItemQuery(tx *gorm.DB) *gorm.DB {
+	return tx.
+		Preload("Options", func(tx *gorm.DB) *gorm.DB { return tx.Order("sort_order, id") }).
+		Preload("ImageRecords", func(tx *gorm.DB) *gorm.DB { return tx.Order("sort_order, id") }).
+		Preload("Schedules", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") })
+}
+
+// loadMenu fills in the schedules and categories of a menu
+func loadMenu(tx *gorm.DB, menu *Menu) error {
+	menu.Schedules = []MenuSchedule{}
+	if err := tx.Where("menu_id = ?", menu.ID).Order("id").Find(&menu.Schedules).Error; err != nil {
+		return fmt.Errorf("failed to get menu schedules: %w", err)
+	}
+	categories, err := loadMenuCategories(tx, menu.ID)
+	if err != nil {
+		return err
+	}
+	menu.Categories = categories
+	return nil
+}
+
+// loadMenuCategories returns the categories of a menu with their items, in display order
+func loadMenuCategories(tx *gorm.DB, menuID uint) ([]MenuCategory, error) {
+	categories := []MenuCategory{}
+	err := tx.Where("menu_id = ?", menuID).Order("sort_order, id").
+		Preload("Items", func(tx *gorm.DB) *gorm.DB { return tx.Order("sort_order, id") }).
+		Preload("Items.Options", func(tx *gorm.DB) *gorm.DB { return tx.Order("sort_order, id") }).
+		Preload("Items.ImageRecords", func(tx *gorm.DB) *gorm.DB { return tx.Order("sort_order, id") }).
+		Preload("Items.Schedules", func(tx *gorm.DB) *gorm.DB { return tx.Order("id") }).
+		Find(&categories).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get menu categories: %w", err)
+	}
+
+	var items []*MenuItem
+	for i := range categories {
+		if categories[i].Items == nil {
+			categories[i].Items = []MenuItem{}
+		}
+		for j := range categories[i].Items {
+			fillItemImages(&categories[i].Items[j])
+			items = append(items, &categories[i].Items[j])
+		}
+	}
+	if err := loadModifierGroups(tx, items); err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+// fillItemImages exposes an item's image records as the list of their URLs
+func fillItemImages(item *MenuItem) {
+	item.Images = make([]string, len(item.ImageRecords))
+	for i, image := range item.ImageRecords {
+		item.Images[i] = image.URL
+	}
+	if item.Options == nil {
+		item.Options = []MenuItemOption{}
+	}
+	if item.Schedules == nil {
+		item.Schedules = []MenuItemSchedule{}
+	}
+}
+
+// saveMenuCategories makes categories the full content of a menu, in the given order
+func saveMenuCategories(tx *gorm.DB, menu *Menu, categories []MenuCategory) error {
+	var existing []MenuCategory
+	if err := tx.Where("menu_id = ?", menu.ID).Find(&existing).Error; err != nil {
+		return fmt.Errorf("failed to get menu categories: %w", err)
+	}
+	ownedCategories := make(map[uint]bool, len(existing))
+	categoryIDs := make([]uint, len(existing))
+	for i, category := range existing {
+		ownedCategories[category.ID] = true
+		categoryIDs[i] = category.ID
+	}
+
+	// Items may move between categories of the menu, so any item of the menu can be kept
+	var itemIDs []uint
+	if len(categoryIDs) > 0 {
+		if err := tx.Model(&MenuItem{}).Where("category_id IN ?", categoryIDs).Pluck("id", &itemIDs).Error; err != nil {
+			return fmt.Errorf("failed to get menu items: %w", err)
+		}
+	}
+	ownedItems := make(map[uint]bool, len(itemIDs))
+	for _, id := range itemIDs {
+		ownedItems[id] = true
+	}
+
+	keptCategories := make(map[uint]bool, len(categories))
+	keptItems := make(map[uint]bool)
+	for i := range categories {
+		category := &categories[i]
+		category.MenuID = menu.ID
+		category.BusinessID = menu.BusinessID
+		category.SortOrder = i
+		if ownedCategories[category.ID] && !keptCategories[category.ID] {
+			if err := tx.Model(category).Omit(clause.Associations).
+				Select("name", "description", "sort_order", "updated_at").Updates(category).Error; err != nil {
+				return fmt.Errorf("failed to update menu category: %w", err)
+			}
+		} else {
+			category.ID = 0
+			if err := tx.Omit(clause.Associations).Create(category).Error; err != nil {
+				return fmt.Errorf("failed to create menu category: %w", err)
+			}
+		}
+		keptCategories[category.ID] = true
+
+		for j := range category.Items {
+			item := &category.Items[j]
+			item.SortOrder = j
+			if keptItems[item.ID] {
+				item.ID = 0 // the same item sent twice becomes a copy
+			}
+			if err := saveMenuItem(tx, menu.BusinessID, category.ID, item, ownedItems); err != nil {
+				return err
+			}
+			keptItems[item.ID] = true
+		}
+		if category.Items == nil {
+			category.Items = []MenuItem{}
+		}
+	}
+
+	var removedItems []uint
+	for _, id := range itemIDs {
+		if !keptItems[id] {
+			removedItems = append(removedItems, id)
+		}
+	}
+	if err := deleteMenuItems(tx, removedItems); err != nil {
+		return err
+	}
+
+	var removedCategories []uint
+	for _, id := range categoryIDs {
+		if !keptCategories[id] {
+			removedCategories = append(removedCategories, id)
+		}
+	}
+	if len(removedCategories) > 0 {
+		if err := tx.Delete(&MenuCategory{}, removedCategories).Error; err != nil {
+			return fmt.Errorf("failed to delete menu categories: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveCategoryItems makes items the full content of a category, in the given order
+func saveCategoryItems(tx *gorm.DB, category *MenuCategory, items []MenuItem) error {
+	var itemIDs []uint
+	if err := tx.Model(&MenuItem{}).Where("category_id = ?", category.ID).Pluck("id", &itemIDs).Error; err != nil {
+		return fmt.Errorf("failed to get menu items: %w", err)
+	}
+	owned := make(map[uint]bool, len(itemIDs))
+	for _, id := range itemIDs {
+		owned[id] = true
+	}
+
+	kept := make(map[uint]bool, len(items))
+	for i := range items {
+		item := &items[i]
+		item.SortOrder = i
+		if kept[item.ID] {
+			item.ID = 0
+		}
+		if err := saveMenuItem(tx, category.BusinessID, category.ID, item, owned); err != nil {
+			return err
+		}
+		kept[item.ID] = true
+	}
+
+	var removed []uint
+	for _, id := range itemIDs {
+		if !kept[id] {
+			removed = append(removed, id)
+		}
+	}
+	if err := deleteMenuItems(tx, removed); err != nil {
+		return err
+	}
+
+	if items == nil {
+		items = []MenuItem{}
+	}
+	category.Items = items
+	return nil
+}
+
+// saveMenuItem updates an item whose ID is in owned, or creates it otherwise, and
+// replaces its options, modifier groups, schedules and images
+func saveMenuItem(tx *gorm.DB, businessID, categoryID uint, item *MenuItem, owned map[uint]bool) error {
+	item.BusinessID = businessID
+	item.CategoryID = categoryID
+	if item.Allergens == nil {
+		item.Allergens = []string{}
+	}
+	if item.DietaryTags == nil {
+		item.DietaryTags = []string{}
+	}
+
+	if owned[item.ID] {
+		item.UpdatedAt = time.Now()
+		if err := tx.Model(item).Omit(clause.Associations).Select(menuItemColumns).Updates(item).Error; err != nil {
+			return fmt.Errorf("failed to update menu item: %w", err)
+		}
+	} else {
+		item.ID = 0
+		if err := tx.Omit(clause.Associations).Create(item).Error; err != nil {
+			return fmt.Errorf("failed to create menu item: %w", err)
+		}
+	}
+
+	if err := saveItemOptions(tx, item); err != nil {
+		return err
+	}
+	if err := saveModifierGroups(tx, item); err != nil {
+		return err
+	}
+	if err := saveItemSchedules(tx, item); err != nil {
+		return err
+	}
+	return saveItemImages(tx, item)
+}
+
+// saveItemOptions makes the item's options its full list of options. Options sent with
+// their ID keep it, so carts referring to them stay valid.
+func saveItemOptions(tx *gorm.DB, item *MenuItem) error {
+	var optionIDs []uint
+	if err := tx.Model(&MenuItemOption{}).Where("menu_item_id = ?", item.ID).Pluck("id", &optionIDs).Error; err != nil {
+		return fmt.Errorf("failed to get menu item options: %w", err)
+	}
+	owned := make(map[uint]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		owned[id] = true
+	}
+
+	kept := make(map[uint]bool, len(item.Options))
+	for i := range item.Options {
+		option := &item.Options[i]
+		option.MenuItemID = item.ID
+		option.SortOrder = i
+		if owned[option.ID] && !kept[option.ID] {
+			if err := tx.Model(option).Select("name", "price_change", "is_required", "sort_order").
+				Updates(option).Error; err != nil {
+				return fmt.Errorf("failed to update menu item option: %w", err)
+			}
+		} else {
+			option.ID = 0
+			if err := tx.Create(option).Error; err != nil {
+				return fmt.Errorf("failed to create menu item option: %w", err)
+			}
+		}
+		kept[option.ID] = true
+	}
+	if item.Options == nil {
+		item.Options = []MenuItemOption{}
+	}
+
+	var removed []uint
+	for _, id := range optionIDs {
+		if !kept[id] {
+			removed = append(removed, id)
+		}
+	}
+	if len(removed) > 0 {
+		if err := tx.Delete(&MenuItemOption{}, removed).Error; err != nil {
+			return fmt.Errorf("failed to delete menu item options: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveItemImages replaces the image records of an item with its list of image URLs
+func saveItemImages(tx *gorm.DB, item *MenuItem) error {
+	if err := tx.Where("menu_item_id = ?", item.ID).Delete(&MenuItemImage{}).Error; err != nil {
+		return fmt.Errorf("failed to delete menu item images: %w", err)
+	}
+
+	item.ImageRecords = nil
+	for i, url := range item.Images {
+		if url == "" {
+			continue
+		}
+		item.ImageRecords = append(item.ImageRecords, MenuItemImage{MenuItemID: item.ID, URL: url, SortOrder: i})
+	}
+	if len(item.ImageRecords) > 0 {
+		if err := tx.Create(&item.ImageRecords).Error; err != nil {
+			return fmt.Errorf("failed to create menu item images: %w", err)
+		}
+	}
+	fillItemImages(item)
+	return nil
+}
+
+// deleteMenuItems removes menu items with their options, schedules and images
+func deleteMenuItems(tx *gorm.DB, itemIDs []uint) error {
+	if len(itemIDs) == 0 {
+		return nil
+	}
+	if err := tx.Where("menu_item_id IN ?", itemIDs).Delete(&MenuItemOption{}).Error; err != nil {
+		return fmt.Errorf("failed to delete menu item options: %w", err)
+	}
+	if err := tx.Where("menu_item_id IN ?", itemIDs).Delete(&MenuItemImage{}).Error; err != nil {
+		return fmt.Errorf("failed to delete menu item images: %w", err)
+	}
+	if err := tx.Where("menu_item_id IN ?", itemIDs).Delete(&MenuItemSchedule{}).Error; err != nil {
+		return fmt.Errorf("failed to delete menu item schedules: %w", err)
+	}
+	if err := tx.Where("menu_item_id IN ?", itemIDs).Delete(&RecipeComponent{}).Error; err != nil {
+		return fmt.Errorf("failed to delete menu item recipes: %w", err)
+	}
+	if err := tx.Model(&StockItem{}).Where("menu_item_id IN ?", itemIDs).Update("menu_item_id", nil).Error; err != nil {
+		return fmt.Errorf("failed to detach stock items: %w", err)
+	}
+	if err := deleteModifierGroups(tx, itemIDs); err != nil {
+		return err
+	}
+	if err := tx.Delete(&MenuItem{}, itemIDs).Error; err != nil {
+		return fmt.Errorf("failed to delete menu items: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"payverge/internal/money"
+)
+
+// menuCSVHeader are the columns of the flat CSV menu format, one row per item. Allergens,
+// dietary tags and options are lists separated by ";", and an option is written as
+// "name:price_change", with ":required" appended to required options.
+var menuCSVHeader = []string{"category", "item", "description", "price", "available", "allergens", "dietary_tags", "options"}
+
+// WriteMenuCSV writes menu categories in the flat CSV format. Categories without items
+// are left out, as a row is an item.
+func WriteMenuCSV(w io.Writer, categories []MenuCategory) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(menuCSVHeader); err != nil {
+		return fmt.Errorf("failed to write menu CSV: %w", err)
+	}
+	for _, category := range categories {
+		for _, item := range category.Items {
+			options := make([]string, len(item.Options))
+			for i, option := range item.Options {
+				options[i] = option.Name + ":" + option.PriceChange.String()
+				if option.IsRequired {
+					options[i] += ":required"
+				}
+			}
+			row := []string{
+				category.Name,
+				item.Name,
+				item.Description,
+				item.Price.String(),
+				strconv.FormatBool(item.IsAvailable),
+				strings.Join(item.Allergens, ";"),
+				strings.Join(item.DietaryTags, ";"),
+				strings.Join(options, ";"),
+			}
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("failed to write menu CSV: %w", err)
+			}
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write menu CSV: %w", err)
+	}
+	return nil
+}
+
+// ReadMenuCSV reads menu categories from the flat CSV format. Columns are found by their
+// header, in any order; only category, item and price are required. Categories are
+// kept in the order they first appear.
+func ReadMenuCSV(r io.Reader) ([]MenuCategory, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: the CSV file is empty", ErrInvalidMenuImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMenuImport, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[nameKey(strings.TrimPrefix(name, "\ufeff"))] = i
+	}
+	for _, required := range []string{"category", "item", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: the CSV file has no %s column", ErrInvalidMenuImport, required)
+		}
+	}
+
+	var categories []MenuCategory
+	index := make(map[string]int)
+	for line := 2; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMenuImport, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		item, err := menuCSVItem(field)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidMenuImport, line, err)
+		}
+
+		category := field("category")
+		i, ok := index[nameKey(category)]
+		if !ok {
+			i = len(categories)
+			index[nameKey(category)] = i
+			categories = append(categories, MenuCategory{Name: category})
+		}
+		categories[i].Items = append(categories[i].Items, item)
+	}
+	return categories, nil
+}
+
+// menuCSVItem reads the item of a CSV row
+func menuCSVItem(field func(string) string) (MenuItem, error) {
+	item := MenuItem{
+		Name:        field("item"),
+		Description: field("description"),
+		IsAvailable: true,
+		Allergens:   splitMenuList(field("allergens")),
+		DietaryTags: splitMenuList(field("dietary_tags")),
+	}
+	if field("category") == "" {
+		return item, errors.New("missing category")
+	}
+	if item.Name == "" {
+		return item, errors.New("missing item name")
+	}
+
+	price, err := money.Parse(field("price"))
+	if err != nil {
+		return item, fmt.Errorf("price of %s: %v", item.Name, err)
+	}
+	item.Price = price
+
+	if available := field("available"); available != "" {
+		switch strings.ToLower(available) {
+		case "true", "yes", "1":
+		case "false", "no", "0":
+			item.IsAvailable = false
+		default:
+			return item, fmt.Errorf("availability of %s must be true or false", item.Name)
+		}
+	}
+
+	for _, value := range splitMenuList(field("options")) {
+		parts := strings.Split(value, ":")
+		option := MenuItemOption{Name: strings.TrimSpace(parts[0])}
+		if option.Name == "" || len(parts) > 3 {
+			return item, fmt.Errorf("option %q of %s is not name:price_change", value, item.Name)
+		}
+		if len(parts) > 1 {
+			if option.PriceChange, err = money.Parse(parts[1]); err != nil {
+				return item, fmt.Errorf("option %s of %s: %v", option.Name, item.Name, err)
+			}
+		}
+		if len(parts) > 2 {
+			if nameKey(parts[2]) != "required" {
+				return item, fmt.Errorf("option %q of %s is not name:price_change:required", value, item.Name)
+			}
+			option.IsRequired = true
+		}
+		item.Options = append(item.Options, option)
+	}
+	return item, nil
+}
+
+// splitMenuList splits a ";" separated list, dropping blank entries
+func splitMenuList(value string) []string {
+	values := []string{}
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			values = append(values, entry)
+		}
+	}
+	return values
+}
@@ -0,0 +1,512 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidMenuImport is returned when an imported menu cannot be read or applied
+var ErrInvalidMenuImport = errors.New("invalid menu import")
+
+// Actions of the changes an import makes
+const (
+	MenuImportAdd    = "add"
+	MenuImportUpdate = "update"
+	MenuImportRemove = "remove"
+)
+
+// MenuExport is a whole menu in the full-fidelity JSON format. Imports match categories,
+// items and options by name, so the IDs it carries are not needed to import it.
+type MenuExport struct {
+	Name       string         `json:"name"`
+	Schedules  []MenuSchedule `json:"schedules"`
+	Categories []MenuCategory `json:"categories"`
+}
+
+// MenuImportChange is one difference between an imported menu and the current one
+type MenuImportChange struct {
+	Action   string   `json:"action"`
+	Category string   `json:"category"`
+	Item     string   `json:"item,omitempty"`   // Empty when the category itself changes
+	Fields   []string `json:"fields,omitempty"` // What an update changes
+}
+
+// MenuImportResult describes an import, applied or not
+type MenuImportResult struct {
+	DryRun  bool               `json:"dry_run"`
+	MenuID  uint               `json:"menu_id"` // Zero when a dry run would create the menu
+	Changes []MenuImportChange `json:"changes"`
+	Menu    *Menu              `json:"menu,omitempty"` // The menu after an applied import
+
+	// NewText holds the categories and items of an applied import whose text is new or
+	// changed, with their IDs and only that text set, so only it gets translated
+	NewText []MenuCategory `json:"-"`
+}
+
+// ExportMenu returns a menu of the business, or its main menu when menuID is 0, in the
+// full-fidelity format
+func ExportMenu(businessID, menuID uint) (*MenuExport, error) {
+	var menu *Menu
+	var err error
+	if menuID == 0 {
+		menu, _, err = GetMenuByBusinessID(businessID)
+	} else {
+		menu, err = GetMenu(businessID, menuID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &MenuExport{Name: menu.Name, Schedules: menu.Schedules, Categories: menu.Categories}, nil
+}
+
+// ImportMenu makes an imported menu the content of a menu of the business, or of its
+// main menu when menuID is 0, and lists what changes. Categories and items are matched
+// to the current ones by name, so they keep their IDs, translations and stock recipes;
+// current ones left out are removed. A partial import, such as a CSV file, only carries
+// names, prices, availability, allergens, dietary tags and options: matched items keep
+// their images, schedules and modifier groups, and matched categories their description.
+// A dry run only lists the changes.
+func ImportMenu(businessID, menuID uint, imported *MenuExport, partial, dryRun bool) (*MenuImportResult, error) {
+	if err := validateImport(imported); err != nil {
+		return nil, err
+	}
+
+	var menu *Menu
+	var err error
+	if menuID == 0 {
+		menu, _, err = GetMenuByBusinessID(businessID)
+		if errors.Is(err, ErrMenuNotFound) {
+			menu, err = &Menu{BusinessID: businessID, IsActive: true, Schedules: []MenuSchedule{}}, nil
+		}
+	} else {
+		menu, err = GetMenu(businessID, menuID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	merged, previous, changes := mergeImportedMenu(menu.Categories, imported.Categories, partial)
+	result := &MenuImportResult{DryRun: dryRun, MenuID: menu.ID, Changes: changes}
+	if dryRun {
+		return result, nil
+	}
+
+	if imported.Name != "" {
+		menu.Name = imported.Name
+	}
+	if imported.Schedules != nil {
+		menu.Schedules = imported.Schedules
+	}
+	if menu.ID == 0 {
+		err = CreateMenu(menu, merged)
+	} else {
+		err = UpdateMenu(menu, merged)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result.MenuID = menu.ID
+	result.Menu = menu
+	result.NewText = newMenuText(menu.Categories, previous)
+	return result, nil
+}
+
+// validateImport checks what a dry run could not otherwise notice before saving
+func validateImport(imported *MenuExport) error {
+	for _, schedule := range imported.Schedules {
+		if err := schedule.validate(); err != nil {
+			return err
+		}
+	}
+	seen := make(map[string]bool, len(imported.Categories))
+	for _, category := range imported.Categories {
+		key := nameKey(category.Name)
+		if key == "" {
+			return fmt.Errorf("%w: a category needs a name", ErrInvalidMenuImport)
+		}
+		if seen[key] {
+			return fmt.Errorf("%w: category %s is listed twice", ErrInvalidMenuImport, category.Name)
+		}
+		seen[key] = true
+
+		for _, item := range category.Items {
+			if nameKey(item.Name) == "" {
+				return fmt.Errorf("%w: an item of %s needs a name", ErrInvalidMenuImport, category.Name)
+			}
+			if item.Price < 0 {
+				return fmt.Errorf("%w: %s has a negative price", ErrInvalidMenuImport, item.Name)
+			}
+			for _, schedule := range item.Schedules {
+				if err := schedule.validate(); err != nil {
+					return err
+				}
+			}
+			if err := validateModifierGroups(item.Modifiers); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nameKey is how names are compared when matching imported content
+func nameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// previousContent is the current category and items an imported category was matched
+// to; nil entries were added by the import
+type previousContent struct {
+	category *MenuCategory
+	items    []*MenuItem
+}
+
+// mergeImportedMenu matches imported categories and items to the current ones by name,
+// giving them the current IDs, and lists the differences. Items are matched across the
+// whole menu, so an item can move to another category.
+func mergeImportedMenu(current, imported []MenuCategory, partial bool) ([]MenuCategory, []previousContent, []MenuImportChange) {
+	categoriesByName := make(map[string]*MenuCategory, len(current))
+	itemsByName := make(map[string][]*MenuItem)
+	itemCategory := make(map[uint]string)
+	for i := range current {
+		category := &current[i]
+		if _, ok := categoriesByName[nameKey(category.Name)]; !ok {
+			categoriesByName[nameKey(category.Name)] = category
+		}
+		for j := range category.Items {
+			item := &category.Items[j]
+			itemsByName[nameKey(item.Name)] = append(itemsByName[nameKey(item.Name)], item)
+			itemCategory[item.ID] = category.Name
+		}
+	}
+
+	changes := []MenuImportChange{}
+	merged := make([]MenuCategory, len(imported))
+	previous := make([]previousContent, len(imported))
+	matchedCategories := make(map[uint]bool)
+	matchedItems := make(map[uint]bool)
+	for i, category := range imported {
+		old := categoriesByName[nameKey(category.Name)]
+		category.ID = 0
+		if old != nil {
+			category.ID = old.ID
+			if partial {
+				category.Description = old.Description
+			}
+			matchedCategories[old.ID] = true
+			var fields []string
+			if category.Name != old.Name {
+				fields = append(fields, "name")
+			}
+			if category.Description != old.Description {
+				fields = append(fields, "description")
+			}
+			if len(fields) > 0 {
+				changes = append(changes, MenuImportChange{Action: MenuImportUpdate, Category: category.Name, Fields: fields})
+			}
+		} else {
+			changes = append(changes, MenuImportChange{Action: MenuImportAdd, Category: category.Name})
+		}
+		previous[i].category = old
+
+		items := make([]MenuItem, len(category.Items))
+		previous[i].items = make([]*MenuItem, len(category.Items))
+		for j, item := range category.Items {
+			var oldItem *MenuItem
+			for _, candidate := range itemsByName[nameKey(item.Name)] {
+				if !matchedItems[candidate.ID] {
+					oldItem = candidate
+					break
+				}
+			}
+
+			item = importedItem(item, oldItem, partial)
+			if oldItem != nil {
+				matchedItems[oldItem.ID] = true
+				fields := menuItemChanges(item, *oldItem)
+				if itemCategory[oldItem.ID] != "" && nameKey(itemCategory[oldItem.ID]) != nameKey(category.Name) {
+					fields = append([]string{"category"}, fields...)
+				}
+				if len(fields) > 0 {
+					changes = append(changes, MenuImportChange{Action: MenuImportUpdate, Category: category.Name, Item: item.Name, Fields: fields})
+				}
+			} else {
+				changes = append(changes, MenuImportChange{Action: MenuImportAdd, Category: category.Name, Item: item.Name})
+			}
+			items[j] = item
+			previous[i].items[j] = oldItem
+		}
+		category.Items = items
+		merged[i] = category
+	}
+
+	for _, category := range current {
+		for _, item := range category.Items {
+			if !matchedItems[item.ID] {
+				changes = append(changes, MenuImportChange{Action: MenuImportRemove, Category: category.Name, Item: item.Name})
+			}
+		}
+		if !matchedCategories[category.ID] {
+			changes = append(changes, MenuImportChange{Action: MenuImportRemove, Category: category.Name})
+		}
+	}
+	return merged, previous, changes
+}
+
+// importedItem prepares an imported item to be saved over the item it was matched to,
+// if any. Sold-out state is not menu content, so it is never imported.
+func importedItem(item MenuItem, old *MenuItem, partial bool) MenuItem {
+	item.ID = 0
+	item.SoldOutUntil = nil
+	if old == nil {
+		return item
+	}
+
+	item.ID = old.ID
+	item.SoldOutUntil = old.SoldOutUntil
+	if partial {
+		item.Description = old.Description
+		item.Currency = old.Currency
+		item.Image = old.Image
+		item.Images = old.Images
+		item.Schedules = old.Schedules
+		item.Modifiers = old.Modifiers
+	} else {
+		item.Modifiers = matchModifierGroups(item.Modifiers, old.Modifiers)
+	}
+
+	options := make([]MenuItemOption, len(item.Options))
+	used := make(map[uint]bool)
+	for i, option := range item.Options {
+		option.ID = 0
+		for _, oldOption := range old.Options {
+			if !used[oldOption.ID] && nameKey(oldOption.Name) == nameKey(option.Name) {
+				option.ID = oldOption.ID
+				used[oldOption.ID] = true
+				break
+			}
+		}
+		options[i] = option
+	}
+	item.Options = options
+	return item
+}
+
+// matchModifierGroups gives imported modifier groups and options the IDs of the current
+// ones with the same names, so carts referring to them stay valid
+func matchModifierGroups(groups, old []ModifierGroup) []ModifierGroup {
+	matched := make([]ModifierGroup, len(groups))
+	used := make(map[uint]bool)
+	for i, group := range groups {
+		var oldGroup *ModifierGroup
+		for j := range old {
+			if !used[old[j].ID] && nameKey(old[j].Name) == nameKey(group.Name) {
+				oldGroup = &old[j]
+				used[oldGroup.ID] = true
+				break
+			}
+		}
+
+		group.ID = 0
+		var oldOptions []ModifierOption
+		if oldGroup != nil {
+			group.ID = oldGroup.ID
+			oldOptions = oldGroup.Options
+		}
+		options := make([]ModifierOption, len(group.Options))
+		usedOptions := make(map[uint]bool)
+		for k, option := range group.Options {
+			option.ID = 0
+			var nested []ModifierGroup
+			for _, oldOption := range oldOptions {
+				if !usedOptions[oldOption.ID] && nameKey(oldOption.Name) == nameKey(option.Name) {
+					option.ID = oldOption.ID
+					nested = oldOption.Groups
+					usedOptions[oldOption.ID] = true
+					break
+				}
+			}
+			option.Groups = matchModifierGroups(option.Groups, nested)
+			options[k] = option
+		}
+		group.Options = options
+		matched[i] = group
+	}
+	return matched
+}
+
+// menuItemChanges lists the fields of an imported item that differ from the current item
+func menuItemChanges(item, old MenuItem) []string {
+	var fields []string
+	if item.Name != old.Name {
+		fields = append(fields, "name")
+	}
+	if item.Description != old.Description {
+		fields = append(fields, "description")
+	}
+	if item.Price != old.Price {
+		fields = append(fields, "price")
+	}
+	if item.Currency != old.Currency {
+		fields = append(fields, "currency")
+	}
+	if item.IsAvailable != old.IsAvailable {
+		fields = append(fields, "is_available")
+	}
+	if !sameStrings(item.Allergens, old.Allergens) {
+		fields = append(fields, "allergens")
+	}
+	if !sameStrings(item.DietaryTags, old.DietaryTags) {
+		fields = append(fields, "dietary_tags")
+	}
+	if !sameStrings(item.Images, old.Images) || item.Image != old.Image {
+		fields = append(fields, "images")
+	}
+	if !sameContent(optionContent(item.Options), optionContent(old.Options)) {
+		fields = append(fields, "options")
+	}
+	if !sameContent(modifierContent(item.Modifiers), modifierContent(old.Modifiers)) {
+		fields = append(fields, "modifier_groups")
+	}
+	if !sameContent(scheduleContent(item.Schedules), scheduleContent(old.Schedules)) {
+		fields = append(fields, "schedules")
+	}
+	return fields
+}
+
+// sameStrings compares string lists, treating nil and empty as the same
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameContent compares the ID-free content of menu parts
+func sameContent(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// optionContent is what an import can change about options
+func optionContent(options []MenuItemOption) []MenuItemOption {
+	content := make([]MenuItemOption, len(options))
+	for i, option := range options {
+		content[i] = MenuItemOption{Name: option.Name, PriceChange: option.PriceChange, IsRequired: option.IsRequired}
+	}
+	return content
+}
+
+// modifierContent is what an import can change about modifier groups
+func modifierContent(groups []ModifierGroup) string {
+	var strip func(groups []ModifierGroup) []ModifierGroup
+	strip = func(groups []ModifierGroup) []ModifierGroup {
+		content := make([]ModifierGroup, len(groups))
+		for i, group := range groups {
+			options := make([]ModifierOption, len(group.Options))
+			for k, option := range group.Options {
+				options[k] = ModifierOption{
+					Name:        option.Name,
+					PriceChange: option.PriceChange,
+					IsDefault:   option.IsDefault,
+					IsAvailable: option.IsAvailable,
+					Groups:      strip(option.Groups),
+				}
+			}
+			content[i] = ModifierGroup{Name: group.Name, MinSelect: group.MinSelect, MaxSelect: group.MaxSelect, Options: options}
+		}
+		return content
+	}
+	encoded, _ := json.Marshal(strip(groups))
+	return string(encoded)
+}
+
+// scheduleContent is what an import can change about schedules
+func scheduleContent(schedules []MenuItemSchedule) []ScheduleWindow {
+	content := make([]ScheduleWindow, len(schedules))
+	for i, schedule := range schedules {
+		content[i] = schedule.ScheduleWindow
+	}
+	return content
+}
+
+// newMenuText picks the text of saved categories and items that is new or differs from
+// the content they replaced. Allergens and dietary tags keep their positions, blank
+// where unchanged, since their translations are stored by position.
+func newMenuText(categories []MenuCategory, previous []previousContent) []MenuCategory {
+	var text []MenuCategory
+	for i, category := range categories {
+		old := previous[i].category
+		delta := MenuCategory{ID: category.ID}
+		if old == nil || old.Name != category.Name {
+			delta.Name = category.Name
+		}
+		if old == nil || old.Description != category.Description {
+			delta.Description = category.Description
+		}
+
+		for j, item := range category.Items {
+			oldItem := previous[i].items[j]
+			if oldItem == nil {
+				delta.Items = append(delta.Items, item)
+				continue
+			}
+
+			itemDelta := MenuItem{ID: item.ID}
+			changed := false
+			if item.Name != oldItem.Name {
+				itemDelta.Name, changed = item.Name, true
+			}
+			if item.Description != oldItem.Description {
+				itemDelta.Description, changed = item.Description, true
+			}
+			oldOptions := make(map[string]bool, len(oldItem.Options))
+			for _, option := range oldItem.Options {
+				oldOptions[option.Name] = true
+			}
+			for _, option := range item.Options {
+				if !oldOptions[option.Name] {
+					itemDelta.Options, changed = append(itemDelta.Options, option), true
+				}
+			}
+			var ok bool
+			if itemDelta.Allergens, ok = changedStrings(item.Allergens, oldItem.Allergens); ok {
+				changed = true
+			}
+			if itemDelta.DietaryTags, ok = changedStrings(item.DietaryTags, oldItem.DietaryTags); ok {
+				changed = true
+			}
+			if changed {
+				delta.Items = append(delta.Items, itemDelta)
+			}
+		}
+
+		if delta.Name != "" || delta.Description != "" || len(delta.Items) > 0 {
+			text = append(text, delta)
+		}
+	}
+	return text
+}
+
+// changedStrings returns values with the entries equal to the old ones at the same
+// position blanked, and whether any entry is left
+func changedStrings(values, old []string) ([]string, bool) {
+	changed := make([]string, len(values))
+	left := false
+	for i, value := range values {
+		if i < len(old) && old[i] == value {
+			continue
+		}
+		changed[i] = value
+		left = left || value != ""
+	}
+	return changed, left
+}
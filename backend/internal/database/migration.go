@@ -2,8 +2,15 @@ package database
 
 import (
 	"embed"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"payverge/internal/money"
+	"payverge/internal/structs"
 )
 
 //go:embed *.sql
@@ -40,34 +47,6 @@ func (db *DB) OptimizeDatabase() error {
 		return fmt.Errorf("failed to apply indexes: %w", err)
 	}
 
-	// Add all models to auto-migration
-	if err := db.conn.AutoMigrate(
-		&User{},
-		&Code{},
-		&Coupon{}, // New coupon model
-		&ErrorLog{},
-		&FaucetTransaction{},
-		&Subscriber{},
-		&MultisigTx{},
-		&Business{},
-		&Menu{},
-		&Table{},
-		&Bill{},
-		&Payment{},
-		&AlternativePayment{},
-		&Counter{},
-		&Staff{},
-		&StaffInvitation{},
-		&StaffLoginCode{},
-		&Referrer{},
-		&ReferralRecord{},
-		&ReferralCommissionClaim{},
-		&WithdrawalHistory{},
-		&SubscriptionPayment{},
-	); err != nil {
-		return fmt.Errorf("failed to auto-migrate database: %w", err)
-	}
-
 	// Analyze tables for query optimization
 	tables := []string{"bills", "payments", "alternative_payment", "businesses", "tables", "subscription_payments"}
 	for _, table := range tables {
@@ -78,3 +57,329 @@ func (db *DB) OptimizeDatabase() error {
 
 	return nil
 }
+
+// dropLegacyIndexes removes indexes that were replaced by newer constraints.
+// AutoMigrate only adds indexes, so databases it created can still have superseded ones.
+func dropLegacyIndexes(conn *gorm.DB) error {
+	// Payments were unique per transaction hash; they are now unique per (tx_hash, log_index)
+	if conn.Migrator().HasIndex(&Payment{}, "idx_payments_tx_hash") {
+		if err := conn.Migrator().DropIndex(&Payment{}, "idx_payments_tx_hash"); err != nil {
+			return fmt.Errorf("failed to drop idx_payments_tx_hash: %w", err)
+		}
+	}
+	return nil
+}
+
+// dataMigration is a one-off change to existing rows, applied at most once
+type dataMigration struct {
+	name  string
+	apply func(tx *gorm.DB) error
+}
+
+// dataMigrations lists the data migrations in the order they must run
+var dataMigrations = []dataMigration{
+	{name: "money_minor_units", apply: migrateMoneyToMinorUnits},
+	{name: "bill_partially_paid_status", apply: migratePartiallyPaidBills},
+	{name: "menu_tables", apply: migrateMenuBlobs},
+	{name: "counter_codes", apply: migrateCounterCodes},
+	{name: "table_cleaned_at", apply: migrateTableCleanedAt},
+	{name: "default_notification_preferences", apply: migrateNotificationPreferences},
+	{name: "drop_legacy_kitchen_tables", apply: dropLegacyKitchenTables},
+	{name: "kitchen_route_ids", apply: migrateKitchenRouteIDs},
+}
+
+// ApplyDataMigrations runs every data migration that has not been recorded yet.
+// Each migration and its record are committed in one transaction.
+func ApplyDataMigrations(conn *gorm.DB) error {
+	for _, m := range dataMigrations {
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			var count int64
+			if err := tx.Model(&DataMigration{}).Where("name = ?", m.name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return nil
+			}
+
+			if err := m.apply(tx); err != nil {
+				return err
+			}
+			return tx.Create(&DataMigration{Name: m.name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply data migration %s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+// moneyColumns lists the columns that held decimal dollar amounts before money
+// was stored in minor units
+var moneyColumns = map[string][]string{
+	"bills":                {"subtotal", "tax_amount", "service_fee_amount", "total_amount", "paid_amount", "tip_amount"},
+	"payments":             {"amount", "tip_amount"},
+	"alternative_payments": {"amount", "tip_amount"},
+	"withdrawal_history":   {"payment_amount", "tip_amount", "total_amount"},
+}
+
+// migrateMoneyToMinorUnits converts stored dollar amounts to integer cents. Amounts
+// inside JSON columns (bill items, order items, menus) keep their decimal encoding
+// and need no conversion.
+func migrateMoneyToMinorUnits(tx *gorm.DB) error {
+	for table, columns := range moneyColumns {
+		if !tx.Migrator().HasTable(table) {
+			continue
+		}
+
+		assignments := make([]string, len(columns))
+		for i, column := range columns {
+			assignments[i] = fmt.Sprintf("%s = CAST(ROUND(COALESCE(%s, 0) * 100) AS BIGINT)", column, column)
+		}
+		if err := tx.Exec(fmt.Sprintf("UPDATE %s SET %s", table, strings.Join(assignments, ", "))).Error; err != nil {
+			return fmt.Errorf("failed to convert %s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// migratePartiallyPaidBills moves bills written with the undeclared "partial" status,
+// and open bills that already took a payment, to partially_paid
+func migratePartiallyPaidBills(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&Bill{}) {
+		return nil
+	}
+	if err := tx.Model(&Bill{}).
+		Where("status = ? OR (status = ? AND paid_amount > 0)", "partial", BillStatusOpen).
+		Update("status", BillStatusPartiallyPaid).Error; err != nil {
+		return fmt.Errorf("failed to migrate partially paid bills: %w", err)
+	}
+	return nil
+}
+
+// migrateCounterCodes gives every counter created before counters had codes one, so
+// each counter can have its own QR code
+func migrateCounterCodes(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&Counter{}) {
+		return nil
+	}
+	var counters []Counter
+	if err := tx.Where("counter_code = '' OR counter_code IS NULL").Find(&counters).Error; err != nil {
+		return fmt.Errorf("failed to get counters without codes: %w", err)
+	}
+	for _, counter := range counters {
+		code, err := generateUniqueCode(tx, &Counter{}, "counter_code")
+		if err != nil {
+			return fmt.Errorf("failed to generate code for counter %d: %w", counter.ID, err)
+		}
+		if err := tx.Model(&Counter{}).Where("id = ?", counter.ID).Update("counter_code", code).Error; err != nil {
+			return fmt.Errorf("failed to set code of counter %d: %w", counter.ID, err)
+		}
+	}
+	return nil
+}
+
+// migrateTableCleanedAt marks existing tables clean so bills settled before the status
+// board existed do not show them as waiting to be cleared
+func migrateTableCleanedAt(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&Table{}) {
+		return nil
+	}
+	if err := tx.Model(&Table{}).Where("cleaned_at IS NULL").Update("cleaned_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to mark tables clean: %w", err)
+	}
+	return nil
+}
+
+// migrateNotificationPreferences turns every notification on for users created before
+// they had preferences; choices users made since are kept
+func migrateNotificationPreferences(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&User{}) {
+		return nil
+	}
+	defaults := structs.NewDefaultNotificationPreferences()
+	if err := tx.Model(&User{}).Where("email_enabled IS NULL").Updates(map[string]interface{}{
+		"email_enabled":         defaults.EmailEnabled,
+		"news_enabled":          defaults.NewsEnabled,
+		"updates_enabled":       defaults.UpdatesEnabled,
+		"transactional_enabled": defaults.TransactionalEnabled,
+		"security_enabled":      defaults.SecurityEnabled,
+		"reports_enabled":       defaults.ReportsEnabled,
+		"statistics_enabled":    defaults.StatisticsEnabled,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to set default notification preferences: %w", err)
+	}
+	return nil
+}
+
+// dropLegacyKitchenTables removes the tables of the kitchen module that kitchen
+// stations and tickets replaced
+func dropLegacyKitchenTables(tx *gorm.DB) error {
+	if err := tx.Migrator().DropTable("kitchen_order_items", "kitchen_orders"); err != nil {
+		return fmt.Errorf("failed to drop legacy kitchen tables: %w", err)
+	}
+	return nil
+}
+
+// legacyKitchenRoute is a kitchen route as it was stored when routes named menu items
+// and categories instead of referencing them
+type legacyKitchenRoute struct {
+	ID         uint
+	BusinessID uint
+	StationID  uint
+	Category   string
+	ItemName   string
+	CreatedAt  time.Time
+}
+
+// migrateKitchenRouteIDs points every route by name at the menu items or categories
+// of the business with that name (or, for categories, that ID), then drops the name
+// columns. Routes naming nothing on the menu are removed.
+func migrateKitchenRouteIDs(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&KitchenRoute{}) || !tx.Migrator().HasColumn(&KitchenRoute{}, "item_name") {
+		return nil
+	}
+
+	var legacy []legacyKitchenRoute
+	if err := tx.Table("kitchen_routes").
+		Select("id, business_id, station_id, COALESCE(category, '') AS category, COALESCE(item_name, '') AS item_name, created_at").
+		Where("category_id IS NULL AND menu_item_id IS NULL").
+		Scan(&legacy).Error; err != nil {
+		return fmt.Errorf("failed to get kitchen routes: %w", err)
+	}
+
+	for _, old := range legacy {
+		var routes []KitchenRoute
+		if name := strings.TrimSpace(old.ItemName); name != "" {
+			var ids []uint
+			if err := tx.Model(&MenuItem{}).Where("business_id = ? AND LOWER(name) = ?", old.BusinessID, strings.ToLower(name)).
+				Pluck("id", &ids).Error; err != nil {
+				return fmt.Errorf("failed to match kitchen route %d: %w", old.ID, err)
+			}
+			for _, id := range ids {
+				routes = append(routes, KitchenRoute{BusinessID: old.BusinessID, StationID: old.StationID, MenuItemID: id, CreatedAt: old.CreatedAt})
+			}
+		} else if category := strings.TrimSpace(old.Category); category != "" {
+			query := tx.Model(&MenuCategory{}).Where("business_id = ?", old.BusinessID)
+			if id, err := strconv.ParseUint(category, 10, 32); err == nil {
+				query = query.Where("id = ? OR LOWER(name) = ?", id, strings.ToLower(category))
+			} else {
+				query = query.Where("LOWER(name) = ?", strings.ToLower(category))
+			}
+			var ids []uint
+			if err := query.Pluck("id", &ids).Error; err != nil {
+				return fmt.Errorf("failed to match kitchen route %d: %w", old.ID, err)
+			}
+			for _, id := range ids {
+				routes = append(routes, KitchenRoute{BusinessID: old.BusinessID, StationID: old.StationID, CategoryID: id, CreatedAt: old.CreatedAt})
+			}
+		}
+
+		if len(routes) > 0 {
+			if err := tx.Create(&routes).Error; err != nil {
+				return fmt.Errorf("failed to convert kitchen route %d: %w", old.ID, err)
+			}
+		}
+		if err := tx.Delete(&KitchenRoute{}, old.ID).Error; err != nil {
+			return fmt.Errorf("failed to remove kitchen route %d: %w", old.ID, err)
+		}
+	}
+
+	for _, column := range []string{"category", "item_name"} {
+		if err := tx.Migrator().DropColumn(&KitchenRoute{}, column); err != nil {
+			return fmt.Errorf("failed to drop kitchen_routes.%s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+// legacyMenuCategory is a category as it was stored in the menus.categories JSON column
+type legacyMenuCategory struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Items       []legacyMenuItem `json:"items"`
+}
+
+type legacyMenuItem struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Price       money.Amount `json:"price"`
+	Currency    string       `json:"currency"`
+	Image       string       `json:"image"`
+	Images      []string     `json:"images"`
+	Options     []struct {
+		Name        string       `json:"name"`
+		PriceChange money.Amount `json:"price_change"`
+		IsRequired  bool         `json:"is_required"`
+	} `json:"options"`
+	Allergens   []string `json:"allergens"`
+	DietaryTags []string `json:"dietary_tags"`
+	IsAvailable bool     `json:"is_available"`
+}
+
+// migrateMenuBlobs moves the categories, items and options stored as JSON on each menu
+// into the menu tables. Translations keyed by category and item position are dropped:
+// they were shared by every business and cannot be matched to the new IDs.
+func migrateMenuBlobs(tx *gorm.DB) error {
+	if !tx.Migrator().HasTable(&Menu{}) || !tx.Migrator().HasColumn(&Menu{}, "categories") {
+		return nil
+	}
+
+	var menus []Menu
+	if err := tx.Where("categories IS NOT NULL AND categories <> ''").Find(&menus).Error; err != nil {
+		return fmt.Errorf("failed to get menus: %w", err)
+	}
+
+	for i := range menus {
+		menu := &menus[i]
+		var count int64
+		if err := tx.Model(&MenuCategory{}).Where("menu_id = ?", menu.ID).Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count menu categories: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		var legacy []legacyMenuCategory
+		if err := json.Unmarshal([]byte(menu.LegacyCategories), &legacy); err != nil {
+			return fmt.Errorf("failed to unmarshal categories of menu %d: %w", menu.ID, err)
+		}
+
+		categories := make([]MenuCategory, len(legacy))
+		for j, lc := range legacy {
+			categories[j] = MenuCategory{Name: lc.Name, Description: lc.Description, Items: make([]MenuItem, len(lc.Items))}
+			for k, li := range lc.Items {
+				item := MenuItem{
+					Name:        li.Name,
+					Description: li.Description,
+					Price:       li.Price,
+					Currency:    li.Currency,
+					Image:       li.Image,
+					Images:      li.Images,
+					Options:     make([]MenuItemOption, len(li.Options)),
+					Allergens:   li.Allergens,
+					DietaryTags: li.DietaryTags,
+					IsAvailable: li.IsAvailable,
+				}
+				for o, lo := range li.Options {
+					item.Options[o] = MenuItemOption{Name: lo.Name, PriceChange: lo.PriceChange, IsRequired: lo.IsRequired}
+				}
+				categories[j].Items[k] = item
+			}
+		}
+
+		if err := saveMenuCategories(tx, menu, categories); err != nil {
+			return fmt.Errorf("failed to migrate menu %d: %w", menu.ID, err)
+		}
+		if err := tx.Model(&Menu{}).Where("id = ?", menu.ID).Update("categories", "").Error; err != nil {
+			return fmt.Errorf("failed to clear categories of menu %d: %w", menu.ID, err)
+		}
+	}
+
+	if tx.Migrator().HasTable(&Translation{}) {
+		if err := tx.Where("entity_type IN ?", []string{"category", "menu_item", "menu_item_option", "allergen", "dietary_tag"}).
+			Delete(&Translation{}).Error; err != nil {
+			return fmt.Errorf("failed to delete position-based menu translations: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+-- Baseline schema: every table as it stood when versioned migrations were introduced.
+-- Databases created before then are brought up to this point by adoptLegacySchema
+-- and never run this file.
+--
+-- There are no foreign key constraints: SQLite never enforced them, and rows such as
+-- counter bills with no table rely on that.
+
+CREATE TABLE "users" ("id" bigserial,"address" text NOT NULL,"token_id" text,"role" text DEFAULT 'user',"email_enabled" boolean,"news_enabled" boolean,"updates_enabled" boolean,"transactional_enabled" boolean,"security_enabled" boolean,"reports_enabled" boolean,"statistics_enabled" boolean,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_users_address" ON "users" ("address");
+
+CREATE TABLE "codes" ("id" bigserial,"code" text NOT NULL,"address" text,"amount" text,"used" boolean DEFAULT false,"expiry" text,"claimed_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_codes_code" ON "codes" ("code");
+
+CREATE TABLE "error_logs" ("id" bigserial,"timestamp" timestamptz,"error" text,"component" text,"function" text,"additional_info" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_error_logs_timestamp" ON "error_logs" ("timestamp");
+
+CREATE TABLE "faucet_transactions" ("id" bigserial,"address" text,"amount" text,"tx_hash" text,"timestamp" timestamptz,"ticker" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_faucet_transactions_timestamp" ON "faucet_transactions" ("timestamp");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_faucet_transactions_tx_hash" ON "faucet_transactions" ("tx_hash");
+CREATE INDEX IF NOT EXISTS "idx_faucet_transactions_address" ON "faucet_transactions" ("address");
+
+CREATE TABLE "subscribers" ("id" bigserial,"email" text NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_subscribers_email" ON "subscribers" ("email");
+
+CREATE TABLE "multisig_transactions" ("id" bigserial,"tx_id" bigint NOT NULL,"data" text,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_multisig_transactions_tx_id" ON "multisig_transactions" ("tx_id");
+
+CREATE TABLE "businesses" ("id" bigserial,"owner_address" text NOT NULL,"name" text NOT NULL,"logo" text,"street" text,"city" text,"state" text,"postal_code" text,"country" text,"settlement_addr" text NOT NULL,"tipping_addr" text NOT NULL,"tax_rate" decimal DEFAULT 0,"service_fee_rate" decimal DEFAULT 0,"tax_inclusive" boolean DEFAULT false,"service_inclusive" boolean DEFAULT false,"is_active" boolean DEFAULT true,"description" text,"custom_url" text,"phone" text,"email" text,"website" text,"social_media" text,"banner_images" text,"business_page_enabled" boolean DEFAULT false,"show_reviews" boolean DEFAULT true,"google_reviews_enabled" boolean DEFAULT false,"google_place_id" text,"google_business_name" text,"google_review_link" text,"google_business_url" text,"referred_by_code" text,"counter_enabled" boolean DEFAULT false,"counter_count" bigint DEFAULT 3,"counter_prefix" text DEFAULT 'C',"default_currency" text DEFAULT 'USD',"display_currency" text DEFAULT 'USD',"default_language" text DEFAULT 'en',"timezone" text DEFAULT 'UTC',"subscription_status" text DEFAULT 'active',"last_payment_date" timestamptz,"subscription_end_date" timestamptz,"last_payment_amount" text,"total_paid" text DEFAULT '0',"yearly_fee" text DEFAULT '120000000',"time_remaining" bigint DEFAULT 0,"referral_code_used" text,"coupon_code_used" text,"discount_applied" text,"registration_tx_hash" text,"last_renewal_tx_hash" text,"reminders_sent" bigint DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_businesses_owner_address" ON "businesses" ("owner_address");
+
+CREATE TABLE "menus" ("id" bigserial,"business_id" bigint NOT NULL,"name" text,"is_active" boolean DEFAULT true,"sort_order" bigint,"created_at" timestamptz,"updated_at" timestamptz,"categories" text,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menus_business_id" ON "menus" ("business_id");
+
+CREATE TABLE "menu_categories" ("id" bigserial,"menu_id" bigint NOT NULL,"business_id" bigint NOT NULL,"name" text NOT NULL,"description" text,"sort_order" bigint,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menu_categories_business_id" ON "menu_categories" ("business_id");
+CREATE INDEX IF NOT EXISTS "idx_menu_categories_menu_id" ON "menu_categories" ("menu_id");
+
+CREATE TABLE "menu_items" ("id" bigserial,"business_id" bigint NOT NULL,"category_id" bigint NOT NULL,"name" text NOT NULL,"description" text,"price" bigint NOT NULL,"currency" text,"image" text,"allergens" text,"dietary_tags" text,"is_available" boolean NOT NULL,"sort_order" bigint,"created_at" timestamptz,"updated_at" timestamptz,"sold_out_until" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menu_items_category_id" ON "menu_items" ("category_id");
+CREATE INDEX IF NOT EXISTS "idx_menu_items_business_id" ON "menu_items" ("business_id");
+
+CREATE TABLE "menu_item_options" ("id" bigserial,"menu_item_id" bigint NOT NULL,"name" text NOT NULL,"price_change" bigint NOT NULL DEFAULT 0,"is_required" boolean NOT NULL,"sort_order" bigint,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menu_item_options_menu_item_id" ON "menu_item_options" ("menu_item_id");
+
+CREATE TABLE "menu_item_images" ("id" bigserial,"menu_item_id" bigint NOT NULL,"url" text NOT NULL,"sort_order" bigint,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menu_item_images_menu_item_id" ON "menu_item_images" ("menu_item_id");
+
+CREATE TABLE "menu_modifier_groups" ("id" bigserial,"menu_item_id" bigint NOT NULL,"parent_option_id" bigint,"name" text NOT NULL,"min_select" bigint NOT NULL DEFAULT 0,"max_select" bigint NOT NULL DEFAULT 0,"sort_order" bigint,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menu_modifier_groups_parent_option_id" ON "menu_modifier_groups" ("parent_option_id");
+CREATE INDEX IF NOT EXISTS "idx_menu_modifier_groups_menu_item_id" ON "menu_modifier_groups" ("menu_item_id");
+
+CREATE TABLE "menu_modifier_options" ("id" bigserial,"group_id" bigint NOT NULL,"menu_item_id" bigint NOT NULL,"name" text NOT NULL,"price_change" bigint NOT NULL DEFAULT 0,"is_default" boolean NOT NULL,"is_available" boolean NOT NULL,"sort_order" bigint,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menu_modifier_options_menu_item_id" ON "menu_modifier_options" ("menu_item_id");
+CREATE INDEX IF NOT EXISTS "idx_menu_modifier_options_group_id" ON "menu_modifier_options" ("group_id");
+
+CREATE TABLE "menu_schedules" ("id" bigserial,"menu_id" bigint NOT NULL,"day_of_week" bigint NOT NULL,"start_time" text NOT NULL,"end_time" text NOT NULL,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menu_schedules_menu_id" ON "menu_schedules" ("menu_id");
+
+CREATE TABLE "menu_item_schedules" ("id" bigserial,"menu_item_id" bigint NOT NULL,"day_of_week" bigint NOT NULL,"start_time" text NOT NULL,"end_time" text NOT NULL,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menu_item_schedules_menu_item_id" ON "menu_item_schedules" ("menu_item_id");
+
+CREATE TABLE "tables" ("id" bigserial,"business_id" bigint NOT NULL,"table_code" text NOT NULL,"name" text NOT NULL,"qr_code" text,"is_active" boolean DEFAULT true,"section" text,"position_x" decimal,"position_y" decimal,"seats" bigint,"shape" text DEFAULT 'square',"cleaned_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_tables_section" ON "tables" ("section");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_tables_table_code" ON "tables" ("table_code");
+CREATE INDEX IF NOT EXISTS "idx_tables_business_id" ON "tables" ("business_id");
+
+CREATE TABLE "bills" ("id" bigserial,"business_id" bigint NOT NULL,"table_id" bigint,"counter_id" bigint,"bill_number" text NOT NULL,"notes" text,"items" jsonb,"subtotal" bigint,"tax_amount" bigint,"service_fee_amount" bigint,"total_amount" bigint,"paid_amount" bigint DEFAULT 0,"tip_amount" bigint DEFAULT 0,"status" text DEFAULT 'open',"settlement_addr" text NOT NULL,"tipping_addr" text NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,"closed_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_bills_bill_number" ON "bills" ("bill_number");
+CREATE INDEX IF NOT EXISTS "idx_bills_counter_id" ON "bills" ("counter_id");
+CREATE INDEX IF NOT EXISTS "idx_bills_table_id" ON "bills" ("table_id");
+CREATE INDEX IF NOT EXISTS "idx_bills_business_id" ON "bills" ("business_id");
+
+CREATE TABLE "counters" ("id" bigserial,"business_id" bigint NOT NULL,"counter_number" bigint NOT NULL,"name" text NOT NULL,"counter_code" text,"qr_code" text,"is_active" boolean DEFAULT true,"current_bill_id" bigint,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_counters_counter_code" ON "counters" ("counter_code");
+CREATE INDEX IF NOT EXISTS "idx_counters_business_id" ON "counters" ("business_id");
+
+CREATE TABLE "table_sessions" ("id" bigserial,"business_id" bigint NOT NULL,"table_id" bigint NOT NULL,"bill_id" bigint,"token_hash" text NOT NULL,"device_id" text,"device_name" text,"user_agent" text,"ip_address" text,"expires_at" timestamptz,"ended_at" timestamptz,"end_reason" text,"last_seen_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_table_sessions_bill_id" ON "table_sessions" ("bill_id");
+CREATE INDEX IF NOT EXISTS "idx_table_sessions_table_id" ON "table_sessions" ("table_id");
+CREATE INDEX IF NOT EXISTS "idx_table_sessions_business_id" ON "table_sessions" ("business_id");
+CREATE INDEX IF NOT EXISTS "idx_table_sessions_device_id" ON "table_sessions" ("device_id");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_table_sessions_token_hash" ON "table_sessions" ("token_hash");
+
+CREATE TABLE "auth_sessions" ("id" bigserial,"address" text,"staff_id" bigint,"business_id" bigint,"refresh_token_hash" text NOT NULL,"previous_token_hash" text,"device_name" text,"user_agent" text,"ip_address" text,"expires_at" timestamptz,"revoked_at" timestamptz,"revoke_reason" text,"last_used_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_auth_sessions_refresh_token_hash" ON "auth_sessions" ("refresh_token_hash");
+CREATE INDEX IF NOT EXISTS "idx_auth_sessions_business_id" ON "auth_sessions" ("business_id");
+CREATE INDEX IF NOT EXISTS "idx_auth_sessions_staff_id" ON "auth_sessions" ("staff_id");
+CREATE INDEX IF NOT EXISTS "idx_auth_sessions_address" ON "auth_sessions" ("address");
+CREATE INDEX IF NOT EXISTS "idx_auth_sessions_previous_token_hash" ON "auth_sessions" ("previous_token_hash");
+
+CREATE TABLE "sign_in_challenges" ("address" varchar(64),"value" text NOT NULL,"expires_at" timestamptz,"created_at" timestamptz,PRIMARY KEY ("address"));
+CREATE INDEX IF NOT EXISTS "idx_sign_in_challenges_expires_at" ON "sign_in_challenges" ("expires_at");
+
+CREATE TABLE "rate_limit_windows" ("limit_key" varchar(255),"window_start" timestamptz,"count" bigint NOT NULL DEFAULT 0,"expires_at" timestamptz,PRIMARY KEY ("limit_key","window_start"));
+CREATE INDEX IF NOT EXISTS "idx_rate_limit_windows_expires_at" ON "rate_limit_windows" ("expires_at");
+
+CREATE TABLE "bill_events" ("id" bigserial,"bill_id" bigint NOT NULL,"from_status" text,"to_status" text NOT NULL,"actor" text NOT NULL,"reason" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_bill_events_bill_id" ON "bill_events" ("bill_id");
+
+CREATE TABLE "payments" ("id" bigserial,"bill_id" bigint NOT NULL,"payer_addr" text NOT NULL,"amount" bigint NOT NULL,"tip_amount" bigint DEFAULT 0,"tx_hash" text NOT NULL,"log_index" bigint,"status" text DEFAULT 'pending',"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_payments_tx_log" ON "payments" ("tx_hash","log_index");
+CREATE INDEX IF NOT EXISTS "idx_payments_bill_id" ON "payments" ("bill_id");
+
+CREATE TABLE "alternative_payments" ("id" bigserial,"bill_id" bigint NOT NULL,"participant_addr" text NOT NULL,"participant_name" text,"amount" bigint NOT NULL,"tip_amount" bigint DEFAULT 0,"payment_method" text NOT NULL,"status" text DEFAULT 'pending',"confirmed_by" text,"created_at" timestamptz,"updated_at" timestamptz,"confirmed_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_alternative_payments_bill_id" ON "alternative_payments" ("bill_id");
+
+CREATE TABLE "split_sessions" ("id" bigserial,"bill_id" bigint NOT NULL,"method" text NOT NULL,"status" text DEFAULT 'active',"created_by" text,"locked_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_split_sessions_bill_id" ON "split_sessions" ("bill_id");
+
+CREATE TABLE "split_shares" ("id" bigserial,"session_id" bigint NOT NULL,"bill_id" bigint NOT NULL,"person_id" text NOT NULL,"person_name" text,"payer_addr" text,"amount" bigint NOT NULL,"tip_amount" bigint DEFAULT 0,"subtotal" bigint,"tax_amount" bigint,"service_fee" bigint,"items" jsonb,"paid_amount" bigint DEFAULT 0,"status" text DEFAULT 'pending',"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_split_shares_payer_addr" ON "split_shares" ("payer_addr");
+CREATE INDEX IF NOT EXISTS "idx_split_shares_bill_id" ON "split_shares" ("bill_id");
+CREATE INDEX IF NOT EXISTS "idx_split_shares_session_id" ON "split_shares" ("session_id");
+
+CREATE TABLE "staff" ("id" bigserial,"business_id" bigint NOT NULL,"email" text NOT NULL,"name" text NOT NULL,"role" text NOT NULL,"is_active" boolean DEFAULT true,"last_login_at" timestamptz,"invited_by" text NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_staff_email" ON "staff" ("email");
+CREATE INDEX IF NOT EXISTS "idx_staff_business_id" ON "staff" ("business_id");
+
+CREATE TABLE "staff_invitations" ("id" bigserial,"business_id" bigint NOT NULL,"email" text NOT NULL,"name" text NOT NULL,"role" text NOT NULL,"token" text NOT NULL,"status" text DEFAULT 'pending',"invited_by" text NOT NULL,"expires_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_staff_invitations_token" ON "staff_invitations" ("token");
+CREATE INDEX IF NOT EXISTS "idx_staff_invitations_business_id" ON "staff_invitations" ("business_id");
+
+CREATE TABLE "staff_login_codes" ("id" bigserial,"staff_id" bigint NOT NULL,"code" text NOT NULL,"expires_at" timestamptz,"used" boolean DEFAULT false,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_staff_login_codes_code" ON "staff_login_codes" ("code");
+CREATE INDEX IF NOT EXISTS "idx_staff_login_codes_staff_id" ON "staff_login_codes" ("staff_id");
+
+CREATE TABLE "orders" ("id" bigserial,"bill_id" bigint NOT NULL,"business_id" bigint NOT NULL,"order_number" text NOT NULL,"status" text NOT NULL DEFAULT 'pending',"created_by" text,"approved_by" text,"notes" text,"items" jsonb,"created_at" timestamptz,"updated_at" timestamptz,"approved_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_orders_business_id" ON "orders" ("business_id");
+CREATE INDEX IF NOT EXISTS "idx_orders_bill_id" ON "orders" ("bill_id");
+
+CREATE TABLE "kitchen_stations" ("id" bigserial,"business_id" bigint NOT NULL,"name" text NOT NULL,"is_default" boolean DEFAULT false,"is_active" boolean DEFAULT true,"sort_order" bigint DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_kitchen_stations_business_id" ON "kitchen_stations" ("business_id");
+
+CREATE TABLE "kitchen_routes" ("id" bigserial,"business_id" bigint NOT NULL,"station_id" bigint NOT NULL,"category" text,"item_name" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_kitchen_routes_station_id" ON "kitchen_routes" ("station_id");
+CREATE INDEX IF NOT EXISTS "idx_kitchen_routes_business_id" ON "kitchen_routes" ("business_id");
+
+CREATE TABLE "kitchen_tickets" ("id" bigserial,"business_id" bigint NOT NULL,"order_id" bigint NOT NULL,"bill_id" bigint NOT NULL,"station_id" bigint NOT NULL,"station_name" text,"order_number" text,"notes" text,"items" jsonb,"status" text NOT NULL DEFAULT 'pending',"started_at" timestamptz,"bumped_at" timestamptz,"bumped_by" text,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_kitchen_tickets_bill_id" ON "kitchen_tickets" ("bill_id");
+CREATE INDEX IF NOT EXISTS "idx_kitchen_tickets_order_id" ON "kitchen_tickets" ("order_id");
+CREATE INDEX IF NOT EXISTS "idx_kitchen_tickets_business_id" ON "kitchen_tickets" ("business_id");
+CREATE INDEX IF NOT EXISTS "idx_kitchen_tickets_status" ON "kitchen_tickets" ("status");
+CREATE INDEX IF NOT EXISTS "idx_kitchen_tickets_station_id" ON "kitchen_tickets" ("station_id");
+
+CREATE TABLE "stock_items" ("id" bigserial,"business_id" bigint NOT NULL,"menu_item_id" bigint,"name" text NOT NULL,"unit" text NOT NULL DEFAULT 'pcs',"quantity" bigint NOT NULL DEFAULT 0,"low_stock_threshold" bigint NOT NULL DEFAULT 0,"low_stock_alerted_at" timestamptz,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_stock_items_menu_item_id" ON "stock_items" ("menu_item_id");
+CREATE INDEX IF NOT EXISTS "idx_stock_items_business_id" ON "stock_items" ("business_id");
+
+CREATE TABLE "menu_item_recipes" ("id" bigserial,"menu_item_id" bigint NOT NULL,"stock_item_id" bigint NOT NULL,"quantity" bigint NOT NULL,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_menu_item_recipes_stock_item_id" ON "menu_item_recipes" ("stock_item_id");
+CREATE INDEX IF NOT EXISTS "idx_menu_item_recipes_menu_item_id" ON "menu_item_recipes" ("menu_item_id");
+
+CREATE TABLE "stock_adjustments" ("id" bigserial,"business_id" bigint NOT NULL,"stock_item_id" bigint NOT NULL,"order_id" bigint,"reason" text NOT NULL,"change" bigint NOT NULL,"quantity_after" bigint NOT NULL,"note" text,"created_by" text,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_stock_adjustments_order_id" ON "stock_adjustments" ("order_id");
+CREATE INDEX IF NOT EXISTS "idx_stock_adjustments_stock_item_id" ON "stock_adjustments" ("stock_item_id");
+CREATE INDEX IF NOT EXISTS "idx_stock_adjustments_business_id" ON "stock_adjustments" ("business_id");
+CREATE INDEX IF NOT EXISTS "idx_stock_adjustments_created_at" ON "stock_adjustments" ("created_at");
+CREATE INDEX IF NOT EXISTS "idx_stock_adjustments_reason" ON "stock_adjustments" ("reason");
+
+CREATE TABLE "referrers" ("id" bigserial,"wallet_address" text NOT NULL,"referral_code" text NOT NULL,"tier" text NOT NULL,"status" text DEFAULT 'active',"total_referrals" bigint DEFAULT 0,"total_commissions" text DEFAULT '0',"claimable_commissions" text DEFAULT '0',"last_claimed_at" timestamptz,"registration_tx_hash" text,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_referrers_referral_code" ON "referrers" ("referral_code");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_referrers_wallet_address" ON "referrers" ("wallet_address");
+
+CREATE TABLE "referral_records" ("id" bigserial,"referrer_id" bigint NOT NULL,"business_id" bigint NOT NULL,"registration_fee" text,"discount" text,"commission" text,"commission_paid" boolean DEFAULT false,"commission_tx_hash" text,"processed_tx_hash" text,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_referral_records_business_id" ON "referral_records" ("business_id");
+CREATE INDEX IF NOT EXISTS "idx_referral_records_referrer_id" ON "referral_records" ("referrer_id");
+
+CREATE TABLE "referral_commission_claims" ("id" bigserial,"referrer_id" bigint NOT NULL,"amount" text,"tx_hash" text,"status" text DEFAULT 'pending',"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_referral_commission_claims_referrer_id" ON "referral_commission_claims" ("referrer_id");
+
+CREATE TABLE "withdrawal_histories" ("id" bigserial,"business_id" bigint NOT NULL,"transaction_hash" text NOT NULL,"payment_amount" bigint DEFAULT 0,"tip_amount" bigint DEFAULT 0,"total_amount" bigint NOT NULL,"withdrawal_address" text NOT NULL,"blockchain_network" text NOT NULL,"status" text DEFAULT 'pending',"created_at" timestamptz,"updated_at" timestamptz,"confirmed_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_withdrawal_histories_transaction_hash" ON "withdrawal_histories" ("transaction_hash");
+CREATE INDEX IF NOT EXISTS "idx_withdrawal_histories_business_id" ON "withdrawal_histories" ("business_id");
+
+CREATE TABLE "subscription_payments" ("id" bigserial,"business_id" bigint NOT NULL,"payment_amount" text NOT NULL,"transaction_hash" text NOT NULL UNIQUE,"block_number" bigint NOT NULL,"new_expiry_time" bigint NOT NULL,"payment_date" timestamptz NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_subscription_payments_business_id" ON "subscription_payments" ("business_id");
+
+CREATE TABLE "supported_currencies" ("id" bigserial,"code" varchar(3) NOT NULL,"name" varchar(100) NOT NULL,"symbol" varchar(10) NOT NULL,"is_active" boolean DEFAULT true,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_supported_currencies_code" ON "supported_currencies" ("code");
+
+CREATE TABLE "exchange_rates" ("id" bigserial,"from_currency" varchar(3) NOT NULL,"to_currency" varchar(3) NOT NULL,"rate" decimal NOT NULL,"source" varchar(50) DEFAULT 'coinbase',"fetched_at" timestamptz NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_exchange_rates_to_currency" ON "exchange_rates" ("to_currency");
+CREATE INDEX IF NOT EXISTS "idx_exchange_rates_from_currency" ON "exchange_rates" ("from_currency");
+
+CREATE TABLE "business_currencies" ("id" bigserial,"business_id" bigint NOT NULL,"currency_code" varchar(3) NOT NULL,"is_preferred" boolean DEFAULT false,"display_order" bigint DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_business_currencies_business_id" ON "business_currencies" ("business_id");
+
+CREATE TABLE "supported_languages" ("id" bigserial,"code" varchar(5) NOT NULL,"name" varchar(100) NOT NULL,"native_name" varchar(100) NOT NULL,"is_active" boolean DEFAULT true,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_supported_languages_code" ON "supported_languages" ("code");
+
+CREATE TABLE "business_languages" ("id" bigserial,"business_id" bigint NOT NULL,"language_code" varchar(5) NOT NULL,"is_default" boolean DEFAULT false,"display_order" bigint DEFAULT 0,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_business_languages_business_id" ON "business_languages" ("business_id");
+
+CREATE TABLE "translations" ("id" bigserial,"entity_type" varchar(50) NOT NULL,"entity_id" bigint NOT NULL,"field_name" varchar(50) NOT NULL,"language_code" varchar(5) NOT NULL,"original_text" text,"translated_text" text NOT NULL,"is_auto_translated" boolean DEFAULT true,"translation_source" varchar(50) DEFAULT 'google',"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_translations_entity_id" ON "translations" ("entity_id");
+CREATE INDEX IF NOT EXISTS "idx_translations_entity_type" ON "translations" ("entity_type");
+
+CREATE TABLE "indexer_checkpoints" ("id" bigserial,"name" text NOT NULL,"last_block" bigint NOT NULL,"created_at" timestamptz,"updated_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_indexer_checkpoints_name" ON "indexer_checkpoints" ("name");
+
+CREATE TABLE "webhook_nonces" ("id" bigserial,"provider" text NOT NULL,"nonce" text NOT NULL,"expires_at" timestamptz NOT NULL,"created_at" timestamptz,PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS "idx_webhook_nonces_expires_at" ON "webhook_nonces" ("expires_at");
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_webhook_nonces_provider_nonce" ON "webhook_nonces" ("provider","nonce");
+
+CREATE TABLE "data_migrations" ("id" bigserial,"name" text NOT NULL,"applied_at" timestamptz,PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_data_migrations_name" ON "data_migrations" ("name");
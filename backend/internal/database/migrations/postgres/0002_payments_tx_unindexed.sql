@@ -0,0 +1,12 @@
+-- A transaction reported before its event is indexed has a single ledger row. The
+-- (tx_hash, log_index) index does not cover these rows because NULLs never collide.
+DELETE FROM payments
+WHERE log_index IS NULL
+  AND EXISTS (
+    SELECT 1 FROM payments earlier
+    WHERE earlier.tx_hash = payments.tx_hash
+      AND earlier.log_index IS NULL
+      AND earlier.id < payments.id
+  );
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_payments_tx_unindexed ON payments (tx_hash) WHERE log_index IS NULL;
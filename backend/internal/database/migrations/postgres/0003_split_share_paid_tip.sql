@@ -0,0 +1,2 @@
+-- Tips already paid towards a split share, so a partially paid share is not tipped twice
+ALTER TABLE split_shares ADD COLUMN paid_tip bigint DEFAULT 0;
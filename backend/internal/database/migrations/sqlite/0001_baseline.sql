@@ -0,0 +1,203 @@
+-- Baseline schema: every table as it stood when versioned migrations were introduced.
+-- Databases created before then are brought up to this point by adoptLegacySchema
+-- and never run this file.
+
+CREATE TABLE `users` (`id` integer PRIMARY KEY AUTOINCREMENT,`address` text NOT NULL,`token_id` text,`role` text DEFAULT "user",`email_enabled` numeric,`news_enabled` numeric,`updates_enabled` numeric,`transactional_enabled` numeric,`security_enabled` numeric,`reports_enabled` numeric,`statistics_enabled` numeric,`created_at` datetime,`updated_at` datetime);
+CREATE UNIQUE INDEX `idx_users_address` ON `users`(`address`);
+
+CREATE TABLE `codes` (`id` integer PRIMARY KEY AUTOINCREMENT,`code` text NOT NULL,`address` text,`amount` text,`used` numeric DEFAULT false,`expiry` text,`claimed_at` datetime,`created_at` datetime,`updated_at` datetime);
+CREATE UNIQUE INDEX `idx_codes_code` ON `codes`(`code`);
+
+CREATE TABLE `error_logs` (`id` integer PRIMARY KEY AUTOINCREMENT,`timestamp` datetime,`error` text,`component` text,`function` text,`additional_info` text,`created_at` datetime);
+CREATE INDEX `idx_error_logs_timestamp` ON `error_logs`(`timestamp`);
+
+CREATE TABLE `faucet_transactions` (`id` integer PRIMARY KEY AUTOINCREMENT,`address` text,`amount` text,`tx_hash` text,`timestamp` datetime,`ticker` text,`created_at` datetime);
+CREATE INDEX `idx_faucet_transactions_timestamp` ON `faucet_transactions`(`timestamp`);
+CREATE UNIQUE INDEX `idx_faucet_transactions_tx_hash` ON `faucet_transactions`(`tx_hash`);
+CREATE INDEX `idx_faucet_transactions_address` ON `faucet_transactions`(`address`);
+
+CREATE TABLE `subscribers` (`id` integer PRIMARY KEY AUTOINCREMENT,`email` text NOT NULL,`created_at` datetime,`updated_at` datetime);
+CREATE UNIQUE INDEX `idx_subscribers_email` ON `subscribers`(`email`);
+
+CREATE TABLE `multisig_transactions` (`id` integer PRIMARY KEY AUTOINCREMENT,`tx_id` integer NOT NULL,`data` text,`created_at` datetime,`updated_at` datetime);
+CREATE UNIQUE INDEX `idx_multisig_transactions_tx_id` ON `multisig_transactions`(`tx_id`);
+
+CREATE TABLE `businesses` (`id` integer PRIMARY KEY AUTOINCREMENT,`owner_address` text NOT NULL,`name` text NOT NULL,`logo` text,`street` text,`city` text,`state` text,`postal_code` text,`country` text,`settlement_addr` text NOT NULL,`tipping_addr` text NOT NULL,`tax_rate` real DEFAULT 0,`service_fee_rate` real DEFAULT 0,`tax_inclusive` numeric DEFAULT false,`service_inclusive` numeric DEFAULT false,`is_active` numeric DEFAULT true,`description` text,`custom_url` text,`phone` text,`email` text,`website` text,`social_media` text,`banner_images` text,`business_page_enabled` numeric DEFAULT false,`show_reviews` numeric DEFAULT true,`google_reviews_enabled` numeric DEFAULT false,`google_place_id` text,`google_business_name` text,`google_review_link` text,`google_business_url` text,`referred_by_code` text,`counter_enabled` numeric DEFAULT false,`counter_count` integer DEFAULT 3,`counter_prefix` text DEFAULT "C",`default_currency` text DEFAULT "USD",`display_currency` text DEFAULT "USD",`default_language` text DEFAULT "en",`timezone` text DEFAULT "UTC",`subscription_status` text DEFAULT "active",`last_payment_date` datetime,`subscription_end_date` datetime,`last_payment_amount` text,`total_paid` text DEFAULT "0",`yearly_fee` text DEFAULT "120000000",`time_remaining` integer DEFAULT 0,`referral_code_used` text,`coupon_code_used` text,`discount_applied` text,`registration_tx_hash` text,`last_renewal_tx_hash` text,`reminders_sent` integer DEFAULT 0,`created_at` datetime,`updated_at` datetime);
+CREATE INDEX `idx_businesses_owner_address` ON `businesses`(`owner_address`);
+
+CREATE TABLE `menus` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`name` text,`is_active` numeric DEFAULT true,`sort_order` integer,`created_at` datetime,`updated_at` datetime,`categories` text,CONSTRAINT `fk_menus_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`));
+CREATE INDEX `idx_menus_business_id` ON `menus`(`business_id`);
+
+CREATE TABLE `menu_categories` (`id` integer PRIMARY KEY AUTOINCREMENT,`menu_id` integer NOT NULL,`business_id` integer NOT NULL,`name` text NOT NULL,`description` text,`sort_order` integer,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_menus_categories` FOREIGN KEY (`menu_id`) REFERENCES `menus`(`id`));
+CREATE INDEX `idx_menu_categories_menu_id` ON `menu_categories`(`menu_id`);
+CREATE INDEX `idx_menu_categories_business_id` ON `menu_categories`(`business_id`);
+
+CREATE TABLE `menu_items` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`category_id` integer NOT NULL,`name` text NOT NULL,`description` text,`price` integer NOT NULL,`currency` text,`image` text,`allergens` text,`dietary_tags` text,`is_available` numeric NOT NULL,`sort_order` integer,`created_at` datetime,`updated_at` datetime,`sold_out_until` datetime,CONSTRAINT `fk_menu_categories_items` FOREIGN KEY (`category_id`) REFERENCES `menu_categories`(`id`));
+CREATE INDEX `idx_menu_items_category_id` ON `menu_items`(`category_id`);
+CREATE INDEX `idx_menu_items_business_id` ON `menu_items`(`business_id`);
+
+CREATE TABLE `menu_item_options` (`id` integer PRIMARY KEY AUTOINCREMENT,`menu_item_id` integer NOT NULL,`name` text NOT NULL,`price_change` integer NOT NULL DEFAULT 0,`is_required` numeric NOT NULL,`sort_order` integer,CONSTRAINT `fk_menu_items_options` FOREIGN KEY (`menu_item_id`) REFERENCES `menu_items`(`id`));
+CREATE INDEX `idx_menu_item_options_menu_item_id` ON `menu_item_options`(`menu_item_id`);
+
+CREATE TABLE `menu_item_images` (`id` integer PRIMARY KEY AUTOINCREMENT,`menu_item_id` integer NOT NULL,`url` text NOT NULL,`sort_order` integer,CONSTRAINT `fk_menu_items_image_records` FOREIGN KEY (`menu_item_id`) REFERENCES `menu_items`(`id`));
+CREATE INDEX `idx_menu_item_images_menu_item_id` ON `menu_item_images`(`menu_item_id`);
+
+CREATE TABLE `menu_modifier_groups` (`id` integer PRIMARY KEY AUTOINCREMENT,`menu_item_id` integer NOT NULL,`parent_option_id` integer,`name` text NOT NULL,`min_select` integer NOT NULL DEFAULT 0,`max_select` integer NOT NULL DEFAULT 0,`sort_order` integer);
+CREATE INDEX `idx_menu_modifier_groups_menu_item_id` ON `menu_modifier_groups`(`menu_item_id`);
+CREATE INDEX `idx_menu_modifier_groups_parent_option_id` ON `menu_modifier_groups`(`parent_option_id`);
+
+CREATE TABLE `menu_modifier_options` (`id` integer PRIMARY KEY AUTOINCREMENT,`group_id` integer NOT NULL,`menu_item_id` integer NOT NULL,`name` text NOT NULL,`price_change` integer NOT NULL DEFAULT 0,`is_default` numeric NOT NULL,`is_available` numeric NOT NULL,`sort_order` integer);
+CREATE INDEX `idx_menu_modifier_options_menu_item_id` ON `menu_modifier_options`(`menu_item_id`);
+CREATE INDEX `idx_menu_modifier_options_group_id` ON `menu_modifier_options`(`group_id`);
+
+CREATE TABLE `menu_schedules` (`id` integer PRIMARY KEY AUTOINCREMENT,`menu_id` integer NOT NULL,`day_of_week` integer NOT NULL,`start_time` text NOT NULL,`end_time` text NOT NULL,CONSTRAINT `fk_menus_schedules` FOREIGN KEY (`menu_id`) REFERENCES `menus`(`id`));
+CREATE INDEX `idx_menu_schedules_menu_id` ON `menu_schedules`(`menu_id`);
+
+CREATE TABLE `menu_item_schedules` (`id` integer PRIMARY KEY AUTOINCREMENT,`menu_item_id` integer NOT NULL,`day_of_week` integer NOT NULL,`start_time` text NOT NULL,`end_time` text NOT NULL,CONSTRAINT `fk_menu_items_schedules` FOREIGN KEY (`menu_item_id`) REFERENCES `menu_items`(`id`));
+CREATE INDEX `idx_menu_item_schedules_menu_item_id` ON `menu_item_schedules`(`menu_item_id`);
+
+CREATE TABLE `tables` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`table_code` text NOT NULL,`name` text NOT NULL,`qr_code` text,`is_active` numeric DEFAULT true,`section` text,`position_x` real,`position_y` real,`seats` integer,`shape` text DEFAULT "square",`cleaned_at` datetime,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_tables_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`));
+CREATE INDEX `idx_tables_section` ON `tables`(`section`);
+CREATE UNIQUE INDEX `idx_tables_table_code` ON `tables`(`table_code`);
+CREATE INDEX `idx_tables_business_id` ON `tables`(`business_id`);
+
+CREATE TABLE `bills` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`table_id` integer,`counter_id` integer,`bill_number` text NOT NULL,`notes` text,`items` text,`subtotal` integer,`tax_amount` integer,`service_fee_amount` integer,`total_amount` integer,`paid_amount` integer DEFAULT 0,`tip_amount` integer DEFAULT 0,`status` text DEFAULT "open",`settlement_addr` text NOT NULL,`tipping_addr` text NOT NULL,`created_at` datetime,`updated_at` datetime,`closed_at` datetime,CONSTRAINT `fk_bills_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`),CONSTRAINT `fk_bills_table` FOREIGN KEY (`table_id`) REFERENCES `tables`(`id`),CONSTRAINT `fk_bills_counter` FOREIGN KEY (`counter_id`) REFERENCES `counters`(`id`));
+CREATE INDEX `idx_bills_table_id` ON `bills`(`table_id`);
+CREATE INDEX `idx_bills_business_id` ON `bills`(`business_id`);
+CREATE UNIQUE INDEX `idx_bills_bill_number` ON `bills`(`bill_number`);
+CREATE INDEX `idx_bills_counter_id` ON `bills`(`counter_id`);
+
+CREATE TABLE `counters` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`counter_number` integer NOT NULL,`name` text NOT NULL,`counter_code` text,`qr_code` text,`is_active` numeric DEFAULT true,`current_bill_id` integer,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_counters_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`),CONSTRAINT `fk_counters_current_bill` FOREIGN KEY (`current_bill_id`) REFERENCES `bills`(`id`));
+CREATE INDEX `idx_counters_business_id` ON `counters`(`business_id`);
+CREATE INDEX `idx_counters_counter_code` ON `counters`(`counter_code`);
+
+CREATE TABLE `table_sessions` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`table_id` integer NOT NULL,`bill_id` integer,`token_hash` text NOT NULL,`device_id` text,`device_name` text,`user_agent` text,`ip_address` text,`expires_at` datetime,`ended_at` datetime,`end_reason` text,`last_seen_at` datetime,`created_at` datetime);
+CREATE INDEX `idx_table_sessions_business_id` ON `table_sessions`(`business_id`);
+CREATE INDEX `idx_table_sessions_device_id` ON `table_sessions`(`device_id`);
+CREATE UNIQUE INDEX `idx_table_sessions_token_hash` ON `table_sessions`(`token_hash`);
+CREATE INDEX `idx_table_sessions_bill_id` ON `table_sessions`(`bill_id`);
+CREATE INDEX `idx_table_sessions_table_id` ON `table_sessions`(`table_id`);
+
+CREATE TABLE `auth_sessions` (`id` integer PRIMARY KEY AUTOINCREMENT,`address` text,`staff_id` integer,`business_id` integer,`refresh_token_hash` text NOT NULL,`previous_token_hash` text,`device_name` text,`user_agent` text,`ip_address` text,`expires_at` datetime,`revoked_at` datetime,`revoke_reason` text,`last_used_at` datetime,`created_at` datetime);
+CREATE INDEX `idx_auth_sessions_previous_token_hash` ON `auth_sessions`(`previous_token_hash`);
+CREATE UNIQUE INDEX `idx_auth_sessions_refresh_token_hash` ON `auth_sessions`(`refresh_token_hash`);
+CREATE INDEX `idx_auth_sessions_business_id` ON `auth_sessions`(`business_id`);
+CREATE INDEX `idx_auth_sessions_staff_id` ON `auth_sessions`(`staff_id`);
+CREATE INDEX `idx_auth_sessions_address` ON `auth_sessions`(`address`);
+
+CREATE TABLE `sign_in_challenges` (`address` text,`value` text NOT NULL,`expires_at` datetime,`created_at` datetime,PRIMARY KEY (`address`));
+CREATE INDEX `idx_sign_in_challenges_expires_at` ON `sign_in_challenges`(`expires_at`);
+
+CREATE TABLE `rate_limit_windows` (`limit_key` text,`window_start` datetime,`count` integer NOT NULL DEFAULT 0,`expires_at` datetime,PRIMARY KEY (`limit_key`,`window_start`));
+CREATE INDEX `idx_rate_limit_windows_expires_at` ON `rate_limit_windows`(`expires_at`);
+
+CREATE TABLE `bill_events` (`id` integer PRIMARY KEY AUTOINCREMENT,`bill_id` integer NOT NULL,`from_status` text,`to_status` text NOT NULL,`actor` text NOT NULL,`reason` text,`created_at` datetime);
+CREATE INDEX `idx_bill_events_bill_id` ON `bill_events`(`bill_id`);
+
+CREATE TABLE `payments` (`id` integer PRIMARY KEY AUTOINCREMENT,`bill_id` integer NOT NULL,`payer_addr` text NOT NULL,`amount` integer NOT NULL,`tip_amount` integer DEFAULT 0,`tx_hash` text NOT NULL,`log_index` integer,`status` text DEFAULT "pending",`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_bills_payments` FOREIGN KEY (`bill_id`) REFERENCES `bills`(`id`));
+CREATE INDEX `idx_payments_bill_id` ON `payments`(`bill_id`);
+CREATE UNIQUE INDEX `idx_payments_tx_log` ON `payments`(`tx_hash`,`log_index`);
+
+CREATE TABLE `alternative_payments` (`id` integer PRIMARY KEY AUTOINCREMENT,`bill_id` integer NOT NULL,`participant_addr` text NOT NULL,`participant_name` text,`amount` integer NOT NULL,`tip_amount` integer DEFAULT 0,`payment_method` text NOT NULL,`status` text DEFAULT "pending",`confirmed_by` text,`created_at` datetime,`updated_at` datetime,`confirmed_at` datetime,CONSTRAINT `fk_alternative_payments_bill` FOREIGN KEY (`bill_id`) REFERENCES `bills`(`id`));
+CREATE INDEX `idx_alternative_payments_bill_id` ON `alternative_payments`(`bill_id`);
+
+CREATE TABLE `split_sessions` (`id` integer PRIMARY KEY AUTOINCREMENT,`bill_id` integer NOT NULL,`method` text NOT NULL,`status` text DEFAULT "active",`created_by` text,`locked_at` datetime,`created_at` datetime,`updated_at` datetime);
+CREATE INDEX `idx_split_sessions_bill_id` ON `split_sessions`(`bill_id`);
+
+CREATE TABLE `split_shares` (`id` integer PRIMARY KEY AUTOINCREMENT,`session_id` integer NOT NULL,`bill_id` integer NOT NULL,`person_id` text NOT NULL,`person_name` text,`payer_addr` text,`amount` integer NOT NULL,`tip_amount` integer DEFAULT 0,`subtotal` integer,`tax_amount` integer,`service_fee` integer,`items` text,`paid_amount` integer DEFAULT 0,`status` text DEFAULT "pending",`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_split_sessions_shares` FOREIGN KEY (`session_id`) REFERENCES `split_sessions`(`id`));
+CREATE INDEX `idx_split_shares_payer_addr` ON `split_shares`(`payer_addr`);
+CREATE INDEX `idx_split_shares_bill_id` ON `split_shares`(`bill_id`);
+CREATE INDEX `idx_split_shares_session_id` ON `split_shares`(`session_id`);
+
+CREATE TABLE `staff` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`email` text NOT NULL,`name` text NOT NULL,`role` text NOT NULL,`is_active` numeric DEFAULT true,`last_login_at` datetime,`invited_by` text NOT NULL,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_staff_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`));
+CREATE UNIQUE INDEX `idx_staff_email` ON `staff`(`email`);
+CREATE INDEX `idx_staff_business_id` ON `staff`(`business_id`);
+
+CREATE TABLE `staff_invitations` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`email` text NOT NULL,`name` text NOT NULL,`role` text NOT NULL,`token` text NOT NULL,`status` text DEFAULT "pending",`invited_by` text NOT NULL,`expires_at` datetime,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_staff_invitations_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`));
+CREATE UNIQUE INDEX `idx_staff_invitations_token` ON `staff_invitations`(`token`);
+CREATE INDEX `idx_staff_invitations_business_id` ON `staff_invitations`(`business_id`);
+
+CREATE TABLE `staff_login_codes` (`id` integer PRIMARY KEY AUTOINCREMENT,`staff_id` integer NOT NULL,`code` text NOT NULL,`expires_at` datetime,`used` numeric DEFAULT false,`created_at` datetime,CONSTRAINT `fk_staff_login_codes_staff` FOREIGN KEY (`staff_id`) REFERENCES `staff`(`id`));
+CREATE UNIQUE INDEX `idx_staff_login_codes_code` ON `staff_login_codes`(`code`);
+CREATE INDEX `idx_staff_login_codes_staff_id` ON `staff_login_codes`(`staff_id`);
+
+CREATE TABLE `orders` (`id` integer PRIMARY KEY AUTOINCREMENT,`bill_id` integer NOT NULL,`business_id` integer NOT NULL,`order_number` text NOT NULL,`status` text NOT NULL DEFAULT "pending",`created_by` text,`approved_by` text,`notes` text,`items` text,`created_at` datetime,`updated_at` datetime,`approved_at` datetime,CONSTRAINT `fk_orders_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`),CONSTRAINT `fk_orders_bill` FOREIGN KEY (`bill_id`) REFERENCES `bills`(`id`));
+CREATE INDEX `idx_orders_bill_id` ON `orders`(`bill_id`);
+CREATE INDEX `idx_orders_business_id` ON `orders`(`business_id`);
+
+CREATE TABLE `kitchen_stations` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`name` text NOT NULL,`is_default` numeric DEFAULT false,`is_active` numeric DEFAULT true,`sort_order` integer DEFAULT 0,`created_at` datetime,`updated_at` datetime);
+CREATE INDEX `idx_kitchen_stations_business_id` ON `kitchen_stations`(`business_id`);
+
+CREATE TABLE `kitchen_routes` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`station_id` integer NOT NULL,`category` text,`item_name` text,`created_at` datetime);
+CREATE INDEX `idx_kitchen_routes_station_id` ON `kitchen_routes`(`station_id`);
+CREATE INDEX `idx_kitchen_routes_business_id` ON `kitchen_routes`(`business_id`);
+
+CREATE TABLE `kitchen_tickets` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`order_id` integer NOT NULL,`bill_id` integer NOT NULL,`station_id` integer NOT NULL,`station_name` text,`order_number` text,`notes` text,`items` text,`status` text NOT NULL DEFAULT "pending",`started_at` datetime,`bumped_at` datetime,`bumped_by` text,`created_at` datetime,`updated_at` datetime);
+CREATE INDEX `idx_kitchen_tickets_status` ON `kitchen_tickets`(`status`);
+CREATE INDEX `idx_kitchen_tickets_station_id` ON `kitchen_tickets`(`station_id`);
+CREATE INDEX `idx_kitchen_tickets_bill_id` ON `kitchen_tickets`(`bill_id`);
+CREATE INDEX `idx_kitchen_tickets_order_id` ON `kitchen_tickets`(`order_id`);
+CREATE INDEX `idx_kitchen_tickets_business_id` ON `kitchen_tickets`(`business_id`);
+
+CREATE TABLE `stock_items` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`menu_item_id` integer,`name` text NOT NULL,`unit` text NOT NULL DEFAULT "pcs",`quantity` integer NOT NULL DEFAULT 0,`low_stock_threshold` integer NOT NULL DEFAULT 0,`low_stock_alerted_at` datetime,`created_at` datetime,`updated_at` datetime);
+CREATE INDEX `idx_stock_items_menu_item_id` ON `stock_items`(`menu_item_id`);
+CREATE INDEX `idx_stock_items_business_id` ON `stock_items`(`business_id`);
+
+CREATE TABLE `menu_item_recipes` (`id` integer PRIMARY KEY AUTOINCREMENT,`menu_item_id` integer NOT NULL,`stock_item_id` integer NOT NULL,`quantity` integer NOT NULL);
+CREATE INDEX `idx_menu_item_recipes_stock_item_id` ON `menu_item_recipes`(`stock_item_id`);
+CREATE INDEX `idx_menu_item_recipes_menu_item_id` ON `menu_item_recipes`(`menu_item_id`);
+
+CREATE TABLE `stock_adjustments` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`stock_item_id` integer NOT NULL,`order_id` integer,`reason` text NOT NULL,`change` integer NOT NULL,`quantity_after` integer NOT NULL,`note` text,`created_by` text,`created_at` datetime);
+CREATE INDEX `idx_stock_adjustments_reason` ON `stock_adjustments`(`reason`);
+CREATE INDEX `idx_stock_adjustments_order_id` ON `stock_adjustments`(`order_id`);
+CREATE INDEX `idx_stock_adjustments_stock_item_id` ON `stock_adjustments`(`stock_item_id`);
+CREATE INDEX `idx_stock_adjustments_business_id` ON `stock_adjustments`(`business_id`);
+CREATE INDEX `idx_stock_adjustments_created_at` ON `stock_adjustments`(`created_at`);
+
+CREATE TABLE `referrers` (`id` integer PRIMARY KEY AUTOINCREMENT,`wallet_address` text NOT NULL,`referral_code` text NOT NULL,`tier` text NOT NULL,`status` text DEFAULT "active",`total_referrals` integer DEFAULT 0,`total_commissions` text DEFAULT "0",`claimable_commissions` text DEFAULT "0",`last_claimed_at` datetime,`registration_tx_hash` text,`created_at` datetime,`updated_at` datetime);
+CREATE UNIQUE INDEX `idx_referrers_referral_code` ON `referrers`(`referral_code`);
+CREATE UNIQUE INDEX `idx_referrers_wallet_address` ON `referrers`(`wallet_address`);
+
+CREATE TABLE `referral_records` (`id` integer PRIMARY KEY AUTOINCREMENT,`referrer_id` integer NOT NULL,`business_id` integer NOT NULL,`registration_fee` text,`discount` text,`commission` text,`commission_paid` numeric DEFAULT false,`commission_tx_hash` text,`processed_tx_hash` text,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_referral_records_referrer` FOREIGN KEY (`referrer_id`) REFERENCES `referrers`(`id`),CONSTRAINT `fk_referral_records_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`));
+CREATE INDEX `idx_referral_records_business_id` ON `referral_records`(`business_id`);
+CREATE INDEX `idx_referral_records_referrer_id` ON `referral_records`(`referrer_id`);
+
+CREATE TABLE `referral_commission_claims` (`id` integer PRIMARY KEY AUTOINCREMENT,`referrer_id` integer NOT NULL,`amount` text,`tx_hash` text,`status` text DEFAULT "pending",`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_referral_commission_claims_referrer` FOREIGN KEY (`referrer_id`) REFERENCES `referrers`(`id`));
+CREATE INDEX `idx_referral_commission_claims_referrer_id` ON `referral_commission_claims`(`referrer_id`);
+
+CREATE TABLE `withdrawal_histories` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`transaction_hash` text NOT NULL,`payment_amount` integer DEFAULT 0,`tip_amount` integer DEFAULT 0,`total_amount` integer NOT NULL,`withdrawal_address` text NOT NULL,`blockchain_network` text NOT NULL,`status` text DEFAULT "pending",`created_at` datetime,`updated_at` datetime,`confirmed_at` datetime,CONSTRAINT `fk_withdrawal_histories_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`));
+CREATE UNIQUE INDEX `idx_withdrawal_histories_transaction_hash` ON `withdrawal_histories`(`transaction_hash`);
+CREATE INDEX `idx_withdrawal_histories_business_id` ON `withdrawal_histories`(`business_id`);
+
+CREATE TABLE `subscription_payments` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`payment_amount` text NOT NULL,`transaction_hash` text NOT NULL UNIQUE,`block_number` integer NOT NULL,`new_expiry_time` integer NOT NULL,`payment_date` datetime NOT NULL,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_subscription_payments_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`));
+CREATE INDEX `idx_subscription_payments_business_id` ON `subscription_payments`(`business_id`);
+
+CREATE TABLE `supported_currencies` (`id` integer PRIMARY KEY AUTOINCREMENT,`code` text NOT NULL,`name` text NOT NULL,`symbol` text NOT NULL,`is_active` numeric DEFAULT true,`created_at` datetime,`updated_at` datetime);
+CREATE UNIQUE INDEX `idx_supported_currencies_code` ON `supported_currencies`(`code`);
+
+CREATE TABLE `exchange_rates` (`id` integer PRIMARY KEY AUTOINCREMENT,`from_currency` text NOT NULL,`to_currency` text NOT NULL,`rate` real NOT NULL,`source` text DEFAULT "coinbase",`fetched_at` datetime NOT NULL,`created_at` datetime,`updated_at` datetime);
+CREATE INDEX `idx_exchange_rates_to_currency` ON `exchange_rates`(`to_currency`);
+CREATE INDEX `idx_exchange_rates_from_currency` ON `exchange_rates`(`from_currency`);
+
+CREATE TABLE `business_currencies` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`currency_code` text NOT NULL,`is_preferred` numeric DEFAULT false,`display_order` integer DEFAULT 0,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_business_currencies_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`));
+CREATE INDEX `idx_business_currencies_business_id` ON `business_currencies`(`business_id`);
+
+CREATE TABLE `supported_languages` (`id` integer PRIMARY KEY AUTOINCREMENT,`code` text NOT NULL,`name` text NOT NULL,`native_name` text NOT NULL,`is_active` numeric DEFAULT true,`created_at` datetime,`updated_at` datetime);
+CREATE UNIQUE INDEX `idx_supported_languages_code` ON `supported_languages`(`code`);
+
+CREATE TABLE `business_languages` (`id` integer PRIMARY KEY AUTOINCREMENT,`business_id` integer NOT NULL,`language_code` text NOT NULL,`is_default` numeric DEFAULT false,`display_order` integer DEFAULT 0,`created_at` datetime,`updated_at` datetime,CONSTRAINT `fk_business_languages_business` FOREIGN KEY (`business_id`) REFERENCES `businesses`(`id`));
+CREATE INDEX `idx_business_languages_business_id` ON `business_languages`(`business_id`);
+
+CREATE TABLE `translations` (`id` integer PRIMARY KEY AUTOINCREMENT,`entity_type` text NOT NULL,`entity_id` integer NOT NULL,`field_name` text NOT NULL,`language_code` text NOT NULL,`original_text` text,`translated_text` text NOT NULL,`is_auto_translated` numeric DEFAULT true,`translation_source` text DEFAULT "google",`created_at` datetime,`updated_at` datetime);
+CREATE INDEX `idx_translations_entity_id` ON `translations`(`entity_id`);
+CREATE INDEX `idx_translations_entity_type` ON `translations`(`entity_type`);
+
+CREATE TABLE `indexer_checkpoints` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`last_block` integer NOT NULL,`created_at` datetime,`updated_at` datetime);
+CREATE UNIQUE INDEX `idx_indexer_checkpoints_name` ON `indexer_checkpoints`(`name`);
+
+CREATE TABLE `webhook_nonces` (`id` integer PRIMARY KEY AUTOINCREMENT,`provider` text NOT NULL,`nonce` text NOT NULL,`expires_at` datetime NOT NULL,`created_at` datetime);
+CREATE INDEX `idx_webhook_nonces_expires_at` ON `webhook_nonces`(`expires_at`);
+CREATE UNIQUE INDEX `idx_webhook_nonces_provider_nonce` ON `webhook_nonces`(`provider`,`nonce`);
+
+CREATE TABLE `data_migrations` (`id` integer PRIMARY KEY AUTOINCREMENT,`name` text NOT NULL,`applied_at` datetime);
+CREATE UNIQUE INDEX `idx_data_migrations_name` ON `data_migrations`(`name`);
@@ -0,0 +1,6 @@
+-- Kitchen routes name menu items and categories by ID instead of by name. Existing
+-- routes are converted by the kitchen_route_ids data migration.
+ALTER TABLE kitchen_routes ADD COLUMN category_id integer;
+ALTER TABLE kitchen_routes ADD COLUMN menu_item_id integer;
+CREATE INDEX `idx_kitchen_routes_category_id` ON `kitchen_routes`(`category_id`);
+CREATE INDEX `idx_kitchen_routes_menu_item_id` ON `kitchen_routes`(`menu_item_id`);
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"payverge/internal/money"
 	"payverge/internal/structs"
 	"time"
 )
@@ -110,6 +111,8 @@ type Business struct {
 	DefaultCurrency string `gorm:"default:'USD'" json:"default_currency"` // Currency for setting prices (internal)
 	DisplayCurrency string `gorm:"default:'USD'" json:"display_currency"` // Currency shown to customers
 	DefaultLanguage string `gorm:"default:'en'" json:"default_language"`  // Default menu language
+	// IANA time zone the business's menu schedules are written in
+	Timezone string `gorm:"default:'UTC'" json:"timezone"`
 	// Subscription Management Fields (Pay-as-you-go model)
 	SubscriptionStatus  string     `gorm:"default:'active'" json:"subscription_status"` // active, expired, suspended, cancelled
 	LastPaymentDate     *time.Time `json:"last_payment_date"`
@@ -135,6 +138,8 @@ type Counter struct {
 	BusinessID    uint      `gorm:"not null;index" json:"business_id"`
 	CounterNumber int       `gorm:"not null" json:"counter_number"`
 	Name          string    `gorm:"not null" json:"name"`
+	CounterCode   string    `gorm:"index" json:"counter_code"`
+	QRCode        string    `json:"qr_code"`
 	IsActive      bool      `gorm:"default:true" json:"is_active"`
 	CurrentBillID *uint     `json:"current_bill_id"`
 	CreatedAt     time.Time `json:"created_at"`
@@ -154,118 +159,331 @@ type BusinessAddress struct {
 	Country    string `json:"country"`
 }
 
-// Menu represents a business's menu
+// Menu represents one of a business's menus, such as breakfast or happy hour. A menu
+// with schedules is served only during them; one without is served whenever no
+// scheduled menu is. The first menu in sort order is the business's main menu.
 type Menu struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	BusinessID uint      `gorm:"index;not null" json:"business_id"`
-	Categories string    `gorm:"type:text" json:"categories"` // JSON string for SQLite
-	IsActive   bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	Business   Business  `gorm:"foreignKey:BusinessID" json:"business,omitempty"`
+	ID         uint           `gorm:"primaryKey" json:"id"`
+	BusinessID uint           `gorm:"index;not null" json:"business_id"`
+	Name       string         `json:"name"`
+	IsActive   bool           `gorm:"default:true" json:"is_active"`
+	SortOrder  int            `json:"sort_order"`
+	Schedules  []MenuSchedule `gorm:"foreignKey:MenuID" json:"schedules"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	Business   Business       `gorm:"foreignKey:BusinessID" json:"business,omitempty"`
+	Categories []MenuCategory `gorm:"foreignKey:MenuID" json:"categories"`
+
+	// LegacyCategories holds menus stored as one JSON document before categories and
+	// items had tables of their own. It is emptied once the menu has been converted.
+	LegacyCategories string `gorm:"column:categories;type:text" json:"-"`
 }
 
 // MenuCategory represents a category within a menu
 type MenuCategory struct {
-	ID          string     `json:"id"`
-	Name        string     `json:"name"`
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	MenuID      uint       `gorm:"index;not null" json:"menu_id"`
+	BusinessID  uint       `gorm:"index;not null" json:"business_id"`
+	Name        string     `gorm:"not null" json:"name"`
 	Description string     `json:"description"`
-	Items       []MenuItem `json:"items"`
 	SortOrder   int        `json:"sort_order"`
+	Items       []MenuItem `gorm:"foreignKey:CategoryID" json:"items"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
 }
 
 // MenuItem represents an individual menu item
 type MenuItem struct {
-	ID          string           `json:"id"`
-	Name        string           `json:"name"`
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	BusinessID  uint             `gorm:"index;not null" json:"business_id"`
+	CategoryID  uint             `gorm:"index;not null" json:"category_id"`
+	Name        string           `gorm:"not null" json:"name"`
 	Description string           `json:"description"`
-	Price       float64          `json:"price"`
+	Price       money.Amount     `gorm:"not null" json:"price"`
 	Currency    string           `json:"currency"`
-	Image       string           `json:"image"`  // Keep for backward compatibility
-	Images      []string         `json:"images"` // New field for multiple images
-	Options     []MenuItemOption `json:"options"`
-	Allergens   []string         `json:"allergens"`
-	DietaryTags []string         `json:"dietary_tags"`
-	IsAvailable bool             `json:"is_available"`
+	Image       string           `json:"image"`           // Keep for backward compatibility
+	Images      []string         `gorm:"-" json:"images"` // URLs of ImageRecords, in order
+	Options     []MenuItemOption `gorm:"foreignKey:MenuItemID" json:"options"`
+	Modifiers   []ModifierGroup  `gorm:"-" json:"modifier_groups"`
+	Allergens   []string         `gorm:"serializer:json" json:"allergens"`
+	DietaryTags []string         `gorm:"serializer:json" json:"dietary_tags"`
+	IsAvailable bool             `gorm:"not null" json:"is_available"`
 	SortOrder   int              `json:"sort_order"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+
+	// Schedules limit the item to some hours of its menu; an item without them is
+	// available whenever its menu is served
+	Schedules []MenuItemSchedule `gorm:"foreignKey:MenuItemID" json:"schedules"`
+	// SoldOutUntil marks the item as sold out until the given time
+	SoldOutUntil *time.Time `json:"sold_out_until"`
+
+	ImageRecords []MenuItemImage `gorm:"foreignKey:MenuItemID" json:"-"`
 }
 
 // MenuItemOption represents options/modifications for menu items
 type MenuItemOption struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	PriceChange float64 `json:"price_change"`
-	IsRequired  bool    `json:"is_required"`
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	MenuItemID  uint         `gorm:"index;not null" json:"menu_item_id"`
+	Name        string       `gorm:"not null" json:"name"`
+	PriceChange money.Amount `gorm:"not null;default:0" json:"price_change"`
+	IsRequired  bool         `gorm:"not null" json:"is_required"`
+	SortOrder   int          `json:"sort_order"`
+}
+
+// ModifierGroup is a choice offered on a menu item, such as "Size" or "Toppings". Guests
+// pick between MinSelect and MaxSelect of its options; a MaxSelect of 0 means no limit.
+// Groups nested under an option are only offered when that option is chosen.
+type ModifierGroup struct {
+	ID             uint             `gorm:"primaryKey" json:"id"`
+	MenuItemID     uint             `gorm:"index;not null" json:"menu_item_id"`
+	ParentOptionID *uint            `gorm:"index" json:"parent_option_id,omitempty"`
+	Name           string           `gorm:"not null" json:"name"`
+	MinSelect      int              `gorm:"not null;default:0" json:"min_select"`
+	MaxSelect      int              `gorm:"not null;default:0" json:"max_select"`
+	SortOrder      int              `json:"sort_order"`
+	Options        []ModifierOption `gorm:"-" json:"options"`
+}
+
+// ModifierOption is one of the choices of a modifier group
+type ModifierOption struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	GroupID     uint            `gorm:"index;not null" json:"group_id"`
+	MenuItemID  uint            `gorm:"index;not null" json:"menu_item_id"`
+	Name        string          `gorm:"not null" json:"name"`
+	PriceChange money.Amount    `gorm:"not null;default:0" json:"price_change"`
+	IsDefault   bool            `gorm:"not null" json:"is_default"`
+	IsAvailable bool            `gorm:"not null" json:"is_available"`
+	SortOrder   int             `json:"sort_order"`
+	Groups      []ModifierGroup `gorm:"-" json:"groups"`
+}
+
+// ScheduleWindow is a weekly period in the business's time zone, from StartTime to
+// EndTime ("HH:MM") on DayOfWeek (0 is Sunday). A window ending at or before its start
+// runs past midnight into the next day; an EndTime of "24:00" ends at midnight.
+type ScheduleWindow struct {
+	DayOfWeek int    `gorm:"not null" json:"day_of_week"`
+	StartTime string `gorm:"not null" json:"start_time"`
+	EndTime   string `gorm:"not null" json:"end_time"`
+}
+
+// MenuSchedule is a weekly period during which a menu is served
+type MenuSchedule struct {
+	ID             uint `gorm:"primaryKey" json:"id"`
+	MenuID         uint `gorm:"index;not null" json:"menu_id"`
+	ScheduleWindow `gorm:"embedded"`
+}
+
+// MenuItemSchedule is a weekly period during which a menu item can be ordered
+type MenuItemSchedule struct {
+	ID             uint `gorm:"primaryKey" json:"id"`
+	MenuItemID     uint `gorm:"index;not null" json:"menu_item_id"`
+	ScheduleWindow `gorm:"embedded"`
+}
+
+// MenuItemImage is one of the pictures of a menu item
+type MenuItemImage struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	MenuItemID uint   `gorm:"index;not null" json:"menu_item_id"`
+	URL        string `gorm:"not null" json:"url"`
+	SortOrder  int    `json:"sort_order"`
+}
+
+// SelectedOption is a snapshot of a menu item option as it was when an item was
+// ordered or added to a bill, so later menu edits do not change past orders
+type SelectedOption struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	PriceChange money.Amount `json:"price_change"`
+	IsRequired  bool         `json:"is_required"`
+}
+
+// SelectedModifierGroup is a snapshot of the options chosen in a modifier group
+type SelectedModifierGroup struct {
+	GroupID uint               `json:"group_id"`
+	Name    string             `json:"name"`
+	Options []SelectedModifier `json:"options"`
+}
+
+// SelectedModifier is a snapshot of a chosen modifier option, with the choices made in
+// the groups nested under it
+type SelectedModifier struct {
+	OptionID    uint                    `json:"option_id"`
+	Name        string                  `json:"name"`
+	PriceChange money.Amount            `json:"price_change"`
+	Groups      []SelectedModifierGroup `json:"groups,omitempty"`
 }
 
 // Table represents a physical table in a business
 type Table struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	BusinessID uint      `gorm:"index;not null" json:"business_id"`
-	TableCode  string    `gorm:"uniqueIndex;not null" json:"table_code"`
-	Name       string    `gorm:"not null" json:"name"`
-	QRCode     string    `json:"qr_code"`
-	IsActive   bool      `gorm:"default:true" json:"is_active"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	Business   Business  `gorm:"foreignKey:BusinessID" json:"business,omitempty"`
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	BusinessID uint       `gorm:"index;not null" json:"business_id"`
+	TableCode  string     `gorm:"uniqueIndex;not null" json:"table_code"`
+	Name       string     `gorm:"not null" json:"name"`
+	QRCode     string     `json:"qr_code"`
+	IsActive   bool       `gorm:"default:true" json:"is_active"`
+	Section    string     `gorm:"index" json:"section"` // Floor plan area, e.g. "Patio"
+	PositionX  float64    `json:"position_x"`           // Floor plan coordinates of the table's centre
+	PositionY  float64    `json:"position_y"`
+	Seats      int        `json:"seats"`
+	Shape      TableShape `gorm:"default:'square'" json:"shape"`
+	CleanedAt  *time.Time `json:"cleaned_at"` // Last time staff marked the table ready for new guests
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Business   Business   `gorm:"foreignKey:BusinessID" json:"business,omitempty"`
+}
+
+// TableShape is how a table is drawn on the floor plan
+type TableShape string
+
+const (
+	TableShapeSquare    TableShape = "square"
+	TableShapeRound     TableShape = "round"
+	TableShapeRectangle TableShape = "rectangle"
+)
+
+// IsValid reports whether s is a known table shape
+func (s TableShape) IsValid() bool {
+	switch s {
+	case TableShapeSquare, TableShapeRound, TableShapeRectangle:
+		return true
+	}
+	return false
+}
+
+// TableSession is a guest device's access to a table, issued when the guest scans the
+// table's QR code. It is scoped to the bill open at the table and ends when that bill
+// closes, the table's code is rotated or staff revoke it.
+type TableSession struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	BusinessID uint       `gorm:"index;not null" json:"business_id"`
+	TableID    uint       `gorm:"index;not null" json:"table_id"`
+	BillID     *uint      `gorm:"index" json:"bill_id"`
+	TokenHash  string     `gorm:"uniqueIndex;not null" json:"-"` // SHA-256 of the token the guest holds
+	DeviceID   string     `gorm:"index" json:"device_id"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	EndedAt    *time.Time `json:"ended_at"`
+	EndReason  string     `json:"end_reason,omitempty"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// AuthSession is a signed-in device of a user or a staff member. The device holds a
+// refresh token that it trades for short-lived access tokens; each trade rotates the
+// token, and revoking the session stops the trades and the access tokens issued for it.
+type AuthSession struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	Address           string     `gorm:"index" json:"address,omitempty"`
+	StaffID           *uint      `gorm:"index" json:"staff_id,omitempty"`
+	BusinessID        *uint      `gorm:"index" json:"business_id,omitempty"`
+	RefreshTokenHash  string     `gorm:"uniqueIndex;not null" json:"-"` // SHA-256 of the current refresh token
+	PreviousTokenHash string     `gorm:"index" json:"-"`                // SHA-256 of the token it was rotated from
+	DeviceName        string     `json:"device_name"`
+	UserAgent         string     `json:"user_agent"`
+	IPAddress         string     `json:"ip_address"`
+	ExpiresAt         time.Time  `json:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at"`
+	RevokeReason      string     `json:"revoke_reason,omitempty"`
+	LastUsedAt        time.Time  `json:"last_used_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// SignInChallenge is the challenge last handed out to an address for signing in, kept
+// in the database so that every replica can check it
+type SignInChallenge struct {
+	Address   string    `gorm:"primaryKey;size:64" json:"address"`
+	Value     string    `gorm:"not null" json:"-"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RateLimitWindow counts the requests made under one rate limit key in one fixed window
+type RateLimitWindow struct {
+	LimitKey    string    `gorm:"primaryKey;size:255" json:"limit_key"`
+	WindowStart time.Time `gorm:"primaryKey" json:"window_start"`
+	Count       int64     `gorm:"not null;default:0" json:"count"`
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
 }
 
 // Bill represents a bill/check for a table
 type Bill struct {
-	ID               uint       `gorm:"primaryKey" json:"id"`
-	BusinessID       uint       `gorm:"index;not null" json:"business_id"`
-	TableID          uint       `gorm:"index" json:"table_id"`
-	CounterID        *uint      `gorm:"index" json:"counter_id"`
-	BillNumber       string     `gorm:"uniqueIndex;not null" json:"bill_number"`
-	Notes            string     `gorm:"type:text" json:"notes"` // Order notes for kitchen/staff
-	Items            string     `gorm:"type:text" json:"items"` // JSON string for SQLite
-	Subtotal         float64    `json:"subtotal"`
-	TaxAmount        float64    `json:"tax_amount"`
-	ServiceFeeAmount float64    `json:"service_fee_amount"`
-	TotalAmount      float64    `json:"total_amount"`
-	PaidAmount       float64    `gorm:"default:0" json:"paid_amount"`
-	TipAmount        float64    `gorm:"default:0" json:"tip_amount"`
-	Status           BillStatus `gorm:"default:'open'" json:"status"`
-	SettlementAddr   string     `gorm:"not null" json:"settlement_address"`
-	TippingAddr      string     `gorm:"not null" json:"tipping_address"`
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
-	ClosedAt         *time.Time `json:"closed_at"`
-	Business         Business   `gorm:"foreignKey:BusinessID" json:"business,omitempty"`
-	Table            Table      `gorm:"foreignKey:TableID" json:"table,omitempty"`
-	Counter          *Counter   `gorm:"foreignKey:CounterID" json:"counter,omitempty"`
-	Payments         []Payment  `gorm:"foreignKey:BillID" json:"payments,omitempty"`
+	ID               uint         `gorm:"primaryKey" json:"id"`
+	BusinessID       uint         `gorm:"index;not null" json:"business_id"`
+	TableID          uint         `gorm:"index" json:"table_id"`
+	CounterID        *uint        `gorm:"index" json:"counter_id"`
+	BillNumber       string       `gorm:"uniqueIndex;not null" json:"bill_number"`
+	Notes            string       `gorm:"type:text" json:"notes"` // Order notes for kitchen/staff
+	Items            JSON         `json:"items"`                  // BillItem array
+	Subtotal         money.Amount `json:"subtotal"`
+	TaxAmount        money.Amount `json:"tax_amount"`
+	ServiceFeeAmount money.Amount `json:"service_fee_amount"`
+	TotalAmount      money.Amount `json:"total_amount"`
+	PaidAmount       money.Amount `gorm:"default:0" json:"paid_amount"`
+	TipAmount        money.Amount `gorm:"default:0" json:"tip_amount"`
+	Status           BillStatus   `gorm:"default:'open'" json:"status"`
+	SettlementAddr   string       `gorm:"not null" json:"settlement_address"`
+	TippingAddr      string       `gorm:"not null" json:"tipping_address"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+	ClosedAt         *time.Time   `json:"closed_at"`
+	Business         Business     `gorm:"foreignKey:BusinessID" json:"business,omitempty"`
+	Table            Table        `gorm:"foreignKey:TableID" json:"table,omitempty"`
+	Counter          *Counter     `gorm:"foreignKey:CounterID" json:"counter,omitempty"`
+	Payments         []Payment    `gorm:"foreignKey:BillID" json:"payments,omitempty"`
 }
 
 // BillItem represents an item on a bill
 type BillItem struct {
-	ID         string           `json:"id"`
-	MenuItemID string           `json:"menu_item_id"`
-	Name       string           `json:"name"`
-	Price      float64          `json:"price"`
-	Quantity   int              `json:"quantity"`
-	Options    []MenuItemOption `json:"options"`
-	Subtotal   float64          `json:"subtotal"`
+	ID         string                  `json:"id"`
+	MenuItemID string                  `json:"menu_item_id"`
+	Name       string                  `json:"name"`
+	Price      money.Amount            `json:"price"`
+	Quantity   int                     `json:"quantity"`
+	Options    []SelectedOption        `json:"options"`
+	Modifiers  []SelectedModifierGroup `json:"modifiers,omitempty"`
+	Subtotal   money.Amount            `json:"subtotal"`
 }
 
 // BillStatus represents the status of a bill
 type BillStatus string
 
 const (
-	BillStatusOpen   BillStatus = "open"
-	BillStatusPaid   BillStatus = "paid"
-	BillStatusClosed BillStatus = "closed"
+	BillStatusOpen          BillStatus = "open"
+	BillStatusPartiallyPaid BillStatus = "partially_paid"
+	BillStatusPaid          BillStatus = "paid"
+	BillStatusClosed        BillStatus = "closed"
+	BillStatusVoided        BillStatus = "voided"
+	BillStatusRefunded      BillStatus = "refunded"
 )
 
+// BillEvent is an audit record of a bill status transition
+type BillEvent struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	BillID     uint       `gorm:"index;not null" json:"bill_id"`
+	FromStatus BillStatus `json:"from_status"`
+	ToStatus   BillStatus `gorm:"not null" json:"to_status"`
+	Actor      string     `gorm:"not null" json:"actor"` // user address, or system/indexer/webhook:<provider>
+	Reason     string     `json:"reason"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// TableName method for BillEvent model
+func (BillEvent) TableName() string {
+	return "bill_events"
+}
+
 // Payment represents a payment made towards a bill
 type Payment struct {
 	ID        uint          `gorm:"primaryKey" json:"id"`
 	BillID    uint          `gorm:"index;not null" json:"bill_id"`
 	PayerAddr string        `gorm:"not null" json:"payer_address"`
-	Amount    float64       `gorm:"not null" json:"amount"`
-	TipAmount float64       `gorm:"default:0" json:"tip_amount"`
-	TxHash    string        `gorm:"uniqueIndex" json:"tx_hash"`
+	Amount    money.Amount  `gorm:"not null" json:"amount"`
+	TipAmount money.Amount  `gorm:"default:0" json:"tip_amount"`
+	TxHash    string        `gorm:"uniqueIndex:idx_payments_tx_log;uniqueIndex:idx_payments_tx_unindexed,where:log_index IS NULL;not null" json:"tx_hash"`
+	LogIndex  *uint         `gorm:"uniqueIndex:idx_payments_tx_log" json:"log_index,omitempty"` // Event log index; nil until the on-chain event is indexed; one such row per transaction
 	Status    PaymentStatus `gorm:"default:'pending'" json:"status"`
 	CreatedAt time.Time     `json:"created_at"`
 	UpdatedAt time.Time     `json:"updated_at"`
@@ -279,23 +497,24 @@ const (
 	PaymentStatusPending   PaymentStatus = "pending"
 	PaymentStatusConfirmed PaymentStatus = "confirmed"
 	PaymentStatusFailed    PaymentStatus = "failed"
+	PaymentStatusFlagged   PaymentStatus = "flagged" // Final on chain but its amounts are not whole cents; reconciled by staff, never counted
 )
 
 // WithdrawalHistory represents a business withdrawal/claim transaction
 type WithdrawalHistory struct {
-	ID                uint       `gorm:"primaryKey" json:"id"`
-	BusinessID        uint       `gorm:"index;not null" json:"business_id"`
-	TransactionHash   string     `gorm:"uniqueIndex;not null" json:"transaction_hash"`
-	PaymentAmount     float64    `gorm:"default:0" json:"payment_amount"`    // Amount from payment earnings
-	TipAmount         float64    `gorm:"default:0" json:"tip_amount"`        // Amount from tip earnings
-	TotalAmount       float64    `gorm:"not null" json:"total_amount"`       // Total withdrawn
-	WithdrawalAddress string     `gorm:"not null" json:"withdrawal_address"` // Address that received the funds
-	BlockchainNetwork string     `gorm:"not null" json:"blockchain_network"` // e.g., "base-sepolia", "ethereum"
-	Status            string     `gorm:"default:'pending'" json:"status"`    // pending, confirmed, failed
-	CreatedAt         time.Time  `json:"created_at"`
-	UpdatedAt         time.Time  `json:"updated_at"`
-	ConfirmedAt       *time.Time `json:"confirmed_at"`
-	Business          Business   `gorm:"foreignKey:BusinessID" json:"business,omitempty"`
+	ID                uint         `gorm:"primaryKey" json:"id"`
+	BusinessID        uint         `gorm:"index;not null" json:"business_id"`
+	TransactionHash   string       `gorm:"uniqueIndex;not null" json:"transaction_hash"`
+	PaymentAmount     money.Amount `gorm:"default:0" json:"payment_amount"`    // Amount from payment earnings
+	TipAmount         money.Amount `gorm:"default:0" json:"tip_amount"`        // Amount from tip earnings
+	TotalAmount       money.Amount `gorm:"not null" json:"total_amount"`       // Total withdrawn
+	WithdrawalAddress string       `gorm:"not null" json:"withdrawal_address"` // Address that received the funds
+	BlockchainNetwork string       `gorm:"not null" json:"blockchain_network"` // e.g., "base-sepolia", "ethereum"
+	Status            string       `gorm:"default:'pending'" json:"status"`    // pending, confirmed, failed
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+	ConfirmedAt       *time.Time   `json:"confirmed_at"`
+	Business          Business     `gorm:"foreignKey:BusinessID" json:"business,omitempty"`
 }
 
 // AlternativePayment represents a non-crypto payment (cash, card, etc.)
@@ -304,7 +523,8 @@ type AlternativePayment struct {
 	BillID          uint                     `gorm:"index;not null" json:"bill_id"`
 	ParticipantAddr string                   `gorm:"not null" json:"participant_address"`
 	ParticipantName string                   `json:"participant_name"` // Optional name for identification
-	Amount          float64                  `gorm:"not null" json:"amount"`
+	Amount          money.Amount             `gorm:"not null" json:"amount"`
+	TipAmount       money.Amount             `gorm:"default:0" json:"tip_amount"`
 	PaymentMethod   AlternativePaymentMethod `gorm:"not null" json:"payment_method"`
 	Status          AlternativePaymentStatus `gorm:"default:'pending'" json:"status"`
 	ConfirmedBy     string                   `json:"confirmed_by"` // Business owner who confirmed
@@ -335,11 +555,76 @@ const (
 
 // PaymentBreakdown represents the breakdown of crypto vs alternative payments
 type PaymentBreakdown struct {
-	TotalAmount     float64 `json:"total_amount"`
-	CryptoPaid      float64 `json:"crypto_paid"`
-	AlternativePaid float64 `json:"alternative_paid"`
-	Remaining       float64 `json:"remaining"`
-	IsComplete      bool    `json:"is_complete"`
+	TotalAmount     money.Amount `json:"total_amount"`
+	CryptoPaid      money.Amount `json:"crypto_paid"`
+	AlternativePaid money.Amount `json:"alternative_paid"`
+	Remaining       money.Amount `json:"remaining"`
+	IsComplete      bool         `json:"is_complete"`
+}
+
+// SplitSession records how the guests at a table agreed to split a bill
+type SplitSession struct {
+	ID        uint               `gorm:"primaryKey" json:"id"`
+	BillID    uint               `gorm:"index;not null" json:"bill_id"`
+	Method    string             `gorm:"not null" json:"method"` // equal, custom, items
+	Status    SplitSessionStatus `gorm:"default:'active'" json:"status"`
+	CreatedBy string             `json:"created_by"`
+	LockedAt  *time.Time         `json:"locked_at"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Shares    []SplitShare       `gorm:"foreignKey:SessionID" json:"shares"`
+}
+
+// SplitSessionStatus represents the status of a split session
+type SplitSessionStatus string
+
+const (
+	// SplitSessionActive sessions can still be replaced by a new split
+	SplitSessionActive SplitSessionStatus = "active"
+	// SplitSessionLocked sessions have received a payment and can no longer be replaced
+	SplitSessionLocked    SplitSessionStatus = "locked"
+	SplitSessionCompleted SplitSessionStatus = "completed"
+	SplitSessionCancelled SplitSessionStatus = "cancelled"
+)
+
+// SplitShare is one participant's share of a split session
+type SplitShare struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	SessionID  uint             `gorm:"index;not null" json:"session_id"`
+	BillID     uint             `gorm:"index;not null" json:"bill_id"`
+	PersonID   string           `gorm:"not null" json:"person_id"`
+	PersonName string           `json:"person_name"`
+	PayerAddr  string           `gorm:"index" json:"payer_address"` // Wallet the share is paid from, lowercased
+	Amount     money.Amount     `gorm:"not null" json:"amount"` // Share of the bill, excluding tip
+	TipAmount  money.Amount     `gorm:"default:0" json:"tip_amount"`
+	Subtotal   money.Amount     `json:"subtotal"`
+	TaxAmount  money.Amount     `json:"tax_amount"`
+	ServiceFee money.Amount     `json:"service_fee"`
+	Items      JSON             `json:"items"` // Claimed items
+	PaidAmount money.Amount     `gorm:"default:0" json:"paid_amount"`
+	PaidTip    money.Amount     `gorm:"default:0" json:"paid_tip"` // Tips paid with the share's payments
+	Status     SplitShareStatus `gorm:"default:'pending'" json:"status"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+}
+
+// SplitShareStatus represents the payment status of a split share
+type SplitShareStatus string
+
+const (
+	SplitSharePending       SplitShareStatus = "pending"
+	SplitSharePartiallyPaid SplitShareStatus = "partially_paid"
+	SplitSharePaid          SplitShareStatus = "paid"
+)
+
+// TableName method for SplitSession model
+func (SplitSession) TableName() string {
+	return "split_sessions"
+}
+
+// TableName method for SplitShare model
+func (SplitShare) TableName() string {
+	return "split_shares"
 }
 
 // Staff represents employees/workers of a business
@@ -437,10 +722,46 @@ func (Menu) TableName() string {
 	return "menus"
 }
 
+func (MenuCategory) TableName() string {
+	return "menu_categories"
+}
+
+func (MenuItem) TableName() string {
+	return "menu_items"
+}
+
+func (MenuItemOption) TableName() string {
+	return "menu_item_options"
+}
+
+func (MenuSchedule) TableName() string {
+	return "menu_schedules"
+}
+
+func (MenuItemSchedule) TableName() string {
+	return "menu_item_schedules"
+}
+
+func (MenuItemImage) TableName() string {
+	return "menu_item_images"
+}
+
+func (ModifierGroup) TableName() string {
+	return "menu_modifier_groups"
+}
+
+func (ModifierOption) TableName() string {
+	return "menu_modifier_options"
+}
+
 func (Table) TableName() string {
 	return "tables"
 }
 
+func (TableSession) TableName() string {
+	return "table_sessions"
+}
+
 func (Bill) TableName() string {
 	return "bills"
 }
@@ -554,14 +875,14 @@ const (
 
 // OrderStats represents order performance statistics
 type OrderStats struct {
-	BusinessID      uint    `json:"business_id"`
-	Date            string  `json:"date"`
-	TotalOrders     int     `json:"total_orders"`
-	CompletedOrders int     `json:"completed_orders"`
-	CancelledOrders int     `json:"cancelled_orders"`
-	AverageTime     float64 `json:"average_time"` // Average preparation time in minutes
-	PeakHour        string  `json:"peak_hour"`
-	TotalRevenue    float64 `json:"total_revenue"`
+	BusinessID      uint         `json:"business_id"`
+	Date            string       `json:"date"`
+	TotalOrders     int          `json:"total_orders"`
+	CompletedOrders int          `json:"completed_orders"`
+	CancelledOrders int          `json:"cancelled_orders"`
+	AverageTime     float64      `json:"average_time"` // Average preparation time in minutes
+	PeakHour        string       `json:"peak_hour"`
+	TotalRevenue    money.Amount `json:"total_revenue"`
 }
 
 // Order represents a small order within a bill (guest requests)
@@ -574,7 +895,7 @@ type Order struct {
 	CreatedBy   string      `json:"created_by"`             // "guest" or staff member address
 	ApprovedBy  string      `json:"approved_by"`            // staff member who approved
 	Notes       string      `json:"notes"`                  // Special instructions
-	Items       string      `gorm:"type:text" json:"items"` // JSON string for SQLite (OrderItem array)
+	Items       JSON        `json:"items"`       // OrderItem array
 	CreatedAt   time.Time   `json:"created_at"`
 	UpdatedAt   time.Time   `json:"updated_at"`
 	ApprovedAt  *time.Time  `json:"approved_at"`
@@ -586,13 +907,15 @@ type Order struct {
 
 // OrderItem represents an item within an order
 type OrderItem struct {
-	ID              string           `json:"id"`
-	MenuItemName    string           `json:"menu_item_name"`
-	Quantity        int              `json:"quantity"`
-	Price           float64          `json:"price"`
-	Options         []MenuItemOption `json:"options"`         // Add-ons/modifiers
-	SpecialRequests string           `json:"special_requests"`
-	Subtotal        float64          `json:"subtotal"`
+	ID              string                  `json:"id"`
+	MenuItemID      string                  `json:"menu_item_id"`
+	MenuItemName    string                  `json:"menu_item_name"`
+	Quantity        int                     `json:"quantity"`
+	Price           money.Amount            `json:"price"`
+	Options         []SelectedOption        `json:"options"` // Add-ons
+	Modifiers       []SelectedModifierGroup `json:"modifiers,omitempty"`
+	SpecialRequests string                  `json:"special_requests"`
+	Subtotal        money.Amount            `json:"subtotal"`
 }
 
 // OrderStatus represents the status of an order
@@ -612,6 +935,138 @@ func (Order) TableName() string {
 	return "orders"
 }
 
+// KitchenStation is a preparation area of a kitchen display system, such as the grill,
+// the bar or desserts
+type KitchenStation struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	BusinessID uint      `gorm:"index;not null" json:"business_id"`
+	Name       string    `gorm:"not null" json:"name"`
+	IsDefault  bool      `gorm:"default:false" json:"is_default"` // Receives items no route matches
+	IsActive   bool      `gorm:"default:true" json:"is_active"`
+	SortOrder  int       `gorm:"default:0" json:"sort_order"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// KitchenRoute sends the items of a menu category, or a single menu item, to a station.
+// Item routes take precedence over category routes.
+type KitchenRoute struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	BusinessID uint      `gorm:"index;not null" json:"business_id"`
+	StationID  uint      `gorm:"index;not null" json:"station_id"`
+	CategoryID uint      `gorm:"index" json:"category_id,omitempty"`  // Menu category whose items go to the station
+	MenuItemID uint      `gorm:"index" json:"menu_item_id,omitempty"` // Menu item that goes to the station, whatever its category
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// KitchenTicket is the part of an approved order prepared at one station
+type KitchenTicket struct {
+	ID          uint                `gorm:"primaryKey" json:"id"`
+	BusinessID  uint                `gorm:"index;not null" json:"business_id"`
+	OrderID     uint                `gorm:"index;not null" json:"order_id"`
+	BillID      uint                `gorm:"index;not null" json:"bill_id"`
+	StationID   uint                `gorm:"index;not null" json:"station_id"`
+	StationName string              `json:"station_name"`
+	OrderNumber string              `json:"order_number"`
+	Notes       string              `json:"notes"`
+	Items       JSON                `json:"items"` // The OrderItems made at this station
+	Status      KitchenTicketStatus `gorm:"index;not null;default:'pending'" json:"status"`
+	StartedAt   *time.Time          `json:"started_at"`
+	BumpedAt    *time.Time          `json:"bumped_at"`
+	BumpedBy    string              `json:"bumped_by"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+// KitchenTicketStatus represents the progress of a kitchen ticket
+type KitchenTicketStatus string
+
+const (
+	KitchenTicketPending    KitchenTicketStatus = "pending"     // Waiting to be started
+	KitchenTicketInProgress KitchenTicketStatus = "in_progress" // Being prepared
+	KitchenTicketDone       KitchenTicketStatus = "done"        // Bumped by the station
+	KitchenTicketCancelled  KitchenTicketStatus = "cancelled"   // Order was cancelled
+)
+
+// TableName method for KitchenStation model
+func (KitchenStation) TableName() string {
+	return "kitchen_stations"
+}
+
+// TableName method for KitchenRoute model
+func (KitchenRoute) TableName() string {
+	return "kitchen_routes"
+}
+
+// TableName method for KitchenTicket model
+func (KitchenTicket) TableName() string {
+	return "kitchen_tickets"
+}
+
+// StockItem is something a business keeps count of: a dish counted in portions or an
+// ingredient used by recipes. Quantities are whole numbers of Unit, so ingredients are
+// best counted in their smallest unit (grams, millilitres, pieces).
+type StockItem struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	BusinessID        uint       `gorm:"index;not null" json:"business_id"`
+	MenuItemID        *uint      `gorm:"index" json:"menu_item_id,omitempty"` // Set when counting portions of one menu item
+	Name              string     `gorm:"not null" json:"name"`
+	Unit              string     `gorm:"not null;default:'pcs'" json:"unit"`
+	Quantity          int64      `gorm:"not null;default:0" json:"quantity"`
+	LowStockThreshold int64      `gorm:"not null;default:0" json:"low_stock_threshold"` // Alert at or below this quantity
+	LowStockAlertedAt *time.Time `json:"low_stock_alerted_at"`                          // Cleared once restocked above the threshold
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// RecipeComponent is the quantity of a stock item used to make one of a menu item
+type RecipeComponent struct {
+	ID          uint  `gorm:"primaryKey" json:"id"`
+	MenuItemID  uint  `gorm:"index;not null" json:"menu_item_id"`
+	StockItemID uint  `gorm:"index;not null" json:"stock_item_id"`
+	Quantity    int64 `gorm:"not null" json:"quantity"`
+}
+
+// StockAdjustmentReason says why a stock item's quantity changed
+type StockAdjustmentReason string
+
+const (
+	StockReasonSale          StockAdjustmentReason = "sale"           // Used by an approved order
+	StockReasonSaleCancelled StockAdjustmentReason = "sale_cancelled" // Returned by a cancelled order
+	StockReasonRestock       StockAdjustmentReason = "restock"        // Delivery or production
+	StockReasonWaste         StockAdjustmentReason = "waste"          // Spoiled, dropped or comped
+	StockReasonCount         StockAdjustmentReason = "count"          // Correction after a stock take
+)
+
+// StockAdjustment is the audit record of one change to a stock item's quantity
+type StockAdjustment struct {
+	ID            uint                  `gorm:"primaryKey" json:"id"`
+	BusinessID    uint                  `gorm:"index;not null" json:"business_id"`
+	StockItemID   uint                  `gorm:"index;not null" json:"stock_item_id"`
+	OrderID       *uint                 `gorm:"index" json:"order_id,omitempty"`
+	Reason        StockAdjustmentReason `gorm:"index;not null" json:"reason"`
+	Change        int64                 `gorm:"not null" json:"change"`
+	QuantityAfter int64                 `gorm:"not null" json:"quantity_after"`
+	Note          string                `json:"note"`
+	CreatedBy     string                `json:"created_by"`
+	CreatedAt     time.Time             `gorm:"index" json:"created_at"`
+}
+
+// TableName method for StockItem model
+func (StockItem) TableName() string {
+	return "stock_items"
+}
+
+// TableName method for RecipeComponent model
+func (RecipeComponent) TableName() string {
+	return "menu_item_recipes"
+}
+
+// TableName method for StockAdjustment model
+func (StockAdjustment) TableName() string {
+	return "stock_adjustments"
+}
+
 // SubscriptionPayment represents a subscription renewal payment
 type SubscriptionPayment struct {
 	ID                uint      `gorm:"primaryKey" json:"id"`
@@ -632,3 +1087,44 @@ type SubscriptionPayment struct {
 func (SubscriptionPayment) TableName() string {
 	return "subscription_payments"
 }
+
+// IndexerCheckpoint records the last block a blockchain event indexer has fully processed
+type IndexerCheckpoint struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex;not null" json:"name"`
+	LastBlock uint64    `gorm:"not null" json:"last_block"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName method for IndexerCheckpoint model
+func (IndexerCheckpoint) TableName() string {
+	return "indexer_checkpoints"
+}
+
+// WebhookNonce records a webhook delivery nonce so a signed request cannot be replayed.
+// Rows are only needed until the request timestamp falls outside the tolerance window.
+type WebhookNonce struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Provider  string    `gorm:"uniqueIndex:idx_webhook_nonces_provider_nonce;not null" json:"provider"`
+	Nonce     string    `gorm:"uniqueIndex:idx_webhook_nonces_provider_nonce;not null" json:"nonce"`
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName method for WebhookNonce model
+func (WebhookNonce) TableName() string {
+	return "webhook_nonces"
+}
+
+// DataMigration records a one-off data migration that has already been applied
+type DataMigration struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Name      string    `gorm:"uniqueIndex;not null" json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// TableName method for DataMigration model
+func (DataMigration) TableName() string {
+	return "data_migrations"
+}
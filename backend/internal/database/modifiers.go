@@ -0,0 +1,327 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"payverge/internal/money"
+)
+
+// ErrInvalidModifierGroup is returned when a menu item's modifier groups are misconfigured
+var ErrInvalidModifierGroup = errors.New("invalid modifier group")
+
+// ModifierSelection is the options picked in one modifier group of a menu item. Groups
+// left out of a selection get their default options.
+type ModifierSelection struct {
+	GroupID   uint   `json:"group_id"`
+	OptionIDs []uint `json:"option_ids"`
+}
+
+// validateModifierGroups checks the selection limits and defaults of modifier groups and
+// of the groups nested under their options
+func validateModifierGroups(groups []ModifierGroup) error {
+	for _, group := range groups {
+		if group.Name == "" {
+			return fmt.Errorf("%w: a modifier group needs a name", ErrInvalidModifierGroup)
+		}
+		if len(group.Options) == 0 {
+			return fmt.Errorf("%w: %s has no options", ErrInvalidModifierGroup, group.Name)
+		}
+		if group.MinSelect < 0 || group.MaxSelect < 0 {
+			return fmt.Errorf("%w: %s has a negative selection limit", ErrInvalidModifierGroup, group.Name)
+		}
+		if group.MaxSelect > 0 && group.MinSelect > group.MaxSelect {
+			return fmt.Errorf("%w: %s requires more options than it allows", ErrInvalidModifierGroup, group.Name)
+		}
+		if group.MinSelect > len(group.Options) {
+			return fmt.Errorf("%w: %s requires more options than it has", ErrInvalidModifierGroup, group.Name)
+		}
+
+		defaults := 0
+		for _, option := range group.Options {
+			if option.Name == "" {
+				return fmt.Errorf("%w: an option of %s needs a name", ErrInvalidModifierGroup, group.Name)
+			}
+			if option.IsDefault {
+				defaults++
+			}
+			if err := validateModifierGroups(option.Groups); err != nil {
+				return err
+			}
+		}
+		if group.MaxSelect > 0 && defaults > group.MaxSelect {
+			return fmt.Errorf("%w: %s has more defaults than it allows", ErrInvalidModifierGroup, group.Name)
+		}
+	}
+	return nil
+}
+
+// saveModifierGroups makes the item's modifier groups its full tree of groups. Groups and
+// options sent with their ID keep it, so carts referring to them stay valid.
+func saveModifierGroups(tx *gorm.DB, item *MenuItem) error {
+	if err := validateModifierGroups(item.Modifiers); err != nil {
+		return err
+	}
+
+	var groupIDs, optionIDs []uint
+	if err := tx.Model(&ModifierGroup{}).Where("menu_item_id = ?", item.ID).Pluck("id", &groupIDs).Error; err != nil {
+		return fmt.Errorf("failed to get modifier groups: %w", err)
+	}
+	if err := tx.Model(&ModifierOption{}).Where("menu_item_id = ?", item.ID).Pluck("id", &optionIDs).Error; err != nil {
+		return fmt.Errorf("failed to get modifier options: %w", err)
+	}
+	ownedGroups := make(map[uint]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		ownedGroups[id] = true
+	}
+	ownedOptions := make(map[uint]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		ownedOptions[id] = true
+	}
+
+	keptGroups := make(map[uint]bool)
+	keptOptions := make(map[uint]bool)
+	var save func(groups []ModifierGroup, parentOptionID *uint) error
+	save = func(groups []ModifierGroup, parentOptionID *uint) error {
+		for i := range groups {
+			group := &groups[i]
+			group.MenuItemID = item.ID
+			group.ParentOptionID = parentOptionID
+			group.SortOrder = i
+			if ownedGroups[group.ID] && !keptGroups[group.ID] {
+				if err := tx.Model(group).Select("parent_option_id", "name", "min_select", "max_select", "sort_order").
+					Updates(group).Error; err != nil {
+					return fmt.Errorf("failed to update modifier group: %w", err)
+				}
+			} else {
+				group.ID = 0
+				if err := tx.Create(group).Error; err != nil {
+					return fmt.Errorf("failed to create modifier group: %w", err)
+				}
+			}
+			keptGroups[group.ID] = true
+
+			for j := range group.Options {
+				option := &group.Options[j]
+				option.GroupID = group.ID
+				option.MenuItemID = item.ID
+				option.SortOrder = j
+				if ownedOptions[option.ID] && !keptOptions[option.ID] {
+					if err := tx.Model(option).Select("group_id", "name", "price_change", "is_default", "is_available", "sort_order").
+						Updates(option).Error; err != nil {
+						return fmt.Errorf("failed to update modifier option: %w", err)
+					}
+				} else {
+					option.ID = 0
+					if err := tx.Create(option).Error; err != nil {
+						return fmt.Errorf("failed to create modifier option: %w", err)
+					}
+				}
+				keptOptions[option.ID] = true
+
+				if option.Groups == nil {
+					option.Groups = []ModifierGroup{}
+				}
+				optionID := option.ID
+				if err := save(option.Groups, &optionID); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if item.Modifiers == nil {
+		item.Modifiers = []ModifierGroup{}
+	}
+	if err := save(item.Modifiers, nil); err != nil {
+		return err
+	}
+
+	var removedOptions []uint
+	for _, id := range optionIDs {
+		if !keptOptions[id] {
+			removedOptions = append(removedOptions, id)
+		}
+	}
+	if len(removedOptions) > 0 {
+		if err := tx.Delete(&ModifierOption{}, removedOptions).Error; err != nil {
+			return fmt.Errorf("failed to delete modifier options: %w", err)
+		}
+	}
+
+	var removedGroups []uint
+	for _, id := range groupIDs {
+		if !keptGroups[id] {
+			removedGroups = append(removedGroups, id)
+		}
+	}
+	if len(removedGroups) > 0 {
+		if err := tx.Delete(&ModifierGroup{}, removedGroups).Error; err != nil {
+			return fmt.Errorf("failed to delete modifier groups: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadModifierGroups fills in the modifier group trees of menu items
+func loadModifierGroups(tx *gorm.DB, items []*MenuItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	itemIDs := make([]uint, len(items))
+	for i, item := range items {
+		itemIDs[i] = item.ID
+	}
+
+	var groups []ModifierGroup
+	if err := tx.Where("menu_item_id IN ?", itemIDs).Order("sort_order, id").Find(&groups).Error; err != nil {
+		return fmt.Errorf("failed to get modifier groups: %w", err)
+	}
+	var options []ModifierOption
+	if err := tx.Where("menu_item_id IN ?", itemIDs).Order("sort_order, id").Find(&options).Error; err != nil {
+		return fmt.Errorf("failed to get modifier options: %w", err)
+	}
+
+	optionsByGroup := make(map[uint][]ModifierOption)
+	for _, option := range options {
+		optionsByGroup[option.GroupID] = append(optionsByGroup[option.GroupID], option)
+	}
+	topGroups := make(map[uint][]ModifierGroup)
+	nestedGroups := make(map[uint][]ModifierGroup)
+	for _, group := range groups {
+		if group.ParentOptionID == nil {
+			topGroups[group.MenuItemID] = append(topGroups[group.MenuItemID], group)
+		} else {
+			nestedGroups[*group.ParentOptionID] = append(nestedGroups[*group.ParentOptionID], group)
+		}
+	}
+
+	var build func(group ModifierGroup) ModifierGroup
+	build = func(group ModifierGroup) ModifierGroup {
+		group.Options = []ModifierOption{}
+		for _, option := range optionsByGroup[group.ID] {
+			option.Groups = []ModifierGroup{}
+			for _, nested := range nestedGroups[option.ID] {
+				option.Groups = append(option.Groups, build(nested))
+			}
+			group.Options = append(group.Options, option)
+		}
+		return group
+	}
+
+	for _, item := range items {
+		item.Modifiers = []ModifierGroup{}
+		for _, group := range topGroups[item.ID] {
+			item.Modifiers = append(item.Modifiers, build(group))
+		}
+	}
+	return nil
+}
+
+// deleteModifierGroups removes the modifier groups and options of menu items
+func deleteModifierGroups(tx *gorm.DB, itemIDs []uint) error {
+	if err := tx.Where("menu_item_id IN ?", itemIDs).Delete(&ModifierOption{}).Error; err != nil {
+		return fmt.Errorf("failed to delete modifier options: %w", err)
+	}
+	if err := tx.Where("menu_item_id IN ?", itemIDs).Delete(&ModifierGroup{}).Error; err != nil {
+		return fmt.Errorf("failed to delete modifier groups: %w", err)
+	}
+	return nil
+}
+
+// resolveModifiers checks modifier choices against an item's groups and returns their
+// snapshot. Nested groups are only offered under a chosen
+// option; choices for groups that are not offered are rejected.
+func resolveModifiers(item MenuItem, selections []ModifierSelection) ([]SelectedModifierGroup, error) {
+	chosen := make(map[uint][]uint, len(selections))
+	for _, selection := range selections {
+		if _, ok := chosen[selection.GroupID]; ok {
+			return nil, fmt.Errorf("%w: modifier group %d chosen twice for %s", ErrInvalidMenuSelection, selection.GroupID, item.Name)
+		}
+		chosen[selection.GroupID] = selection.OptionIDs
+	}
+
+	offered := make(map[uint]bool)
+	var resolve func(groups []ModifierGroup) ([]SelectedModifierGroup, error)
+	resolve = func(groups []ModifierGroup) ([]SelectedModifierGroup, error) {
+		var result []SelectedModifierGroup
+		for _, group := range groups {
+			offered[group.ID] = true
+
+			picked := make(map[uint]bool)
+			if optionIDs, ok := chosen[group.ID]; ok {
+				for _, optionID := range optionIDs {
+					if picked[optionID] {
+						return nil, fmt.Errorf("%w: option %d chosen twice in %s", ErrInvalidMenuSelection, optionID, group.Name)
+					}
+					picked[optionID] = true
+				}
+			} else {
+				for _, option := range group.Options {
+					if option.IsDefault && option.IsAvailable {
+						picked[option.ID] = true
+					}
+				}
+			}
+
+			selected := SelectedModifierGroup{GroupID: group.ID, Name: group.Name, Options: []SelectedModifier{}}
+			for _, option := range group.Options {
+				if !picked[option.ID] {
+					continue
+				}
+				delete(picked, option.ID)
+				if !option.IsAvailable {
+					return nil, fmt.Errorf("%w: %s is not available", ErrInvalidMenuSelection, option.Name)
+				}
+				nested, err := resolve(option.Groups)
+				if err != nil {
+					return nil, err
+				}
+				selected.Options = append(selected.Options, SelectedModifier{
+					OptionID:    option.ID,
+					Name:        option.Name,
+					PriceChange: option.PriceChange,
+					Groups:      nested,
+				})
+			}
+			for optionID := range picked {
+				return nil, fmt.Errorf("%w: option %d not found in %s", ErrInvalidMenuSelection, optionID, group.Name)
+			}
+
+			count := len(selected.Options)
+			if count < group.MinSelect {
+				return nil, fmt.Errorf("%w: choose at least %d of %s", ErrInvalidMenuSelection, group.MinSelect, group.Name)
+			}
+			if group.MaxSelect > 0 && count > group.MaxSelect {
+				return nil, fmt.Errorf("%w: choose at most %d of %s", ErrInvalidMenuSelection, group.MaxSelect, group.Name)
+			}
+			if count > 0 {
+				result = append(result, selected)
+			}
+		}
+		return result, nil
+	}
+
+	groups, err := resolve(item.Modifiers)
+	if err != nil {
+		return nil, err
+	}
+	for groupID := range chosen {
+		if !offered[groupID] {
+			return nil, fmt.Errorf("%w: modifier group %d is not offered for %s", ErrInvalidMenuSelection, groupID, item.Name)
+		}
+	}
+	return groups, nil
+}
+
+// modifiersPrice is the total price change of chosen modifiers, nested ones included
+func modifiersPrice(groups []SelectedModifierGroup) money.Amount {
+	var total money.Amount
+	for _, group := range groups {
+		for _, option := range group.Options {
+			total += option.PriceChange + modifiersPrice(option.Groups)
+		}
+	}
+	return total
+}
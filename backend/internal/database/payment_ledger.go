@@ -0,0 +1,278 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"payverge/internal/money"
+
+	"gorm.io/gorm"
+)
+
+// PaymentTotals holds the confirmed amounts recorded against a bill
+type PaymentTotals struct {
+	CryptoAmount      money.Amount `json:"crypto_amount"`
+	CryptoTips        money.Amount `json:"crypto_tips"`
+	AlternativeAmount money.Amount `json:"alternative_amount"`
+	AlternativeTips   money.Amount `json:"alternative_tips"`
+}
+
+// PaidAmount returns the total confirmed amount paid towards the bill, excluding tips
+func (t PaymentTotals) PaidAmount() money.Amount {
+	return t.CryptoAmount + t.AlternativeAmount
+}
+
+// TipAmount returns the total confirmed tips
+func (t PaymentTotals) TipAmount() money.Amount {
+	return t.CryptoTips + t.AlternativeTips
+}
+
+// errPaymentInsertConflict marks an insert that lost a race with a concurrent report
+// of the same transaction
+var errPaymentInsertConflict = errors.New("payment insert rejected")
+
+// RecordPayment is the single ingestion path for crypto payments. The payment is
+// upserted by transaction hash and log index and the bill's paid amount, tip amount
+// and status are then derived from the ledger. Recording a payment that is already
+// known is a no-op; the returned flag reports whether anything changed. The actor is
+// recorded against any status transition the payment causes.
+func RecordPayment(payment *Payment, actor string) (*Bill, bool, error) {
+	bill, changed, err := recordPayment(payment, actor)
+	if errors.Is(err, errPaymentInsertConflict) {
+		// The unique indexes rejected a row another report inserted meanwhile; the
+		// retry finds that row and merges into it
+		bill, changed, err = recordPayment(payment, actor)
+	}
+	return bill, changed, err
+}
+
+// recordPayment upserts the payment and updates its bill in one transaction
+func recordPayment(payment *Payment, actor string) (*Bill, bool, error) {
+	payment.TxHash = normalizeTxHash(payment.TxHash)
+	if payment.TxHash == "" {
+		return nil, false, fmt.Errorf("transaction hash is required")
+	}
+	if payment.Status == "" {
+		payment.Status = PaymentStatusPending
+	}
+
+	var bill Bill
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&bill, payment.BillID).Error; err != nil {
+			return fmt.Errorf("failed to get bill: %w", err)
+		}
+
+		existing, err := findLedgerPayment(tx, payment)
+		if err != nil {
+			return err
+		}
+
+		if existing == nil {
+			if err := tx.Create(payment).Error; err != nil {
+				return fmt.Errorf("failed to create payment: %w: %v", errPaymentInsertConflict, err)
+			}
+			changed = true
+		} else {
+			if existing.BillID != payment.BillID {
+				return fmt.Errorf("transaction %s is already recorded for bill %d", payment.TxHash, existing.BillID)
+			}
+			changed = mergePayment(existing, payment)
+			if changed {
+				if err := tx.Save(existing).Error; err != nil {
+					return fmt.Errorf("failed to update payment: %w", err)
+				}
+			}
+			*payment = *existing
+		}
+
+		if !changed {
+			return nil
+		}
+		return recalculateBillPayments(tx, &bill, actor)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if changed {
+		notifyTableStatus(bill.BusinessID, bill.TableID)
+	}
+	return &bill, changed, nil
+}
+
+// RecalculateBillPayments re-derives a bill's paid amount, tip amount and status
+// from its confirmed payments and alternative payments
+func RecalculateBillPayments(billID uint, actor string) (*Bill, error) {
+	var bill Bill
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&bill, billID).Error; err != nil {
+			return fmt.Errorf("failed to get bill: %w", err)
+		}
+		return recalculateBillPayments(tx, &bill, actor)
+	})
+	if err != nil {
+		return nil, err
+	}
+	notifyTableStatus(bill.BusinessID, bill.TableID)
+	return &bill, nil
+}
+
+// ExpirePendingPayments marks payments that have been pending since before the given
+// time as failed: a reported payment the indexer never confirmed was dropped from the
+// chain. Pending payments never count towards a bill, so no bill changes. An indexed
+// event arriving later still confirms the payment.
+func ExpirePendingPayments(before time.Time) (int64, error) {
+	result := db.Model(&Payment{}).
+		Where("status = ? AND updated_at < ?", PaymentStatusPending, before).
+		Update("status", PaymentStatusFailed)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to expire pending payments: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetBillPaymentTotals sums the confirmed payments recorded against a bill
+func GetBillPaymentTotals(billID uint) (*PaymentTotals, error) {
+	return billPaymentTotals(db, billID)
+}
+
+// findLedgerPayment looks up the ledger row a payment belongs to. Reports that do
+// not know the log index (client reports) match any row for the transaction; indexed
+// events also claim a row that was recorded for the same bill from such a report.
+func findLedgerPayment(tx *gorm.DB, payment *Payment) (*Payment, error) {
+	var existing Payment
+	var err error
+
+	if payment.LogIndex != nil {
+		err = tx.Where("tx_hash = ? AND log_index = ?", payment.TxHash, *payment.LogIndex).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = tx.Where("tx_hash = ? AND log_index IS NULL AND bill_id = ?", payment.TxHash, payment.BillID).First(&existing).Error
+		}
+	} else {
+		err = tx.Where("tx_hash = ?", payment.TxHash).Order("id").First(&existing).Error
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up payment: %w", err)
+	}
+	return &existing, nil
+}
+
+// mergePayment applies an incoming report to an existing ledger row and reports
+// whether the row changed. Indexed events are authoritative for amounts; statuses
+// only move forward from pending.
+func mergePayment(existing, incoming *Payment) bool {
+	changed := false
+
+	if existing.LogIndex == nil && incoming.LogIndex != nil {
+		logIndex := *incoming.LogIndex
+		existing.LogIndex = &logIndex
+		existing.Amount = incoming.Amount
+		existing.TipAmount = incoming.TipAmount
+		if incoming.PayerAddr != "" {
+			existing.PayerAddr = incoming.PayerAddr
+		}
+		changed = true
+	}
+
+	if existing.Status == PaymentStatusPending && incoming.Status != PaymentStatusPending {
+		existing.Status = incoming.Status
+		changed = true
+	}
+
+	// A pending payment that expired before the indexer reached its block is revived
+	// by the indexed event
+	if existing.Status == PaymentStatusFailed && incoming.LogIndex != nil &&
+		(incoming.Status == PaymentStatusConfirmed || incoming.Status == PaymentStatusFlagged) {
+		existing.Status = incoming.Status
+		changed = true
+	}
+
+	return changed
+}
+
+// recalculateBillPayments updates the bill from the ledger within a transaction.
+// Active bills move between open, partially paid and paid as payments are covered;
+// bills that have left the active states keep their status.
+func recalculateBillPayments(tx *gorm.DB, bill *Bill, actor string) error {
+	totals, err := billPaymentTotals(tx, bill.ID)
+	if err != nil {
+		return err
+	}
+
+	bill.PaidAmount = totals.PaidAmount()
+	bill.TipAmount = totals.TipAmount()
+	if err := tx.Model(&Bill{}).Where("id = ?", bill.ID).Updates(map[string]interface{}{
+		"paid_amount": bill.PaidAmount,
+		"tip_amount":  bill.TipAmount,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update bill totals: %w", err)
+	}
+
+	if _, err := refreshSplitSession(tx, bill.ID); err != nil {
+		return err
+	}
+
+	if !bill.Status.IsActive() {
+		return nil
+	}
+	return transitionBill(tx, bill, ledgerBillStatus(bill), actor, "payment ledger updated")
+}
+
+// billPaymentTotals sums confirmed crypto and alternative payments for a bill
+func billPaymentTotals(conn *gorm.DB, billID uint) (*PaymentTotals, error) {
+	var crypto struct {
+		Amount money.Amount
+		Tips   money.Amount
+	}
+	if err := conn.Model(&Payment{}).
+		Select("COALESCE(SUM(amount), 0) AS amount, COALESCE(SUM(tip_amount), 0) AS tips").
+		Where("bill_id = ? AND status = ?", billID, PaymentStatusConfirmed).
+		Scan(&crypto).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum payments: %w", err)
+	}
+
+	var alternative struct {
+		Amount money.Amount
+		Tips   money.Amount
+	}
+	if err := conn.Model(&AlternativePayment{}).
+		Select("COALESCE(SUM(amount), 0) AS amount, COALESCE(SUM(tip_amount), 0) AS tips").
+		Where("bill_id = ? AND status = ?", billID, AltPaymentStatusConfirmed).
+		Scan(&alternative).Error; err != nil {
+		return nil, fmt.Errorf("failed to sum alternative payments: %w", err)
+	}
+
+	return &PaymentTotals{
+		CryptoAmount:      crypto.Amount,
+		CryptoTips:        crypto.Tips,
+		AlternativeAmount: alternative.Amount,
+		AlternativeTips:   alternative.Tips,
+	}, nil
+}
+
+// normalizeTxHash canonicalizes a transaction hash so reports from different sources match
+func normalizeTxHash(txHash string) string {
+	return strings.ToLower(strings.TrimSpace(txHash))
+}
+
+// RecordPayment records a crypto payment through the ledger
+func (d *DB) RecordPayment(payment *Payment, actor string) (*Bill, bool, error) {
+	return RecordPayment(payment, actor)
+}
+
+// RecalculateBillPayments re-derives a bill's totals from the ledger
+func (d *DB) RecalculateBillPayments(billID uint, actor string) (*Bill, error) {
+	return RecalculateBillPayments(billID, actor)
+}
+
+// ExpirePendingPayments marks payments pending since before the given time as failed
+func (d *DB) ExpirePendingPayments(before time.Time) (int64, error) {
+	return ExpirePendingPayments(before)
+}
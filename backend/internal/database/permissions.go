@@ -0,0 +1,158 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ErrAccessDenied is returned when a caller is neither the owner nor active staff of a business
+var ErrAccessDenied = errors.New("access denied")
+
+// Permission is an action on a business that a route requires
+type Permission string
+
+const (
+	PermissionBusinessRead   Permission = "business:read"
+	PermissionBusinessWrite  Permission = "business:write" // Settings, payout addresses, deletion
+	PermissionFinancialRead  Permission = "financial:read" // Sales analytics, reports, withdrawals, subscription payments
+	PermissionFinancialWrite Permission = "financial:write"
+	PermissionStaffManage    Permission = "staff:manage"
+	PermissionMenuRead       Permission = "menu:read"
+	PermissionMenuWrite      Permission = "menu:write"
+	PermissionTablesRead     Permission = "tables:read"
+	PermissionTablesWrite    Permission = "tables:write" // Create tables and counters, codes, floor plan
+	PermissionTablesSeat     Permission = "tables:seat"  // Seat guests, clear tables, sign guests out
+	PermissionBillsRead      Permission = "bills:read"
+	PermissionBillsWrite     Permission = "bills:write" // Open bills, change items, move, merge and split
+	PermissionBillsClose     Permission = "bills:close" // Record payments taken by staff and close bills
+	PermissionBillsVoid      Permission = "bills:void"  // Void and refund bills
+	PermissionOrdersRead     Permission = "orders:read"
+	PermissionOrdersWrite    Permission = "orders:write"
+	PermissionOrdersUpdate   Permission = "orders:update"
+	PermissionKitchenRead    Permission = "kitchen:read"
+	PermissionKitchenWrite   Permission = "kitchen:write" // Stations and routing
+	PermissionKitchenUpdate  Permission = "kitchen:update"
+	PermissionInventoryRead  Permission = "inventory:read"
+	PermissionInventoryWrite Permission = "inventory:write"
+)
+
+// rolePermissions lists what each staff role may do. Owners may do everything;
+// business settings, money and staff stay with them.
+var rolePermissions = map[StaffRole][]Permission{
+	StaffRoleManager: {
+		PermissionBusinessRead,
+		PermissionMenuRead, PermissionMenuWrite,
+		PermissionTablesRead, PermissionTablesWrite, PermissionTablesSeat,
+		PermissionBillsRead, PermissionBillsWrite, PermissionBillsClose, PermissionBillsVoid,
+		PermissionOrdersRead, PermissionOrdersWrite, PermissionOrdersUpdate,
+		PermissionKitchenRead, PermissionKitchenWrite, PermissionKitchenUpdate,
+		PermissionInventoryRead, PermissionInventoryWrite,
+	},
+	StaffRoleServer: {
+		PermissionBusinessRead,
+		PermissionMenuRead,
+		PermissionTablesRead, PermissionTablesSeat,
+		PermissionBillsRead, PermissionBillsWrite, PermissionBillsClose,
+		PermissionOrdersRead, PermissionOrdersWrite, PermissionOrdersUpdate,
+		PermissionKitchenRead,
+	},
+	StaffRoleHost: {
+		PermissionBusinessRead,
+		PermissionMenuRead,
+		PermissionTablesRead, PermissionTablesSeat,
+	},
+	StaffRoleKitchen: {
+		PermissionBusinessRead,
+		PermissionMenuRead,
+		PermissionOrdersRead, PermissionOrdersUpdate,
+		PermissionKitchenRead, PermissionKitchenUpdate,
+		PermissionInventoryRead,
+	},
+}
+
+// IsValid reports whether r is a known staff role
+func (r StaffRole) IsValid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Can reports whether staff with role r have the permission
+func (r StaffRole) Can(permission Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Permissions lists the permissions of role r
+func (r StaffRole) Permissions() []Permission {
+	return append([]Permission(nil), rolePermissions[r]...)
+}
+
+// BusinessAccess is what an authenticated caller may do on a business: everything as
+// its owner, or what their role allows as one of its active staff
+type BusinessAccess struct {
+	Business *Business
+	Staff    *Staff // Nil for the owner
+}
+
+// IsOwner reports whether the caller owns the business
+func (a *BusinessAccess) IsOwner() bool {
+	return a.Staff == nil
+}
+
+// Can reports whether the caller has the permission
+func (a *BusinessAccess) Can(permission Permission) bool {
+	return a.IsOwner() || a.Staff.Role.Can(permission)
+}
+
+// Actor identifies the caller in audit trails such as bill events and stock adjustments
+func (a *BusinessAccess) Actor() string {
+	if a.IsOwner() {
+		return a.Business.OwnerAddress
+	}
+	return fmt.Sprintf("staff:%d", a.Staff.ID)
+}
+
+// GetBusinessAccess resolves the access of a caller identified by a wallet address, a
+// staff ID or both. Staff are looked up on every call so that deactivated staff and
+// role changes take effect without waiting for their token to expire.
+func GetBusinessAccess(businessID uint, address string, staffID uint) (*BusinessAccess, error) {
+	business, err := GetBusinessByID(businessID)
+	if err != nil {
+		return nil, err
+	}
+
+	if address != "" && business.OwnerAddress == address {
+		return &BusinessAccess{Business: business}, nil
+	}
+
+	if staffID == 0 {
+		return nil, ErrAccessDenied
+	}
+	var staff Staff
+	err = db.Where("id = ? AND business_id = ? AND is_active = ?", staffID, businessID, true).First(&staff).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAccessDenied
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get staff: %w", err)
+	}
+	return &BusinessAccess{Business: business, Staff: &staff}, nil
+}
+
+// GetBillBusinessID returns the ID of the business a bill belongs to
+func GetBillBusinessID(billID uint) (uint, error) {
+	var bill Bill
+	if err := db.Select("id", "business_id").First(&bill, billID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrBillNotFound
+		}
+		return 0, fmt.Errorf("failed to get bill: %w", err)
+	}
+	return bill.BusinessID, nil
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"payverge/internal/money"
+)
+
+// ErrInvalidMenuSelection is returned when an order or bill refers to menu items or
+// options that cannot be ordered
+var ErrInvalidMenuSelection = errors.New("invalid menu selection")
+
+// MenuSelection is a menu item picked by a guest or staff member, with the IDs of the
+// options and modifiers chosen for it. Prices are never taken from the client; they are
+// resolved from the menu the business is serving.
+type MenuSelection struct {
+	MenuItemID      uint                `json:"menu_item_id"`
+	OptionIDs       []uint              `json:"option_ids"`
+	Modifiers       []ModifierSelection `json:"modifiers"`
+	Quantity        int                 `json:"quantity"`
+	SpecialRequests string              `json:"special_requests"`
+}
+
+// pricedSelection is a selection resolved against the menu
+type pricedSelection struct {
+	item      MenuItem
+	options   []SelectedOption
+	modifiers []SelectedModifierGroup
+	quantity  int
+	subtotal  money.Amount
+}
+
+// UnitPrice is the price of one item with its selected options and modifiers
+func UnitPrice(price money.Amount, options []SelectedOption, modifiers []SelectedModifierGroup) money.Amount {
+	for _, option := range options {
+		price += option.PriceChange
+	}
+	return price + modifiersPrice(modifiers)
+}
+
+// PriceOrderItems resolves selections against the menu a business is serving into order items
+// carrying a snapshot of the menu's names and prices
+func PriceOrderItems(businessID uint, selections []MenuSelection) ([]OrderItem, error) {
+	priced, err := priceSelections(businessID, selections)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	items := make([]OrderItem, len(priced))
+	for i, p := range priced {
+		items[i] = OrderItem{
+			ID:              fmt.Sprintf("%d-%d", p.item.ID, now+int64(i)),
+			MenuItemID:      strconv.FormatUint(uint64(p.item.ID), 10),
+			MenuItemName:    p.item.Name,
+			Quantity:        p.quantity,
+			Price:           p.item.Price,
+			Options:         p.options,
+			Modifiers:       p.modifiers,
+			SpecialRequests: selections[i].SpecialRequests,
+			Subtotal:        p.subtotal,
+		}
+	}
+	return items, nil
+}
+
+// PriceBillItems resolves selections against the menu a business is serving into bill items
+// carrying a snapshot of the menu's names and prices
+func PriceBillItems(businessID uint, selections []MenuSelection) ([]BillItem, error) {
+	priced, err := priceSelections(businessID, selections)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano()
+	items := make([]BillItem, len(priced))
+	for i, p := range priced {
+		items[i] = BillItem{
+			ID:         fmt.Sprintf("item_%d", now+int64(i)),
+			MenuItemID: strconv.FormatUint(uint64(p.item.ID), 10),
+			Name:       p.item.Name,
+			Price:      p.item.Price,
+			Quantity:   p.quantity,
+			Options:    p.options,
+			Modifiers:  p.modifiers,
+			Subtotal:   p.subtotal,
+		}
+	}
+	return items, nil
+}
+
+// priceSelections looks up every selection on the menu served right now. Items that are
+// unavailable, sold out or outside their schedules, unknown or repeated options, missing
+// required options, modifier choices outside a group's limits and quantities needing
+// more stock than is left are rejected.
+func priceSelections(businessID uint, selections []MenuSelection) ([]pricedSelection, error) {
+	if len(selections) == 0 {
+		return nil, nil
+	}
+
+	_, categories, err := GetMenuAt(businessID, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: no menu is being served", ErrInvalidMenuSelection)
+	}
+	menuItems := make(map[uint]MenuItem)
+	for _, category := range categories {
+		for _, item := range category.Items {
+			menuItems[item.ID] = item
+		}
+	}
+
+	priced := make([]pricedSelection, len(selections))
+	for i, selection := range selections {
+		item, ok := menuItems[selection.MenuItemID]
+		if !ok {
+			return nil, fmt.Errorf("%w: menu item %d not found", ErrInvalidMenuSelection, selection.MenuItemID)
+		}
+		if !item.IsAvailable {
+			return nil, fmt.Errorf("%w: %s is not available", ErrInvalidMenuSelection, item.Name)
+		}
+		if selection.Quantity < 1 {
+			return nil, fmt.Errorf("%w: quantity of %s must be at least 1", ErrInvalidMenuSelection, item.Name)
+		}
+
+		chosen := make(map[uint]bool, len(selection.OptionIDs))
+		for _, optionID := range selection.OptionIDs {
+			if chosen[optionID] {
+				return nil, fmt.Errorf("%w: option %d chosen twice for %s", ErrInvalidMenuSelection, optionID, item.Name)
+			}
+			chosen[optionID] = true
+		}
+
+		// Keep the menu's option order so snapshots do not depend on the client
+		options := []SelectedOption{}
+		for _, option := range item.Options {
+			if chosen[option.ID] {
+				options = append(options, SelectedOption{
+					ID:          strconv.FormatUint(uint64(option.ID), 10),
+					Name:        option.Name,
+					PriceChange: option.PriceChange,
+					IsRequired:  option.IsRequired,
+				})
+				delete(chosen, option.ID)
+			} else if option.IsRequired {
+				return nil, fmt.Errorf("%w: %s requires %s", ErrInvalidMenuSelection, item.Name, option.Name)
+			}
+		}
+		for optionID := range chosen {
+			return nil, fmt.Errorf("%w: option %d not found for %s", ErrInvalidMenuSelection, optionID, item.Name)
+		}
+
+		modifiers, err := resolveModifiers(item, selection.Modifiers)
+		if err != nil {
+			return nil, err
+		}
+
+		priced[i] = pricedSelection{
+			item:      item,
+			options:   options,
+			modifiers: modifiers,
+			quantity:  selection.Quantity,
+			subtotal:  UnitPrice(item.Price, options, modifiers).Mul(selection.Quantity),
+		}
+	}
+	if err := checkStock(db, priced); err != nil {
+		return nil, err
+	}
+	return priced, nil
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidSchedule is returned when a menu or menu item schedule is malformed
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// parseClock returns the minutes since midnight of an "HH:MM" time. "24:00" is only
+// accepted as the end of a window.
+func parseClock(clock string, isEnd bool) (int, error) {
+	if len(clock) != 5 || clock[2] != ':' {
+		return 0, fmt.Errorf("%w: %q is not an HH:MM time", ErrInvalidSchedule, clock)
+	}
+	for _, i := range []int{0, 1, 3, 4} {
+		if clock[i] < '0' || clock[i] > '9' {
+			return 0, fmt.Errorf("%w: %q is not an HH:MM time", ErrInvalidSchedule, clock)
+		}
+	}
+	hours := int(clock[0]-'0')*10 + int(clock[1]-'0')
+	minutes := int(clock[3]-'0')*10 + int(clock[4]-'0')
+	if isEnd && hours == 24 && minutes == 0 {
+		return 24 * 60, nil
+	}
+	if hours > 23 || minutes > 59 {
+		return 0, fmt.Errorf("%w: %q is not a time of day", ErrInvalidSchedule, clock)
+	}
+	return hours*60 + minutes, nil
+}
+
+// validate checks the day and times of a schedule window
+func (w ScheduleWindow) validate() error {
+	if w.DayOfWeek < 0 || w.DayOfWeek > 6 {
+		return fmt.Errorf("%w: day of week must be between 0 (Sunday) and 6", ErrInvalidSchedule)
+	}
+	if _, err := parseClock(w.StartTime, false); err != nil {
+		return err
+	}
+	_, err := parseClock(w.EndTime, true)
+	return err
+}
+
+// contains reports whether a time, already in the business's time zone, falls inside
+// the window. Windows are validated before they are stored.
+func (w ScheduleWindow) contains(local time.Time) bool {
+	start, _ := parseClock(w.StartTime, false)
+	end, _ := parseClock(w.EndTime, true)
+	minute := local.Hour()*60 + local.Minute()
+	day := int(local.Weekday())
+
+	if start < end {
+		return day == w.DayOfWeek && minute >= start && minute < end
+	}
+	return (day == w.DayOfWeek && minute >= start) || (day == (w.DayOfWeek+1)%7 && minute < end)
+}
+
+// menuServedAt picks the menu served at a local time: the first scheduled menu with a
+// window containing it or, failing that, the first menu without schedules
+func menuServedAt(menus []Menu, local time.Time) *Menu {
+	var fallback *Menu
+	for i := range menus {
+		menu := &menus[i]
+		if len(menu.Schedules) == 0 {
+			if fallback == nil {
+				fallback = menu
+			}
+			continue
+		}
+		for _, schedule := range menu.Schedules {
+			if schedule.contains(local) {
+				return menu
+			}
+		}
+	}
+	return fallback
+}
+
+// itemAvailableAt reports whether a menu item can be ordered at a time: it must be
+// marked available, not sold out, and inside one of its own schedules if it has any
+func itemAvailableAt(item MenuItem, at time.Time, loc *time.Location) bool {
+	if !item.IsAvailable {
+		return false
+	}
+	if item.SoldOutUntil != nil && at.Before(*item.SoldOutUntil) {
+		return false
+	}
+	if len(item.Schedules) == 0 {
+		return true
+	}
+	local := at.In(loc)
+	for _, schedule := range item.Schedules {
+		if schedule.contains(local) {
+			return true
+		}
+	}
+	return false
+}
+
+// businessLocation returns the time zone of a business, UTC when it has none or an
+// unknown one
+func businessLocation(tx *gorm.DB, businessID uint) (*time.Location, error) {
+	var zones []string
+	if err := tx.Model(&Business{}).Where("id = ?", businessID).Pluck("timezone", &zones).Error; err != nil {
+		return nil, fmt.Errorf("failed to get business time zone: %w", err)
+	}
+	if len(zones) == 0 || zones[0] == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(zones[0])
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// EndOfBusinessDay returns the next midnight after a time in the business's time zone
+func EndOfBusinessDay(businessID uint, at time.Time) (time.Time, error) {
+	loc, err := businessLocation(db, businessID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	local := at.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc), nil
+}
+
+// saveMenuSchedules replaces the schedules of a menu
+func saveMenuSchedules(tx *gorm.DB, menu *Menu) error {
+	for _, schedule := range menu.Schedules {
+		if err := schedule.validate(); err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("menu_id = ?", menu.ID).Delete(&MenuSchedule{}).Error; err != nil {
+		return fmt.Errorf("failed to delete menu schedules: %w", err)
+	}
+	if menu.Schedules == nil {
+		menu.Schedules = []MenuSchedule{}
+	}
+	for i := range menu.Schedules {
+		menu.Schedules[i].ID = 0
+		menu.Schedules[i].MenuID = menu.ID
+	}
+	if len(menu.Schedules) > 0 {
+		if err := tx.Create(&menu.Schedules).Error; err != nil {
+			return fmt.Errorf("failed to create menu schedules: %w", err)
+		}
+	}
+	return nil
+}
+
+// saveItemSchedules replaces the schedules of a menu item
+func saveItemSchedules(tx *gorm.DB, item *MenuItem) error {
+	for _, schedule := range item.Schedules {
+		if err := schedule.validate(); err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("menu_item_id = ?", item.ID).Delete(&MenuItemSchedule{}).Error; err != nil {
+		return fmt.Errorf("failed to delete menu item schedules: %w", err)
+	}
+	if item.Schedules == nil {
+		item.Schedules = []MenuItemSchedule{}
+	}
+	for i := range item.Schedules {
+		item.Schedules[i].ID = 0
+		item.Schedules[i].MenuItemID = item.ID
+	}
+	if len(item.Schedules) > 0 {
+		if err := tx.Create(&item.Schedules).Error; err != nil {
+			return fmt.Errorf("failed to create menu item schedules: %w", err)
+		}
+	}
+	return nil
+}
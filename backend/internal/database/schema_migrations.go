@@ -0,0 +1,208 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schema changes are plain SQL files under migrations/<driver>, named
+// NNNN_description.sql. Each runs once, in version order, in its own transaction.
+//
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var schemaFiles embed.FS
+
+// SchemaMigration records a schema migration that has been applied
+type SchemaMigration struct {
+	Version   uint      `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// schemaMigration is a migration file read from migrations/<driver>
+type schemaMigration struct {
+	version uint
+	name    string
+	sql     string
+}
+
+// models lists every model the baseline migration creates a table for
+var models = []interface{}{
+	&User{},
+	&Code{},
+	&ErrorLog{},
+	&FaucetTransaction{},
+	&Subscriber{},
+	&MultisigTx{},
+	// Payverge models
+	&Business{},
+	&Menu{},
+	&MenuCategory{},
+	&MenuItem{},
+	&MenuItemOption{},
+	&MenuItemImage{},
+	&ModifierGroup{},
+	&ModifierOption{},
+	&MenuSchedule{},
+	&MenuItemSchedule{},
+	&Table{},
+	&Counter{},
+	&TableSession{},
+	&AuthSession{},
+	&SignInChallenge{},
+	&RateLimitWindow{},
+	&Bill{},
+	&BillEvent{},
+	&Payment{},
+	&AlternativePayment{},
+	&SplitSession{},
+	&SplitShare{},
+	// Staff management models
+	&Staff{},
+	&StaffInvitation{},
+	&StaffLoginCode{},
+	// Order management models
+	&Order{},
+	&KitchenStation{},
+	&KitchenRoute{},
+	&KitchenTicket{},
+	// Inventory models
+	&StockItem{},
+	&RecipeComponent{},
+	&StockAdjustment{},
+	// Referral system models
+	&Referrer{},
+	&ReferralRecord{},
+	&ReferralCommissionClaim{},
+	// Withdrawal history model
+	&WithdrawalHistory{},
+	// Subscription payment model
+	&SubscriptionPayment{},
+	// Multi-currency and multilingual models
+	&SupportedCurrency{},
+	&ExchangeRate{},
+	&BusinessCurrency{},
+	&SupportedLanguage{},
+	&BusinessLanguage{},
+	&Translation{},
+	// Blockchain indexer state
+	&IndexerCheckpoint{},
+	// Payment webhook replay protection
+	&WebhookNonce{},
+	// Applied data migrations
+	&DataMigration{},
+}
+
+// Models returns every model the baseline migration creates a table for
+func Models() []interface{} {
+	return models
+}
+
+// loadSchemaMigrations reads the migration files of a driver in version order
+func loadSchemaMigrations(driver string) ([]schemaMigration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := fs.ReadDir(schemaFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %s: %w", driver, err)
+	}
+
+	migrations := make([]schemaMigration, 0, len(entries))
+	seen := make(map[uint]string)
+	for _, entry := range entries {
+		version, name, ok := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		number, err := strconv.ParseUint(version, 10, 32)
+		if !ok || err != nil || number == 0 {
+			return nil, fmt.Errorf("migration %s is not named NNNN_description.sql", entry.Name())
+		}
+		if other, ok := seen[uint(number)]; ok {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), number)
+		}
+		seen[uint(number)] = entry.Name()
+
+		content, err := fs.ReadFile(schemaFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, schemaMigration{version: uint(number), name: name, sql: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// Migrate brings the schema up to date by applying every schema migration not applied
+// yet, then runs the data migrations
+func Migrate(conn *gorm.DB) error {
+	migrations, err := loadSchemaMigrations(conn.Dialector.Name())
+	if err != nil {
+		return err
+	}
+
+	if !conn.Migrator().HasTable(&SchemaMigration{}) {
+		if err := conn.Migrator().CreateTable(&SchemaMigration{}); err != nil {
+			return fmt.Errorf("failed to create schema_migrations: %w", err)
+		}
+	}
+
+	var applied []SchemaMigration
+	if err := conn.Find(&applied).Error; err != nil {
+		return fmt.Errorf("failed to get applied schema migrations: %w", err)
+	}
+	if len(applied) == 0 && conn.Migrator().HasTable(&Business{}) {
+		adopted, err := adoptLegacySchema(conn, migrations)
+		if err != nil {
+			return err
+		}
+		applied = adopted
+	}
+
+	done := make(map[uint]bool, len(applied))
+	for _, m := range applied {
+		done[m.Version] = true
+	}
+	for _, m := range migrations {
+		if done[m.version] {
+			continue
+		}
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Exec(m.sql).Error; err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to apply schema migration %04d_%s: %w", m.version, m.name, err)
+		}
+	}
+
+	return ApplyDataMigrations(conn)
+}
+
+// adoptLegacySchema brings a database created before versioned migrations, when
+// tables were auto-migrated from the models, up to date. Auto-migrating the current
+// models yields the schema every known migration builds, so all of them are recorded
+// as applied.
+func adoptLegacySchema(conn *gorm.DB, migrations []schemaMigration) ([]SchemaMigration, error) {
+	if err := conn.AutoMigrate(models...); err != nil {
+		return nil, fmt.Errorf("failed to bring legacy schema up to date: %w", err)
+	}
+	if err := dropLegacyIndexes(conn); err != nil {
+		return nil, err
+	}
+
+	applied := make([]SchemaMigration, 0, len(migrations))
+	for _, m := range migrations {
+		applied = append(applied, SchemaMigration{Version: m.version, Name: m.name, AppliedAt: time.Now()})
+	}
+	if err := conn.Create(&applied).Error; err != nil {
+		return nil, fmt.Errorf("failed to record adopted schema migrations: %w", err)
+	}
+	return applied, nil
+}
@@ -1,7 +1,6 @@
 package database
 
 import (
-	"encoding/json"
 	"time"
 )
 
@@ -188,7 +187,7 @@ func (s *AlternativePaymentService) MarkAsConfirmed(id uint, confirmedBy string)
 	}, id)
 }
 
-// MenuService provides menu-specific operations with JSON handling
+// MenuService provides menu-specific operations over the menu tables
 type MenuService struct {
 	repo *Repository[Menu]
 }
@@ -200,35 +199,15 @@ func NewMenuService() *MenuService {
 }
 
 func (s *MenuService) Create(menu *Menu, categories []MenuCategory) error {
-	categoriesJSON, err := json.Marshal(categories)
-	if err != nil {
-		return err
-	}
-	menu.Categories = string(categoriesJSON)
-	return s.repo.Create(menu)
+	return CreateMenu(menu, categories)
 }
 
 func (s *MenuService) GetByBusinessID(businessID uint) (*Menu, []MenuCategory, error) {
-	menu, err := s.repo.GetFirstWhere("business_id = ? AND is_active = ?", businessID, true)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	var categories []MenuCategory
-	if err := json.Unmarshal([]byte(menu.Categories), &categories); err != nil {
-		return nil, nil, err
-	}
-
-	return menu, categories, nil
+	return GetMenuByBusinessID(businessID)
 }
 
 func (s *MenuService) Update(menu *Menu, categories []MenuCategory) error {
-	categoriesJSON, err := json.Marshal(categories)
-	if err != nil {
-		return err
-	}
-	menu.Categories = string(categoriesJSON)
-	return s.repo.Update(menu)
+	return UpdateMenu(menu, categories)
 }
 
 // CodeService provides code-specific operations
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SaveSignInChallenge stores the challenge handed out to an address, replacing any
+// earlier one
+func SaveSignInChallenge(address, value string, expiresAt time.Time) error {
+	challenge := SignInChallenge{Address: address, Value: value, ExpiresAt: expiresAt, CreatedAt: time.Now()}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "address"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expires_at", "created_at"}),
+	}).Create(&challenge).Error
+	if err != nil {
+		return fmt.Errorf("failed to save sign-in challenge: %w", err)
+	}
+	return nil
+}
+
+// GetSignInChallenge returns the challenge handed out to an address, or nil if there is
+// none
+func GetSignInChallenge(address string) (*SignInChallenge, error) {
+	var challenge SignInChallenge
+	err := db.Where("address = ?", address).First(&challenge).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sign-in challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+// DeleteSignInChallenge removes the challenge of an address once it was used
+func DeleteSignInChallenge(address string) error {
+	if err := db.Where("address = ?", address).Delete(&SignInChallenge{}).Error; err != nil {
+		return fmt.Errorf("failed to delete sign-in challenge: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSignInChallenges removes the challenges that expired before now
+func DeleteExpiredSignInChallenges(now time.Time) (int64, error) {
+	result := db.Where("expires_at < ?", now).Delete(&SignInChallenge{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired sign-in challenges: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// IncrementRateLimit counts a request against a key in the window starting at
+// windowStart and returns the window's count so far. The increment is a single upsert,
+// so replicas counting the same key at once do not lose requests.
+func IncrementRateLimit(key string, windowStart, expiresAt time.Time) (int64, error) {
+	window := RateLimitWindow{LimitKey: key, WindowStart: windowStart, Count: 1, ExpiresAt: expiresAt}
+	var count int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "limit_key"}, {Name: "window_start"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("rate_limit_windows.count + 1")}),
+		}).Create(&window).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(&RateLimitWindow{}).
+			Where("limit_key = ? AND window_start = ?", key, windowStart).
+			Select("count").Scan(&count).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rate limited request: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteExpiredRateLimitWindows removes the windows that ended before now
+func DeleteExpiredRateLimitWindows(now time.Time) (int64, error) {
+	result := db.Where("expires_at < ?", now).Delete(&RateLimitWindow{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired rate limit windows: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
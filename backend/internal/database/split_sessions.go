@@ -0,0 +1,274 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"payverge/internal/money"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSplitSessionLocked is returned when replacing a split that guests already started paying
+	ErrSplitSessionLocked = errors.New("split session is locked because payments have started")
+	// ErrSplitShareNotFound is returned when a bill's current split has no share for a person
+	ErrSplitShareNotFound = errors.New("split share not found")
+	// ErrSplitShareClaimed is returned when a share is already claimed by another wallet
+	ErrSplitShareClaimed = errors.New("split share is already claimed by another address")
+)
+
+// CreateSplitSession stores a new split for a bill with its shares. A previous split
+// that nobody has paid towards is cancelled; a locked one cannot be replaced.
+func CreateSplitSession(session *SplitSession) error {
+	for i := range session.Shares {
+		session.Shares[i].BillID = session.BillID
+		session.Shares[i].PayerAddr = normalizeAddress(session.Shares[i].PayerAddr)
+		session.Shares[i].PaidAmount = 0
+		session.Shares[i].PaidTip = 0
+		session.Shares[i].Status = SplitSharePending
+	}
+	if err := checkDistinctPayers(session.Shares); err != nil {
+		return err
+	}
+	session.Status = SplitSessionActive
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var bill Bill
+		if err := tx.First(&bill, session.BillID).Error; err != nil {
+			return fmt.Errorf("failed to get bill: %w", err)
+		}
+		if !bill.Status.IsActive() {
+			return fmt.Errorf("cannot split a %s bill", bill.Status)
+		}
+
+		current, err := currentSplitSession(tx, session.BillID)
+		if err != nil {
+			return err
+		}
+		if current != nil {
+			if current.Status != SplitSessionActive {
+				return ErrSplitSessionLocked
+			}
+			if err := tx.Model(&SplitSession{}).Where("id = ?", current.ID).
+				Update("status", SplitSessionCancelled).Error; err != nil {
+				return fmt.Errorf("failed to cancel previous split session: %w", err)
+			}
+		}
+
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("failed to create split session: %w", err)
+		}
+
+		// Payments already made from a participant's wallet count towards their share
+		_, err = refreshSplitSession(tx, session.BillID)
+		return err
+	})
+}
+
+// GetSplitSession returns the bill's current split session with its shares, or nil
+// if the bill has not been split
+func GetSplitSession(billID uint) (*SplitSession, error) {
+	return currentSplitSession(db, billID)
+}
+
+// ClaimSplitShare ties a share of the bill's current split to the wallet that will pay it
+func ClaimSplitShare(billID uint, personID, payerAddr string) (*SplitSession, error) {
+	payerAddr = normalizeAddress(payerAddr)
+	if payerAddr == "" {
+		return nil, fmt.Errorf("payer address is required")
+	}
+
+	var session *SplitSession
+	err := db.Transaction(func(tx *gorm.DB) error {
+		current, err := currentSplitSession(tx, billID)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.Status == SplitSessionCompleted {
+			return ErrSplitShareNotFound
+		}
+
+		var share *SplitShare
+		for i := range current.Shares {
+			other := &current.Shares[i]
+			if other.PersonID == personID {
+				share = other
+			} else if other.PayerAddr == payerAddr {
+				return ErrSplitShareClaimed
+			}
+		}
+		if share == nil {
+			return ErrSplitShareNotFound
+		}
+		if share.PayerAddr != "" && share.PayerAddr != payerAddr {
+			return ErrSplitShareClaimed
+		}
+
+		if err := tx.Model(&SplitShare{}).Where("id = ?", share.ID).Update("payer_addr", payerAddr).Error; err != nil {
+			return fmt.Errorf("failed to claim split share: %w", err)
+		}
+
+		session, err = refreshSplitSession(tx, billID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// RefreshSplitSession re-matches the bill's payments to the shares of its current split
+func RefreshSplitSession(billID uint) (*SplitSession, error) {
+	var session *SplitSession
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		session, err = refreshSplitSession(tx, billID)
+		return err
+	})
+	return session, err
+}
+
+// currentSplitSession loads the most recent split session of a bill that was not cancelled
+func currentSplitSession(conn *gorm.DB, billID uint) (*SplitSession, error) {
+	var session SplitSession
+	err := conn.Preload("Shares", func(q *gorm.DB) *gorm.DB { return q.Order("id") }).
+		Where("bill_id = ? AND status <> ?", billID, SplitSessionCancelled).
+		Order("id DESC").
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get split session: %w", err)
+	}
+	return &session, nil
+}
+
+// refreshSplitSession matches confirmed crypto payments to shares by payer address and
+// confirmed alternative payments by participant address or person ID. The session is
+// locked by its first payment and completed once every share is paid.
+func refreshSplitSession(tx *gorm.DB, billID uint) (*SplitSession, error) {
+	session, err := currentSplitSession(tx, billID)
+	if err != nil || session == nil || session.Status == SplitSessionCompleted {
+		return session, err
+	}
+
+	var payments []Payment
+	if err := tx.Where("bill_id = ? AND status = ?", billID, PaymentStatusConfirmed).Find(&payments).Error; err != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", err)
+	}
+	var alternatives []AlternativePayment
+	if err := tx.Where("bill_id = ? AND status = ?", billID, AltPaymentStatusConfirmed).Find(&alternatives).Error; err != nil {
+		return nil, fmt.Errorf("failed to get alternative payments: %w", err)
+	}
+
+	paidBy := make(map[string]money.Amount)
+	tippedBy := make(map[string]money.Amount)
+	for _, p := range payments {
+		paidBy[normalizeAddress(p.PayerAddr)] += p.Amount
+		tippedBy[normalizeAddress(p.PayerAddr)] += p.TipAmount
+	}
+	altPaidBy := make(map[string]money.Amount)
+	altTippedBy := make(map[string]money.Amount)
+	for _, p := range alternatives {
+		altPaidBy[normalizeAddress(p.ParticipantAddr)] += p.Amount
+		altTippedBy[normalizeAddress(p.ParticipantAddr)] += p.TipAmount
+	}
+
+	anyPaid, allPaid := false, true
+	for i := range session.Shares {
+		share := &session.Shares[i]
+		var paid, tipped money.Amount
+		if share.PayerAddr != "" {
+			paid += paidBy[share.PayerAddr] + altPaidBy[share.PayerAddr]
+			tipped += tippedBy[share.PayerAddr] + altTippedBy[share.PayerAddr]
+		}
+		if personKey := normalizeAddress(share.PersonID); personKey != "" && personKey != share.PayerAddr {
+			paid += altPaidBy[personKey]
+			tipped += altTippedBy[personKey]
+		}
+
+		status := SplitSharePending
+		switch {
+		case paid >= share.Amount:
+			status = SplitSharePaid
+		case paid > 0:
+			status = SplitSharePartiallyPaid
+		}
+		anyPaid = anyPaid || paid > 0
+		allPaid = allPaid && status == SplitSharePaid
+
+		if paid == share.PaidAmount && tipped == share.PaidTip && status == share.Status {
+			continue
+		}
+		share.PaidAmount = paid
+		share.PaidTip = tipped
+		share.Status = status
+		if err := tx.Model(&SplitShare{}).Where("id = ?", share.ID).Updates(map[string]interface{}{
+			"paid_amount": paid,
+			"paid_tip":    tipped,
+			"status":      status,
+		}).Error; err != nil {
+			return nil, fmt.Errorf("failed to update split share: %w", err)
+		}
+	}
+
+	status := session.Status
+	switch {
+	case allPaid && len(session.Shares) > 0:
+		status = SplitSessionCompleted
+	case anyPaid:
+		status = SplitSessionLocked
+	}
+	if status != session.Status {
+		updates := map[string]interface{}{"status": status}
+		if session.LockedAt == nil {
+			now := time.Now()
+			updates["locked_at"] = &now
+			session.LockedAt = &now
+		}
+		if err := tx.Model(&SplitSession{}).Where("id = ?", session.ID).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update split session: %w", err)
+		}
+		session.Status = status
+	}
+	return session, nil
+}
+
+// checkDistinctPayers ensures no wallet is assigned two shares of the same split
+func checkDistinctPayers(shares []SplitShare) error {
+	seen := make(map[string]bool)
+	for _, share := range shares {
+		if share.PayerAddr == "" {
+			continue
+		}
+		if seen[share.PayerAddr] {
+			return fmt.Errorf("address %s is assigned to more than one share", share.PayerAddr)
+		}
+		seen[share.PayerAddr] = true
+	}
+	return nil
+}
+
+// normalizeAddress canonicalizes a wallet address or participant identifier for matching
+func normalizeAddress(addr string) string {
+	return strings.ToLower(strings.TrimSpace(addr))
+}
+
+// CreateSplitSession stores a new split session for a bill
+func (d *DB) CreateSplitSession(session *SplitSession) error {
+	return CreateSplitSession(session)
+}
+
+// GetSplitSession returns the bill's current split session
+func (d *DB) GetSplitSession(billID uint) (*SplitSession, error) {
+	return GetSplitSession(billID)
+}
+
+// ClaimSplitShare ties a split share to the wallet paying it
+func (d *DB) ClaimSplitShare(billID uint, personID, payerAddr string) (*SplitSession, error) {
+	return ClaimSplitShare(billID, personID, payerAddr)
+}
@@ -0,0 +1,202 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TableSessionLifetime bounds how long a guest can use a table session, even if the
+// bill it belongs to stays open
+const TableSessionLifetime = 12 * time.Hour
+
+// Reasons recorded when a table session ends
+const (
+	TableSessionEndedBillClosed = "bill_closed"
+	TableSessionEndedBillMoved  = "bill_moved"
+	TableSessionEndedRotated    = "code_rotated"
+	TableSessionEndedRevoked    = "revoked"
+	TableSessionEndedReplaced   = "replaced"
+)
+
+// ErrTableSessionInvalid is returned for a table session token that is unknown, belongs
+// to another table or has ended
+var ErrTableSessionInvalid = errors.New("table session is invalid or has ended")
+
+// hashToken is how session tokens are stored, so a database leak cannot be used to
+// sign in or to order at a table
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// StartTableSession issues a session for a guest device that scanned the table's code
+// and returns it with the token the guest must send back. The session is scoped to the
+// bill open at the table, if any; a device that scans again replaces its old session.
+func StartTableSession(table *Table, deviceID, deviceName, userAgent, ipAddress string) (*TableSession, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", fmt.Errorf("failed to generate table session token: %w", err)
+	}
+	token := hex.EncodeToString(raw)
+
+	now := time.Now()
+	session := &TableSession{
+		BusinessID: table.BusinessID,
+		TableID:    table.ID,
+		TokenHash:  hashToken(token),
+		DeviceID:   deviceID,
+		DeviceName: deviceName,
+		UserAgent:  userAgent,
+		IPAddress:  ipAddress,
+		ExpiresAt:  now.Add(TableSessionLifetime),
+		LastSeenAt: now,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var bill Bill
+		err := tx.Where("table_id = ? AND status IN ?", table.ID, ActiveBillStatuses).First(&bill).Error
+		if err == nil {
+			session.BillID = &bill.ID
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("failed to get open bill: %w", err)
+		}
+
+		if deviceID != "" {
+			if err := endTableSessions(tx, TableSessionEndedReplaced, "table_id = ? AND device_id = ?", table.ID, deviceID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Create(session).Error; err != nil {
+			return fmt.Errorf("failed to create table session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return session, token, nil
+}
+
+// ValidateTableSession returns the live session of the table the token belongs to. A
+// session started before the table had a bill is tied to the bill opened since; one
+// whose bill is no longer active is ended.
+func ValidateTableSession(tableID uint, token string) (*TableSession, error) {
+	if token == "" {
+		return nil, ErrTableSessionInvalid
+	}
+
+	var session TableSession
+	ended := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("token_hash = ? AND table_id = ? AND ended_at IS NULL", hashToken(token), tableID).
+			First(&session).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTableSessionInvalid
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get table session: %w", err)
+		}
+
+		now := time.Now()
+		if now.After(session.ExpiresAt) {
+			return ErrTableSessionInvalid
+		}
+
+		updates := map[string]interface{}{"last_seen_at": now}
+		if session.BillID == nil {
+			var bill Bill
+			err := tx.Where("table_id = ? AND status IN ?", tableID, ActiveBillStatuses).First(&bill).Error
+			if err == nil {
+				session.BillID = &bill.ID
+				updates["bill_id"] = bill.ID
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("failed to get open bill: %w", err)
+			}
+		} else {
+			var bill Bill
+			if err := tx.Select("id", "status").First(&bill, *session.BillID).Error; err != nil {
+				return fmt.Errorf("failed to get table session bill: %w", err)
+			}
+			if !bill.Status.IsActive() {
+				updates = map[string]interface{}{"ended_at": now, "end_reason": TableSessionEndedBillClosed}
+				if err := tx.Model(&session).Updates(updates).Error; err != nil {
+					return fmt.Errorf("failed to end table session: %w", err)
+				}
+				ended = true
+				return nil
+			}
+		}
+
+		session.LastSeenAt = now
+		if err := tx.Model(&session).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update table session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ended {
+		return nil, ErrTableSessionInvalid
+	}
+	return &session, nil
+}
+
+// ValidateBillTableSession returns the live table session of a bill the token belongs
+// to. Only guests at the bill's table, on a session tied to this bill, pass.
+func ValidateBillTableSession(bill *Bill, token string) (*TableSession, error) {
+	if bill.TableID == 0 {
+		return nil, ErrTableSessionInvalid
+	}
+	session, err := ValidateTableSession(bill.TableID, token)
+	if err != nil {
+		return nil, err
+	}
+	if session.BillID == nil || *session.BillID != bill.ID {
+		return nil, ErrTableSessionInvalid
+	}
+	return session, nil
+}
+
+// GetTableSessions returns a business table's sessions, newest first. With activeOnly,
+// only the devices still joined are returned.
+func GetTableSessions(businessID, tableID uint, activeOnly bool) ([]TableSession, error) {
+	query := db.Where("business_id = ? AND table_id = ?", businessID, tableID)
+	if activeOnly {
+		query = query.Where("ended_at IS NULL AND expires_at > ?", time.Now())
+	}
+	var sessions []TableSession
+	if err := query.Order("created_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get table sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeTableSession ends one session of a business table, signing the device out
+func RevokeTableSession(businessID, tableID, sessionID uint) error {
+	result := db.Model(&TableSession{}).
+		Where("id = ? AND business_id = ? AND table_id = ? AND ended_at IS NULL", sessionID, businessID, tableID).
+		Updates(map[string]interface{}{"ended_at": time.Now(), "end_reason": TableSessionEndedRevoked})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke table session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("table session not found")
+	}
+	return nil
+}
+
+// endTableSessions ends the live sessions matching the condition
+func endTableSessions(tx *gorm.DB, reason string, query string, args ...interface{}) error {
+	if err := tx.Model(&TableSession{}).Where("ended_at IS NULL").Where(query, args...).
+		Updates(map[string]interface{}{"ended_at": time.Now(), "end_reason": reason}).Error; err != nil {
+		return fmt.Errorf("failed to end table sessions: %w", err)
+	}
+	return nil
+}
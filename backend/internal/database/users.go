@@ -1,8 +1,6 @@
 package database
 
 import (
-	"log"
-
 	"payverge/internal/structs"
 )
 
@@ -99,37 +97,3 @@ func UpdateNotificationPreferences(user structs.User, preferences structs.Notifi
 	user.NotificationPreferences = preferences
 	return UpdateUser(user)
 }
-
-// SetDefaultNotificationPreferences sets default notification preferences for all users that don't have them set
-func SetDefaultNotificationPreferences() error {
-	// Default notification preferences with all fields set to true
-	defaultPreferences := structs.NotificationPreferences{
-		EmailEnabled:         true,
-		NewsEnabled:          true,
-		UpdatesEnabled:       true,
-		TransactionalEnabled: true,
-		SecurityEnabled:      true,
-		ReportsEnabled:       true,
-		StatisticsEnabled:    true,
-	}
-
-	// Update all users that have empty notification preferences
-	result := db.Model(&User{}).
-		Where("notification_preferences_email_enabled = ? OR notification_preferences_email_enabled IS NULL", false).
-		Updates(map[string]interface{}{
-			"notification_preferences_email_enabled":         defaultPreferences.EmailEnabled,
-			"notification_preferences_news_enabled":          defaultPreferences.NewsEnabled,
-			"notification_preferences_updates_enabled":       defaultPreferences.UpdatesEnabled,
-			"notification_preferences_transactional_enabled": defaultPreferences.TransactionalEnabled,
-			"notification_preferences_security_enabled":      defaultPreferences.SecurityEnabled,
-			"notification_preferences_reports_enabled":       defaultPreferences.ReportsEnabled,
-			"notification_preferences_statistics_enabled":    defaultPreferences.StatisticsEnabled,
-		})
-
-	if result.Error != nil {
-		return result.Error
-	}
-
-	log.Printf("Updated notification preferences for %d users", result.RowsAffected)
-	return nil
-}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// ClaimWebhookNonce stores a webhook nonce for the provider. It returns false when the
+// nonce has already been used, i.e. the request is a replay. Expired nonces are pruned
+// on the way since their requests would be rejected by the timestamp check anyway.
+func (d *DB) ClaimWebhookNonce(provider, nonce string, expiresAt time.Time) (bool, error) {
+	if err := d.conn.Where("expires_at < ?", time.Now()).Delete(&WebhookNonce{}).Error; err != nil {
+		return false, fmt.Errorf("failed to prune webhook nonces: %w", err)
+	}
+
+	result := d.conn.Clauses(clause.OnConflict{DoNothing: true}).Create(&WebhookNonce{
+		Provider:  provider,
+		Nonce:     nonce,
+		ExpiresAt: expiresAt,
+	})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to store webhook nonce: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReleaseWebhookNonce forgets a claimed nonce so a delivery that could not be
+// processed can be retried with it
+func (d *DB) ReleaseWebhookNonce(provider, nonce string) error {
+	if err := d.conn.Where("provider = ? AND nonce = ?", provider, nonce).Delete(&WebhookNonce{}).Error; err != nil {
+		return fmt.Errorf("failed to release webhook nonce: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"payverge/internal/middleware"
+)
+
+// authorizedBusinessID returns the ID of the business the route's permission check
+// granted the caller access to
+func authorizedBusinessID(c *gin.Context) (uint, bool) {
+	access, ok := middleware.Access(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return 0, false
+	}
+	return access.Business.ID, true
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"payverge/internal/database"
+	"payverge/internal/money"
 )
 
 func TestAlternativePaymentEndpoints(t *testing.T) {
@@ -105,9 +106,9 @@ func TestAlternativePaymentEndpoints(t *testing.T) {
 func TestPaymentBreakdownCalculation(t *testing.T) {
 	t.Run("Payment Breakdown Logic", func(t *testing.T) {
 		// Test the payment breakdown calculation logic
-		totalAmount := 100.0
-		cryptoPaid := 60.0
-		alternativePaid := 25.0
+		totalAmount := money.MustParse("100.00")
+		cryptoPaid := money.MustParse("60.00")
+		alternativePaid := money.MustParse("25.00")
 		
 		remaining := totalAmount - (cryptoPaid + alternativePaid)
 		isComplete := remaining <= 0
@@ -120,22 +121,22 @@ func TestPaymentBreakdownCalculation(t *testing.T) {
 			IsComplete:      isComplete,
 		}
 
-		assert.Equal(t, 100.0, breakdown.TotalAmount)
-		assert.Equal(t, 60.0, breakdown.CryptoPaid)
-		assert.Equal(t, 25.0, breakdown.AlternativePaid)
-		assert.Equal(t, 15.0, breakdown.Remaining)
+		assert.Equal(t, money.MustParse("100.00"), breakdown.TotalAmount)
+		assert.Equal(t, money.MustParse("60.00"), breakdown.CryptoPaid)
+		assert.Equal(t, money.MustParse("25.00"), breakdown.AlternativePaid)
+		assert.Equal(t, money.MustParse("15.00"), breakdown.Remaining)
 		assert.False(t, breakdown.IsComplete)
 	})
 
 	t.Run("Complete Payment Breakdown", func(t *testing.T) {
 		// Test when bill is fully paid
-		totalAmount := 100.0
-		cryptoPaid := 70.0
-		alternativePaid := 30.0
+		totalAmount := money.MustParse("100.00")
+		cryptoPaid := money.MustParse("70.00")
+		alternativePaid := money.MustParse("30.00")
 		
 		remaining := totalAmount - (cryptoPaid + alternativePaid)
 		if remaining < 0 {
-			remaining = 0
+			remaining = money.Zero
 		}
 		isComplete := cryptoPaid + alternativePaid >= totalAmount
 
@@ -147,10 +148,10 @@ func TestPaymentBreakdownCalculation(t *testing.T) {
 			IsComplete:      isComplete,
 		}
 
-		assert.Equal(t, 100.0, breakdown.TotalAmount)
-		assert.Equal(t, 70.0, breakdown.CryptoPaid)
-		assert.Equal(t, 30.0, breakdown.AlternativePaid)
-		assert.Equal(t, 0.0, breakdown.Remaining)
+		assert.Equal(t, money.MustParse("100.00"), breakdown.TotalAmount)
+		assert.Equal(t, money.MustParse("70.00"), breakdown.CryptoPaid)
+		assert.Equal(t, money.MustParse("30.00"), breakdown.AlternativePaid)
+		assert.Equal(t, money.MustParse("0.00"), breakdown.Remaining)
 		assert.True(t, breakdown.IsComplete)
 	})
 }
@@ -275,8 +275,8 @@ func (h *AnalyticsHandler) GetLiveBills(c *gin.Context) {
 	_ = userID    // Suppress unused variable warning
 	_ = business  // Suppress unused variable warning
 
-	// Get active bills (open status)
-	bills, err := h.db.GetBillsByBusinessAndStatus(uint(businessID), database.BillStatusOpen)
+	// Get active bills (open or partially paid)
+	bills, err := h.db.GetBillsByBusinessAndStatus(uint(businessID), database.ActiveBillStatuses...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -368,7 +368,7 @@ func (h *AnalyticsHandler) GetDashboardSummary(c *gin.Context) {
 	}
 
 	// Get active bills count
-	activeBills, err := h.db.GetBillsByBusinessAndStatus(uint(businessID), database.BillStatusOpen)
+	activeBills, err := h.db.GetBillsByBusinessAndStatus(uint(businessID), database.ActiveBillStatuses...)
 	if err != nil {
 		activeBills = []database.Bill{} // Default to empty if error
 	}
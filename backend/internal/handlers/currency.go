@@ -6,9 +6,11 @@ import (
 	"strconv"
 
 	"payverge/internal/database"
+	"payverge/internal/middleware"
 	"payverge/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 // CurrencyHandler handles currency and language related requests
@@ -112,26 +114,6 @@ func (h *CurrencyHandler) GetBusinessCurrencies(c *gin.Context) {
 		return
 	}
 
-	// Verify business exists and user has access
-	var business database.Business
-	if err := h.db.GetGorm().First(&business, businessID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Verify user owns the business
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
 	currencies, err := h.db.CurrencyService.GetBusinessCurrencies(uint(businessID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get business currencies"})
@@ -160,26 +142,6 @@ func (h *CurrencyHandler) UpdateBusinessCurrencies(c *gin.Context) {
 		return
 	}
 
-	// Verify business exists and user has access
-	var business database.Business
-	if err := h.db.GetGorm().First(&business, businessID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Verify user owns the business
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
 	// Validate that preferred currency is in the list
 	preferredFound := false
 	for _, code := range request.CurrencyCodes {
@@ -212,26 +174,6 @@ func (h *CurrencyHandler) GetBusinessLanguages(c *gin.Context) {
 		return
 	}
 
-	// Verify business exists and user has access
-	var business database.Business
-	if err := h.db.GetGorm().First(&business, businessID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Verify user owns the business
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
 	languages, err := h.db.LanguageService.GetBusinessLanguages(uint(businessID))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get business languages"})
@@ -243,12 +185,13 @@ func (h *CurrencyHandler) GetBusinessLanguages(c *gin.Context) {
 
 // UpdateBusinessLanguages updates languages supported by a business
 func (h *CurrencyHandler) UpdateBusinessLanguages(c *gin.Context) {
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
+	access, ok := middleware.Access(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
 		return
 	}
+	business := access.Business
+	businessID := business.ID
 
 	var request struct {
 		LanguageCodes []string `json:"language_codes" binding:"required"`
@@ -260,26 +203,6 @@ func (h *CurrencyHandler) UpdateBusinessLanguages(c *gin.Context) {
 		return
 	}
 
-	// Verify business exists and user has access
-	var business database.Business
-	if err := h.db.GetGorm().First(&business, businessID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Verify user owns the business
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
 	// Validate that default language is in the list
 	defaultFound := false
 	for _, code := range request.LanguageCodes {
@@ -357,14 +280,12 @@ func (h *CurrencyHandler) UpdateTranslation(c *gin.Context) {
 		OriginalText   string `json:"original_text"`
 	}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
+	// The authorization middleware has read the body to find the entity's business
+	if err := c.ShouldBindBodyWith(&request, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// TODO: Add authorization check based on entity type
-	// For now, we'll allow any authenticated user to update translations
-
 	translation := &database.Translation{
 		EntityType:        request.EntityType,
 		EntityID:          request.EntityID,
@@ -401,8 +322,7 @@ func (h *CurrencyHandler) GetMenuTranslations(c *gin.Context) {
 	}
 
 	// Get all translations for this business and language
-	var translations []database.Translation
-	err = h.db.GetGorm().Where("language_code = ?", languageCode).Find(&translations).Error
+	translations, err := h.db.TranslationService.GetBusinessTranslations(uint(businessID), languageCode)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get translations"})
 		return
@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"payverge/internal/database"
+	"payverge/internal/middleware"
+)
+
+// LowStockNotifier is told when stock items of a business run low
+type LowStockNotifier interface {
+	NotifyLowStock(business *database.Business, items []database.StockItem)
+}
+
+var lowStockNotifier LowStockNotifier
+
+// SetLowStockNotifier sets where low-stock alerts are sent
+func SetLowStockNotifier(notifier LowStockNotifier) {
+	lowStockNotifier = notifier
+}
+
+// StockItemRequest represents the request to create or update a stock item. Quantity
+// is only used when creating; later changes go through adjustments.
+type StockItemRequest struct {
+	Name              string `json:"name" binding:"required"`
+	Unit              string `json:"unit"`
+	Quantity          int64  `json:"quantity"`
+	LowStockThreshold int64  `json:"low_stock_threshold"`
+}
+
+// StockAdjustmentRequest represents a restock, a waste entry or a stock take. Change is
+// used for restock and waste; Quantity is the counted quantity for a stock take.
+type StockAdjustmentRequest struct {
+	Reason   database.StockAdjustmentReason `json:"reason" binding:"required"`
+	Change   int64                          `json:"change"`
+	Quantity *int64                         `json:"quantity"`
+	Note     string                         `json:"note"`
+}
+
+// RecipeRequest represents the request to replace the recipe of a menu item
+type RecipeRequest struct {
+	Components []database.RecipeComponent `json:"components"`
+}
+
+// TrackStockRequest represents the request to count a menu item in portions
+type TrackStockRequest struct {
+	Quantity          int64 `json:"quantity"`
+	LowStockThreshold int64 `json:"low_stock_threshold"`
+}
+
+// alertLowStock sends the business's newly low stock items, if a notifier is configured
+func alertLowStock(businessID uint) {
+	if lowStockNotifier == nil {
+		return
+	}
+	items, err := database.ClaimLowStockAlerts(businessID)
+	if err != nil {
+		log.Printf("Failed to check low stock for business %d: %v", businessID, err)
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+	business, err := database.GetBusinessByID(businessID)
+	if err != nil {
+		log.Printf("Failed to get business %d for low stock alert: %v", businessID, err)
+		return
+	}
+	lowStockNotifier.NotifyLowStock(business, items)
+}
+
+// stockError responds to inventory errors
+func stockError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, database.ErrStockItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock item not found"})
+	case errors.Is(err, database.ErrMenuItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Menu item not found"})
+	case errors.Is(err, database.ErrInvalidStockAdjustment), errors.Is(err, database.ErrInvalidRecipe):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// stockPeriod parses the optional from and to RFC 3339 query parameters
+func stockPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	var from, to time.Time
+	for _, p := range []struct {
+		name string
+		at   *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		value := c.Query(p.name)
+		if value == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + p.name + " time, expected RFC 3339"})
+			return from, to, false
+		}
+		*p.at = at
+	}
+	return from, to, true
+}
+
+// GetStockItems lists a business's stock items
+func GetStockItems(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	items, err := database.GetStockItems(businessID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items})
+}
+
+// CreateStockItem adds a stock item such as an ingredient or a bottle
+func CreateStockItem(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	var req StockItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Quantity < 0 || req.LowStockThreshold < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantities cannot be negative"})
+		return
+	}
+
+	item := database.StockItem{
+		BusinessID:        businessID,
+		Name:              req.Name,
+		Unit:              req.Unit,
+		Quantity:          req.Quantity,
+		LowStockThreshold: req.LowStockThreshold,
+	}
+	if err := database.CreateStockItem(&item, middleware.Actor(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock item"})
+		return
+	}
+
+	go alertLowStock(businessID)
+	c.JSON(http.StatusCreated, gin.H{"item": item})
+}
+
+// UpdateStockItem updates a stock item's name, unit and low-stock threshold
+func UpdateStockItem(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	stockItemID, err := strconv.ParseUint(c.Param("stockItemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock item ID"})
+		return
+	}
+
+	var req StockItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.LowStockThreshold < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quantities cannot be negative"})
+		return
+	}
+
+	item := database.StockItem{Name: req.Name, Unit: req.Unit, LowStockThreshold: req.LowStockThreshold}
+	if err := database.UpdateStockItem(businessID, uint(stockItemID), &item); err != nil {
+		stockError(c, err, "Failed to update stock item")
+		return
+	}
+
+	go alertLowStock(businessID)
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// DeleteStockItem removes a stock item and the recipes using it
+func DeleteStockItem(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	stockItemID, err := strconv.ParseUint(c.Param("stockItemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock item ID"})
+		return
+	}
+
+	if err := database.DeleteStockItem(businessID, uint(stockItemID)); err != nil {
+		stockError(c, err, "Failed to delete stock item")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Stock item deleted successfully"})
+}
+
+// AdjustStockItem records a restock, a waste entry or a stock take
+func AdjustStockItem(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	stockItemID, err := strconv.ParseUint(c.Param("stockItemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock item ID"})
+		return
+	}
+
+	var req StockAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var item *database.StockItem
+	actor := middleware.Actor(c)
+	if req.Reason == database.StockReasonCount {
+		if req.Quantity == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A stock count needs the counted quantity"})
+			return
+		}
+		item, err = database.CountStock(businessID, uint(stockItemID), *req.Quantity, req.Note, actor)
+	} else {
+		item, err = database.AdjustStock(businessID, uint(stockItemID), req.Reason, req.Change, req.Note, actor)
+	}
+	if err != nil {
+		stockError(c, err, "Failed to adjust stock")
+		return
+	}
+
+	go alertLowStock(businessID)
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// GetStockAdjustments lists the stock adjustments of a business, newest first,
+// optionally for one stock item and a period
+func GetStockAdjustments(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	var stockItemID uint64
+	if s := c.Query("stock_item_id"); s != "" {
+		var err error
+		stockItemID, err = strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock item ID"})
+			return
+		}
+	}
+	from, to, ok := stockPeriod(c)
+	if !ok {
+		return
+	}
+
+	adjustments, err := database.GetStockAdjustments(businessID, uint(stockItemID), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock adjustments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"adjustments": adjustments})
+}
+
+// GetStockReconciliation totals sales, waste, restocks and corrections per stock item
+// over a period
+func GetStockReconciliation(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	from, to, ok := stockPeriod(c)
+	if !ok {
+		return
+	}
+
+	report, err := database.GetStockReconciliation(businessID, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve stock reconciliation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reconciliation": report})
+}
+
+// GetMenuItemRecipe returns the stock used to make a menu item
+func GetMenuItemRecipe(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("menuItemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu item ID"})
+		return
+	}
+
+	components, err := database.GetMenuItemRecipe(businessID, uint(itemID))
+	if err != nil {
+		stockError(c, err, "Failed to retrieve recipe")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"components": components})
+}
+
+// SetMenuItemRecipe replaces the stock used to make a menu item
+func SetMenuItemRecipe(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("menuItemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu item ID"})
+		return
+	}
+
+	var req RecipeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.SetMenuItemRecipe(businessID, uint(itemID), req.Components); err != nil {
+		stockError(c, err, "Failed to update recipe")
+		return
+	}
+
+	if req.Components == nil {
+		req.Components = []database.RecipeComponent{}
+	}
+	c.JSON(http.StatusOK, gin.H{"components": req.Components})
+}
+
+// TrackMenuItemStock counts a menu item in portions instead of through ingredients
+func TrackMenuItemStock(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	itemID, err := strconv.ParseUint(c.Param("menuItemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu item ID"})
+		return
+	}
+
+	var req TrackStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := database.TrackMenuItemStock(businessID, uint(itemID), req.Quantity, req.LowStockThreshold, middleware.Actor(c))
+	if err != nil {
+		stockError(c, err, "Failed to track menu item stock")
+		return
+	}
+
+	go alertLowStock(businessID)
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"payverge/internal/database"
+	"payverge/internal/middleware"
+)
+
+// KitchenNotifier is told about new and updated kitchen tickets so kitchen displays
+// can refresh live
+type KitchenNotifier interface {
+	NotifyKitchenTicket(ticket *database.KitchenTicket, order *database.Order)
+}
+
+var kitchenNotifier KitchenNotifier
+
+// SetKitchenNotifier sets where kitchen ticket updates are sent
+func SetKitchenNotifier(notifier KitchenNotifier) {
+	kitchenNotifier = notifier
+}
+
+// KitchenStationRequest represents the request to create or update a kitchen station
+type KitchenStationRequest struct {
+	Name      string `json:"name" binding:"required"`
+	IsDefault bool   `json:"is_default"`
+	IsActive  *bool  `json:"is_active"`
+	SortOrder int    `json:"sort_order"`
+}
+
+// KitchenRoutesRequest represents the request to replace a business's kitchen routes
+type KitchenRoutesRequest struct {
+	Routes []database.KitchenRoute `json:"routes"`
+}
+
+// notifyKitchenTicket sends a ticket update if a notifier is configured
+func notifyKitchenTicket(ticket *database.KitchenTicket, order *database.Order) {
+	if kitchenNotifier != nil {
+		kitchenNotifier.NotifyKitchenTicket(ticket, order)
+	}
+}
+
+// notifyOrderTickets sends every ticket of an order after its status changed
+func notifyOrderTickets(order *database.Order) {
+	if kitchenNotifier == nil {
+		return
+	}
+	tickets, err := database.GetKitchenTicketsByOrder(order.ID)
+	if err != nil {
+		return
+	}
+	for i := range tickets {
+		kitchenNotifier.NotifyKitchenTicket(&tickets[i], order)
+	}
+}
+
+// GetKitchenStations lists a business's kitchen stations
+func GetKitchenStations(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	stations, err := database.GetKitchenStations(businessID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve kitchen stations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stations": stations})
+}
+
+// CreateKitchenStation adds a kitchen station such as grill, bar or dessert
+func CreateKitchenStation(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	var req KitchenStationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	station := database.KitchenStation{
+		BusinessID: businessID,
+		Name:       req.Name,
+		IsDefault:  req.IsDefault,
+		IsActive:   req.IsActive == nil || *req.IsActive,
+		SortOrder:  req.SortOrder,
+	}
+	if err := database.CreateKitchenStation(&station); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"station": station})
+}
+
+// UpdateKitchenStation updates a kitchen station
+func UpdateKitchenStation(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	stationID, err := strconv.ParseUint(c.Param("stationId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid station ID"})
+		return
+	}
+
+	var req KitchenStationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	station := database.KitchenStation{
+		ID:         uint(stationID),
+		BusinessID: businessID,
+		Name:       req.Name,
+		IsDefault:  req.IsDefault,
+		IsActive:   req.IsActive == nil || *req.IsActive,
+		SortOrder:  req.SortOrder,
+	}
+	if err := database.UpdateKitchenStation(&station); err != nil {
+		if errors.Is(err, database.ErrKitchenStationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen station not found"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"station": station})
+}
+
+// DeleteKitchenStation removes a kitchen station and its routes
+func DeleteKitchenStation(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	stationID, err := strconv.ParseUint(c.Param("stationId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid station ID"})
+		return
+	}
+
+	if err := database.DeleteKitchenStation(businessID, uint(stationID)); err != nil {
+		if errors.Is(err, database.ErrKitchenStationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen station not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete kitchen station"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Kitchen station deleted successfully"})
+}
+
+// GetKitchenRoutes lists which categories and items go to which station
+func GetKitchenRoutes(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	routes, err := database.GetKitchenRoutes(businessID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve kitchen routes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"routes": routes})
+}
+
+// SetKitchenRoutes replaces the category and item routes of a business
+func SetKitchenRoutes(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	var req KitchenRoutesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.SetKitchenRoutes(businessID, req.Routes); err != nil {
+		if errors.Is(err, database.ErrKitchenStationNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Route refers to an unknown kitchen station"})
+			return
+		}
+		if errors.Is(err, database.ErrKitchenRouteTargetNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Route refers to an unknown menu item or category"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"routes": req.Routes})
+}
+
+// GetKitchenTickets lists kitchen tickets, optionally for one station and some statuses.
+// Without a status filter only open tickets are returned.
+func GetKitchenTickets(c *gin.Context) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	var stationID uint64
+	if s := c.Query("station_id"); s != "" {
+		var err error
+		stationID, err = strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid station ID"})
+			return
+		}
+	}
+
+	statuses := []database.KitchenTicketStatus{database.KitchenTicketPending, database.KitchenTicketInProgress}
+	if s := c.Query("status"); s != "" {
+		statuses = nil
+		for _, status := range strings.Split(s, ",") {
+			statuses = append(statuses, database.KitchenTicketStatus(strings.TrimSpace(status)))
+		}
+	}
+
+	tickets, err := database.GetKitchenTickets(businessID, uint(stationID), statuses...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve kitchen tickets"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tickets": tickets, "total": len(tickets)})
+}
+
+// StartKitchenTicket marks a ticket as being prepared
+func StartKitchenTicket(c *gin.Context) {
+	updateKitchenTicket(c, func(businessID, ticketID uint, _ string) (*database.KitchenTicket, *database.Order, error) {
+		return database.StartKitchenTicket(businessID, ticketID)
+	})
+}
+
+// BumpKitchenTicket marks a ticket as done. Its order becomes ready once every ticket
+// of the order has been bumped.
+func BumpKitchenTicket(c *gin.Context) {
+	updateKitchenTicket(c, database.BumpKitchenTicket)
+}
+
+// updateKitchenTicket applies a ticket transition and notifies kitchen displays
+func updateKitchenTicket(c *gin.Context, update func(businessID, ticketID uint, actor string) (*database.KitchenTicket, *database.Order, error)) {
+	businessID, ok := authorizedBusinessID(c)
+	if !ok {
+		return
+	}
+
+	ticketID, err := strconv.ParseUint(c.Param("ticketId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+		return
+	}
+
+	actor := middleware.Actor(c)
+	ticket, order, err := update(businessID, uint(ticketID), actor)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrKitchenTicketNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kitchen ticket not found"})
+		case errors.Is(err, database.ErrKitchenTicketClosed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update kitchen ticket"})
+		}
+		return
+	}
+
+	notifyKitchenTicket(ticket, order)
+
+	c.JSON(http.StatusOK, gin.H{"ticket": ticket, "order_status": order.Status})
+}
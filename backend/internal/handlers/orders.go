@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -8,28 +9,29 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"payverge/internal/database"
+	"payverge/internal/middleware"
 )
 
 // CreateOrderRequest represents the request to create a new order
 type CreateOrderRequest struct {
 	BillID  uint                    `json:"bill_id" binding:"required"`
 	Notes   string                  `json:"notes"`
-	Items   []CreateOrderItemRequest `json:"items" binding:"required"`
+	Items   []CreateOrderItemRequest `json:"items" binding:"required,min=1,dive"`
 }
 
-// CreateOrderItemRequest represents individual items in the order
+// CreateOrderItemRequest represents individual items in the order. Prices are resolved
+// from the menu, never taken from the request.
 type CreateOrderItemRequest struct {
-	MenuItemName    string                      `json:"menu_item_name" binding:"required"`
-	Quantity        int                         `json:"quantity" binding:"required"`
-	Price           float64                     `json:"price" binding:"required"`
-	Options         []database.MenuItemOption  `json:"options"`         // Add-ons/modifiers
-	SpecialRequests string                      `json:"special_requests"`
+	MenuItemID      uint                         `json:"menu_item_id" binding:"required"`
+	OptionIDs       []uint                       `json:"option_ids"` // Selected add-ons
+	Modifiers       []database.ModifierSelection `json:"modifiers"`  // Options chosen per modifier group
+	Quantity        int                          `json:"quantity" binding:"required,min=1"`
+	SpecialRequests string                       `json:"special_requests"`
 }
 
 // UpdateOrderStatusRequest represents the request to update order status
 type UpdateOrderStatusRequest struct {
-	Status     database.OrderStatus `json:"status" binding:"required"`
-	ApprovedBy string               `json:"approved_by"`
+	Status database.OrderStatus `json:"status" binding:"required"`
 }
 
 // OrderResponse represents the response for orders
@@ -92,25 +94,25 @@ func CreateOrder(c *gin.Context) {
 		UpdatedAt:   time.Now(),
 	}
 
-	// Convert request items to order items
-	var orderItems []database.OrderItem
-	for _, itemReq := range req.Items {
-		// Calculate subtotal including options
-		subtotal := itemReq.Price * float64(itemReq.Quantity)
-		for _, option := range itemReq.Options {
-			subtotal += option.PriceChange * float64(itemReq.Quantity)
-		}
-
-		orderItem := database.OrderItem{
-			ID:              fmt.Sprintf("%s-%d", itemReq.MenuItemName, time.Now().UnixNano()),
-			MenuItemName:    itemReq.MenuItemName,
+	// Price the items from the menu
+	selections := make([]database.MenuSelection, len(req.Items))
+	for i, itemReq := range req.Items {
+		selections[i] = database.MenuSelection{
+			MenuItemID:      itemReq.MenuItemID,
+			OptionIDs:       itemReq.OptionIDs,
+			Modifiers:       itemReq.Modifiers,
 			Quantity:        itemReq.Quantity,
-			Price:           itemReq.Price,
-			Options:         itemReq.Options,
 			SpecialRequests: itemReq.SpecialRequests,
-			Subtotal:        subtotal,
 		}
-		orderItems = append(orderItems, orderItem)
+	}
+	orderItems, err := database.PriceOrderItems(uint(businessID), selections)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidMenuSelection) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price order items"})
+		return
 	}
 
 	// Create order with items
@@ -223,8 +225,14 @@ func UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	// Update order status
-	if err := database.UpdateOrderStatus(uint(orderID), req.Status, req.ApprovedBy); err != nil {
+	// Update order status; approvals are attributed to the caller the authorization
+	// middleware identified
+	if err := database.UpdateOrderStatus(uint(orderID), req.Status, middleware.Actor(c)); err != nil {
+		var invalid *database.InvalidOrderTransitionError
+		if errors.As(err, &invalid) {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Order cannot move from %s to %s", invalid.From, invalid.To)})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update order status"})
 		return
 	}
@@ -236,6 +244,14 @@ func UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
+	// Approving sends the order's tickets to the kitchen; cancelling withdraws them
+	if req.Status == database.OrderStatusApproved || req.Status == database.OrderStatusOrderCancelled {
+		notifyOrderTickets(updatedOrder)
+	}
+	if req.Status == database.OrderStatusApproved {
+		go alertLowStock(updatedOrder.BusinessID)
+	}
+
 	c.JSON(http.StatusOK, OrderResponse{Order: *updatedOrder})
 }
 
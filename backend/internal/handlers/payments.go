@@ -1,13 +1,19 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
 	"payverge/internal/blockchain"
 	"payverge/internal/database"
+	"payverge/internal/middleware"
+	"payverge/internal/money"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,16 +22,58 @@ import (
 type PaymentHandler struct {
 	db         *database.DB
 	blockchain *blockchain.BlockchainService
+	events     paymentEventSource
+	webhooks   WebhookConfig
+	notifier   SplitNotifier
+
+	// confirmations is the depth a payment's block needs before it is confirmed, as
+	// the indexer requires; shallower payments are recorded as pending
+	confirmations uint64
 }
 
+// maxWebhookBodySize caps the payload read from webhook requests
+const maxWebhookBodySize = 1 << 20
+
+// defaultConfirmations is the indexer's default confirmation depth
+var defaultConfirmations = blockchain.DefaultIndexerConfig().Confirmations
+
 // NewPaymentHandler creates a new payment handler
 func NewPaymentHandler(db *database.DB, blockchain *blockchain.BlockchainService) *PaymentHandler {
-	return &PaymentHandler{
-		db:         db,
-		blockchain: blockchain,
+	handler := &PaymentHandler{
+		db:            db,
+		blockchain:    blockchain,
+		confirmations: defaultConfirmations,
+	}
+	if blockchain != nil {
+		handler.events = blockchain
+	}
+	return handler
+}
+
+// SetSplitNotifier sets where split share updates are pushed after payments are recorded
+func (h *PaymentHandler) SetSplitNotifier(notifier SplitNotifier) {
+	h.notifier = notifier
+}
+
+// SetConfirmations sets the depth a payment's block needs before a reported payment is
+// confirmed. It should match the indexer's, which confirms the payments left pending.
+func (h *PaymentHandler) SetConfirmations(confirmations uint64) {
+	h.confirmations = confirmations
+}
+
+// notifySplit pushes the bill's split status if a notifier is configured
+func (h *PaymentHandler) notifySplit(billID uint) {
+	if h.notifier != nil {
+		h.notifier.NotifySplitSession(billID)
 	}
 }
 
+// ConfigureWebhooks sets the providers allowed to deliver payment webhooks. Until it is
+// called with at least one secret the webhook endpoint rejects every request.
+func (h *PaymentHandler) ConfigureWebhooks(config WebhookConfig) {
+	h.webhooks = config
+}
+
 // CreateBillPayment processes a payment for a bill
 // POST /api/v1/bills/:id/payments
 func (h *PaymentHandler) CreateBillPayment(c *gin.Context) {
@@ -59,23 +107,7 @@ func (h *PaymentHandler) CreateBillPayment(c *gin.Context) {
 		return
 	}
 
-	// Update bill payment status in database
-	bill.PaidAmount += float64(req.Amount)
-	bill.TipAmount += float64(req.TipAmount)
-
-	// Update bill status based on payment
-	if bill.PaidAmount >= bill.TotalAmount {
-		bill.Status = database.BillStatusPaid
-	} else if bill.PaidAmount > 0 {
-		bill.Status = "partial"
-	}
-
-	err = h.db.UpdateBill(bill)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bill"})
-		return
-	}
-
+	// The bill is updated once the payment event is ingested into the ledger
 	c.JSON(http.StatusOK, gin.H{
 		"payment_result": result,
 		"bill":           bill,
@@ -160,23 +192,68 @@ func (h *PaymentHandler) GetBillTotalPaid(c *gin.Context) {
 		"bill_id":    bill.ID,
 		"total_paid": totalPaid,
 		"bill_total": bill.TotalAmount,
-		"remaining":  bill.TotalAmount - float64(totalPaid),
+		"remaining":  bill.TotalAmount - totalPaid,
 	})
 }
 
-// WebhookPaymentConfirmation handles payment confirmation webhooks
+// WebhookPaymentConfirmation handles payment confirmation webhooks. Requests must be
+// signed by a configured provider, carry a fresh timestamp and an unused nonce, and
+// reference a transaction whose PaymentMade event for the bill is found on chain.
+// Amounts are taken from the chain, never from the request body.
 // POST /api/v1/payments/webhook
 func (h *PaymentHandler) WebhookPaymentConfirmation(c *gin.Context) {
+	if len(h.webhooks.Secrets) == 0 || h.events == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Payment webhooks are not configured"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBodySize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	delivery, err := h.webhooks.verify(
+		c.GetHeader(WebhookProviderHeader),
+		c.GetHeader(WebhookTimestampHeader),
+		c.GetHeader(WebhookNonceHeader),
+		c.GetHeader(WebhookSignatureHeader),
+		body,
+		time.Now(),
+	)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	fresh, err := h.db.ClaimWebhookNonce(delivery.provider, delivery.nonce, h.webhooks.nonceExpiry(delivery))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check webhook nonce"})
+		return
+	}
+	if !fresh {
+		c.JSON(http.StatusConflict, gin.H{"error": "Webhook nonce already used"})
+		return
+	}
+
+	// The nonce only counts as used once the delivery is processed, so a delivery
+	// rejected below can be retried with it
+	processed := false
+	defer func() {
+		if processed {
+			return
+		}
+		if err := h.db.ReleaseWebhookNonce(delivery.provider, delivery.nonce); err != nil {
+			log.Printf("Failed to release webhook nonce from %s: %v", delivery.provider, err)
+		}
+	}()
+
 	var webhook struct {
 		TransactionHash string `json:"transaction_hash"`
 		BillID          string `json:"bill_id"`
-		Amount          int64  `json:"amount"`
-		TipAmount       int64  `json:"tip_amount"`
-		Status          string `json:"status"`
 	}
-
-	if err := c.ShouldBindJSON(&webhook); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := json.Unmarshal(body, &webhook); err != nil || webhook.TransactionHash == "" || webhook.BillID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "transaction_hash and bill_id are required"})
 		return
 	}
 
@@ -188,37 +265,55 @@ func (h *PaymentHandler) WebhookPaymentConfirmation(c *gin.Context) {
 	}
 
 	// Get bill from database
-	bill, err := h.db.GetBill(uint(billID))
-	if err != nil {
+	if _, err := h.db.GetBill(uint(billID)); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
 		return
 	}
 
-	// Update bill with confirmed payment
-	if webhook.Status == "confirmed" {
-		bill.PaidAmount += float64(webhook.Amount)
-		bill.TipAmount += float64(webhook.TipAmount)
-
-		// Update bill status
-		if bill.PaidAmount >= bill.TotalAmount {
-			bill.Status = database.BillStatusPaid
-		} else if bill.PaidAmount > 0 {
-			bill.Status = "partial"
+	// Nothing is recorded unless the chain shows the payment
+	events, final, err := h.events.PaymentEventsForBill(c.Request.Context(), webhook.TransactionHash, webhook.BillID, h.confirmations)
+	if err != nil {
+		switch {
+		case errors.Is(err, blockchain.ErrTransactionNotFound),
+			errors.Is(err, blockchain.ErrTransactionFailed),
+			errors.Is(err, blockchain.ErrPaymentNotFound):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to verify transaction on chain"})
 		}
+		return
+	}
 
-		err = h.db.UpdateBill(bill)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bill"})
-			return
-		}
+	// Record through the ledger; events already indexed are no-ops. Events that are not
+	// final yet stay pending until the indexer reaches their block.
+	bill, recorded, err := h.recordChainPayments(uint(billID), events, final, "webhook:"+delivery.provider)
+	if err != nil {
+		log.Printf("Failed to record webhook payment %s for bill %d: %v", webhook.TransactionHash, billID, err)
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction could not be recorded for this bill"})
+		return
+	}
+	processed = true
+
+	if recorded {
+		h.notifySplit(uint(billID))
+	}
+
+	// TODO: Send WebSocket notification to business dashboard
+	// TODO: Send WebSocket notification to guest bill view
 
-		// TODO: Send WebSocket notification to business dashboard
-		// TODO: Send WebSocket notification to guest bill view
+	if !final {
+		c.JSON(http.StatusAccepted, gin.H{
+			"status":    "pending",
+			"duplicate": !recorded,
+			"bill":      bill,
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status": "processed",
-		"bill":   bill,
+		"status":    "processed",
+		"duplicate": !recorded,
+		"bill":      bill,
 	})
 }
 
@@ -250,15 +345,22 @@ func (h *PaymentHandler) MarkAlternativePayment(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
 		return
 	}
+	if !bill.Status.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Bill is %s and no longer accepts payments", bill.Status)})
+		return
+	}
 
-	// Convert amount to float64 (assuming it's in USDC with 6 decimals)
-	amountFloat, err := strconv.ParseFloat(req.Amount, 64)
+	// Amount is given in USDC base units (6 decimals)
+	amountUnits, err := strconv.ParseInt(req.Amount, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
 		return
 	}
-	// Convert from micro USDC to USDC
-	amountFloat = amountFloat / 1_000_000
+	amount, err := money.FromUSDC(amountUnits)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be a whole number of cents"})
+		return
+	}
 
 	// Validate payment method
 	var paymentMethod database.AlternativePaymentMethod
@@ -276,22 +378,15 @@ func (h *PaymentHandler) MarkAlternativePayment(c *gin.Context) {
 		return
 	}
 
-	// Get user address from context (set by auth middleware)
-	userAddress, exists := c.Get("user_address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	// Create alternative payment record
 	now := time.Now()
 	altPayment := &database.AlternativePayment{
 		BillID:          uint(billID),
 		ParticipantAddr: req.ParticipantAddress,
-		Amount:          amountFloat,
+		Amount:          amount,
 		PaymentMethod:   paymentMethod,
 		Status:          database.AltPaymentStatusConfirmed,
-		ConfirmedBy:     userAddress.(string),
+		ConfirmedBy:     middleware.Actor(c),
 		ConfirmedAt:     &now,
 	}
 
@@ -301,13 +396,8 @@ func (h *PaymentHandler) MarkAlternativePayment(c *gin.Context) {
 		return
 	}
 
-	// Update bill paid amount
-	bill.PaidAmount += amountFloat
-	if bill.PaidAmount >= bill.TotalAmount {
-		bill.Status = database.BillStatusPaid
-	}
-
-	if err := h.db.UpdateBill(bill); err != nil {
+	// Re-derive the bill's paid amount from the ledger
+	if _, err := h.db.RecalculateBillPayments(bill.ID, middleware.Actor(c)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bill"})
 		return
 	}
@@ -319,7 +409,7 @@ func (h *PaymentHandler) MarkAlternativePayment(c *gin.Context) {
 		return
 	}
 
-	// TODO: Send WebSocket notification
+	h.notifySplit(uint(billID))
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":           true,
@@ -350,19 +440,27 @@ func (h *PaymentHandler) RequestAlternativePayment(c *gin.Context) {
 	}
 
 	// Get bill from database to validate it exists
-	_, err = h.db.GetBill(uint(billID))
+	bill, err := h.db.GetBill(uint(billID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
 		return
 	}
+	if !bill.Status.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Bill is %s and no longer accepts payments", bill.Status)})
+		return
+	}
 
-	// Convert amount to float64
-	amountFloat, err := strconv.ParseFloat(req.Amount, 64)
+	// Amount is given in USDC base units (6 decimals)
+	amountUnits, err := strconv.ParseInt(req.Amount, 10, 64)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid amount format"})
 		return
 	}
-	amountFloat = amountFloat / 1_000_000
+	amount, err := money.FromUSDC(amountUnits)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be a whole number of cents"})
+		return
+	}
 
 	// Validate payment method
 	var paymentMethod database.AlternativePaymentMethod
@@ -385,7 +483,7 @@ func (h *PaymentHandler) RequestAlternativePayment(c *gin.Context) {
 		BillID:          uint(billID),
 		ParticipantAddr: "guest", // Placeholder for guest requests
 		ParticipantName: req.ParticipantName,
-		Amount:          amountFloat,
+		Amount:          amount,
 		PaymentMethod:   paymentMethod,
 		Status:          database.AltPaymentStatusPending,
 	}
@@ -478,23 +576,12 @@ func (h *PaymentHandler) getBillPaymentBreakdown(billID uint) (*database.Payment
 		return nil, err
 	}
 
-	// Get alternative payments total
-	altPayments, err := h.db.AlternativePaymentService.GetConfirmedByBillID(billID)
+	// Get confirmed totals from the ledger
+	totals, err := database.GetBillPaymentTotals(billID)
 	if err != nil {
 		return nil, err
 	}
 
-	var alternativePaid float64
-	for _, payment := range altPayments {
-		alternativePaid += payment.Amount
-	}
-
-	// Calculate crypto paid (total paid - alternative paid)
-	cryptoPaid := bill.PaidAmount - alternativePaid
-	if cryptoPaid < 0 {
-		cryptoPaid = 0
-	}
-
 	remaining := bill.TotalAmount - bill.PaidAmount
 	if remaining < 0 {
 		remaining = 0
@@ -502,8 +589,8 @@ func (h *PaymentHandler) getBillPaymentBreakdown(billID uint) (*database.Payment
 
 	breakdown := &database.PaymentBreakdown{
 		TotalAmount:     bill.TotalAmount,
-		CryptoPaid:      cryptoPaid,
-		AlternativePaid: alternativePaid,
+		CryptoPaid:      totals.CryptoAmount,
+		AlternativePaid: totals.AlternativeAmount,
 		Remaining:       remaining,
 		IsComplete:      bill.Status == database.BillStatusPaid,
 	}
@@ -511,17 +598,19 @@ func (h *PaymentHandler) getBillPaymentBreakdown(billID uint) (*database.Payment
 	return breakdown, nil
 }
 
-// CryptoPaymentRequest represents a crypto payment request
+// CryptoPaymentRequest represents a crypto payment request. Amounts are read from the
+// transaction's PaymentMade events, so the guest only reports the transaction hash.
 type CryptoPaymentRequest struct {
-	TransactionHash   string  `json:"transaction_hash" binding:"required"`
-	AmountPaid        float64 `json:"amount_paid" binding:"required"`
-	TipAmount         float64 `json:"tip_amount"`
-	PaymentMethod     string  `json:"payment_method" binding:"required"`
-	BlockchainNetwork string  `json:"blockchain_network"`
+	TransactionHash   string `json:"transaction_hash" binding:"required"`
+	PaymentMethod     string `json:"payment_method" binding:"required"`
+	BlockchainNetwork string `json:"blockchain_network"`
 }
 
-// ProcessCryptoPayment handles crypto payment completion
-// POST /api/v1/inside/bills/:bill_id/crypto-payment
+// ProcessCryptoPayment handles crypto payment completion. The payment is confirmed
+// only from the transaction's events on chain once its block is final; until then it
+// is recorded as pending for the indexer to confirm. A transaction that cannot be
+// verified yet is not recorded at all, the indexer records it when its event is final.
+// POST /api/v1/guest/bills/:bill_id/crypto-payment
 func (h *PaymentHandler) ProcessCryptoPayment(c *gin.Context) {
 	billIDStr := c.Param("bill_id")
 	billID, err := strconv.ParseUint(billIDStr, 10, 32)
@@ -551,9 +640,9 @@ func (h *PaymentHandler) ProcessCryptoPayment(c *gin.Context) {
 		nonce := fmt.Sprintf("bill_%d_%d", billID, time.Now().Unix())
 
 		_, err = h.blockchain.CreateBill(
-			fmt.Sprintf("%d", billID),   // Use database bill ID as blockchain bill ID
-			bill.SettlementAddr,         // Business address
-			int64(bill.TotalAmount*1e6), // Convert to USDC wei (6 decimals)
+			fmt.Sprintf("%d", billID), // Use database bill ID as blockchain bill ID
+			bill.SettlementAddr,       // Business address
+			bill.TotalAmount,
 			metadata,
 			nonce,
 		)
@@ -563,57 +652,105 @@ func (h *PaymentHandler) ProcessCryptoPayment(c *gin.Context) {
 		}
 	}
 
-	// Update bill with payment information
-	bill.PaidAmount += req.AmountPaid  // Only add the bill amount, not the tip
-	bill.TipAmount += req.TipAmount    // Track tips separately
+	var events []blockchain.Payment
+	var final bool
+	if h.events != nil {
+		events, final, err = h.events.PaymentEventsForBill(c.Request.Context(), req.TransactionHash, billIDStr, h.confirmations)
+		if errors.Is(err, blockchain.ErrTransactionFailed) || errors.Is(err, blockchain.ErrPaymentNotFound) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	// Check if bill is fully paid (tips don't count toward bill completion)
-	if bill.PaidAmount >= bill.TotalAmount {
-		bill.Status = database.BillStatusPaid
+	if h.events == nil || err != nil {
+		// Not verifiable yet: nothing is recorded, the indexer records the payment once
+		// its event is final
+		c.JSON(http.StatusAccepted, gin.H{
+			"success":          true,
+			"message":          "Payment is pending confirmation on chain",
+			"bill_id":          billID,
+			"transaction_hash": req.TransactionHash,
+			"payment_status":   database.PaymentStatusPending,
+			"bill_status":      bill.Status,
+			"remaining_amount": bill.TotalAmount - bill.PaidAmount,
+		})
+		return
 	}
-	// Note: We keep the bill as "open" if not fully paid
 
-	// Update the bill in database
-	err = h.db.UpdateBill(bill)
+	updated, recorded, err := h.recordChainPayments(uint(billID), events, final, database.BillActorGuest)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bill"})
+		c.JSON(http.StatusConflict, gin.H{"error": "Transaction could not be recorded for this bill"})
 		return
 	}
+	if recorded {
+		h.notifySplit(uint(billID))
+	}
 
-	// Create payment record
-	payment := &database.Payment{
-		BillID:    uint(billID),
-		PayerAddr: "crypto_guest", // Placeholder for crypto payments
-		Amount:    req.AmountPaid,
-		TipAmount: req.TipAmount,
-		TxHash:    req.TransactionHash,
-		Status:    database.PaymentStatusConfirmed,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	var amountPaid, tipAmount money.Amount
+	for _, event := range events {
+		amountPaid += event.Amount
+		tipAmount += event.TipAmount
 	}
 
-	err = database.CreatePayment(payment)
-	if err != nil {
-		// Log error but don't fail the request since bill was already updated
-		// TODO: Add proper logging
+	// Until the block is final the payment is recorded as pending and does not count
+	// towards the bill yet
+	status, message, code := database.PaymentStatusConfirmed, "Payment processed successfully", http.StatusOK
+	if !final {
+		status, message, code = database.PaymentStatusPending, "Payment is pending confirmation on chain", http.StatusAccepted
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	c.JSON(code, gin.H{
 		"success":          true,
-		"message":          "Payment processed successfully",
+		"message":          message,
 		"bill_id":          billID,
 		"transaction_hash": req.TransactionHash,
-		"amount_paid":      req.AmountPaid,
-		"tip_amount":       req.TipAmount,
-		"bill_status":      bill.Status,
-		"remaining_amount": bill.TotalAmount - bill.PaidAmount,
+		"payment_status":   status,
+		"amount_paid":      amountPaid,
+		"tip_amount":       tipAmount,
+		"bill_status":      updated.Status,
+		"remaining_amount": updated.TotalAmount - updated.PaidAmount,
+		"duplicate":        !recorded,
 	})
 }
 
+// recordChainPayments records verified PaymentMade events, as confirmed payments once
+// final and as pending ones before that. Events whose amounts are not whole cents are
+// flagged, as the indexer does. Events already in the ledger are no-ops; the flag
+// reports whether any was new.
+func (h *PaymentHandler) recordChainPayments(billID uint, events []blockchain.Payment, final bool, actor string) (*database.Bill, bool, error) {
+	var bill *database.Bill
+	recorded := false
+	for _, event := range events {
+		status := database.PaymentStatusPending
+		if final {
+			status = database.PaymentStatusConfirmed
+			if event.AmountError != "" {
+				status = database.PaymentStatusFlagged
+			}
+		}
+		logIndex := event.LogIndex
+		updated, isNew, err := h.db.RecordPayment(&database.Payment{
+			BillID:    billID,
+			PayerAddr: event.Payer,
+			Amount:    event.Amount,
+			TipAmount: event.TipAmount,
+			TxHash:    event.TransactionHash,
+			LogIndex:  &logIndex,
+			Status:    status,
+		}, actor)
+		if err != nil {
+			return nil, false, err
+		}
+		bill = updated
+		recorded = recorded || isNew
+	}
+	return bill, recorded, nil
+}
+
 // CreateOnChainBillRequest represents a request to create a bill on-chain
 type CreateOnChainBillRequest struct {
-	BusinessAddress string  `json:"business_address" binding:"required"`
-	TotalAmount     float64 `json:"total_amount" binding:"required"`
+	BusinessAddress string       `json:"business_address" binding:"required"`
+	TotalAmount     money.Amount `json:"total_amount" binding:"required"`
 }
 
 // CreateOnChainBill creates a bill on the blockchain
@@ -646,9 +783,9 @@ func (h *PaymentHandler) CreateOnChainBill(c *gin.Context) {
 		nonce := fmt.Sprintf("bill_%d_%d", billID, time.Now().Unix())
 
 		result, err := h.blockchain.CreateBill(
-			fmt.Sprintf("%d", billID),  // Use database bill ID as blockchain bill ID
-			req.BusinessAddress,        // Business address from request
-			int64(req.TotalAmount*1e6), // Convert to USDC wei (6 decimals)
+			fmt.Sprintf("%d", billID), // Use database bill ID as blockchain bill ID
+			req.BusinessAddress,       // Business address from request
+			req.TotalAmount,
 			metadata,
 			nonce,
 		)
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -10,14 +11,21 @@ import (
 	"github.com/gin-gonic/gin"
 	"payverge/internal/blockchain"
 	"payverge/internal/database"
+	"payverge/internal/money"
 	"payverge/internal/splitting"
 )
 
+// SplitNotifier pushes the per-share status of a bill's split to connected guests
+type SplitNotifier interface {
+	NotifySplitSession(billID uint)
+}
+
 // SplittingHandler handles bill splitting requests
 type SplittingHandler struct {
 	db         *database.DB
 	splitter   *splitting.SplittingService
 	blockchain *blockchain.BlockchainService
+	notifier   SplitNotifier
 }
 
 // NewSplittingHandler creates a new splitting handler
@@ -29,6 +37,18 @@ func NewSplittingHandler(db *database.DB, blockchainService *blockchain.Blockcha
 	}
 }
 
+// SetNotifier sets where split session updates are pushed
+func (h *SplittingHandler) SetNotifier(notifier SplitNotifier) {
+	h.notifier = notifier
+}
+
+// notifySplit pushes the bill's split status if a notifier is configured
+func (h *SplittingHandler) notifySplit(billID uint) {
+	if h.notifier != nil {
+		h.notifier.NotifySplitSession(billID)
+	}
+}
+
 // CalculateEqualSplit calculates equal split for a bill
 // POST /api/v1/bills/:id/split/equal
 func (h *SplittingHandler) CalculateEqualSplit(c *gin.Context) {
@@ -60,6 +80,11 @@ func (h *SplittingHandler) CalculateEqualSplit(c *gin.Context) {
 		return
 	}
 
+	if err := splitting.ApplyTip(result, req.Tip); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"result":  result,
@@ -102,6 +127,11 @@ func (h *SplittingHandler) CalculateCustomSplit(c *gin.Context) {
 		return
 	}
 
+	if err := splitting.ApplyTip(result, req.Tip); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"result":  result,
@@ -138,12 +168,160 @@ func (h *SplittingHandler) CalculateItemSplit(c *gin.Context) {
 		return
 	}
 
-	result, err := h.splitter.CalculateItemSplit(req.BillID, req.ItemSelections, req.People)
+	result, err := h.splitter.CalculateItemSplit(req.BillID, req.ItemSelections, req.ItemPortions, req.People)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	if err := splitting.ApplyTip(result, req.Tip); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// CalculateWeightedSplit splits a bill by relative weights
+// POST /api/v1/bills/:id/split/weighted
+func (h *SplittingHandler) CalculateWeightedSplit(c *gin.Context) {
+	billIDStr := c.Param("bill_id")
+	billID, err := strconv.ParseUint(billIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	var req splitting.WeightedSplitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Override bill ID from URL parameter
+	req.BillID = uint(billID)
+
+	if len(req.Weights) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Weights cannot be empty"})
+		return
+	}
+
+	if len(req.Weights) > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot split between more than 20 people"})
+		return
+	}
+
+	result, err := h.splitter.CalculateWeightedSplit(req.BillID, req.Weights, req.People)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := splitting.ApplyTip(result, req.Tip); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// CalculatePercentageSplit splits a bill by percentages
+// POST /api/v1/bills/:id/split/percentage
+func (h *SplittingHandler) CalculatePercentageSplit(c *gin.Context) {
+	billIDStr := c.Param("bill_id")
+	billID, err := strconv.ParseUint(billIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	var req splitting.PercentageSplitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Override bill ID from URL parameter
+	req.BillID = uint(billID)
+
+	if len(req.Percentages) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Percentages cannot be empty"})
+		return
+	}
+
+	if len(req.Percentages) > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot split between more than 20 people"})
+		return
+	}
+
+	result, err := h.splitter.CalculatePercentageSplit(req.BillID, req.Percentages, req.People)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := splitting.ApplyTip(result, req.Tip); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// CalculateMixedSplit charges claimed items to their people and splits the rest equally
+// POST /api/v1/bills/:id/split/mixed
+func (h *SplittingHandler) CalculateMixedSplit(c *gin.Context) {
+	billIDStr := c.Param("bill_id")
+	billID, err := strconv.ParseUint(billIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	var req splitting.MixedSplitRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Override bill ID from URL parameter
+	req.BillID = uint(billID)
+
+	if len(req.ItemSelections) == 0 && len(req.People) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "People cannot be empty"})
+		return
+	}
+
+	if len(req.ItemSelections) > 20 || len(req.People) > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot split between more than 20 people"})
+		return
+	}
+
+	result, err := h.splitter.CalculateMixedSplit(req.BillID, req.ItemSelections, req.ItemPortions, req.People)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := splitting.ApplyTip(result, req.Tip); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(result.Splits) > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot split between more than 20 people"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"result":  result,
@@ -213,6 +391,25 @@ func (h *SplittingHandler) GetBillSplitOptions(c *gin.Context) {
 				"max_people":   20,
 				"total_items":  len(billItems),
 			},
+			"weighted": gin.H{
+				"available":   true,
+				"description": "Split in proportion to a weight for each person",
+				"min_people":  1,
+				"max_people":  20,
+			},
+			"percentage": gin.H{
+				"available":   true,
+				"description": "Specify the percentage each person pays",
+				"min_people":  1,
+				"max_people":  20,
+			},
+			"mixed": gin.H{
+				"available":   len(billItems) > 0,
+				"description": "Pay for your own items and split the rest equally",
+				"min_people":  1,
+				"max_people":  20,
+				"total_items": len(billItems),
+			},
 		},
 	})
 }
@@ -230,9 +427,13 @@ func (h *SplittingHandler) ValidateSplit(c *gin.Context) {
 	var req struct {
 		Method          string                     `json:"method"`
 		NumPeople       int                        `json:"num_people,omitempty"`
-		Amounts         map[string]float64         `json:"amounts,omitempty"`
+		Amounts         map[string]money.Amount    `json:"amounts,omitempty"`
 		ItemSelections  map[string][]string        `json:"item_selections,omitempty"`
+		ItemPortions    map[string]map[string]float64 `json:"item_portions,omitempty"`
+		Weights         map[string]float64         `json:"weights,omitempty"`
+		Percentages     map[string]float64         `json:"percentages,omitempty"`
 		People          map[string]string          `json:"people,omitempty"`
+		Tip             *splitting.TipPolicy       `json:"tip,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -260,12 +461,34 @@ func (h *SplittingHandler) ValidateSplit(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Item selections are required for item split"})
 			return
 		}
-		result, err = h.splitter.CalculateItemSplit(uint(billID), req.ItemSelections, req.People)
+		result, err = h.splitter.CalculateItemSplit(uint(billID), req.ItemSelections, req.ItemPortions, req.People)
+	case "weighted":
+		if len(req.Weights) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Weights are required for weighted split"})
+			return
+		}
+		result, err = h.splitter.CalculateWeightedSplit(uint(billID), req.Weights, req.People)
+	case "percentage":
+		if len(req.Percentages) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Percentages are required for percentage split"})
+			return
+		}
+		result, err = h.splitter.CalculatePercentageSplit(uint(billID), req.Percentages, req.People)
+	case "mixed":
+		if len(req.ItemSelections) == 0 && len(req.People) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "People are required for mixed split"})
+			return
+		}
+		result, err = h.splitter.CalculateMixedSplit(uint(billID), req.ItemSelections, req.ItemPortions, req.People)
 	default:
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid split method. Must be 'equal', 'custom', or 'items'"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid split method. Must be 'equal', 'custom', 'items', 'weighted', 'percentage' or 'mixed'"})
 		return
 	}
 
+	if err == nil {
+		err = splitting.ApplyTip(result, req.Tip)
+	}
+
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -398,14 +621,23 @@ func (h *SplittingHandler) GetBillSummaryWithParticipants(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-// ExecuteSplitPayment coordinates a split payment execution
+// ExecuteSplitPayment stores the split the guests agreed on so that each share can be
+// matched to the payments made for it. The shares are recalculated from the bill
+// rather than taken from the request.
 // POST /api/v1/bills/:id/split/execute
 func (h *SplittingHandler) ExecuteSplitPayment(c *gin.Context) {
 	billIDStr := c.Param("bill_id")
-	
+	billID, err := strconv.ParseUint(billIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
 	var req struct {
-		SplitResult *splitting.SplitResult `json:"split_result"`
-		PaymentInfo map[string]interface{} `json:"payment_info"`
+		SplitResult  *splitting.SplitResult `json:"split_result"`
+		PaymentInfo  map[string]interface{} `json:"payment_info"`
+		Participants map[string]string      `json:"participants"` // person_id -> wallet address
+		Tip          *splitting.TipPolicy   `json:"tip,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -418,12 +650,141 @@ func (h *SplittingHandler) ExecuteSplitPayment(c *gin.Context) {
 		return
 	}
 
-	// Store split execution info (this would typically trigger payment processing)
+	if len(req.SplitResult.Splits) > 20 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot split between more than 20 people"})
+		return
+	}
+
+	bill, err := h.db.GetBill(uint(billID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+		return
+	}
+
+	result, err := h.splitter.Recalculate(uint(billID), req.SplitResult, req.Tip)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.splitter.CreateSession(uint(billID), result, req.Participants, database.BillActorGuest)
+	if err != nil {
+		if errors.Is(err, database.ErrSplitSessionLocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.notifySplit(uint(billID))
+
+	// Each unpaid share is paid with one payBill call carrying what is left of the
+	// person's tip
+	payments := make([]gin.H, 0, len(session.Shares))
+	for _, share := range session.Shares {
+		if share.Status == database.SplitSharePaid {
+			continue
+		}
+		tip := share.TipAmount - share.PaidTip
+		if tip < 0 {
+			tip = 0
+		}
+		call, err := h.payBillCall(billIDStr, bill, share.Amount-share.PaidAmount, tip)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to prepare payment: " + err.Error()})
+			return
+		}
+		payments = append(payments, gin.H{
+			"person_id":       share.PersonID,
+			"payer_address":   share.PayerAddr,
+			"amount_with_tip": call.Amount + call.TipAmount,
+			"call":            call,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"message":        "Split payment execution initiated",
+		"bill_id":        billIDStr,
+		"method":         session.Method,
+		"splits":         len(session.Shares),
+		"tip_amount":     result.TipAmount,
+		"total_with_tip": result.TotalWithTip,
+		"session":        session,
+		"payments":       payments,
+	})
+}
+
+// payBillCall prepares the contract call paying a share of the bill to the business's
+// settlement address and its tip to the business's tipping address
+func (h *SplittingHandler) payBillCall(billID string, bill *database.Bill, amount, tip money.Amount) (*blockchain.PayBillCall, error) {
+	if h.blockchain != nil {
+		return h.blockchain.NewPayBillCall(billID, amount, tip, bill.SettlementAddr, bill.TippingAddr)
+	}
+	return blockchain.NewPayBillCall(billID, amount, tip, bill.SettlementAddr, bill.TippingAddr)
+}
+
+// GetSplitSession returns the bill's current split and the payment status of each share
+// GET /api/v1/bills/:id/split/session
+func (h *SplittingHandler) GetSplitSession(c *gin.Context) {
+	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	session, err := h.db.GetSplitSession(uint(billID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill has not been split"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"session": session,
+	})
+}
+
+// ClaimSplitShare ties a share of the bill's split to the wallet that will pay it
+// POST /api/v1/bills/:id/split/session/claim
+func (h *SplittingHandler) ClaimSplitShare(c *gin.Context) {
+	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return
+	}
+
+	var req struct {
+		PersonID     string `json:"person_id" binding:"required"`
+		PayerAddress string `json:"payer_address" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	session, err := h.db.ClaimSplitShare(uint(billID), req.PersonID, req.PayerAddress)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrSplitShareNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, database.ErrSplitShareClaimed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	h.notifySplit(uint(billID))
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "Split payment execution initiated",
-		"bill_id": billIDStr,
-		"method":  req.SplitResult.Method,
-		"splits":  len(req.SplitResult.Splits),
+		"session": session,
 	})
 }
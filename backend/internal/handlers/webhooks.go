@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"payverge/internal/blockchain"
+)
+
+// Headers carried by signed payment webhooks
+const (
+	WebhookProviderHeader  = "X-Webhook-Provider"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookNonceHeader     = "X-Webhook-Nonce"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+// DefaultWebhookTolerance is how far a webhook timestamp may drift from the server clock
+const DefaultWebhookTolerance = 5 * time.Minute
+
+const maxWebhookNonceLength = 128
+
+var (
+	errUnknownWebhookProvider  = errors.New("unknown webhook provider")
+	errInvalidWebhookSignature = errors.New("invalid webhook signature")
+	errStaleWebhook            = errors.New("webhook timestamp outside tolerance window")
+)
+
+// WebhookConfig holds the shared secrets of the providers allowed to call the payment webhook
+type WebhookConfig struct {
+	// Secrets maps a provider name to its HMAC shared secret
+	Secrets map[string]string
+	// Tolerance bounds the accepted clock difference of a request timestamp
+	Tolerance time.Duration
+}
+
+// paymentEventSource looks up the on-chain payment events a webhook refers to
+type paymentEventSource interface {
+	PaymentEventsForBill(ctx context.Context, txHash string, billID string, confirmations uint64) ([]blockchain.Payment, bool, error)
+}
+
+// ParseWebhookSecrets parses a comma separated list of provider:secret pairs
+func ParseWebhookSecrets(value string) (map[string]string, error) {
+	secrets := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		provider, secret, ok := strings.Cut(entry, ":")
+		provider = strings.TrimSpace(provider)
+		if !ok || provider == "" || secret == "" {
+			return nil, fmt.Errorf("invalid webhook secret entry %q, expected provider:secret", entry)
+		}
+		secrets[provider] = secret
+	}
+	return secrets, nil
+}
+
+// SignWebhook returns the hex encoded HMAC-SHA256 signature of a webhook delivery. The
+// signed message is "<timestamp>.<nonce>.<body>" so that neither header can be swapped.
+func SignWebhook(secret string, timestamp int64, nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write([]byte(nonce))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookDelivery is the authenticated envelope of a webhook request
+type webhookDelivery struct {
+	provider  string
+	nonce     string
+	timestamp time.Time
+}
+
+// verify checks the provider, timestamp and signature of a webhook request
+func (cfg WebhookConfig) verify(provider, timestampHeader, nonce, signature string, body []byte, now time.Time) (*webhookDelivery, error) {
+	secret, ok := cfg.Secrets[provider]
+	if !ok || secret == "" {
+		return nil, errUnknownWebhookProvider
+	}
+
+	if nonce == "" || len(nonce) > maxWebhookNonceLength {
+		return nil, errInvalidWebhookSignature
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return nil, errStaleWebhook
+	}
+	tolerance := cfg.window()
+	sent := time.Unix(timestamp, 0)
+	if sent.Before(now.Add(-tolerance)) || sent.After(now.Add(tolerance)) {
+		return nil, errStaleWebhook
+	}
+
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return nil, errInvalidWebhookSignature
+	}
+	expected, _ := hex.DecodeString(SignWebhook(secret, timestamp, nonce, body))
+	if !hmac.Equal(given, expected) {
+		return nil, errInvalidWebhookSignature
+	}
+
+	return &webhookDelivery{provider: provider, nonce: nonce, timestamp: sent}, nil
+}
+
+// nonceExpiry is when a nonce can be forgotten: after that its timestamp is rejected anyway
+func (cfg WebhookConfig) nonceExpiry(delivery *webhookDelivery) time.Time {
+	return delivery.timestamp.Add(cfg.window())
+}
+
+func (cfg WebhookConfig) window() time.Duration {
+	if cfg.Tolerance <= 0 {
+		return DefaultWebhookTolerance
+	}
+	return cfg.Tolerance
+}
@@ -0,0 +1,279 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"payverge/internal/blockchain"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+const (
+	testWebhookProvider = "relay"
+	testWebhookSecret   = "s3cret"
+	testWebhookTx       = "0x1111111111111111111111111111111111111111111111111111111111111111"
+)
+
+type stubPaymentEvents struct {
+	payments []blockchain.Payment
+	shallow  bool // The transaction's block does not have enough confirmations yet
+	err      error
+	calls    int
+}
+
+func (s *stubPaymentEvents) PaymentEventsForBill(ctx context.Context, txHash string, billID string, confirmations uint64) ([]blockchain.Payment, bool, error) {
+	s.calls++
+	return s.payments, !s.shallow, s.err
+}
+
+func setupWebhookTest(t *testing.T) (*PaymentHandler, *stubPaymentEvents, *database.Bill, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+
+	conn := dbtest.Open(t)
+	database.InitTestDB(conn)
+
+	bill := &database.Bill{BusinessID: 1, TableID: 1, BillNumber: "WEBHOOK-1", TotalAmount: money.MustParse("30.00"), Status: database.BillStatusOpen}
+	require.NoError(t, database.CreateBill(bill, []database.BillItem{}))
+
+	events := &stubPaymentEvents{}
+	handler := NewPaymentHandler(database.NewDB(), nil)
+	handler.events = events
+	handler.ConfigureWebhooks(WebhookConfig{
+		Secrets:   map[string]string{testWebhookProvider: testWebhookSecret},
+		Tolerance: time.Minute,
+	})
+
+	router := gin.New()
+	router.POST("/payments/webhook", handler.WebhookPaymentConfirmation)
+	return handler, events, bill, router
+}
+
+func webhookRequest(body string, timestamp time.Time, nonce, secret string) *http.Request {
+	ts := timestamp.Unix()
+	req := httptest.NewRequest(http.MethodPost, "/payments/webhook", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookProviderHeader, testWebhookProvider)
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(WebhookNonceHeader, nonce)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(secret, ts, nonce, []byte(body)))
+	return req
+}
+
+func serve(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func reloadBill(t *testing.T, id uint) *database.Bill {
+	bill, _, err := database.GetBillByID(id)
+	require.NoError(t, err)
+	return bill
+}
+
+func TestWebhookRecordsVerifiedChainPayment(t *testing.T) {
+	_, events, bill, router := setupWebhookTest(t)
+	billID := strconv.FormatUint(uint64(bill.ID), 10)
+	events.payments = []blockchain.Payment{{
+		BillID: billID, Payer: "0xpayer", Amount: money.MustParse("30.00"), TipAmount: money.MustParse("2.00"),
+		TransactionHash: testWebhookTx, LogIndex: 3,
+	}}
+
+	// The body's amount is ignored in favour of the chain's
+	body := `{"transaction_hash":"` + testWebhookTx + `","bill_id":"` + billID + `","amount":999000000,"status":"confirmed"}`
+	w := serve(router, webhookRequest(body, time.Now(), "nonce-1", testWebhookSecret))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	updated := reloadBill(t, bill.ID)
+	assert.Equal(t, money.MustParse("30.00"), updated.PaidAmount)
+	assert.Equal(t, money.MustParse("2.00"), updated.TipAmount)
+	assert.Equal(t, database.BillStatusPaid, updated.Status)
+
+	// A new delivery for the same transaction does not count it twice
+	w = serve(router, webhookRequest(body, time.Now(), "nonce-2", testWebhookSecret))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"duplicate":true`)
+	assert.Equal(t, money.MustParse("30.00"), reloadBill(t, bill.ID).PaidAmount)
+}
+
+// A payment whose block is not final yet is only recorded as pending; the indexer
+// confirms it once it reaches the block
+func TestWebhookLeavesUnconfirmedPaymentPending(t *testing.T) {
+	_, events, bill, router := setupWebhookTest(t)
+	billID := strconv.FormatUint(uint64(bill.ID), 10)
+	events.shallow = true
+	events.payments = []blockchain.Payment{{
+		BillID: billID, Payer: "0xpayer", Amount: money.MustParse("30.00"), TransactionHash: testWebhookTx, LogIndex: 3,
+	}}
+
+	body := `{"transaction_hash":"` + testWebhookTx + `","bill_id":"` + billID + `"}`
+	w := serve(router, webhookRequest(body, time.Now(), "shallow", testWebhookSecret))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, money.Zero, reloadBill(t, bill.ID).PaidAmount)
+
+	payments, err := database.GetPaymentsByBillID(bill.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, database.PaymentStatusPending, payments[0].Status)
+
+	logIndex := uint(3)
+	_, _, err = database.RecordPayment(&database.Payment{
+		BillID: bill.ID, PayerAddr: "0xpayer", Amount: money.MustParse("30.00"), TxHash: testWebhookTx, LogIndex: &logIndex,
+		Status: database.PaymentStatusConfirmed,
+	}, database.BillActorIndexer)
+	require.NoError(t, err)
+	assert.Equal(t, database.BillStatusPaid, reloadBill(t, bill.ID).Status)
+}
+
+func TestWebhookRejectsUnauthenticatedRequests(t *testing.T) {
+	_, events, bill, router := setupWebhookTest(t)
+	body := `{"transaction_hash":"` + testWebhookTx + `","bill_id":"` + strconv.FormatUint(uint64(bill.ID), 10) + `"}`
+
+	t.Run("bad signature", func(t *testing.T) {
+		w := serve(router, webhookRequest(body, time.Now(), "bad-sig", "wrong-secret"))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		req := webhookRequest(body, time.Now(), "tampered", testWebhookSecret)
+		req.Body = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body+" ")).Body
+		assert.Equal(t, http.StatusUnauthorized, serve(router, req).Code)
+	})
+
+	t.Run("stale timestamp", func(t *testing.T) {
+		w := serve(router, webhookRequest(body, time.Now().Add(-2*time.Minute), "stale", testWebhookSecret))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		req := webhookRequest(body, time.Now(), "unknown", testWebhookSecret)
+		req.Header.Set(WebhookProviderHeader, "someone-else")
+		assert.Equal(t, http.StatusUnauthorized, serve(router, req).Code)
+	})
+
+	assert.Zero(t, events.calls)
+	assert.Equal(t, money.Zero, reloadBill(t, bill.ID).PaidAmount)
+}
+
+func TestWebhookRejectsReplayedNonce(t *testing.T) {
+	_, events, bill, router := setupWebhookTest(t)
+	billID := strconv.FormatUint(uint64(bill.ID), 10)
+	events.payments = []blockchain.Payment{{BillID: billID, Payer: "0xpayer", Amount: money.MustParse("10.00"), TransactionHash: testWebhookTx}}
+	body := `{"transaction_hash":"` + testWebhookTx + `","bill_id":"` + billID + `"}`
+
+	now := time.Now()
+	require.Equal(t, http.StatusOK, serve(router, webhookRequest(body, now, "once", testWebhookSecret)).Code)
+	assert.Equal(t, http.StatusConflict, serve(router, webhookRequest(body, now, "once", testWebhookSecret)).Code)
+	assert.Equal(t, 1, events.calls)
+}
+
+// A delivery that fails verification does not use up its nonce, so the provider can
+// retry it once the transaction is mined
+func TestWebhookNonceReleasedWhenDeliveryFails(t *testing.T) {
+	_, events, bill, router := setupWebhookTest(t)
+	billID := strconv.FormatUint(uint64(bill.ID), 10)
+	body := `{"transaction_hash":"` + testWebhookTx + `","bill_id":"` + billID + `"}`
+	now := time.Now()
+
+	events.err = blockchain.ErrTransactionNotFound
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(router, webhookRequest(body, now, "retry", testWebhookSecret)).Code)
+
+	events.err = nil
+	events.payments = []blockchain.Payment{{BillID: billID, Payer: "0xpayer", Amount: money.MustParse("10.00"), TransactionHash: testWebhookTx}}
+	require.Equal(t, http.StatusOK, serve(router, webhookRequest(body, now, "retry", testWebhookSecret)).Code)
+	assert.Equal(t, http.StatusConflict, serve(router, webhookRequest(body, now, "retry", testWebhookSecret)).Code)
+	assert.Equal(t, money.MustParse("10.00"), reloadBill(t, bill.ID).PaidAmount)
+}
+
+func TestWebhookWithoutChainPaymentChangesNothing(t *testing.T) {
+	_, events, bill, router := setupWebhookTest(t)
+	body := `{"transaction_hash":"` + testWebhookTx + `","bill_id":"` + strconv.FormatUint(uint64(bill.ID), 10) + `","amount":30000000,"status":"confirmed"}`
+
+	events.err = blockchain.ErrTransactionNotFound
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(router, webhookRequest(body, time.Now(), "missing", testWebhookSecret)).Code)
+
+	events.err = blockchain.ErrPaymentNotFound
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(router, webhookRequest(body, time.Now(), "other-bill", testWebhookSecret)).Code)
+
+	payments, err := database.GetPaymentsByBillID(bill.ID)
+	require.NoError(t, err)
+	assert.Empty(t, payments)
+	assert.Equal(t, money.Zero, reloadBill(t, bill.ID).PaidAmount)
+	assert.Equal(t, database.BillStatusOpen, reloadBill(t, bill.ID).Status)
+}
+
+func TestWebhookDisabledWithoutSecrets(t *testing.T) {
+	handler, _, bill, router := setupWebhookTest(t)
+	handler.ConfigureWebhooks(WebhookConfig{})
+	body := `{"transaction_hash":"` + testWebhookTx + `","bill_id":"` + strconv.FormatUint(uint64(bill.ID), 10) + `"}`
+	assert.Equal(t, http.StatusServiceUnavailable, serve(router, webhookRequest(body, time.Now(), "n", testWebhookSecret)).Code)
+}
+
+// Guests only report the transaction hash; the amounts and the confirmation come from
+// the chain, and a transaction that is not final yet stays pending
+func TestCryptoPaymentConfirmedFromChain(t *testing.T) {
+	handler, events, bill, router := setupWebhookTest(t)
+	router.POST("/guest/bills/:bill_id/crypto-payment", handler.ProcessCryptoPayment)
+	billID := strconv.FormatUint(uint64(bill.ID), 10)
+	body := `{"transaction_hash":"` + testWebhookTx + `","payment_method":"usdc","amount_paid":30000000}`
+	report := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/guest/bills/"+billID+"/crypto-payment", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		return req
+	}
+
+	// Nothing is recorded for a transaction that is not mined yet; the indexer records it
+	events.err = blockchain.ErrTransactionNotFound
+	w := serve(router, report())
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, money.Zero, reloadBill(t, bill.ID).PaidAmount)
+	payments, err := database.GetPaymentsByBillID(bill.ID)
+	require.NoError(t, err)
+	assert.Empty(t, payments)
+
+	events.err = blockchain.ErrPaymentNotFound
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(router, report()).Code)
+
+	// A payment whose block is not final yet stays pending
+	events.err = nil
+	events.shallow = true
+	events.payments = []blockchain.Payment{{
+		BillID: billID, Payer: "0xpayer", Amount: money.MustParse("12.00"), TipAmount: money.MustParse("1.00"),
+		TransactionHash: testWebhookTx, LogIndex: 1,
+	}}
+	w = serve(router, report())
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"payment_status":"pending"`)
+	assert.Equal(t, money.Zero, reloadBill(t, bill.ID).PaidAmount)
+
+	events.shallow = false
+	w = serve(router, report())
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	updated := reloadBill(t, bill.ID)
+	assert.Equal(t, money.MustParse("12.00"), updated.PaidAmount)
+	assert.Equal(t, money.MustParse("1.00"), updated.TipAmount)
+	payments, err = database.GetPaymentsByBillID(bill.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+	assert.Equal(t, database.PaymentStatusConfirmed, payments[0].Status)
+}
+
+func TestParseWebhookSecrets(t *testing.T) {
+	secrets, err := ParseWebhookSecrets(" relay:abc , stripe:x:y ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"relay": "abc", "stripe": "x:y"}, secrets)
+
+	_, err = ParseWebhookSecrets("missing-secret")
+	assert.Error(t, err)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"payverge/internal/database"
+	"payverge/internal/money"
 )
 
 type WithdrawalHandler struct {
@@ -21,12 +22,12 @@ func NewWithdrawalHandler(db *database.DB) *WithdrawalHandler {
 
 // CreateWithdrawalRequest represents the request body for creating a withdrawal record
 type CreateWithdrawalRequest struct {
-	TransactionHash   string  `json:"transaction_hash" binding:"required"`
-	PaymentAmount     float64 `json:"payment_amount"`
-	TipAmount         float64 `json:"tip_amount"`
-	TotalAmount       float64 `json:"total_amount" binding:"required"`
-	WithdrawalAddress string  `json:"withdrawal_address" binding:"required"`
-	BlockchainNetwork string  `json:"blockchain_network" binding:"required"`
+	TransactionHash   string       `json:"transaction_hash" binding:"required"`
+	PaymentAmount     money.Amount `json:"payment_amount"`
+	TipAmount         money.Amount `json:"tip_amount"`
+	TotalAmount       money.Amount `json:"total_amount" binding:"required"`
+	WithdrawalAddress string       `json:"withdrawal_address" binding:"required"`
+	BlockchainNetwork string       `json:"blockchain_network" binding:"required"`
 }
 
 // UpdateWithdrawalStatusRequest represents the request body for updating withdrawal status
@@ -49,26 +50,6 @@ func (h *WithdrawalHandler) CreateWithdrawal(c *gin.Context) {
 		return
 	}
 
-	// Verify business exists and user has access
-	var business database.Business
-	if err := h.db.GetGorm().First(&business, businessID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	// Get user address from context (set by auth middleware)
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Verify user owns the business
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
 	// Create withdrawal record
 	withdrawal := database.WithdrawalHistory{
 		BusinessID:        uint(businessID),
@@ -98,26 +79,6 @@ func (h *WithdrawalHandler) GetWithdrawalHistory(c *gin.Context) {
 		return
 	}
 
-	// Verify business exists and user has access
-	var business database.Business
-	if err := h.db.GetGorm().First(&business, businessID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Verify user owns the business
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
 	// Get pagination parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
@@ -173,26 +134,6 @@ func (h *WithdrawalHandler) GetWithdrawal(c *gin.Context) {
 		return
 	}
 
-	// Verify business exists and user has access
-	var business database.Business
-	if err := h.db.GetGorm().First(&business, businessID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Verify user owns the business
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
 	// Get withdrawal record
 	var withdrawal database.WithdrawalHistory
 	if err := h.db.GetGorm().Where("id = ? AND business_id = ?", withdrawalID, businessID).First(&withdrawal).Error; err != nil {
@@ -225,26 +166,6 @@ func (h *WithdrawalHandler) UpdateWithdrawalStatus(c *gin.Context) {
 		return
 	}
 
-	// Verify business exists and user has access
-	var business database.Business
-	if err := h.db.GetGorm().First(&business, businessID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Verify user owns the business
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
-		return
-	}
-
 	// Update withdrawal status
 	updates := map[string]interface{}{
 		"status":     req.Status,
@@ -10,37 +10,52 @@ type Challenge struct {
 	ExpiresAt time.Time
 }
 
-type ChallengeStore struct {
+// ChallengeStore keeps the challenges handed out for sign-in until they are used. When
+// several replicas serve the API they must share one store, since the challenge and
+// sign-in requests of a user can land on different replicas. Get may return an expired
+// challenge until Cleanup removes it; callers check ExpiresAt.
+type ChallengeStore interface {
+	Set(address string, challenge Challenge) error
+	Get(address string) (Challenge, bool, error)
+	Delete(address string) error
+	// Cleanup removes expired challenges, for stores that do not expire them on their own
+	Cleanup() error
+}
+
+// MemoryChallengeStore keeps challenges in the process, for a single replica
+type MemoryChallengeStore struct {
 	challenges map[string]Challenge
 	mu         sync.RWMutex
 }
 
-func NewChallengeStore() *ChallengeStore {
-	return &ChallengeStore{
+func NewMemoryChallengeStore() *MemoryChallengeStore {
+	return &MemoryChallengeStore{
 		challenges: make(map[string]Challenge),
 	}
 }
 
-func (cs *ChallengeStore) Set(address string, challenge Challenge) {
+func (cs *MemoryChallengeStore) Set(address string, challenge Challenge) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 	cs.challenges[address] = challenge
+	return nil
 }
 
-func (cs *ChallengeStore) Get(address string) (Challenge, bool) {
+func (cs *MemoryChallengeStore) Get(address string) (Challenge, bool, error) {
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 	challenge, ok := cs.challenges[address]
-	return challenge, ok
+	return challenge, ok, nil
 }
 
-func (cs *ChallengeStore) Delete(address string) {
+func (cs *MemoryChallengeStore) Delete(address string) error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 	delete(cs.challenges, address)
+	return nil
 }
 
-func (cs *ChallengeStore) Cleanup() {
+func (cs *MemoryChallengeStore) Cleanup() error {
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 	for address, challenge := range cs.challenges {
@@ -48,4 +63,5 @@ func (cs *ChallengeStore) Cleanup() {
 			delete(cs.challenges, address)
 		}
 	}
+	return nil
 }
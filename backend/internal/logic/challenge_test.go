@@ -0,0 +1,59 @@
+package logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+)
+
+func newSQLChallengeStore(t *testing.T) ChallengeStore {
+	db := dbtest.Open(t)
+	database.InitTestDB(db)
+	return NewSQLChallengeStore()
+}
+
+// Every store behaves the same, so one can replace another
+func TestChallengeStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) ChallengeStore{
+		"memory": func(t *testing.T) ChallengeStore { return NewMemoryChallengeStore() },
+		"sql":    newSQLChallengeStore,
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			expiresAt := time.Now().Add(5 * time.Minute).Truncate(time.Second)
+
+			_, ok, err := store.Get("0xabc")
+			require.NoError(t, err)
+			assert.False(t, ok)
+
+			require.NoError(t, store.Set("0xabc", Challenge{Value: "first", ExpiresAt: expiresAt}))
+			require.NoError(t, store.Set("0xabc", Challenge{Value: "second", ExpiresAt: expiresAt}))
+			challenge, ok, err := store.Get("0xabc")
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, "second", challenge.Value)
+			assert.True(t, expiresAt.Equal(challenge.ExpiresAt))
+
+			// Cleanup only removes expired challenges
+			require.NoError(t, store.Set("0xold", Challenge{Value: "old", ExpiresAt: time.Now().Add(-time.Minute)}))
+			require.NoError(t, store.Cleanup())
+			_, ok, err = store.Get("0xold")
+			require.NoError(t, err)
+			assert.False(t, ok)
+			_, ok, err = store.Get("0xabc")
+			require.NoError(t, err)
+			assert.True(t, ok)
+
+			require.NoError(t, store.Delete("0xabc"))
+			_, ok, err = store.Get("0xabc")
+			require.NoError(t, err)
+			assert.False(t, ok)
+			assert.NoError(t, store.Delete("0xabc"))
+		})
+	}
+}
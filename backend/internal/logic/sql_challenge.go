@@ -0,0 +1,35 @@
+package logic
+
+import (
+	"time"
+
+	"payverge/internal/database"
+)
+
+// SQLChallengeStore keeps challenges in the database, shared by every replica
+type SQLChallengeStore struct{}
+
+func NewSQLChallengeStore() *SQLChallengeStore {
+	return &SQLChallengeStore{}
+}
+
+func (cs *SQLChallengeStore) Set(address string, challenge Challenge) error {
+	return database.SaveSignInChallenge(address, challenge.Value, challenge.ExpiresAt)
+}
+
+func (cs *SQLChallengeStore) Get(address string) (Challenge, bool, error) {
+	stored, err := database.GetSignInChallenge(address)
+	if err != nil || stored == nil {
+		return Challenge{}, false, err
+	}
+	return Challenge{Value: stored.Value, ExpiresAt: stored.ExpiresAt}, true, nil
+}
+
+func (cs *SQLChallengeStore) Delete(address string) error {
+	return database.DeleteSignInChallenge(address)
+}
+
+func (cs *SQLChallengeStore) Cleanup() error {
+	_, err := database.DeleteExpiredSignInChallenges(time.Now())
+	return err
+}
@@ -0,0 +1,187 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"payverge/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// accessKey is the context key RequirePermission stores the caller's access under
+const accessKey = "business_access"
+
+// errInvalidID is returned by resolvers for a path parameter that is not an ID
+var errInvalidID = errors.New("invalid ID")
+
+// BusinessResolver finds the business a request acts on from its path
+type BusinessResolver func(c *gin.Context) (uint, error)
+
+// BusinessParam resolves the business from a business ID path parameter
+func BusinessParam(name string) BusinessResolver {
+	return func(c *gin.Context) (uint, error) {
+		return pathID(c, name)
+	}
+}
+
+// BillParam resolves the business of the bill in a path parameter
+func BillParam(name string) BusinessResolver {
+	return func(c *gin.Context) (uint, error) {
+		billID, err := pathID(c, name)
+		if err != nil {
+			return 0, err
+		}
+		return database.GetBillBusinessID(billID)
+	}
+}
+
+// TableParam resolves the business of the table in a path parameter
+func TableParam(name string) BusinessResolver {
+	return func(c *gin.Context) (uint, error) {
+		tableID, err := pathID(c, name)
+		if err != nil {
+			return 0, err
+		}
+		table, err := database.GetTableByID(tableID)
+		if err != nil {
+			return 0, err
+		}
+		return table.BusinessID, nil
+	}
+}
+
+// BusinessQuery resolves the business from a business ID query parameter
+func BusinessQuery(name string) BusinessResolver {
+	return func(c *gin.Context) (uint, error) {
+		id, err := strconv.ParseUint(c.Query(name), 10, 32)
+		if err != nil {
+			return 0, errInvalidID
+		}
+		return uint(id), nil
+	}
+}
+
+// TranslationEntity resolves the business of the entity a translation request names by
+// entity_type and entity_id, in the query string of reads and the JSON body of writes.
+// The body stays available to handlers through ShouldBindBodyWith.
+func TranslationEntity() BusinessResolver {
+	return func(c *gin.Context) (uint, error) {
+		var entity struct {
+			EntityType string `form:"entity_type" json:"entity_type"`
+			EntityID   uint   `form:"entity_id" json:"entity_id"`
+		}
+		var err error
+		if c.Request.Method == http.MethodGet {
+			err = c.ShouldBindQuery(&entity)
+		} else {
+			err = c.ShouldBindBodyWith(&entity, binding.JSON)
+		}
+		if err != nil || entity.EntityID == 0 {
+			return 0, errInvalidID
+		}
+		return database.GetTranslationEntityBusinessID(entity.EntityType, entity.EntityID)
+	}
+}
+
+// TranslationJobParam resolves the business a translation job in a path parameter was
+// started for. Job IDs are translate_<business ID>_<timestamp>.
+func TranslationJobParam(name string) BusinessResolver {
+	return func(c *gin.Context) (uint, error) {
+		rest, ok := strings.CutPrefix(c.Param(name), "translate_")
+		if !ok {
+			return 0, errInvalidID
+		}
+		businessID, _, _ := strings.Cut(rest, "_")
+		id, err := strconv.ParseUint(businessID, 10, 32)
+		if err != nil {
+			return 0, errInvalidID
+		}
+		return uint(id), nil
+	}
+}
+
+func pathID(c *gin.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		return 0, errInvalidID
+	}
+	return uint(id), nil
+}
+
+// RequirePermission lets a request through when the caller owns the business it acts
+// on or is active staff whose role has the permission. The caller comes from the
+// address and staff_id set by the authentication middleware; the access granted is
+// available to handlers through Access and Actor.
+func RequirePermission(permission database.Permission, resolve BusinessResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		address := c.GetString("address")
+		staffID := c.GetUint("staff_id")
+		if address == "" && staffID == 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+			return
+		}
+
+		businessID, err := resolve(c)
+		if err != nil {
+			abortAccessError(c, err)
+			return
+		}
+		access, err := database.GetBusinessAccess(businessID, address, staffID)
+		if err != nil {
+			abortAccessError(c, err)
+			return
+		}
+		if !access.Can(permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":      "Missing permission",
+				"permission": permission,
+			})
+			return
+		}
+
+		c.Set(accessKey, access)
+		c.Next()
+	}
+}
+
+func abortAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errInvalidID):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+	case errors.Is(err, database.ErrAccessDenied):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	case errors.Is(err, database.ErrBusinessNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+	case errors.Is(err, database.ErrBillNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+	case errors.Is(err, database.ErrTableNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+	case errors.Is(err, database.ErrTranslationEntityNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Translated entity not found"})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorize request"})
+	}
+}
+
+// Access returns the access RequirePermission granted the request
+func Access(c *gin.Context) (*database.BusinessAccess, bool) {
+	value, exists := c.Get(accessKey)
+	if !exists {
+		return nil, false
+	}
+	access, ok := value.(*database.BusinessAccess)
+	return access, ok
+}
+
+// Actor identifies the caller of an authorized request in audit trails
+func Actor(c *gin.Context) string {
+	access, ok := Access(c)
+	if !ok {
+		return ""
+	}
+	return access.Actor()
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+)
+
+type AuthorizeTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	business *database.Business
+	other    *database.Business
+	staff    map[database.StaffRole]*database.Staff
+}
+
+func (suite *AuthorizeTestSuite) SetupSuite() {
+	gin.SetMode(gin.TestMode)
+
+	db := dbtest.Open(suite.T())
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *AuthorizeTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *AuthorizeTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM bills")
+	suite.db.Exec("DELETE FROM menu_items")
+	suite.db.Exec("DELETE FROM tables")
+	suite.db.Exec("DELETE FROM staff")
+	suite.db.Exec("DELETE FROM businesses")
+
+	suite.business = &database.Business{OwnerAddress: "0xOwner", Name: "Authorize Test"}
+	require.NoError(suite.T(), suite.db.Create(suite.business).Error)
+	suite.other = &database.Business{OwnerAddress: "0xOther", Name: "Other Business"}
+	require.NoError(suite.T(), suite.db.Create(suite.other).Error)
+
+	suite.staff = make(map[database.StaffRole]*database.Staff)
+	for _, role := range []database.StaffRole{database.StaffRoleManager, database.StaffRoleServer, database.StaffRoleHost, database.StaffRoleKitchen} {
+		staff := &database.Staff{
+			BusinessID: suite.business.ID,
+			Email:      fmt.Sprintf("%s@example.com", role),
+			Name:       string(role),
+			Role:       role,
+			IsActive:   true,
+			InvitedBy:  "0xOwner",
+		}
+		require.NoError(suite.T(), suite.db.Create(staff).Error)
+		suite.staff[role] = staff
+	}
+}
+
+func TestAuthorizeTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthorizeTestSuite))
+}
+
+// request runs a request to a route guarded by RequirePermission as the given caller
+// and returns the response and the actor the handler saw
+func (suite *AuthorizeTestSuite) request(permission database.Permission, resolve BusinessResolver, route, path, address string, staffID uint) (*httptest.ResponseRecorder, string) {
+	var actor string
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if address != "" {
+			c.Set("address", address)
+		}
+		if staffID != 0 {
+			c.Set("staff_id", staffID)
+		}
+	})
+	router.GET(route, RequirePermission(permission, resolve), func(c *gin.Context) {
+		actor = Actor(c)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, path, nil)
+	router.ServeHTTP(w, req)
+	return w, actor
+}
+
+func (suite *AuthorizeTestSuite) businessPath() string {
+	return fmt.Sprintf("/businesses/%d", suite.business.ID)
+}
+
+func (suite *AuthorizeTestSuite) TestOwnerHasEveryPermission() {
+	for _, permission := range []database.Permission{database.PermissionFinancialRead, database.PermissionStaffManage, database.PermissionBillsVoid} {
+		w, actor := suite.request(permission, BusinessParam("id"), "/businesses/:id", suite.businessPath(), "0xOwner", 0)
+		assert.Equal(suite.T(), http.StatusOK, w.Code, permission)
+		assert.Equal(suite.T(), "0xOwner", actor)
+	}
+}
+
+func (suite *AuthorizeTestSuite) TestStaffAreLimitedToTheirRole() {
+	cases := []struct {
+		role       database.StaffRole
+		permission database.Permission
+		allowed    bool
+	}{
+		{database.StaffRoleManager, database.PermissionMenuWrite, true},
+		{database.StaffRoleManager, database.PermissionFinancialRead, false},
+		{database.StaffRoleServer, database.PermissionBillsClose, true},
+		{database.StaffRoleServer, database.PermissionBillsVoid, false},
+		{database.StaffRoleHost, database.PermissionTablesSeat, true},
+		{database.StaffRoleHost, database.PermissionBillsClose, false},
+		{database.StaffRoleKitchen, database.PermissionOrdersUpdate, true},
+		{database.StaffRoleKitchen, database.PermissionMenuWrite, false},
+	}
+	for _, tc := range cases {
+		staff := suite.staff[tc.role]
+		w, actor := suite.request(tc.permission, BusinessParam("id"), "/businesses/:id", suite.businessPath(), "", staff.ID)
+		if !tc.allowed {
+			assert.Equal(suite.T(), http.StatusForbidden, w.Code, "%s %s", tc.role, tc.permission)
+			var body map[string]interface{}
+			require.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(suite.T(), string(tc.permission), body["permission"])
+			continue
+		}
+		assert.Equal(suite.T(), http.StatusOK, w.Code, "%s %s", tc.role, tc.permission)
+		assert.Equal(suite.T(), fmt.Sprintf("staff:%d", staff.ID), actor)
+	}
+}
+
+// Staff are checked against the database on every request, not against their token
+func (suite *AuthorizeTestSuite) TestInactiveAndForeignStaffAreDenied() {
+	server := suite.staff[database.StaffRoleServer]
+	require.NoError(suite.T(), suite.db.Model(server).Update("is_active", false).Error)
+	w, _ := suite.request(database.PermissionBillsRead, BusinessParam("id"), "/businesses/:id", suite.businessPath(), "", server.ID)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+
+	manager := suite.staff[database.StaffRoleManager]
+	w, _ = suite.request(database.PermissionBillsRead, BusinessParam("id"), "/businesses/:id", fmt.Sprintf("/businesses/%d", suite.other.ID), "", manager.ID)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+
+	// Someone else's wallet gets nothing either
+	w, _ = suite.request(database.PermissionBusinessRead, BusinessParam("id"), "/businesses/:id", suite.businessPath(), "0xOther", 0)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+}
+
+func (suite *AuthorizeTestSuite) TestResolvers() {
+	table := &database.Table{BusinessID: suite.other.ID, Name: "T1", TableCode: "tbl-1", IsActive: true}
+	require.NoError(suite.T(), suite.db.Create(table).Error)
+	bill := &database.Bill{BusinessID: suite.business.ID, BillNumber: "B1", Items: "[]", Status: database.BillStatusOpen}
+	require.NoError(suite.T(), suite.db.Create(bill).Error)
+
+	w, _ := suite.request(database.PermissionBillsRead, BillParam("bill_id"), "/bills/:bill_id", fmt.Sprintf("/bills/%d", bill.ID), "0xOwner", 0)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	// The table belongs to the other business
+	w, _ = suite.request(database.PermissionTablesWrite, TableParam("id"), "/tables/:id", fmt.Sprintf("/tables/%d", table.ID), "0xOwner", 0)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	w, _ = suite.request(database.PermissionTablesWrite, TableParam("id"), "/tables/:id", fmt.Sprintf("/tables/%d", table.ID), "0xOther", 0)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w, _ = suite.request(database.PermissionBillsRead, BillParam("bill_id"), "/bills/:bill_id", "/bills/9999", "0xOwner", 0)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	assert.JSONEq(suite.T(), `{"error":"Bill not found"}`, w.Body.String())
+	w, _ = suite.request(database.PermissionBusinessRead, BusinessParam("id"), "/businesses/:id", "/businesses/9999", "0xOwner", 0)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	assert.JSONEq(suite.T(), `{"error":"Business not found"}`, w.Body.String())
+	w, _ = suite.request(database.PermissionBillsRead, BillParam("bill_id"), "/bills/:bill_id", "/bills/abc", "0xOwner", 0)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+// Translation routes act on the business that owns the translated entity, named in the
+// query string of reads and in the body of writes, which handlers can still bind
+func (suite *AuthorizeTestSuite) TestTranslationResolvers() {
+	item := &database.MenuItem{BusinessID: suite.other.ID, CategoryID: 1, Name: "Lemonade"}
+	require.NoError(suite.T(), suite.db.Create(item).Error)
+
+	get := func(address, query string) int {
+		w, _ := suite.request(database.PermissionMenuRead, TranslationEntity(), "/translations", "/translations?"+query, address, 0)
+		return w.Code
+	}
+	itemQuery := fmt.Sprintf("entity_type=menu_item&entity_id=%d", item.ID)
+	assert.Equal(suite.T(), http.StatusOK, get("0xOther", itemQuery))
+	assert.Equal(suite.T(), http.StatusForbidden, get("0xOwner", itemQuery))
+	assert.Equal(suite.T(), http.StatusNotFound, get("0xOwner", "entity_type=menu_item&entity_id=9999"))
+	assert.Equal(suite.T(), http.StatusNotFound, get("0xOwner", fmt.Sprintf("entity_type=table&entity_id=%d", item.ID)))
+	assert.Equal(suite.T(), http.StatusBadRequest, get("0xOwner", "entity_type=menu_item"))
+
+	put := func(address string) (int, string) {
+		var text string
+		router := gin.New()
+		router.Use(func(c *gin.Context) { c.Set("address", address) })
+		router.PUT("/translations", RequirePermission(database.PermissionMenuWrite, TranslationEntity()), func(c *gin.Context) {
+			var body struct {
+				TranslatedText string `json:"translated_text"`
+			}
+			require.NoError(suite.T(), c.ShouldBindBodyWith(&body, binding.JSON))
+			text = body.TranslatedText
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		body := fmt.Sprintf(`{"entity_type":"menu_item","entity_id":%d,"translated_text":"Limonada"}`, item.ID)
+		req, _ := http.NewRequest(http.MethodPut, "/translations", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w.Code, text
+	}
+	code, _ := put("0xOwner")
+	assert.Equal(suite.T(), http.StatusForbidden, code)
+	code, text := put("0xOther")
+	assert.Equal(suite.T(), http.StatusOK, code)
+	assert.Equal(suite.T(), "Limonada", text)
+
+	w, _ := suite.request(database.PermissionMenuRead, TranslationJobParam("jobId"), "/jobs/:jobId", fmt.Sprintf("/jobs/translate_%d_1728728340", suite.business.ID), "0xOther", 0)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+	w, _ = suite.request(database.PermissionMenuRead, TranslationJobParam("jobId"), "/jobs/:jobId", "/jobs/nope", "0xOther", 0)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func (suite *AuthorizeTestSuite) TestUnauthenticated() {
+	w, _ := suite.request(database.PermissionBusinessRead, BusinessParam("id"), "/businesses/:id", suite.businessPath(), "", 0)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+}
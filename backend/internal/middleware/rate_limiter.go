@@ -1,25 +1,69 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"sync"
 	"time"
 
+	"payverge/internal/database"
+
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
 )
 
-// RateLimiter stores rate limiters for different clients
-type RateLimiter struct {
+// RateLimiter decides whether the client a key stands for may make another request.
+// Limiters backed by storage that every replica shares enforce one limit across the
+// deployment instead of one per replica.
+type RateLimiter interface {
+	Allow(key string) (bool, error)
+}
+
+// RateLimitKey picks what a limit is counted per; an empty key leaves the request unlimited
+type RateLimitKey func(c *gin.Context) string
+
+// ByClientIP counts requests per client IP address
+func ByClientIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// Limit rejects requests beyond the limiter's limit with the given message. Requests
+// are let through when the limiter cannot be reached, so an outage of its storage does
+// not take the API down with it.
+func Limit(limiter RateLimiter, key RateLimitKey, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		allowed, err := limiter.Allow(k)
+		if err != nil {
+			log.Printf("Rate limiter unavailable, allowing request: %v", err)
+			allowed = true
+		}
+		if !allowed {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": message})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// MemoryRateLimiter keeps a token bucket per key in the process
+type MemoryRateLimiter struct {
 	limiters map[string]*rate.Limiter
 	mu       sync.RWMutex
 	rate     rate.Limit
 	burst    int
 }
 
-// NewRateLimiter creates a new rate limiter
-func NewRateLimiter(r rate.Limit, b int) *RateLimiter {
-	return &RateLimiter{
+// NewMemoryRateLimiter creates a new rate limiter
+func NewMemoryRateLimiter(r rate.Limit, b int) *MemoryRateLimiter {
+	return &MemoryRateLimiter{
 		limiters: make(map[string]*rate.Limiter),
 		rate:     r,
 		burst:    b,
@@ -27,7 +71,7 @@ func NewRateLimiter(r rate.Limit, b int) *RateLimiter {
 }
 
 // GetLimiter returns the rate limiter for a specific key
-func (rl *RateLimiter) GetLimiter(key string) *rate.Limiter {
+func (rl *MemoryRateLimiter) GetLimiter(key string) *rate.Limiter {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
@@ -40,72 +84,65 @@ func (rl *RateLimiter) GetLimiter(key string) *rate.Limiter {
 	return limiter
 }
 
-// RateLimit middleware for Gin
-func RateLimit(requestsPerSecond int, burst int) gin.HandlerFunc {
-	limiter := NewRateLimiter(rate.Limit(requestsPerSecond), burst)
+// Allow takes a token from the key's bucket
+func (rl *MemoryRateLimiter) Allow(key string) (bool, error) {
+	return rl.GetLimiter(key).Allow(), nil
+}
 
-	return func(c *gin.Context) {
-		// Use IP address as the key
-		key := c.ClientIP()
-		
-		// Get limiter for this client
-		clientLimiter := limiter.GetLimiter(key)
-		
-		if !clientLimiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Rate limit exceeded. Please try again later.",
-			})
-			c.Abort()
-			return
-		}
+// SQLRateLimiter counts requests per key in fixed windows in the database, shared by
+// every replica. The name keeps the counts of different limits on the same key apart.
+type SQLRateLimiter struct {
+	name   string
+	limit  int64
+	window time.Duration
+}
 
-		c.Next()
+// NewSQLRateLimiter allows limit requests per key in each window
+func NewSQLRateLimiter(name string, limit int, window time.Duration) *SQLRateLimiter {
+	return &SQLRateLimiter{name: name, limit: int64(limit), window: window}
+}
+
+// Allow counts the request in the key's current window
+func (rl *SQLRateLimiter) Allow(key string) (bool, error) {
+	start := time.Now().Truncate(rl.window)
+	count, err := database.IncrementRateLimit(rl.name+":"+key, start, start.Add(rl.window))
+	if err != nil {
+		return false, err
 	}
+	return count <= rl.limit, nil
+}
+
+// Cleanup removes the windows that have ended, of every SQL rate limiter
+func (rl *SQLRateLimiter) Cleanup() error {
+	_, err := database.DeleteExpiredRateLimitWindows(time.Now())
+	return err
+}
+
+// RateLimit middleware for Gin
+func RateLimit(requestsPerSecond int, burst int) gin.HandlerFunc {
+	limiter := NewMemoryRateLimiter(rate.Limit(requestsPerSecond), burst)
+	return Limit(limiter, ByClientIP, "Rate limit exceeded. Please try again later.")
 }
 
 // BusinessRateLimit applies rate limiting per business
 func BusinessRateLimit(requestsPerMinute int) gin.HandlerFunc {
-	limiter := NewRateLimiter(rate.Every(time.Minute/time.Duration(requestsPerMinute)), 1)
-
-	return func(c *gin.Context) {
+	limiter := NewMemoryRateLimiter(rate.Every(time.Minute/time.Duration(requestsPerMinute)), 1)
+	return Limit(limiter, func(c *gin.Context) string {
 		businessID := c.Param("businessId")
 		if businessID == "" {
 			businessID = c.Param("id")
 		}
-
-		if businessID != "" {
-			key := "business:" + businessID
-			clientLimiter := limiter.GetLimiter(key)
-			
-			if !clientLimiter.Allow() {
-				c.JSON(http.StatusTooManyRequests, gin.H{
-					"error": "Business rate limit exceeded. Please try again later.",
-				})
-				c.Abort()
-				return
-			}
+		if businessID == "" {
+			return ""
 		}
-
-		c.Next()
-	}
+		return "business:" + businessID
+	}, "Business rate limit exceeded. Please try again later.")
 }
 
 // PaymentRateLimit applies stricter rate limiting for payment endpoints
 func PaymentRateLimit() gin.HandlerFunc {
-	limiter := NewRateLimiter(rate.Every(10*time.Second), 1) // 1 payment per 10 seconds
-
-	return func(c *gin.Context) {
-		key := c.ClientIP() + ":payment"
-		clientLimiter := limiter.GetLimiter(key)
-		
-		if !clientLimiter.Allow() {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "Payment rate limit exceeded. Please wait before making another payment.",
-			})
-			c.Abort()
-			return
-		}
-
-		c.Next()
-	}
+	limiter := NewMemoryRateLimiter(rate.Every(10*time.Second), 1) // 1 payment per 10 seconds
+	return Limit(limiter, func(c *gin.Context) string {
+		return c.ClientIP() + ":payment"
+	}, "Payment rate limit exceeded. Please wait before making another payment.")
 }
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+)
+
+func setupRateLimitDB(t *testing.T) *gorm.DB {
+	db := dbtest.Open(t)
+	database.InitTestDB(db)
+	return db
+}
+
+func TestSQLRateLimiter(t *testing.T) {
+	db := setupRateLimitDB(t)
+
+	// Two replicas configured alike share the count
+	first := NewSQLRateLimiter("api", 3, time.Hour)
+	second := NewSQLRateLimiter("api", 3, time.Hour)
+	for i, limiter := range []RateLimiter{first, second, first} {
+		allowed, err := limiter.Allow("192.168.1.1")
+		require.NoError(t, err)
+		assert.True(t, allowed, "request %d", i)
+	}
+	allowed, err := second.Allow("192.168.1.1")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+
+	// Other clients and other limits are counted apart
+	allowed, err = first.Allow("192.168.1.2")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	allowed, err = NewSQLRateLimiter("payments", 3, time.Hour).Allow("192.168.1.1")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+
+	// Ended windows are cleaned up
+	require.NoError(t, db.Model(&database.RateLimitWindow{}).Where("limit_key = ?", "api:192.168.1.1").
+		Update("expires_at", time.Now().Add(-time.Minute)).Error)
+	require.NoError(t, first.Cleanup())
+	var windows int64
+	require.NoError(t, db.Model(&database.RateLimitWindow{}).Count(&windows).Error)
+	assert.Equal(t, int64(2), windows)
+}
+
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(key string) (bool, error) {
+	return false, errors.New("connection refused")
+}
+
+func TestLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	serve := func(limiter RateLimiter) int {
+		router := gin.New()
+		router.Use(Limit(limiter, ByClientIP, "Rate limit exceeded. Please try again later."))
+		router.GET("/test", func(c *gin.Context) { c.Status(http.StatusOK) })
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/test", nil))
+		return w.Code
+	}
+
+	limiter := NewMemoryRateLimiter(1, 1)
+	assert.Equal(t, http.StatusOK, serve(limiter))
+	assert.Equal(t, http.StatusTooManyRequests, serve(limiter))
+
+	// A limiter whose storage is down does not block traffic
+	assert.Equal(t, http.StatusOK, serve(failingLimiter{}))
+}
@@ -54,6 +54,11 @@ func (rl *SimpleRateLimiter) RateLimit() gin.HandlerFunc {
 	}
 }
 
+// Allow records a request by key if it is within the limit
+func (rl *SimpleRateLimiter) Allow(key string) (bool, error) {
+	return rl.isAllowed(key), nil
+}
+
 // isAllowed checks if the request is within rate limits
 func (rl *SimpleRateLimiter) isAllowed(ip string) bool {
 	rl.mu.Lock()
@@ -163,7 +168,7 @@ func CORS() gin.HandlerFunc {
 		}
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-Table-Session")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Max-Age", "86400")
 
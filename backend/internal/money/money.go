@@ -0,0 +1,321 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Amount is a monetary value in minor units (cents). Amounts are stored as integers
+// in the database and serialized to JSON as decimal numbers with two places, so the
+// API keeps exchanging dollar values while all arithmetic stays exact.
+type Amount int64
+
+const (
+	// Decimals is the number of minor-unit digits an Amount carries
+	Decimals = 2
+	// USDCDecimals is the number of decimals of the USDC token on chain
+	USDCDecimals = 6
+
+	centsPerUnit = 100
+	usdcPerCent  = 10_000 // 10^(USDCDecimals-Decimals)
+)
+
+// Zero is the zero amount
+const Zero Amount = 0
+
+// FromCents returns the amount for a number of minor units
+func FromCents(cents int64) Amount {
+	return Amount(cents)
+}
+
+// FromFloat converts a decimal value to an amount, rounding half away from zero.
+// It is meant for inputs that are inherently floating point, such as computed rates.
+func FromFloat(value float64) Amount {
+	return Amount(math.Round(value * centsPerUnit))
+}
+
+// Parse reads a decimal string such as "12", "12.5" or "-0.05". Digits beyond the
+// second decimal place are rounded half away from zero.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	whole, frac, _ := strings.Cut(s, ".")
+	if whole == "" && frac == "" {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > math.MaxInt64/centsPerUnit-1 {
+		return 0, fmt.Errorf("amount %q out of range", s)
+	}
+
+	cents := int64(0)
+	for i := 0; i < Decimals; i++ {
+		cents *= 10
+		if i < len(frac) {
+			cents += int64(frac[i] - '0')
+		}
+	}
+	if len(frac) > Decimals && frac[Decimals] >= '5' {
+		cents++
+	}
+
+	total := units*centsPerUnit + cents
+	if negative {
+		total = -total
+	}
+	return Amount(total), nil
+}
+
+// MustParse is like Parse but panics on invalid input; intended for constants and tests
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// ErrSubCentUSDC is returned for USDC values that are not a whole number of cents
+var ErrSubCentUSDC = errors.New("USDC amount is not a whole number of cents")
+
+// FromUSDC converts on-chain USDC base units (6 decimals) to an amount. Amounts are
+// kept in cents, so a value with precision below a cent is rejected rather than
+// rounded, which would leave the ledger disagreeing with the chain.
+func FromUSDC(units int64) (Amount, error) {
+	if units%usdcPerCent != 0 {
+		return 0, fmt.Errorf("%w: %d base units", ErrSubCentUSDC, units)
+	}
+	return Amount(units / usdcPerCent), nil
+}
+
+// FromUSDCBig converts on-chain USDC base units to an amount, failing on values that
+// do not fit in an int64
+func FromUSDCBig(units *big.Int) (Amount, error) {
+	if units == nil {
+		return 0, nil
+	}
+	if !units.IsInt64() {
+		return 0, fmt.Errorf("USDC amount %s out of range", units)
+	}
+	return FromUSDC(units.Int64())
+}
+
+// USDC returns the amount in USDC base units (6 decimals)
+func (a Amount) USDC() int64 {
+	return int64(a) * usdcPerCent
+}
+
+// USDCBig returns the amount in USDC base units as a big integer, as contract calls expect
+func (a Amount) USDCBig() *big.Int {
+	return new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(usdcPerCent))
+}
+
+// Cents returns the amount in minor units
+func (a Amount) Cents() int64 {
+	return int64(a)
+}
+
+// Float64 returns the amount in major units. Use it for display and ratios only.
+func (a Amount) Float64() float64 {
+	return float64(a) / centsPerUnit
+}
+
+// Mul multiplies the amount by a quantity
+func (a Amount) Mul(quantity int) Amount {
+	return a * Amount(quantity)
+}
+
+// Div divides the amount into n parts, rounding half away from zero. It panics if n is zero.
+func (a Amount) Div(n int) Amount {
+	return Amount(math.Round(float64(a) / float64(n)))
+}
+
+// Percent returns rate percent of the amount, rounded half away from zero
+func (a Amount) Percent(rate float64) Amount {
+	return Amount(math.Round(float64(a) * rate / 100))
+}
+
+// Scale multiplies the amount by a ratio, rounded half away from zero
+func (a Amount) Scale(ratio float64) Amount {
+	return Amount(math.Round(float64(a) * ratio))
+}
+
+// Allocate splits the amount into parts proportional to weights using the largest
+// remainder method. Every part is within a cent of its exact share and the parts
+// always add up to the amount; leftover cents go to the largest remainders, earlier
+// weights first on ties. It panics if a weight is negative or all weights are zero.
+func (a Amount) Allocate(weights []int64) []Amount {
+	var total uint64
+	for _, w := range weights {
+		if w < 0 {
+			panic("money: negative allocation weight")
+		}
+		total += uint64(w)
+	}
+	if total == 0 {
+		panic("money: allocation weights sum to zero")
+	}
+
+	cents := uint64(a.Abs())
+	parts := make([]Amount, len(weights))
+	remainders := make([]uint64, len(weights))
+	var allocated uint64
+	for i, w := range weights {
+		// cents*w/total never exceeds cents, so the 128-bit division cannot overflow
+		hi, lo := bits.Mul64(cents, uint64(w))
+		quotient, remainder := bits.Div64(hi, lo, total)
+		parts[i] = Amount(quotient)
+		remainders[i] = remainder
+		allocated += quotient
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return remainders[order[i]] > remainders[order[j]]
+	})
+	for _, i := range order[:cents-allocated] {
+		parts[i]++
+	}
+
+	if a < 0 {
+		for i := range parts {
+			parts[i] = -parts[i]
+		}
+	}
+	return parts
+}
+
+// Abs returns the absolute value of the amount
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// String formats the amount with two decimal places, e.g. "12.50"
+func (a Amount) String() string {
+	sign := ""
+	cents := int64(a)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/centsPerUnit, cents%centsPerUnit)
+}
+
+// MarshalJSON encodes the amount as a decimal number in major units
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a decimal number or a quoted decimal string in major units
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		var quoted string
+		if err := json.Unmarshal(data, &quoted); err != nil {
+			return err
+		}
+		s = quoted
+	} else if strings.ContainsAny(s, "eE") {
+		// Exponent notation is valid JSON; go through float parsing for it
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount %s", s)
+		}
+		*a = FromFloat(f)
+		return nil
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value stores the amount as an integer number of minor units
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// Scan reads an amount stored in minor units
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*a = 0
+	case int64:
+		*a = Amount(v)
+	case float64:
+		*a = Amount(math.Round(v))
+	case []byte:
+		return a.scanString(string(v))
+	case string:
+		return a.scanString(v)
+	default:
+		return fmt.Errorf("cannot scan %T into money.Amount", src)
+	}
+	return nil
+}
+
+func (a *Amount) scanString(s string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return fmt.Errorf("cannot scan %q into money.Amount", s)
+	}
+	*a = Amount(math.Round(f))
+	return nil
+}
+
+// Sum adds up amounts
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, a := range amounts {
+		total += a
+	}
+	return total
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
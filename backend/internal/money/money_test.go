@@ -0,0 +1,167 @@
+package money
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]Amount{
+		"0":       0,
+		"12":      1200,
+		"12.5":    1250,
+		"12.50":   1250,
+		"0.01":    1,
+		".99":     99,
+		"-0.05":   -5,
+		"+3.10":   310,
+		"1.005":   101,
+		"1.004":   100,
+		" 7.25 ":  725,
+		"1000000": 100000000,
+	}
+	for input, want := range cases {
+		got, err := Parse(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "-", "abc", "1.2.3", "1,50", "1e3"} {
+		_, err := Parse(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "0.00", Amount(0).String())
+	assert.Equal(t, "12.05", Amount(1205).String())
+	assert.Equal(t, "-0.50", Amount(-50).String())
+}
+
+func TestJSONKeepsDecimalEncoding(t *testing.T) {
+	var item struct {
+		Price    Amount `json:"price"`
+		Subtotal Amount `json:"subtotal"`
+	}
+	// Blobs written before amounts were stored in cents decode unchanged
+	require.NoError(t, json.Unmarshal([]byte(`{"price": 12.99, "subtotal": "25.98"}`), &item))
+	assert.Equal(t, Amount(1299), item.Price)
+	assert.Equal(t, Amount(2598), item.Subtotal)
+
+	out, err := json.Marshal(item)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"price": 12.99, "subtotal": 25.98}`, string(out))
+
+	var tiny Amount
+	require.NoError(t, json.Unmarshal([]byte(`1e-2`), &tiny))
+	assert.Equal(t, Amount(1), tiny)
+}
+
+func TestUSDCConversion(t *testing.T) {
+	amount, err := FromUSDC(1_500_000)
+	require.NoError(t, err)
+	assert.Equal(t, Amount(150), amount)
+	amount, err = FromUSDC(-10_000)
+	require.NoError(t, err)
+	assert.Equal(t, Amount(-1), amount)
+
+	_, err = FromUSDC(5_000)
+	assert.ErrorIs(t, err, ErrSubCentUSDC, "half a cent is not rounded")
+	_, err = FromUSDC(1_234_567)
+	assert.ErrorIs(t, err, ErrSubCentUSDC)
+
+	amount, err = FromUSDCBig(big.NewInt(25_990_000))
+	require.NoError(t, err)
+	assert.Equal(t, Amount(2599), amount)
+	amount, err = FromUSDCBig(nil)
+	require.NoError(t, err)
+	assert.Equal(t, Amount(0), amount)
+	_, err = FromUSDCBig(new(big.Int).Lsh(big.NewInt(1), 64))
+	assert.Error(t, err, "values beyond int64 are not truncated")
+
+	a := MustParse("25.99")
+	assert.Equal(t, int64(25_990_000), a.USDC())
+	assert.Equal(t, big.NewInt(25_990_000), a.USDCBig())
+	roundTrip, err := FromUSDC(a.USDC())
+	require.NoError(t, err)
+	assert.Equal(t, a, roundTrip)
+}
+
+func TestArithmetic(t *testing.T) {
+	price := MustParse("3.33")
+	assert.Equal(t, MustParse("9.99"), price.Mul(3))
+	assert.Equal(t, MustParse("3.33"), MustParse("9.99").Div(3))
+	assert.Equal(t, MustParse("0.80"), MustParse("9.99").Percent(8))
+	assert.Equal(t, MustParse("1.50"), MustParse("10.00").Percent(15))
+	assert.Equal(t, MustParse("0.03"), MustParse("0.25").Percent(10), "0.025 rounds half away from zero")
+	assert.Equal(t, MustParse("2.50"), MustParse("10.00").Scale(0.25))
+	assert.Equal(t, MustParse("6.00"), Sum(MustParse("1.00"), MustParse("2.00"), MustParse("3.00")))
+	assert.Equal(t, MustParse("0.10"), MustParse("-0.10").Abs())
+}
+
+func TestScan(t *testing.T) {
+	var a Amount
+	require.NoError(t, a.Scan(int64(1250)))
+	assert.Equal(t, Amount(1250), a)
+	require.NoError(t, a.Scan(float64(1250)))
+	assert.Equal(t, Amount(1250), a)
+	require.NoError(t, a.Scan([]byte("99")))
+	assert.Equal(t, Amount(99), a)
+	require.NoError(t, a.Scan(nil))
+	assert.Equal(t, Amount(0), a)
+	assert.Error(t, a.Scan(true))
+
+	v, err := MustParse("1.25").Value()
+	require.NoError(t, err)
+	assert.Equal(t, int64(125), v)
+}
+
+func TestAllocate(t *testing.T) {
+	assert.Equal(t, []Amount{34, 33, 33}, MustParse("1.00").Allocate([]int64{1, 1, 1}))
+	assert.Equal(t, []Amount{-34, -33, -33}, MustParse("-1.00").Allocate([]int64{1, 1, 1}))
+	assert.Equal(t, []Amount{0, 1000}, MustParse("10.00").Allocate([]int64{0, 7}))
+	assert.Equal(t, []Amount{67, 33}, MustParse("1.00").Allocate([]int64{2, 1}))
+	assert.Panics(t, func() { MustParse("1.00").Allocate([]int64{0, 0}) })
+	assert.Panics(t, func() { MustParse("1.00").Allocate([]int64{1, -1}) })
+}
+
+// Allocated parts always add up to the amount, each part is within a cent of its exact
+// proportional share, and zero weights receive nothing
+func TestAllocateProperties(t *testing.T) {
+	property := func(amount int64, raw []uint32) bool {
+		weights := make([]int64, 0, len(raw)+1)
+		var total int64
+		for _, w := range raw {
+			weights = append(weights, int64(w))
+			total += int64(w)
+		}
+		if total == 0 {
+			weights = append(weights, 1)
+			total = 1
+		}
+		a := Amount(amount % 1e15)
+
+		parts := a.Allocate(weights)
+		if len(parts) != len(weights) || Sum(parts...) != a {
+			return false
+		}
+		for i, part := range parts {
+			// |part*total - a*w| < total
+			exact := new(big.Int).Mul(big.NewInt(int64(a)), big.NewInt(weights[i]))
+			diff := new(big.Int).Sub(new(big.Int).Mul(big.NewInt(int64(part)), big.NewInt(total)), exact)
+			if diff.Abs(diff).Cmp(big.NewInt(total)) >= 0 {
+				return false
+			}
+			if weights[i] == 0 && part != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	require.NoError(t, quick.Check(property, &quick.Config{MaxCount: 2000}))
+}
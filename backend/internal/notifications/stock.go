@@ -0,0 +1,52 @@
+package notifications
+
+import (
+	"fmt"
+	"strings"
+
+	"payverge/internal/database"
+	"payverge/internal/emails"
+	"payverge/internal/structs"
+)
+
+// LowStockNotifier alerts business owners about stock items running low
+type LowStockNotifier struct {
+	manager *NotificationManager
+}
+
+// NewLowStockNotifier creates a low-stock notifier sending through a notification manager
+func NewLowStockNotifier(manager *NotificationManager) *LowStockNotifier {
+	return &LowStockNotifier{manager: manager}
+}
+
+// NotifyLowStock sends the owner of a business one alert listing its low stock items.
+// Owners without an account of their own are reached at the business's contact email.
+func (n *LowStockNotifier) NotifyLowStock(business *database.Business, items []database.StockItem) {
+	if len(items) == 0 {
+		return
+	}
+
+	owner, err := database.GetUserByAddress(business.OwnerAddress)
+	if err != nil {
+		owner = structs.User{
+			Address:                 business.OwnerAddress,
+			NotificationPreferences: structs.NotificationPreferences{EmailEnabled: true, TransactionalEnabled: true},
+		}
+	}
+	owner.Email = business.Email
+	owner.NotificationPreference = structs.EmailNotificationPreference
+
+	lines := make([]string, len(items))
+	for i, item := range items {
+		lines[i] = fmt.Sprintf("%s: %d %s left", item.Name, item.Quantity, item.Unit)
+	}
+	title := fmt.Sprintf("Low stock at %s", business.Name)
+	description := strings.Join(lines, "\n")
+
+	notification := structs.NewTemplateNotification(title, description, 0, emails.TemplateGenericNotification, map[string]interface{}{
+		"title":         title,
+		"description":   description,
+		"business_name": business.Name,
+	})
+	n.manager.SendNotification(notification, owner)
+}
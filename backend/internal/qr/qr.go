@@ -0,0 +1,144 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"github.com/fogleman/gg"
+	"github.com/skip2/go-qrcode"
+)
+
+// Size limits of rendered codes, in pixels
+const (
+	MinSize     = 128
+	MaxSize     = 2048
+	DefaultSize = 512
+)
+
+// logoShare is the width of a composited logo relative to the code. High error
+// correction recovers up to 30% of the modules, so the logo stays well below that.
+const logoShare = 0.22
+
+// ErrInvalidSize is returned when a code is asked for outside the size limits
+var ErrInvalidSize = errors.New("invalid QR code size")
+
+// encode builds the code for content. Codes carrying a logo use the highest recovery
+// level so the modules it covers can be read anyway.
+func encode(content string, withLogo bool) (*qrcode.QRCode, error) {
+	level := qrcode.Medium
+	if withLogo {
+		level = qrcode.Highest
+	}
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return code, nil
+}
+
+func checkSize(size int) error {
+	if size < MinSize || size > MaxSize {
+		return fmt.Errorf("%w: must be between %d and %d pixels", ErrInvalidSize, MinSize, MaxSize)
+	}
+	return nil
+}
+
+// PNG renders content as a square PNG code of size pixels, with the logo centred on
+// a white pad when one is given
+func PNG(content string, size int, logo image.Image) ([]byte, error) {
+	if err := checkSize(size); err != nil {
+		return nil, err
+	}
+	code, err := encode(content, logo != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dc := gg.NewContext(size, size)
+	dc.SetColor(color.White)
+	dc.Clear()
+	dc.DrawImage(code.Image(size), 0, 0)
+	if logo != nil {
+		drawLogo(dc, logo, float64(size))
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, dc.Image()); err != nil {
+		return nil, fmt.Errorf("failed to encode QR code image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// drawLogo scales the logo into the centre of a code of the given size
+func drawLogo(dc *gg.Context, logo image.Image, size float64) {
+	bounds := logo.Bounds()
+	box := size * logoShare
+	scale := box / float64(max(bounds.Dx(), bounds.Dy()))
+	width, height := float64(bounds.Dx())*scale, float64(bounds.Dy())*scale
+	pad := box * 0.12
+
+	dc.SetColor(color.White)
+	dc.DrawRoundedRectangle((size-width)/2-pad, (size-height)/2-pad, width+2*pad, height+2*pad, pad)
+	dc.Fill()
+
+	dc.Push()
+	dc.Translate((size-width)/2, (size-height)/2)
+	dc.Scale(scale, scale)
+	dc.DrawImage(logo, -bounds.Min.X, -bounds.Min.Y)
+	dc.Pop()
+}
+
+// SVG renders content as a scalable code drawn size pixels wide, with the logo
+// embedded in the centre when one is given
+func SVG(content string, size int, logo image.Image) ([]byte, error) {
+	if err := checkSize(size); err != nil {
+		return nil, err
+	}
+	code, err := encode(content, logo != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// One unit per module keeps the path small; the viewBox scales it to size
+	bitmap := code.Bitmap()
+	modules := len(bitmap)
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		size, size, modules, modules)
+	fmt.Fprintf(&svg, `<rect width="%d" height="%d" fill="#fff"/><path d="%s" fill="#000"/>`, modules, modules, path.String())
+	if logo != nil {
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, logo); err != nil {
+			return nil, fmt.Errorf("failed to encode QR code logo: %w", err)
+		}
+		box := float64(modules) * logoShare
+		pad := box * 0.12
+		offset := (float64(modules) - box) / 2
+		fmt.Fprintf(&svg, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" rx="%.2f" fill="#fff"/>`,
+			offset-pad, offset-pad, box+2*pad, box+2*pad, pad)
+		fmt.Fprintf(&svg, `<image x="%.2f" y="%.2f" width="%.2f" height="%.2f" preserveAspectRatio="xMidYMid meet" href="data:image/png;base64,%s"/>`,
+			offset, offset, box, box, base64.StdEncoding.EncodeToString(encoded.Bytes()))
+	}
+	svg.WriteString("</svg>")
+	return svg.Bytes(), nil
+}
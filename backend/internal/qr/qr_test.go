@@ -0,0 +1,81 @@
+package qr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testURL = "https://payverge.io/t/ABCDE12345"
+
+func testLogo() image.Image {
+	logo := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 20; y++ {
+			logo.Set(x, y, color.RGBA{R: 200, A: 255})
+		}
+	}
+	return logo
+}
+
+func TestPNG(t *testing.T) {
+	for _, logo := range []image.Image{nil, testLogo()} {
+		data, err := PNG(testURL, 300, logo)
+		require.NoError(t, err)
+
+		decoded, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, 300, decoded.Bounds().Dx())
+		assert.Equal(t, 300, decoded.Bounds().Dy())
+	}
+
+	// The logo sits on a white pad in the middle of the code
+	data, err := PNG(testURL, 300, testLogo())
+	require.NoError(t, err)
+	decoded, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	r, g, b, _ := decoded.At(150, 150).RGBA()
+	assert.Equal(t, [3]uint32{200, 0, 0}, [3]uint32{r >> 8, g >> 8, b >> 8})
+}
+
+func TestSVG(t *testing.T) {
+	data, err := SVG(testURL, 256, nil)
+	require.NoError(t, err)
+	require.NoError(t, xml.Unmarshal(data, new(interface{})))
+	assert.True(t, strings.HasPrefix(string(data), "<svg "))
+	assert.Contains(t, string(data), `width="256"`)
+	assert.NotContains(t, string(data), "<image")
+
+	data, err = SVG(testURL, 256, testLogo())
+	require.NoError(t, err)
+	require.NoError(t, xml.Unmarshal(data, new(interface{})))
+	assert.Contains(t, string(data), "data:image/png;base64,")
+}
+
+func TestSizeLimits(t *testing.T) {
+	for _, size := range []int{0, MinSize - 1, MaxSize + 1} {
+		_, err := PNG(testURL, size, nil)
+		assert.ErrorIs(t, err, ErrInvalidSize)
+		_, err = SVG(testURL, size, nil)
+		assert.ErrorIs(t, err, ErrInvalidSize)
+	}
+}
+
+func TestTentSheet(t *testing.T) {
+	tents := []Tent{
+		{Name: "Table 1", URL: testURL},
+		{Name: "Terrasse é", URL: "https://payverge.io/t/ZZZZZ00000"},
+		{Name: "Counter 1", URL: "https://payverge.io/c/CCCCC11111"},
+	}
+	data, err := TentSheet("Café Payverge", "Scan to pay", tents, testLogo())
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("%PDF-")))
+	assert.Equal(t, len(tents), bytes.Count(data, []byte("/Type /Page\n")))
+}
@@ -0,0 +1,81 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Tent is one table tent: a named place guests scan to reach
+type Tent struct {
+	Name string // Shown under the code, e.g. "Table 4" or "Counter 2"
+	URL  string // Encoded in the code
+}
+
+// tentCodeSize is the pixel size codes are rendered at for printing
+const tentCodeSize = 1024
+
+// TentSheet renders a printable A4 PDF with one folding table tent per page. Each page
+// folds along its middle line; the upper face is printed upside down so both faces
+// read upright once the tent stands. The logo, if any, is composited into every code.
+func TentSheet(businessName, caption string, tents []Tent, logo image.Image) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle(businessName+" table tents", true)
+	pdf.SetAutoPageBreak(false, 0)
+	translate := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	half := pageHeight / 2
+	for i, tent := range tents {
+		code, err := PNG(tent.URL, tentCodeSize, logo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render the code for %s: %w", tent.Name, err)
+		}
+		imageName := fmt.Sprintf("tent-%d", i)
+		pdf.RegisterImageOptionsReader(imageName, gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(code))
+
+		pdf.AddPage()
+		face := func(top float64) {
+			pdf.SetFont("Helvetica", "B", 22)
+			pdf.SetXY(10, top+12)
+			pdf.CellFormat(pageWidth-20, 10, translate(businessName), "", 0, "C", false, 0, "")
+
+			codeSize := half - 60
+			pdf.ImageOptions(imageName, (pageWidth-codeSize)/2, top+26, codeSize, codeSize, false,
+				gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+			pdf.SetFont("Helvetica", "B", 28)
+			pdf.SetXY(10, top+half-32)
+			pdf.CellFormat(pageWidth-20, 12, translate(tent.Name), "", 0, "C", false, 0, "")
+			pdf.SetFont("Helvetica", "", 13)
+			pdf.SetXY(10, top+half-19)
+			pdf.CellFormat(pageWidth-20, 8, translate(caption), "", 0, "C", false, 0, "")
+		}
+
+		// Upper face, upside down
+		pdf.TransformBegin()
+		pdf.TransformRotate(180, pageWidth/2, half/2)
+		face(0)
+		pdf.TransformEnd()
+
+		face(half)
+
+		// Fold line
+		pdf.SetDrawColor(160, 160, 160)
+		pdf.SetLineWidth(0.2)
+		pdf.SetDashPattern([]float64{2, 2}, 0)
+		pdf.Line(5, half, pageWidth-5, half)
+		pdf.SetDashPattern([]float64{}, 0)
+	}
+	if len(tents) == 0 {
+		pdf.AddPage()
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render table tents: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"payverge/internal/database"
+	"payverge/internal/money"
 )
 
 // AdminStats represents comprehensive admin dashboard statistics
@@ -24,11 +25,11 @@ type AdminStats struct {
 	UserGrowth     []MonthlyGrowth `json:"user_growth"`
 
 	// Payment metrics
-	TotalPaymentVolume    float64         `json:"total_payment_volume"`
-	TotalCryptoPayments   float64         `json:"total_crypto_payments"`
-	TotalAlternativePayments float64      `json:"total_alternative_payments"`
+	TotalPaymentVolume    money.Amount    `json:"total_payment_volume"`
+	TotalCryptoPayments   money.Amount    `json:"total_crypto_payments"`
+	TotalAlternativePayments money.Amount `json:"total_alternative_payments"`
 	PaymentVolumeGrowth   []MonthlyGrowth `json:"payment_volume_growth"`
-	AverageTransactionSize float64        `json:"average_transaction_size"`
+	AverageTransactionSize money.Amount   `json:"average_transaction_size"`
 
 	// Bill metrics
 	TotalBills     int64           `json:"total_bills"`
@@ -46,15 +47,15 @@ type AdminStats struct {
 	TopReferrers          []TopReferrer            `json:"top_referrers"`
 
 	// Revenue metrics
-	TotalRevenue          float64         `json:"total_revenue"`
+	TotalRevenue          money.Amount    `json:"total_revenue"`
 	RevenueGrowth         []MonthlyGrowth `json:"revenue_growth"`
 }
 
 // MonthlyGrowth represents growth data for a specific month
 type MonthlyGrowth struct {
-	Month string  `json:"month"`
-	Count int64   `json:"count"`
-	Value float64 `json:"value,omitempty"` // For revenue/volume data
+	Month string       `json:"month"`
+	Count int64        `json:"count"`
+	Value money.Amount `json:"value,omitempty"` // For revenue/volume data
 }
 
 // TopReferrer represents top performing referrers
@@ -225,7 +226,7 @@ func getPaymentMetrics(stats *AdminStats) error {
 
 	// Total crypto payment volume
 	var cryptoVolume struct {
-		Total float64 `json:"total"`
+		Total money.Amount `json:"total"`
 	}
 	if err := db.Model(&database.Payment{}).
 		Select("COALESCE(SUM(amount + tip_amount), 0) as total").
@@ -237,7 +238,7 @@ func getPaymentMetrics(stats *AdminStats) error {
 
 	// Total alternative payment volume
 	var altVolume struct {
-		Total float64 `json:"total"`
+		Total money.Amount `json:"total"`
 	}
 	if err := db.Model(&database.AlternativePayment{}).
 		Select("COALESCE(SUM(amount), 0) as total").
@@ -251,7 +252,7 @@ func getPaymentMetrics(stats *AdminStats) error {
 
 	// Average transaction size
 	var avgTransaction struct {
-		Average float64 `json:"average"`
+		Average money.Amount `json:"average"`
 	}
 	if err := db.Model(&database.Payment{}).
 		Select("COALESCE(AVG(amount + tip_amount), 0) as average").
@@ -269,7 +270,7 @@ func getPaymentMetrics(stats *AdminStats) error {
 		monthEnd := monthStart.AddDate(0, 1, 0).Add(-time.Second)
 
 		var cryptoSum struct {
-			Total float64 `json:"total"`
+			Total money.Amount `json:"total"`
 		}
 		if err := db.Model(&database.Payment{}).
 			Select("COALESCE(SUM(amount + tip_amount), 0) as total").
@@ -279,7 +280,7 @@ func getPaymentMetrics(stats *AdminStats) error {
 		}
 
 		var altSum struct {
-			Total float64 `json:"total"`
+			Total money.Amount `json:"total"`
 		}
 		if err := db.Model(&database.AlternativePayment{}).
 			Select("COALESCE(SUM(amount), 0) as total").
@@ -384,8 +385,7 @@ func getReferralMetrics(stats *AdminStats) error {
 	db := database.GetDB()
 
 	// Check if referrers table exists
-	var tableExists bool
-	if err := db.Raw("SELECT name FROM sqlite_master WHERE type='table' AND name='referrers'").Scan(&tableExists).Error; err != nil {
+	if !db.Migrator().HasTable("referrers") {
 		// If table doesn't exist, set default values
 		stats.TotalReferrers = 0
 		stats.ReferrersByTier = make(map[string]int64)
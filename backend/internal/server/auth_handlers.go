@@ -1,10 +1,10 @@
 package server
 
 import (
+	"crypto/rand"
 	"encoding/hex"
 	"errors"
 	"log"
-	"math/rand"
 	"net/http"
 	"strings"
 	"time"
@@ -14,14 +14,27 @@ import (
 	"payverge/internal/database"
 	"payverge/internal/logic"
 	"payverge/internal/metrics"
-	"payverge/internal/structs"
-	"payverge/internal/utils"
+	"payverge/internal/siwe"
 )
 
-var ChallengeStore *logic.ChallengeStore
+// ChallengeStore keeps the sign-in challenges handed out. It is in memory unless main
+// configures a store shared by all replicas.
+var ChallengeStore logic.ChallengeStore
+
+var (
+	signInConfig   siwe.Config
+	signInVerifier = siwe.NewVerifier(0, nil)
+)
+
+// ConfigureSignIn sets the domains and chains sign-in messages are accepted for and the
+// verifier that checks their signatures
+func ConfigureSignIn(config siwe.Config, verifier *siwe.Verifier) {
+	signInConfig = config
+	signInVerifier = verifier
+}
 
 func init() {
-	ChallengeStore = logic.NewChallengeStore()
+	ChallengeStore = logic.NewMemoryChallengeStore()
 }
 
 type SignatureRequest struct {
@@ -30,10 +43,19 @@ type SignatureRequest struct {
 }
 
 type SignInRequest struct {
-	Message   string `json:"message"`
-	Signature string `json:"signature"`
+	Message    string `json:"message"`
+	Signature  string `json:"signature"`
+	DeviceName string `json:"device_name"`
 }
 
+// RefreshRequest carries the refresh token when it is not sent as a cookie
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshTokenCookie holds the refresh token for browsers, out of reach of scripts
+const refreshTokenCookie = "refresh_token"
+
 // GenerateChallenge generates a challenge for the user to sign
 func GenerateChallenge(c *gin.Context) {
 	metrics.AuthOperations.WithLabelValues("challenge_generated").Inc()
@@ -55,10 +77,15 @@ func GenerateChallenge(c *gin.Context) {
 	address := strings.ToLower(req.Address)
 
 	// Store the challenge
-	ChallengeStore.Set(address, logic.Challenge{
+	err = ChallengeStore.Set(address, logic.Challenge{
 		Value:     hex.EncodeToString(challenge),
 		ExpiresAt: time.Now().Add(5 * time.Minute),
 	})
+	if err != nil {
+		log.Printf("Failed to store challenge: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate challenge"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"challenge": hex.EncodeToString(challenge)})
 
@@ -82,19 +109,13 @@ func GetSession(c *gin.Context) {
 		return
 	}
 
-	parts := strings.Split(authHeader, " ")
-	if len(parts) != 2 || parts[0] != "Bearer" {
+	sessionToken, ok := bearerToken(authHeader)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Authorization header format"})
 		return
 	}
 
-	sessionToken := parts[1]
-	// remove \"\" from the token
-	sessionToken = sessionToken[1 : len(sessionToken)-1]
-	token, err := jwt.Parse(sessionToken, func(token *jwt.Token) (interface{}, error) {
-		return structs.SecretKey, nil
-	})
-
+	_, _, err := VerifySessionToken(sessionToken)
 	if err != nil {
 		var validationError *jwt.ValidationError
 		if errors.As(err, &validationError) {
@@ -110,17 +131,18 @@ func GetSession(c *gin.Context) {
 				return
 			}
 		}
+		if errors.Is(err, database.ErrAuthSessionInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, sign in again"})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session token"})
 		return
 	}
-	if token.Valid {
-		// Return the original JWT token
-		c.JSON(http.StatusOK, gin.H{
-			"session_token": sessionToken,
-		})
-	} else {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session token"})
-	}
+
+	// Return the original JWT token
+	c.JSON(http.StatusOK, gin.H{
+		"session_token": sessionToken,
+	})
 }
 
 // SignIn signs the user in by verifying the signature
@@ -132,31 +154,44 @@ func SignIn(c *gin.Context) {
 		return
 	}
 
-	lines := strings.Split(req.Message, "\n")
-	if len(lines) < 11 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message"})
+	message, err := siwe.ParseMessage(req.Message)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message", "reason": err.Error()})
+		return
+	}
+	if err := message.Validate(signInConfig, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message", "reason": err.Error()})
 		return
 	}
-	address := strings.ToLower(strings.Replace(strings.Split(lines[5], ":")[1], " ", "", -1))
-	challenge := strings.Replace(strings.Split(lines[6], ":")[1], " ", "", -1)
-	chainId := strings.Split(lines[7], "#")[1]
+	address := strings.ToLower(message.Address)
 
-	if !utils.VerifySignature(address, req.Message, req.Signature, chainId) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature"})
+	storedChallenge, ok, err := ChallengeStore.Get(address)
+	if err != nil {
+		log.Printf("Failed to get challenge: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check challenge"})
 		return
 	}
-	storedChallenge, ok := ChallengeStore.Get(address)
-	if !ok || storedChallenge.Value != challenge {
+	if !ok || storedChallenge.Value != message.Nonce {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired challenge"})
 		return
 	}
 
 	if time.Now().After(storedChallenge.ExpiresAt) {
-		ChallengeStore.Delete(address)
+		deleteChallenge(address)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Challenge expired"})
 		return
 	}
 
+	if err := signInVerifier.Verify(c.Request.Context(), message, req.Message, req.Signature); err != nil {
+		if errors.Is(err, siwe.ErrInvalidSignature) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid signature"})
+			return
+		}
+		log.Printf("Failed to verify sign-in signature of %s: %v", address, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to verify signature"})
+		return
+	}
+
 	user, err := database.GetUserByAddress(address)
 	if err != nil {
 		user = newUser(address)
@@ -179,9 +214,14 @@ func SignIn(c *gin.Context) {
 		}
 	}
 
-	token, err := GenerateToken(address, user.Role)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+	session := &database.AuthSession{
+		Address:    address,
+		DeviceName: truncateDeviceField(req.DeviceName),
+		UserAgent:  truncateDeviceField(c.Request.UserAgent()),
+		IPAddress:  c.ClientIP(),
+	}
+	tokens, ok := startAuthSession(c, session, string(user.Role))
+	if !ok {
 		return
 	}
 
@@ -197,9 +237,10 @@ func SignIn(c *gin.Context) {
 	}
 
 	metrics.AuthOperations.WithLabelValues("sign_in_success").Inc()
-	ChallengeStore.Delete(address)
-	c.SetCookie("session_token", token, 3600*24, "/", "", false, true)
-	c.JSON(http.StatusOK, gin.H{"success": true, "token": token, "address": address})
+	deleteChallenge(address)
+	tokens["success"] = true
+	tokens["address"] = address
+	c.JSON(http.StatusOK, tokens)
 
 	// Track successful sign in
 	properties = map[string]interface{}{
@@ -212,26 +253,139 @@ func SignIn(c *gin.Context) {
 	}
 }
 
-// SignOut signs the user out by invalidating the session token
+// deleteChallenge removes a used or expired challenge. A challenge left behind still
+// expires, so failing to delete it does not fail the request.
+func deleteChallenge(address string) {
+	if err := ChallengeStore.Delete(address); err != nil {
+		log.Printf("Failed to delete challenge of %s: %v", address, err)
+	}
+}
+
+// startAuthSession signs a device in and returns the tokens it gets, responding with an
+// error if that fails. Browsers also get them as cookies.
+func startAuthSession(c *gin.Context, session *database.AuthSession, role string) (gin.H, bool) {
+	refreshToken, err := database.CreateAuthSession(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating session"})
+		return nil, false
+	}
+	return sessionTokens(c, session, role, refreshToken)
+}
+
+// sessionTokens issues an access token for the session next to its refresh token
+func sessionTokens(c *gin.Context, session *database.AuthSession, role, refreshToken string) (gin.H, bool) {
+	token, err := GenerateAccessToken(session, role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating token"})
+		return nil, false
+	}
+
+	c.SetCookie("session_token", token, int(AccessTokenLifetime.Seconds()), "/", "", false, true)
+	c.SetCookie(refreshTokenCookie, refreshToken, int(time.Until(session.ExpiresAt).Seconds()), "/api/v1/auth", "", false, true)
+	return gin.H{
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(AccessTokenLifetime.Seconds()),
+		"session_id":    session.ID,
+	}, true
+}
+
+// requestRefreshToken returns the refresh token from the request body or cookie
+func requestRefreshToken(c *gin.Context) string {
+	var req RefreshRequest
+	if c.Request.ContentLength > 0 {
+		_ = c.ShouldBindJSON(&req)
+	}
+	if req.RefreshToken != "" {
+		return req.RefreshToken
+	}
+	token, _ := c.Cookie(refreshTokenCookie)
+	return token
+}
+
+// RefreshSession trades a refresh token for a new access token and the next refresh
+// token. The role in the new access token is read again, and a staff member who was
+// deactivated is signed out.
+func RefreshSession(c *gin.Context) {
+	metrics.AuthOperations.WithLabelValues("refresh").Inc()
+	session, refreshToken, err := database.RefreshAuthSession(requestRefreshToken(c),
+		truncateDeviceField(c.Request.UserAgent()), c.ClientIP())
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRefreshTokenReused):
+			log.Printf("Refresh token reused, session signed out")
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used, sign in again"})
+		case errors.Is(err, database.ErrAuthSessionInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, sign in again"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		}
+		return
+	}
+
+	var role string
+	if session.StaffID != nil {
+		staff, err := database.GetDBWrapper().StaffService.GetByID(*session.StaffID)
+		if err != nil || !staff.IsActive {
+			if err := database.RevokeAuthSession(session.ID, "", *session.StaffID, database.AuthSessionRevokedStaffInactive); err != nil {
+				log.Printf("Failed to revoke session %d: %v", session.ID, err)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Staff account is inactive"})
+			return
+		}
+		role = string(staff.Role)
+	} else {
+		user, err := database.GetUserByAddress(session.Address)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, sign in again"})
+			return
+		}
+		role = string(user.Role)
+	}
+
+	tokens, ok := sessionTokens(c, session, role, refreshToken)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// SignOut signs the user out by revoking the session of the refresh token or, without
+// one, of the access token
 func SignOut(c *gin.Context) {
 	metrics.AuthOperations.WithLabelValues("sign_out").Inc()
-	// Invalidate the session token (remove cookie)
+
+	var address string
+	if refreshToken := requestRefreshToken(c); refreshToken != "" {
+		session, err := database.EndAuthSession(refreshToken)
+		if err == nil {
+			address = session.Address
+		} else if !errors.Is(err, database.ErrAuthSessionInvalid) {
+			log.Printf("Failed to end session: %v", err)
+		}
+	} else if token, ok := bearerToken(c.GetHeader("Authorization")); ok {
+		if _, session, err := VerifySessionToken(token); err == nil {
+			var staffID uint
+			if session.StaffID != nil {
+				staffID = *session.StaffID
+			}
+			if err := database.RevokeAuthSession(session.ID, session.Address, staffID, database.AuthSessionRevokedSignOut); err != nil {
+				log.Printf("Failed to end session %d: %v", session.ID, err)
+			}
+			address = session.Address
+		}
+	}
+
 	c.SetCookie("session_token", "", -1, "/", "", false, true)
+	c.SetCookie(refreshTokenCookie, "", -1, "/api/v1/auth", "", false, true)
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully signed out"})
 
 	// Track sign out
-	claims, ok := c.Get("claims")
-	if !ok {
-		log.Println("Failed to get claims from context")
-		return
-	}
-	claimsMap, ok := claims.(map[string]interface{})
-	if !ok {
-		log.Println("Failed to convert claims to map")
+	if address == "" {
 		return
 	}
 	properties := map[string]interface{}{
-		"user_address": claimsMap["address"],
+		"user_address": address,
 		"timestamp":    time.Now(),
 	}
 	err := metrics.TrackGeneralEvent("User Sign Out", properties)
@@ -3,6 +3,7 @@ package server
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -10,27 +11,62 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
 	"payverge/internal/logic"
+	"payverge/internal/siwe"
 	"payverge/internal/structs"
 )
 
 type AuthHandlersTestSuite struct {
 	suite.Suite
 	router *gin.Engine
+	db     *gorm.DB
+}
+
+// setupAuthTestDB opens a test database with the tables sign-in touches
+func setupAuthTestDB(tb testing.TB) *gorm.DB {
+	db := dbtest.Open(tb)
+	database.InitTestDB(db)
+	return db
+}
+
+func (suite *AuthHandlersTestSuite) SetupSuite() {
+	suite.db = setupAuthTestDB(suite.T())
+}
+
+func (suite *AuthHandlersTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
 }
 
 func (suite *AuthHandlersTestSuite) SetupTest() {
 	gin.SetMode(gin.TestMode)
 	suite.router = gin.New()
+
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM auth_sessions")
+	suite.db.Exec("DELETE FROM staff")
+	suite.db.Exec("DELETE FROM users")
+	structs.PreviousSecretKeys = nil
 	
 	// Initialize challenge store for testing
-	ChallengeStore = logic.NewChallengeStore()
+	ChallengeStore = logic.NewMemoryChallengeStore()
 	
 	// Set up test secret key
 	structs.SecretKey = []byte("test-secret-key-for-testing-purposes")
+
+	ConfigureSignIn(siwe.Config{Domains: []string{"app.payverge.test"}, ChainIDs: []int64{8453}}, siwe.NewVerifier(8453, nil))
 	
 	// Mock external services to prevent panics
 	os.Setenv("DISABLE_POSTHOG", "true")
@@ -63,7 +99,8 @@ func (suite *AuthHandlersTestSuite) TestGenerateChallenge_Success() {
 	assert.Contains(suite.T(), response, "challenge")
 
 	// Verify challenge is stored
-	challenge, exists := ChallengeStore.Get(strings.ToLower(requestBody["address"]))
+	challenge, exists, err := ChallengeStore.Get(strings.ToLower(requestBody["address"]))
+	assert.NoError(suite.T(), err)
 	assert.True(suite.T(), exists)
 	assert.Equal(suite.T(), response["challenge"], challenge.Value)
 }
@@ -138,8 +175,7 @@ func (suite *AuthHandlersTestSuite) TestGetSession_ValidToken() {
 
 	// Generate a valid token
 	address := "0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1"
-	token, err := GenerateToken(address, structs.RoleUser)
-	assert.NoError(suite.T(), err)
+	token, _ := suite.signIn(address)
 
 	req, _ := http.NewRequest("GET", "/session", nil)
 	req.Header.Set("Authorization", "Bearer \""+token+"\"")
@@ -149,7 +185,7 @@ func (suite *AuthHandlersTestSuite) TestGetSession_ValidToken() {
 	assert.Equal(suite.T(), 200, w.Code)
 	
 	var response map[string]interface{}
-	err = json.Unmarshal(w.Body.Bytes(), &response)
+	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(suite.T(), err)
 	assert.Contains(suite.T(), response, "session_token")
 }
@@ -185,42 +221,74 @@ func (suite *AuthHandlersTestSuite) TestSignIn_InvalidMessage() {
 	assert.Contains(suite.T(), w.Body.String(), "Invalid message")
 }
 
+// signInMessage returns an EIP-4361 message from the configured test site
+func signInMessage(address, nonce string) *siwe.Message {
+	return &siwe.Message{
+		Domain:    "app.payverge.test",
+		Address:   address,
+		Statement: "Please sign with your account to make sure this wallet is yours",
+		URI:       "https://app.payverge.test",
+		Version:   "1",
+		ChainID:   8453,
+		Nonce:     nonce,
+		IssuedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+}
+
+func (suite *AuthHandlersTestSuite) postSignIn(message, signature string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/signin", SignIn)
+	jsonBody, _ := json.Marshal(SignInRequest{Message: message, Signature: signature})
+	req, _ := http.NewRequest("POST", "/signin", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
 func (suite *AuthHandlersTestSuite) TestSignIn_InvalidChallenge() {
-	suite.router.POST("/signin", SignIn)
+	message := signInMessage("0x742d35Cc6634C0532925a3b844Bc454e4438f44e", "invalidchallenge")
+	w := suite.postSignIn(message.String(), "0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1b")
 
-	// Create a proper SIWE message format but with invalid challenge
-	message := `example.com wants you to sign in with your Ethereum account:
-0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "Invalid or expired challenge")
+}
 
-I accept the ExampleOrg Terms of Service: https://service.invalid
+// Messages signed for another site or chain are refused before anything else is checked
+func (suite *AuthHandlersTestSuite) TestSignIn_MessageForAnotherSite() {
+	message := signInMessage("0x742d35Cc6634C0532925a3b844Bc454e4438f44e", "abcdef0123456789")
+	message.Domain, message.URI = "payverge.evil", "https://payverge.evil"
+	w := suite.postSignIn(message.String(), "0x00")
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "domain is not allowed")
 
-URI: https://example.com/login
-Version: 1
-Chain ID: #1
-Nonce: invalid-challenge
-Issued At: 2021-01-01T00:00:00.000Z
-Expiration Time: 2021-01-01T00:00:00.000Z`
+	message = signInMessage("0x742d35Cc6634C0532925a3b844Bc454e4438f44e", "abcdef0123456789")
+	message.ChainID = 1
+	w = suite.postSignIn(message.String(), "0x00")
+	assert.Equal(suite.T(), 400, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "chain is not allowed")
+}
 
-	requestBody := SignInRequest{
-		Message:   message,
-		Signature: "0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef1b",
-	}
-	jsonBody, _ := json.Marshal(requestBody)
+func (suite *AuthHandlersTestSuite) TestSignIn_InvalidSignature() {
+	signer, err := crypto.GenerateKey()
+	suite.Require().NoError(err)
+	other, err := crypto.GenerateKey()
+	suite.Require().NoError(err)
 
-	req, _ := http.NewRequest("POST", "/signin", bytes.NewBuffer(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	w := httptest.NewRecorder()
-	suite.router.ServeHTTP(w, req)
+	address := crypto.PubkeyToAddress(signer.PublicKey).Hex()
+	ChallengeStore.Set(strings.ToLower(address), logic.Challenge{Value: "abcdef0123456789", ExpiresAt: time.Now().Add(time.Minute)})
+	message := signInMessage(address, "abcdef0123456789").String()
+
+	sig, err := crypto.Sign(accounts.TextHash([]byte(message)), other)
+	suite.Require().NoError(err)
+	w := suite.postSignIn(message, hexutil.Encode(sig))
 
-	// The test should fail at signature verification due to invalid signature format
-	// This is acceptable since we're testing the error handling path
 	assert.Equal(suite.T(), 400, w.Code)
-	// Accept either signature error or challenge error since both are validation failures
-	body := w.Body.String()
-	assert.True(suite.T(), 
-		strings.Contains(body, "Invalid signature") || 
-		strings.Contains(body, "Invalid or expired challenge"),
-		"Expected signature or challenge error, got: %s", body)
+	assert.Contains(suite.T(), w.Body.String(), "Invalid signature")
+	// The challenge stays usable for a correct signature
+	_, exists, err := ChallengeStore.Get(strings.ToLower(address))
+	suite.Require().NoError(err)
+	assert.True(suite.T(), exists)
 }
 
 // Test SignOut Handler
@@ -257,9 +325,10 @@ func (suite *AuthHandlersTestSuite) TestChallengeStore_SetAndGet() {
 		ExpiresAt: time.Now().Add(5 * time.Minute),
 	}
 	
-	ChallengeStore.Set(address, challenge)
+	assert.NoError(suite.T(), ChallengeStore.Set(address, challenge))
 	
-	retrieved, exists := ChallengeStore.Get(address)
+	retrieved, exists, err := ChallengeStore.Get(address)
+	assert.NoError(suite.T(), err)
 	assert.True(suite.T(), exists)
 	assert.Equal(suite.T(), challengeValue, retrieved.Value)
 }
@@ -273,9 +342,10 @@ func (suite *AuthHandlersTestSuite) TestChallengeStore_ExpiredChallenge() {
 		ExpiresAt: time.Now().Add(-1 * time.Minute), // Expired
 	}
 	
-	ChallengeStore.Set(address, challenge)
+	assert.NoError(suite.T(), ChallengeStore.Set(address, challenge))
 	
-	retrieved, exists := ChallengeStore.Get(address)
+	retrieved, exists, err := ChallengeStore.Get(address)
+	assert.NoError(suite.T(), err)
 	assert.True(suite.T(), exists) // Store doesn't auto-cleanup, handler should check expiry
 	assert.True(suite.T(), time.Now().After(retrieved.ExpiresAt))
 }
@@ -289,24 +359,228 @@ func (suite *AuthHandlersTestSuite) TestChallengeStore_Delete() {
 		ExpiresAt: time.Now().Add(5 * time.Minute),
 	}
 	
-	ChallengeStore.Set(address, challenge)
-	ChallengeStore.Delete(address)
+	assert.NoError(suite.T(), ChallengeStore.Set(address, challenge))
+	assert.NoError(suite.T(), ChallengeStore.Delete(address))
 	
-	_, exists := ChallengeStore.Get(address)
+	_, exists, err := ChallengeStore.Get(address)
+	assert.NoError(suite.T(), err)
 	assert.False(suite.T(), exists)
 }
 
+// signIn starts a session for a wallet user and returns its access and refresh tokens
+func (suite *AuthHandlersTestSuite) signIn(address string) (string, string) {
+	session := &database.AuthSession{Address: strings.ToLower(address)}
+	refreshToken, err := database.CreateAuthSession(session)
+	suite.Require().NoError(err)
+	token, err := GenerateAccessToken(session, string(structs.RoleUser))
+	suite.Require().NoError(err)
+	return token, refreshToken
+}
+
+// authorized sends a request with an access token through the authentication middleware
+func (suite *AuthHandlersTestSuite) authorized(method, path, token string) *httptest.ResponseRecorder {
+	router := gin.New()
+	protected := router.Group("/", AuthenticationMiddleware())
+	protected.GET("/sessions", GetAuthSessions)
+	protected.DELETE("/sessions", RevokeOtherAuthSessions)
+	protected.DELETE("/sessions/:sessionId", RevokeAuthSession)
+
+	req, _ := http.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *AuthHandlersTestSuite) postRefresh(refreshToken string) *httptest.ResponseRecorder {
+	router := gin.New()
+	router.POST("/refresh", RefreshSession)
+	jsonBody, _ := json.Marshal(RefreshRequest{RefreshToken: refreshToken})
+	req, _ := http.NewRequest("POST", "/refresh", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
 // Test Helper Functions
-func (suite *AuthHandlersTestSuite) TestGenerateToken_ValidInput() {
+func (suite *AuthHandlersTestSuite) TestGenerateAccessToken_ValidInput() {
 	address := "0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1"
-	
-	token, err := GenerateToken(address, structs.RoleUser)
-	assert.NoError(suite.T(), err)
+
+	token, _ := suite.signIn(address)
 	assert.NotEmpty(suite.T(), token)
-	
+
 	// Token should be a valid JWT format (3 parts separated by dots)
 	parts := strings.Split(token, ".")
 	assert.Equal(suite.T(), 3, len(parts))
+
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, jwt.MapClaims{})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), keyID(structs.SecretKey), parsed.Header["kid"])
+	claims := parsed.Claims.(jwt.MapClaims)
+	assert.NotEmpty(suite.T(), claims["jti"])
+	assert.NotEmpty(suite.T(), claims["sid"])
+	assert.InDelta(suite.T(), time.Now().Add(AccessTokenLifetime).Unix(), claims["exp"], 5)
+}
+
+// Staff tokens identify the staff member and never pass as a wallet address
+func (suite *AuthHandlersTestSuite) TestStaffToken_Authenticates() {
+	var address, staffID interface{}
+	var hasAddress bool
+	suite.router.GET("/whoami", AuthenticationMiddleware(), func(c *gin.Context) {
+		address, hasAddress = c.Get("address")
+		staffID, _ = c.Get("staff_id")
+		c.Status(http.StatusOK)
+	})
+
+	staff, business := uint(7), uint(3)
+	session := &database.AuthSession{StaffID: &staff, BusinessID: &business}
+	_, err := database.CreateAuthSession(session)
+	suite.Require().NoError(err)
+	token, err := GenerateAccessToken(session, string(database.StaffRoleServer))
+	assert.NoError(suite.T(), err)
+
+	claims, err := VerifyToken(token)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), float64(3), claims["business_id"])
+	assert.Equal(suite.T(), "server", claims["role"])
+
+	req, _ := http.NewRequest("GET", "/whoami", nil)
+	req.Header.Set("Authorization", "Bearer \""+token+"\"")
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), uint(7), staffID)
+	assert.False(suite.T(), hasAddress, "unexpected address %v", address)
+}
+
+// Tokens are accepted with or without the quotes some clients wrap them in
+func (suite *AuthHandlersTestSuite) TestMiddleware_AcceptsUnquotedToken() {
+	token, _ := suite.signIn("0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1")
+
+	assert.Equal(suite.T(), 200, suite.authorized("GET", "/sessions", token).Code)
+	assert.Equal(suite.T(), 200, suite.authorized("GET", "/sessions", "\""+token+"\"").Code)
+	assert.Equal(suite.T(), 401, suite.authorized("GET", "/sessions", "\"\"").Code)
+}
+
+func (suite *AuthHandlersTestSuite) TestRefreshSession_RotatesRefreshToken() {
+	address := "0x742d35cc6635c0532925a3b8d400e4c3f2c0c1c1"
+	suite.Require().NoError(database.RegisterUser(newUser(address)))
+	_, refreshToken := suite.signIn(address)
+
+	w := suite.postRefresh(refreshToken)
+	suite.Require().Equal(200, w.Code, w.Body.String())
+	var response struct {
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEqual(suite.T(), refreshToken, response.RefreshToken)
+	assert.Equal(suite.T(), 200, suite.authorized("GET", "/sessions", response.Token).Code)
+
+	// The rotated token only works once; presenting it again means it was copied, so
+	// the whole session is signed out
+	w = suite.postRefresh(refreshToken)
+	assert.Equal(suite.T(), 401, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "already used")
+	assert.Equal(suite.T(), 401, suite.postRefresh(response.RefreshToken).Code)
+	assert.Equal(suite.T(), 401, suite.authorized("GET", "/sessions", response.Token).Code)
+}
+
+func (suite *AuthHandlersTestSuite) TestRefreshSession_InactiveStaff() {
+	staff := &database.Staff{BusinessID: 3, Email: "server@example.com", Name: "Server", Role: database.StaffRoleServer, IsActive: true, InvitedBy: "0xowner"}
+	suite.Require().NoError(suite.db.Create(staff).Error)
+	// Deactivated after signing in
+	suite.Require().NoError(suite.db.Model(staff).Update("is_active", false).Error)
+	session := &database.AuthSession{StaffID: &staff.ID, BusinessID: &staff.BusinessID}
+	refreshToken, err := database.CreateAuthSession(session)
+	suite.Require().NoError(err)
+
+	w := suite.postRefresh(refreshToken)
+	assert.Equal(suite.T(), 401, w.Code)
+	_, err = database.GetActiveAuthSession(session.ID)
+	assert.ErrorIs(suite.T(), err, database.ErrAuthSessionInvalid)
+}
+
+func (suite *AuthHandlersTestSuite) TestSignOut_RevokesSession() {
+	token, refreshToken := suite.signIn("0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1")
+
+	router := gin.New()
+	router.POST("/signout", SignOut)
+	req, _ := http.NewRequest("POST", "/signout", nil)
+	req.Header.Set("Authorization", "Bearer \""+token+"\"")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), 200, w.Code)
+
+	// The access token stops working before it expires, and cannot be refreshed
+	w = suite.authorized("GET", "/sessions", token)
+	assert.Equal(suite.T(), 401, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "Session has ended")
+	assert.Equal(suite.T(), 401, suite.postRefresh(refreshToken).Code)
+}
+
+func (suite *AuthHandlersTestSuite) TestSessions_ListAndRevoke() {
+	address := "0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1"
+	token, _ := suite.signIn(address)
+	phone, _ := suite.signIn(address)
+	laptop, _ := suite.signIn(address)
+	other, _ := suite.signIn("0x0000000000000000000000000000000000000001")
+
+	w := suite.authorized("GET", "/sessions", token)
+	suite.Require().Equal(200, w.Code)
+	var response struct {
+		Sessions         []database.AuthSession `json:"sessions"`
+		CurrentSessionID uint                   `json:"current_session_id"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(suite.T(), response.Sessions, 3)
+	assert.NotZero(suite.T(), response.CurrentSessionID)
+
+	// Another user's sessions cannot be revoked
+	claims, err := VerifyToken(other)
+	suite.Require().NoError(err)
+	w = suite.authorized("DELETE", fmt.Sprintf("/sessions/%d", uint(claims["sid"].(float64))), token)
+	assert.Equal(suite.T(), 404, w.Code)
+
+	claims, err = VerifyToken(phone)
+	suite.Require().NoError(err)
+	w = suite.authorized("DELETE", fmt.Sprintf("/sessions/%d", uint(claims["sid"].(float64))), token)
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), 401, suite.authorized("GET", "/sessions", phone).Code)
+
+	w = suite.authorized("DELETE", "/sessions", token)
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"revoked":1`)
+	assert.Equal(suite.T(), 401, suite.authorized("GET", "/sessions", laptop).Code)
+	assert.Equal(suite.T(), 200, suite.authorized("GET", "/sessions", token).Code)
+	assert.Equal(suite.T(), 200, suite.authorized("GET", "/sessions", other).Code)
+}
+
+// Rolling the signing key keeps tokens signed with the old key working while the old
+// key is listed as a previous key
+func (suite *AuthHandlersTestSuite) TestSigningKeyRotation() {
+	token, _ := suite.signIn("0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1")
+	oldKey := structs.SecretKey
+	defer func() { structs.SecretKey = oldKey }()
+
+	structs.SecretKey = []byte("rolled-secret-key-for-testing-purposes")
+	assert.Equal(suite.T(), 401, suite.authorized("GET", "/sessions", token).Code)
+
+	structs.PreviousSecretKeys = [][]byte{oldKey}
+	assert.Equal(suite.T(), 200, suite.authorized("GET", "/sessions", token).Code)
+
+	fresh, _ := suite.signIn("0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1")
+	parsed, _, err := new(jwt.Parser).ParseUnverified(fresh, jwt.MapClaims{})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), keyID(structs.SecretKey), parsed.Header["kid"])
+
+	// A token without a key ID, or signed with another algorithm, is refused
+	claims := jwt.MapClaims{"sid": 1, "address": "0xabc", "exp": time.Now().Add(time.Minute).Unix()}
+	unnamed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(structs.SecretKey)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 401, suite.authorized("GET", "/sessions", unnamed).Code)
 }
 
 // Benchmark tests
@@ -315,7 +589,7 @@ func BenchmarkGenerateChallenge(b *testing.B) {
 	router := gin.New()
 	router.POST("/challenge", GenerateChallenge)
 	
-	ChallengeStore = logic.NewChallengeStore()
+	ChallengeStore = logic.NewMemoryChallengeStore()
 	
 	requestBody := map[string]string{
 		"address": "0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1",
@@ -337,10 +611,12 @@ func BenchmarkGetSession(b *testing.B) {
 	router.GET("/session", GetSession)
 	
 	structs.SecretKey = []byte("test-secret-key-for-testing-purposes")
+	setupAuthTestDB(b)
 	
 	// Generate a valid token
-	address := "0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c1"
-	token, _ := GenerateToken(address, "user")
+	session := &database.AuthSession{Address: "0x742d35cc6635c0532925a3b8d400e4c3f2c0c1c1"}
+	database.CreateAuthSession(session)
+	token, _ := GenerateAccessToken(session, "user")
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"payverge/internal/database"
+	"payverge/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BillTransferNotifier is told when bills are moved, merged or split
+type BillTransferNotifier interface {
+	NotifyBillTransfer(businessID uint, transfer *database.BillTransfer)
+}
+
+var billTransferNotifier BillTransferNotifier
+
+// SetBillTransferNotifier sets where bill transfers are announced
+func SetBillTransferNotifier(notifier BillTransferNotifier) {
+	billTransferNotifier = notifier
+}
+
+// MergeBillRequest names the bill merged into the bill in the path
+type MergeBillRequest struct {
+	BillID uint `json:"bill_id" binding:"required"`
+}
+
+// SplitBillRequest lists the items split off into a new bill
+type SplitBillRequest struct {
+	Items []database.BillItemSplit `json:"items" binding:"required,min=1"`
+}
+
+// transferBill loads the bill in the path and returns who is moving it. Access to the
+// bill's business is checked by the route.
+func transferBill(c *gin.Context) (*database.Bill, string, bool) {
+	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+		return nil, "", false
+	}
+
+	bill, _, err := database.GetBillByID(uint(billID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+		return nil, "", false
+	}
+
+	return bill, middleware.Actor(c), true
+}
+
+// respondBillTransfer announces a completed transfer and returns it, or reports why it
+// could not be applied
+func respondBillTransfer(c *gin.Context, businessID uint, transfer *database.BillTransfer, err error) {
+	if err != nil {
+		var invalid *database.InvalidBillTransitionError
+		switch {
+		case errors.Is(err, database.ErrInvalidBillTransfer):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, database.ErrTableHasOpenBill), errors.Is(err, database.ErrCounterHasOpenBill),
+			errors.Is(err, database.ErrSplitSessionLocked), errors.As(err, &invalid):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case strings.Contains(err.Error(), "not found"):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bills"})
+		}
+		return
+	}
+
+	if billTransferNotifier != nil {
+		billTransferNotifier.NotifyBillTransfer(businessID, transfer)
+	}
+	c.JSON(http.StatusOK, transfer)
+}
+
+// MoveBill moves an open bill to another table or counter
+func MoveBill(c *gin.Context) {
+	bill, actor, ok := transferBill(c)
+	if !ok {
+		return
+	}
+
+	var req database.BillTransferTarget
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := database.MoveBill(bill.BusinessID, bill.ID, req, actor)
+	respondBillTransfer(c, bill.BusinessID, transfer, err)
+}
+
+// MergeBill merges another open bill into the bill in the path, voiding the other one
+func MergeBill(c *gin.Context) {
+	bill, actor, ok := transferBill(c)
+	if !ok {
+		return
+	}
+
+	var req MergeBillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := database.MergeBills(bill.BusinessID, bill.ID, req.BillID, actor)
+	respondBillTransfer(c, bill.BusinessID, transfer, err)
+}
+
+// SplitBillItems moves some items of an open bill onto a new bill at the same table
+func SplitBillItems(c *gin.Context) {
+	bill, actor, ok := transferBill(c)
+	if !ok {
+		return
+	}
+
+	var req SplitBillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	transfer, err := database.SplitBill(bill.BusinessID, bill.ID, req.Items, actor)
+	respondBillTransfer(c, bill.BusinessID, transfer, err)
+}
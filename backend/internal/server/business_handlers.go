@@ -3,6 +3,7 @@ package server
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"regexp"
@@ -11,6 +12,8 @@ import (
 	"time"
 
 	"payverge/internal/database"
+	"payverge/internal/middleware"
+	"payverge/internal/money"
 
 	"github.com/gin-gonic/gin"
 )
@@ -48,6 +51,7 @@ type CreateBusinessRequest struct {
 	BusinessPageEnabled  bool                    `json:"business_page_enabled"`
 	ShowReviews          bool                    `json:"show_reviews"`
 	GoogleReviewsEnabled bool                    `json:"google_reviews_enabled"`
+	Timezone             string                  `json:"timezone"` // IANA name, e.g. "Europe/Madrid"
 	ReferredByCode       string                  `json:"referred_by_code"` // Referral code used during registration
 	// Subscription fields (Pay-as-you-go model)
 	PaymentAmount        string                  `json:"payment_amount"` // USDC amount for subscription
@@ -79,6 +83,7 @@ type UpdateBusinessRequest struct {
 	// Currency settings
 	DefaultCurrency      string                  `json:"default_currency"`
 	DisplayCurrency      string                  `json:"display_currency"`
+	Timezone             string                  `json:"timezone"`
 }
 
 // CreateBusiness creates a new business for the authenticated user
@@ -113,6 +118,13 @@ func CreateBusiness(c *gin.Context) {
 			return
 		}
 	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(req.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time zone"})
+		return
+	}
 
 	business := &database.Business{
 		OwnerAddress:         userAddress.(string),
@@ -136,6 +148,7 @@ func CreateBusiness(c *gin.Context) {
 		BusinessPageEnabled:  req.BusinessPageEnabled,
 		ShowReviews:          req.ShowReviews,
 		GoogleReviewsEnabled: req.GoogleReviewsEnabled,
+		Timezone:             req.Timezone,
 		ReferredByCode:       req.ReferredByCode,
 		// Subscription fields (will be updated from smart contract data)
 		SubscriptionStatus:   "active", // Default status
@@ -215,34 +228,11 @@ func GetBusiness(c *gin.Context) {
 
 // UpdateBusiness updates an existing business
 func UpdateBusiness(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Check if business exists and user owns it
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve business"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req UpdateBusinessRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -283,7 +273,7 @@ func UpdateBusiness(c *gin.Context) {
 	}
 	if req.CustomURL != "" {
 		// Check if custom URL is already taken by another business
-		if err := validateCustomURL(req.CustomURL, uint(businessID)); err != nil {
+		if err := validateCustomURL(req.CustomURL, businessID); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -311,6 +301,13 @@ func UpdateBusiness(c *gin.Context) {
 	if req.DisplayCurrency != "" {
 		business.DisplayCurrency = req.DisplayCurrency
 	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time zone"})
+			return
+		}
+		business.Timezone = req.Timezone
+	}
 	
 	business.UpdatedAt = time.Now()
 
@@ -324,36 +321,13 @@ func UpdateBusiness(c *gin.Context) {
 
 // DeleteBusiness soft deletes a business
 func DeleteBusiness(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Check if business exists and user owns it
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve business"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this business"})
-		return
-	}
-
-	if err := database.DeleteBusiness(uint(businessID)); err != nil {
+	if err := database.DeleteBusiness(businessID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete business"})
 		return
 	}
@@ -370,34 +344,11 @@ type CreateMenuRequest struct {
 
 // CreateMenu creates or updates a menu for a business
 func CreateMenu(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Check if business exists and user owns it
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve business"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req CreateMenuRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -406,7 +357,7 @@ func CreateMenu(c *gin.Context) {
 	}
 
 	// Check if menu already exists
-	existingMenu, _, err := database.GetMenuByBusinessID(uint(businessID))
+	existingMenu, _, err := database.GetMenuByBusinessID(businessID)
 	if err != nil && !strings.Contains(err.Error(), "not found") {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing menu"})
 		return
@@ -416,6 +367,10 @@ func CreateMenu(c *gin.Context) {
 		// Update existing menu
 		existingMenu.UpdatedAt = time.Now()
 		if err := database.UpdateMenu(existingMenu, req.Categories); err != nil {
+			if errors.Is(err, database.ErrInvalidModifierGroup) || errors.Is(err, database.ErrInvalidSchedule) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update menu"})
 			return
 		}
@@ -423,13 +378,17 @@ func CreateMenu(c *gin.Context) {
 	} else {
 		// Create new menu
 		menu := &database.Menu{
-			BusinessID: uint(businessID),
+			BusinessID: businessID,
 			IsActive:   true,
 			CreatedAt:  time.Now(),
 			UpdatedAt:  time.Now(),
 		}
 
 		if err := database.CreateMenu(menu, req.Categories); err != nil {
+			if errors.Is(err, database.ErrInvalidModifierGroup) || errors.Is(err, database.ErrInvalidSchedule) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create menu"})
 			return
 		}
@@ -503,21 +462,21 @@ func applyTranslationsToMenu(categories []database.MenuCategory, languageCode st
 		// Translate category name
 		var categoryNameTranslation database.Translation
 		if err := db.GetGorm().Where("entity_type = ? AND entity_id = ? AND field_name = ? AND language_code = ?", 
-			"category", i, "name", languageCode).Order("id DESC").First(&categoryNameTranslation).Error; err == nil {
+			"category", category.ID, "name", languageCode).Order("id DESC").First(&categoryNameTranslation).Error; err == nil {
 			log.Printf("✅ Found category name translation: '%s' -> '%s'", category.Name, categoryNameTranslation.TranslatedText)
 			translatedCategory.Name = categoryNameTranslation.TranslatedText
 		} else {
-			log.Printf("❌ No category name translation found for entity_id=%d, field=name, language=%s", i, languageCode)
+			log.Printf("❌ No category name translation found for entity_id=%d, field=name, language=%s", category.ID, languageCode)
 		}
 		
 		// Translate category description
 		var categoryDescTranslation database.Translation
 		if err := db.GetGorm().Where("entity_type = ? AND entity_id = ? AND field_name = ? AND language_code = ?", 
-			"category", i, "description", languageCode).Order("id DESC").First(&categoryDescTranslation).Error; err == nil {
+			"category", category.ID, "description", languageCode).Order("id DESC").First(&categoryDescTranslation).Error; err == nil {
 			log.Printf("✅ Found category description translation: '%s' -> '%s'", category.Description, categoryDescTranslation.TranslatedText)
 			translatedCategory.Description = categoryDescTranslation.TranslatedText
 		} else {
-			log.Printf("❌ No category description translation found for entity_id=%d, field=description, language=%s", i, languageCode)
+			log.Printf("❌ No category description translation found for entity_id=%d, field=description, language=%s", category.ID, languageCode)
 		}
 		
 		// Translate menu items
@@ -525,8 +484,7 @@ func applyTranslationsToMenu(categories []database.MenuCategory, languageCode st
 		for j, item := range category.Items {
 			translatedItem := item
 			
-			// Use position-based ID: categoryIndex * 1000 + itemIndex
-			entityID := i*1000 + j
+			entityID := item.ID
 			log.Printf("🍽️ Retrieving translations for item %d in category %d: '%s' (entity_id=%d)", j, i, item.Name, entityID)
 			
 			// Translate item name
@@ -553,7 +511,7 @@ func applyTranslationsToMenu(categories []database.MenuCategory, languageCode st
 			translatedOptions := make([]database.MenuItemOption, len(item.Options))
 			for k, option := range item.Options {
 				translatedOption := option
-				optionEntityID := entityID*1000 + k
+				optionEntityID := option.ID
 				
 				var optionTranslation database.Translation
 				if err := db.GetGorm().Where("entity_type = ? AND entity_id = ? AND field_name = ? AND language_code = ?", 
@@ -570,15 +528,13 @@ func applyTranslationsToMenu(categories []database.MenuCategory, languageCode st
 			// Translate allergens
 			translatedAllergens := make([]string, len(item.Allergens))
 			for k, allergen := range item.Allergens {
-				allergenEntityID := entityID*10000 + k
-				
 				var allergenTranslation database.Translation
 				if err := db.GetGorm().Where("entity_type = ? AND entity_id = ? AND field_name = ? AND language_code = ?", 
-					"allergen", allergenEntityID, "name", languageCode).Order("id DESC").First(&allergenTranslation).Error; err == nil {
+					"menu_item", entityID, allergenField(k), languageCode).Order("id DESC").First(&allergenTranslation).Error; err == nil {
 					log.Printf("✅ Found allergen translation: '%s' -> '%s'", allergen, allergenTranslation.TranslatedText)
 					translatedAllergens[k] = allergenTranslation.TranslatedText
 				} else {
-					log.Printf("❌ No allergen translation found for entity_id=%d, field=name, language=%s", allergenEntityID, languageCode)
+					log.Printf("❌ No allergen translation found for entity_id=%d, field=%s, language=%s", entityID, allergenField(k), languageCode)
 					translatedAllergens[k] = allergen // Keep original if no translation
 				}
 			}
@@ -587,15 +543,13 @@ func applyTranslationsToMenu(categories []database.MenuCategory, languageCode st
 			// Translate dietary tags
 			translatedTags := make([]string, len(item.DietaryTags))
 			for k, tag := range item.DietaryTags {
-				tagEntityID := entityID*100000 + k
-				
 				var tagTranslation database.Translation
 				if err := db.GetGorm().Where("entity_type = ? AND entity_id = ? AND field_name = ? AND language_code = ?", 
-					"dietary_tag", tagEntityID, "name", languageCode).Order("id DESC").First(&tagTranslation).Error; err == nil {
+					"menu_item", entityID, dietaryTagField(k), languageCode).Order("id DESC").First(&tagTranslation).Error; err == nil {
 					log.Printf("✅ Found dietary tag translation: '%s' -> '%s'", tag, tagTranslation.TranslatedText)
 					translatedTags[k] = tagTranslation.TranslatedText
 				} else {
-					log.Printf("❌ No dietary tag translation found for entity_id=%d, field=name, language=%s", tagEntityID, languageCode)
+					log.Printf("❌ No dietary tag translation found for entity_id=%d, field=%s, language=%s", entityID, dietaryTagField(k), languageCode)
 					translatedTags[k] = tag // Keep original if no translation
 				}
 			}
@@ -629,8 +583,8 @@ func TranslateMenu(c *gin.Context) {
 		return
 	}
 
-	// Get the menu
-	_, categories, err := database.GetMenuByBusinessID(uint(businessID))
+	// Get the categories of all menus
+	categories, err := database.GetAllMenuCategories(uint(businessID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
 		return
@@ -656,7 +610,7 @@ func TranslateMenu(c *gin.Context) {
 			if err == nil && translatedTexts[req.LanguageCode] != "" {
 				translation := &database.Translation{
 					EntityType:        "category",
-					EntityID:          uint(i),
+					EntityID:          category.ID,
 					FieldName:         "name",
 					LanguageCode:      req.LanguageCode,
 					OriginalText:      category.Name,
@@ -665,7 +619,7 @@ func TranslateMenu(c *gin.Context) {
 					TranslationSource: "google_translate",
 				}
 				log.Printf("💾 Storing category name translation: entity_id=%d, original='%s', translated='%s'", 
-					i, category.Name, translatedTexts[req.LanguageCode])
+					category.ID, category.Name, translatedTexts[req.LanguageCode])
 				db.TranslationService.SaveTranslation(translation)
 			} else {
 				log.Printf("❌ Failed to translate category name '%s': %v", category.Name, err)
@@ -678,7 +632,7 @@ func TranslateMenu(c *gin.Context) {
 			if err == nil && translatedTexts[req.LanguageCode] != "" {
 				translation := &database.Translation{
 					EntityType:        "category",
-					EntityID:          uint(i),
+					EntityID:          category.ID,
 					FieldName:         "description",
 					LanguageCode:      req.LanguageCode,
 					OriginalText:      category.Description,
@@ -687,7 +641,7 @@ func TranslateMenu(c *gin.Context) {
 					TranslationSource: "google_translate",
 				}
 				log.Printf("💾 Storing category description translation: entity_id=%d, original='%s', translated='%s'", 
-					i, category.Description, translatedTexts[req.LanguageCode])
+					category.ID, category.Description, translatedTexts[req.LanguageCode])
 				db.TranslationService.SaveTranslation(translation)
 			} else {
 				log.Printf("❌ Failed to translate category description '%s': %v", category.Description, err)
@@ -696,8 +650,7 @@ func TranslateMenu(c *gin.Context) {
 
 		// Translate menu items
 		for j, item := range category.Items {
-			// Use position-based ID: categoryIndex * 1000 + itemIndex
-			entityID := i*1000 + j
+			entityID := item.ID
 			log.Printf("🍽️ Processing item %d in category %d: '%s' (entity_id=%d)", j, i, item.Name, entityID)
 
 			// Translate item name
@@ -706,7 +659,7 @@ func TranslateMenu(c *gin.Context) {
 				if err == nil && translatedTexts[req.LanguageCode] != "" {
 					translation := &database.Translation{
 						EntityType:        "menu_item",
-						EntityID:          uint(entityID),
+						EntityID:          entityID,
 						FieldName:         "name",
 						LanguageCode:      req.LanguageCode,
 						OriginalText:      item.Name,
@@ -728,7 +681,7 @@ func TranslateMenu(c *gin.Context) {
 				if err == nil && translatedTexts[req.LanguageCode] != "" {
 					translation := &database.Translation{
 						EntityType:        "menu_item",
-						EntityID:          uint(entityID),
+						EntityID:          entityID,
 						FieldName:         "description",
 						LanguageCode:      req.LanguageCode,
 						OriginalText:      item.Description,
@@ -745,14 +698,14 @@ func TranslateMenu(c *gin.Context) {
 			}
 
 			// Translate item options
-			for k, option := range item.Options {
+			for _, option := range item.Options {
 				if option.Name != "" {
-					optionEntityID := entityID*1000 + k // Unique ID for each option
+					optionEntityID := option.ID
 					translatedTexts, err := servicesTranslationService.TranslateText(option.Name, []string{req.LanguageCode})
 					if err == nil && translatedTexts[req.LanguageCode] != "" {
 						translation := &database.Translation{
 							EntityType:        "menu_item_option",
-							EntityID:          uint(optionEntityID),
+							EntityID:          optionEntityID,
 							FieldName:         "name",
 							LanguageCode:      req.LanguageCode,
 							OriginalText:      option.Name,
@@ -772,13 +725,12 @@ func TranslateMenu(c *gin.Context) {
 			// Translate allergens
 			for k, allergen := range item.Allergens {
 				if allergen != "" {
-					allergenEntityID := entityID*10000 + k // Unique ID for each allergen
 					translatedTexts, err := servicesTranslationService.TranslateText(allergen, []string{req.LanguageCode})
 					if err == nil && translatedTexts[req.LanguageCode] != "" {
 						translation := &database.Translation{
-							EntityType:        "allergen",
-							EntityID:          uint(allergenEntityID),
-							FieldName:         "name",
+							EntityType:        "menu_item",
+							EntityID:          entityID,
+							FieldName:         allergenField(k),
 							LanguageCode:      req.LanguageCode,
 							OriginalText:      allergen,
 							TranslatedText:    translatedTexts[req.LanguageCode],
@@ -786,7 +738,7 @@ func TranslateMenu(c *gin.Context) {
 							TranslationSource: "google_translate",
 						}
 						log.Printf("💾 Storing allergen translation: entity_id=%d, original='%s', translated='%s'", 
-							allergenEntityID, allergen, translatedTexts[req.LanguageCode])
+							entityID, allergen, translatedTexts[req.LanguageCode])
 						db.TranslationService.SaveTranslation(translation)
 					} else {
 						log.Printf("❌ Failed to translate allergen '%s': %v", allergen, err)
@@ -797,13 +749,12 @@ func TranslateMenu(c *gin.Context) {
 			// Translate dietary tags
 			for k, tag := range item.DietaryTags {
 				if tag != "" {
-					tagEntityID := entityID*100000 + k // Unique ID for each dietary tag
 					translatedTexts, err := servicesTranslationService.TranslateText(tag, []string{req.LanguageCode})
 					if err == nil && translatedTexts[req.LanguageCode] != "" {
 						translation := &database.Translation{
-							EntityType:        "dietary_tag",
-							EntityID:          uint(tagEntityID),
-							FieldName:         "name",
+							EntityType:        "menu_item",
+							EntityID:          entityID,
+							FieldName:         dietaryTagField(k),
 							LanguageCode:      req.LanguageCode,
 							OriginalText:      tag,
 							TranslatedText:    translatedTexts[req.LanguageCode],
@@ -811,7 +762,7 @@ func TranslateMenu(c *gin.Context) {
 							TranslationSource: "google_translate",
 						}
 						log.Printf("💾 Storing dietary tag translation: entity_id=%d, original='%s', translated='%s'", 
-							tagEntityID, tag, translatedTexts[req.LanguageCode])
+							entityID, tag, translatedTexts[req.LanguageCode])
 						db.TranslationService.SaveTranslation(translation)
 					} else {
 						log.Printf("❌ Failed to translate dietary tag '%s': %v", tag, err)
@@ -844,7 +795,9 @@ func isValidEthereumAddress(address string) bool {
 // Phase 2: Enhanced Menu Management API Endpoints
 
 // Menu category request structures
+// AddCategoryRequest adds a category to the menu with MenuID, or to the main menu
 type AddCategoryRequest struct {
+	MenuID      uint                  `json:"menu_id"`
 	Name        string                `json:"name" binding:"required"`
 	Description string                `json:"description"`
 	Items       []database.MenuItem   `json:"items"`
@@ -858,41 +811,40 @@ type UpdateCategoryRequest struct {
 
 // Menu item request structures
 type AddMenuItemRequest struct {
-	CategoryIndex int                 `json:"category_index" binding:"min=0"`
-	Item          database.MenuItem   `json:"item" binding:"required"`
+	CategoryID uint              `json:"category_id" binding:"required"`
+	Item       database.MenuItem `json:"item" binding:"required"`
 }
 
+// UpdateMenuItemRequest updates a menu item; a category_id moves it to that category
 type UpdateMenuItemRequest struct {
-	CategoryIndex int                 `json:"category_index" binding:"min=0"`
-	ItemIndex     int                 `json:"item_index" binding:"min=0"`
-	Item          database.MenuItem   `json:"item" binding:"required"`
+	CategoryID uint              `json:"category_id"`
+	Item       database.MenuItem `json:"item" binding:"required"`
 }
 
-// AddMenuCategory adds a new category to a business menu
-func AddMenuCategory(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
+// menuError responds to a failed menu change
+func menuError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, database.ErrMenuNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+	case errors.Is(err, database.ErrMenuCategoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
+	case errors.Is(err, database.ErrMenuItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Menu item not found"})
+	case errors.Is(err, database.ErrInvalidModifierGroup), errors.Is(err, database.ErrInvalidSchedule),
+		errors.Is(err, database.ErrInvalidMenuImport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
+}
 
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this business"})
+// AddMenuCategory adds a new category to a business menu
+func AddMenuCategory(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req AddCategoryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -906,55 +858,39 @@ func AddMenuCategory(c *gin.Context) {
 		Items:       req.Items,
 	}
 
-	if err := database.AddMenuCategory(uint(businessID), category); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := database.AddMenuCategory(businessID, req.MenuID, &category); err != nil {
+		menuError(c, err)
 		return
 	}
 
-	// Auto-translate the new category
+	// Auto-translate the new category and its items
 	go func() {
-		// Get the category index by counting existing categories
-		_, categories, _ := database.GetMenuByBusinessID(uint(businessID))
-		categoryIndex := len(categories) - 1 // New category is at the end
-		if err := autoTranslateCategory(uint(businessID), categoryIndex, category.Name, category.Description); err != nil {
+		if err := autoTranslateCategory(businessID, category); err != nil {
 			log.Printf("Failed to translate category: %v", err)
 		}
+		for _, item := range category.Items {
+			if err := autoTranslateMenuItem(businessID, item); err != nil {
+				log.Printf("Failed to translate menu item: %v", err)
+			}
+		}
 	}()
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Category added successfully"})
+	c.JSON(http.StatusCreated, gin.H{"message": "Category added successfully", "category": category})
 }
 
 // UpdateMenuCategory updates a specific category in a business menu
 func UpdateMenuCategory(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	categoryIndex, err := strconv.Atoi(c.Param("category_index"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category index"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
+	categoryID, err := strconv.ParseUint(c.Param("category_id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
 		return
 	}
 
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req UpdateCategoryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -968,93 +904,74 @@ func UpdateMenuCategory(c *gin.Context) {
 		Items:       req.Items,
 	}
 
-	if err := database.UpdateMenuCategory(uint(businessID), categoryIndex, category); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := database.UpdateMenuCategory(businessID, uint(categoryID), &category); err != nil {
+		menuError(c, err)
 		return
 	}
 
 	// Auto-translate the updated category
 	go func() {
-		if err := autoTranslateCategory(uint(businessID), categoryIndex, category.Name, category.Description); err != nil {
+		if err := autoTranslateCategory(businessID, category); err != nil {
 			log.Printf("Failed to translate updated category: %v", err)
 		}
+		for _, item := range req.Items {
+			if err := autoTranslateMenuItem(businessID, item); err != nil {
+				log.Printf("Failed to translate updated menu item: %v", err)
+			}
+		}
 	}()
 
-	c.JSON(http.StatusOK, gin.H{"message": "Category updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Category updated successfully", "category": category})
 }
 
-// DeleteMenuCategory removes a category from a business menu
+// DeleteMenuCategory removes a category and its items from a business menu
 func DeleteMenuCategory(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	categoryID, err := strconv.ParseUint(c.Param("category_id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
 		return
 	}
 
-	categoryIndex, err := strconv.Atoi(c.Param("category_index"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category index"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
+	// Keep the category's items so their translations can be removed too
+	categories, err := database.GetAllMenuCategories(businessID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Category not found"})
 		return
 	}
+	var deleted database.MenuCategory
+	for _, category := range categories {
+		if category.ID == uint(categoryID) {
+			deleted = category
+		}
+	}
 
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this business"})
+	if err := database.DeleteMenuCategory(businessID, uint(categoryID)); err != nil {
+		menuError(c, err)
 		return
 	}
 
-	// Delete translations for the category before deleting the category
 	go func() {
-		if err := deleteTranslationsForCategory(uint(businessID), categoryIndex); err != nil {
+		if err := deleteTranslationsForCategory(deleted); err != nil {
 			log.Printf("Failed to delete category translations: %v", err)
 		}
 	}()
 
-	if err := database.DeleteMenuCategory(uint(businessID), categoryIndex); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Category deleted successfully"})
 }
 
 // AddMenuItem adds a new item to a menu category
 func AddMenuItem(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req AddMenuItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1062,51 +979,35 @@ func AddMenuItem(c *gin.Context) {
 		return
 	}
 
-	if err := database.AddMenuItem(uint(businessID), req.CategoryIndex, req.Item); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	item := req.Item
+	if err := database.AddMenuItem(businessID, req.CategoryID, &item); err != nil {
+		menuError(c, err)
 		return
 	}
 
 	// Auto-translate the new menu item
 	go func() {
-		// Get the item index by counting existing items in the category
-		_, categories, _ := database.GetMenuByBusinessID(uint(businessID))
-		if req.CategoryIndex < len(categories) {
-			itemIndex := len(categories[req.CategoryIndex].Items) - 1 // New item is at the end
-			if err := autoTranslateMenuItem(uint(businessID), req.CategoryIndex, itemIndex, req.Item.Name, req.Item.Description, req.Item.Options, req.Item.Allergens, req.Item.DietaryTags); err != nil {
-				log.Printf("Failed to translate menu item: %v", err)
-			}
+		if err := autoTranslateMenuItem(businessID, item); err != nil {
+			log.Printf("Failed to translate menu item: %v", err)
 		}
 	}()
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Menu item added successfully"})
+	c.JSON(http.StatusCreated, gin.H{"message": "Menu item added successfully", "item": item})
 }
 
 // UpdateMenuItem updates a specific menu item
 func UpdateMenuItem(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
 		return
 	}
 
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req UpdateMenuItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1114,156 +1015,433 @@ func UpdateMenuItem(c *gin.Context) {
 		return
 	}
 
-	if err := database.UpdateMenuItem(uint(businessID), req.CategoryIndex, req.ItemIndex, req.Item); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	item := req.Item
+	item.CategoryID = req.CategoryID
+	if err := database.UpdateMenuItem(businessID, uint(itemID), &item); err != nil {
+		menuError(c, err)
 		return
 	}
 
 	// Auto-translate the updated menu item
 	go func() {
-		if err := autoTranslateMenuItem(uint(businessID), req.CategoryIndex, req.ItemIndex, req.Item.Name, req.Item.Description, req.Item.Options, req.Item.Allergens, req.Item.DietaryTags); err != nil {
+		if err := autoTranslateMenuItem(businessID, item); err != nil {
 			log.Printf("Failed to translate updated menu item: %v", err)
 		}
 	}()
 
-	c.JSON(http.StatusOK, gin.H{"message": "Menu item updated successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Menu item updated successfully", "item": item})
 }
 
 // DeleteMenuItem removes an item from a menu category
 func DeleteMenuItem(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	categoryIndex, err := strconv.Atoi(c.Param("category_index"))
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category index"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
 		return
 	}
 
-	itemIndex, err := strconv.Atoi(c.Param("item_index"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item index"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
+	item, err := database.GetMenuItem(businessID, uint(itemID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+		menuError(c, err)
 		return
 	}
 
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this business"})
+	if err := database.DeleteMenuItem(businessID, uint(itemID)); err != nil {
+		menuError(c, err)
 		return
 	}
 
-	// Delete translations for the menu item before deleting the item
 	go func() {
-		if err := deleteTranslationsForMenuItem(uint(businessID), categoryIndex, itemIndex); err != nil {
+		if err := deleteTranslationsForMenuItem(*item); err != nil {
 			log.Printf("Failed to delete menu item translations: %v", err)
 		}
 	}()
 
-	if err := database.DeleteMenuItem(uint(businessID), categoryIndex, itemIndex); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "Menu item deleted successfully"})
 }
 
-// Bill management handlers
-
-// CreateBillRequest represents the request to create a new bill
-type CreateBillRequest struct {
-	TableID   *uint                `json:"table_id"`
-	CounterID *uint                `json:"counter_id"`
-	Notes     string               `json:"notes"`
-	Items     []database.BillItem  `json:"items"`
-}
-
-// UpdateBillRequest represents the request to update a bill
-type UpdateBillRequest struct {
-	Items []database.BillItem `json:"items"`
+// MenuRequest creates or updates one of a business's menus. Categories are optional
+// when updating; leaving them out keeps the menu's content.
+type MenuRequest struct {
+	Name       string                  `json:"name" binding:"required"`
+	IsActive   *bool                   `json:"is_active"`
+	SortOrder  *int                    `json:"sort_order"`
+	Schedules  []database.MenuSchedule `json:"schedules"`
+	Categories []database.MenuCategory `json:"categories"`
 }
 
-// AddBillItemRequest represents the request to add an item to a bill
-type AddBillItemRequest struct {
-	MenuItemID string                     `json:"menu_item_id" binding:"required"`
-	Name       string                     `json:"name" binding:"required"`
-	Price      float64                    `json:"price" binding:"required"`
-	Quantity   int                        `json:"quantity" binding:"required"`
-	Options    []database.MenuItemOption  `json:"options"`
+// SoldOutRequest marks a menu item as sold out; without an until time it stays sold
+// out until the end of the business day
+type SoldOutRequest struct {
+	Until *time.Time `json:"until"`
 }
 
-// CreateBill creates a new bill for a business
-func CreateBill(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+// ListMenus returns all menus of a business with their schedules
+func ListMenus(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	menus, err := database.GetMenus(businessID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve menus"})
 		return
 	}
 
-	var req CreateBillRequest
+	c.JSON(http.StatusOK, gin.H{"menus": menus})
+}
+
+// AddMenu adds a menu, such as a breakfast or happy hour menu, to a business
+func AddMenu(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+	businessID := business.ID
+
+	var req MenuRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
+	menu := &database.Menu{
+		BusinessID: businessID,
+		Name:       req.Name,
+		IsActive:   req.IsActive == nil || *req.IsActive,
+		Schedules:  req.Schedules,
+	}
+	if err := database.CreateMenu(menu, req.Categories); err != nil {
+		menuError(c, err)
+		return
+	}
+
+	// Auto-translate the new menu's content
+	go func() {
+		for _, category := range menu.Categories {
+			if err := autoTranslateCategory(businessID, category); err != nil {
+				log.Printf("Failed to translate category: %v", err)
+			}
+			for _, item := range category.Items {
+				if err := autoTranslateMenuItem(businessID, item); err != nil {
+					log.Printf("Failed to translate menu item: %v", err)
+				}
+			}
+		}
+	}()
+
+	c.JSON(http.StatusCreated, menu)
+}
+
+// UpdateMenuByID updates the name, state, order and schedules of one of a business's
+// menus, and its content when categories are sent
+func UpdateMenuByID(c *gin.Context) {
+	menuID, err := strconv.ParseUint(c.Param("menu_id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu ID"})
 		return
 	}
 
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to access this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
-	// Validate that either table_id or counter_id is provided, but not both
-	if req.TableID == nil && req.CounterID == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Either table_id or counter_id must be provided"})
+	var req MenuRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if req.TableID != nil && req.CounterID != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot specify both table_id and counter_id"})
+	menu, err := database.GetMenu(businessID, uint(menuID))
+	if err != nil {
+		menuError(c, err)
 		return
 	}
 
-	// Validate table if provided
-	if req.TableID != nil {
-		table, err := database.GetTableByID(*req.TableID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
-			return
-		}
+	menu.Name = req.Name
+	if req.IsActive != nil {
+		menu.IsActive = *req.IsActive
+	}
+	if req.SortOrder != nil {
+		menu.SortOrder = *req.SortOrder
+	}
+	menu.Schedules = req.Schedules
+	if err := database.UpdateMenu(menu, req.Categories); err != nil {
+		menuError(c, err)
+		return
+	}
 
-		if table.BusinessID != uint(businessID) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Table does not belong to this business"})
-			return
+	// Auto-translate the updated content
+	go func() {
+		for _, category := range req.Categories {
+			if err := autoTranslateCategory(businessID, category); err != nil {
+				log.Printf("Failed to translate updated category: %v", err)
+			}
+			for _, item := range category.Items {
+				if err := autoTranslateMenuItem(businessID, item); err != nil {
+					log.Printf("Failed to translate updated menu item: %v", err)
+				}
+			}
 		}
+	}()
 
-		// Check if table already has an open bill
-		existingBill, _, err := database.GetOpenBillByTableID(*req.TableID)
+	c.JSON(http.StatusOK, menu)
+}
+
+// DeleteMenuByID removes one of a business's menus with its categories and items
+func DeleteMenuByID(c *gin.Context) {
+	menuID, err := strconv.ParseUint(c.Param("menu_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu ID"})
+		return
+	}
+
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+	businessID := business.ID
+
+	// Keep the menu's content so its translations can be removed too
+	menu, err := database.GetMenu(businessID, uint(menuID))
+	if err != nil {
+		menuError(c, err)
+		return
+	}
+
+	if err := database.DeleteMenu(businessID, uint(menuID)); err != nil {
+		menuError(c, err)
+		return
+	}
+
+	go func() {
+		for _, category := range menu.Categories {
+			if err := deleteTranslationsForCategory(category); err != nil {
+				log.Printf("Failed to delete category translations: %v", err)
+			}
+		}
+	}()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Menu deleted successfully"})
+}
+
+// PreviewMenu shows the menu a business serves at a given time (RFC 3339 "at" query
+// parameter, now by default) as guests will see it then
+func PreviewMenu(c *gin.Context) {
+	at := time.Now()
+	if value := c.Query("at"); value != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid time, use RFC 3339"})
+			return
+		}
+	}
+
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+	businessID := business.ID
+
+	menu, categories, err := database.GetMenuAt(businessID, at)
+	if err != nil {
+		if errors.Is(err, database.ErrMenuNotFound) {
+			c.JSON(http.StatusOK, gin.H{"at": at, "menu": nil, "categories": []database.MenuCategory{}})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve menu"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"at": at, "menu": menu, "categories": categories})
+}
+
+// MarkMenuItemSoldOut marks a menu item as sold out until a given time or the end of
+// the business day; it comes back on sale by itself afterwards
+func MarkMenuItemSoldOut(c *gin.Context) {
+	setMenuItemSoldOut(c, true)
+}
+
+// ClearMenuItemSoldOut puts a sold out menu item back on sale
+func ClearMenuItemSoldOut(c *gin.Context) {
+	setMenuItemSoldOut(c, false)
+}
+
+// setMenuItemSoldOut marks or clears the sold out state of one of the owner's items
+func setMenuItemSoldOut(c *gin.Context, soldOut bool) {
+	itemID, err := strconv.ParseUint(c.Param("item_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+	businessID := business.ID
+
+	var until *time.Time
+	if soldOut {
+		var req SoldOutRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		until = req.Until
+		if until == nil {
+			endOfDay, err := database.EndOfBusinessDay(businessID, time.Now())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update menu item"})
+				return
+			}
+			until = &endOfDay
+		}
+	}
+
+	item, err := database.SetMenuItemSoldOut(businessID, uint(itemID), until)
+	if err != nil {
+		menuError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"item": item})
+}
+
+// Bill management handlers
+
+// CreateBillRequest represents the request to create a new bill
+type CreateBillRequest struct {
+	TableID   *uint             `json:"table_id"`
+	CounterID *uint             `json:"counter_id"`
+	Notes     string            `json:"notes"`
+	Items     []BillItemRequest `json:"items" binding:"dive"`
+}
+
+// UpdateBillRequest represents the request to update a bill
+type UpdateBillRequest struct {
+	Items []BillItemRequest `json:"items" binding:"dive"`
+}
+
+// BillItemRequest is an item to put on a bill. New items are priced from the menu, while
+// items already on the bill (given by ID) keep the price they were added at.
+type BillItemRequest struct {
+	ID         string                       `json:"id"`
+	MenuItemID uint                         `json:"menu_item_id"`
+	OptionIDs  []uint                       `json:"option_ids"`
+	Modifiers  []database.ModifierSelection `json:"modifiers"`
+	Quantity   int                          `json:"quantity" binding:"required,min=1"`
+}
+
+// AddBillItemRequest represents the request to add an item to a bill
+type AddBillItemRequest struct {
+	MenuItemID uint                         `json:"menu_item_id" binding:"required"`
+	OptionIDs  []uint                       `json:"option_ids"`
+	Modifiers  []database.ModifierSelection `json:"modifiers"`
+	Quantity   int                          `json:"quantity" binding:"required,min=1"`
+}
+
+// resolveBillItems turns requested bill items into bill items. Items already on the bill
+// keep their snapshot and only change quantity; new items are priced from the menu.
+func resolveBillItems(businessID uint, existing []database.BillItem, reqs []BillItemRequest) ([]database.BillItem, error) {
+	onBill := make(map[string]database.BillItem, len(existing))
+	for _, item := range existing {
+		onBill[item.ID] = item
+	}
+
+	items := make([]database.BillItem, len(reqs))
+	var selections []database.MenuSelection
+	var newAt []int
+	for i, itemReq := range reqs {
+		if itemReq.ID != "" {
+			item, ok := onBill[itemReq.ID]
+			if !ok {
+				return nil, fmt.Errorf("%w: item %q is not on the bill", database.ErrInvalidMenuSelection, itemReq.ID)
+			}
+			item.Quantity = itemReq.Quantity
+			item.Subtotal = database.UnitPrice(item.Price, item.Options, item.Modifiers).Mul(item.Quantity)
+			items[i] = item
+			continue
+		}
+		selections = append(selections, database.MenuSelection{
+			MenuItemID: itemReq.MenuItemID,
+			OptionIDs:  itemReq.OptionIDs,
+			Modifiers:  itemReq.Modifiers,
+			Quantity:   itemReq.Quantity,
+		})
+		newAt = append(newAt, i)
+	}
+
+	priced, err := database.PriceBillItems(businessID, selections)
+	if err != nil {
+		return nil, err
+	}
+	for j, i := range newAt {
+		items[i] = priced[j]
+	}
+	return items, nil
+}
+
+// billItemsError responds to a failure to resolve bill items
+func billItemsError(c *gin.Context, err error) {
+	if errors.Is(err, database.ErrInvalidMenuSelection) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price bill items"})
+}
+
+// CreateBill creates a new bill for a business
+func CreateBill(c *gin.Context) {
+	var req CreateBillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+	businessID := business.ID
+
+	// Validate that either table_id or counter_id is provided, but not both
+	if req.TableID == nil && req.CounterID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either table_id or counter_id must be provided"})
+		return
+	}
+
+	if req.TableID != nil && req.CounterID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot specify both table_id and counter_id"})
+		return
+	}
+
+	// Validate table if provided
+	if req.TableID != nil {
+		table, err := database.GetTableByID(*req.TableID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			return
+		}
+
+		if table.BusinessID != businessID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Table does not belong to this business"})
+			return
+		}
+
+		// Check if table already has an open bill
+		existingBill, _, err := database.GetOpenBillByTableID(*req.TableID)
 		if err == nil && existingBill != nil {
 			c.JSON(http.StatusConflict, gin.H{"error": "Table already has an open bill"})
 			return
@@ -1278,7 +1456,7 @@ func CreateBill(c *gin.Context) {
 			return
 		}
 
-		if counter.BusinessID != uint(businessID) {
+		if counter.BusinessID != businessID {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Counter does not belong to this business"})
 			return
 		}
@@ -1289,15 +1467,21 @@ func CreateBill(c *gin.Context) {
 		}
 	}
 
+	// Price the items from the menu
+	items, err := resolveBillItems(businessID, nil, req.Items)
+	if err != nil {
+		billItemsError(c, err)
+		return
+	}
+
 	// Calculate totals
-	subtotal := 0.0
-	for i := range req.Items {
-		req.Items[i].Subtotal = req.Items[i].Price * float64(req.Items[i].Quantity)
-		subtotal += req.Items[i].Subtotal
+	var subtotal money.Amount
+	for _, item := range items {
+		subtotal += item.Subtotal
 	}
 
-	taxAmount := subtotal * business.TaxRate / 100
-	serviceFeeAmount := subtotal * business.ServiceFeeRate / 100
+	taxAmount := subtotal.Percent(business.TaxRate)
+	serviceFeeAmount := subtotal.Percent(business.ServiceFeeRate)
 	totalAmount := subtotal + taxAmount + serviceFeeAmount
 
 	// Generate bill number
@@ -1305,7 +1489,7 @@ func CreateBill(c *gin.Context) {
 
 	// Create bill
 	bill := &database.Bill{
-		BusinessID:       uint(businessID),
+		BusinessID:       businessID,
 		CounterID:        req.CounterID,
 		BillNumber:       billNumber,
 		Notes:            req.Notes,
@@ -1323,45 +1507,26 @@ func CreateBill(c *gin.Context) {
 		bill.TableID = *req.TableID
 	}
 
-	if err := database.CreateBill(bill, req.Items); err != nil {
+	if err := database.CreateBill(bill, items); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-
 	c.JSON(http.StatusCreated, gin.H{
 		"bill":  bill,
-		"items": req.Items,
+		"items": items,
 	})
 }
 
 // GetBusinessBills retrieves all bills for a business
 func GetBusinessBills(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to access this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
-	bills, err := database.GetAllBillsByBusinessID(uint(businessID))
+	bills, err := database.GetAllBillsByBusinessID(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1372,31 +1537,13 @@ func GetBusinessBills(c *gin.Context) {
 
 // GetOpenBusinessBills retrieves only open bills for a business (for table filtering)
 func GetOpenBusinessBills(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to access this business"})
-		return
-	}
-
-	bills, err := database.GetOpenBillsByBusinessID(uint(businessID))
+	bills, err := database.GetOpenBillsByBusinessID(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -1407,12 +1554,6 @@ func GetOpenBusinessBills(c *gin.Context) {
 
 // GetBill retrieves a specific bill by ID
 func GetBill(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
@@ -1425,18 +1566,6 @@ func GetBill(c *gin.Context) {
 		return
 	}
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(bill.BusinessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to access this bill"})
-		return
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"bill":  bill,
 		"items": items,
@@ -1445,12 +1574,6 @@ func GetBill(c *gin.Context) {
 
 // UpdateBill updates an existing bill
 func UpdateBill(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
@@ -1463,38 +1586,36 @@ func UpdateBill(c *gin.Context) {
 		return
 	}
 
-	bill, _, err := database.GetBillByID(uint(billID))
+	bill, existing, err := database.GetBillByID(uint(billID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
 		return
 	}
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(bill.BusinessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
 
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this bill"})
+	if !bill.Status.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot modify a %s bill", bill.Status)})
 		return
 	}
 
-	if bill.Status != database.BillStatusOpen {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot modify closed bill"})
+	items, err := resolveBillItems(bill.BusinessID, existing, req.Items)
+	if err != nil {
+		billItemsError(c, err)
 		return
 	}
 
 	// Recalculate totals
-	subtotal := 0.0
-	for i := range req.Items {
-		req.Items[i].Subtotal = req.Items[i].Price * float64(req.Items[i].Quantity)
-		subtotal += req.Items[i].Subtotal
+	var subtotal money.Amount
+	for _, item := range items {
+		subtotal += item.Subtotal
 	}
 
-	taxAmount := subtotal * business.TaxRate / 100
-	serviceFeeAmount := subtotal * business.ServiceFeeRate / 100
+	taxAmount := subtotal.Percent(business.TaxRate)
+	serviceFeeAmount := subtotal.Percent(business.ServiceFeeRate)
 	totalAmount := subtotal + taxAmount + serviceFeeAmount
 
 	bill.Subtotal = subtotal
@@ -1502,25 +1623,26 @@ func UpdateBill(c *gin.Context) {
 	bill.ServiceFeeAmount = serviceFeeAmount
 	bill.TotalAmount = totalAmount
 
-	if err := database.UpdateBill(bill, req.Items); err != nil {
+	if err := database.UpdateBill(bill, items); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A changed total can settle a partially paid bill or leave a balance again
+	bill, err = database.RecalculateBillPayments(bill.ID, middleware.Actor(c))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"bill":  bill,
-		"items": req.Items,
+		"items": items,
 	})
 }
 
 // AddBillItem adds an item to an existing bill
 func AddBillItem(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
@@ -1539,45 +1661,39 @@ func AddBillItem(c *gin.Context) {
 		return
 	}
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(bill.BusinessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this bill"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
 
-	if bill.Status != database.BillStatusOpen {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot modify closed bill"})
+	if !bill.Status.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot modify a %s bill", bill.Status)})
 		return
 	}
 
-	// Create new bill item
-	newItem := database.BillItem{
-		ID:         fmt.Sprintf("item_%d", time.Now().UnixNano()),
+	// Price the new item from the menu
+	newItems, err := database.PriceBillItems(bill.BusinessID, []database.MenuSelection{{
 		MenuItemID: req.MenuItemID,
-		Name:       req.Name,
-		Price:      req.Price,
+		OptionIDs:  req.OptionIDs,
+		Modifiers:  req.Modifiers,
 		Quantity:   req.Quantity,
-		Options:    req.Options,
-		Subtotal:   req.Price * float64(req.Quantity),
+	}})
+	if err != nil {
+		billItemsError(c, err)
+		return
 	}
 
 	// Add to existing items
-	items = append(items, newItem)
+	items = append(items, newItems...)
 
 	// Recalculate totals
-	subtotal := 0.0
+	var subtotal money.Amount
 	for _, item := range items {
 		subtotal += item.Subtotal
 	}
 
-	taxAmount := subtotal * business.TaxRate / 100
-	serviceFeeAmount := subtotal * business.ServiceFeeRate / 100
+	taxAmount := subtotal.Percent(business.TaxRate)
+	serviceFeeAmount := subtotal.Percent(business.ServiceFeeRate)
 	totalAmount := subtotal + taxAmount + serviceFeeAmount
 
 	bill.Subtotal = subtotal
@@ -1590,6 +1706,13 @@ func AddBillItem(c *gin.Context) {
 		return
 	}
 
+	// A changed total can settle a partially paid bill or leave a balance again
+	bill, err = database.RecalculateBillPayments(bill.ID, middleware.Actor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"bill":  bill,
 		"items": items,
@@ -1598,12 +1721,6 @@ func AddBillItem(c *gin.Context) {
 
 // RemoveBillItem removes an item from a bill
 func RemoveBillItem(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
@@ -1622,20 +1739,13 @@ func RemoveBillItem(c *gin.Context) {
 		return
 	}
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(bill.BusinessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
 
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this bill"})
-		return
-	}
-
-	if bill.Status != database.BillStatusOpen {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot modify closed bill"})
+	if !bill.Status.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot modify a %s bill", bill.Status)})
 		return
 	}
 
@@ -1656,13 +1766,13 @@ func RemoveBillItem(c *gin.Context) {
 	}
 
 	// Recalculate totals
-	subtotal := 0.0
+	var subtotal money.Amount
 	for _, item := range updatedItems {
 		subtotal += item.Subtotal
 	}
 
-	taxAmount := subtotal * business.TaxRate / 100
-	serviceFeeAmount := subtotal * business.ServiceFeeRate / 100
+	taxAmount := subtotal.Percent(business.TaxRate)
+	serviceFeeAmount := subtotal.Percent(business.ServiceFeeRate)
 	totalAmount := subtotal + taxAmount + serviceFeeAmount
 
 	bill.Subtotal = subtotal
@@ -1675,6 +1785,13 @@ func RemoveBillItem(c *gin.Context) {
 		return
 	}
 
+	// A changed total can settle a partially paid bill or leave a balance again
+	bill, err = database.RecalculateBillPayments(bill.ID, middleware.Actor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"bill":  bill,
 		"items": updatedItems,
@@ -1683,57 +1800,100 @@ func RemoveBillItem(c *gin.Context) {
 
 // CloseBill closes a bill
 func CloseBill(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
 		return
 	}
 
-	bill, items, err := database.GetBillByID(uint(billID))
+	_, items, err := database.GetBillByID(uint(billID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
 		return
 	}
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(bill.BusinessID)
+	if err := database.CloseBill(uint(billID), middleware.Actor(c)); err != nil {
+		c.JSON(billTransitionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get updated bill
+	updatedBill, _, err := database.GetBillByID(uint(billID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated bill"})
 		return
 	}
 
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this bill"})
+	c.JSON(http.StatusOK, gin.H{
+		"bill":  updatedBill,
+		"items": items,
+	})
+}
+
+// BillTransitionRequest carries the reason for a staff-initiated bill status change
+type BillTransitionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// VoidBill cancels a bill that has not taken any payment
+func VoidBill(c *gin.Context) {
+	transitionBillStatus(c, database.BillStatusVoided)
+}
+
+// RefundBill marks a bill whose payments have been returned to the guests
+func RefundBill(c *gin.Context) {
+	transitionBillStatus(c, database.BillStatusRefunded)
+}
+
+// transitionBillStatus moves the bill in the path to a new status
+func transitionBillStatus(c *gin.Context, to database.BillStatus) {
+	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
 		return
 	}
 
-	if bill.Status != database.BillStatusOpen {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Bill is already closed"})
+	// The reason is optional
+	var req BillTransitionRequest
+	_ = c.ShouldBindJSON(&req)
+
+	updatedBill, err := database.TransitionBill(uint(billID), to, middleware.Actor(c), req.Reason)
+	if err != nil {
+		c.JSON(billTransitionErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := database.CloseBill(uint(billID)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	c.JSON(http.StatusOK, gin.H{"bill": updatedBill})
+}
+
+// GetBillEvents returns the status history of a bill
+func GetBillEvents(c *gin.Context) {
+	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
 		return
 	}
 
-	// Get updated bill
-	updatedBill, _, err := database.GetBillByID(uint(billID))
+	events, err := database.GetBillEvents(uint(billID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve updated bill"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"bill":  updatedBill,
-		"items": items,
-	})
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// billTransitionErrorStatus maps a bill lifecycle error to an HTTP status
+func billTransitionErrorStatus(err error) int {
+	var invalid *database.InvalidBillTransitionError
+	switch {
+	case errors.As(err, &invalid):
+		return http.StatusConflict
+	case errors.Is(err, database.ErrBillBalanceOutstanding):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 // validateCustomURL checks if a custom URL is available for use
@@ -1805,31 +1965,11 @@ type UpdateCounterSettingsRequest struct {
 
 // UpdateCounterSettings updates counter configuration for a business
 func UpdateCounterSettings(c *gin.Context) {
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req UpdateCounterSettingsRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1838,7 +1978,7 @@ func UpdateCounterSettings(c *gin.Context) {
 	}
 
 	// Update counter settings
-	if err := database.UpdateBusinessCounters(uint(businessID), req.CounterEnabled, req.CounterCount, req.CounterPrefix); err != nil {
+	if err := database.UpdateBusinessCounters(businessID, req.CounterEnabled, req.CounterCount, req.CounterPrefix); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update counter settings"})
 		return
 	}
@@ -1848,34 +1988,14 @@ func UpdateCounterSettings(c *gin.Context) {
 
 // GetBusinessCounters retrieves all counters for a business
 func GetBusinessCounters(c *gin.Context) {
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to access this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Get counters
-	counters, err := database.GetBusinessCounters(uint(businessID))
+	counters, err := database.GetBusinessCounters(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get counters"})
 		return
@@ -1893,31 +2013,11 @@ func GetBusinessCounters(c *gin.Context) {
 
 // GetAvailableCounters retrieves available counters for bill creation
 func GetAvailableCounters(c *gin.Context) {
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Get user address from context
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to access this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Check if counters are enabled
 	if !business.CounterEnabled {
@@ -1926,7 +2026,7 @@ func GetAvailableCounters(c *gin.Context) {
 	}
 
 	// Get available counters
-	counters, err := database.GetAvailableCounters(uint(businessID))
+	counters, err := database.GetAvailableCounters(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get available counters"})
 		return
@@ -1939,20 +2039,14 @@ func GetAvailableCounters(c *gin.Context) {
 
 // MarkPaidRequest represents the request to mark a bill as paid
 type MarkPaidRequest struct {
-	PaymentMethod string  `json:"payment_method" binding:"required,oneof=cash card crypto"`
-	AmountPaid    float64 `json:"amount_paid" binding:"required,min=0"`
-	TipAmount     float64 `json:"tip_amount,omitempty"`
-	Notes         string  `json:"notes,omitempty"`
+	PaymentMethod string       `json:"payment_method" binding:"required,oneof=cash card crypto"`
+	AmountPaid    money.Amount `json:"amount_paid" binding:"required,min=0"`
+	TipAmount     money.Amount `json:"tip_amount,omitempty"`
+	Notes         string       `json:"notes,omitempty"`
 }
 
 // MarkBillAsPaid allows staff to mark a bill as paid
 func MarkBillAsPaid(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
@@ -1972,26 +2066,14 @@ func MarkBillAsPaid(c *gin.Context) {
 		return
 	}
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(bill.BusinessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to access this bill"})
-		return
-	}
-
 	// Check if bill is already paid
-	if bill.Status == database.BillStatusPaid || bill.Status == database.BillStatusClosed {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Bill is already paid or closed"})
+	if !bill.Status.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Bill is already %s", bill.Status)})
 		return
 	}
 
 	// Update bill status and payment details
-	err = database.MarkBillAsPaid(uint(billID), req.AmountPaid, req.TipAmount, req.PaymentMethod, req.Notes)
+	err = database.MarkBillAsPaid(uint(billID), req.AmountPaid, req.TipAmount, req.PaymentMethod, req.Notes, middleware.Actor(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark bill as paid"})
 		return
@@ -2012,12 +2094,6 @@ func MarkBillAsPaid(c *gin.Context) {
 
 // ApproveCashPayment allows staff to approve cash payments
 func ApproveCashPayment(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
@@ -2040,27 +2116,15 @@ func ApproveCashPayment(c *gin.Context) {
 		return
 	}
 
-	// Verify business ownership
-	business, err := database.GetBusinessByID(bill.BusinessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to access this bill"})
-		return
-	}
-
 	// Check if bill is already paid
-	if bill.Status == database.BillStatusPaid || bill.Status == database.BillStatusClosed {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Bill is already paid or closed"})
+	if !bill.Status.IsActive() {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Bill is already %s", bill.Status)})
 		return
 	}
 
 	// Update bill status and payment details with cash approval
 	notes := fmt.Sprintf("Cash payment approved by staff. %s", req.Notes)
-	err = database.MarkBillAsPaid(uint(billID), req.AmountPaid, req.TipAmount, "cash", notes)
+	err = database.MarkBillAsPaid(uint(billID), req.AmountPaid, req.TipAmount, "cash", notes, middleware.Actor(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve cash payment"})
 		return
@@ -2107,32 +2171,14 @@ func GetSupportedCurrencies(c *gin.Context) {
 
 // GetBusinessLanguages returns languages configured for a business
 func GetBusinessLanguages(c *gin.Context) {
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	db := database.GetDBWrapper()
-	languages, err := db.LanguageService.GetBusinessLanguages(uint(businessID))
+	languages, err := db.LanguageService.GetBusinessLanguages(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get business languages"})
 		return
@@ -2143,32 +2189,14 @@ func GetBusinessLanguages(c *gin.Context) {
 
 // GetBusinessCurrencies returns currencies configured for a business
 func GetBusinessCurrencies(c *gin.Context) {
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	db := database.GetDBWrapper()
-	currencies, err := db.CurrencyService.GetBusinessCurrencies(uint(businessID))
+	currencies, err := db.CurrencyService.GetBusinessCurrencies(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get business currencies"})
 		return
@@ -2185,29 +2213,11 @@ type SetBusinessLanguagesRequest struct {
 
 // SetBusinessLanguages sets the languages for a business
 func SetBusinessLanguages(c *gin.Context) {
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req SetBusinessLanguagesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -2229,7 +2239,7 @@ func SetBusinessLanguages(c *gin.Context) {
 	}
 
 	db := database.GetDBWrapper()
-	err = db.LanguageService.SetBusinessLanguages(uint(businessID), req.LanguageCodes, req.DefaultCode)
+	err := db.LanguageService.SetBusinessLanguages(businessID, req.LanguageCodes, req.DefaultCode)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set business languages"})
 		return
@@ -2237,7 +2247,7 @@ func SetBusinessLanguages(c *gin.Context) {
 
 	// Auto-translate existing menu content when languages are updated
 	go func() {
-		if err := translateExistingMenuContent(uint(businessID)); err != nil {
+		if err := translateExistingMenuContent(businessID); err != nil {
 			log.Printf("Failed to translate existing menu content for business %d: %v", businessID, err)
 		}
 	}()
@@ -2251,23 +2261,23 @@ func SetBusinessLanguages(c *gin.Context) {
 func translateExistingMenuContent(businessID uint) error {
 	log.Printf("Starting translation of existing menu content for business %d", businessID)
 
-	// Get the menu for this business
-	_, categories, err := database.GetMenuByBusinessID(businessID)
+	// Get the categories of all of the business's menus
+	categories, err := database.GetAllMenuCategories(businessID)
 	if err != nil {
 		return fmt.Errorf("failed to get menu: %w", err)
 	}
 
 	// Translate all categories and their items
-	for categoryIndex, category := range categories {
+	for _, category := range categories {
 		// Translate category
-		if err := autoTranslateCategory(businessID, categoryIndex, category.Name, category.Description); err != nil {
-			log.Printf("Failed to translate category %d: %v", categoryIndex, err)
+		if err := autoTranslateCategory(businessID, category); err != nil {
+			log.Printf("Failed to translate category %d: %v", category.ID, err)
 		}
 
 		// Translate all items in this category
-		for itemIndex, item := range category.Items {
-			if err := autoTranslateMenuItem(businessID, categoryIndex, itemIndex, item.Name, item.Description, item.Options, item.Allergens, item.DietaryTags); err != nil {
-				log.Printf("Failed to translate menu item %d in category %d: %v", itemIndex, categoryIndex, err)
+		for _, item := range category.Items {
+			if err := autoTranslateMenuItem(businessID, item); err != nil {
+				log.Printf("Failed to translate menu item %d in category %d: %v", item.ID, category.ID, err)
 			}
 		}
 	}
@@ -2284,29 +2294,11 @@ type SetBusinessCurrenciesRequest struct {
 
 // SetBusinessCurrencies sets the currencies for a business
 func SetBusinessCurrencies(c *gin.Context) {
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req SetBusinessCurrenciesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -2328,7 +2320,7 @@ func SetBusinessCurrencies(c *gin.Context) {
 	}
 
 	db := database.GetDBWrapper()
-	err = db.CurrencyService.SetBusinessCurrencies(uint(businessID), req.CurrencyCodes, req.PreferredCode)
+	err := db.CurrencyService.SetBusinessCurrencies(businessID, req.CurrencyCodes, req.PreferredCode)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set business currencies"})
 		return
@@ -2340,7 +2332,8 @@ func SetBusinessCurrencies(c *gin.Context) {
 // Translation helper functions for menu management
 
 // autoTranslateCategory translates a category to all business languages
-func autoTranslateCategory(businessID uint, categoryIndex int, name, description string) error {
+func autoTranslateCategory(businessID uint, category database.MenuCategory) error {
+	name, description := category.Name, category.Description
 	db := database.GetDBWrapper()
 	translationService := GetTranslationService()
 	
@@ -2372,7 +2365,7 @@ func autoTranslateCategory(businessID uint, categoryIndex int, name, description
 			if err == nil && translatedTexts[bl.LanguageCode] != "" {
 				translation := &database.Translation{
 					EntityType:        "category",
-					EntityID:          uint(categoryIndex),
+					EntityID:          category.ID,
 					FieldName:         "name",
 					LanguageCode:      bl.LanguageCode,
 					OriginalText:      name,
@@ -2390,7 +2383,7 @@ func autoTranslateCategory(businessID uint, categoryIndex int, name, description
 			if err == nil && translatedTexts[bl.LanguageCode] != "" {
 				translation := &database.Translation{
 					EntityType:        "category",
-					EntityID:          uint(categoryIndex),
+					EntityID:          category.ID,
 					FieldName:         "description",
 					LanguageCode:      bl.LanguageCode,
 					OriginalText:      description,
@@ -2407,7 +2400,8 @@ func autoTranslateCategory(businessID uint, categoryIndex int, name, description
 }
 
 // autoTranslateMenuItem translates a menu item to all business languages
-func autoTranslateMenuItem(businessID uint, categoryIndex, itemIndex int, name, description string, options []database.MenuItemOption, allergens, dietaryTags []string) error {
+func autoTranslateMenuItem(businessID uint, item database.MenuItem) error {
+	name, description := item.Name, item.Description
 	db := database.GetDBWrapper()
 	translationService := GetTranslationService()
 	
@@ -2426,8 +2420,7 @@ func autoTranslateMenuItem(businessID uint, categoryIndex, itemIndex int, name,
 		return nil
 	}
 
-	// Calculate entity ID using same logic as TranslateMenu
-	entityID := categoryIndex*1000 + itemIndex
+	entityID := item.ID
 
 	// Translate to each business language
 	for _, bl := range businessLanguages {
@@ -2442,7 +2435,7 @@ func autoTranslateMenuItem(businessID uint, categoryIndex, itemIndex int, name,
 			if err == nil && translatedTexts[bl.LanguageCode] != "" {
 				translation := &database.Translation{
 					EntityType:        "menu_item",
-					EntityID:          uint(entityID),
+					EntityID:          entityID,
 					FieldName:         "name",
 					LanguageCode:      bl.LanguageCode,
 					OriginalText:      name,
@@ -2460,7 +2453,7 @@ func autoTranslateMenuItem(businessID uint, categoryIndex, itemIndex int, name,
 			if err == nil && translatedTexts[bl.LanguageCode] != "" {
 				translation := &database.Translation{
 					EntityType:        "menu_item",
-					EntityID:          uint(entityID),
+					EntityID:          entityID,
 					FieldName:         "description",
 					LanguageCode:      bl.LanguageCode,
 					OriginalText:      description,
@@ -2473,14 +2466,13 @@ func autoTranslateMenuItem(businessID uint, categoryIndex, itemIndex int, name,
 		}
 
 		// Translate options
-		for k, option := range options {
+		for _, option := range item.Options {
 			if option.Name != "" {
-				optionEntityID := entityID*1000 + k
 				translatedTexts, err := translationService.TranslateText(option.Name, []string{bl.LanguageCode})
 				if err == nil && translatedTexts[bl.LanguageCode] != "" {
 					translation := &database.Translation{
 						EntityType:        "menu_item_option",
-						EntityID:          uint(optionEntityID),
+						EntityID:          option.ID,
 						FieldName:         "name",
 						LanguageCode:      bl.LanguageCode,
 						OriginalText:      option.Name,
@@ -2494,15 +2486,14 @@ func autoTranslateMenuItem(businessID uint, categoryIndex, itemIndex int, name,
 		}
 
 		// Translate allergens
-		for k, allergen := range allergens {
+		for k, allergen := range item.Allergens {
 			if allergen != "" {
-				allergenEntityID := entityID*10000 + k
 				translatedTexts, err := translationService.TranslateText(allergen, []string{bl.LanguageCode})
 				if err == nil && translatedTexts[bl.LanguageCode] != "" {
 					translation := &database.Translation{
-						EntityType:        "allergen",
-						EntityID:          uint(allergenEntityID),
-						FieldName:         "name",
+						EntityType:        "menu_item",
+						EntityID:          entityID,
+						FieldName:         allergenField(k),
 						LanguageCode:      bl.LanguageCode,
 						OriginalText:      allergen,
 						TranslatedText:    translatedTexts[bl.LanguageCode],
@@ -2515,15 +2506,14 @@ func autoTranslateMenuItem(businessID uint, categoryIndex, itemIndex int, name,
 		}
 
 		// Translate dietary tags
-		for k, tag := range dietaryTags {
+		for k, tag := range item.DietaryTags {
 			if tag != "" {
-				tagEntityID := entityID*100000 + k
 				translatedTexts, err := translationService.TranslateText(tag, []string{bl.LanguageCode})
 				if err == nil && translatedTexts[bl.LanguageCode] != "" {
 					translation := &database.Translation{
-						EntityType:        "dietary_tag",
-						EntityID:          uint(tagEntityID),
-						FieldName:         "name",
+						EntityType:        "menu_item",
+						EntityID:          entityID,
+						FieldName:         dietaryTagField(k),
 						LanguageCode:      bl.LanguageCode,
 						OriginalText:      tag,
 						TranslatedText:    translatedTexts[bl.LanguageCode],
@@ -2539,50 +2529,58 @@ func autoTranslateMenuItem(businessID uint, categoryIndex, itemIndex int, name,
 	return nil
 }
 
-// deleteTranslationsForCategory removes all translations for a category
-func deleteTranslationsForCategory(businessID uint, categoryIndex int) error {
+// deleteTranslationsForCategory removes all translations for a category and its items
+func deleteTranslationsForCategory(category database.MenuCategory) error {
 	db := database.GetDBWrapper()
 	
 	// Delete category name and description translations
-	if err := db.GetGorm().Where("entity_type = ? AND entity_id = ?", "category", categoryIndex).Delete(&database.Translation{}).Error; err != nil {
+	if err := db.GetGorm().Where("entity_type = ? AND entity_id = ?", "category", category.ID).Delete(&database.Translation{}).Error; err != nil {
 		return fmt.Errorf("failed to delete category translations: %v", err)
 	}
 
-	log.Printf("🗑️ Deleted translations for category %d", categoryIndex)
+	for _, item := range category.Items {
+		if err := deleteTranslationsForMenuItem(item); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("🗑️ Deleted translations for category %d", category.ID)
 	return nil
 }
 
-// deleteTranslationsForMenuItem removes all translations for a menu item
-func deleteTranslationsForMenuItem(businessID uint, categoryIndex, itemIndex int) error {
+// deleteTranslationsForMenuItem removes all translations for a menu item and its options
+func deleteTranslationsForMenuItem(item database.MenuItem) error {
 	db := database.GetDBWrapper()
-	
-	// Calculate entity ID using same logic as TranslateMenu
-	entityID := categoryIndex*1000 + itemIndex
 
-	// Delete menu item name and description translations
-	if err := db.GetGorm().Where("entity_type = ? AND entity_id = ?", "menu_item", entityID).Delete(&database.Translation{}).Error; err != nil {
+	// Delete menu item name, description, allergen and dietary tag translations
+	if err := db.GetGorm().Where("entity_type = ? AND entity_id = ?", "menu_item", item.ID).Delete(&database.Translation{}).Error; err != nil {
 		return fmt.Errorf("failed to delete menu item translations: %v", err)
 	}
 
-	// Delete option translations (entity_id starts with entityID*1000)
-	if err := db.GetGorm().Where("entity_type = ? AND entity_id >= ? AND entity_id < ?", "menu_item_option", entityID*1000, (entityID+1)*1000).Delete(&database.Translation{}).Error; err != nil {
-		return fmt.Errorf("failed to delete option translations: %v", err)
+	optionIDs := make([]uint, len(item.Options))
+	for i, option := range item.Options {
+		optionIDs[i] = option.ID
 	}
-
-	// Delete allergen translations (entity_id starts with entityID*10000)
-	if err := db.GetGorm().Where("entity_type = ? AND entity_id >= ? AND entity_id < ?", "allergen", entityID*10000, (entityID+1)*10000).Delete(&database.Translation{}).Error; err != nil {
-		return fmt.Errorf("failed to delete allergen translations: %v", err)
-	}
-
-	// Delete dietary tag translations (entity_id starts with entityID*100000)
-	if err := db.GetGorm().Where("entity_type = ? AND entity_id >= ? AND entity_id < ?", "dietary_tag", entityID*100000, (entityID+1)*100000).Delete(&database.Translation{}).Error; err != nil {
-		return fmt.Errorf("failed to delete dietary tag translations: %v", err)
+	if len(optionIDs) > 0 {
+		if err := db.GetGorm().Where("entity_type = ? AND entity_id IN ?", "menu_item_option", optionIDs).Delete(&database.Translation{}).Error; err != nil {
+			return fmt.Errorf("failed to delete option translations: %v", err)
+		}
 	}
 
-	log.Printf("🗑️ Deleted translations for menu item %d in category %d", itemIndex, categoryIndex)
+	log.Printf("🗑️ Deleted translations for menu item %d", item.ID)
 	return nil
 }
 
+// allergenField is the translation field of a menu item's k-th allergen
+func allergenField(k int) string {
+	return fmt.Sprintf("allergen_%d", k)
+}
+
+// dietaryTagField is the translation field of a menu item's k-th dietary tag
+func dietaryTagField(k int) string {
+	return fmt.Sprintf("dietary_tag_%d", k)
+}
+
 // Google Places API integration
 
 // GoogleBusinessSearchRequest represents a request to search for Google businesses
@@ -2627,34 +2625,14 @@ func SearchGoogleBusinesses(c *gin.Context) {
 
 // UpdateBusinessGoogleInfo updates the Google business information for a business
 func UpdateBusinessGoogleInfo(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
 	var req GoogleBusinessUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Get the business and verify ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
 
@@ -2706,28 +2684,8 @@ func UpdateBusinessGoogleInfo(c *gin.Context) {
 
 // RemoveBusinessGoogleInfo removes Google business integration from a business
 func RemoveBusinessGoogleInfo(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Get the business and verify ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
 
@@ -2752,32 +2710,11 @@ func RemoveBusinessGoogleInfo(c *gin.Context) {
 
 // SyncBusinessSubscriptionData syncs subscription data from smart contract
 func SyncBusinessSubscriptionData(c *gin.Context) {
-	// Get business ID from URL
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.Atoi(businessIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Get user address from context (set by auth middleware)
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Get the business and verify ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Parse request body
 	var req SyncSubscriptionRequest
@@ -2798,7 +2735,7 @@ func SyncBusinessSubscriptionData(c *gin.Context) {
 	}
 
 	// Update subscription data in database
-	if err := database.UpdateBusinessSubscriptionData(uint(businessID), subscriptionData); err != nil {
+	if err := database.UpdateBusinessSubscriptionData(businessID, subscriptionData); err != nil {
 		log.Printf("Error updating business subscription data: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subscription data"})
 		return
@@ -2811,32 +2748,11 @@ func SyncBusinessSubscriptionData(c *gin.Context) {
 
 // RecordSubscriptionRenewal records a new subscription renewal payment
 func RecordSubscriptionRenewal(c *gin.Context) {
-	// Get business ID from URL
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.Atoi(businessIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Get user address from context (set by auth middleware)
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Get the business and verify ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Parse request body
 	type RenewalRequest struct {
@@ -2854,7 +2770,7 @@ func RecordSubscriptionRenewal(c *gin.Context) {
 
 	// Create subscription payment record
 	payment := &database.SubscriptionPayment{
-		BusinessID:      uint(businessID),
+		BusinessID:      businessID,
 		PaymentAmount:   req.PaymentAmount,
 		TransactionHash: req.TransactionHash,
 		BlockNumber:     req.BlockNumber,
@@ -2878,7 +2794,7 @@ func RecordSubscriptionRenewal(c *gin.Context) {
 		"last_payment_amount":   req.PaymentAmount,
 	}
 
-	if err := database.UpdateBusinessSubscriptionData(uint(businessID), subscriptionData); err != nil {
+	if err := database.UpdateBusinessSubscriptionData(businessID, subscriptionData); err != nil {
 		log.Printf("Error updating business subscription data: %v", err)
 		// Don't fail if business update fails, payment record is more important
 	}
@@ -2891,35 +2807,14 @@ func RecordSubscriptionRenewal(c *gin.Context) {
 
 // GetSubscriptionPaymentHistory gets payment history for a business subscription
 func GetSubscriptionPaymentHistory(c *gin.Context) {
-	// Get business ID from URL
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.Atoi(businessIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Get user address from context (set by auth middleware)
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User address not found"})
-		return
-	}
-
-	// Get the business and verify ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Get subscription payment history
-	payments, err := database.GetSubscriptionPaymentsByBusinessID(uint(businessID))
+	payments, err := database.GetSubscriptionPaymentsByBusinessID(businessID)
 	if err != nil {
 		log.Printf("Error getting subscription payment history: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get payment history"})
@@ -2927,12 +2822,12 @@ func GetSubscriptionPaymentHistory(c *gin.Context) {
 	}
 
 	// Get latest payment and total
-	latestPayment, err := database.GetLatestSubscriptionPayment(uint(businessID))
+	latestPayment, err := database.GetLatestSubscriptionPayment(businessID)
 	if err != nil {
 		log.Printf("Error getting latest subscription payment: %v", err)
 	}
 
-	totalPaid, err := database.GetTotalSubscriptionPayments(uint(businessID))
+	totalPaid, err := database.GetTotalSubscriptionPayments(businessID)
 	if err != nil {
 		log.Printf("Error getting total subscription payments: %v", err)
 		totalPaid = "0"
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"payverge/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateFloorPlanRequest positions some or all of a business's tables
+type UpdateFloorPlanRequest struct {
+	Tables []database.TableLayout `json:"tables" binding:"required,dive"`
+}
+
+// GetFloorPlan returns the status board: every active table with its floor plan layout
+// and live status. Updates are pushed to the business WebSocket room as table_status
+// messages.
+func GetFloorPlan(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+	respondTableBoard(c, business.ID)
+}
+
+// UpdateFloorPlan saves table sections, positions, seats and shapes
+func UpdateFloorPlan(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateFloorPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.UpdateFloorPlan(business.ID, req.Tables); err != nil {
+		switch {
+		case errors.Is(err, database.ErrInvalidTableLayout):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case strings.Contains(err.Error(), "not found"):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update floor plan"})
+		}
+		return
+	}
+
+	respondTableBoard(c, business.ID)
+}
+
+// SeatTable opens a bill at a free table for guests the host has just seated
+func SeatTable(c *gin.Context) {
+	business, tableID, ok := floorPlanTable(c)
+	if !ok {
+		return
+	}
+
+	bill, err := database.SeatTable(business.ID, tableID)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrTableHasOpenBill):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case strings.Contains(err.Error(), "not found"):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to seat table"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"bill": bill})
+}
+
+// MarkTableClean marks a cleared table ready for new guests
+func MarkTableClean(c *gin.Context) {
+	business, tableID, ok := floorPlanTable(c)
+	if !ok {
+		return
+	}
+
+	if err := database.MarkTableClean(business.ID, tableID); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark table clean"})
+		return
+	}
+
+	respondTableBoard(c, business.ID)
+}
+
+// floorPlanTable checks the caller owns the business in the path and parses the table ID
+func floorPlanTable(c *gin.Context) (*database.Business, uint, bool) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return nil, 0, false
+	}
+
+	tableID, err := strconv.ParseUint(c.Param("tableId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid table ID"})
+		return nil, 0, false
+	}
+	return business, uint(tableID), true
+}
+
+func respondTableBoard(c *gin.Context, businessID uint) {
+	board, err := database.GetTableBoard(businessID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get table status"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tables": board})
+}
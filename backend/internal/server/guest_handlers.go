@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -31,13 +32,13 @@ func GetTableByCodePublic(c *gin.Context) {
 		return
 	}
 
-	// Get menu for the business
-	menu, categories, err := database.GetMenuByBusinessID(table.BusinessID)
+	// Get the menu the business is serving right now
+	menu, categories, err := database.GetMenuAt(table.BusinessID, time.Now())
 	if err != nil {
 		// Menu might not exist yet, return empty menu
 		menu = &database.Menu{
 			BusinessID: table.BusinessID,
-			Categories: "[]",
+			Categories: []database.MenuCategory{},
 		}
 		categories = []database.MenuCategory{}
 	}
@@ -136,6 +137,10 @@ func CreateBillByTableCode(c *gin.Context) {
 		return
 	}
 
+	if _, ok := requireTableSession(c, table); !ok {
+		return
+	}
+
 	// Check if there's already an open bill for this table
 	_, _, err = database.GetOpenBillByTableID(table.ID)
 	if err == nil {
@@ -195,12 +200,12 @@ func GetMenuByTableCode(c *gin.Context) {
 		return
 	}
 
-	menu, categories, err := database.GetMenuByBusinessID(table.BusinessID)
+	menu, categories, err := database.GetMenuAt(table.BusinessID, time.Now())
 	if err != nil {
 		// Menu might not exist yet, return empty menu
 		menu = &database.Menu{
 			BusinessID: table.BusinessID,
-			Categories: "[]",
+			Categories: []database.MenuCategory{},
 		}
 		categories = []database.MenuCategory{}
 	}
@@ -295,16 +300,21 @@ func CreateGuestOrder(c *gin.Context) {
 	
 	fmt.Printf("Found table: ID=%d, BusinessID=%d, Name=%s\n", table.ID, table.BusinessID, table.Name)
 
+	session, ok := requireTableSession(c, table)
+	if !ok {
+		return
+	}
+
 	// Parse request body
 	var req struct {
 		BillID uint `json:"bill_id" binding:"required"`
 		Items  []struct {
-			MenuItemName    string                      `json:"menu_item_name" binding:"required"`
-			Quantity        int                         `json:"quantity" binding:"required"`
-			Price           float64                     `json:"price" binding:"required"`
-			Options         []database.MenuItemOption  `json:"options"`         // Add-ons/modifiers
-			SpecialRequests string                      `json:"special_requests"`
-		} `json:"items" binding:"required"`
+			MenuItemID      uint                         `json:"menu_item_id" binding:"required"`
+			OptionIDs       []uint                       `json:"option_ids"` // Selected add-ons
+			Modifiers       []database.ModifierSelection `json:"modifiers"`  // Options chosen per modifier group
+			Quantity        int                          `json:"quantity" binding:"required,min=1"`
+			SpecialRequests string                       `json:"special_requests"`
+		} `json:"items" binding:"required,min=1,dive"`
 		Notes string `json:"notes"`
 	}
 
@@ -316,6 +326,12 @@ func CreateGuestOrder(c *gin.Context) {
 	
 	fmt.Printf("Parsed request: BillID=%d, Items=%+v, Notes=%s\n", req.BillID, req.Items, req.Notes)
 
+	// A session only orders on the bill it joined
+	if session.BillID == nil || *session.BillID != req.BillID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "This table session does not belong to the bill"})
+		return
+	}
+
 	db := database.GetDB()
 	if db == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database connection failed"})
@@ -345,25 +361,25 @@ func CreateGuestOrder(c *gin.Context) {
 		UpdatedAt:   time.Now(),
 	}
 
-	// Convert request items to order items
-	var orderItems []database.OrderItem
-	for _, itemReq := range req.Items {
-		// Calculate subtotal including options
-		subtotal := itemReq.Price * float64(itemReq.Quantity)
-		for _, option := range itemReq.Options {
-			subtotal += option.PriceChange * float64(itemReq.Quantity)
-		}
-
-		orderItem := database.OrderItem{
-			ID:              fmt.Sprintf("%s-%d", itemReq.MenuItemName, time.Now().UnixNano()),
-			MenuItemName:    itemReq.MenuItemName,
+	// Price the items from the menu; guests cannot set their own prices
+	selections := make([]database.MenuSelection, len(req.Items))
+	for i, itemReq := range req.Items {
+		selections[i] = database.MenuSelection{
+			MenuItemID:      itemReq.MenuItemID,
+			OptionIDs:       itemReq.OptionIDs,
+			Modifiers:       itemReq.Modifiers,
 			Quantity:        itemReq.Quantity,
-			Price:           itemReq.Price,
-			Options:         itemReq.Options,
 			SpecialRequests: itemReq.SpecialRequests,
-			Subtotal:        subtotal,
 		}
-		orderItems = append(orderItems, orderItem)
+	}
+	orderItems, err := database.PriceOrderItems(table.BusinessID, selections)
+	if err != nil {
+		if errors.Is(err, database.ErrInvalidMenuSelection) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to price order items"})
+		return
 	}
 
 	// Create order with items
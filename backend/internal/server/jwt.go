@@ -1,34 +1,83 @@
 package server
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"time"
+
 	"github.com/golang-jwt/jwt/v4"
+	"payverge/internal/database"
 	"payverge/internal/structs"
-	"time"
 )
 
-// GenerateToken generates a JWT token with the user ID as part of the claims
-func GenerateToken(address string, role structs.Role) (string, error) {
-	// Create the claims
+// AccessTokenLifetime is how long an access token is accepted. Clients trade their
+// refresh token for a new one before then; a revoked session gets no new ones.
+const AccessTokenLifetime = 15 * time.Minute
+
+// errUnknownSigningKey is returned for a token signed with a key the server no longer has
+var errUnknownSigningKey = errors.New("unknown signing key")
+
+// keyID names a signing key in the kid header without revealing it
+func keyID(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenerateAccessToken generates a short-lived JWT for a signed-in session. It carries
+// the session ID so that revoking the session rejects the token before it expires.
+func GenerateAccessToken(session *database.AuthSession, role string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"address": address,
-		"role":    role,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(),
+		"sid":  session.ID,
+		"jti":  hex.EncodeToString(jti),
+		"role": role,
+		"iat":  now.Unix(),
+		"exp":  now.Add(AccessTokenLifetime).Unix(),
+	}
+	if session.StaffID != nil {
+		// What a staff token allows is decided by the staff member's current role,
+		// not the role it was issued with
+		claims["staff_id"] = *session.StaffID
+		claims["business_id"] = session.BusinessID
+	} else {
+		claims["address"] = session.Address
 	}
 
-	// Create the token
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign the token
+	token.Header["kid"] = keyID(structs.SecretKey)
 	return token.SignedString(structs.SecretKey)
 }
 
+// verificationKey returns the key a token names in its kid header: the current key or
+// one it was rolled from
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == keyID(structs.SecretKey) {
+		return structs.SecretKey, nil
+	}
+	for _, key := range structs.PreviousSecretKeys {
+		if kid == keyID(key) {
+			return key, nil
+		}
+	}
+	return nil, errUnknownSigningKey
+}
+
 // VerifyToken verifies a token JWT validate
 func VerifyToken(tokenString string) (jwt.MapClaims, error) {
 	// Parse the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return structs.SecretKey, nil
-	})
+	token, err := jwt.Parse(tokenString, verificationKey)
 
 	// Check if there was an error parsing the token
 	if err != nil {
@@ -44,3 +93,21 @@ func VerifyToken(tokenString string) (jwt.MapClaims, error) {
 	// Return the claims
 	return token.Claims.(jwt.MapClaims), nil
 }
+
+// VerifySessionToken verifies a token and that the session it was issued for is still
+// signed in
+func VerifySessionToken(tokenString string) (jwt.MapClaims, *database.AuthSession, error) {
+	claims, err := VerifyToken(tokenString)
+	if err != nil {
+		return nil, nil, err
+	}
+	sessionID, ok := claims["sid"].(float64)
+	if !ok || sessionID <= 0 {
+		return nil, nil, database.ErrAuthSessionInvalid
+	}
+	session, err := database.GetActiveAuthSession(uint(sessionID))
+	if err != nil {
+		return nil, nil, err
+	}
+	return claims, session, nil
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"payverge/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxMenuImportSize limits the size of an imported menu file
+const maxMenuImportSize = 5 << 20
+
+// menuTransferBusiness returns the business the caller may access and reads the
+// optional menu_id query parameter, 0 meaning the main menu
+func menuTransferBusiness(c *gin.Context) (uint, uint, bool) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return 0, 0, false
+	}
+
+	var menuID uint64
+	if value := c.Query("menu_id"); value != "" {
+		var err error
+		if menuID, err = strconv.ParseUint(value, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid menu ID"})
+			return 0, 0, false
+		}
+	}
+
+	return business.ID, uint(menuID), true
+}
+
+// menuFormat returns the requested menu file format, "json" unless CSV is asked for by
+// the format query parameter or the content type
+func menuFormat(c *gin.Context) (string, bool) {
+	format := strings.ToLower(c.Query("format"))
+	if format == "" && strings.Contains(c.ContentType(), "csv") {
+		format = "csv"
+	}
+	switch format {
+	case "", "json":
+		return "json", true
+	case "csv":
+		return "csv", true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format, use csv or json"})
+	return "", false
+}
+
+// ExportMenuFile downloads a whole menu as CSV or full-fidelity JSON
+func ExportMenuFile(c *gin.Context) {
+	businessID, menuID, ok := menuTransferBusiness(c)
+	if !ok {
+		return
+	}
+	format, ok := menuFormat(c)
+	if !ok {
+		return
+	}
+
+	export, err := database.ExportMenu(businessID, menuID)
+	if err != nil {
+		menuError(c, err)
+		return
+	}
+
+	var body bytes.Buffer
+	contentType := "application/json"
+	if format == "csv" {
+		contentType = "text/csv"
+		err = database.WriteMenuCSV(&body, export.Categories)
+	} else {
+		encoder := json.NewEncoder(&body)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(export)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export menu"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=menu_%d.%s", businessID, format))
+	c.Data(http.StatusOK, contentType, body.Bytes())
+}
+
+// ImportMenuFile replaces a whole menu with an uploaded CSV or JSON file, sent as the
+// request body or as the "file" form field. With dry_run=true it only returns the
+// changes the import would make.
+func ImportMenuFile(c *gin.Context) {
+	businessID, menuID, ok := menuTransferBusiness(c)
+	if !ok {
+		return
+	}
+	format, ok := menuFormat(c)
+	if !ok {
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	var body io.Reader = http.MaxBytesReader(c.Writer, c.Request.Body, maxMenuImportSize)
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
+			return
+		}
+		if header.Size > maxMenuImportSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Menu file is too large"})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read menu file"})
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	imported := &database.MenuExport{}
+	partial := format == "csv"
+	var err error
+	if partial {
+		imported.Categories, err = database.ReadMenuCSV(body)
+	} else if err = json.NewDecoder(body).Decode(imported); err != nil {
+		err = fmt.Errorf("%w: %v", database.ErrInvalidMenuImport, err)
+	}
+	if err != nil {
+		menuError(c, err)
+		return
+	}
+
+	result, err := database.ImportMenu(businessID, menuID, imported, partial, dryRun)
+	if err != nil {
+		menuError(c, err)
+		return
+	}
+
+	// Only new and changed text needs translating
+	if !dryRun {
+		go func() {
+			for _, category := range result.NewText {
+				if category.Name != "" || category.Description != "" {
+					if err := autoTranslateCategory(businessID, category); err != nil {
+						log.Printf("Failed to translate imported category: %v", err)
+					}
+				}
+				for _, item := range category.Items {
+					if err := autoTranslateMenuItem(businessID, item); err != nil {
+						log.Printf("Failed to translate imported menu item: %v", err)
+					}
+				}
+			}
+		}()
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -1,72 +1,90 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"payverge/internal/database"
 	"payverge/internal/metrics"
+	"payverge/internal/middleware"
 	"net/http"
 	"strings"
 )
 
-// AuthenticationMiddleware checks if the user has a valid JWT token
-func AuthenticationMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authentication token"})
-			c.Abort()
-			return
-		}
+// bearerToken returns the token of an Authorization header. Some clients send it quoted.
+func bearerToken(header string) (string, bool) {
+	parts := strings.Split(header, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return "", false
+	}
+	token := strings.Trim(parts[1], `"`)
+	return token, token != ""
+}
 
-		// The token should be prefixed with "Bearer "
-		tokenParts := strings.Split(tokenString, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+// authenticate verifies the request's access token and that its session has not been
+// revoked, aborting the request otherwise
+func authenticate(c *gin.Context) (jwt.MapClaims, bool) {
+	tokenString := c.GetHeader("Authorization")
+	if tokenString == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authentication token"})
+		c.Abort()
+		return nil, false
+	}
+
+	// The token should be prefixed with "Bearer "
+	tokenString, ok := bearerToken(tokenString)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token"})
+		c.Abort()
+		return nil, false
+	}
+
+	claims, session, err := VerifySessionToken(tokenString)
+	if err != nil {
+		if errors.Is(err, database.ErrAuthSessionInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has ended, sign in again"})
+		} else {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token"})
-			c.Abort()
-			return
 		}
+		c.Abort()
+		return nil, false
+	}
 
-		tokenString = tokenParts[1]
-		// Remove the \"\" from the token string
-		tokenString = tokenString[1 : len(tokenString)-1]
-		claims, err := VerifyToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token"})
-			c.Abort()
+	c.Set("session_id", session.ID)
+	return claims, true
+}
+
+// AuthenticationMiddleware checks if the user has a valid JWT token
+func AuthenticationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := authenticate(c)
+		if !ok {
 			return
 		}
 
 		c.Set("user_id", claims["user_id"])
-		c.Set("address", claims["address"])
+		setCaller(c, claims)
 		c.Next()
 	}
 }
 
+// setCaller stores who the token belongs to: a wallet address for owners and users,
+// or a staff ID for staff, so that staff tokens never pass as an address
+func setCaller(c *gin.Context, claims jwt.MapClaims) {
+	if address, ok := claims["address"].(string); ok && address != "" {
+		c.Set("address", address)
+	}
+	if staffID, ok := claims["staff_id"].(float64); ok && staffID > 0 {
+		c.Set("staff_id", uint(staffID))
+	}
+}
+
 // AuthenticationAdminMiddleware checks if the user has a valid JWT token and if is an admin
 func AuthenticationAdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization")
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authentication token"})
-			c.Abort()
-			return
-		}
-
-		// The token should be prefixed with "Bearer "
-		tokenParts := strings.Split(tokenString, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token"})
-			c.Abort()
-			return
-		}
-
-		tokenString = tokenParts[1]
-		// Remove the \"\" from the token string
-		tokenString = tokenString[1 : len(tokenString)-1]
-		claims, err := VerifyToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authentication token"})
-			c.Abort()
+		claims, ok := authenticate(c)
+		if !ok {
 			return
 		}
 
@@ -89,3 +107,14 @@ func PrometheusMiddleware() gin.HandlerFunc {
 		metrics.TotalRequests.WithLabelValues(c.FullPath(), c.Request.Method, status).Inc()
 	}
 }
+
+// authorizedBusiness returns the business that middleware.RequirePermission authorized
+// the request for. Handlers reached without it are refused.
+func authorizedBusiness(c *gin.Context) (*database.Business, bool) {
+	access, ok := middleware.Access(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		return nil, false
+	}
+	return access.Business, true
+}
@@ -0,0 +1,334 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"payverge/internal/database"
+	"payverge/internal/qr"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxQRLogoSize limits the size of a business logo downloaded into QR codes
+const maxQRLogoSize = 2 << 20
+
+// qrTentCaption is printed under every code on the table tents
+const qrTentCaption = "Scan to view the menu and pay"
+
+var qrLogoClient = &http.Client{Timeout: 5 * time.Second}
+
+// defaultQRBaseURL is the guest frontend URL codes point to, from QR_BASE_URL or else
+// BASE_URL
+func defaultQRBaseURL() string {
+	for _, name := range []string{"QR_BASE_URL", "BASE_URL"} {
+		if value := os.Getenv(name); value != "" {
+			return strings.TrimRight(value, "/")
+		}
+	}
+	return "http://localhost:3000"
+}
+
+// qrBaseURL returns the base URL asked for by the base_url query parameter, or the
+// default one, and whether it is the default
+func qrBaseURL(c *gin.Context) (string, bool, bool) {
+	value := c.Query("base_url")
+	if value == "" {
+		return defaultQRBaseURL(), true, true
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid base URL, use an absolute http or https URL"})
+		return "", false, false
+	}
+	return strings.TrimRight(value, "/"), false, true
+}
+
+// tableGuestURL is the URL a table's QR code opens
+func tableGuestURL(baseURL, code string) string {
+	return baseURL + "/t/" + code
+}
+
+// counterGuestURL is the URL a counter's QR code opens
+func counterGuestURL(baseURL, code string) string {
+	return baseURL + "/c/" + code
+}
+
+// qrLogo downloads the business logo when the logo query parameter asks for it. Codes
+// are still rendered, without the logo, when it cannot be fetched.
+func qrLogo(c *gin.Context, business *database.Business) image.Image {
+	if want, _ := strconv.ParseBool(c.Query("logo")); !want || business.Logo == "" {
+		return nil
+	}
+
+	resp, err := qrLogoClient.Get(business.Logo)
+	if err != nil {
+		log.Printf("Failed to fetch logo of business %d: %v", business.ID, err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Failed to fetch logo of business %d: status %d", business.ID, resp.StatusCode)
+		return nil
+	}
+
+	logo, _, err := image.Decode(io.LimitReader(resp.Body, maxQRLogoSize))
+	if err != nil {
+		log.Printf("Failed to decode logo of business %d: %v", business.ID, err)
+		return nil
+	}
+	return logo
+}
+
+// writeQRCode renders content in the requested format (png or svg) and size
+func writeQRCode(c *gin.Context, business *database.Business, content, filename string) {
+	size := qr.DefaultSize
+	if value := c.Query("size"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size"})
+			return
+		}
+		size = parsed
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "png"))
+	var render func(string, int, image.Image) ([]byte, error)
+	var contentType string
+	switch format {
+	case "png":
+		render, contentType = qr.PNG, "image/png"
+	case "svg":
+		render, contentType = qr.SVG, "image/svg+xml"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unsupported format, use png or svg"})
+		return
+	}
+
+	code, err := render(content, size, qrLogo(c, business))
+	if err != nil {
+		if errors.Is(err, qr.ErrInvalidSize) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render QR code"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%s.%s", filename, format))
+	c.Data(http.StatusOK, contentType, code)
+}
+
+// GetTableQRCode renders the QR code of a table as PNG or SVG
+func GetTableQRCode(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+	baseURL, isDefault, ok := qrBaseURL(c)
+	if !ok {
+		return
+	}
+
+	tableID, err := strconv.ParseUint(c.Param("tableId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid table ID"})
+		return
+	}
+	table, err := database.GetTableByID(uint(tableID))
+	if err != nil || table.BusinessID != business.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+		return
+	}
+
+	// Keep the stored URL in step with the configured base URL
+	content := tableGuestURL(baseURL, table.TableCode)
+	if isDefault && table.QRCode != content {
+		if err := database.SetTableQRCode(table.ID, content); err != nil {
+			log.Printf("Failed to store QR code of table %d: %v", table.ID, err)
+		}
+	}
+
+	writeQRCode(c, business, content, fmt.Sprintf("table_%d", table.ID))
+}
+
+// GetCounterQRCode renders the QR code of a counter as PNG or SVG
+func GetCounterQRCode(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+	baseURL, isDefault, ok := qrBaseURL(c)
+	if !ok {
+		return
+	}
+
+	counterID, err := strconv.ParseUint(c.Param("counterId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid counter ID"})
+		return
+	}
+	counter, err := database.GetCounterByID(uint(counterID))
+	if err != nil || counter.BusinessID != business.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Counter not found"})
+		return
+	}
+
+	content := counterGuestURL(baseURL, counter.CounterCode)
+	if isDefault && counter.QRCode != content {
+		if err := database.SetCounterQRCode(counter.ID, content); err != nil {
+			log.Printf("Failed to store QR code of counter %d: %v", counter.ID, err)
+		}
+	}
+
+	writeQRCode(c, business, content, fmt.Sprintf("counter_%d", counter.ID))
+}
+
+// GetQRCodeSheet renders a printable PDF with a table tent for every active table and
+// counter of the business
+func GetQRCodeSheet(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+	baseURL, _, ok := qrBaseURL(c)
+	if !ok {
+		return
+	}
+
+	tables, err := database.GetTablesByBusinessID(business.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tables"})
+		return
+	}
+	counters, err := database.GetBusinessCounters(business.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get counters"})
+		return
+	}
+
+	tents := make([]qr.Tent, 0, len(tables)+len(counters))
+	for _, table := range tables {
+		tents = append(tents, qr.Tent{Name: table.Name, URL: tableGuestURL(baseURL, table.TableCode)})
+	}
+	for _, counter := range counters {
+		if counter.IsActive && counter.CounterCode != "" {
+			tents = append(tents, qr.Tent{Name: counter.Name, URL: counterGuestURL(baseURL, counter.CounterCode)})
+		}
+	}
+	if len(tents) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The business has no tables or counters"})
+		return
+	}
+
+	caption := c.DefaultQuery("caption", qrTentCaption)
+	sheet, err := qr.TentSheet(business.Name, caption, tents, qrLogo(c, business))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render table tents"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=table_tents_%d.pdf", business.ID))
+	c.Data(http.StatusOK, "application/pdf", sheet)
+}
+
+// RotateTableCode gives a table a new code, so its old QR code stops working. The
+// new code has to be printed again. Guests already at the table are signed out too,
+// unless keep_sessions=true.
+func RotateTableCode(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+
+	tableID, err := strconv.ParseUint(c.Param("tableId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid table ID"})
+		return
+	}
+
+	keepSessions, _ := strconv.ParseBool(c.Query("keep_sessions"))
+
+	baseURL := defaultQRBaseURL()
+	table, err := database.RotateTableCode(business.ID, uint(tableID), func(code string) string {
+		return tableGuestURL(baseURL, code)
+	}, !keepSessions)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate table code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, table)
+}
+
+// RotateCounterCode gives a counter a new code, so its old QR code stops working
+func RotateCounterCode(c *gin.Context) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return
+	}
+
+	counterID, err := strconv.ParseUint(c.Param("counterId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid counter ID"})
+		return
+	}
+
+	baseURL := defaultQRBaseURL()
+	counter, err := database.RotateCounterCode(business.ID, uint(counterID), func(code string) string {
+		return counterGuestURL(baseURL, code)
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Counter not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate counter code"})
+		return
+	}
+
+	c.JSON(http.StatusOK, counter)
+}
+
+// GetCounterByCodePublic resolves a counter QR code to the counter, its business and
+// the bill currently open at it
+func GetCounterByCodePublic(c *gin.Context) {
+	counter, err := database.GetCounterByCode(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Counter not found"})
+		return
+	}
+
+	business, err := database.GetBusinessByID(counter.BusinessID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
+		return
+	}
+
+	response := gin.H{
+		"counter":  counter,
+		"business": business,
+	}
+	if counter.CurrentBillID != nil {
+		if bill, _, err := database.GetBillByID(*counter.CurrentBillID); err == nil {
+			response["bill"] = bill
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"payverge/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionOwner returns the address or staff member the caller signed in as
+func sessionOwner(c *gin.Context) (string, uint, bool) {
+	address := c.GetString("address")
+	staffID := c.GetUint("staff_id")
+	if address == "" && staffID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return "", 0, false
+	}
+	return address, staffID, true
+}
+
+// GetAuthSessions lists the devices the caller is signed in on
+func GetAuthSessions(c *gin.Context) {
+	address, staffID, ok := sessionOwner(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := database.GetAuthSessions(address, staffID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"sessions":           sessions,
+		"current_session_id": c.GetUint("session_id"),
+	})
+}
+
+// RevokeAuthSession signs the caller out on one device. Its access tokens stop working
+// straight away.
+func RevokeAuthSession(c *gin.Context) {
+	address, staffID, ok := sessionOwner(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("sessionId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := database.RevokeAuthSession(uint(sessionID), address, staffID, database.AuthSessionRevokedByUser); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// RevokeOtherAuthSessions signs the caller out everywhere but the current device
+func RevokeOtherAuthSessions(c *gin.Context) {
+	address, staffID, ok := sessionOwner(c)
+	if !ok {
+		return
+	}
+
+	revoked, err := database.RevokeOtherAuthSessions(address, staffID, c.GetUint("session_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Other sessions revoked", "revoked": revoked})
+}
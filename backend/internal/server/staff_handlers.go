@@ -12,6 +12,7 @@ import (
 
 	"payverge/internal/database"
 	"payverge/internal/emails"
+	"payverge/internal/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -30,8 +31,9 @@ type StaffLoginRequest struct {
 
 // Staff login code verification request
 type VerifyLoginCodeRequest struct {
-	Email string `json:"email" binding:"required,email"`
-	Code  string `json:"code" binding:"required"`
+	Email      string `json:"email" binding:"required,email"`
+	Code       string `json:"code" binding:"required"`
+	DeviceName string `json:"device_name"`
 }
 
 // Accept invitation request
@@ -66,40 +68,24 @@ func generateInvitationToken() string {
 
 // InviteStaff handles staff invitation by business owners
 func InviteStaff(c *gin.Context) {
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Get owner address from context (set by auth middleware)
-	ownerAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Owner address not found"})
-		return
-	}
-
 	var req InviteStaffRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !req.Role.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
 
 	// Get database wrapper
 	db := database.GetDBWrapper()
 
-	// Verify business ownership
-	business, err := db.BusinessService.GetByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != ownerAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to manage this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Check if staff member already exists
 	existingStaff, _ := db.StaffService.GetByEmail(req.Email)
@@ -109,7 +95,7 @@ func InviteStaff(c *gin.Context) {
 	}
 
 	// Check for pending invitation
-	pendingInvitations, _ := db.StaffInvitationService.GetByBusinessID(uint(businessID))
+	pendingInvitations, _ := db.StaffInvitationService.GetByBusinessID(businessID)
 	for _, invitation := range pendingInvitations {
 		if invitation.Email == req.Email && invitation.Status == database.InvitationStatusPending {
 			c.JSON(http.StatusConflict, gin.H{"error": "Invitation already sent to this email"})
@@ -126,13 +112,13 @@ func InviteStaff(c *gin.Context) {
 
 	// Create invitation
 	invitation := &database.StaffInvitation{
-		BusinessID: uint(businessID),
+		BusinessID: businessID,
 		Email:      strings.ToLower(req.Email),
 		Name:       req.Name,
 		Role:       req.Role,
 		Token:      token,
 		Status:     database.InvitationStatusPending,
-		InvitedBy:  ownerAddress.(string),
+		InvitedBy:  middleware.Actor(c),
 		ExpiresAt:  time.Now().Add(7 * 24 * time.Hour), // 7 days
 	}
 
@@ -153,7 +139,7 @@ func InviteStaff(c *gin.Context) {
 		"staff_name":     req.Name,
 		"role":           string(req.Role),
 		"invitation_url": invitationURL,
-		"owner_address":  ownerAddress.(string),
+		"owner_address":  middleware.Actor(c),
 		"expires_days":   "7",
 	}
 
@@ -175,15 +161,8 @@ func InviteStaff(c *gin.Context) {
 
 // ResendInvitation resends an existing staff invitation
 func ResendInvitation(c *gin.Context) {
-	businessIDStr := c.Param("id")
 	invitationIDStr := c.Param("invitationId")
 	
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-	
 	invitationID, err := strconv.ParseUint(invitationIDStr, 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid invitation ID"})
@@ -193,24 +172,11 @@ func ResendInvitation(c *gin.Context) {
 	// Get database wrapper
 	db := database.GetDBWrapper()
 
-	// Get owner address from context (set by auth middleware)
-	ownerAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Owner address not found"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := db.BusinessService.GetByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != ownerAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to manage this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Get the existing invitation
 	invitation, err := db.StaffInvitationService.GetByID(uint(invitationID))
@@ -220,7 +186,7 @@ func ResendInvitation(c *gin.Context) {
 	}
 
 	// Verify invitation belongs to this business
-	if invitation.BusinessID != uint(businessID) {
+	if invitation.BusinessID != businessID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Invitation does not belong to this business"})
 		return
 	}
@@ -256,7 +222,7 @@ func ResendInvitation(c *gin.Context) {
 		"staff_name":     invitation.Name,
 		"role":           string(invitation.Role),
 		"invitation_url": invitationURL,
-		"owner_address":  ownerAddress.(string),
+		"owner_address":  middleware.Actor(c),
 		"expires_days":   "7",
 	}
 
@@ -415,6 +381,11 @@ func VerifyLoginCode(c *gin.Context) {
 		return
 	}
 
+	if !staff.IsActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Staff account is inactive"})
+		return
+	}
+
 	// Find and verify login code
 	loginCode, err := db.StaffLoginCodeService.GetByCode(req.Code)
 	if err != nil || loginCode.StaffID != staff.ID {
@@ -428,12 +399,24 @@ func VerifyLoginCode(c *gin.Context) {
 	// Update last login time
 	db.StaffService.UpdateLastLogin(staff.ID)
 
-	// Generate JWT token for staff (placeholder for now)
-	token := "staff_jwt_token_placeholder"
+	businessID := staff.BusinessID
+	session := &database.AuthSession{
+		StaffID:    &staff.ID,
+		BusinessID: &businessID,
+		DeviceName: truncateDeviceField(req.DeviceName),
+		UserAgent:  truncateDeviceField(c.Request.UserAgent()),
+		IPAddress:  c.ClientIP(),
+	}
+	tokens, ok := startAuthSession(c, session, string(staff.Role))
+	if !ok {
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Login successful",
-		"token": token,
+		"token": tokens["token"],
+		"refresh_token": tokens["refresh_token"],
+		"expires_in": tokens["expires_in"],
 		"staff": gin.H{
 			"id": staff.ID,
 			"name": staff.Name,
@@ -446,44 +429,24 @@ func VerifyLoginCode(c *gin.Context) {
 
 // GetBusinessStaff returns all staff members for a business
 func GetBusinessStaff(c *gin.Context) {
-	businessIDStr := c.Param("id")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Get owner address from context
-	ownerAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Owner address not found"})
-		return
-	}
-
 	// Get database wrapper
 	db := database.GetDBWrapper()
 
-	// Verify business ownership
-	business, err := db.BusinessService.GetByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != ownerAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this business staff"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Get staff members
-	staff, err := db.StaffService.GetByBusinessID(uint(businessID))
+	staff, err := db.StaffService.GetByBusinessID(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve staff"})
 		return
 	}
 
 	// Get pending invitations (only pending status)
-	allInvitations, err := db.StaffInvitationService.GetByBusinessID(uint(businessID))
+	allInvitations, err := db.StaffInvitationService.GetByBusinessID(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve invitations"})
 		return
@@ -505,42 +468,22 @@ func GetBusinessStaff(c *gin.Context) {
 
 // RemoveStaff removes a staff member
 func RemoveStaff(c *gin.Context) {
-	businessIDStr := c.Param("id")
 	staffIDStr := c.Param("staffId")
 	
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-	
 	staffID, err := strconv.ParseUint(staffIDStr, 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid staff ID"})
 		return
 	}
 
-	// Get owner address from context
-	ownerAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Owner address not found"})
-		return
-	}
-
 	// Get database wrapper
 	db := database.GetDBWrapper()
 
-	// Verify business ownership
-	business, err := db.BusinessService.GetByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != ownerAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to manage this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Verify staff belongs to business
 	staff, err := db.StaffService.GetByID(uint(staffID))
@@ -549,7 +492,7 @@ func RemoveStaff(c *gin.Context) {
 		return
 	}
 
-	if staff.BusinessID != uint(businessID) {
+	if staff.BusinessID != businessID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Staff member does not belong to this business"})
 		return
 	}
@@ -565,15 +508,8 @@ func RemoveStaff(c *gin.Context) {
 
 // UpdateStaffRole updates a staff member's role
 func UpdateStaffRole(c *gin.Context) {
-	businessIDStr := c.Param("id")
 	staffIDStr := c.Param("staffId")
 	
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-	
 	staffID, err := strconv.ParseUint(staffIDStr, 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid staff ID"})
@@ -589,40 +525,19 @@ func UpdateStaffRole(c *gin.Context) {
 	}
 
 	// Validate role
-	validRoles := []string{"manager", "server", "host", "kitchen"}
-	roleValid := false
-	for _, validRole := range validRoles {
-		if req.Role == validRole {
-			roleValid = true
-			break
-		}
-	}
-	if !roleValid {
+	if !database.StaffRole(req.Role).IsValid() {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
 		return
 	}
 
-	// Get owner address from context
-	ownerAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Owner address not found"})
-		return
-	}
-
 	// Get database wrapper
 	db := database.GetDBWrapper()
 
-	// Verify business ownership
-	business, err := db.BusinessService.GetByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != ownerAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to manage this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Get staff member
 	staff, err := db.StaffService.GetByID(uint(staffID))
@@ -632,7 +547,7 @@ func UpdateStaffRole(c *gin.Context) {
 	}
 
 	// Verify staff belongs to this business
-	if staff.BusinessID != uint(businessID) {
+	if staff.BusinessID != businessID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Staff member does not belong to this business"})
 		return
 	}
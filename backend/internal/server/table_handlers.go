@@ -16,7 +16,18 @@ import (
 
 // Table creation request
 type CreateTableRequest struct {
-	Name string `json:"name" binding:"required"`
+	Name    string              `json:"name" binding:"required"`
+	Section string              `json:"section"`
+	Seats   int                 `json:"seats" binding:"min=0"`
+	Shape   database.TableShape `json:"shape"`
+}
+
+// tableShape defaults an unset shape and reports whether the requested one is known
+func (r *CreateTableRequest) tableShape() (database.TableShape, bool) {
+	if r.Shape == "" {
+		return database.TableShapeSquare, true
+	}
+	return r.Shape, r.Shape.IsValid()
 }
 
 // Table update request
@@ -27,40 +38,22 @@ type UpdateTableRequest struct {
 
 // CreateTable creates a new table for a business
 func CreateTable(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessIDStr := c.Param("businessId")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Check if business exists and user owns it
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve business"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req CreateTableRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	shape, ok := req.tableShape()
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid table shape"})
+		return
+	}
 
 	// Generate unique table code
 	tableCode, err := generateTableCode()
@@ -70,14 +63,17 @@ func CreateTable(c *gin.Context) {
 	}
 
 	// Generate QR code URL (this would point to the guest interface)
-	qrCodeURL := fmt.Sprintf("https://payverge.io/t/%s", tableCode)
+	qrCodeURL := tableGuestURL(defaultQRBaseURL(), tableCode)
 
 	table := &database.Table{
-		BusinessID: uint(businessID),
+		BusinessID: businessID,
 		TableCode:  tableCode,
 		Name:       req.Name,
 		QRCode:     qrCodeURL,
 		IsActive:   true,
+		Section:    req.Section,
+		Seats:      req.Seats,
+		Shape:      shape,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
@@ -92,36 +88,13 @@ func CreateTable(c *gin.Context) {
 
 // GetTables retrieves all tables for a business
 func GetTables(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessIDStr := c.Param("businessId")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
-	// Check if business exists and user owns it
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve business"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this business"})
-		return
-	}
-
-	tables, err := database.GetTablesByBusinessID(uint(businessID))
+	tables, err := database.GetTablesByBusinessID(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tables"})
 		return
@@ -132,19 +105,6 @@ func GetTables(c *gin.Context) {
 
 // GetTable retrieves a specific table by ID
 func GetTable(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessIDStr := c.Param("businessId")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
 	tableIDStr := c.Param("tableId")
 	tableID, err := strconv.ParseUint(tableIDStr, 10, 32)
 	if err != nil {
@@ -152,21 +112,11 @@ func GetTable(c *gin.Context) {
 		return
 	}
 
-	// Check if business exists and user owns it
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve business"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Get the table and verify it belongs to the business
 	table, err := database.GetTableByID(uint(tableID))
@@ -179,7 +129,7 @@ func GetTable(c *gin.Context) {
 		return
 	}
 
-	if table.BusinessID != uint(businessID) {
+	if table.BusinessID != businessID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Table does not belong to this business"})
 		return
 	}
@@ -189,19 +139,6 @@ func GetTable(c *gin.Context) {
 
 // UpdateTable updates an existing table
 func UpdateTable(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessIDStr := c.Param("businessId")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
 	tableIDStr := c.Param("tableId")
 	tableID, err := strconv.ParseUint(tableIDStr, 10, 32)
 	if err != nil {
@@ -209,21 +146,11 @@ func UpdateTable(c *gin.Context) {
 		return
 	}
 
-	// Check if business exists and user owns it
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve business"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Get the table and verify it belongs to the business
 	table, err := database.GetTableByID(uint(tableID))
@@ -236,7 +163,7 @@ func UpdateTable(c *gin.Context) {
 		return
 	}
 
-	if table.BusinessID != uint(businessID) {
+	if table.BusinessID != businessID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Table does not belong to this business"})
 		return
 	}
@@ -264,19 +191,6 @@ func UpdateTable(c *gin.Context) {
 
 // DeleteTable soft deletes a table
 func DeleteTable(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessIDStr := c.Param("businessId")
-	businessID, err := strconv.ParseUint(businessIDStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
 	tableIDStr := c.Param("tableId")
 	tableID, err := strconv.ParseUint(tableIDStr, 10, 32)
 	if err != nil {
@@ -284,21 +198,11 @@ func DeleteTable(c *gin.Context) {
 		return
 	}
 
-	// Check if business exists and user owns it
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve business"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	// Get the table and verify it belongs to the business
 	table, err := database.GetTableByID(uint(tableID))
@@ -311,7 +215,7 @@ func DeleteTable(c *gin.Context) {
 		return
 	}
 
-	if table.BusinessID != uint(businessID) {
+	if table.BusinessID != businessID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Table does not belong to this business"})
 		return
 	}
@@ -388,48 +292,39 @@ func generateTableCode() (string, error) {
 
 // CreateTableWithQR creates a new table with automatic QR code generation
 func CreateTableWithQR(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req CreateTableRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	shape, ok := req.tableShape()
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid table shape"})
+		return
+	}
 
 	// Generate unique table code
-	tableCode, err := database.GenerateUniqueTableCode(uint(businessID), req.Name)
+	tableCode, err := database.GenerateUniqueTableCode(businessID, req.Name)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate table code"})
 		return
 	}
 
 	table := &database.Table{
-		BusinessID: uint(businessID),
+		BusinessID: businessID,
 		Name:       req.Name,
 		TableCode:  tableCode,
+		QRCode:     tableGuestURL(defaultQRBaseURL(), tableCode),
 		IsActive:   true,
+		Section:    req.Section,
+		Seats:      req.Seats,
+		Shape:      shape,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
@@ -445,7 +340,11 @@ func CreateTableWithQR(c *gin.Context) {
 		"name":       table.Name,
 		"table_code": table.TableCode,
 		"qr_url":     fmt.Sprintf("/t/%s", table.TableCode),
+		"qr_code":    table.QRCode,
 		"is_active":  table.IsActive,
+		"section":    table.Section,
+		"seats":      table.Seats,
+		"shape":      table.Shape,
 		"created_at": table.CreatedAt,
 	}
 
@@ -454,36 +353,19 @@ func CreateTableWithQR(c *gin.Context) {
 
 // UpdateTableDetails updates table information
 func UpdateTableDetails(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	tableID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid table ID"})
 		return
 	}
 
-	// Get table and verify ownership
+	// Get table; the route has already checked access to its business
 	table, err := database.GetTableByID(uint(tableID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
 		return
 	}
 
-	business, err := database.GetBusinessByID(table.BusinessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to modify this table"})
-		return
-	}
-
 	var req UpdateTableRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -507,6 +389,7 @@ func UpdateTableDetails(c *gin.Context) {
 		"name":       table.Name,
 		"table_code": table.TableCode,
 		"qr_url":     fmt.Sprintf("/t/%s", table.TableCode),
+		"qr_code":    table.QRCode,
 		"is_active":  table.IsActive,
 		"updated_at": table.UpdatedAt,
 	})
@@ -514,36 +397,12 @@ func UpdateTableDetails(c *gin.Context) {
 
 // DeleteTableSoft soft deletes a table
 func DeleteTableSoft(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
 	tableID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid table ID"})
 		return
 	}
 
-	// Get table and verify ownership
-	table, err := database.GetTableByID(uint(tableID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
-		return
-	}
-
-	business, err := database.GetBusinessByID(table.BusinessID)
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to delete this table"})
-		return
-	}
-
 	if err := database.DeleteTable(uint(tableID)); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -554,37 +413,29 @@ func DeleteTableSoft(c *gin.Context) {
 
 // GetBusinessTables gets all tables for a business with QR URLs
 func GetBusinessTables(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	tables, err := database.GetTablesByBusinessID(businessID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	business, err := database.GetBusinessByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view this business"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	tables, err := database.GetTablesByBusinessID(uint(businessID))
+	board, err := database.GetTableBoard(businessID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	states := make(map[uint]database.TableState, len(board))
+	for _, status := range board {
+		states[status.TableID] = status.Status
+	}
 
-	// Add QR URLs to response
+	// Add QR URLs, floor plan layout and live status to response
 	var response []gin.H
 	for _, table := range tables {
 		response = append(response, gin.H{
@@ -592,7 +443,14 @@ func GetBusinessTables(c *gin.Context) {
 			"name":       table.Name,
 			"table_code": table.TableCode,
 			"qr_url":     fmt.Sprintf("/t/%s", table.TableCode),
+			"qr_code":    table.QRCode,
 			"is_active":  table.IsActive,
+			"section":    table.Section,
+			"position_x": table.PositionX,
+			"position_y": table.PositionY,
+			"seats":      table.Seats,
+			"shape":      table.Shape,
+			"status":     states[table.ID],
 			"created_at": table.CreatedAt,
 			"updated_at": table.UpdatedAt,
 		})
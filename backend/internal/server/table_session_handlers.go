@@ -0,0 +1,191 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"payverge/internal/database"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TableSessionHeader carries the token guests get when they scan a table's QR code
+const TableSessionHeader = "X-Table-Session"
+
+// maxDeviceFieldLength limits the device details guests report about themselves
+const maxDeviceFieldLength = 255
+
+// StartTableSessionRequest describes the guest device joining a table
+type StartTableSessionRequest struct {
+	DeviceID   string `json:"device_id"`
+	DeviceName string `json:"device_name"`
+}
+
+func truncateDeviceField(value string) string {
+	if len(value) > maxDeviceFieldLength {
+		return value[:maxDeviceFieldLength]
+	}
+	return value
+}
+
+// requireTableSession checks the table session token sent with a guest request
+func requireTableSession(c *gin.Context, table *database.Table) (*database.TableSession, bool) {
+	session, err := database.ValidateTableSession(table.ID, c.GetHeader(TableSessionHeader))
+	if err != nil {
+		if errors.Is(err, database.ErrTableSessionInvalid) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "A valid table session is required, scan the table's QR code again"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check table session"})
+		return nil, false
+	}
+	return session, true
+}
+
+// RequireBillTableSession guards guest routes that act on a bill. The request must
+// carry the table session of the device seated at the table the bill belongs to.
+func RequireBillTableSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		billID, err := strconv.ParseUint(c.Param("bill_id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bill ID"})
+			c.Abort()
+			return
+		}
+		bill, _, err := database.GetBillByID(uint(billID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bill not found"})
+			c.Abort()
+			return
+		}
+
+		if _, err := database.ValidateBillTableSession(bill, c.GetHeader(TableSessionHeader)); err != nil {
+			if errors.Is(err, database.ErrTableSessionInvalid) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "A valid table session is required, scan the table's QR code again"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check table session"})
+			}
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// StartTableSession joins a guest device to a table after it scanned the table's code.
+// The returned token must be sent in the X-Table-Session header to open a bill or order.
+func StartTableSession(c *gin.Context) {
+	table, err := database.GetTableByCode(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+		return
+	}
+
+	var req StartTableSessionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	session, token, err := database.StartTableSession(table,
+		truncateDeviceField(req.DeviceID),
+		truncateDeviceField(req.DeviceName),
+		truncateDeviceField(c.Request.UserAgent()),
+		c.ClientIP())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start table session"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":   token,
+		"session": session,
+	})
+}
+
+// GetTableSession tells a guest whether their table session is still valid
+func GetTableSession(c *gin.Context) {
+	table, err := database.GetTableByCode(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+		return
+	}
+
+	session, ok := requireTableSession(c, table)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"session": session})
+}
+
+// tableSessionTable checks the caller owns the business and returns its table
+func tableSessionTable(c *gin.Context) (*database.Business, *database.Table, bool) {
+	business, ok := authorizedBusiness(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	tableID, err := strconv.ParseUint(c.Param("tableId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid table ID"})
+		return nil, nil, false
+	}
+	table, err := database.GetTableByID(uint(tableID))
+	if err != nil || table.BusinessID != business.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+		return nil, nil, false
+	}
+
+	return business, table, true
+}
+
+// GetTableSessions lists the guest devices that joined a table, only those still
+// joined unless all=true
+func GetTableSessions(c *gin.Context) {
+	business, table, ok := tableSessionTable(c)
+	if !ok {
+		return
+	}
+	all, _ := strconv.ParseBool(c.Query("all"))
+
+	sessions, err := database.GetTableSessions(business.ID, table.ID, !all)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get table sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"table":    table,
+		"sessions": sessions,
+	})
+}
+
+// RevokeTableSession signs a guest device out of a table
+func RevokeTableSession(c *gin.Context) {
+	business, table, ok := tableSessionTable(c)
+	if !ok {
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("sessionId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	if err := database.RevokeTableSession(business.ID, table.ID, uint(sessionID)); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Table session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke table session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Table session revoked"})
+}
@@ -34,30 +34,11 @@ type TranslationStatus struct {
 
 // TranslateEntireMenu translates an entire business menu to specified languages
 func TranslateEntireMenu(c *gin.Context) {
-	userAddress, exists := c.Get("address")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	businessID, err := strconv.ParseUint(c.Param("id"), 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid business ID"})
-		return
-	}
-
-	// Verify business ownership
-	db := database.GetDBWrapper()
-	business, err := db.BusinessService.GetByID(uint(businessID))
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Business not found"})
-		return
-	}
-
-	if business.OwnerAddress != userAddress.(string) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to manage this business"})
+	business, ok := authorizedBusiness(c)
+	if !ok {
 		return
 	}
+	businessID := business.ID
 
 	var req TranslateMenuRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -66,11 +47,11 @@ func TranslateEntireMenu(c *gin.Context) {
 	}
 
 	// Generate a job ID
-	jobID := generateJobID(uint(businessID))
+	jobID := generateJobID(businessID)
 
 	// Start translation in background
 	go func() {
-		if err := performBatchTranslation(uint(businessID), req.LanguageCodes, jobID); err != nil {
+		if err := performBatchTranslation(businessID, req.LanguageCodes, jobID); err != nil {
 			log.Printf("Failed to translate menu for business %d: %v", businessID, err)
 		}
 	}()
@@ -109,8 +90,8 @@ func generateJobID(businessID uint) string {
 func performBatchTranslation(businessID uint, languageCodes []string, jobID string) error {
 	log.Printf("Starting batch translation job %s for business %d", jobID, businessID)
 
-	// Get the menu
-	_, categories, err := database.GetMenuByBusinessID(businessID)
+	// Get the categories of all menus
+	categories, err := database.GetAllMenuCategories(businessID)
 	if err != nil {
 		return err
 	}
@@ -150,25 +131,19 @@ func performBatchTranslation(businessID uint, languageCodes []string, jobID stri
 	// Use the existing translation service to translate all content
 	processed := 0
 	for _, category := range categories {
-		// Create a consistent ID hash from category name and description
-		categoryID := uint(len(category.Name) + len(category.Description))
-		
 		// Translate category using the existing service
-		err := translationService.TranslateCategory(businessID, categoryID, category.Name, category.Description)
+		err := translationService.TranslateCategory(businessID, category.ID, category.Name, category.Description)
 		if err != nil {
-			log.Printf("Failed to translate category %s: %v", category.ID, err)
+			log.Printf("Failed to translate category %d: %v", category.ID, err)
 		}
 		processed++
 
 		// Translate all items in category
 		for _, item := range category.Items {
-			// Create a consistent ID hash from item name and description
-			itemID := uint(len(item.Name) + len(item.Description))
-			
 			// Translate menu item using the existing service
-			err := translationService.TranslateMenuItem(businessID, itemID, item.Name, item.Description)
+			err := translationService.TranslateMenuItem(businessID, item.ID, item.Name, item.Description)
 			if err != nil {
-				log.Printf("Failed to translate menu item %s: %v", item.ID, err)
+				log.Printf("Failed to translate menu item %d: %v", item.ID, err)
 			}
 			processed++
 		}
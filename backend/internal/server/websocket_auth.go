@@ -0,0 +1,53 @@
+package server
+
+import (
+	"payverge/internal/database"
+)
+
+// VerifyWebSocketToken verifies a JWT for the websocket hub and returns its claims.
+// Tokens of revoked sessions are refused.
+func VerifyWebSocketToken(tokenString string) (map[string]interface{}, error) {
+	claims, _, err := VerifySessionToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// CanAccessBusiness reports whether the token claims belong to the business owner
+// or to an active staff member of the business
+func CanAccessBusiness(claims map[string]interface{}, businessID uint) bool {
+	return businessAccess(claims, businessID) != nil
+}
+
+// CanAccessKitchen reports whether the token claims belong to the business owner or to
+// an active staff member whose role can see the kitchen
+func CanAccessKitchen(claims map[string]interface{}, businessID uint) bool {
+	access := businessAccess(claims, businessID)
+	return access != nil && access.Can(database.PermissionKitchenRead)
+}
+
+// CanWatchBill reports whether a guest's table session belongs to the bill, or the token
+// claims give access to the bill's business
+func CanWatchBill(claims map[string]interface{}, sessionToken string, billID uint) bool {
+	bill, _, err := database.GetBillByID(billID)
+	if err != nil {
+		return false
+	}
+	if claims != nil && CanAccessBusiness(claims, bill.BusinessID) {
+		return true
+	}
+	_, err = database.ValidateBillTableSession(bill, sessionToken)
+	return err == nil
+}
+
+// businessAccess resolves what the claims allow on the business, or nil if nothing
+func businessAccess(claims map[string]interface{}, businessID uint) *database.BusinessAccess {
+	address, _ := claims["address"].(string)
+	staffID, _ := claims["staff_id"].(float64)
+	access, err := database.GetBusinessAccess(businessID, address, uint(staffID))
+	if err != nil {
+		return nil
+	}
+	return access
+}
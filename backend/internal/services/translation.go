@@ -231,25 +231,23 @@ func (s *TranslationService) TranslateExistingContent(businessID uint) error {
 	log.Printf("Starting translation of existing content for business %d", businessID)
 
 	// Get all categories for this business
-	_, categories, err := database.GetMenuByBusinessID(businessID)
+	categories, err := database.GetAllMenuCategories(businessID)
 	if err != nil {
 		return fmt.Errorf("failed to get menu: %w", err)
 	}
 
 	// Translate all categories
-	for i, category := range categories {
-		categoryID := uint(i + 1) // Use index as ID for now
-		err := s.TranslateCategory(businessID, categoryID, category.Name, category.Description)
+	for _, category := range categories {
+		err := s.TranslateCategory(businessID, category.ID, category.Name, category.Description)
 		if err != nil {
-			log.Printf("Failed to translate category %d: %v", categoryID, err)
+			log.Printf("Failed to translate category %d: %v", category.ID, err)
 		}
 
 		// Translate all items in this category
-		for j, item := range category.Items {
-			itemID := uint(j + 1) // Use index as ID for now
-			err := s.TranslateMenuItem(businessID, itemID, item.Name, item.Description)
+		for _, item := range category.Items {
+			err := s.TranslateMenuItem(businessID, item.ID, item.Name, item.Description)
 			if err != nil {
-				log.Printf("Failed to translate menu item %d: %v", itemID, err)
+				log.Printf("Failed to translate menu item %d: %v", item.ID, err)
 			}
 		}
 	}
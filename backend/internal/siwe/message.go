@@ -0,0 +1,258 @@
+// Package siwe parses, validates and verifies Sign-In with Ethereum (EIP-4361) messages
+package siwe
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ErrInvalidMessage is returned for a message that does not follow the EIP-4361 format
+var ErrInvalidMessage = errors.New("invalid sign-in message")
+
+const (
+	headerSuffix   = " wants you to sign in with your Ethereum account:"
+	uriTag         = "URI: "
+	versionTag     = "Version: "
+	chainIDTag     = "Chain ID: "
+	nonceTag       = "Nonce: "
+	issuedAtTag    = "Issued At: "
+	expirationTag  = "Expiration Time: "
+	notBeforeTag   = "Not Before: "
+	requestIDTag   = "Request ID: "
+	resourcesTag   = "Resources:"
+	resourcePrefix = "- "
+)
+
+// Message is a parsed EIP-4361 sign-in message
+type Message struct {
+	Scheme         string // Optional, e.g. "https"
+	Domain         string // Authority of the site asking for the signature
+	Address        string
+	Statement      string // Optional
+	URI            string
+	Version        string
+	ChainID        int64
+	Nonce          string
+	IssuedAt       time.Time
+	ExpirationTime *time.Time
+	NotBefore      *time.Time
+	RequestID      string
+	Resources      []string
+}
+
+// ParseMessage parses a message laid out as EIP-4361 describes. Only the format is
+// checked here; Validate checks the fields against the server's configuration.
+func ParseMessage(raw string) (*Message, error) {
+	lines := strings.Split(raw, "\n")
+	p := &parser{lines: lines}
+	m := &Message{}
+
+	header := p.next()
+	if !strings.HasSuffix(header, headerSuffix) {
+		return nil, p.errorf("missing header")
+	}
+	m.Domain = strings.TrimSuffix(header, headerSuffix)
+	if scheme, domain, ok := strings.Cut(m.Domain, "://"); ok {
+		m.Scheme, m.Domain = scheme, domain
+	}
+	if m.Domain == "" || strings.ContainsAny(m.Domain, " /") {
+		return nil, p.errorf("invalid domain %q", m.Domain)
+	}
+
+	m.Address = p.next()
+	if !validAddress(m.Address) {
+		return nil, p.errorf("invalid address %q", m.Address)
+	}
+
+	if p.next() != "" {
+		return nil, p.errorf("expected an empty line after the address")
+	}
+	// The statement is optional, and so is the empty line where it would be
+	if line := p.peek(); line != "" && !strings.HasPrefix(line, uriTag) {
+		m.Statement = p.next()
+		if p.next() != "" {
+			return nil, p.errorf("expected an empty line after the statement")
+		}
+	} else if line == "" {
+		p.next()
+	}
+
+	var err error
+	if m.URI, err = p.field(uriTag); err != nil {
+		return nil, err
+	}
+	if u, err := url.Parse(m.URI); err != nil || u.Scheme == "" {
+		return nil, p.errorf("invalid URI %q", m.URI)
+	}
+	if m.Version, err = p.field(versionTag); err != nil {
+		return nil, err
+	}
+	chainID, err := p.field(chainIDTag)
+	if err != nil {
+		return nil, err
+	}
+	if m.ChainID, err = strconv.ParseInt(chainID, 10, 64); err != nil || m.ChainID <= 0 {
+		return nil, p.errorf("invalid chain ID %q", chainID)
+	}
+	if m.Nonce, err = p.field(nonceTag); err != nil {
+		return nil, err
+	}
+	if !validNonce(m.Nonce) {
+		return nil, p.errorf("nonce must be at least 8 letters and digits")
+	}
+	issuedAt, err := p.field(issuedAtTag)
+	if err != nil {
+		return nil, err
+	}
+	if m.IssuedAt, err = time.Parse(time.RFC3339, issuedAt); err != nil {
+		return nil, p.errorf("invalid issued at time %q", issuedAt)
+	}
+
+	if p.hasPrefix(expirationTag) {
+		if m.ExpirationTime, err = p.timeField(expirationTag); err != nil {
+			return nil, err
+		}
+	}
+	if p.hasPrefix(notBeforeTag) {
+		if m.NotBefore, err = p.timeField(notBeforeTag); err != nil {
+			return nil, err
+		}
+	}
+	if p.hasPrefix(requestIDTag) {
+		m.RequestID = strings.TrimPrefix(p.next(), requestIDTag)
+	}
+	if p.peek() == resourcesTag {
+		p.next()
+		for p.hasPrefix(resourcePrefix) {
+			m.Resources = append(m.Resources, strings.TrimPrefix(p.next(), resourcePrefix))
+		}
+	}
+
+	// Wallets may end the message with a newline, nothing else may follow
+	for p.more() {
+		if p.next() != "" {
+			return nil, p.errorf("unexpected line %q", p.lines[p.pos-1])
+		}
+	}
+	return m, nil
+}
+
+// String lays the message out as EIP-4361 describes; it is the text that gets signed
+func (m *Message) String() string {
+	var b strings.Builder
+	if m.Scheme != "" {
+		b.WriteString(m.Scheme + "://")
+	}
+	b.WriteString(m.Domain + headerSuffix + "\n")
+	b.WriteString(m.Address + "\n\n")
+	if m.Statement != "" {
+		b.WriteString(m.Statement + "\n")
+	}
+	b.WriteString("\n")
+	b.WriteString(uriTag + m.URI + "\n")
+	b.WriteString(versionTag + m.Version + "\n")
+	b.WriteString(chainIDTag + strconv.FormatInt(m.ChainID, 10) + "\n")
+	b.WriteString(nonceTag + m.Nonce + "\n")
+	b.WriteString(issuedAtTag + m.IssuedAt.Format(time.RFC3339))
+	if m.ExpirationTime != nil {
+		b.WriteString("\n" + expirationTag + m.ExpirationTime.Format(time.RFC3339))
+	}
+	if m.NotBefore != nil {
+		b.WriteString("\n" + notBeforeTag + m.NotBefore.Format(time.RFC3339))
+	}
+	if m.RequestID != "" {
+		b.WriteString("\n" + requestIDTag + m.RequestID)
+	}
+	if len(m.Resources) > 0 {
+		b.WriteString("\n" + resourcesTag)
+		for _, resource := range m.Resources {
+			b.WriteString("\n" + resourcePrefix + resource)
+		}
+	}
+	return b.String()
+}
+
+// validAddress accepts a hex address in EIP-55 checksum case, or all in one case as
+// EIP-55 allows for addresses that carry no checksum
+func validAddress(address string) bool {
+	if !strings.HasPrefix(address, "0x") || !common.IsHexAddress(address) {
+		return false
+	}
+	hex := address[2:]
+	if hex == strings.ToLower(hex) || hex == strings.ToUpper(hex) {
+		return true
+	}
+	return common.HexToAddress(address).Hex() == address
+}
+
+func validNonce(nonce string) bool {
+	if len(nonce) < 8 {
+		return false
+	}
+	for _, r := range nonce {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// parser walks the lines of a message, remembering the line it is on for errors
+type parser struct {
+	lines []string
+	pos   int
+}
+
+func (p *parser) more() bool {
+	return p.pos < len(p.lines)
+}
+
+func (p *parser) peek() string {
+	if !p.more() {
+		return ""
+	}
+	return strings.TrimSuffix(p.lines[p.pos], "\r")
+}
+
+func (p *parser) next() string {
+	line := p.peek()
+	p.pos++
+	return line
+}
+
+func (p *parser) hasPrefix(prefix string) bool {
+	return p.more() && strings.HasPrefix(p.peek(), prefix)
+}
+
+func (p *parser) field(tag string) (string, error) {
+	if !p.hasPrefix(tag) {
+		return "", p.errorf("expected %q", strings.TrimSpace(tag))
+	}
+	value := strings.TrimPrefix(p.next(), tag)
+	if value == "" {
+		return "", fmt.Errorf("%w: line %d: %q is empty", ErrInvalidMessage, p.pos, strings.TrimSpace(tag))
+	}
+	return value, nil
+}
+
+func (p *parser) timeField(tag string) (*time.Time, error) {
+	value, err := p.field(tag)
+	if err != nil {
+		return nil, err
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: line %d: invalid time %q", ErrInvalidMessage, p.pos, value)
+	}
+	return &t, nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalidMessage, p.pos, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,106 @@
+package siwe
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAddress = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+
+// Example from EIP-4361 with every optional field
+const fullMessage = `https://example.com wants you to sign in with your Ethereum account:
+0x742d35Cc6634C0532925a3b844Bc454e4438f44e
+
+I accept the ExampleOrg Terms of Service: https://example.com/tos
+
+URI: https://example.com/login
+Version: 1
+Chain ID: 1
+Nonce: 32891756
+Issued At: 2021-09-30T16:25:24Z
+Expiration Time: 2021-10-01T16:25:24Z
+Not Before: 2021-09-30T16:25:00Z
+Request ID: some_id
+Resources:
+- ipfs://bafybeiemxf5abjwjbikoz4mc3a3dla6ual3jsgpdr4cjr3oz3evfyavhwq/
+- https://example.com/my-web2-claim.json`
+
+func testMessage() *Message {
+	return &Message{
+		Domain:   "app.payverge.io",
+		Address:  testAddress,
+		URI:      "https://app.payverge.io",
+		Version:  "1",
+		ChainID:  8453,
+		Nonce:    "a1b2c3d4e5f6a7b8",
+		IssuedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestParseFullMessage(t *testing.T) {
+	m, err := ParseMessage(fullMessage)
+	require.NoError(t, err)
+
+	assert.Equal(t, "https", m.Scheme)
+	assert.Equal(t, "example.com", m.Domain)
+	assert.Equal(t, testAddress, m.Address)
+	assert.Equal(t, "I accept the ExampleOrg Terms of Service: https://example.com/tos", m.Statement)
+	assert.Equal(t, "https://example.com/login", m.URI)
+	assert.Equal(t, "1", m.Version)
+	assert.Equal(t, int64(1), m.ChainID)
+	assert.Equal(t, "32891756", m.Nonce)
+	assert.Equal(t, time.Date(2021, 9, 30, 16, 25, 24, 0, time.UTC), m.IssuedAt.UTC())
+	require.NotNil(t, m.ExpirationTime)
+	assert.Equal(t, time.Date(2021, 10, 1, 16, 25, 24, 0, time.UTC), m.ExpirationTime.UTC())
+	require.NotNil(t, m.NotBefore)
+	assert.Equal(t, "some_id", m.RequestID)
+	assert.Len(t, m.Resources, 2)
+
+	assert.Equal(t, fullMessage, m.String())
+}
+
+func TestParseMinimalMessage(t *testing.T) {
+	m := testMessage()
+	raw := m.String()
+	// Without a statement the empty line it would sit on stays
+	assert.Contains(t, raw, testAddress+"\n\n\nURI: ")
+
+	parsed, err := ParseMessage(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "", parsed.Scheme)
+	assert.Equal(t, "", parsed.Statement)
+	assert.Nil(t, parsed.ExpirationTime)
+	assert.Equal(t, raw, parsed.String())
+
+	// Some wallets drop that line or add a trailing newline
+	parsed, err = ParseMessage(strings.Replace(raw, "\n\n\nURI: ", "\n\nURI: ", 1) + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, m.Nonce, parsed.Nonce)
+}
+
+func TestParseRejectsMalformedMessages(t *testing.T) {
+	valid := testMessage().String()
+	cases := map[string]string{
+		"old layout":       "🍽️ Welcome to Payverge!\n\nWallet: " + testAddress + "\nVerification: a1b2c3d4e5f6a7b8\nNetwork: Base #8453",
+		"bad address":      strings.Replace(valid, testAddress, "0x1234", 1),
+		"bad checksum":     strings.Replace(valid, testAddress, "0x742D35Cc6634C0532925a3b844Bc454e4438f44e", 1),
+		"missing version":  strings.Replace(valid, "Version: 1\n", "", 1),
+		"chain not number": strings.Replace(valid, "Chain ID: 8453", "Chain ID: #8453", 1),
+		"short nonce":      strings.Replace(valid, "a1b2c3d4e5f6a7b8", "abc", 1),
+		"bad issued at":    strings.Replace(valid, "2024-05-01T12:00:00Z", "May 1, 12:00 PM", 1),
+		"trailing text":    valid + "\nExtra: field",
+		"no statement gap": strings.Replace(valid, testAddress+"\n\n\n", testAddress+"\nURI-less\n\n", 1),
+	}
+	for name, raw := range cases {
+		_, err := ParseMessage(raw)
+		assert.ErrorIs(t, err, ErrInvalidMessage, name)
+	}
+
+	// Lowercase addresses carry no checksum and are accepted
+	_, err := ParseMessage(strings.Replace(valid, testAddress, strings.ToLower(testAddress), 1))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,110 @@
+package siwe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrInvalidSignature is returned when neither the address's key nor, for a smart
+// contract wallet, the contract accepts the signature
+var ErrInvalidSignature = errors.New("invalid sign-in signature")
+
+// eip1271MagicValue is returned by isValidSignature(bytes32,bytes) for a valid signature
+var eip1271MagicValue = []byte{0x16, 0x26, 0xba, 0x7e}
+
+// ContractCaller is the subset of the Ethereum client needed to ask a smart contract
+// wallet whether it signed a message
+type ContractCaller interface {
+	CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error)
+	CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// Verifier checks that a message was signed by its address
+type Verifier struct {
+	chainID int64
+	caller  ContractCaller
+}
+
+// NewVerifier returns a verifier for externally owned accounts that also asks smart
+// contract wallets (EIP-1271), such as a Safe, on the chain the caller is connected to.
+// A nil caller verifies externally owned accounts only.
+func NewVerifier(chainID int64, caller ContractCaller) *Verifier {
+	return &Verifier{chainID: chainID, caller: caller}
+}
+
+// Verify checks the hex encoded signature of the raw message text
+func (v *Verifier) Verify(ctx context.Context, m *Message, raw, signature string) error {
+	sig, err := hexutil.Decode(strings.TrimSpace(signature))
+	if err != nil || len(sig) == 0 {
+		return fmt.Errorf("%w: not hex encoded", ErrInvalidSignature)
+	}
+	address := common.HexToAddress(m.Address)
+	hash := accounts.TextHash([]byte(raw))
+
+	if recovered, ok := recoverSigner(hash, sig); ok && recovered == address {
+		return nil
+	}
+
+	if v.caller == nil || m.ChainID != v.chainID {
+		return ErrInvalidSignature
+	}
+	code, err := v.caller.CodeAt(ctx, address, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get wallet code: %w", err)
+	}
+	if len(code) == 0 {
+		// An externally owned account whose key did not sign
+		return ErrInvalidSignature
+	}
+
+	result, err := v.caller.CallContract(ctx, ethereum.CallMsg{To: &address, Data: isValidSignatureCall(hash, sig)}, nil)
+	if err != nil {
+		// Wallets revert rather than return a failure value for signatures they reject
+		return fmt.Errorf("%w: wallet rejected it: %v", ErrInvalidSignature, err)
+	}
+	if len(result) < len(eip1271MagicValue) || !bytes.Equal(result[:len(eip1271MagicValue)], eip1271MagicValue) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// recoverSigner returns the address whose key made a 65 byte signature of hash
+func recoverSigner(hash, sig []byte) (common.Address, bool) {
+	if len(sig) != crypto.SignatureLength {
+		return common.Address{}, false
+	}
+	sig = append([]byte(nil), sig...)
+	// Wallets use 27 and 28 for the recovery ID, go-ethereum 0 and 1
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, false
+	}
+	return crypto.PubkeyToAddress(*pubKey), true
+}
+
+// isValidSignatureCall ABI encodes isValidSignature(bytes32 hash, bytes signature). The
+// function's selector is the magic value it returns for a valid signature.
+func isValidSignatureCall(hash, sig []byte) []byte {
+	data := append([]byte(nil), eip1271MagicValue...)
+	data = append(data, hash...)
+	data = append(data, common.LeftPadBytes(big.NewInt(64).Bytes(), 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(int64(len(sig))).Bytes(), 32)...)
+	data = append(data, sig...)
+	if rem := len(sig) % 32; rem != 0 {
+		data = append(data, make([]byte, 32-rem)...)
+	}
+	return data
+}
@@ -0,0 +1,107 @@
+package siwe
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWallet is a smart contract wallet at one address that accepts one signature
+type fakeWallet struct {
+	address   common.Address
+	signature []byte
+	calls     int
+}
+
+func (w *fakeWallet) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	if contract == w.address {
+		return []byte{0x60, 0x80}, nil
+	}
+	return nil, nil
+}
+
+func (w *fakeWallet) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	w.calls++
+	if *call.To != w.address {
+		return nil, errors.New("no contract")
+	}
+	if bytes.Contains(call.Data, w.signature) {
+		return common.RightPadBytes(eip1271MagicValue, 32), nil
+	}
+	return nil, errors.New("execution reverted")
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, raw string) string {
+	sig, err := crypto.Sign(accounts.TextHash([]byte(raw)), key)
+	require.NoError(t, err)
+	sig[crypto.RecoveryIDOffset] += 27
+	return hexutil.Encode(sig)
+}
+
+func TestVerifyExternallyOwnedAccount(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	m := testMessage()
+	m.Address = crypto.PubkeyToAddress(key.PublicKey).Hex()
+	raw := m.String()
+
+	verifier := NewVerifier(8453, nil)
+	assert.NoError(t, verifier.Verify(context.Background(), m, raw, sign(t, key, raw)))
+
+	// A signature over different text, or by another key, is rejected
+	assert.ErrorIs(t, verifier.Verify(context.Background(), m, raw, sign(t, key, raw+" ")), ErrInvalidSignature)
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	assert.ErrorIs(t, verifier.Verify(context.Background(), m, raw, sign(t, other, raw)), ErrInvalidSignature)
+	assert.ErrorIs(t, verifier.Verify(context.Background(), m, raw, "0x1234"), ErrInvalidSignature)
+	assert.ErrorIs(t, verifier.Verify(context.Background(), m, raw, "not hex"), ErrInvalidSignature)
+}
+
+func TestVerifySmartContractWallet(t *testing.T) {
+	owner, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	m := testMessage()
+	raw := m.String()
+
+	// A Safe signature is the owners' signatures packed together, not one a key recovers to
+	signature := sign(t, owner, raw)
+	wallet := &fakeWallet{address: common.HexToAddress(m.Address), signature: hexutil.MustDecode(signature)}
+
+	verifier := NewVerifier(8453, wallet)
+	assert.NoError(t, verifier.Verify(context.Background(), m, raw, signature))
+	assert.Equal(t, 1, wallet.calls)
+
+	// The wallet reverts for signatures it does not accept
+	other, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	assert.ErrorIs(t, verifier.Verify(context.Background(), m, raw, sign(t, other, raw)), ErrInvalidSignature)
+
+	// Wallets on other chains cannot be asked
+	m.ChainID = 84532
+	calls := wallet.calls
+	assert.ErrorIs(t, verifier.Verify(context.Background(), m, m.String(), signature), ErrInvalidSignature)
+	assert.Equal(t, calls, wallet.calls)
+}
+
+func TestIsValidSignatureCall(t *testing.T) {
+	hash := accounts.TextHash([]byte("hello"))
+	data := isValidSignatureCall(hash, make([]byte, 65))
+
+	assert.Equal(t, "0x1626ba7e", hexutil.Encode(data[:4]))
+	assert.Equal(t, hash, data[4:36])
+	assert.Equal(t, big.NewInt(64), new(big.Int).SetBytes(data[36:68]))
+	assert.Equal(t, big.NewInt(65), new(big.Int).SetBytes(data[68:100]))
+	// The signature is padded to a whole number of words
+	assert.Len(t, data, 4+32*3+96)
+}
@@ -0,0 +1,113 @@
+package siwe
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrDomainNotAllowed is returned for a message asked for by a site the server does not serve
+	ErrDomainNotAllowed = errors.New("sign-in domain is not allowed")
+	// ErrURIMismatch is returned when the message's URI is not on its domain
+	ErrURIMismatch = errors.New("sign-in URI does not match the domain")
+	// ErrUnsupportedVersion is returned for a message version other than 1
+	ErrUnsupportedVersion = errors.New("unsupported sign-in message version")
+	// ErrChainNotAllowed is returned for a chain ID outside the configured allowlist
+	ErrChainNotAllowed = errors.New("sign-in chain is not allowed")
+	// ErrMessageExpired is returned once a message's expiration time has passed
+	ErrMessageExpired = errors.New("sign-in message has expired")
+	// ErrMessageNotYetValid is returned before a message's not-before time, or for a
+	// message issued in the future
+	ErrMessageNotYetValid = errors.New("sign-in message is not valid yet")
+)
+
+// DefaultClockSkew is how far the wallet's clock may be ahead of the server's
+const DefaultClockSkew = time.Minute
+
+// Config is what the server accepts sign-in messages for
+type Config struct {
+	// Domains lists the hosts, with port if any, of the sites users sign in from
+	Domains []string
+	// ChainIDs lists the chains users may sign in on
+	ChainIDs []int64
+	// ClockSkew bounds the accepted difference between wallet and server clocks
+	ClockSkew time.Duration
+}
+
+// Validate checks the message's fields against the configuration at time now. The
+// nonce and signature are checked by the caller.
+func (m *Message) Validate(config Config, now time.Time) error {
+	if !containsFold(config.Domains, m.Domain) {
+		return fmt.Errorf("%w: %s", ErrDomainNotAllowed, m.Domain)
+	}
+	if m.Scheme != "" && m.Scheme != "https" && m.Scheme != "http" {
+		return fmt.Errorf("%w: scheme %s", ErrDomainNotAllowed, m.Scheme)
+	}
+	uri, err := url.Parse(m.URI)
+	if err != nil || !strings.EqualFold(uri.Host, m.Domain) {
+		return fmt.Errorf("%w: %s is not on %s", ErrURIMismatch, m.URI, m.Domain)
+	}
+	if m.Version != "1" {
+		return fmt.Errorf("%w: %s", ErrUnsupportedVersion, m.Version)
+	}
+
+	allowed := false
+	for _, id := range config.ChainIDs {
+		allowed = allowed || id == m.ChainID
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %d", ErrChainNotAllowed, m.ChainID)
+	}
+
+	skew := config.ClockSkew
+	if skew == 0 {
+		skew = DefaultClockSkew
+	}
+	if m.IssuedAt.After(now.Add(skew)) {
+		return fmt.Errorf("%w: issued at %s", ErrMessageNotYetValid, m.IssuedAt.Format(time.RFC3339))
+	}
+	if m.NotBefore != nil && m.NotBefore.After(now.Add(skew)) {
+		return fmt.Errorf("%w: not before %s", ErrMessageNotYetValid, m.NotBefore.Format(time.RFC3339))
+	}
+	if m.ExpirationTime != nil && !now.Before(*m.ExpirationTime) {
+		return ErrMessageExpired
+	}
+	return nil
+}
+
+// ParseConfig builds a configuration from comma separated lists of domains and chain IDs
+func ParseConfig(domains, chainIDs string) (Config, error) {
+	config := Config{ClockSkew: DefaultClockSkew}
+	for _, domain := range strings.Split(domains, ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			config.Domains = append(config.Domains, domain)
+		}
+	}
+	for _, value := range strings.Split(chainIDs, ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || id <= 0 {
+			return Config{}, fmt.Errorf("invalid chain ID %q", value)
+		}
+		config.ChainIDs = append(config.ChainIDs, id)
+	}
+	if len(config.Domains) == 0 || len(config.ChainIDs) == 0 {
+		return Config{}, errors.New("at least one sign-in domain and chain ID are required")
+	}
+	return config, nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
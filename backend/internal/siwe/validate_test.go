@@ -0,0 +1,63 @@
+package siwe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{
+	Domains:  []string{"app.payverge.io", "localhost:3000"},
+	ChainIDs: []int64{8453, 84532},
+}
+
+func TestValidate(t *testing.T) {
+	issuedAt := testMessage().IssuedAt
+	expires := issuedAt.Add(5 * time.Minute)
+	notBefore := issuedAt.Add(time.Hour)
+
+	cases := []struct {
+		name   string
+		change func(m *Message)
+		now    time.Time
+		err    error
+	}{
+		{"valid", func(m *Message) {}, issuedAt, nil},
+		{"domain case", func(m *Message) { m.Domain, m.URI = "App.Payverge.io", "https://app.payverge.io/login" }, issuedAt, nil},
+		{"with scheme", func(m *Message) { m.Scheme = "https" }, issuedAt, nil},
+		{"phishing domain", func(m *Message) { m.Domain, m.URI = "payverge.evil", "https://payverge.evil" }, issuedAt, ErrDomainNotAllowed},
+		{"bad scheme", func(m *Message) { m.Scheme = "ftp" }, issuedAt, ErrDomainNotAllowed},
+		{"URI elsewhere", func(m *Message) { m.URI = "https://payverge.evil/login" }, issuedAt, ErrURIMismatch},
+		{"version", func(m *Message) { m.Version = "2" }, issuedAt, ErrUnsupportedVersion},
+		{"chain", func(m *Message) { m.ChainID = 1 }, issuedAt, ErrChainNotAllowed},
+		{"issued in the future", func(m *Message) {}, issuedAt.Add(-2 * time.Minute), ErrMessageNotYetValid},
+		{"small clock skew", func(m *Message) {}, issuedAt.Add(-30 * time.Second), nil},
+		{"not before", func(m *Message) { m.NotBefore = &notBefore }, issuedAt, ErrMessageNotYetValid},
+		{"not yet expired", func(m *Message) { m.ExpirationTime = &expires }, expires.Add(-time.Second), nil},
+		{"expired", func(m *Message) { m.ExpirationTime = &expires }, expires, ErrMessageExpired},
+	}
+	for _, tc := range cases {
+		m := testMessage()
+		tc.change(m)
+		err := m.Validate(testConfig, tc.now)
+		if tc.err == nil {
+			assert.NoError(t, err, tc.name)
+		} else {
+			assert.ErrorIs(t, err, tc.err, tc.name)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig(" app.payverge.io, localhost:3000 ,", "8453,84532")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app.payverge.io", "localhost:3000"}, config.Domains)
+	assert.Equal(t, []int64{8453, 84532}, config.ChainIDs)
+
+	_, err = ParseConfig("app.payverge.io", "base")
+	assert.Error(t, err)
+	_, err = ParseConfig("", "8453")
+	assert.Error(t, err)
+}
@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
+
 	"payverge/internal/database"
+	"payverge/internal/money"
 )
 
 // SplittingService handles bill splitting calculations
@@ -21,50 +24,87 @@ func NewSplittingService(db *database.DB) *SplittingService {
 
 // SplitResult represents the result of a bill split calculation
 type SplitResult struct {
-	Method      string                 `json:"method"`
-	TotalAmount float64                `json:"total_amount"`
-	Splits      []PersonSplit          `json:"splits"`
-	Breakdown   map[string]interface{} `json:"breakdown"`
+	Method       string                 `json:"method"`
+	TotalAmount  money.Amount           `json:"total_amount"`
+	TipAmount    money.Amount           `json:"tip_amount"`
+	TotalWithTip money.Amount           `json:"total_with_tip"`
+	Splits       []PersonSplit          `json:"splits"`
+	Breakdown    map[string]interface{} `json:"breakdown"`
 }
 
 // PersonSplit represents one person's portion of the bill
 type PersonSplit struct {
-	PersonID    string  `json:"person_id"`
-	PersonName  string  `json:"person_name,omitempty"`
-	Amount      float64 `json:"amount"`
-	Items       []SplitItem `json:"items,omitempty"`
-	TaxAmount   float64 `json:"tax_amount"`
-	ServiceFee  float64 `json:"service_fee"`
-	Subtotal    float64 `json:"subtotal"`
+	PersonID      string       `json:"person_id"`
+	PersonName    string       `json:"person_name,omitempty"`
+	Amount        money.Amount `json:"amount"` // share of the bill, excluding tip
+	Items         []SplitItem  `json:"items,omitempty"`
+	TaxAmount     money.Amount `json:"tax_amount"`
+	ServiceFee    money.Amount `json:"service_fee"`
+	Subtotal      money.Amount `json:"subtotal"`
+	Weight        float64      `json:"weight,omitempty"` // weight or percentage in weighted splits
+	TipAmount     money.Amount `json:"tip_amount"`
+	AmountWithTip money.Amount `json:"amount_with_tip"`
 }
 
 // SplitItem represents an item in a person's split
 type SplitItem struct {
-	ItemID   string  `json:"item_id"`
-	Name     string  `json:"name"`
-	Price    float64 `json:"price"`
-	Quantity int     `json:"quantity"`
-	Subtotal float64 `json:"subtotal"`
+	ItemID   string       `json:"item_id"`
+	Name     string       `json:"name"`
+	Price    money.Amount `json:"price"`
+	Quantity int          `json:"quantity"`
+	Subtotal money.Amount `json:"subtotal"`
+	Portion  float64      `json:"portion,omitempty"` // fraction of a shared item, omitted when not shared
 }
 
 // EqualSplitRequest represents a request for equal bill splitting
 type EqualSplitRequest struct {
-	BillID    uint `json:"bill_id"`
-	NumPeople int  `json:"num_people"`
+	BillID    uint       `json:"bill_id"`
+	NumPeople int        `json:"num_people"`
+	Tip       *TipPolicy `json:"tip,omitempty"`
 }
 
 // CustomSplitRequest represents a request for custom bill splitting
 type CustomSplitRequest struct {
-	BillID  uint                   `json:"bill_id"`
-	Amounts map[string]float64     `json:"amounts"` // person_id -> amount
-	People  map[string]string      `json:"people"`  // person_id -> name
+	BillID  uint                    `json:"bill_id"`
+	Amounts map[string]money.Amount `json:"amounts"` // person_id -> amount
+	People  map[string]string       `json:"people"`  // person_id -> name
+	Tip     *TipPolicy              `json:"tip,omitempty"`
 }
 
-// ItemSplitRequest represents a request for item-based bill splitting
+// ItemSplitRequest represents a request for item-based bill splitting. An item selected
+// by several people is shared between them, equally unless portions are given.
 type ItemSplitRequest struct {
-	BillID         uint                       `json:"bill_id"`
-	ItemSelections map[string][]string        `json:"item_selections"` // person_id -> [item_ids]
-	People         map[string]string          `json:"people"`          // person_id -> name
+	BillID         uint                          `json:"bill_id"`
+	ItemSelections map[string][]string           `json:"item_selections"`         // person_id -> [item_ids]
+	ItemPortions   map[string]map[string]float64 `json:"item_portions,omitempty"` // person_id -> item_id -> portion
+	People         map[string]string             `json:"people"`                  // person_id -> name
+	Tip            *TipPolicy                    `json:"tip,omitempty"`
+}
+
+// WeightedSplitRequest represents a request for splitting a bill by relative weights
+type WeightedSplitRequest struct {
+	BillID  uint               `json:"bill_id"`
+	Weights map[string]float64 `json:"weights"` // person_id -> weight
+	People  map[string]string  `json:"people"`  // person_id -> name
+	Tip     *TipPolicy         `json:"tip,omitempty"`
+}
+
+// PercentageSplitRequest represents a request for splitting a bill by percentages
+type PercentageSplitRequest struct {
+	BillID      uint               `json:"bill_id"`
+	Percentages map[string]float64 `json:"percentages"` // person_id -> percentage, adding up to 100
+	People      map[string]string  `json:"people"`      // person_id -> name
+	Tip         *TipPolicy         `json:"tip,omitempty"`
+}
+
+// MixedSplitRequest represents a request for splitting claimed items to the people who
+// had them and the rest of the bill equally between everyone
+type MixedSplitRequest struct {
+	BillID         uint                          `json:"bill_id"`
+	ItemSelections map[string][]string           `json:"item_selections"`         // person_id -> [item_ids]
+	ItemPortions   map[string]map[string]float64 `json:"item_portions,omitempty"` // person_id -> item_id -> portion
+	People         map[string]string             `json:"people"`                  // person_id -> name, including people with no items
+	Tip            *TipPolicy                    `json:"tip,omitempty"`
 }
 
 // CalculateEqualSplit calculates equal split for a bill
@@ -79,102 +119,228 @@ func (s *SplittingService) CalculateEqualSplit(billID uint, numPeople int) (*Spl
 		return nil, fmt.Errorf("failed to get bill: %w", err)
 	}
 
-	// Calculate equal split amount
-	amountPerPerson := bill.TotalAmount / float64(numPeople)
-	taxPerPerson := bill.TaxAmount / float64(numPeople)
-	serviceFeePerPerson := bill.ServiceFeeAmount / float64(numPeople)
-	subtotalPerPerson := bill.Subtotal / float64(numPeople)
+	return splitEqual(bill, numPeople), nil
+}
 
-	// Create splits
-	splits := make([]PersonSplit, numPeople)
-	for i := 0; i < numPeople; i++ {
-		splits[i] = PersonSplit{
-			PersonID:   fmt.Sprintf("person_%d", i+1),
-			PersonName: fmt.Sprintf("Person %d", i+1),
-			Amount:     roundToTwoDecimals(amountPerPerson),
-			TaxAmount:  roundToTwoDecimals(taxPerPerson),
-			ServiceFee: roundToTwoDecimals(serviceFeePerPerson),
-			Subtotal:   roundToTwoDecimals(subtotalPerPerson),
-		}
+// CalculateCustomSplit calculates custom split for a bill
+func (s *SplittingService) CalculateCustomSplit(billID uint, amounts map[string]money.Amount, people map[string]string) (*SplitResult, error) {
+	if len(amounts) == 0 {
+		return nil, errors.New("amounts map cannot be empty")
+	}
+
+	// Get bill from database
+	bill, err := s.db.GetBill(billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bill: %w", err)
 	}
 
-	// Handle rounding differences by adjusting the last person's amount
-	totalSplitAmount := 0.0
-	for _, split := range splits {
-		totalSplitAmount += split.Amount
+	return splitCustom(bill, amounts, people)
+}
+
+// CalculateWeightedSplit splits a bill in proportion to each person's weight, e.g. 2:1:1
+func (s *SplittingService) CalculateWeightedSplit(billID uint, weights map[string]float64, people map[string]string) (*SplitResult, error) {
+	if len(weights) == 0 {
+		return nil, errors.New("weights cannot be empty")
 	}
-	
-	if diff := bill.TotalAmount - totalSplitAmount; diff != 0 {
-		splits[len(splits)-1].Amount = roundToTwoDecimals(splits[len(splits)-1].Amount + diff)
+
+	bill, err := s.db.GetBill(billID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bill: %w", err)
 	}
 
-	return &SplitResult{
-		Method:      "equal",
-		TotalAmount: bill.TotalAmount,
-		Splits:      splits,
-		Breakdown: map[string]interface{}{
-			"num_people":           numPeople,
-			"amount_per_person":    roundToTwoDecimals(amountPerPerson),
-			"tax_per_person":       roundToTwoDecimals(taxPerPerson),
-			"service_fee_per_person": roundToTwoDecimals(serviceFeePerPerson),
-		},
-	}, nil
+	return splitWeighted(bill, "weighted", weights, people)
 }
 
-// CalculateCustomSplit calculates custom split for a bill
-func (s *SplittingService) CalculateCustomSplit(billID uint, amounts map[string]float64, people map[string]string) (*SplitResult, error) {
-	if len(amounts) == 0 {
-		return nil, errors.New("amounts map cannot be empty")
+// CalculatePercentageSplit splits a bill by percentages that must add up to 100
+func (s *SplittingService) CalculatePercentageSplit(billID uint, percentages map[string]float64, people map[string]string) (*SplitResult, error) {
+	if len(percentages) == 0 {
+		return nil, errors.New("percentages cannot be empty")
 	}
 
-	// Get bill from database
 	bill, err := s.db.GetBill(billID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get bill: %w", err)
 	}
 
-	// Validate total amounts
-	totalCustomAmount := 0.0
-	for _, amount := range amounts {
-		if amount < 0 {
-			return nil, errors.New("amounts cannot be negative")
+	return splitWeighted(bill, "percentage", percentages, people)
+}
+
+// CalculateItemSplit calculates item-based split for a bill. Every item must be selected
+// by someone; items selected by several people are shared according to their portions.
+func (s *SplittingService) CalculateItemSplit(billID uint, itemSelections map[string][]string, portions map[string]map[string]float64, people map[string]string) (*SplitResult, error) {
+	if len(itemSelections) == 0 {
+		return nil, errors.New("item selections cannot be empty")
+	}
+
+	bill, billItems, err := s.billWithItems(billID)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitItems(bill, billItems, itemSelections, portions, people, false)
+}
+
+// CalculateMixedSplit charges claimed items to the people who had them and splits the
+// unclaimed items equally between everyone in people and itemSelections
+func (s *SplittingService) CalculateMixedSplit(billID uint, itemSelections map[string][]string, portions map[string]map[string]float64, people map[string]string) (*SplitResult, error) {
+	if len(itemSelections) == 0 && len(people) == 0 {
+		return nil, errors.New("people cannot be empty")
+	}
+
+	bill, billItems, err := s.billWithItems(billID)
+	if err != nil {
+		return nil, err
+	}
+
+	return splitItems(bill, billItems, itemSelections, portions, people, true)
+}
+
+// billWithItems loads a bill and its parsed items
+func (s *SplittingService) billWithItems(billID uint) (*database.Bill, []database.BillItem, error) {
+	bill, err := s.db.GetBill(billID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get bill: %w", err)
+	}
+
+	billItems, err := s.db.GetBillItems(billID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get bill items: %w", err)
+	}
+	return bill, billItems, nil
+}
+
+// weightScale converts fractional weights, percentages and portions to the integer
+// weights used for allocation
+const weightScale = 1_000_000
+
+// maxWeight bounds a single weight so that scaled weights cannot overflow
+const maxWeight = 1_000_000
+
+// participant is a person taking part in a split
+type participant struct {
+	id   string
+	name string
+}
+
+// participantsOf returns the people with the given IDs sorted by ID, so that splits
+// and the allocation of leftover cents are deterministic
+func participantsOf(ids []string, people map[string]string) []participant {
+	sorted := append([]string(nil), ids...)
+	sort.Strings(sorted)
+
+	result := make([]participant, len(sorted))
+	for i, id := range sorted {
+		name := people[id]
+		if name == "" {
+			name = id
 		}
-		totalCustomAmount += amount
+		result[i] = participant{id: id, name: name}
 	}
+	return result
+}
 
-	if math.Abs(totalCustomAmount-bill.TotalAmount) > 0.01 {
-		return nil, fmt.Errorf("custom amounts total (%.2f) does not match bill total (%.2f)", 
-			totalCustomAmount, bill.TotalAmount)
+// toWeight scales a non-negative fractional weight to an integer allocation weight
+func toWeight(value float64) (int64, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+		return 0, errors.New("weights cannot be negative")
+	}
+	if value > maxWeight {
+		return 0, fmt.Errorf("weight %g is too large", value)
+	}
+	return int64(math.Round(value * weightScale)), nil
+}
+
+// shareOut builds one split per participant by allocating the bill's total, subtotal,
+// tax and service fee in proportion to weights. Each figure is allocated on its own
+// with the largest remainder method, so every column adds up exactly to the bill.
+// When all weights are zero the bill is shared equally.
+func shareOut(bill *database.Bill, people []participant, weights []int64) []PersonSplit {
+	var sum int64
+	for _, w := range weights {
+		sum += w
 	}
+	if sum == 0 {
+		weights = make([]int64, len(people))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+
+	totals := bill.TotalAmount.Allocate(weights)
+	subtotals := bill.Subtotal.Allocate(weights)
+	taxes := bill.TaxAmount.Allocate(weights)
+	serviceFees := bill.ServiceFeeAmount.Allocate(weights)
+
+	splits := make([]PersonSplit, len(people))
+	for i, person := range people {
+		splits[i] = PersonSplit{
+			PersonID:   person.id,
+			PersonName: person.name,
+			Amount:     totals[i],
+			TaxAmount:  taxes[i],
+			ServiceFee: serviceFees[i],
+			Subtotal:   subtotals[i],
+		}
+	}
+	return splits
+}
+
+// splitEqual splits a bill equally; any leftover cents go to the first people
+func splitEqual(bill *database.Bill, numPeople int) *SplitResult {
+	people := make([]participant, numPeople)
+	weights := make([]int64, numPeople)
+	for i := range people {
+		people[i] = participant{
+			id:   fmt.Sprintf("person_%d", i+1),
+			name: fmt.Sprintf("Person %d", i+1),
+		}
+		weights[i] = 1
+	}
+
+	splits := shareOut(bill, people, weights)
+	last := splits[len(splits)-1]
+
+	return &SplitResult{
+		Method:      "equal",
+		TotalAmount: bill.TotalAmount,
+		Splits:      splits,
+		Breakdown: map[string]interface{}{
+			"num_people":             numPeople,
+			"amount_per_person":      last.Amount,
+			"tax_per_person":         last.TaxAmount,
+			"service_fee_per_person": last.ServiceFee,
+		},
+	}
+}
 
-	// Create splits
-	splits := make([]PersonSplit, 0, len(amounts))
+// splitCustom uses the amounts people chose. They may be a cent off the bill total;
+// allocating the bill in proportion to them keeps the shares adding up exactly.
+func splitCustom(bill *database.Bill, amounts map[string]money.Amount, people map[string]string) (*SplitResult, error) {
+	var totalCustomAmount money.Amount
+	ids := make([]string, 0, len(amounts))
 	for personID, amount := range amounts {
-		personName := people[personID]
-		if personName == "" {
-			personName = personID
+		if amount < 0 {
+			return nil, errors.New("amounts cannot be negative")
 		}
+		totalCustomAmount += amount
+		ids = append(ids, personID)
+	}
 
-		// Calculate proportional tax and service fee
-		proportion := amount / bill.TotalAmount
-		taxAmount := bill.TaxAmount * proportion
-		serviceFee := bill.ServiceFeeAmount * proportion
-		subtotal := bill.Subtotal * proportion
+	if (totalCustomAmount - bill.TotalAmount).Abs() > money.FromCents(1) {
+		return nil, fmt.Errorf("custom amounts total (%s) does not match bill total (%s)",
+			totalCustomAmount, bill.TotalAmount)
+	}
 
-		splits = append(splits, PersonSplit{
-			PersonID:   personID,
-			PersonName: personName,
-			Amount:     roundToTwoDecimals(amount),
-			TaxAmount:  roundToTwoDecimals(taxAmount),
-			ServiceFee: roundToTwoDecimals(serviceFee),
-			Subtotal:   roundToTwoDecimals(subtotal),
-		})
+	participants := participantsOf(ids, people)
+	weights := make([]int64, len(participants))
+	for i, person := range participants {
+		weights[i] = amounts[person.id].Cents()
 	}
 
 	return &SplitResult{
 		Method:      "custom",
 		TotalAmount: bill.TotalAmount,
-		Splits:      splits,
+		Splits:      shareOut(bill, participants, weights),
 		Breakdown: map[string]interface{}{
 			"num_people":     len(amounts),
 			"custom_amounts": amounts,
@@ -182,106 +348,192 @@ func (s *SplittingService) CalculateCustomSplit(billID uint, amounts map[string]
 	}, nil
 }
 
-// CalculateItemSplit calculates item-based split for a bill
-func (s *SplittingService) CalculateItemSplit(billID uint, itemSelections map[string][]string, people map[string]string) (*SplitResult, error) {
-	if len(itemSelections) == 0 {
-		return nil, errors.New("item selections cannot be empty")
+// splitWeighted splits a bill in proportion to weights. For the percentage method the
+// weights are percentages and must add up to 100.
+func splitWeighted(bill *database.Bill, method string, weights map[string]float64, people map[string]string) (*SplitResult, error) {
+	ids := make([]string, 0, len(weights))
+	for personID := range weights {
+		ids = append(ids, personID)
+	}
+	participants := participantsOf(ids, people)
+
+	scaled := make([]int64, len(participants))
+	var sum int64
+	for i, person := range participants {
+		w, err := toWeight(weights[person.id])
+		if err != nil {
+			return nil, err
+		}
+		scaled[i] = w
+		sum += w
 	}
 
-	// Get bill from database
-	bill, err := s.db.GetBill(billID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get bill: %w", err)
+	if method == "percentage" {
+		// Allow for percentages such as 33.33 that cannot add up to exactly 100
+		if diff := sum - 100*weightScale; diff > weightScale/100 || diff < -weightScale/100 {
+			return nil, fmt.Errorf("percentages must add up to 100, got %g", float64(sum)/weightScale)
+		}
+	} else if sum == 0 {
+		return nil, errors.New("at least one weight must be greater than 0")
 	}
 
-	// Parse bill items
-	billItems, err := s.db.GetBillItems(billID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get bill items: %w", err)
+	splits := shareOut(bill, participants, scaled)
+	for i := range splits {
+		splits[i].Weight = weights[splits[i].PersonID]
 	}
 
-	// Create item lookup map
-	itemMap := make(map[string]database.BillItem)
+	return &SplitResult{
+		Method:      method,
+		TotalAmount: bill.TotalAmount,
+		Splits:      splits,
+		Breakdown: map[string]interface{}{
+			"num_people": len(weights),
+			"weights":    weights,
+		},
+	}, nil
+}
+
+// splitItems charges every item to the people who selected it, sharing an item between
+// several people by their portions (equally by default). In mixed mode, items nobody
+// selected are shared equally between all participants; otherwise they are an error.
+// Each person's share of the item subtotals then weighs their part of the bill total,
+// so tax, service fee and any difference between items and total are shared fairly.
+func splitItems(bill *database.Bill, billItems []database.BillItem, itemSelections map[string][]string, portions map[string]map[string]float64, people map[string]string, mixed bool) (*SplitResult, error) {
+	itemMap := make(map[string]database.BillItem, len(billItems))
 	for _, item := range billItems {
+		if item.Subtotal < 0 {
+			return nil, fmt.Errorf("item %s has a negative subtotal", item.ID)
+		}
 		itemMap[item.ID] = item
 	}
 
-	// Validate all selected items exist
-	allSelectedItems := make(map[string]bool)
-	for _, itemIDs := range itemSelections {
-		for _, itemID := range itemIDs {
-			if _, exists := itemMap[itemID]; !exists {
-				return nil, fmt.Errorf("item with ID %s not found in bill", itemID)
+	ids := make([]string, 0, len(itemSelections)+len(people))
+	seen := make(map[string]bool)
+	for personID := range itemSelections {
+		ids = append(ids, personID)
+		seen[personID] = true
+	}
+	if mixed {
+		for personID := range people {
+			if !seen[personID] {
+				ids = append(ids, personID)
 			}
-			allSelectedItems[itemID] = true
 		}
 	}
+	participants := participantsOf(ids, people)
+	if len(participants) == 0 {
+		return nil, errors.New("split has no participants")
+	}
 
-	// Ensure all bill items are selected by someone
-	for _, item := range billItems {
-		if !allSelectedItems[item.ID] {
-			return nil, fmt.Errorf("item %s (%s) is not assigned to anyone", item.ID, item.Name)
+	// Collect who selected each item, in participant order
+	claimants := make(map[string][]int)
+	for i, person := range participants {
+		selected := make(map[string]bool)
+		for _, itemID := range itemSelections[person.id] {
+			if _, exists := itemMap[itemID]; !exists {
+				return nil, fmt.Errorf("item with ID %s not found in bill", itemID)
+			}
+			if selected[itemID] {
+				continue
+			}
+			selected[itemID] = true
+			claimants[itemID] = append(claimants[itemID], i)
 		}
 	}
 
-	// Calculate splits
-	splits := make([]PersonSplit, 0, len(itemSelections))
-	totalItemsSubtotal := 0.0
+	subtotals := make([]money.Amount, len(participants))
+	personItems := make([][]SplitItem, len(participants))
+	var sharedItems, leftoverItems []string
+	var leftoverSubtotal money.Amount
 
-	for personID, itemIDs := range itemSelections {
-		personName := people[personID]
-		if personName == "" {
-			personName = personID
+	for _, item := range billItems {
+		sharers := claimants[item.ID]
+		weights := make([]int64, len(sharers))
+		for j, i := range sharers {
+			portion := 1.0
+			if p, ok := portions[participants[i].id][item.ID]; ok {
+				portion = p
+			}
+			w, err := toWeight(portion)
+			if err != nil {
+				return nil, fmt.Errorf("invalid portion of item %s: %w", item.ID, err)
+			}
+			if w == 0 {
+				return nil, fmt.Errorf("portion of item %s for %s must be greater than 0", item.ID, participants[i].id)
+			}
+			weights[j] = w
 		}
 
-		personSubtotal := 0.0
-		personItems := make([]SplitItem, 0, len(itemIDs))
+		if len(sharers) == 0 {
+			if !mixed {
+				return nil, fmt.Errorf("item %s (%s) is not assigned to anyone", item.ID, item.Name)
+			}
+			// Unclaimed items are shared equally by everyone
+			sharers = make([]int, len(participants))
+			weights = make([]int64, len(participants))
+			for i := range participants {
+				sharers[i] = i
+				weights[i] = 1
+			}
+			leftoverItems = append(leftoverItems, item.ID)
+			leftoverSubtotal += item.Subtotal
+		} else if len(sharers) > 1 {
+			sharedItems = append(sharedItems, item.ID)
+		}
 
-		for _, itemID := range itemIDs {
-			item := itemMap[itemID]
-			personSubtotal += item.Subtotal
-			
-			personItems = append(personItems, SplitItem{
+		var weightSum int64
+		for _, w := range weights {
+			weightSum += w
+		}
+		parts := item.Subtotal.Allocate(weights)
+		for j, i := range sharers {
+			splitItem := SplitItem{
 				ItemID:   item.ID,
 				Name:     item.Name,
 				Price:    item.Price,
 				Quantity: item.Quantity,
-				Subtotal: item.Subtotal,
-			})
+				Subtotal: parts[j],
+			}
+			if len(sharers) > 1 {
+				splitItem.Portion = float64(weights[j]) / float64(weightSum)
+			}
+			personItems[i] = append(personItems[i], splitItem)
+			subtotals[i] += parts[j]
 		}
+	}
 
-		totalItemsSubtotal += personSubtotal
+	weights := make([]int64, len(participants))
+	var totalItemsSubtotal money.Amount
+	for i, subtotal := range subtotals {
+		weights[i] = subtotal.Cents()
+		totalItemsSubtotal += subtotal
+	}
 
-		// Calculate proportional tax and service fee
-		proportion := personSubtotal / bill.Subtotal
-		taxAmount := bill.TaxAmount * proportion
-		serviceFee := bill.ServiceFeeAmount * proportion
-		totalAmount := personSubtotal + taxAmount + serviceFee
+	splits := shareOut(bill, participants, weights)
+	for i := range splits {
+		splits[i].Items = personItems[i]
+		if splits[i].Items == nil {
+			splits[i].Items = []SplitItem{}
+		}
+	}
 
-		splits = append(splits, PersonSplit{
-			PersonID:   personID,
-			PersonName: personName,
-			Amount:     roundToTwoDecimals(totalAmount),
-			Items:      personItems,
-			TaxAmount:  roundToTwoDecimals(taxAmount),
-			ServiceFee: roundToTwoDecimals(serviceFee),
-			Subtotal:   roundToTwoDecimals(personSubtotal),
-		})
+	method := "items"
+	breakdown := map[string]interface{}{
+		"num_people":           len(participants),
+		"total_items_subtotal": totalItemsSubtotal,
+		"item_assignments":     itemSelections,
+		"shared_items":         sharedItems,
+	}
+	if mixed {
+		method = "mixed"
+		breakdown["leftover_items"] = leftoverItems
+		breakdown["leftover_subtotal"] = leftoverSubtotal
 	}
 
 	return &SplitResult{
-		Method:      "items",
+		Method:      method,
 		TotalAmount: bill.TotalAmount,
 		Splits:      splits,
-		Breakdown: map[string]interface{}{
-			"num_people":           len(itemSelections),
-			"total_items_subtotal": totalItemsSubtotal,
-			"item_assignments":     itemSelections,
-		},
+		Breakdown:   breakdown,
 	}, nil
 }
-
-// roundToTwoDecimals rounds a float64 to 2 decimal places
-func roundToTwoDecimals(value float64) float64 {
-	return math.Round(value*100) / 100
-}
@@ -0,0 +1,330 @@
+package splitting
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"payverge/internal/database"
+	"payverge/internal/money"
+)
+
+var propertyConfig = &quick.Config{MaxCount: 500}
+
+// randomBill builds a bill with a few items and tax and service fee on top
+func randomBill(r *rand.Rand) (*database.Bill, []database.BillItem) {
+	items := make([]database.BillItem, 1+r.Intn(8))
+	var subtotal money.Amount
+	for i := range items {
+		price := money.FromCents(1 + r.Int63n(5000))
+		quantity := 1 + r.Intn(3)
+		items[i] = database.BillItem{
+			ID:       fmt.Sprintf("item_%d", i+1),
+			Name:     fmt.Sprintf("Item %d", i+1),
+			Price:    price,
+			Quantity: quantity,
+			Subtotal: price.Mul(quantity),
+		}
+		subtotal += items[i].Subtotal
+	}
+
+	bill := &database.Bill{
+		Subtotal:         subtotal,
+		TaxAmount:        subtotal.Percent(float64(r.Intn(16))),
+		ServiceFeeAmount: subtotal.Percent(float64(r.Intn(21))),
+	}
+	bill.TotalAmount = bill.Subtotal + bill.TaxAmount + bill.ServiceFeeAmount
+	return bill, items
+}
+
+func randomPeople(r *rand.Rand) []string {
+	people := make([]string, 1+r.Intn(8))
+	for i := range people {
+		people[i] = fmt.Sprintf("p%d", i+1)
+	}
+	return people
+}
+
+// addsUp reports whether every column of the split adds up exactly to the bill
+func addsUp(bill *database.Bill, result *SplitResult) bool {
+	var total, subtotal, tax, fee money.Amount
+	for _, split := range result.Splits {
+		total += split.Amount
+		subtotal += split.Subtotal
+		tax += split.TaxAmount
+		fee += split.ServiceFee
+	}
+	return total == bill.TotalAmount && subtotal == bill.Subtotal &&
+		tax == bill.TaxAmount && fee == bill.ServiceFeeAmount
+}
+
+// withinACent reports whether amount is within a cent of total*weight/sum
+func withinACent(amount, total money.Amount, weight, sum float64) bool {
+	exact := float64(total.Cents()) * weight / sum
+	diff := float64(amount.Cents()) - exact
+	return diff > -1.0001 && diff < 1.0001
+}
+
+func TestEqualSplitProperties(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		bill, _ := randomBill(r)
+		result := splitEqual(bill, 1+r.Intn(20))
+
+		lowest, highest := result.Splits[0].Amount, result.Splits[0].Amount
+		for _, split := range result.Splits {
+			if split.Amount < lowest {
+				lowest = split.Amount
+			}
+			if split.Amount > highest {
+				highest = split.Amount
+			}
+		}
+		return addsUp(bill, result) && highest-lowest <= 1
+	}
+	require.NoError(t, quick.Check(property, propertyConfig))
+}
+
+func TestEqualSplitSpreadsResidue(t *testing.T) {
+	bill := &database.Bill{TotalAmount: money.MustParse("100.00"), Subtotal: money.MustParse("100.00")}
+	result := splitEqual(bill, 3)
+
+	assert.Equal(t, money.MustParse("33.34"), result.Splits[0].Amount)
+	assert.Equal(t, money.MustParse("33.33"), result.Splits[1].Amount)
+	assert.Equal(t, money.MustParse("33.33"), result.Splits[2].Amount)
+}
+
+func TestCustomSplitProperties(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		bill, _ := randomBill(r)
+		people := randomPeople(r)
+
+		// Hand out the total at random, then be a cent off as guests typing amounts may be
+		amounts := make(map[string]money.Amount, len(people))
+		remaining := bill.TotalAmount
+		for i, person := range people {
+			amount := remaining
+			if i < len(people)-1 {
+				amount = money.FromCents(r.Int63n(remaining.Cents() + 1))
+			}
+			amounts[person] = amount
+			remaining -= amount
+		}
+		if amounts[people[0]] > 0 {
+			amounts[people[0]] -= money.FromCents(r.Int63n(2))
+		}
+
+		result, err := splitCustom(bill, amounts, nil)
+		if err != nil || !addsUp(bill, result) {
+			return false
+		}
+		for _, split := range result.Splits {
+			if (split.Amount - amounts[split.PersonID]).Abs() > 1 {
+				return false
+			}
+		}
+		return true
+	}
+	require.NoError(t, quick.Check(property, propertyConfig))
+}
+
+func TestWeightedSplitProperties(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		bill, _ := randomBill(r)
+
+		weights := make(map[string]float64)
+		var sum float64
+		for _, person := range randomPeople(r) {
+			weights[person] = float64(r.Intn(5)) + float64(r.Intn(4))/4
+			sum += weights[person]
+		}
+		result, err := splitWeighted(bill, "weighted", weights, nil)
+		if sum == 0 {
+			return err != nil
+		}
+		if err != nil || !addsUp(bill, result) {
+			return false
+		}
+		for _, split := range result.Splits {
+			if !withinACent(split.Amount, bill.TotalAmount, weights[split.PersonID], sum) {
+				return false
+			}
+		}
+		return true
+	}
+	require.NoError(t, quick.Check(property, propertyConfig))
+}
+
+func TestPercentageSplitProperties(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		bill, _ := randomBill(r)
+		people := randomPeople(r)
+
+		// Percentages with two decimals that add up to exactly 100
+		percentages := make(map[string]float64, len(people))
+		remaining := 10000
+		for i, person := range people {
+			share := remaining
+			if i < len(people)-1 {
+				share = r.Intn(remaining + 1)
+			}
+			percentages[person] = float64(share) / 100
+			remaining -= share
+		}
+
+		result, err := splitWeighted(bill, "percentage", percentages, nil)
+		if err != nil || !addsUp(bill, result) {
+			return false
+		}
+		for _, split := range result.Splits {
+			if !withinACent(split.Amount, bill.TotalAmount, percentages[split.PersonID], 100) {
+				return false
+			}
+		}
+		return true
+	}
+	require.NoError(t, quick.Check(property, propertyConfig))
+}
+
+func TestPercentageSplitMustAddUpTo100(t *testing.T) {
+	bill := &database.Bill{TotalAmount: money.MustParse("90.00"), Subtotal: money.MustParse("90.00")}
+
+	_, err := splitWeighted(bill, "percentage", map[string]float64{"a": 50, "b": 40}, nil)
+	assert.Error(t, err)
+
+	result, err := splitWeighted(bill, "percentage", map[string]float64{"a": 33.33, "b": 33.33, "c": 33.34}, nil)
+	require.NoError(t, err)
+	assert.True(t, addsUp(bill, result))
+}
+
+// randomSelections assigns every item to one or more people, sometimes with portions
+func randomSelections(r *rand.Rand, items []database.BillItem, people []string, skip bool) (map[string][]string, map[string]map[string]float64) {
+	selections := make(map[string][]string)
+	portions := make(map[string]map[string]float64)
+	for _, item := range items {
+		if skip && r.Intn(3) == 0 {
+			continue
+		}
+		for _, i := range r.Perm(len(people))[:1+r.Intn(len(people))] {
+			person := people[i]
+			selections[person] = append(selections[person], item.ID)
+			if r.Intn(2) == 0 {
+				if portions[person] == nil {
+					portions[person] = make(map[string]float64)
+				}
+				portions[person][item.ID] = float64(1+r.Intn(4)) / 4
+			}
+		}
+	}
+	return selections, portions
+}
+
+// itemsAddUp reports whether the shares of every item add up to the item's subtotal and
+// each person's subtotal is exactly the sum of their item shares
+func itemsAddUp(items []database.BillItem, result *SplitResult) bool {
+	perItem := make(map[string]money.Amount)
+	for _, split := range result.Splits {
+		var subtotal money.Amount
+		for _, item := range split.Items {
+			perItem[item.ItemID] += item.Subtotal
+			subtotal += item.Subtotal
+		}
+		if subtotal != split.Subtotal {
+			return false
+		}
+	}
+	for _, item := range items {
+		if perItem[item.ID] != item.Subtotal {
+			return false
+		}
+	}
+	return true
+}
+
+func TestItemSplitProperties(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		bill, items := randomBill(r)
+		selections, portions := randomSelections(r, items, randomPeople(r), false)
+
+		result, err := splitItems(bill, items, selections, portions, nil, false)
+		return err == nil && addsUp(bill, result) && itemsAddUp(items, result)
+	}
+	require.NoError(t, quick.Check(property, propertyConfig))
+}
+
+func TestItemSplitSharesItem(t *testing.T) {
+	items := []database.BillItem{
+		{ID: "appetizer", Name: "Nachos", Price: money.MustParse("10.00"), Quantity: 1, Subtotal: money.MustParse("10.00")},
+		{ID: "main", Name: "Burger", Price: money.MustParse("15.00"), Quantity: 1, Subtotal: money.MustParse("15.00")},
+	}
+	bill := &database.Bill{Subtotal: money.MustParse("25.00"), TotalAmount: money.MustParse("25.00")}
+
+	result, err := splitItems(bill, items, map[string][]string{
+		"a": {"appetizer", "main"},
+		"b": {"appetizer"},
+		"c": {"appetizer"},
+	}, nil, nil, false)
+	require.NoError(t, err)
+
+	assert.Equal(t, money.MustParse("18.34"), result.Splits[0].Amount)
+	assert.Equal(t, money.MustParse("3.33"), result.Splits[1].Amount)
+	assert.Equal(t, money.MustParse("3.33"), result.Splits[2].Amount)
+	assert.InDelta(t, 1.0/3, result.Splits[1].Items[0].Portion, 1e-9)
+
+	result, err = splitItems(bill, items, map[string][]string{
+		"a": {"appetizer", "main"},
+		"b": {"appetizer"},
+	}, map[string]map[string]float64{"a": {"appetizer": 0.75}, "b": {"appetizer": 0.25}}, nil, false)
+	require.NoError(t, err)
+	assert.Equal(t, money.MustParse("22.50"), result.Splits[0].Amount)
+	assert.Equal(t, money.MustParse("2.50"), result.Splits[1].Amount)
+
+	_, err = splitItems(bill, items, map[string][]string{"a": {"appetizer"}}, nil, nil, false)
+	assert.Error(t, err, "unassigned items are rejected outside mixed mode")
+}
+
+func TestMixedSplitProperties(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		bill, items := randomBill(r)
+		people := randomPeople(r)
+		selections, portions := randomSelections(r, items, people, true)
+
+		names := make(map[string]string, len(people))
+		for _, person := range people {
+			names[person] = person
+		}
+		result, err := splitItems(bill, items, selections, portions, names, true)
+		if err != nil || len(result.Splits) != len(people) {
+			return false
+		}
+		return addsUp(bill, result) && itemsAddUp(items, result)
+	}
+	require.NoError(t, quick.Check(property, propertyConfig))
+}
+
+func TestMixedSplitSharesLeftovers(t *testing.T) {
+	items := []database.BillItem{
+		{ID: "wine", Name: "Wine", Price: money.MustParse("30.00"), Quantity: 1, Subtotal: money.MustParse("30.00")},
+		{ID: "steak", Name: "Steak", Price: money.MustParse("25.00"), Quantity: 1, Subtotal: money.MustParse("25.00")},
+	}
+	bill := &database.Bill{Subtotal: money.MustParse("55.00"), TotalAmount: money.MustParse("55.00")}
+
+	result, err := splitItems(bill, items, map[string][]string{"a": {"steak"}},
+		nil, map[string]string{"a": "Ann", "b": "Ben", "c": "Cat"}, true)
+	require.NoError(t, err)
+	require.Len(t, result.Splits, 3)
+
+	assert.Equal(t, "mixed", result.Method)
+	assert.Equal(t, money.MustParse("35.00"), result.Splits[0].Amount)
+	assert.Equal(t, money.MustParse("10.00"), result.Splits[1].Amount)
+	assert.Equal(t, money.MustParse("10.00"), result.Splits[2].Amount)
+	assert.Equal(t, "Ben", result.Splits[1].PersonName)
+}
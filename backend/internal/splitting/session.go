@@ -0,0 +1,128 @@
+package splitting
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"payverge/internal/database"
+	"payverge/internal/money"
+)
+
+// Recalculate recomputes a split proposed by a client from the bill's current data, so
+// the shares that get stored never depend on amounts the client sent. Tips are the
+// guests' choice: without a tip policy, the tips on the proposed split are kept.
+func (s *SplittingService) Recalculate(billID uint, proposed *SplitResult, tip *TipPolicy) (*SplitResult, error) {
+	if proposed == nil || len(proposed.Splits) == 0 {
+		return nil, errors.New("split has no participants")
+	}
+
+	people := make(map[string]string, len(proposed.Splits))
+	for _, split := range proposed.Splits {
+		if split.PersonID == "" {
+			return nil, errors.New("every split needs a person ID")
+		}
+		if _, dup := people[split.PersonID]; dup {
+			return nil, fmt.Errorf("person %s appears more than once", split.PersonID)
+		}
+		people[split.PersonID] = split.PersonName
+	}
+
+	var result *SplitResult
+	var err error
+	switch proposed.Method {
+	case "equal":
+		result, err = s.CalculateEqualSplit(billID, len(proposed.Splits))
+		if err == nil {
+			// Keep the names and IDs the guests agreed on
+			for i := range result.Splits {
+				result.Splits[i].PersonID = proposed.Splits[i].PersonID
+				if name := proposed.Splits[i].PersonName; name != "" {
+					result.Splits[i].PersonName = name
+				}
+			}
+		}
+	case "custom":
+		amounts := make(map[string]money.Amount, len(proposed.Splits))
+		for _, split := range proposed.Splits {
+			amounts[split.PersonID] = split.Amount
+		}
+		result, err = s.CalculateCustomSplit(billID, amounts, people)
+	case "weighted", "percentage":
+		weights := make(map[string]float64, len(proposed.Splits))
+		for _, split := range proposed.Splits {
+			weights[split.PersonID] = split.Weight
+		}
+		if proposed.Method == "percentage" {
+			result, err = s.CalculatePercentageSplit(billID, weights, people)
+		} else {
+			result, err = s.CalculateWeightedSplit(billID, weights, people)
+		}
+	case "items", "mixed":
+		selections := make(map[string][]string, len(proposed.Splits))
+		portions := make(map[string]map[string]float64)
+		for _, split := range proposed.Splits {
+			for _, item := range split.Items {
+				selections[split.PersonID] = append(selections[split.PersonID], item.ItemID)
+				if item.Portion > 0 {
+					if portions[split.PersonID] == nil {
+						portions[split.PersonID] = make(map[string]float64)
+					}
+					portions[split.PersonID][item.ItemID] = item.Portion
+				}
+			}
+		}
+		if proposed.Method == "mixed" {
+			result, err = s.CalculateMixedSplit(billID, selections, portions, people)
+		} else {
+			result, err = s.CalculateItemSplit(billID, selections, portions, people)
+		}
+	default:
+		return nil, fmt.Errorf("invalid split method %q", proposed.Method)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if tip == nil {
+		tip = perPersonTips(proposed)
+	}
+	if err := ApplyTip(result, tip); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreateSession stores a split so that each share can be matched to the payments made
+// for it. payers maps person IDs to the wallet address paying that share, if known.
+func (s *SplittingService) CreateSession(billID uint, result *SplitResult, payers map[string]string, createdBy string) (*database.SplitSession, error) {
+	session := &database.SplitSession{
+		BillID:    billID,
+		Method:    result.Method,
+		CreatedBy: createdBy,
+		Shares:    make([]database.SplitShare, 0, len(result.Splits)),
+	}
+
+	for _, split := range result.Splits {
+		items, err := json.Marshal(split.Items)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal split items: %w", err)
+		}
+		session.Shares = append(session.Shares, database.SplitShare{
+			PersonID:   split.PersonID,
+			PersonName: split.PersonName,
+			PayerAddr:  payers[split.PersonID],
+			Amount:     split.Amount,
+			TipAmount:  split.TipAmount,
+			Subtotal:   split.Subtotal,
+			TaxAmount:  split.TaxAmount,
+			ServiceFee: split.ServiceFee,
+			Items:      database.JSON(items),
+		})
+	}
+
+	if err := s.db.CreateSplitSession(session); err != nil {
+		return nil, err
+	}
+	return s.db.GetSplitSession(billID)
+}
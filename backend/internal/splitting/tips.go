@@ -0,0 +1,102 @@
+package splitting
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"payverge/internal/money"
+)
+
+// Tip policy modes
+const (
+	TipModePercentage = "percentage" // the same percentage of everyone's share
+	TipModePerPerson  = "per_person" // each person chooses their own tip
+	TipModeGroup      = "group"      // a fixed tip shared in proportion to each share
+)
+
+// TipPolicy describes how a split adds a tip on top of each person's share
+type TipPolicy struct {
+	Mode       string                  `json:"mode"`
+	Percentage float64                 `json:"percentage,omitempty"` // for percentage tips
+	Amounts    map[string]money.Amount `json:"amounts,omitempty"`    // person_id -> tip, for per-person tips
+	Amount     money.Amount            `json:"amount,omitempty"`     // for group tips
+}
+
+// ApplyTip adds the tips of a policy to a split. A nil policy leaves everyone without a
+// tip. Percentage and group tips are computed for the whole group and allocated in
+// proportion to each share, so the individual tips always add up to the group's tip.
+func ApplyTip(result *SplitResult, policy *TipPolicy) error {
+	tips := make([]money.Amount, len(result.Splits))
+
+	if policy != nil {
+		weights := make([]int64, len(result.Splits))
+		var sum int64
+		for i, split := range result.Splits {
+			weights[i] = split.Amount.Cents()
+			sum += weights[i]
+		}
+		if sum <= 0 {
+			for i := range weights {
+				weights[i] = 1
+			}
+		}
+
+		switch policy.Mode {
+		case TipModePercentage:
+			if math.IsNaN(policy.Percentage) || policy.Percentage < 0 || policy.Percentage > 100 {
+				return errors.New("tip percentage must be between 0 and 100")
+			}
+			if len(tips) > 0 {
+				tips = result.TotalAmount.Percent(policy.Percentage).Allocate(weights)
+			}
+		case TipModePerPerson:
+			known := make(map[string]bool, len(result.Splits))
+			for i, split := range result.Splits {
+				known[split.PersonID] = true
+				tips[i] = policy.Amounts[split.PersonID]
+				if tips[i] < 0 {
+					return errors.New("tips cannot be negative")
+				}
+			}
+			for personID := range policy.Amounts {
+				if !known[personID] {
+					return fmt.Errorf("tip given for unknown person %s", personID)
+				}
+			}
+		case TipModeGroup:
+			if policy.Amount < 0 {
+				return errors.New("tips cannot be negative")
+			}
+			if len(tips) > 0 {
+				tips = policy.Amount.Allocate(weights)
+			}
+		default:
+			return fmt.Errorf("invalid tip mode %q", policy.Mode)
+		}
+	}
+
+	result.TipAmount = 0
+	for i := range result.Splits {
+		result.Splits[i].TipAmount = tips[i]
+		result.Splits[i].AmountWithTip = result.Splits[i].Amount + tips[i]
+		result.TipAmount += tips[i]
+	}
+	result.TotalWithTip = result.TotalAmount + result.TipAmount
+	return nil
+}
+
+// perPersonTips returns the tips already on a split as a per-person policy, or nil if
+// nobody is tipping
+func perPersonTips(result *SplitResult) *TipPolicy {
+	amounts := make(map[string]money.Amount)
+	for _, split := range result.Splits {
+		if split.TipAmount != 0 {
+			amounts[split.PersonID] = split.TipAmount
+		}
+	}
+	if len(amounts) == 0 {
+		return nil
+	}
+	return &TipPolicy{Mode: TipModePerPerson, Amounts: amounts}
+}
@@ -0,0 +1,98 @@
+package splitting
+
+import (
+	"math/rand"
+	"testing"
+	"testing/quick"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"payverge/internal/database"
+	"payverge/internal/money"
+)
+
+// tipsAddUp reports whether each person's amount with tip is their share plus their tip
+// and the tips add up to the result's tip
+func tipsAddUp(result *SplitResult) bool {
+	var tips money.Amount
+	for _, split := range result.Splits {
+		if split.AmountWithTip != split.Amount+split.TipAmount {
+			return false
+		}
+		tips += split.TipAmount
+	}
+	return tips == result.TipAmount && result.TotalWithTip == result.TotalAmount+result.TipAmount
+}
+
+func TestPercentageTipProperties(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		bill, _ := randomBill(r)
+		result := splitEqual(bill, 1+r.Intn(20))
+		rate := float64(r.Intn(31))
+
+		if err := ApplyTip(result, &TipPolicy{Mode: TipModePercentage, Percentage: rate}); err != nil {
+			return false
+		}
+		if !tipsAddUp(result) || result.TipAmount != bill.TotalAmount.Percent(rate) {
+			return false
+		}
+		// Everyone tips within a cent of the rate applied to their own share
+		for _, split := range result.Splits {
+			if !withinACent(split.TipAmount, result.TipAmount, float64(split.Amount), float64(bill.TotalAmount)) {
+				return false
+			}
+		}
+		return true
+	}
+	require.NoError(t, quick.Check(property, propertyConfig))
+}
+
+func TestGroupTipProperties(t *testing.T) {
+	property := func(seed int64) bool {
+		r := rand.New(rand.NewSource(seed))
+		bill, items := randomBill(r)
+		selections, portions := randomSelections(r, items, randomPeople(r), false)
+		result, err := splitItems(bill, items, selections, portions, nil, false)
+		if err != nil {
+			return false
+		}
+
+		tip := money.FromCents(r.Int63n(10000))
+		if err := ApplyTip(result, &TipPolicy{Mode: TipModeGroup, Amount: tip}); err != nil {
+			return false
+		}
+		return tipsAddUp(result) && result.TipAmount == tip
+	}
+	require.NoError(t, quick.Check(property, propertyConfig))
+}
+
+func TestPerPersonTip(t *testing.T) {
+	bill := &database.Bill{TotalAmount: money.MustParse("30.00"), Subtotal: money.MustParse("30.00")}
+	result := splitEqual(bill, 3)
+
+	require.NoError(t, ApplyTip(result, &TipPolicy{
+		Mode:    TipModePerPerson,
+		Amounts: map[string]money.Amount{"person_1": money.MustParse("2.00"), "person_3": money.MustParse("0.50")},
+	}))
+	assert.Equal(t, money.MustParse("12.00"), result.Splits[0].AmountWithTip)
+	assert.Equal(t, money.MustParse("10.00"), result.Splits[1].AmountWithTip)
+	assert.Equal(t, money.MustParse("10.50"), result.Splits[2].AmountWithTip)
+	assert.Equal(t, money.MustParse("2.50"), result.TipAmount)
+	assert.Equal(t, money.MustParse("32.50"), result.TotalWithTip)
+
+	err := ApplyTip(result, &TipPolicy{Mode: TipModePerPerson, Amounts: map[string]money.Amount{"stranger": 100}})
+	assert.Error(t, err)
+	assert.Error(t, ApplyTip(result, &TipPolicy{Mode: TipModeGroup, Amount: -1}))
+	assert.Error(t, ApplyTip(result, &TipPolicy{Mode: "generous"}))
+}
+
+func TestNoTipPolicy(t *testing.T) {
+	bill := &database.Bill{TotalAmount: money.MustParse("30.00"), Subtotal: money.MustParse("30.00")}
+	result := splitEqual(bill, 2)
+
+	require.NoError(t, ApplyTip(result, nil))
+	assert.True(t, tipsAddUp(result))
+	assert.Equal(t, money.Zero, result.TipAmount)
+	assert.Equal(t, money.MustParse("15.00"), result.Splits[0].AmountWithTip)
+}
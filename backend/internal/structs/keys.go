@@ -1,9 +1,16 @@
 package structs
 
-import "os"
+import (
+	"os"
+	"strings"
+)
 
 var SecretKey = []byte(getSecretKey())
 
+// PreviousSecretKeys are the keys SecretKey was rolled from, still accepted for the
+// tokens they signed so that rolling the key does not sign everyone out
+var PreviousSecretKeys = getPreviousSecretKeys()
+
 func getSecretKey() string {
 	key := os.Getenv("JWT_SECRET_KEY")
 	if key == "" {
@@ -12,3 +19,13 @@ func getSecretKey() string {
 	}
 	return key
 }
+
+func getPreviousSecretKeys() [][]byte {
+	var keys [][]byte
+	for _, key := range strings.Split(os.Getenv("JWT_PREVIOUS_SECRET_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, []byte(key))
+		}
+	}
+	return keys
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type BillLifecycleTestSuite struct {
+	suite.Suite
+	db   *gorm.DB
+	bill *database.Bill
+}
+
+func (suite *BillLifecycleTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *BillLifecycleTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *BillLifecycleTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM bill_events")
+	suite.db.Exec("DELETE FROM alternative_payments")
+	suite.db.Exec("DELETE FROM payments")
+	suite.db.Exec("DELETE FROM table_sessions")
+	suite.db.Exec("DELETE FROM bills")
+
+	suite.bill = &database.Bill{
+		BusinessID:  1,
+		TableID:     1,
+		BillNumber:  "LIFECYCLE-" + time.Now().Format("150405.000000"),
+		Subtotal:    money.MustParse("50.00"),
+		TotalAmount: money.MustParse("50.00"),
+		Status:      database.BillStatusOpen,
+	}
+	require.NoError(suite.T(), database.CreateBill(suite.bill, []database.BillItem{}))
+}
+
+func TestBillLifecycleTestSuite(t *testing.T) {
+	suite.Run(t, new(BillLifecycleTestSuite))
+}
+
+func (suite *BillLifecycleTestSuite) pay(txHash, amount string) *database.Bill {
+	logIndex := uint(0)
+	bill, _, err := database.RecordPayment(&database.Payment{
+		BillID:    suite.bill.ID,
+		PayerAddr: "0xguest",
+		Amount:    money.MustParse(amount),
+		TxHash:    txHash,
+		LogIndex:  &logIndex,
+		Status:    database.PaymentStatusConfirmed,
+	}, database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	return bill
+}
+
+func TestBillStatusTransitions(t *testing.T) {
+	allowed := []struct{ from, to database.BillStatus }{
+		{database.BillStatusOpen, database.BillStatusPartiallyPaid},
+		{database.BillStatusOpen, database.BillStatusPaid},
+		{database.BillStatusOpen, database.BillStatusVoided},
+		{database.BillStatusPartiallyPaid, database.BillStatusPaid},
+		{database.BillStatusPaid, database.BillStatusClosed},
+		{database.BillStatusPaid, database.BillStatusRefunded},
+		{database.BillStatusClosed, database.BillStatusRefunded},
+	}
+	for _, tc := range allowed {
+		assert.True(t, tc.from.CanTransitionTo(tc.to), "%s -> %s", tc.from, tc.to)
+	}
+
+	denied := []struct{ from, to database.BillStatus }{
+		{database.BillStatusPaid, database.BillStatusOpen},
+		{database.BillStatusClosed, database.BillStatusOpen},
+		{database.BillStatusPartiallyPaid, database.BillStatusVoided},
+		{database.BillStatusVoided, database.BillStatusOpen},
+		{database.BillStatusRefunded, database.BillStatusPaid},
+		{database.BillStatus("partial"), database.BillStatusPaid},
+	}
+	for _, tc := range denied {
+		assert.False(t, tc.from.CanTransitionTo(tc.to), "%s -> %s", tc.from, tc.to)
+	}
+}
+
+// Payments move the bill through partially paid to paid, and each step is audited
+func (suite *BillLifecycleTestSuite) TestLedgerDrivesPaymentStatuses() {
+	bill := suite.pay("0xa1", "20.00")
+	assert.Equal(suite.T(), database.BillStatusPartiallyPaid, bill.Status)
+
+	bill = suite.pay("0xa2", "30.00")
+	assert.Equal(suite.T(), database.BillStatusPaid, bill.Status)
+	assert.NotNil(suite.T(), bill.ClosedAt)
+
+	events, err := database.GetBillEvents(suite.bill.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), events, 2)
+	assert.Equal(suite.T(), database.BillStatusOpen, events[0].FromStatus)
+	assert.Equal(suite.T(), database.BillStatusPartiallyPaid, events[0].ToStatus)
+	assert.Equal(suite.T(), database.BillStatusPaid, events[1].ToStatus)
+	assert.Equal(suite.T(), database.BillActorIndexer, events[1].Actor)
+}
+
+// A bill with a balance still owing cannot be closed
+func (suite *BillLifecycleTestSuite) TestCloseRequiresFullPayment() {
+	suite.pay("0xb1", "20.00")
+
+	err := database.CloseBill(suite.bill.ID, "0xowner")
+	assert.ErrorIs(suite.T(), err, database.ErrBillBalanceOutstanding)
+
+	suite.pay("0xb2", "30.00")
+	require.NoError(suite.T(), database.CloseBill(suite.bill.ID, "0xowner"))
+
+	bill, _, err := database.GetBillByID(suite.bill.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.BillStatusClosed, bill.Status)
+
+	events, err := database.GetBillEvents(suite.bill.ID)
+	require.NoError(suite.T(), err)
+	last := events[len(events)-1]
+	assert.Equal(suite.T(), database.BillStatusClosed, last.ToStatus)
+	assert.Equal(suite.T(), "0xowner", last.Actor)
+}
+
+// Staff settlement marks the bill paid even when less than the total was collected
+func (suite *BillLifecycleTestSuite) TestStaffSettledBillCanBeClosed() {
+	require.NoError(suite.T(), database.MarkBillAsPaid(suite.bill.ID, money.MustParse("45.00"), money.Zero, "cash", "", "0xowner"))
+	require.NoError(suite.T(), database.CloseBill(suite.bill.ID, "0xowner"))
+}
+
+// Voided and refunded bills are final and do not move on new payments
+func (suite *BillLifecycleTestSuite) TestVoidedBillIsFinal() {
+	_, err := database.TransitionBill(suite.bill.ID, database.BillStatusVoided, "0xowner", "opened by mistake")
+	require.NoError(suite.T(), err)
+
+	_, err = database.TransitionBill(suite.bill.ID, database.BillStatusOpen, "0xowner", "")
+	var invalid *database.InvalidBillTransitionError
+	assert.ErrorAs(suite.T(), err, &invalid)
+
+	bill := suite.pay("0xc1", "50.00")
+	assert.Equal(suite.T(), database.BillStatusVoided, bill.Status)
+
+	err = database.MarkBillAsPaid(suite.bill.ID, money.MustParse("50.00"), money.Zero, "cash", "", "0xowner")
+	assert.Error(suite.T(), err)
+}
+
+// Saving items does not overwrite a status changed concurrently by the ledger
+func (suite *BillLifecycleTestSuite) TestUpdateBillKeepsStatus() {
+	stale, items, err := database.GetBillByID(suite.bill.ID)
+	require.NoError(suite.T(), err)
+
+	suite.pay("0xd1", "50.00")
+
+	require.NoError(suite.T(), database.UpdateBill(stale, items))
+	bill, _, err := database.GetBillByID(suite.bill.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.BillStatusPaid, bill.Status)
+	assert.Equal(suite.T(), money.MustParse("50.00"), bill.PaidAmount)
+}
+
+// Bills stored with the legacy "partial" status are migrated to partially_paid
+func (suite *BillLifecycleTestSuite) TestLegacyPartialStatusMigrated() {
+	require.NoError(suite.T(), suite.db.Exec("UPDATE bills SET status = 'partial' WHERE id = ?", suite.bill.ID).Error)
+	// Only the status migration is pending
+	suite.db.Exec("DELETE FROM data_migrations")
+	require.NoError(suite.T(), suite.db.Create(&database.DataMigration{Name: "money_minor_units", AppliedAt: time.Now()}).Error)
+
+	require.NoError(suite.T(), database.ApplyDataMigrations(suite.db))
+
+	bill, _, err := database.GetBillByID(suite.bill.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.BillStatusPartiallyPaid, bill.Status)
+	assert.Equal(suite.T(), money.MustParse("50.00"), bill.TotalAmount)
+}
@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type BillTransfersTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	business *database.Business
+	tables   []*database.Table
+	counter  *database.Counter
+}
+
+func (suite *BillTransfersTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *BillTransfersTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *BillTransfersTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM kitchen_tickets")
+	suite.db.Exec("DELETE FROM orders")
+	suite.db.Exec("DELETE FROM split_shares")
+	suite.db.Exec("DELETE FROM split_sessions")
+	suite.db.Exec("DELETE FROM alternative_payments")
+	suite.db.Exec("DELETE FROM payments")
+	suite.db.Exec("DELETE FROM bill_events")
+	suite.db.Exec("DELETE FROM table_sessions")
+	suite.db.Exec("DELETE FROM bills")
+	suite.db.Exec("DELETE FROM counters")
+	suite.db.Exec("DELETE FROM tables")
+	suite.db.Exec("DELETE FROM businesses")
+
+	suite.business = &database.Business{
+		OwnerAddress:   "0xOwner",
+		Name:           "Transfers Test",
+		SettlementAddr: "0xSettlement",
+		TippingAddr:    "0xTips",
+		TaxRate:        10,
+		ServiceFeeRate: 5,
+	}
+	require.NoError(suite.T(), suite.db.Create(suite.business).Error)
+
+	suite.tables = nil
+	for i := 1; i <= 3; i++ {
+		code, err := database.GenerateUniqueTableCode(suite.business.ID, "")
+		require.NoError(suite.T(), err)
+		table := &database.Table{BusinessID: suite.business.ID, Name: fmt.Sprintf("Table %d", i), TableCode: code, IsActive: true}
+		require.NoError(suite.T(), database.CreateTable(table))
+		suite.tables = append(suite.tables, table)
+	}
+
+	require.NoError(suite.T(), database.CreateCountersForBusiness(suite.business.ID, 1, "Counter "))
+	counters, err := database.GetBusinessCounters(suite.business.ID)
+	require.NoError(suite.T(), err)
+	suite.counter = &counters[0]
+}
+
+func TestBillTransfersTestSuite(t *testing.T) {
+	suite.Run(t, new(BillTransfersTestSuite))
+}
+
+// openBill opens a bill at a table with the given items, totalled without tax
+func (suite *BillTransfersTestSuite) openBill(tableID uint, items ...database.BillItem) *database.Bill {
+	var subtotal money.Amount
+	for _, item := range items {
+		subtotal += item.Subtotal
+	}
+	bill := &database.Bill{
+		BusinessID:  suite.business.ID,
+		TableID:     tableID,
+		BillNumber:  "TRANSFER-" + time.Now().Format("150405.000000000"),
+		Subtotal:    subtotal,
+		TotalAmount: subtotal,
+		Status:      database.BillStatusOpen,
+	}
+	require.NoError(suite.T(), database.CreateBill(bill, items))
+	return bill
+}
+
+func billItem(id, name, price string, quantity int) database.BillItem {
+	unit := money.MustParse(price)
+	return database.BillItem{ID: id, Name: name, Price: unit, Quantity: quantity, Subtotal: unit.Mul(quantity)}
+}
+
+func (suite *BillTransfersTestSuite) items(bill database.Bill) []database.BillItem {
+	var items []database.BillItem
+	require.NoError(suite.T(), json.Unmarshal([]byte(bill.Items), &items))
+	return items
+}
+
+func (suite *BillTransfersTestSuite) TestMoveBillToTableAndCounter() {
+	bill := suite.openBill(suite.tables[0].ID, billItem("a", "Burger", "20.00", 1))
+	_, token, err := database.StartTableSession(suite.tables[0], "phone", "", "", "")
+	require.NoError(suite.T(), err)
+	busy := suite.openBill(suite.tables[2].ID, billItem("b", "Beer", "5.00", 1))
+
+	_, err = database.MoveBill(suite.business.ID, bill.ID, database.BillTransferTarget{TableID: &suite.tables[2].ID}, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrTableHasOpenBill)
+	_, err = database.MoveBill(suite.business.ID, bill.ID, database.BillTransferTarget{}, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidBillTransfer)
+	_, err = database.MoveBill(suite.business.ID+1, bill.ID, database.BillTransferTarget{TableID: &suite.tables[1].ID}, "0xOwner")
+	assert.Error(suite.T(), err, "another business cannot move the bill")
+
+	transfer, err := database.MoveBill(suite.business.ID, bill.ID, database.BillTransferTarget{TableID: &suite.tables[1].ID}, "0xOwner")
+	require.NoError(suite.T(), err)
+	moved := transfer.Bills[0]
+	assert.Equal(suite.T(), suite.tables[1].ID, moved.TableID)
+	// Totals are recomputed with the business's 10% tax and 5% service fee
+	assert.Equal(suite.T(), money.MustParse("20.00"), moved.Subtotal)
+	assert.Equal(suite.T(), money.MustParse("2.00"), moved.TaxAmount)
+	assert.Equal(suite.T(), money.MustParse("1.00"), moved.ServiceFeeAmount)
+	assert.Equal(suite.T(), money.MustParse("23.00"), moved.TotalAmount)
+	assert.Len(suite.T(), transfer.Tables, 2, "both tables are told")
+
+	_, err = database.ValidateTableSession(suite.tables[0].ID, token)
+	assert.ErrorIs(suite.T(), err, database.ErrTableSessionInvalid, "guests have to scan the new table")
+
+	transfer, err = database.MoveBill(suite.business.ID, bill.ID, database.BillTransferTarget{CounterID: &suite.counter.ID}, "0xOwner")
+	require.NoError(suite.T(), err)
+	moved = transfer.Bills[0]
+	assert.Zero(suite.T(), moved.TableID)
+	require.NotNil(suite.T(), moved.CounterID)
+	counter, err := database.GetCounterByID(suite.counter.ID)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), counter.CurrentBillID)
+	assert.Equal(suite.T(), bill.ID, *counter.CurrentBillID)
+
+	_, err = database.MoveBill(suite.business.ID, busy.ID, database.BillTransferTarget{CounterID: &suite.counter.ID}, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrCounterHasOpenBill)
+
+	_, err = database.MoveBill(suite.business.ID, bill.ID, database.BillTransferTarget{TableID: &suite.tables[0].ID}, "0xOwner")
+	require.NoError(suite.T(), err)
+	counter, err = database.GetCounterByID(suite.counter.ID)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), counter.CurrentBillID, "the counter is free again")
+}
+
+func (suite *BillTransfersTestSuite) TestMergeBills() {
+	target := suite.openBill(suite.tables[0].ID, billItem("a", "Burger", "20.00", 1))
+	source := suite.openBill(suite.tables[1].ID, billItem("a", "Beer", "5.00", 2))
+	order := &database.Order{BillID: source.ID, BusinessID: suite.business.ID, OrderNumber: "O-1", Status: database.OrderStatusApproved}
+	require.NoError(suite.T(), database.CreateOrder(order, nil))
+
+	_, err := database.MergeBills(suite.business.ID, target.ID, target.ID, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidBillTransfer)
+
+	transfer, err := database.MergeBills(suite.business.ID, target.ID, source.ID, "0xOwner")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), transfer.Bills, 2)
+	merged, voided := transfer.Bills[0], transfer.Bills[1]
+
+	items := suite.items(merged)
+	require.Len(suite.T(), items, 2)
+	assert.NotEqual(suite.T(), items[0].ID, items[1].ID, "clashing item IDs are kept apart")
+	assert.Equal(suite.T(), money.MustParse("30.00"), merged.Subtotal)
+	assert.Equal(suite.T(), money.MustParse("34.50"), merged.TotalAmount)
+
+	assert.Equal(suite.T(), database.BillStatusVoided, voided.Status)
+	assert.Zero(suite.T(), voided.TotalAmount)
+	assert.Empty(suite.T(), suite.items(voided))
+	assert.Len(suite.T(), transfer.Tables, 2)
+
+	var moved database.Order
+	require.NoError(suite.T(), suite.db.First(&moved, order.ID).Error)
+	assert.Equal(suite.T(), target.ID, moved.BillID)
+
+	// The voided bill can take no further transfers
+	_, err = database.MergeBills(suite.business.ID, target.ID, source.ID, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidBillTransfer)
+}
+
+func (suite *BillTransfersTestSuite) TestMergeRefusesPaidSource() {
+	target := suite.openBill(suite.tables[0].ID, billItem("a", "Burger", "20.00", 1))
+	source := suite.openBill(suite.tables[1].ID, billItem("b", "Beer", "5.00", 2))
+	require.NoError(suite.T(), suite.db.Create(&database.AlternativePayment{
+		BillID:          source.ID,
+		ParticipantAddr: "0xguest",
+		Amount:          money.MustParse("5.00"),
+		PaymentMethod:   database.PaymentMethodCash,
+		Status:          database.AltPaymentStatusPending,
+	}).Error)
+
+	_, err := database.MergeBills(suite.business.ID, target.ID, source.ID, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidBillTransfer)
+}
+
+func (suite *BillTransfersTestSuite) TestSplitBillItems() {
+	bill := suite.openBill(suite.tables[0].ID,
+		billItem("burger", "Burger", "20.00", 1),
+		billItem("beer", "Beer", "5.00", 3),
+	)
+
+	_, err := database.SplitBill(suite.business.ID, bill.ID, []database.BillItemSplit{{ID: "beer", Quantity: 4}}, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidBillTransfer)
+	_, err = database.SplitBill(suite.business.ID, bill.ID, []database.BillItemSplit{{ID: "wine", Quantity: 1}}, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidBillTransfer)
+	_, err = database.SplitBill(suite.business.ID, bill.ID, []database.BillItemSplit{
+		{ID: "burger", Quantity: 1}, {ID: "beer", Quantity: 3},
+	}, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidBillTransfer, "the whole bill cannot be split off")
+
+	transfer, err := database.SplitBill(suite.business.ID, bill.ID, []database.BillItemSplit{{ID: "beer", Quantity: 2}}, "0xOwner")
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), transfer.Bills, 2)
+	original, split := transfer.Bills[0], transfer.Bills[1]
+
+	originalItems := suite.items(original)
+	require.Len(suite.T(), originalItems, 2)
+	assert.Equal(suite.T(), 1, originalItems[1].Quantity)
+	assert.Equal(suite.T(), money.MustParse("25.00"), original.Subtotal)
+	assert.Equal(suite.T(), money.MustParse("28.75"), original.TotalAmount)
+
+	splitItems := suite.items(split)
+	require.Len(suite.T(), splitItems, 1)
+	assert.Equal(suite.T(), 2, splitItems[0].Quantity)
+	assert.NotEqual(suite.T(), "beer", splitItems[0].ID)
+	assert.Equal(suite.T(), suite.tables[0].ID, split.TableID)
+	assert.Equal(suite.T(), database.BillStatusOpen, split.Status)
+	assert.Equal(suite.T(), money.MustParse("10.00"), split.Subtotal)
+	assert.Equal(suite.T(), money.MustParse("11.50"), split.TotalAmount)
+	assert.NotEqual(suite.T(), original.BillNumber, split.BillNumber)
+}
+
+func (suite *BillTransfersTestSuite) TestSplitKeepsWhatWasPaid() {
+	bill := suite.openBill(suite.tables[0].ID,
+		billItem("burger", "Burger", "20.00", 1),
+		billItem("beer", "Beer", "5.00", 2),
+	)
+	require.NoError(suite.T(), suite.db.Model(&database.Bill{}).Where("id = ?", bill.ID).
+		Updates(map[string]interface{}{"paid_amount": money.MustParse("25.00"), "status": database.BillStatusPartiallyPaid}).Error)
+
+	// 20.00 of food would total 23.00, less than was paid
+	_, err := database.SplitBill(suite.business.ID, bill.ID, []database.BillItemSplit{{ID: "beer", Quantity: 2}}, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidBillTransfer)
+
+	// Splitting off the burger would leave 11.50 too
+	_, err = database.SplitBill(suite.business.ID, bill.ID, []database.BillItemSplit{{ID: "burger", Quantity: 1}}, "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidBillTransfer)
+
+	transfer, err := database.SplitBill(suite.business.ID, bill.ID, []database.BillItemSplit{{ID: "beer", Quantity: 1}}, "0xOwner")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), money.MustParse("28.75"), transfer.Bills[0].TotalAmount)
+	assert.Equal(suite.T(), database.BillStatusPartiallyPaid, transfer.Bills[0].Status)
+}
@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+// recordingTableNotifier keeps every status board pushed to it
+type recordingTableNotifier struct {
+	boards [][]database.TableStatus
+}
+
+func (r *recordingTableNotifier) NotifyTableStatus(businessID uint, board []database.TableStatus) {
+	r.boards = append(r.boards, board)
+}
+
+type FloorPlanTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	business *database.Business
+	tables   []*database.Table
+	notifier *recordingTableNotifier
+}
+
+func (suite *FloorPlanTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *FloorPlanTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *FloorPlanTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM orders")
+	suite.db.Exec("DELETE FROM alternative_payments")
+	suite.db.Exec("DELETE FROM payments")
+	suite.db.Exec("DELETE FROM bill_events")
+	suite.db.Exec("DELETE FROM table_sessions")
+	suite.db.Exec("DELETE FROM bills")
+	suite.db.Exec("DELETE FROM tables")
+	suite.db.Exec("DELETE FROM businesses")
+
+	suite.business = &database.Business{
+		OwnerAddress:   "0xOwner",
+		Name:           "Floor Plan Test",
+		SettlementAddr: "0xSettlement",
+		TippingAddr:    "0xTips",
+	}
+	require.NoError(suite.T(), suite.db.Create(suite.business).Error)
+
+	suite.tables = nil
+	for i, section := range []string{"Patio", "Bar"} {
+		code, err := database.GenerateUniqueTableCode(suite.business.ID, "")
+		require.NoError(suite.T(), err)
+		table := &database.Table{
+			BusinessID: suite.business.ID,
+			Name:       fmt.Sprintf("Table %d", i+1),
+			TableCode:  code,
+			IsActive:   true,
+			Section:    section,
+			Seats:      4,
+		}
+		require.NoError(suite.T(), database.CreateTable(table))
+		suite.tables = append(suite.tables, table)
+	}
+
+	suite.notifier = &recordingTableNotifier{}
+	database.SetTableStatusNotifier(suite.notifier)
+}
+
+func (suite *FloorPlanTestSuite) TearDownTest() {
+	database.SetTableStatusNotifier(nil)
+}
+
+func TestFloorPlanTestSuite(t *testing.T) {
+	suite.Run(t, new(FloorPlanTestSuite))
+}
+
+// state returns a table's status from the board and checks the last pushed board agrees
+func (suite *FloorPlanTestSuite) state(tableID uint) database.TableState {
+	board, err := database.GetTableBoard(suite.business.ID)
+	require.NoError(suite.T(), err)
+	require.NotEmpty(suite.T(), suite.notifier.boards)
+	assert.Equal(suite.T(), board, suite.notifier.boards[len(suite.notifier.boards)-1])
+
+	for _, status := range board {
+		if status.TableID == tableID {
+			return status.Status
+		}
+	}
+	suite.T().Fatalf("table %d is not on the board", tableID)
+	return ""
+}
+
+// A table moves through the whole meal and every step is pushed to staff
+func (suite *FloorPlanTestSuite) TestBoardFollowsTheMeal() {
+	table := suite.tables[0]
+
+	bill, err := database.SeatTable(suite.business.ID, table.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "0xSettlement", bill.SettlementAddr)
+	assert.Equal(suite.T(), database.TableStateSeated, suite.state(table.ID))
+
+	order := &database.Order{BillID: bill.ID, BusinessID: suite.business.ID, OrderNumber: "1", Status: database.OrderStatusPending}
+	require.NoError(suite.T(), database.CreateOrder(order, []database.OrderItem{}))
+	assert.Equal(suite.T(), database.TableStateOrdered, suite.state(table.ID))
+
+	bill.Subtotal = money.MustParse("20.00")
+	bill.TotalAmount = money.MustParse("20.00")
+	require.NoError(suite.T(), database.UpdateBill(bill, []database.BillItem{}))
+	for _, status := range []database.OrderStatus{database.OrderStatusApproved, database.OrderStatusOrderReady, database.OrderStatusOrderDelivered} {
+		require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, status, ""))
+	}
+	assert.Equal(suite.T(), database.TableStateAwaitingPayment, suite.state(table.ID))
+
+	require.NoError(suite.T(), database.MarkBillAsPaid(bill.ID, money.MustParse("20.00"), money.Zero, "cash", "", "0xOwner"))
+	assert.Equal(suite.T(), database.TableStatePaid, suite.state(table.ID))
+
+	require.NoError(suite.T(), database.CloseBill(bill.ID, "0xOwner"))
+	assert.Equal(suite.T(), database.TableStateNeedsCleaning, suite.state(table.ID))
+
+	require.NoError(suite.T(), database.MarkTableClean(suite.business.ID, table.ID))
+	assert.Equal(suite.T(), database.TableStateFree, suite.state(table.ID))
+
+	// The other table was never touched
+	assert.Equal(suite.T(), database.TableStateFree, suite.state(suite.tables[1].ID))
+}
+
+// Items staff put straight on the bill count as ordered; a part payment means the
+// table is paying
+func (suite *FloorPlanTestSuite) TestBillWithoutOrders() {
+	table := suite.tables[1]
+	bill, err := database.SeatTable(suite.business.ID, table.ID)
+	require.NoError(suite.T(), err)
+
+	bill.Subtotal = money.MustParse("30.00")
+	bill.TotalAmount = money.MustParse("30.00")
+	require.NoError(suite.T(), database.UpdateBill(bill, []database.BillItem{}))
+	assert.Equal(suite.T(), database.TableStateOrdered, suite.state(table.ID))
+
+	logIndex := uint(0)
+	_, _, err = database.RecordPayment(&database.Payment{
+		BillID:    bill.ID,
+		PayerAddr: "0xguest",
+		Amount:    money.MustParse("10.00"),
+		TxHash:    "0xfloor1",
+		LogIndex:  &logIndex,
+		Status:    database.PaymentStatusConfirmed,
+	}, database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.TableStateAwaitingPayment, suite.state(table.ID))
+
+	board, err := database.GetTableBoard(suite.business.ID)
+	require.NoError(suite.T(), err)
+	for _, status := range board {
+		if status.TableID == table.ID {
+			assert.Equal(suite.T(), []uint{bill.ID}, status.BillIDs)
+			assert.Equal(suite.T(), money.MustParse("20.00"), status.Outstanding)
+			assert.NotNil(suite.T(), status.SeatedAt)
+		}
+	}
+}
+
+// A table with an open bill cannot be seated again, and tables of other businesses
+// cannot be seated at all
+func (suite *FloorPlanTestSuite) TestSeatTableRules() {
+	_, err := database.SeatTable(suite.business.ID, suite.tables[0].ID)
+	require.NoError(suite.T(), err)
+
+	_, err = database.SeatTable(suite.business.ID, suite.tables[0].ID)
+	assert.ErrorIs(suite.T(), err, database.ErrTableHasOpenBill)
+
+	_, err = database.SeatTable(suite.business.ID+1, suite.tables[1].ID)
+	assert.ErrorContains(suite.T(), err, "not found")
+}
+
+// Seating a table that was never cleared marks it clean
+func (suite *FloorPlanTestSuite) TestSeatingClearsTable() {
+	table := suite.tables[0]
+	bill, err := database.SeatTable(suite.business.ID, table.ID)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), database.MarkBillAsPaid(bill.ID, money.Zero, money.Zero, "cash", "", "0xOwner"))
+	require.NoError(suite.T(), database.CloseBill(bill.ID, "0xOwner"))
+	require.Equal(suite.T(), database.TableStateNeedsCleaning, suite.state(table.ID))
+
+	_, err = database.SeatTable(suite.business.ID, table.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.TableStateSeated, suite.state(table.ID))
+}
+
+func (suite *FloorPlanTestSuite) TestUpdateFloorPlan() {
+	err := database.UpdateFloorPlan(suite.business.ID, []database.TableLayout{
+		{TableID: suite.tables[0].ID, Section: "Terrace", PositionX: 120, PositionY: 80, Seats: 6, Shape: database.TableShapeRound},
+		{TableID: suite.tables[1].ID, Section: "Bar", PositionX: 10, PositionY: 20, Seats: 2},
+	})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), suite.notifier.boards, 1)
+
+	board := suite.notifier.boards[0]
+	require.Len(suite.T(), board, 2)
+	// Ordered by section
+	assert.Equal(suite.T(), "Bar", board[0].Section)
+	assert.Equal(suite.T(), database.TableShapeSquare, board[0].Shape)
+	assert.Equal(suite.T(), "Terrace", board[1].Section)
+	assert.Equal(suite.T(), 120.0, board[1].PositionX)
+	assert.Equal(suite.T(), 6, board[1].Seats)
+	assert.Equal(suite.T(), database.TableShapeRound, board[1].Shape)
+
+	err = database.UpdateFloorPlan(suite.business.ID, []database.TableLayout{{TableID: suite.tables[0].ID, Shape: "hexagon"}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidTableLayout)
+
+	err = database.UpdateFloorPlan(suite.business.ID+1, []database.TableLayout{{TableID: suite.tables[0].ID}})
+	assert.ErrorContains(suite.T(), err, "not found")
+
+	// Failed updates change nothing and push nothing
+	assert.Len(suite.T(), suite.notifier.boards, 1)
+	table, err := database.GetTableByID(suite.tables[0].ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Terrace", table.Section)
+}
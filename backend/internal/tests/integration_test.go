@@ -29,7 +29,7 @@ func (suite *IntegrationTestSuite) SetupSuite() {
 	
 	// Initialize test environment
 	structs.SecretKey = []byte("test-secret-key-for-integration-testing")
-	server.ChallengeStore = logic.NewChallengeStore()
+	server.ChallengeStore = logic.NewMemoryChallengeStore()
 	
 	// Create router with middleware
 	suite.router = gin.New()
@@ -53,6 +53,7 @@ func (suite *IntegrationTestSuite) setupRoutes() {
 		auth.POST("/challenge", server.GenerateChallenge)
 		auth.GET("/session", server.GetSession)
 		auth.POST("/signin", server.SignIn)
+		auth.POST("/refresh", server.RefreshSession)
 		auth.POST("/signout", server.SignOut)
 	}
 	
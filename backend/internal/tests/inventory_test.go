@@ -0,0 +1,322 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type InventoryTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	business *database.Business
+	bill     *database.Bill
+	ids      map[string]uint
+}
+
+func (suite *InventoryTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *InventoryTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *InventoryTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM kitchen_tickets")
+	suite.db.Exec("DELETE FROM orders")
+	suite.db.Exec("DELETE FROM bill_events")
+	suite.db.Exec("DELETE FROM table_sessions")
+	suite.db.Exec("DELETE FROM bills")
+	suite.db.Exec("DELETE FROM stock_adjustments")
+	suite.db.Exec("DELETE FROM menu_item_recipes")
+	suite.db.Exec("DELETE FROM stock_items")
+	suite.db.Exec("DELETE FROM menu_schedules")
+	suite.db.Exec("DELETE FROM menu_item_schedules")
+	suite.db.Exec("DELETE FROM menu_modifier_options")
+	suite.db.Exec("DELETE FROM menu_modifier_groups")
+	suite.db.Exec("DELETE FROM menu_item_images")
+	suite.db.Exec("DELETE FROM menu_item_options")
+	suite.db.Exec("DELETE FROM menu_items")
+	suite.db.Exec("DELETE FROM menu_categories")
+	suite.db.Exec("DELETE FROM menus")
+	suite.db.Exec("DELETE FROM businesses")
+
+	suite.business = &database.Business{
+		OwnerAddress:   "0xOwner",
+		Name:           "Inventory Test",
+		SettlementAddr: "0xSettlement",
+		TippingAddr:    "0xTips",
+	}
+	require.NoError(suite.T(), suite.db.Create(suite.business).Error)
+
+	categories := []database.MenuCategory{{
+		Name: "Mains",
+		Items: []database.MenuItem{
+			{Name: "Burger", Price: money.MustParse("12.00"), IsAvailable: true},
+			{Name: "Cheeseburger", Price: money.MustParse("14.00"), IsAvailable: true},
+			{Name: "Pie", Price: money.MustParse("6.00"), IsAvailable: true},
+		},
+	}}
+	require.NoError(suite.T(), database.CreateMenu(&database.Menu{BusinessID: suite.business.ID, IsActive: true}, categories))
+	suite.ids = map[string]uint{}
+	for _, item := range categories[0].Items {
+		suite.ids[item.Name] = item.ID
+	}
+
+	suite.bill = &database.Bill{
+		BusinessID: suite.business.ID,
+		TableID:    1,
+		BillNumber: "INV-" + time.Now().Format("150405.000000"),
+		Status:     database.BillStatusOpen,
+	}
+	require.NoError(suite.T(), database.CreateBill(suite.bill, []database.BillItem{}))
+}
+
+func TestInventoryTestSuite(t *testing.T) {
+	suite.Run(t, new(InventoryTestSuite))
+}
+
+// stock creates a stock item with an opening quantity and threshold
+func (suite *InventoryTestSuite) stock(name string, quantity, threshold int64) *database.StockItem {
+	item := &database.StockItem{BusinessID: suite.business.ID, Name: name, Quantity: quantity, LowStockThreshold: threshold}
+	require.NoError(suite.T(), database.CreateStockItem(item, "0xOwner"))
+	return item
+}
+
+// quantity returns the current quantity of a stock item
+func (suite *InventoryTestSuite) quantity(item *database.StockItem) int64 {
+	items, err := database.GetStockItems(suite.business.ID)
+	require.NoError(suite.T(), err)
+	for _, stored := range items {
+		if stored.ID == item.ID {
+			return stored.Quantity
+		}
+	}
+	suite.T().Fatalf("stock item %s not found", item.Name)
+	return 0
+}
+
+// order places a pending order for the given quantities of menu items
+func (suite *InventoryTestSuite) order(quantities map[string]int) *database.Order {
+	var selections []database.MenuSelection
+	for name, quantity := range quantities {
+		selections = append(selections, database.MenuSelection{MenuItemID: suite.ids[name], Quantity: quantity})
+	}
+	items, err := database.PriceOrderItems(suite.business.ID, selections)
+	require.NoError(suite.T(), err)
+
+	order := &database.Order{
+		BillID:      suite.bill.ID,
+		BusinessID:  suite.business.ID,
+		OrderNumber: "O-" + time.Now().Format("150405.000000"),
+		Status:      database.OrderStatusPending,
+	}
+	require.NoError(suite.T(), database.CreateOrder(order, items))
+	return order
+}
+
+// available reports whether the served menu offers a menu item right now
+func (suite *InventoryTestSuite) available(name string) bool {
+	_, categories, err := database.GetMenuAt(suite.business.ID, time.Now())
+	require.NoError(suite.T(), err)
+	for _, category := range categories {
+		for _, item := range category.Items {
+			if item.ID == suite.ids[name] {
+				return item.IsAvailable
+			}
+		}
+	}
+	suite.T().Fatalf("menu item %s not served", name)
+	return false
+}
+
+func (suite *InventoryTestSuite) TestApprovingAnOrderConsumesRecipeStock() {
+	bun := suite.stock("Bun", 10, 0)
+	patty := suite.stock("Patty", 10, 0)
+	cheese := suite.stock("Cheese slice", 10, 0)
+	require.NoError(suite.T(), database.SetMenuItemRecipe(suite.business.ID, suite.ids["Burger"], []database.RecipeComponent{
+		{StockItemID: bun.ID, Quantity: 1}, {StockItemID: patty.ID, Quantity: 1},
+	}))
+	require.NoError(suite.T(), database.SetMenuItemRecipe(suite.business.ID, suite.ids["Cheeseburger"], []database.RecipeComponent{
+		{StockItemID: bun.ID, Quantity: 1}, {StockItemID: patty.ID, Quantity: 2}, {StockItemID: cheese.ID, Quantity: 2},
+	}))
+
+	order := suite.order(map[string]int{"Burger": 2, "Cheeseburger": 1, "Pie": 3})
+	// Pending orders do not take stock
+	assert.Equal(suite.T(), int64(10), suite.quantity(bun))
+
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"))
+	assert.Equal(suite.T(), int64(7), suite.quantity(bun))
+	assert.Equal(suite.T(), int64(6), suite.quantity(patty))
+	assert.Equal(suite.T(), int64(8), suite.quantity(cheese))
+
+	// Approving again does not take the stock twice
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"))
+	assert.Equal(suite.T(), int64(7), suite.quantity(bun))
+}
+
+func (suite *InventoryTestSuite) TestCancellingAnOrderRestoresStock() {
+	pie, err := database.TrackMenuItemStock(suite.business.ID, suite.ids["Pie"], 5, 0, "0xOwner")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "portions", pie.Unit)
+
+	order := suite.order(map[string]int{"Pie": 2})
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"))
+	assert.Equal(suite.T(), int64(3), suite.quantity(pie))
+
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusOrderCancelled, ""))
+	assert.Equal(suite.T(), int64(5), suite.quantity(pie))
+
+	// Cancelling again returns nothing more
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusOrderCancelled, ""))
+	assert.Equal(suite.T(), int64(5), suite.quantity(pie))
+
+	// A cancelled order cannot be approved again
+	var invalid *database.InvalidOrderTransitionError
+	assert.ErrorAs(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"), &invalid)
+	assert.Equal(suite.T(), int64(5), suite.quantity(pie))
+
+	// A cancelled order that was never approved took nothing
+	pending := suite.order(map[string]int{"Pie": 1})
+	require.NoError(suite.T(), database.UpdateOrderStatus(pending.ID, database.OrderStatusOrderCancelled, ""))
+	assert.Equal(suite.T(), int64(5), suite.quantity(pie))
+}
+
+func (suite *InventoryTestSuite) TestItemsOutOfStockAreUnavailable() {
+	pie, err := database.TrackMenuItemStock(suite.business.ID, suite.ids["Pie"], 2, 0, "0xOwner")
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), suite.available("Pie"))
+
+	// Ordering more than is left is rejected
+	_, err = database.PriceOrderItems(suite.business.ID, []database.MenuSelection{{MenuItemID: suite.ids["Pie"], Quantity: 3}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuSelection)
+
+	order := suite.order(map[string]int{"Pie": 2})
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"))
+	assert.False(suite.T(), suite.available("Pie"))
+	assert.True(suite.T(), suite.available("Burger"))
+
+	_, err = database.PriceOrderItems(suite.business.ID, []database.MenuSelection{{MenuItemID: suite.ids["Pie"], Quantity: 1}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuSelection)
+
+	_, err = database.AdjustStock(suite.business.ID, pie.ID, database.StockReasonRestock, 4, "Delivery", "0xOwner")
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), suite.available("Pie"))
+}
+
+func (suite *InventoryTestSuite) TestLowStockIsAlertedOncePerRunningLow() {
+	bun := suite.stock("Bun", 5, 2)
+	suite.stock("Patty", 1, 3)
+
+	items, err := database.ClaimLowStockAlerts(suite.business.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), items, 1)
+	assert.Equal(suite.T(), "Patty", items[0].Name)
+
+	_, err = database.AdjustStock(suite.business.ID, bun.ID, database.StockReasonWaste, -3, "Stale", "0xOwner")
+	require.NoError(suite.T(), err)
+	items, err = database.ClaimLowStockAlerts(suite.business.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), items, 1)
+	assert.Equal(suite.T(), "Bun", items[0].Name)
+
+	// Nothing new until a stock item is restocked above its threshold and runs low again
+	items, err = database.ClaimLowStockAlerts(suite.business.ID)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), items)
+
+	_, err = database.AdjustStock(suite.business.ID, bun.ID, database.StockReasonRestock, 10, "", "0xOwner")
+	require.NoError(suite.T(), err)
+	_, err = database.CountStock(suite.business.ID, bun.ID, 1, "Weekly count", "0xOwner")
+	require.NoError(suite.T(), err)
+	items, err = database.ClaimLowStockAlerts(suite.business.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), items, 1)
+	assert.Equal(suite.T(), "Bun", items[0].Name)
+}
+
+func (suite *InventoryTestSuite) TestAdjustmentsReconcileWasteAgainstSales() {
+	pie, err := database.TrackMenuItemStock(suite.business.ID, suite.ids["Pie"], 10, 0, "0xOwner")
+	require.NoError(suite.T(), err)
+
+	sold := suite.order(map[string]int{"Pie": 3})
+	require.NoError(suite.T(), database.UpdateOrderStatus(sold.ID, database.OrderStatusApproved, "0xOwner"))
+	cancelled := suite.order(map[string]int{"Pie": 1})
+	require.NoError(suite.T(), database.UpdateOrderStatus(cancelled.ID, database.OrderStatusApproved, "0xOwner"))
+	require.NoError(suite.T(), database.UpdateOrderStatus(cancelled.ID, database.OrderStatusOrderCancelled, ""))
+
+	_, err = database.AdjustStock(suite.business.ID, pie.ID, database.StockReasonWaste, -2, "Dropped", "0xOwner")
+	require.NoError(suite.T(), err)
+	_, err = database.AdjustStock(suite.business.ID, pie.ID, database.StockReasonRestock, 6, "", "0xOwner")
+	require.NoError(suite.T(), err)
+	_, err = database.CountStock(suite.business.ID, pie.ID, 10, "", "0xOwner")
+	require.NoError(suite.T(), err)
+
+	adjustments, err := database.GetStockAdjustments(suite.business.ID, pie.ID, time.Time{}, time.Time{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), adjustments, 7)
+	// Newest first, each with the quantity it left
+	assert.Equal(suite.T(), database.StockReasonCount, adjustments[0].Reason)
+	assert.Equal(suite.T(), int64(-1), adjustments[0].Change)
+	assert.Equal(suite.T(), int64(10), adjustments[0].QuantityAfter)
+	assert.Equal(suite.T(), "Dropped", adjustments[2].Note)
+
+	report, err := database.GetStockReconciliation(suite.business.ID, time.Time{}, time.Time{})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), report, 1)
+	assert.Equal(suite.T(), int64(3), report[0].Sold)
+	assert.Equal(suite.T(), int64(2), report[0].Wasted)
+	assert.Equal(suite.T(), int64(6), report[0].Restocked)
+	assert.Equal(suite.T(), int64(9), report[0].Corrected)
+	assert.Equal(suite.T(), int64(10), report[0].StockItem.Quantity)
+
+	// Restocks must add and waste must remove stock
+	_, err = database.AdjustStock(suite.business.ID, pie.ID, database.StockReasonWaste, 2, "", "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidStockAdjustment)
+	_, err = database.AdjustStock(suite.business.ID, pie.ID, database.StockReasonSale, -1, "", "0xOwner")
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidStockAdjustment)
+}
+
+func (suite *InventoryTestSuite) TestRecipesAreValidated() {
+	bun := suite.stock("Bun", 10, 0)
+	other := &database.StockItem{BusinessID: suite.business.ID + 1, Name: "Other business bun"}
+	require.NoError(suite.T(), database.CreateStockItem(other, "0xOther"))
+
+	err := database.SetMenuItemRecipe(suite.business.ID, suite.ids["Burger"], []database.RecipeComponent{{StockItemID: bun.ID, Quantity: 0}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidRecipe)
+	err = database.SetMenuItemRecipe(suite.business.ID, suite.ids["Burger"], []database.RecipeComponent{{StockItemID: other.ID, Quantity: 1}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidRecipe)
+	err = database.SetMenuItemRecipe(suite.business.ID, suite.ids["Burger"], []database.RecipeComponent{
+		{StockItemID: bun.ID, Quantity: 1}, {StockItemID: bun.ID, Quantity: 1},
+	})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidRecipe)
+	err = database.SetMenuItemRecipe(suite.business.ID, 9999, []database.RecipeComponent{{StockItemID: bun.ID, Quantity: 1}})
+	assert.ErrorIs(suite.T(), err, database.ErrMenuItemNotFound)
+
+	require.NoError(suite.T(), database.SetMenuItemRecipe(suite.business.ID, suite.ids["Burger"], []database.RecipeComponent{{StockItemID: bun.ID, Quantity: 1}}))
+	recipe, err := database.GetMenuItemRecipe(suite.business.ID, suite.ids["Burger"])
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), recipe, 1)
+
+	// Deleting a stock item drops it from recipes
+	require.NoError(suite.T(), database.DeleteStockItem(suite.business.ID, bun.ID))
+	recipe, err = database.GetMenuItemRecipe(suite.business.ID, suite.ids["Burger"])
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), recipe)
+	assert.True(suite.T(), suite.available("Burger"))
+}
@@ -0,0 +1,290 @@
+package tests
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type KitchenTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	business   *database.Business
+	bill       *database.Bill
+	categories map[string]uint // Menu category IDs by name
+	items      map[string]uint // Menu item IDs by name
+}
+
+func (suite *KitchenTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *KitchenTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *KitchenTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM kitchen_tickets")
+	suite.db.Exec("DELETE FROM kitchen_routes")
+	suite.db.Exec("DELETE FROM kitchen_stations")
+	suite.db.Exec("DELETE FROM orders")
+	suite.db.Exec("DELETE FROM bill_events")
+	suite.db.Exec("DELETE FROM table_sessions")
+	suite.db.Exec("DELETE FROM bills")
+	suite.db.Exec("DELETE FROM menu_modifier_options")
+	suite.db.Exec("DELETE FROM menu_schedules")
+	suite.db.Exec("DELETE FROM menu_item_schedules")
+	suite.db.Exec("DELETE FROM stock_adjustments")
+	suite.db.Exec("DELETE FROM menu_item_recipes")
+	suite.db.Exec("DELETE FROM stock_items")
+	suite.db.Exec("DELETE FROM menu_modifier_groups")
+	suite.db.Exec("DELETE FROM menu_item_images")
+	suite.db.Exec("DELETE FROM menu_item_options")
+	suite.db.Exec("DELETE FROM menu_items")
+	suite.db.Exec("DELETE FROM menu_categories")
+	suite.db.Exec("DELETE FROM menus")
+	suite.db.Exec("DELETE FROM businesses")
+
+	suite.business = &database.Business{
+		OwnerAddress:   "0xOwner",
+		Name:           "Kitchen Test",
+		SettlementAddr: "0xSettlement",
+		TippingAddr:    "0xTips",
+	}
+	require.NoError(suite.T(), suite.db.Create(suite.business).Error)
+
+	categories := []database.MenuCategory{
+		{Name: "Mains", Items: []database.MenuItem{{Name: "Burger"}, {Name: "Steak"}}},
+		{Name: "Drinks", Items: []database.MenuItem{{Name: "Lemonade"}, {Name: "Beer"}}},
+		{Name: "Desserts", Items: []database.MenuItem{{Name: "Brownie"}}},
+	}
+	require.NoError(suite.T(), database.CreateMenu(&database.Menu{BusinessID: suite.business.ID}, categories))
+	suite.categories = map[string]uint{}
+	suite.items = map[string]uint{}
+	for _, category := range categories {
+		suite.categories[category.Name] = category.ID
+		for _, item := range category.Items {
+			suite.items[item.Name] = item.ID
+		}
+	}
+
+	suite.bill = &database.Bill{
+		BusinessID: suite.business.ID,
+		TableID:    1,
+		BillNumber: "KDS-" + time.Now().Format("150405.000000"),
+		Status:     database.BillStatusOpen,
+	}
+	require.NoError(suite.T(), database.CreateBill(suite.bill, []database.BillItem{}))
+}
+
+func TestKitchenTestSuite(t *testing.T) {
+	suite.Run(t, new(KitchenTestSuite))
+}
+
+func (suite *KitchenTestSuite) station(name string, isDefault bool) *database.KitchenStation {
+	station := &database.KitchenStation{BusinessID: suite.business.ID, Name: name, IsDefault: isDefault, IsActive: true}
+	require.NoError(suite.T(), database.CreateKitchenStation(station))
+	return station
+}
+
+// approvedOrder places an order for the named items and approves it. Names not on
+// the menu are ordered without a menu item ID.
+func (suite *KitchenTestSuite) approvedOrder(names ...string) *database.Order {
+	items := make([]database.OrderItem, len(names))
+	for i, name := range names {
+		menuItemID := ""
+		if id, ok := suite.items[name]; ok {
+			menuItemID = strconv.FormatUint(uint64(id), 10)
+		}
+		items[i] = database.OrderItem{
+			ID:           name,
+			MenuItemID:   menuItemID,
+			MenuItemName: name,
+			Quantity:     1,
+			Price:        money.MustParse("10.00"),
+			Subtotal:     money.MustParse("10.00"),
+		}
+	}
+	order := &database.Order{
+		BillID:      suite.bill.ID,
+		BusinessID:  suite.business.ID,
+		OrderNumber: "O-" + time.Now().Format("150405.000000"),
+		Status:      database.OrderStatusPending,
+	}
+	require.NoError(suite.T(), database.CreateOrder(order, items))
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"))
+	return order
+}
+
+// ticketItems returns the item names on a ticket
+func (suite *KitchenTestSuite) ticketItems(ticket database.KitchenTicket) []string {
+	var items []database.OrderItem
+	require.NoError(suite.T(), json.Unmarshal([]byte(ticket.Items), &items))
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.MenuItemName
+	}
+	return names
+}
+
+func (suite *KitchenTestSuite) TestApprovedOrderIsRoutedToStations() {
+	grill := suite.station("Grill", true)
+	bar := suite.station("Bar", false)
+	dessert := suite.station("Dessert", false)
+	require.NoError(suite.T(), database.SetKitchenRoutes(suite.business.ID, []database.KitchenRoute{
+		{StationID: bar.ID, CategoryID: suite.categories["Drinks"]},
+		{StationID: dessert.ID, CategoryID: suite.categories["Desserts"]},
+		// An item route wins over its category's route
+		{StationID: grill.ID, MenuItemID: suite.items["Lemonade"]},
+	}))
+
+	order := suite.approvedOrder("Burger", "Beer", "Lemonade", "Brownie", "Off Menu Special")
+
+	tickets, err := database.GetKitchenTicketsByOrder(order.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), tickets, 3)
+
+	byStation := make(map[uint]database.KitchenTicket)
+	for _, ticket := range tickets {
+		assert.Equal(suite.T(), database.KitchenTicketPending, ticket.Status)
+		assert.Equal(suite.T(), order.OrderNumber, ticket.OrderNumber)
+		byStation[ticket.StationID] = ticket
+	}
+	assert.ElementsMatch(suite.T(), []string{"Burger", "Lemonade", "Off Menu Special"}, suite.ticketItems(byStation[grill.ID]))
+	assert.ElementsMatch(suite.T(), []string{"Beer"}, suite.ticketItems(byStation[bar.ID]))
+	assert.ElementsMatch(suite.T(), []string{"Brownie"}, suite.ticketItems(byStation[dessert.ID]))
+
+	barTickets, err := database.GetKitchenTickets(suite.business.ID, bar.ID)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), barTickets, 1)
+
+	// Approving again does not send the order to the kitchen twice
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"))
+	tickets, err = database.GetKitchenTicketsByOrder(order.ID)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), tickets, 3)
+}
+
+func (suite *KitchenTestSuite) TestOrderIsReadyOnlyWhenEveryTicketIsBumped() {
+	grill := suite.station("Grill", true)
+	bar := suite.station("Bar", false)
+	require.NoError(suite.T(), database.SetKitchenRoutes(suite.business.ID, []database.KitchenRoute{
+		{StationID: bar.ID, CategoryID: suite.categories["Drinks"]},
+	}))
+	order := suite.approvedOrder("Steak", "Beer")
+
+	tickets, err := database.GetKitchenTickets(suite.business.ID, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), tickets, 2)
+	var grillTicket, barTicket database.KitchenTicket
+	for _, ticket := range tickets {
+		if ticket.StationID == grill.ID {
+			grillTicket = ticket
+		} else {
+			barTicket = ticket
+		}
+	}
+
+	ticket, updated, err := database.StartKitchenTicket(suite.business.ID, grillTicket.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.KitchenTicketInProgress, ticket.Status)
+	assert.NotNil(suite.T(), ticket.StartedAt)
+	assert.Equal(suite.T(), database.OrderStatusInKitchen, updated.Status)
+
+	// The bar bumps its ticket first; the grill is still cooking
+	ticket, updated, err = database.BumpKitchenTicket(suite.business.ID, barTicket.ID, "0xBar")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.KitchenTicketDone, ticket.Status)
+	assert.Equal(suite.T(), "0xBar", ticket.BumpedBy)
+	assert.Equal(suite.T(), database.OrderStatusInKitchen, updated.Status)
+
+	_, _, err = database.BumpKitchenTicket(suite.business.ID, barTicket.ID, "0xBar")
+	assert.ErrorIs(suite.T(), err, database.ErrKitchenTicketClosed)
+
+	_, updated, err = database.BumpKitchenTicket(suite.business.ID, grillTicket.ID, "0xGrill")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.OrderStatusOrderReady, updated.Status)
+
+	stored, _, err := database.GetOrderByID(order.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.OrderStatusOrderReady, stored.Status)
+
+	open, err := database.GetKitchenTickets(suite.business.ID, 0, database.KitchenTicketPending, database.KitchenTicketInProgress)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), open)
+}
+
+func (suite *KitchenTestSuite) TestTicketsOfOtherBusinessesAreNotFound() {
+	suite.station("Grill", true)
+	suite.approvedOrder("Burger")
+
+	tickets, err := database.GetKitchenTickets(suite.business.ID, 0)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), tickets, 1)
+
+	_, _, err = database.BumpKitchenTicket(suite.business.ID+1, tickets[0].ID, "0xOther")
+	assert.ErrorIs(suite.T(), err, database.ErrKitchenTicketNotFound)
+}
+
+func (suite *KitchenTestSuite) TestCancellingAnOrderWithdrawsItsTickets() {
+	grill := suite.station("Grill", true)
+	suite.station("Bar", false)
+	order := suite.approvedOrder("Burger")
+
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusOrderCancelled, ""))
+
+	tickets, err := database.GetKitchenTickets(suite.business.ID, grill.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), tickets, 1)
+	assert.Equal(suite.T(), database.KitchenTicketCancelled, tickets[0].Status)
+
+	_, _, err = database.StartKitchenTicket(suite.business.ID, tickets[0].ID)
+	assert.ErrorIs(suite.T(), err, database.ErrKitchenTicketClosed)
+}
+
+func (suite *KitchenTestSuite) TestBusinessesWithoutStationsGetNoTickets() {
+	order := suite.approvedOrder("Burger")
+
+	tickets, err := database.GetKitchenTicketsByOrder(order.ID)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), tickets)
+
+	stored, _, err := database.GetOrderByID(order.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), database.OrderStatusApproved, stored.Status)
+}
+
+func (suite *KitchenTestSuite) TestOnlyOneDefaultStation() {
+	first := suite.station("Grill", true)
+	second := suite.station("Pass", true)
+
+	stations, err := database.GetKitchenStations(suite.business.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), stations, 2)
+	for _, station := range stations {
+		assert.Equal(suite.T(), station.ID == second.ID, station.IsDefault, "station %d", station.ID)
+	}
+
+	err = database.SetKitchenRoutes(suite.business.ID, []database.KitchenRoute{{StationID: first.ID}})
+	assert.Error(suite.T(), err, "a route needs a category or an item")
+	err = database.SetKitchenRoutes(suite.business.ID, []database.KitchenRoute{{StationID: first.ID + 100, CategoryID: suite.categories["Mains"]}})
+	assert.ErrorIs(suite.T(), err, database.ErrKitchenStationNotFound)
+	err = database.SetKitchenRoutes(suite.business.ID, []database.KitchenRoute{{StationID: first.ID, MenuItemID: suite.items["Brownie"] + 100}})
+	assert.ErrorIs(suite.T(), err, database.ErrKitchenRouteTargetNotFound)
+}
@@ -0,0 +1,259 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type MenuImportTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	business *database.Business
+	menu     *database.Menu
+}
+
+func (suite *MenuImportTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *MenuImportTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *MenuImportTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM menu_schedules")
+	suite.db.Exec("DELETE FROM menu_item_schedules")
+	suite.db.Exec("DELETE FROM stock_adjustments")
+	suite.db.Exec("DELETE FROM menu_item_recipes")
+	suite.db.Exec("DELETE FROM stock_items")
+	suite.db.Exec("DELETE FROM menu_modifier_options")
+	suite.db.Exec("DELETE FROM menu_modifier_groups")
+	suite.db.Exec("DELETE FROM menu_item_images")
+	suite.db.Exec("DELETE FROM menu_item_options")
+	suite.db.Exec("DELETE FROM menu_items")
+	suite.db.Exec("DELETE FROM menu_categories")
+	suite.db.Exec("DELETE FROM menus")
+	suite.db.Exec("DELETE FROM businesses")
+
+	suite.business = &database.Business{
+		OwnerAddress:   "0xOwner",
+		Name:           "Import Test",
+		SettlementAddr: "0xSettlement",
+		TippingAddr:    "0xTips",
+	}
+	require.NoError(suite.T(), suite.db.Create(suite.business).Error)
+
+	suite.menu = &database.Menu{BusinessID: suite.business.ID, Name: "All day", IsActive: true}
+	require.NoError(suite.T(), database.CreateMenu(suite.menu, []database.MenuCategory{
+		{
+			Name:        "Mains",
+			Description: "From the grill",
+			Items: []database.MenuItem{
+				{
+					Name: "Burger", Price: money.MustParse("12.00"), IsAvailable: true,
+					Images:    []string{"https://img/burger.jpg"},
+					Allergens: []string{"gluten", "dairy"},
+					Options:   []database.MenuItemOption{{Name: "Bacon", PriceChange: money.MustParse("2.00")}},
+					Modifiers: []database.ModifierGroup{{
+						Name: "Doneness", MinSelect: 1, MaxSelect: 1,
+						Options: []database.ModifierOption{{Name: "Medium", IsAvailable: true}, {Name: "Well done", IsAvailable: true}},
+					}},
+				},
+				{Name: "Salad", Price: money.MustParse("9.00"), IsAvailable: true, DietaryTags: []string{"vegan"}},
+			},
+		},
+		{
+			Name:  "Drinks",
+			Items: []database.MenuItem{{Name: "Lemonade", Price: money.MustParse("3.50"), IsAvailable: true}},
+		},
+	}))
+}
+
+func TestMenuImportTestSuite(t *testing.T) {
+	suite.Run(t, new(MenuImportTestSuite))
+}
+
+// items returns the items of the main menu by name
+func (suite *MenuImportTestSuite) items() map[string]database.MenuItem {
+	_, categories, err := database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	items := make(map[string]database.MenuItem)
+	for _, category := range categories {
+		for _, item := range category.Items {
+			items[item.Name] = item
+		}
+	}
+	return items
+}
+
+func (suite *MenuImportTestSuite) TestCSVRoundTrip() {
+	export, err := database.ExportMenu(suite.business.ID, 0)
+	require.NoError(suite.T(), err)
+
+	var buf bytes.Buffer
+	require.NoError(suite.T(), database.WriteMenuCSV(&buf, export.Categories))
+	assert.Contains(suite.T(), buf.String(), "Mains,Burger,,12.00,true,gluten;dairy,,Bacon:2.00\n")
+
+	categories, err := database.ReadMenuCSV(&buf)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), categories, 2)
+	assert.Equal(suite.T(), "Mains", categories[0].Name)
+	require.Len(suite.T(), categories[0].Items, 2)
+	burger := categories[0].Items[0]
+	assert.Equal(suite.T(), money.MustParse("12.00"), burger.Price)
+	assert.Equal(suite.T(), []string{"gluten", "dairy"}, burger.Allergens)
+	require.Len(suite.T(), burger.Options, 1)
+	assert.Equal(suite.T(), money.MustParse("2.00"), burger.Options[0].PriceChange)
+
+	// Importing an unchanged export changes nothing
+	result, err := database.ImportMenu(suite.business.ID, 0, &database.MenuExport{Categories: categories}, true, true)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), result.Changes)
+}
+
+func (suite *MenuImportTestSuite) TestReadMenuCSVRejectsBadRows() {
+	_, err := database.ReadMenuCSV(strings.NewReader("category,item\nMains,Burger\n"))
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuImport)
+
+	_, err = database.ReadMenuCSV(strings.NewReader("item,price,category\nBurger,twelve,Mains\n"))
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuImport)
+	assert.Contains(suite.T(), err.Error(), "line 2")
+
+	categories, err := database.ReadMenuCSV(strings.NewReader(
+		"item,price,category,options,available\nSteak,24,Mains,Sauce:1.5;Cooked:0:required,no\n"))
+	require.NoError(suite.T(), err)
+	steak := categories[0].Items[0]
+	assert.False(suite.T(), steak.IsAvailable)
+	require.Len(suite.T(), steak.Options, 2)
+	assert.True(suite.T(), steak.Options[1].IsRequired)
+}
+
+func (suite *MenuImportTestSuite) TestDryRunListsChangesWithoutApplying() {
+	before := suite.items()
+	csv := "category,item,price,allergens,options\n" +
+		"Mains,Burger,13.50,gluten;dairy,Bacon:2.00\n" +
+		"Drinks,Salad,9.00,,\n" +
+		"Desserts,Brownie,6.00,nuts,\n"
+	categories, err := database.ReadMenuCSV(strings.NewReader(csv))
+	require.NoError(suite.T(), err)
+
+	result, err := database.ImportMenu(suite.business.ID, 0, &database.MenuExport{Categories: categories}, true, true)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), result.DryRun)
+	assert.Equal(suite.T(), suite.menu.ID, result.MenuID)
+	assert.ElementsMatch(suite.T(), []database.MenuImportChange{
+		{Action: database.MenuImportUpdate, Category: "Mains", Item: "Burger", Fields: []string{"price"}},
+		{Action: database.MenuImportUpdate, Category: "Drinks", Item: "Salad", Fields: []string{"category", "dietary_tags"}},
+		{Action: database.MenuImportAdd, Category: "Desserts"},
+		{Action: database.MenuImportAdd, Category: "Desserts", Item: "Brownie"},
+		{Action: database.MenuImportRemove, Category: "Drinks", Item: "Lemonade"},
+	}, result.Changes)
+
+	assert.Equal(suite.T(), before, suite.items())
+}
+
+func (suite *MenuImportTestSuite) TestCSVImportKeepsWhatCSVCannotCarry() {
+	before := suite.items()
+	csv := "category,item,price,allergens,options\n" +
+		"Mains,Burger,13.50,gluten;dairy,Bacon:2.00;Cheese:1.00\n" +
+		"Drinks,Lemonade,3.50,,\n"
+	categories, err := database.ReadMenuCSV(strings.NewReader(csv))
+	require.NoError(suite.T(), err)
+
+	result, err := database.ImportMenu(suite.business.ID, 0, &database.MenuExport{Categories: categories}, true, false)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), result.DryRun)
+
+	after := suite.items()
+	require.Len(suite.T(), after, 2)
+	burger := after["Burger"]
+	assert.Equal(suite.T(), before["Burger"].ID, burger.ID)
+	assert.Equal(suite.T(), money.MustParse("13.50"), burger.Price)
+	assert.Equal(suite.T(), []string{"https://img/burger.jpg"}, burger.Images)
+	require.Len(suite.T(), burger.Modifiers, 1)
+	assert.Equal(suite.T(), before["Burger"].Modifiers[0].ID, burger.Modifiers[0].ID)
+	require.Len(suite.T(), burger.Options, 2)
+	assert.Equal(suite.T(), before["Burger"].Options[0].ID, burger.Options[0].ID)
+	assert.Equal(suite.T(), before["Lemonade"].ID, after["Lemonade"].ID)
+
+	menu, err := database.GetMenu(suite.business.ID, suite.menu.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "All day", menu.Name)
+	assert.Equal(suite.T(), "From the grill", menu.Categories[0].Description)
+
+	// Only the new option needs translating; the burger's unchanged text does not
+	require.Len(suite.T(), result.NewText, 1)
+	require.Len(suite.T(), result.NewText[0].Items, 1)
+	text := result.NewText[0].Items[0]
+	assert.Equal(suite.T(), burger.ID, text.ID)
+	assert.Empty(suite.T(), text.Name)
+	assert.Equal(suite.T(), []string{"", ""}, text.Allergens)
+	require.Len(suite.T(), text.Options, 1)
+	assert.Equal(suite.T(), "Cheese", text.Options[0].Name)
+}
+
+func (suite *MenuImportTestSuite) TestJSONImportIsFullFidelity() {
+	export, err := database.ExportMenu(suite.business.ID, 0)
+	require.NoError(suite.T(), err)
+	data, err := json.Marshal(export)
+	require.NoError(suite.T(), err)
+
+	// Import the export into another business, as when copying a menu between venues
+	other := &database.Business{OwnerAddress: "0xOwner", Name: "Second venue", SettlementAddr: "0xS", TippingAddr: "0xT"}
+	require.NoError(suite.T(), suite.db.Create(other).Error)
+	var imported database.MenuExport
+	require.NoError(suite.T(), json.Unmarshal(data, &imported))
+	imported.Categories[0].Items[0].Description = "Double patty"
+
+	result, err := database.ImportMenu(other.ID, 0, &imported, false, false)
+	require.NoError(suite.T(), err)
+	assert.NotZero(suite.T(), result.MenuID)
+	assert.Len(suite.T(), result.Changes, 5)
+
+	menu, categories, err := database.GetMenuByBusinessID(other.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "All day", menu.Name)
+	require.Len(suite.T(), categories, 2)
+	assert.Equal(suite.T(), "From the grill", categories[0].Description)
+	burger := categories[0].Items[0]
+	assert.Equal(suite.T(), "Double patty", burger.Description)
+	assert.Equal(suite.T(), []string{"https://img/burger.jpg"}, burger.Images)
+	require.Len(suite.T(), burger.Modifiers, 1)
+	assert.Len(suite.T(), burger.Modifiers[0].Options, 2)
+	assert.NotEqual(suite.T(), export.Categories[0].Items[0].ID, burger.ID)
+
+	// The original business's menu is untouched
+	assert.Equal(suite.T(), "", suite.items()["Burger"].Description)
+}
+
+func (suite *MenuImportTestSuite) TestImportRejectsInvalidMenus() {
+	_, err := database.ImportMenu(suite.business.ID, 0, &database.MenuExport{Categories: []database.MenuCategory{
+		{Name: "Mains"}, {Name: " mains "},
+	}}, false, true)
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuImport)
+
+	_, err = database.ImportMenu(suite.business.ID, 0, &database.MenuExport{Categories: []database.MenuCategory{
+		{Name: "Mains", Items: []database.MenuItem{{Name: "Burger", Modifiers: []database.ModifierGroup{{Name: "Empty"}}}}},
+	}}, false, true)
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidModifierGroup)
+
+	_, err = database.ImportMenu(suite.business.ID, 9999, &database.MenuExport{}, false, true)
+	assert.ErrorIs(suite.T(), err, database.ErrMenuNotFound)
+}
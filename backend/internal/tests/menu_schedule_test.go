@@ -0,0 +1,261 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type MenuScheduleTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	business *database.Business
+	madrid   *time.Location
+}
+
+func (suite *MenuScheduleTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+
+	var err error
+	suite.madrid, err = time.LoadLocation("Europe/Madrid")
+	require.NoError(suite.T(), err)
+}
+
+func (suite *MenuScheduleTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *MenuScheduleTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM menu_schedules")
+	suite.db.Exec("DELETE FROM menu_item_schedules")
+	suite.db.Exec("DELETE FROM stock_adjustments")
+	suite.db.Exec("DELETE FROM menu_item_recipes")
+	suite.db.Exec("DELETE FROM stock_items")
+	suite.db.Exec("DELETE FROM menu_modifier_options")
+	suite.db.Exec("DELETE FROM menu_modifier_groups")
+	suite.db.Exec("DELETE FROM menu_item_images")
+	suite.db.Exec("DELETE FROM menu_item_options")
+	suite.db.Exec("DELETE FROM menu_items")
+	suite.db.Exec("DELETE FROM menu_categories")
+	suite.db.Exec("DELETE FROM menus")
+	suite.db.Exec("DELETE FROM businesses")
+
+	suite.business = &database.Business{
+		OwnerAddress:   "0xOwner",
+		Name:           "Schedule Test",
+		SettlementAddr: "0xSettlement",
+		TippingAddr:    "0xTips",
+		Timezone:       "Europe/Madrid",
+	}
+	require.NoError(suite.T(), suite.db.Create(suite.business).Error)
+}
+
+func TestMenuScheduleTestSuite(t *testing.T) {
+	suite.Run(t, new(MenuScheduleTestSuite))
+}
+
+// addMenu creates a menu with one category holding the given item
+func (suite *MenuScheduleTestSuite) addMenu(name string, schedules []database.MenuSchedule, item database.MenuItem) *database.Menu {
+	menu := &database.Menu{BusinessID: suite.business.ID, Name: name, IsActive: true, Schedules: schedules}
+	require.NoError(suite.T(), database.CreateMenu(menu, []database.MenuCategory{
+		{Name: name, Items: []database.MenuItem{item}},
+	}))
+	return menu
+}
+
+// everyDay is a menu schedule repeated on each day of the week
+func everyDay(start, end string) []database.MenuSchedule {
+	schedules := make([]database.MenuSchedule, 7)
+	for day := range schedules {
+		schedules[day].ScheduleWindow = database.ScheduleWindow{DayOfWeek: day, StartTime: start, EndTime: end}
+	}
+	return schedules
+}
+
+// servedAt returns the name of the menu served at a time in Madrid
+func (suite *MenuScheduleTestSuite) servedAt(day, hour, minute int) string {
+	menu, _, err := database.GetMenuAt(suite.business.ID, time.Date(2026, time.October, day, hour, minute, 0, 0, suite.madrid))
+	require.NoError(suite.T(), err)
+	return menu.Name
+}
+
+func (suite *MenuScheduleTestSuite) TestScheduledMenusAreServedDuringTheirHours() {
+	suite.addMenu("All day", nil, database.MenuItem{Name: "Coffee", Price: money.MustParse("2.00"), IsAvailable: true})
+	suite.addMenu("Breakfast", everyDay("07:00", "11:00"), database.MenuItem{Name: "Toast", Price: money.MustParse("4.00"), IsAvailable: true})
+	// October 16th 2026 is a Friday
+	suite.addMenu("Happy hour", []database.MenuSchedule{
+		{ScheduleWindow: database.ScheduleWindow{DayOfWeek: int(time.Friday), StartTime: "22:00", EndTime: "02:00"}},
+	}, database.MenuItem{Name: "Spritz", Price: money.MustParse("5.00"), IsAvailable: true})
+
+	assert.Equal(suite.T(), "Breakfast", suite.servedAt(12, 7, 0))
+	assert.Equal(suite.T(), "Breakfast", suite.servedAt(12, 10, 59))
+	assert.Equal(suite.T(), "All day", suite.servedAt(12, 11, 0))
+	assert.Equal(suite.T(), "All day", suite.servedAt(16, 21, 59))
+	assert.Equal(suite.T(), "Happy hour", suite.servedAt(16, 23, 30))
+	assert.Equal(suite.T(), "Happy hour", suite.servedAt(17, 1, 30))
+	assert.Equal(suite.T(), "All day", suite.servedAt(17, 2, 0))
+	assert.Equal(suite.T(), "All day", suite.servedAt(17, 23, 30))
+
+	// The main menu is still the first one, whatever is being served
+	menu, _, err := database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "All day", menu.Name)
+}
+
+func (suite *MenuScheduleTestSuite) TestSchedulesUseTheBusinessTimezone() {
+	suite.addMenu("All day", nil, database.MenuItem{Name: "Coffee", Price: money.MustParse("2.00"), IsAvailable: true})
+	suite.addMenu("Breakfast", everyDay("07:00", "11:00"), database.MenuItem{Name: "Toast", Price: money.MustParse("4.00"), IsAvailable: true})
+
+	// Madrid is two hours ahead of UTC in October until the 25th
+	menu, _, err := database.GetMenuAt(suite.business.ID, time.Date(2026, time.October, 12, 8, 30, 0, 0, time.UTC))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Breakfast", menu.Name)
+
+	menu, _, err = database.GetMenuAt(suite.business.ID, time.Date(2026, time.October, 12, 9, 30, 0, 0, time.UTC))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "All day", menu.Name)
+}
+
+func (suite *MenuScheduleTestSuite) TestNothingIsServedOutsideSchedulesWithoutAnAllDayMenu() {
+	suite.addMenu("Breakfast", everyDay("07:00", "11:00"), database.MenuItem{Name: "Toast", Price: money.MustParse("4.00"), IsAvailable: true})
+
+	_, _, err := database.GetMenuAt(suite.business.ID, time.Date(2026, time.October, 12, 15, 0, 0, 0, suite.madrid))
+	assert.ErrorIs(suite.T(), err, database.ErrMenuNotFound)
+
+	_, err = database.PriceOrderItems(suite.business.ID, []database.MenuSelection{{MenuItemID: 1, Quantity: 1}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuSelection)
+}
+
+func (suite *MenuScheduleTestSuite) TestInactiveMenusAreNotServed() {
+	suite.addMenu("All day", nil, database.MenuItem{Name: "Coffee", Price: money.MustParse("2.00"), IsAvailable: true})
+	breakfast := suite.addMenu("Breakfast", everyDay("07:00", "11:00"), database.MenuItem{Name: "Toast", Price: money.MustParse("4.00"), IsAvailable: true})
+
+	breakfast.IsActive = false
+	require.NoError(suite.T(), database.UpdateMenu(breakfast, nil))
+	assert.Equal(suite.T(), "All day", suite.servedAt(12, 8, 0))
+
+	// Updating without categories keeps the menu's content
+	menu, err := database.GetMenu(suite.business.ID, breakfast.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), menu.Categories, 1)
+	assert.Equal(suite.T(), "Toast", menu.Categories[0].Items[0].Name)
+	assert.Len(suite.T(), menu.Schedules, 7)
+}
+
+func (suite *MenuScheduleTestSuite) TestItemSchedulesLimitAvailability() {
+	suite.addMenu("All day", nil, database.MenuItem{
+		Name: "Croissant", Price: money.MustParse("2.50"), IsAvailable: true,
+		Schedules: []database.MenuItemSchedule{
+			{ScheduleWindow: database.ScheduleWindow{DayOfWeek: int(time.Monday), StartTime: "08:00", EndTime: "12:00"}},
+		},
+	})
+
+	_, categories, err := database.GetMenuAt(suite.business.ID, time.Date(2026, time.October, 12, 9, 0, 0, 0, suite.madrid))
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), categories[0].Items[0].IsAvailable)
+	require.Len(suite.T(), categories[0].Items[0].Schedules, 1)
+
+	_, categories, err = database.GetMenuAt(suite.business.ID, time.Date(2026, time.October, 12, 13, 0, 0, 0, suite.madrid))
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), categories[0].Items[0].IsAvailable)
+
+	// The stored flag is left alone; only the served menu reflects the schedule
+	_, categories, err = database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), categories[0].Items[0].IsAvailable)
+}
+
+func (suite *MenuScheduleTestSuite) TestSoldOutItemsComeBackOnTheirOwn() {
+	menu := suite.addMenu("All day", nil, database.MenuItem{Name: "Tortilla", Price: money.MustParse("3.50"), IsAvailable: true})
+	tortilla := menu.Categories[0].Items[0]
+
+	until := time.Now().Add(time.Hour)
+	item, err := database.SetMenuItemSoldOut(suite.business.ID, tortilla.ID, &until)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), item.SoldOutUntil)
+
+	_, err = database.PriceOrderItems(suite.business.ID, []database.MenuSelection{{MenuItemID: tortilla.ID, Quantity: 1}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuSelection)
+
+	_, categories, err := database.GetMenuAt(suite.business.ID, until.Add(time.Minute))
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), categories[0].Items[0].IsAvailable)
+
+	_, err = database.SetMenuItemSoldOut(suite.business.ID, tortilla.ID, nil)
+	require.NoError(suite.T(), err)
+	items, err := database.PriceOrderItems(suite.business.ID, []database.MenuSelection{{MenuItemID: tortilla.ID, Quantity: 1}})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), money.MustParse("3.50"), items[0].Subtotal)
+
+	_, err = database.SetMenuItemSoldOut(suite.business.ID+1, tortilla.ID, nil)
+	assert.ErrorIs(suite.T(), err, database.ErrMenuItemNotFound)
+}
+
+func (suite *MenuScheduleTestSuite) TestEndOfBusinessDay() {
+	// 23:30 UTC on the 12th is already 01:30 on the 13th in Madrid
+	end, err := database.EndOfBusinessDay(suite.business.ID, time.Date(2026, time.October, 12, 23, 30, 0, 0, time.UTC))
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), end.Equal(time.Date(2026, time.October, 14, 0, 0, 0, 0, suite.madrid)))
+}
+
+func (suite *MenuScheduleTestSuite) TestInvalidSchedulesAreRejected() {
+	for _, window := range []database.ScheduleWindow{
+		{DayOfWeek: 7, StartTime: "08:00", EndTime: "10:00"},
+		{DayOfWeek: 1, StartTime: "8:00", EndTime: "10:00"},
+		{DayOfWeek: 1, StartTime: "24:00", EndTime: "10:00"},
+		{DayOfWeek: 1, StartTime: "08:00", EndTime: "10:60"},
+	} {
+		menu := &database.Menu{BusinessID: suite.business.ID, Name: "Broken", IsActive: true,
+			Schedules: []database.MenuSchedule{{ScheduleWindow: window}}}
+		err := database.CreateMenu(menu, nil)
+		assert.ErrorIs(suite.T(), err, database.ErrInvalidSchedule, "%+v", window)
+	}
+
+	err := database.AddMenuCategory(suite.business.ID, 0, &database.MenuCategory{Name: "Bakery", Items: []database.MenuItem{{
+		Name: "Croissant", Price: money.MustParse("2.50"), IsAvailable: true,
+		Schedules: []database.MenuItemSchedule{
+			{ScheduleWindow: database.ScheduleWindow{DayOfWeek: 1, StartTime: "08:00", EndTime: "25:00"}},
+		},
+	}}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidSchedule)
+}
+
+func (suite *MenuScheduleTestSuite) TestCategoriesCanBeAddedToAnyMenu() {
+	suite.addMenu("All day", nil, database.MenuItem{Name: "Coffee", Price: money.MustParse("2.00"), IsAvailable: true})
+	breakfast := suite.addMenu("Breakfast", everyDay("07:00", "11:00"), database.MenuItem{Name: "Toast", Price: money.MustParse("4.00"), IsAvailable: true})
+
+	category := &database.MenuCategory{Name: "Pastries"}
+	require.NoError(suite.T(), database.AddMenuCategory(suite.business.ID, breakfast.ID, category))
+	assert.Equal(suite.T(), breakfast.ID, category.MenuID)
+
+	err := database.AddMenuCategory(suite.business.ID, breakfast.ID+100, &database.MenuCategory{Name: "Nowhere"})
+	assert.ErrorIs(suite.T(), err, database.ErrMenuNotFound)
+
+	menus, err := database.GetMenus(suite.business.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), menus, 2)
+	assert.Len(suite.T(), menus[1].Categories, 2)
+
+	categories, err := database.GetAllMenuCategories(suite.business.ID)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), categories, 3)
+
+	require.NoError(suite.T(), database.DeleteMenu(suite.business.ID, breakfast.ID))
+	var count int64
+	suite.db.Model(&database.MenuItem{}).Where("business_id = ?", suite.business.ID).Count(&count)
+	assert.Equal(suite.T(), int64(1), count)
+}
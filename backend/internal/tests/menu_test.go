@@ -0,0 +1,348 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type MenuTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	business *database.Business
+	menu     *database.Menu
+}
+
+func (suite *MenuTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *MenuTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *MenuTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM translations")
+	suite.db.Exec("DELETE FROM menu_modifier_options")
+	suite.db.Exec("DELETE FROM menu_schedules")
+	suite.db.Exec("DELETE FROM menu_item_schedules")
+	suite.db.Exec("DELETE FROM stock_adjustments")
+	suite.db.Exec("DELETE FROM menu_item_recipes")
+	suite.db.Exec("DELETE FROM stock_items")
+	suite.db.Exec("DELETE FROM menu_modifier_groups")
+	suite.db.Exec("DELETE FROM menu_item_images")
+	suite.db.Exec("DELETE FROM menu_item_options")
+	suite.db.Exec("DELETE FROM menu_items")
+	suite.db.Exec("DELETE FROM menu_categories")
+	suite.db.Exec("DELETE FROM menus")
+	suite.db.Exec("DELETE FROM businesses")
+
+	suite.business = &database.Business{
+		OwnerAddress:   "0xOwner",
+		Name:           "Menu Test",
+		SettlementAddr: "0xSettlement",
+		TippingAddr:    "0xTips",
+	}
+	require.NoError(suite.T(), suite.db.Create(suite.business).Error)
+
+	suite.menu = &database.Menu{BusinessID: suite.business.ID, IsActive: true}
+	require.NoError(suite.T(), database.CreateMenu(suite.menu, []database.MenuCategory{
+		{Name: "Starters", Items: []database.MenuItem{
+			{Name: "Bruschetta", Price: money.MustParse("7.00"), IsAvailable: true},
+		}},
+		{Name: "Mains", Items: []database.MenuItem{
+			{
+				Name: "Pasta", Price: money.MustParse("14.00"), IsAvailable: true,
+				Images:    []string{"https://img/pasta-1.jpg", "https://img/pasta-2.jpg"},
+				Allergens: []string{"gluten"},
+				Options:   []database.MenuItemOption{{Name: "Extra cheese", PriceChange: money.MustParse("1.50")}},
+			},
+			{Name: "Risotto", Price: money.MustParse("16.00"), IsAvailable: true},
+		}},
+	}))
+}
+
+func TestMenuTestSuite(t *testing.T) {
+	suite.Run(t, new(MenuTestSuite))
+}
+
+// item returns the stored menu item with the given name
+func (suite *MenuTestSuite) item(name string) database.MenuItem {
+	_, categories, err := database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	for _, category := range categories {
+		for _, item := range category.Items {
+			if item.Name == name {
+				return item
+			}
+		}
+	}
+	suite.T().Fatalf("menu item %s not found", name)
+	return database.MenuItem{}
+}
+
+func (suite *MenuTestSuite) TestMenuIsStoredInTables() {
+	menu, categories, err := database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.menu.ID, menu.ID)
+	require.Len(suite.T(), categories, 2)
+	assert.Equal(suite.T(), "Starters", categories[0].Name)
+	assert.Equal(suite.T(), "Mains", categories[1].Name)
+
+	pasta := categories[1].Items[0]
+	assert.NotZero(suite.T(), pasta.ID)
+	assert.Equal(suite.T(), categories[1].ID, pasta.CategoryID)
+	assert.Equal(suite.T(), money.MustParse("14.00"), pasta.Price)
+	assert.Equal(suite.T(), []string{"https://img/pasta-1.jpg", "https://img/pasta-2.jpg"}, pasta.Images)
+	assert.Equal(suite.T(), []string{"gluten"}, pasta.Allergens)
+	require.Len(suite.T(), pasta.Options, 1)
+	assert.NotZero(suite.T(), pasta.Options[0].ID)
+}
+
+// A business's menu translations cover its own entities only, and each entity resolves
+// to the business that owns it
+func (suite *MenuTestSuite) TestBusinessTranslations() {
+	pasta := suite.item("Pasta")
+	translations := database.NewTranslationService(suite.db)
+	save := func(entityType string, entityID uint, text string) {
+		require.NoError(suite.T(), translations.SaveTranslation(&database.Translation{
+			EntityType: entityType, EntityID: entityID, FieldName: "name", LanguageCode: "es", TranslatedText: text,
+		}))
+	}
+	save("menu_item", pasta.ID, "Pasta")
+	save("menu_item_option", pasta.Options[0].ID, "Queso extra")
+	save("category", pasta.CategoryID, "Principales")
+	save("menu_item", pasta.ID+1000, "Ajena")
+
+	found, err := translations.GetBusinessTranslations(suite.business.ID, "es")
+	require.NoError(suite.T(), err)
+	texts := make([]string, 0, len(found))
+	for _, translation := range found {
+		texts = append(texts, translation.TranslatedText)
+	}
+	assert.ElementsMatch(suite.T(), []string{"Pasta", "Queso extra", "Principales"}, texts)
+
+	businessID, err := database.GetTranslationEntityBusinessID("menu_item_option", pasta.Options[0].ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.business.ID, businessID)
+	_, err = database.GetTranslationEntityBusinessID("menu_item", pasta.ID+1000)
+	assert.ErrorIs(suite.T(), err, database.ErrTranslationEntityNotFound)
+}
+
+func (suite *MenuTestSuite) TestDeletingACategoryKeepsOtherIDs() {
+	_, categories, err := database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	pasta := suite.item("Pasta")
+
+	require.NoError(suite.T(), database.DeleteMenuCategory(suite.business.ID, categories[0].ID))
+
+	_, categories, err = database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), categories, 1)
+	stored, err := database.GetMenuItem(suite.business.ID, pasta.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Pasta", stored.Name)
+
+	var count int64
+	suite.db.Model(&database.MenuItem{}).Where("name = ?", "Bruschetta").Count(&count)
+	assert.Zero(suite.T(), count, "items of a deleted category are deleted with it")
+
+	err = database.DeleteMenuCategory(suite.business.ID, categories[0].ID+100)
+	assert.ErrorIs(suite.T(), err, database.ErrMenuCategoryNotFound)
+}
+
+func (suite *MenuTestSuite) TestUpdateMenuKeepsIDsOfKeptEntries() {
+	_, categories, err := database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	pasta := categories[1].Items[0]
+	risotto := categories[1].Items[1]
+
+	// Starters are dropped, the risotto is removed and the pasta changes price and options
+	mains := categories[1]
+	pasta.Price = money.MustParse("15.00")
+	pasta.Options = append(pasta.Options, database.MenuItemOption{Name: "Chili"})
+	mains.Items = []database.MenuItem{pasta, {Name: "Lasagna", Price: money.MustParse("13.00"), IsAvailable: true}}
+	require.NoError(suite.T(), database.UpdateMenu(suite.menu, []database.MenuCategory{mains}))
+
+	_, categories, err = database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), categories, 1)
+	assert.Equal(suite.T(), mains.ID, categories[0].ID)
+	require.Len(suite.T(), categories[0].Items, 2)
+
+	stored := categories[0].Items[0]
+	assert.Equal(suite.T(), pasta.ID, stored.ID)
+	assert.Equal(suite.T(), money.MustParse("15.00"), stored.Price)
+	require.Len(suite.T(), stored.Options, 2)
+	assert.Equal(suite.T(), pasta.Options[0].ID, stored.Options[0].ID)
+	assert.Equal(suite.T(), "Lasagna", categories[0].Items[1].Name)
+
+	_, err = database.GetMenuItem(suite.business.ID, risotto.ID)
+	assert.ErrorIs(suite.T(), err, database.ErrMenuItemNotFound)
+}
+
+func (suite *MenuTestSuite) TestItemsAreAddressedByID() {
+	_, categories, err := database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+	starters := categories[0]
+
+	soup := database.MenuItem{Name: "Soup", Price: money.MustParse("6.00"), IsAvailable: true}
+	require.NoError(suite.T(), database.AddMenuItem(suite.business.ID, starters.ID, &soup))
+	assert.NotZero(suite.T(), soup.ID)
+	assert.Equal(suite.T(), 1, soup.SortOrder)
+
+	// Moving the soup to the mains appends it there
+	soup.Price = money.MustParse("6.50")
+	soup.CategoryID = categories[1].ID
+	require.NoError(suite.T(), database.UpdateMenuItem(suite.business.ID, soup.ID, &soup))
+	stored, err := database.GetMenuItem(suite.business.ID, soup.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), categories[1].ID, stored.CategoryID)
+	assert.Equal(suite.T(), 2, stored.SortOrder)
+	assert.Equal(suite.T(), money.MustParse("6.50"), stored.Price)
+
+	// Items of another business cannot be touched
+	err = database.UpdateMenuItem(suite.business.ID+1, soup.ID, &soup)
+	assert.ErrorIs(suite.T(), err, database.ErrMenuItemNotFound)
+	err = database.AddMenuItem(suite.business.ID+1, starters.ID, &database.MenuItem{Name: "Bread"})
+	assert.ErrorIs(suite.T(), err, database.ErrMenuCategoryNotFound)
+
+	require.NoError(suite.T(), database.DeleteMenuItem(suite.business.ID, soup.ID))
+	err = database.DeleteMenuItem(suite.business.ID, soup.ID)
+	assert.ErrorIs(suite.T(), err, database.ErrMenuItemNotFound)
+}
+
+func (suite *MenuTestSuite) TestModifierGroupsKeepTheirIDs() {
+	pasta := suite.item("Pasta")
+	pasta.Modifiers = []database.ModifierGroup{
+		{Name: "Pasta", MinSelect: 1, MaxSelect: 1, Options: []database.ModifierOption{
+			{Name: "Spaghetti", IsDefault: true, IsAvailable: true},
+			{Name: "Gluten free", PriceChange: money.MustParse("2.00"), IsAvailable: true, Groups: []database.ModifierGroup{
+				{Name: "Shape", MaxSelect: 1, Options: []database.ModifierOption{{Name: "Penne", IsAvailable: true}}},
+			}},
+		}},
+	}
+	require.NoError(suite.T(), database.UpdateMenuItem(suite.business.ID, pasta.ID, &pasta))
+
+	stored := suite.item("Pasta")
+	require.Len(suite.T(), stored.Modifiers, 1)
+	group := stored.Modifiers[0]
+	assert.Equal(suite.T(), 1, group.MinSelect)
+	require.Len(suite.T(), group.Options, 2)
+	assert.True(suite.T(), group.Options[0].IsDefault)
+	require.Len(suite.T(), group.Options[1].Groups, 1)
+	shape := group.Options[1].Groups[0]
+	require.NotNil(suite.T(), shape.ParentOptionID)
+	assert.Equal(suite.T(), group.Options[1].ID, *shape.ParentOptionID)
+
+	// Spaghetti sells out and the nested group is dropped; the other IDs stay
+	stored.Modifiers[0].Options[0].IsAvailable = false
+	stored.Modifiers[0].Options[1].Groups = nil
+	require.NoError(suite.T(), database.UpdateMenuItem(suite.business.ID, pasta.ID, &stored))
+
+	updated := suite.item("Pasta")
+	require.Len(suite.T(), updated.Modifiers, 1)
+	assert.Equal(suite.T(), group.ID, updated.Modifiers[0].ID)
+	assert.Equal(suite.T(), group.Options[0].ID, updated.Modifiers[0].Options[0].ID)
+	assert.False(suite.T(), updated.Modifiers[0].Options[0].IsAvailable)
+	assert.Empty(suite.T(), updated.Modifiers[0].Options[1].Groups)
+
+	var count int64
+	suite.db.Model(&database.ModifierGroup{}).Where("id = ?", shape.ID).Count(&count)
+	assert.Zero(suite.T(), count)
+
+	require.NoError(suite.T(), database.DeleteMenuItem(suite.business.ID, pasta.ID))
+	suite.db.Model(&database.ModifierOption{}).Where("menu_item_id = ?", pasta.ID).Count(&count)
+	assert.Zero(suite.T(), count, "modifiers are deleted with their item")
+}
+
+func (suite *MenuTestSuite) TestMisconfiguredModifierGroupsAreRejected() {
+	cases := map[string]database.ModifierGroup{
+		"no options":         {Name: "Sauce", MinSelect: 1},
+		"min above max":      {Name: "Sauce", MinSelect: 2, MaxSelect: 1, Options: []database.ModifierOption{{Name: "A"}, {Name: "B"}}},
+		"min above options":  {Name: "Sauce", MinSelect: 2, Options: []database.ModifierOption{{Name: "A"}}},
+		"too many defaults":  {Name: "Sauce", MaxSelect: 1, Options: []database.ModifierOption{{Name: "A", IsDefault: true}, {Name: "B", IsDefault: true}}},
+		"unnamed nested one": {Name: "Sauce", Options: []database.ModifierOption{{Name: "A", Groups: []database.ModifierGroup{{Options: []database.ModifierOption{{Name: "B"}}}}}}},
+	}
+	pasta := suite.item("Pasta")
+	for name, group := range cases {
+		item := pasta
+		item.Modifiers = []database.ModifierGroup{group}
+		err := database.UpdateMenuItem(suite.business.ID, pasta.ID, &item)
+		assert.ErrorIs(suite.T(), err, database.ErrInvalidModifierGroup, name)
+	}
+}
+
+// Menus stored as one JSON document are moved into the menu tables once
+func TestMenuTablesMigration(t *testing.T) {
+	// Menus stored as JSON predate Postgres support, so only SQLite databases have them
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(
+		&database.Menu{},
+		&database.MenuCategory{},
+		&database.MenuItem{},
+		&database.MenuItemOption{},
+		&database.MenuItemImage{},
+		&database.ModifierGroup{},
+		&database.ModifierOption{},
+		&database.MenuSchedule{},
+		&database.MenuItemSchedule{},
+		&database.StockItem{},
+		&database.RecipeComponent{},
+		&database.StockAdjustment{},
+		&database.Translation{},
+		&database.DataMigration{},
+	))
+
+	require.NoError(t, db.Exec(`INSERT INTO menus (business_id, is_active, categories) VALUES (7, true, ?)`,
+		`[{"id":"cat_1","name":"Drinks","items":[
+			{"id":"item_1","name":"Lemonade","price":3.5,"is_available":true,"images":["https://img/lemonade.jpg"],
+			 "options":[{"id":"opt_1","name":"Large","price_change":1}]},
+			{"id":"item_2","name":"Espresso","price":2,"is_available":false}]}]`).Error)
+	require.NoError(t, db.Create(&database.Translation{
+		EntityType: "menu_item", EntityID: 0, FieldName: "name", LanguageCode: "es", TranslatedText: "Limonada",
+	}).Error)
+
+	require.NoError(t, database.ApplyDataMigrations(db))
+	// Applying again must not copy the menu a second time
+	require.NoError(t, database.ApplyDataMigrations(db))
+
+	var categories []database.MenuCategory
+	require.NoError(t, db.Preload("Items.Options").Preload("Items.ImageRecords").Find(&categories).Error)
+	require.Len(t, categories, 1)
+	assert.Equal(t, "Drinks", categories[0].Name)
+	assert.Equal(t, uint(7), categories[0].BusinessID)
+	require.Len(t, categories[0].Items, 2)
+
+	lemonade := categories[0].Items[0]
+	assert.Equal(t, "Lemonade", lemonade.Name)
+	assert.Equal(t, money.MustParse("3.50"), lemonade.Price)
+	assert.Equal(t, uint(7), lemonade.BusinessID)
+	require.Len(t, lemonade.Options, 1)
+	assert.Equal(t, money.MustParse("1.00"), lemonade.Options[0].PriceChange)
+	require.Len(t, lemonade.ImageRecords, 1)
+	assert.False(t, categories[0].Items[1].IsAvailable)
+
+	var menu database.Menu
+	require.NoError(t, db.First(&menu).Error)
+	assert.Empty(t, menu.LegacyCategories)
+
+	var translations int64
+	db.Model(&database.Translation{}).Count(&translations)
+	assert.Zero(t, translations, "position-based menu translations are dropped")
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/money"
+)
+
+// Rows written while amounts were stored as decimal dollars are converted to cents once
+func TestMoneyMinorUnitsMigration(t *testing.T) {
+	// Only SQLite databases predate minor units, and only SQLite accepts decimals in
+	// these integer columns
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&database.Bill{}, &database.Payment{}, &database.AlternativePayment{}, &database.DataMigration{}))
+
+	require.NoError(t, db.Exec(`INSERT INTO bills (business_id, bill_number, subtotal, tax_amount, service_fee_amount, total_amount, paid_amount, tip_amount, settlement_addr, tipping_addr)
+		VALUES (1, 'LEGACY-1', 25.98, 2.08, 3.9, 31.96, 10.1, 0.5, '0xs', '0xt')`).Error)
+	require.NoError(t, db.Exec(`INSERT INTO payments (bill_id, payer_addr, amount, tip_amount, tx_hash) VALUES (1, '0xp', 10.1, 0.5, '0xtx')`).Error)
+
+	require.NoError(t, database.ApplyDataMigrations(db))
+	// Applying again must not scale the amounts a second time
+	require.NoError(t, database.ApplyDataMigrations(db))
+
+	var bill database.Bill
+	require.NoError(t, db.Where("bill_number = ?", "LEGACY-1").First(&bill).Error)
+	assert.Equal(t, money.MustParse("25.98"), bill.Subtotal)
+	assert.Equal(t, money.MustParse("2.08"), bill.TaxAmount)
+	assert.Equal(t, money.MustParse("3.90"), bill.ServiceFeeAmount)
+	assert.Equal(t, money.MustParse("31.96"), bill.TotalAmount)
+	assert.Equal(t, money.MustParse("10.10"), bill.PaidAmount)
+	assert.Equal(t, money.MustParse("0.50"), bill.TipAmount)
+
+	var payment database.Payment
+	require.NoError(t, db.First(&payment).Error)
+	assert.Equal(t, money.MustParse("10.10"), payment.Amount)
+	assert.Equal(t, money.MustParse("0.50"), payment.TipAmount)
+
+	var applied int64
+	db.Model(&database.DataMigration{}).Where("name = ?", "money_minor_units").Count(&applied)
+	assert.Equal(t, int64(1), applied)
+}
@@ -0,0 +1,296 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type PaymentLedgerTestSuite struct {
+	suite.Suite
+	db   *gorm.DB
+	bill *database.Bill
+}
+
+func (suite *PaymentLedgerTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *PaymentLedgerTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *PaymentLedgerTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM alternative_payments")
+	suite.db.Exec("DELETE FROM payments")
+	suite.db.Exec("DELETE FROM table_sessions")
+	suite.db.Exec("DELETE FROM bills")
+
+	suite.bill = &database.Bill{
+		BusinessID:  1,
+		TableID:     1,
+		BillNumber:  "LEDGER-" + time.Now().Format("150405.000000"),
+		Subtotal:    money.MustParse("50.00"),
+		TotalAmount: money.MustParse("60.00"),
+		Status:      database.BillStatusOpen,
+	}
+	require.NoError(suite.T(), database.CreateBill(suite.bill, []database.BillItem{}))
+}
+
+func TestPaymentLedgerTestSuite(t *testing.T) {
+	suite.Run(t, new(PaymentLedgerTestSuite))
+}
+
+func (suite *PaymentLedgerTestSuite) indexedPayment(txHash string, logIndex uint, amount, tip string) *database.Payment {
+	return &database.Payment{
+		BillID:    suite.bill.ID,
+		PayerAddr: "0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c4",
+		Amount:    money.MustParse(amount),
+		TipAmount: money.MustParse(tip),
+		TxHash:    txHash,
+		LogIndex:  &logIndex,
+		Status:    database.PaymentStatusConfirmed,
+	}
+}
+
+func (suite *PaymentLedgerTestSuite) countPayments() int64 {
+	var count int64
+	suite.db.Model(&database.Payment{}).Where("bill_id = ?", suite.bill.ID).Count(&count)
+	return count
+}
+
+// Replaying the same on-chain event must not count it twice
+func (suite *PaymentLedgerTestSuite) TestReplayedEventIsNoOp() {
+	txHash := "0xAAA0000000000000000000000000000000000000000000000000000000000001"
+
+	bill, recorded, err := database.RecordPayment(suite.indexedPayment(txHash, 2, "20.00", "2.00"), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), recorded)
+	assert.Equal(suite.T(), money.MustParse("20.00"), bill.PaidAmount)
+	assert.Equal(suite.T(), money.MustParse("2.00"), bill.TipAmount)
+
+	for i := 0; i < 3; i++ {
+		bill, recorded, err = database.RecordPayment(suite.indexedPayment(txHash, 2, "20.00", "2.00"), database.BillActorIndexer)
+		require.NoError(suite.T(), err)
+		assert.False(suite.T(), recorded)
+	}
+
+	assert.Equal(suite.T(), int64(1), suite.countPayments())
+	assert.Equal(suite.T(), money.MustParse("20.00"), bill.PaidAmount)
+	assert.Equal(suite.T(), database.BillStatusPartiallyPaid, bill.Status)
+}
+
+// Two events in one transaction are distinct payments
+func (suite *PaymentLedgerTestSuite) TestSameTransactionDifferentLogIndex() {
+	txHash := "0xbbb0000000000000000000000000000000000000000000000000000000000002"
+
+	_, _, err := database.RecordPayment(suite.indexedPayment(txHash, 0, "30.00", "0.00"), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	bill, recorded, err := database.RecordPayment(suite.indexedPayment(txHash, 1, "30.00", "5.00"), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+
+	assert.True(suite.T(), recorded)
+	assert.Equal(suite.T(), int64(2), suite.countPayments())
+	assert.Equal(suite.T(), money.MustParse("60.00"), bill.PaidAmount)
+	assert.Equal(suite.T(), money.MustParse("5.00"), bill.TipAmount)
+	assert.Equal(suite.T(), database.BillStatusPaid, bill.Status)
+}
+
+// A webhook delivered before and after the indexed event resolves to one ledger row
+func (suite *PaymentLedgerTestSuite) TestWebhookAndEventForSameTransaction() {
+	txHash := "0xccc0000000000000000000000000000000000000000000000000000000000003"
+
+	webhook := func() *database.Payment {
+		return &database.Payment{
+			BillID:    suite.bill.ID,
+			PayerAddr: "webhook",
+			Amount:    money.MustParse("25.00"),
+			TxHash:    txHash,
+			Status:    database.PaymentStatusConfirmed,
+		}
+	}
+
+	bill, recorded, err := database.RecordPayment(webhook(), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), recorded)
+	assert.Equal(suite.T(), money.MustParse("25.00"), bill.PaidAmount)
+
+	// Redelivered webhook
+	_, recorded, err = database.RecordPayment(webhook(), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), recorded)
+
+	// Indexed event claims the row and corrects the amount from chain data
+	bill, recorded, err = database.RecordPayment(suite.indexedPayment(txHash, 4, "24.50", "1.00"), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), recorded)
+	assert.Equal(suite.T(), money.MustParse("24.50"), bill.PaidAmount)
+	assert.Equal(suite.T(), money.MustParse("1.00"), bill.TipAmount)
+
+	// Webhook arriving after the event
+	_, recorded, err = database.RecordPayment(webhook(), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), recorded)
+
+	assert.Equal(suite.T(), int64(1), suite.countPayments())
+}
+
+// Pending reports do not count until confirmed
+func (suite *PaymentLedgerTestSuite) TestPendingPaymentConfirmedLater() {
+	txHash := "0xddd0000000000000000000000000000000000000000000000000000000000004"
+
+	bill, _, err := database.RecordPayment(&database.Payment{
+		BillID: suite.bill.ID, PayerAddr: "webhook", Amount: money.MustParse("60.00"), TxHash: txHash, Status: database.PaymentStatusPending,
+	}, database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), money.Zero, bill.PaidAmount)
+
+	bill, recorded, err := database.RecordPayment(&database.Payment{
+		BillID: suite.bill.ID, PayerAddr: "webhook", Amount: money.MustParse("60.00"), TxHash: txHash, Status: database.PaymentStatusConfirmed,
+	}, database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), recorded)
+	assert.Equal(suite.T(), money.MustParse("60.00"), bill.PaidAmount)
+	assert.Equal(suite.T(), database.BillStatusPaid, bill.Status)
+}
+
+// A transaction cannot be attributed to a second bill
+func (suite *PaymentLedgerTestSuite) TestTransactionCannotMoveBetweenBills() {
+	txHash := "0xeee0000000000000000000000000000000000000000000000000000000000005"
+	_, _, err := database.RecordPayment(suite.indexedPayment(txHash, 0, "10.00", "0.00"), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+
+	other := &database.Bill{BusinessID: 1, TableID: 1, BillNumber: "LEDGER-OTHER", TotalAmount: money.MustParse("10.00"), Status: database.BillStatusOpen}
+	require.NoError(suite.T(), database.CreateBill(other, []database.BillItem{}))
+
+	payment := suite.indexedPayment(txHash, 0, "10.00", "0.00")
+	payment.BillID = other.ID
+	_, _, err = database.RecordPayment(payment, database.BillActorIndexer)
+	assert.Error(suite.T(), err)
+}
+
+// A transaction has at most one row waiting for its event to be indexed, even when
+// reports race past the lookup
+func (suite *PaymentLedgerTestSuite) TestOneUnindexedRowPerTransaction() {
+	txHash := "0xfff0000000000000000000000000000000000000000000000000000000000006"
+	pending := func() *database.Payment {
+		return &database.Payment{BillID: suite.bill.ID, PayerAddr: "crypto_guest", TxHash: txHash, Status: database.PaymentStatusPending}
+	}
+
+	require.NoError(suite.T(), suite.db.Create(pending()).Error)
+	assert.Error(suite.T(), suite.db.Create(pending()).Error)
+
+	_, recorded, err := database.RecordPayment(pending(), database.BillActorGuest)
+	require.NoError(suite.T(), err)
+	assert.False(suite.T(), recorded)
+	assert.Equal(suite.T(), int64(1), suite.countPayments())
+}
+
+// A pending report for the wrong bill does not stop the indexed event from being
+// recorded against the bill the chain names
+func (suite *PaymentLedgerTestSuite) TestIndexedEventIgnoresOtherBillsPendingReport() {
+	txHash := "0xabc0000000000000000000000000000000000000000000000000000000000007"
+	other := &database.Bill{BusinessID: 1, TableID: 1, BillNumber: "LEDGER-CLAIM", TotalAmount: money.MustParse("10.00"), Status: database.BillStatusOpen}
+	require.NoError(suite.T(), database.CreateBill(other, []database.BillItem{}))
+
+	_, _, err := database.RecordPayment(&database.Payment{
+		BillID: other.ID, PayerAddr: "crypto_guest", TxHash: txHash, Status: database.PaymentStatusPending,
+	}, database.BillActorGuest)
+	require.NoError(suite.T(), err)
+
+	bill, recorded, err := database.RecordPayment(suite.indexedPayment(txHash, 0, "60.00", "0.00"), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), recorded)
+	assert.Equal(suite.T(), database.BillStatusPaid, bill.Status)
+
+	unclaimed, _, err := database.GetBillByID(other.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), money.Zero, unclaimed.PaidAmount)
+}
+
+// A reported payment the indexer never confirms expires, but a late indexed event
+// still confirms it
+func (suite *PaymentLedgerTestSuite) TestPendingPaymentsExpire() {
+	txHash := "0xbbb0000000000000000000000000000000000000000000000000000000000008"
+	reported := suite.indexedPayment(txHash, 1, "60.00", "0.00")
+	reported.Status = database.PaymentStatusPending
+	_, _, err := database.RecordPayment(reported, database.BillActorGuest)
+	require.NoError(suite.T(), err)
+
+	expired, err := database.ExpirePendingPayments(time.Now().Add(-time.Hour))
+	require.NoError(suite.T(), err)
+	assert.Zero(suite.T(), expired, "recent reports are kept")
+
+	expired, err = database.ExpirePendingPayments(time.Now().Add(time.Second))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(1), expired)
+
+	var payment database.Payment
+	require.NoError(suite.T(), suite.db.Where("tx_hash = ?", txHash).First(&payment).Error)
+	assert.Equal(suite.T(), database.PaymentStatusFailed, payment.Status)
+
+	bill, recorded, err := database.RecordPayment(suite.indexedPayment(txHash, 1, "60.00", "0.00"), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), recorded)
+	assert.Equal(suite.T(), database.BillStatusPaid, bill.Status)
+}
+
+// Confirmed alternative payments count towards the bill alongside crypto payments
+func (suite *PaymentLedgerTestSuite) TestAlternativePaymentsIncludedInTotals() {
+	_, _, err := database.RecordPayment(suite.indexedPayment("0xfff0000000000000000000000000000000000000000000000000000000000006", 0, "40.00", "0.00"), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+
+	now := time.Now()
+	require.NoError(suite.T(), suite.db.Create(&database.AlternativePayment{
+		BillID: suite.bill.ID, ParticipantAddr: "guest", Amount: money.MustParse("20.00"), PaymentMethod: database.PaymentMethodCash,
+		Status: database.AltPaymentStatusConfirmed, ConfirmedAt: &now,
+	}).Error)
+	require.NoError(suite.T(), suite.db.Create(&database.AlternativePayment{
+		BillID: suite.bill.ID, ParticipantAddr: "guest", Amount: money.MustParse("100.00"), PaymentMethod: database.PaymentMethodCard,
+		Status: database.AltPaymentStatusPending,
+	}).Error)
+
+	bill, err := database.RecalculateBillPayments(suite.bill.ID, database.BillActorSystem)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), money.MustParse("60.00"), bill.PaidAmount)
+	assert.Equal(suite.T(), database.BillStatusPaid, bill.Status)
+
+	totals, err := database.GetBillPaymentTotals(suite.bill.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), money.MustParse("40.00"), totals.CryptoAmount)
+	assert.Equal(suite.T(), money.MustParse("20.00"), totals.AlternativeAmount)
+}
+
+// Staff marking a bill paid records only the part the ledger does not already cover
+func (suite *PaymentLedgerTestSuite) TestMarkBillAsPaidRecordsOutstandingAmount() {
+	_, _, err := database.RecordPayment(suite.indexedPayment("0x1110000000000000000000000000000000000000000000000000000000000007", 0, "45.00", "0.00"), database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+
+	require.NoError(suite.T(), database.MarkBillAsPaid(suite.bill.ID, money.MustParse("60.00"), money.MustParse("3.00"), "cash", "", "0xowner"))
+	require.NoError(suite.T(), database.MarkBillAsPaid(suite.bill.ID, money.MustParse("60.00"), money.MustParse("3.00"), "cash", "", "0xowner"))
+
+	var alternatives []database.AlternativePayment
+	suite.db.Where("bill_id = ?", suite.bill.ID).Find(&alternatives)
+	require.Len(suite.T(), alternatives, 1)
+	assert.Equal(suite.T(), money.MustParse("15.00"), alternatives[0].Amount)
+	assert.Equal(suite.T(), money.MustParse("3.00"), alternatives[0].TipAmount)
+
+	bill, err := database.RecalculateBillPayments(suite.bill.ID, database.BillActorSystem)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), money.MustParse("60.00"), bill.PaidAmount)
+	assert.Equal(suite.T(), database.BillStatusPaid, bill.Status)
+}
@@ -6,9 +6,10 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
 )
 
 type PayvergeSimpleTestSuite struct {
@@ -18,21 +19,10 @@ type PayvergeSimpleTestSuite struct {
 
 func (suite *PayvergeSimpleTestSuite) SetupSuite() {
 	// Create a temporary test database
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	assert.NoError(suite.T(), err)
+	db := dbtest.Open(suite.T())
 	
 	suite.db = db
 	database.InitTestDB(db)
-	
-	// Auto-migrate all models
-	err = db.AutoMigrate(
-		&database.Business{},
-		&database.Menu{},
-		&database.Table{},
-		&database.Bill{},
-		&database.Payment{},
-	)
-	assert.NoError(suite.T(), err)
 }
 
 func (suite *PayvergeSimpleTestSuite) TearDownSuite() {
@@ -45,8 +35,20 @@ func (suite *PayvergeSimpleTestSuite) TearDownSuite() {
 func (suite *PayvergeSimpleTestSuite) SetupTest() {
 	// Clean up data before each test
 	suite.db.Exec("DELETE FROM payments")
+	suite.db.Exec("DELETE FROM table_sessions")
 	suite.db.Exec("DELETE FROM bills")
 	suite.db.Exec("DELETE FROM tables")
+	suite.db.Exec("DELETE FROM menu_modifier_options")
+	suite.db.Exec("DELETE FROM menu_schedules")
+	suite.db.Exec("DELETE FROM menu_item_schedules")
+	suite.db.Exec("DELETE FROM stock_adjustments")
+	suite.db.Exec("DELETE FROM menu_item_recipes")
+	suite.db.Exec("DELETE FROM stock_items")
+	suite.db.Exec("DELETE FROM menu_modifier_groups")
+	suite.db.Exec("DELETE FROM menu_item_images")
+	suite.db.Exec("DELETE FROM menu_item_options")
+	suite.db.Exec("DELETE FROM menu_items")
+	suite.db.Exec("DELETE FROM menu_categories")
 	suite.db.Exec("DELETE FROM menus")
 	suite.db.Exec("DELETE FROM businesses")
 }
@@ -137,7 +139,7 @@ func (suite *PayvergeSimpleTestSuite) TestMenuOperations() {
 				{
 					Name:        "Caesar Salad",
 					Description: "Fresh romaine lettuce",
-					Price:       12.99,
+					Price:       money.MustParse("12.99"),
 					IsAvailable: true,
 				},
 			},
@@ -240,7 +242,7 @@ func (suite *PayvergeSimpleTestSuite) TestBillOperations() {
 	items := []database.BillItem{
 		{
 			Name:     "Caesar Salad",
-			Price:    12.99,
+			Price:    money.MustParse("12.99"),
 			Quantity: 2,
 		},
 	}
@@ -249,10 +251,10 @@ func (suite *PayvergeSimpleTestSuite) TestBillOperations() {
 		BusinessID:       business.ID,
 		TableID:          table.ID,
 		BillNumber:       "BILL001",
-		Subtotal:         25.98,
-		TaxAmount:        2.08,
-		ServiceFeeAmount: 3.90,
-		TotalAmount:      31.96,
+		Subtotal:         money.MustParse("25.98"),
+		TaxAmount:        money.MustParse("2.08"),
+		ServiceFeeAmount: money.MustParse("3.90"),
+		TotalAmount:      money.MustParse("31.96"),
 		Status:           database.BillStatusOpen,
 		CreatedAt:        time.Now(),
 		UpdatedAt:        time.Now(),
@@ -302,8 +304,8 @@ func (suite *PayvergeSimpleTestSuite) TestPaymentOperations() {
 		BusinessID:  business.ID,
 		TableID:     table.ID,
 		BillNumber:  "BILL001",
-		Subtotal:    50.00,
-		TotalAmount: 60.00,
+		Subtotal:    money.MustParse("50.00"),
+		TotalAmount: money.MustParse("60.00"),
 		Status:      database.BillStatusOpen,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
@@ -315,8 +317,8 @@ func (suite *PayvergeSimpleTestSuite) TestPaymentOperations() {
 	payment := &database.Payment{
 		BillID:    bill.ID,
 		PayerAddr: "0x742d35Cc6635C0532925a3b8D400E4C3f2c0C1c4",
-		Amount:    60.00,
-		TipAmount: 10.00,
+		Amount:    money.MustParse("60.00"),
+		TipAmount: money.MustParse("10.00"),
 		TxHash:    "0x1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
 		Status:    database.PaymentStatusPending,
 		CreatedAt: time.Now(),
@@ -0,0 +1,367 @@
+package tests
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type PricingTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	business *database.Business
+	menu     *database.Menu
+	ids      map[string]uint // menu item and option IDs by name
+}
+
+func (suite *PricingTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *PricingTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *PricingTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM orders")
+	suite.db.Exec("DELETE FROM bill_events")
+	suite.db.Exec("DELETE FROM table_sessions")
+	suite.db.Exec("DELETE FROM bills")
+	suite.db.Exec("DELETE FROM menu_modifier_options")
+	suite.db.Exec("DELETE FROM menu_schedules")
+	suite.db.Exec("DELETE FROM menu_item_schedules")
+	suite.db.Exec("DELETE FROM stock_adjustments")
+	suite.db.Exec("DELETE FROM menu_item_recipes")
+	suite.db.Exec("DELETE FROM stock_items")
+	suite.db.Exec("DELETE FROM menu_modifier_groups")
+	suite.db.Exec("DELETE FROM menu_item_images")
+	suite.db.Exec("DELETE FROM menu_item_options")
+	suite.db.Exec("DELETE FROM menu_items")
+	suite.db.Exec("DELETE FROM menu_categories")
+	suite.db.Exec("DELETE FROM menus")
+	suite.db.Exec("DELETE FROM businesses")
+
+	suite.business = &database.Business{
+		OwnerAddress:   "0xOwner",
+		Name:           "Pricing Test",
+		SettlementAddr: "0xSettlement",
+		TippingAddr:    "0xTips",
+	}
+	require.NoError(suite.T(), suite.db.Create(suite.business).Error)
+
+	suite.ids = map[string]uint{}
+	suite.menu = &database.Menu{BusinessID: suite.business.ID, IsActive: true}
+	categories := suite.categories(money.MustParse("24.00"))
+	require.NoError(suite.T(), database.CreateMenu(suite.menu, categories))
+	for _, item := range categories[0].Items {
+		suite.ids[item.Name] = item.ID
+		for _, option := range item.Options {
+			suite.ids[option.Name] = option.ID
+		}
+	}
+}
+
+func TestPricingTestSuite(t *testing.T) {
+	suite.Run(t, new(PricingTestSuite))
+}
+
+// categories is the test menu with the steak at the given price. Items and options
+// keep the IDs they were created with.
+func (suite *PricingTestSuite) categories(steakPrice money.Amount) []database.MenuCategory {
+	return []database.MenuCategory{{
+		Name: "Mains",
+		Items: []database.MenuItem{
+			{
+				ID: suite.ids["Steak"], Name: "Steak", Price: steakPrice, IsAvailable: true,
+				Options: []database.MenuItemOption{
+					{ID: suite.ids["Cooked medium"], Name: "Cooked medium", IsRequired: true},
+					{ID: suite.ids["Pepper sauce"], Name: "Pepper sauce", PriceChange: money.MustParse("2.50")},
+					{ID: suite.ids["Fries"], Name: "Fries", PriceChange: money.MustParse("3.00")},
+				},
+			},
+			{ID: suite.ids["Soup"], Name: "Soup", Price: money.MustParse("6.00"), IsAvailable: true},
+			{ID: suite.ids["Lobster"], Name: "Lobster", Price: money.MustParse("45.00"), IsAvailable: false},
+		},
+	}}
+}
+
+func (suite *PricingTestSuite) TestPricesComeFromTheMenu() {
+	items, err := database.PriceOrderItems(suite.business.ID, []database.MenuSelection{
+		{MenuItemID: suite.ids["Steak"], OptionIDs: []uint{suite.ids["Fries"], suite.ids["Cooked medium"]}, Quantity: 2, SpecialRequests: "no salt"},
+		{MenuItemID: suite.ids["Soup"], Quantity: 1},
+	})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), items, 2)
+
+	assert.Equal(suite.T(), strconv.FormatUint(uint64(suite.ids["Steak"]), 10), items[0].MenuItemID)
+	assert.Equal(suite.T(), "Steak", items[0].MenuItemName)
+	assert.Equal(suite.T(), money.MustParse("24.00"), items[0].Price)
+	assert.Equal(suite.T(), money.MustParse("54.00"), items[0].Subtotal)
+	assert.Equal(suite.T(), "no salt", items[0].SpecialRequests)
+	require.Len(suite.T(), items[0].Options, 2)
+	assert.Equal(suite.T(), "Cooked medium", items[0].Options[0].Name, "options keep the menu's order")
+	assert.Equal(suite.T(), money.MustParse("6.00"), items[1].Subtotal)
+	assert.NotEqual(suite.T(), items[0].ID, items[1].ID)
+
+	billItems, err := database.PriceBillItems(suite.business.ID, []database.MenuSelection{
+		{MenuItemID: suite.ids["Steak"], OptionIDs: []uint{suite.ids["Cooked medium"], suite.ids["Pepper sauce"]}, Quantity: 1},
+	})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), billItems, 1)
+	assert.Equal(suite.T(), "Steak", billItems[0].Name)
+	assert.Equal(suite.T(), money.MustParse("26.50"), billItems[0].Subtotal)
+}
+
+func (suite *PricingTestSuite) TestInvalidSelectionsAreRejected() {
+	steak, medium, fries := suite.ids["Steak"], suite.ids["Cooked medium"], suite.ids["Fries"]
+	cases := map[string]database.MenuSelection{
+		"unknown item":            {MenuItemID: 9999, Quantity: 1},
+		"unavailable item":        {MenuItemID: suite.ids["Lobster"], Quantity: 1},
+		"missing required option": {MenuItemID: steak, OptionIDs: []uint{fries}, Quantity: 1},
+		"unknown option":          {MenuItemID: steak, OptionIDs: []uint{medium, 9999}, Quantity: 1},
+		"option of another item":  {MenuItemID: suite.ids["Soup"], OptionIDs: []uint{fries}, Quantity: 1},
+		"repeated option":         {MenuItemID: steak, OptionIDs: []uint{medium, fries, fries}, Quantity: 1},
+		"zero quantity":           {MenuItemID: suite.ids["Soup"], Quantity: 0},
+	}
+	for name, selection := range cases {
+		_, err := database.PriceOrderItems(suite.business.ID, []database.MenuSelection{selection})
+		assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuSelection, name)
+	}
+
+	_, err := database.PriceOrderItems(suite.business.ID+1, []database.MenuSelection{{MenuItemID: suite.ids["Soup"], Quantity: 1}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuSelection, "a business without a menu")
+}
+
+func (suite *PricingTestSuite) TestMenuEditsDoNotRewriteHistory() {
+	bill := &database.Bill{
+		BusinessID: suite.business.ID,
+		TableID:    1,
+		BillNumber: "PRICE-" + time.Now().Format("150405.000000"),
+		Status:     database.BillStatusOpen,
+	}
+	require.NoError(suite.T(), database.CreateBill(bill, []database.BillItem{}))
+
+	items, err := database.PriceOrderItems(suite.business.ID, []database.MenuSelection{
+		{MenuItemID: suite.ids["Steak"], OptionIDs: []uint{suite.ids["Cooked medium"], suite.ids["Pepper sauce"]}, Quantity: 1},
+	})
+	require.NoError(suite.T(), err)
+	order := &database.Order{
+		BillID:      bill.ID,
+		BusinessID:  suite.business.ID,
+		OrderNumber: "O-1",
+		Status:      database.OrderStatusPending,
+	}
+	require.NoError(suite.T(), database.CreateOrder(order, items))
+
+	// The steak gets more expensive before the order is approved
+	require.NoError(suite.T(), database.UpdateMenu(suite.menu, suite.categories(money.MustParse("30.00"))))
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"))
+
+	stored, billItems, err := database.GetBillByID(bill.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), billItems, 1)
+	assert.Equal(suite.T(), strconv.FormatUint(uint64(suite.ids["Steak"]), 10), billItems[0].MenuItemID)
+	assert.Equal(suite.T(), money.MustParse("24.00"), billItems[0].Price)
+	assert.Equal(suite.T(), money.MustParse("26.50"), billItems[0].Subtotal)
+	assert.Len(suite.T(), billItems[0].Options, 2)
+	assert.Equal(suite.T(), money.MustParse("26.50"), stored.Subtotal)
+}
+
+// An order's items reach the bill once when it is approved and leave it when the
+// approved order is cancelled
+func (suite *PricingTestSuite) TestCancelledOrderLeavesTheBill() {
+	bill := &database.Bill{
+		BusinessID: suite.business.ID,
+		TableID:    1,
+		BillNumber: "PRICE-" + time.Now().Format("150405.000000"),
+		Status:     database.BillStatusOpen,
+	}
+	require.NoError(suite.T(), database.CreateBill(bill, []database.BillItem{}))
+	order := func(name string, options ...uint) *database.Order {
+		items, err := database.PriceOrderItems(suite.business.ID, []database.MenuSelection{{MenuItemID: suite.ids[name], OptionIDs: options, Quantity: 1}})
+		require.NoError(suite.T(), err)
+		order := &database.Order{BillID: bill.ID, BusinessID: suite.business.ID, OrderNumber: name, Status: database.OrderStatusPending}
+		require.NoError(suite.T(), database.CreateOrder(order, items))
+		require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"))
+		return order
+	}
+	steak := order("Steak", suite.ids["Cooked medium"])
+	soup := order("Soup")
+
+	// Approving again changes nothing
+	require.NoError(suite.T(), database.UpdateOrderStatus(steak.ID, database.OrderStatusApproved, "0xOwner"))
+	stored, billItems, err := database.GetBillByID(bill.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), billItems, 2)
+	withSoup := stored.Subtotal
+
+	require.NoError(suite.T(), database.UpdateOrderStatus(steak.ID, database.OrderStatusOrderCancelled, "0xOwner"))
+	stored, billItems, err = database.GetBillByID(bill.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), billItems, 1)
+	assert.Equal(suite.T(), strconv.FormatUint(uint64(suite.ids["Soup"]), 10), billItems[0].MenuItemID)
+	assert.Equal(suite.T(), billItems[0].Subtotal, stored.Subtotal)
+	assert.Less(suite.T(), int64(stored.Subtotal), int64(withSoup))
+
+	var invalid *database.InvalidOrderTransitionError
+	assert.ErrorAs(suite.T(), database.UpdateOrderStatus(steak.ID, database.OrderStatusApproved, "0xOwner"), &invalid)
+	require.NoError(suite.T(), database.UpdateOrderStatus(soup.ID, database.OrderStatusOrderReady, "0xOwner"))
+	require.NoError(suite.T(), database.UpdateOrderStatus(soup.ID, database.OrderStatusOrderDelivered, "0xOwner"))
+	assert.ErrorAs(suite.T(), database.UpdateOrderStatus(soup.ID, database.OrderStatusOrderCancelled, "0xOwner"), &invalid)
+}
+
+func (suite *PricingTestSuite) TestMenuEditsKeepItemIDs() {
+	// The soup is dropped and the steak gets a new price; the steak stays orderable by its ID
+	categories := suite.categories(money.MustParse("30.00"))
+	categories[0].Items = categories[0].Items[:1]
+	require.NoError(suite.T(), database.UpdateMenu(suite.menu, categories))
+
+	items, err := database.PriceBillItems(suite.business.ID, []database.MenuSelection{
+		{MenuItemID: suite.ids["Steak"], OptionIDs: []uint{suite.ids["Cooked medium"]}, Quantity: 1},
+	})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), money.MustParse("30.00"), items[0].Subtotal)
+
+	_, err = database.PriceBillItems(suite.business.ID, []database.MenuSelection{{MenuItemID: suite.ids["Soup"], Quantity: 1}})
+	assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuSelection)
+}
+
+// pizza adds a pizza with a size, up to two toppings and a crust offered only for the
+// large size, and returns it as stored
+func (suite *PricingTestSuite) pizza() database.MenuItem {
+	_, categories, err := database.GetMenuByBusinessID(suite.business.ID)
+	require.NoError(suite.T(), err)
+
+	pizza := database.MenuItem{
+		Name: "Pizza", Price: money.MustParse("10.00"), IsAvailable: true,
+		Modifiers: []database.ModifierGroup{
+			{Name: "Size", MinSelect: 1, MaxSelect: 1, Options: []database.ModifierOption{
+				{Name: "Regular", IsDefault: true, IsAvailable: true},
+				{Name: "Large", PriceChange: money.MustParse("4.00"), IsAvailable: true, Groups: []database.ModifierGroup{
+					{Name: "Crust", MinSelect: 1, MaxSelect: 1, Options: []database.ModifierOption{
+						{Name: "Thin", IsAvailable: true},
+						{Name: "Stuffed", PriceChange: money.MustParse("2.00"), IsAvailable: true},
+					}},
+				}},
+			}},
+			{Name: "Toppings", MaxSelect: 2, Options: []database.ModifierOption{
+				{Name: "Ham", PriceChange: money.MustParse("1.50"), IsAvailable: true},
+				{Name: "Olives", PriceChange: money.MustParse("1.00"), IsAvailable: true},
+				{Name: "Mushrooms", PriceChange: money.MustParse("1.00"), IsAvailable: true},
+				{Name: "Truffle", PriceChange: money.MustParse("5.00"), IsAvailable: false},
+			}},
+		},
+	}
+	require.NoError(suite.T(), database.AddMenuItem(suite.business.ID, categories[0].ID, &pizza))
+	stored, err := database.GetMenuItem(suite.business.ID, pizza.ID)
+	require.NoError(suite.T(), err)
+	return *stored
+}
+
+func (suite *PricingTestSuite) TestModifiersArePricedAndSnapshotted() {
+	pizza := suite.pizza()
+	size, toppings := pizza.Modifiers[0], pizza.Modifiers[1]
+	large := size.Options[1]
+	crust := large.Groups[0]
+
+	items, err := database.PriceOrderItems(suite.business.ID, []database.MenuSelection{{
+		MenuItemID: pizza.ID,
+		Quantity:   2,
+		Modifiers: []database.ModifierSelection{
+			{GroupID: size.ID, OptionIDs: []uint{large.ID}},
+			{GroupID: crust.ID, OptionIDs: []uint{crust.Options[1].ID}},
+			{GroupID: toppings.ID, OptionIDs: []uint{toppings.Options[2].ID, toppings.Options[0].ID}},
+		},
+	}})
+	require.NoError(suite.T(), err)
+	// 10.00 + 4.00 large + 2.00 stuffed + 1.50 ham + 1.00 mushrooms, twice
+	assert.Equal(suite.T(), money.MustParse("37.00"), items[0].Subtotal)
+
+	modifiers := items[0].Modifiers
+	require.Len(suite.T(), modifiers, 2)
+	assert.Equal(suite.T(), "Size", modifiers[0].Name)
+	require.Len(suite.T(), modifiers[0].Options, 1)
+	assert.Equal(suite.T(), "Large", modifiers[0].Options[0].Name)
+	require.Len(suite.T(), modifiers[0].Options[0].Groups, 1)
+	assert.Equal(suite.T(), "Stuffed", modifiers[0].Options[0].Groups[0].Options[0].Name)
+	require.Len(suite.T(), modifiers[1].Options, 2)
+	assert.Equal(suite.T(), "Ham", modifiers[1].Options[0].Name, "options keep the menu's order")
+
+	// Groups left out get their defaults
+	billItems, err := database.PriceBillItems(suite.business.ID, []database.MenuSelection{{MenuItemID: pizza.ID, Quantity: 1}})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), money.MustParse("10.00"), billItems[0].Subtotal)
+	require.Len(suite.T(), billItems[0].Modifiers, 1)
+	assert.Equal(suite.T(), "Regular", billItems[0].Modifiers[0].Options[0].Name)
+	assert.Equal(suite.T(), money.MustParse("10.00"), database.UnitPrice(billItems[0].Price, billItems[0].Options, billItems[0].Modifiers))
+}
+
+func (suite *PricingTestSuite) TestModifierLimitsAreEnforced() {
+	pizza := suite.pizza()
+	size, toppings := pizza.Modifiers[0], pizza.Modifiers[1]
+	regular, large := size.Options[0], size.Options[1]
+	crust := large.Groups[0]
+
+	cases := map[string][]database.ModifierSelection{
+		"no size":                 {{GroupID: size.ID, OptionIDs: []uint{}}},
+		"two sizes":               {{GroupID: size.ID, OptionIDs: []uint{regular.ID, large.ID}}},
+		"too many toppings":       {{GroupID: toppings.ID, OptionIDs: []uint{toppings.Options[0].ID, toppings.Options[1].ID, toppings.Options[2].ID}}},
+		"unavailable topping":     {{GroupID: toppings.ID, OptionIDs: []uint{toppings.Options[3].ID}}},
+		"option of another group": {{GroupID: toppings.ID, OptionIDs: []uint{regular.ID}}},
+		"large without crust":     {{GroupID: size.ID, OptionIDs: []uint{large.ID}}},
+		"crust on a regular":      {{GroupID: crust.ID, OptionIDs: []uint{crust.Options[0].ID}}},
+		"group chosen twice": {
+			{GroupID: toppings.ID, OptionIDs: []uint{toppings.Options[0].ID}},
+			{GroupID: toppings.ID, OptionIDs: []uint{toppings.Options[1].ID}},
+		},
+	}
+	for name, modifiers := range cases {
+		_, err := database.PriceOrderItems(suite.business.ID, []database.MenuSelection{
+			{MenuItemID: pizza.ID, Quantity: 1, Modifiers: modifiers},
+		})
+		assert.ErrorIs(suite.T(), err, database.ErrInvalidMenuSelection, name)
+	}
+}
+
+func (suite *PricingTestSuite) TestApprovedOrdersCarryModifiersToTheBill() {
+	pizza := suite.pizza()
+	bill := &database.Bill{
+		BusinessID: suite.business.ID,
+		TableID:    1,
+		BillNumber: "MOD-" + time.Now().Format("150405.000000"),
+		Status:     database.BillStatusOpen,
+	}
+	require.NoError(suite.T(), database.CreateBill(bill, []database.BillItem{}))
+
+	items, err := database.PriceOrderItems(suite.business.ID, []database.MenuSelection{{
+		MenuItemID: pizza.ID,
+		Quantity:   1,
+		Modifiers:  []database.ModifierSelection{{GroupID: pizza.Modifiers[1].ID, OptionIDs: []uint{pizza.Modifiers[1].Options[1].ID}}},
+	}})
+	require.NoError(suite.T(), err)
+	order := &database.Order{BillID: bill.ID, BusinessID: suite.business.ID, OrderNumber: "O-MOD", Status: database.OrderStatusPending}
+	require.NoError(suite.T(), database.CreateOrder(order, items))
+	require.NoError(suite.T(), database.UpdateOrderStatus(order.ID, database.OrderStatusApproved, "0xOwner"))
+
+	_, billItems, err := database.GetBillByID(bill.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), billItems, 1)
+	require.Len(suite.T(), billItems[0].Modifiers, 2)
+	assert.Equal(suite.T(), "Olives", billItems[0].Modifiers[1].Options[0].Name)
+	assert.Equal(suite.T(), money.MustParse("11.00"), billItems[0].Subtotal)
+}
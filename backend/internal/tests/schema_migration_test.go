@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+)
+
+// assertSchemaMatchesModels checks that every model has its table and every field its column
+func assertSchemaMatchesModels(t *testing.T, db *gorm.DB) {
+	for _, model := range database.Models() {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		if !assert.True(t, db.Migrator().HasTable(model), "table %s", stmt.Schema.Table) {
+			continue
+		}
+		for _, column := range stmt.Schema.DBNames {
+			assert.True(t, db.Migrator().HasColumn(model, column), "column %s.%s", stmt.Schema.Table, column)
+		}
+	}
+}
+
+// The versioned migrations build the schema the models describe, and applying them
+// again changes nothing
+func TestSchemaMigrations(t *testing.T) {
+	db := dbtest.OpenEmpty(t)
+	database.InitTestDB(db)
+
+	require.NoError(t, database.Migrate(db))
+	require.NoError(t, database.Migrate(db))
+	assertSchemaMatchesModels(t, db)
+
+	var applied []database.SchemaMigration
+	require.NoError(t, db.Order("version").Find(&applied).Error)
+	require.NotEmpty(t, applied)
+	assert.Equal(t, uint(1), applied[0].Version)
+	assert.Equal(t, "baseline", applied[0].Name)
+}
+
+// A database auto-migrated before versioned migrations is brought up to the baseline
+// instead of running it, and loses the index the baseline no longer has
+func TestSchemaMigrationsAdoptLegacySchema(t *testing.T) {
+	db := dbtest.OpenEmpty(t)
+	database.InitTestDB(db)
+
+	require.NoError(t, db.AutoMigrate(&database.Business{}, &database.Payment{}))
+	require.NoError(t, db.Exec("CREATE UNIQUE INDEX idx_payments_tx_hash ON payments (tx_hash)").Error)
+
+	require.NoError(t, database.Migrate(db))
+	assertSchemaMatchesModels(t, db)
+	assert.False(t, db.Migrator().HasIndex(&database.Payment{}, "idx_payments_tx_hash"))
+
+	var applied []database.SchemaMigration
+	require.NoError(t, db.Order("version").Find(&applied).Error)
+	require.NotEmpty(t, applied)
+	assert.Equal(t, uint(1), applied[0].Version)
+}
+
+// Users from before notification preferences get every notification turned on, and
+// the tables of the old kitchen module are dropped
+func TestDataMigrationsUpgradeLegacyData(t *testing.T) {
+	db := dbtest.OpenEmpty(t)
+	database.InitTestDB(db)
+
+	require.NoError(t, db.AutoMigrate(&database.Business{}, &database.User{}))
+	require.NoError(t, db.Exec("CREATE TABLE kitchen_orders (id integer PRIMARY KEY)").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (address) VALUES ('0xlegacy')").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (address, email_enabled, news_enabled) VALUES ('0xoptedout', ?, ?)", false, false).Error)
+
+	require.NoError(t, database.Migrate(db))
+	assert.False(t, db.Migrator().HasTable("kitchen_orders"))
+
+	var legacy, optedOut database.User
+	require.NoError(t, db.Where("address = ?", "0xlegacy").First(&legacy).Error)
+	assert.True(t, legacy.NotificationPreferences.EmailEnabled)
+	assert.True(t, legacy.NotificationPreferences.StatisticsEnabled)
+	require.NoError(t, db.Where("address = ?", "0xoptedout").First(&optedOut).Error)
+	assert.False(t, optedOut.NotificationPreferences.EmailEnabled)
+}
+
+// Kitchen routes that named menu items and categories are pointed at their IDs, and
+// routes naming nothing on the menu are dropped
+func TestKitchenRoutesMigratedToIDs(t *testing.T) {
+	db := dbtest.OpenEmpty(t)
+	database.InitTestDB(db)
+
+	require.NoError(t, db.AutoMigrate(&database.Business{}, &database.MenuCategory{}, &database.MenuItem{}, &database.DataMigration{}))
+	require.NoError(t, db.Exec(`CREATE TABLE "kitchen_routes" ("id" integer PRIMARY KEY,"business_id" integer NOT NULL,"station_id" integer NOT NULL,`+
+		`"category" text,"item_name" text,"created_at" timestamp,"category_id" integer,"menu_item_id" integer)`).Error)
+
+	drinks := &database.MenuCategory{MenuID: 1, BusinessID: 1, Name: "Drinks"}
+	require.NoError(t, db.Create(drinks).Error)
+	lemonade := &database.MenuItem{BusinessID: 1, CategoryID: drinks.ID, Name: "Lemonade"}
+	require.NoError(t, db.Create(lemonade).Error)
+	require.NoError(t, db.Exec(`INSERT INTO kitchen_routes (id, business_id, station_id, category, item_name) VALUES
+		(1, 1, 10, 'drinks', NULL), (2, 1, 11, NULL, 'LEMONADE'), (3, 1, 12, 'Desserts', NULL), (4, 2, 13, 'Drinks', NULL)`).Error)
+
+	require.NoError(t, database.ApplyDataMigrations(db))
+	assert.False(t, db.Migrator().HasColumn(&database.KitchenRoute{}, "item_name"))
+
+	var routes []database.KitchenRoute
+	require.NoError(t, db.Order("station_id").Find(&routes).Error)
+	require.Len(t, routes, 2)
+	assert.Equal(t, uint(10), routes[0].StationID)
+	assert.Equal(t, drinks.ID, routes[0].CategoryID)
+	assert.Equal(t, uint(11), routes[1].StationID)
+	assert.Equal(t, lemonade.ID, routes[1].MenuItemID)
+}
+
+// JSON columns round-trip their documents and store an empty one as NULL
+func TestJSONColumn(t *testing.T) {
+	db := dbtest.OpenEmpty(t)
+	database.InitTestDB(db)
+	require.NoError(t, database.Migrate(db))
+
+	items, err := json.Marshal([]database.BillItem{{ID: "item_1", Name: "Lemonade", Quantity: 2}})
+	require.NoError(t, err)
+	bill := &database.Bill{BusinessID: 1, BillNumber: "JSON-1", Items: database.JSON(items), SettlementAddr: "0xs", TippingAddr: "0xt"}
+	require.NoError(t, db.Create(bill).Error)
+	empty := &database.Bill{BusinessID: 1, BillNumber: "JSON-2", SettlementAddr: "0xs", TippingAddr: "0xt"}
+	require.NoError(t, db.Create(empty).Error)
+
+	var found database.Bill
+	require.NoError(t, db.First(&found, bill.ID).Error)
+	var decoded []database.BillItem
+	require.NoError(t, json.Unmarshal([]byte(found.Items), &decoded))
+	require.Len(t, decoded, 1)
+	assert.Equal(t, "Lemonade", decoded[0].Name)
+	assert.Equal(t, 2, decoded[0].Quantity)
+
+	var nulls int64
+	require.NoError(t, db.Model(&database.Bill{}).Where("items IS NULL").Count(&nulls).Error)
+	assert.Equal(t, int64(1), nulls)
+	var foundEmpty database.Bill
+	require.NoError(t, db.First(&foundEmpty, empty.ID).Error)
+	assert.Empty(t, foundEmpty.Items)
+}
@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+type SplitSessionTestSuite struct {
+	suite.Suite
+	db   *gorm.DB
+	bill *database.Bill
+}
+
+func (suite *SplitSessionTestSuite) SetupSuite() {
+	db := dbtest.Open(suite.T())
+
+	suite.db = db
+	database.InitTestDB(db)
+}
+
+func (suite *SplitSessionTestSuite) TearDownSuite() {
+	if suite.db != nil {
+		sqlDB, _ := suite.db.DB()
+		sqlDB.Close()
+	}
+}
+
+func (suite *SplitSessionTestSuite) SetupTest() {
+	database.InitTestDB(suite.db)
+	suite.db.Exec("DELETE FROM split_shares")
+	suite.db.Exec("DELETE FROM split_sessions")
+	suite.db.Exec("DELETE FROM bill_events")
+	suite.db.Exec("DELETE FROM alternative_payments")
+	suite.db.Exec("DELETE FROM payments")
+	suite.db.Exec("DELETE FROM table_sessions")
+	suite.db.Exec("DELETE FROM bills")
+
+	suite.bill = &database.Bill{
+		BusinessID:  1,
+		TableID:     1,
+		BillNumber:  "SPLIT-" + time.Now().Format("150405.000000"),
+		Subtotal:    money.MustParse("60.00"),
+		TotalAmount: money.MustParse("60.00"),
+		Status:      database.BillStatusOpen,
+	}
+	require.NoError(suite.T(), database.CreateBill(suite.bill, []database.BillItem{}))
+}
+
+func TestSplitSessionTestSuite(t *testing.T) {
+	suite.Run(t, new(SplitSessionTestSuite))
+}
+
+// split stores a three-way equal split in which only alice's wallet is known up front
+func (suite *SplitSessionTestSuite) split() error {
+	return database.CreateSplitSession(&database.SplitSession{
+		BillID:    suite.bill.ID,
+		Method:    "equal",
+		CreatedBy: database.BillActorGuest,
+		Shares: []database.SplitShare{
+			{PersonID: "alice", PersonName: "Alice", PayerAddr: "0xAlice", Amount: money.MustParse("20.00")},
+			{PersonID: "bob", PersonName: "Bob", Amount: money.MustParse("20.00")},
+			{PersonID: "carol", PersonName: "Carol", Amount: money.MustParse("20.00")},
+		},
+	})
+}
+
+func (suite *SplitSessionTestSuite) pay(txHash, payer, amount string) {
+	logIndex := uint(0)
+	_, _, err := database.RecordPayment(&database.Payment{
+		BillID:    suite.bill.ID,
+		PayerAddr: payer,
+		Amount:    money.MustParse(amount),
+		TxHash:    txHash,
+		LogIndex:  &logIndex,
+		Status:    database.PaymentStatusConfirmed,
+	}, database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+}
+
+func (suite *SplitSessionTestSuite) session() *database.SplitSession {
+	session, err := database.GetSplitSession(suite.bill.ID)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), session)
+	return session
+}
+
+func (suite *SplitSessionTestSuite) TestUnpaidSplitCanBeReplaced() {
+	require.NoError(suite.T(), suite.split())
+	first := suite.session()
+	assert.Equal(suite.T(), database.SplitSessionActive, first.Status)
+	assert.Equal(suite.T(), "0xalice", first.Shares[0].PayerAddr)
+
+	require.NoError(suite.T(), suite.split())
+	second := suite.session()
+	assert.NotEqual(suite.T(), first.ID, second.ID)
+
+	var cancelled database.SplitSession
+	require.NoError(suite.T(), suite.db.First(&cancelled, first.ID).Error)
+	assert.Equal(suite.T(), database.SplitSessionCancelled, cancelled.Status)
+}
+
+// A payment from a claimed wallet settles that share and locks the split
+func (suite *SplitSessionTestSuite) TestPaymentLocksSession() {
+	require.NoError(suite.T(), suite.split())
+	suite.pay("0xs1", "0xALICE", "20.00")
+
+	session := suite.session()
+	assert.Equal(suite.T(), database.SplitSessionLocked, session.Status)
+	assert.NotNil(suite.T(), session.LockedAt)
+	assert.Equal(suite.T(), database.SplitSharePaid, session.Shares[0].Status)
+	assert.Equal(suite.T(), money.MustParse("20.00"), session.Shares[0].PaidAmount)
+	assert.Equal(suite.T(), database.SplitSharePending, session.Shares[1].Status)
+
+	assert.ErrorIs(suite.T(), suite.split(), database.ErrSplitSessionLocked)
+}
+
+// A partial payment's tip is recorded against the share so the rest of the share is
+// not tipped again
+func (suite *SplitSessionTestSuite) TestPartialPaymentRecordsTip() {
+	require.NoError(suite.T(), suite.split())
+	logIndex := uint(0)
+	_, _, err := database.RecordPayment(&database.Payment{
+		BillID:    suite.bill.ID,
+		PayerAddr: "0xalice",
+		Amount:    money.MustParse("5.00"),
+		TipAmount: money.MustParse("3.00"),
+		TxHash:    "0xs-tip",
+		LogIndex:  &logIndex,
+		Status:    database.PaymentStatusConfirmed,
+	}, database.BillActorIndexer)
+	require.NoError(suite.T(), err)
+
+	share := suite.session().Shares[0]
+	assert.Equal(suite.T(), database.SplitSharePartiallyPaid, share.Status)
+	assert.Equal(suite.T(), money.MustParse("5.00"), share.PaidAmount)
+	assert.Equal(suite.T(), money.MustParse("3.00"), share.PaidTip)
+}
+
+// Cash recorded for a participant counts towards the share with that person ID
+func (suite *SplitSessionTestSuite) TestAlternativePaymentMatchesPerson() {
+	require.NoError(suite.T(), suite.split())
+	require.NoError(suite.T(), suite.db.Create(&database.AlternativePayment{
+		BillID:          suite.bill.ID,
+		ParticipantAddr: "bob",
+		Amount:          money.MustParse("10.00"),
+		PaymentMethod:   database.PaymentMethodCash,
+		Status:          database.AltPaymentStatusConfirmed,
+	}).Error)
+	_, err := database.RecalculateBillPayments(suite.bill.ID, "0xowner")
+	require.NoError(suite.T(), err)
+
+	session := suite.session()
+	assert.Equal(suite.T(), database.SplitSharePartiallyPaid, session.Shares[1].Status)
+}
+
+func (suite *SplitSessionTestSuite) TestSessionCompletesWhenAllSharesPaid() {
+	require.NoError(suite.T(), suite.split())
+	_, err := database.ClaimSplitShare(suite.bill.ID, "bob", "0xbob")
+	require.NoError(suite.T(), err)
+	_, err = database.ClaimSplitShare(suite.bill.ID, "carol", "0xcarol")
+	require.NoError(suite.T(), err)
+
+	suite.pay("0xc1", "0xalice", "20.00")
+	suite.pay("0xc2", "0xbob", "20.00")
+	suite.pay("0xc3", "0xcarol", "20.00")
+
+	session := suite.session()
+	assert.Equal(suite.T(), database.SplitSessionCompleted, session.Status)
+	for _, share := range session.Shares {
+		assert.Equal(suite.T(), database.SplitSharePaid, share.Status, share.PersonID)
+	}
+}
+
+func (suite *SplitSessionTestSuite) TestClaimConflicts() {
+	require.NoError(suite.T(), suite.split())
+
+	_, err := database.ClaimSplitShare(suite.bill.ID, "bob", "0xalice")
+	assert.ErrorIs(suite.T(), err, database.ErrSplitShareClaimed)
+
+	_, err = database.ClaimSplitShare(suite.bill.ID, "alice", "0xmallory")
+	assert.ErrorIs(suite.T(), err, database.ErrSplitShareClaimed)
+
+	_, err = database.ClaimSplitShare(suite.bill.ID, "dave", "0xdave")
+	assert.ErrorIs(suite.T(), err, database.ErrSplitShareNotFound)
+
+	session, err := database.ClaimSplitShare(suite.bill.ID, "bob", "0xBob")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "0xbob", session.Shares[1].PayerAddr)
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/server"
+)
+
+func setupTableCodesDB(t *testing.T) *gorm.DB {
+	db := dbtest.Open(t)
+	database.InitTestDB(db)
+	return db
+}
+
+func guestURL(prefix string) func(string) string {
+	return func(code string) string { return "https://example.com/" + prefix + "/" + code }
+}
+
+// A rotated table code replaces the old one, which no longer resolves
+func TestRotateTableCode(t *testing.T) {
+	setupTableCodesDB(t)
+
+	code, err := database.GenerateUniqueTableCode(1, "Table 1")
+	require.NoError(t, err)
+	table := &database.Table{BusinessID: 1, Name: "Table 1", TableCode: code, IsActive: true}
+	require.NoError(t, database.CreateTable(table))
+
+	_, err = database.RotateTableCode(2, table.ID, guestURL("t"), true)
+	assert.Error(t, err, "another business cannot rotate the table")
+
+	rotated, err := database.RotateTableCode(1, table.ID, guestURL("t"), true)
+	require.NoError(t, err)
+	assert.NotEqual(t, code, rotated.TableCode)
+	assert.Len(t, rotated.TableCode, 10)
+	assert.Equal(t, "https://example.com/t/"+rotated.TableCode, rotated.QRCode)
+
+	_, err = database.GetTableByCode(code)
+	assert.Error(t, err)
+	found, err := database.GetTableByCode(rotated.TableCode)
+	require.NoError(t, err)
+	assert.Equal(t, rotated.QRCode, found.QRCode)
+}
+
+// Counters get codes when created, counters created before then get one from the data
+// migration, and codes can be rotated
+func TestCounterCodes(t *testing.T) {
+	db := setupTableCodesDB(t)
+
+	require.NoError(t, database.CreateCountersForBusiness(1, 2, "Counter "))
+	counters, err := database.GetBusinessCounters(1)
+	require.NoError(t, err)
+	require.Len(t, counters, 2)
+	assert.NotEmpty(t, counters[0].CounterCode)
+	assert.NotEqual(t, counters[0].CounterCode, counters[1].CounterCode)
+
+	require.NoError(t, db.Exec(`INSERT INTO counters (business_id, counter_number, name, is_active) VALUES (1, 3, 'Counter 3', true)`).Error)
+	require.NoError(t, db.Exec(`DELETE FROM data_migrations WHERE name = 'counter_codes'`).Error)
+	require.NoError(t, database.ApplyDataMigrations(db))
+	counters, err = database.GetBusinessCounters(1)
+	require.NoError(t, err)
+	require.Len(t, counters, 3)
+	legacy := counters[2]
+	assert.Len(t, legacy.CounterCode, 10)
+
+	found, err := database.GetCounterByCode(legacy.CounterCode)
+	require.NoError(t, err)
+	assert.Equal(t, legacy.ID, found.ID)
+	_, err = database.GetCounterByCode("")
+	assert.Error(t, err)
+
+	rotated, err := database.RotateCounterCode(1, legacy.ID, guestURL("c"))
+	require.NoError(t, err)
+	assert.NotEqual(t, legacy.CounterCode, rotated.CounterCode)
+	assert.Equal(t, "https://example.com/c/"+rotated.CounterCode, rotated.QRCode)
+	_, err = database.GetCounterByCode(legacy.CounterCode)
+	assert.Error(t, err)
+
+	// Counters added when the counter settings grow get codes too
+	require.NoError(t, database.UpdateBusinessCounters(1, true, 4, "Counter "))
+	added, err := database.GetBusinessCounters(1)
+	require.NoError(t, err)
+	require.Len(t, added, 4)
+	assert.NotEmpty(t, added[3].CounterCode)
+}
+
+func createSessionTable(t *testing.T, name string) *database.Table {
+	code, err := database.GenerateUniqueTableCode(1, name)
+	require.NoError(t, err)
+	table := &database.Table{BusinessID: 1, Name: name, TableCode: code, IsActive: true}
+	require.NoError(t, database.CreateTable(table))
+	return table
+}
+
+// Sessions are tied to the bill open at the table and end when it closes
+func TestTableSessionsFollowTheBill(t *testing.T) {
+	setupTableCodesDB(t)
+	table := createSessionTable(t, "Table 1")
+	other := createSessionTable(t, "Table 2")
+
+	// Scanned before the table had a bill
+	early, earlyToken, err := database.StartTableSession(table, "phone-1", "Ana's phone", "test-agent", "10.0.0.1")
+	require.NoError(t, err)
+	assert.Nil(t, early.BillID)
+	assert.NotEmpty(t, earlyToken)
+	assert.NotContains(t, early.TokenHash, earlyToken)
+
+	_, err = database.ValidateTableSession(table.ID, "")
+	assert.ErrorIs(t, err, database.ErrTableSessionInvalid)
+	_, err = database.ValidateTableSession(other.ID, earlyToken)
+	assert.ErrorIs(t, err, database.ErrTableSessionInvalid, "a token only works at its own table")
+
+	bill := &database.Bill{BusinessID: 1, TableID: table.ID, BillNumber: "SESSION-1", Status: database.BillStatusOpen}
+	require.NoError(t, database.CreateBill(bill, []database.BillItem{}))
+
+	session, err := database.ValidateTableSession(table.ID, earlyToken)
+	require.NoError(t, err)
+	require.NotNil(t, session.BillID)
+	assert.Equal(t, bill.ID, *session.BillID, "the session joins the bill opened since")
+
+	late, lateToken, err := database.StartTableSession(table, "phone-2", "", "test-agent", "10.0.0.2")
+	require.NoError(t, err)
+	require.NotNil(t, late.BillID)
+	assert.Equal(t, bill.ID, *late.BillID)
+
+	sessions, err := database.GetTableSessions(1, table.ID, true)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 2)
+
+	_, err = database.TransitionBill(bill.ID, database.BillStatusVoided, "staff", "walked out")
+	require.NoError(t, err)
+
+	_, err = database.ValidateTableSession(table.ID, earlyToken)
+	assert.ErrorIs(t, err, database.ErrTableSessionInvalid)
+	_, err = database.ValidateTableSession(table.ID, lateToken)
+	assert.ErrorIs(t, err, database.ErrTableSessionInvalid)
+
+	sessions, err = database.GetTableSessions(1, table.ID, true)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	sessions, err = database.GetTableSessions(1, table.ID, false)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, database.TableSessionEndedBillClosed, sessions[0].EndReason)
+}
+
+// Scanning again replaces a device's session; staff can revoke one and rotating the
+// code ends them all
+func TestTableSessionsEnd(t *testing.T) {
+	setupTableCodesDB(t)
+	table := createSessionTable(t, "Table 1")
+
+	_, firstToken, err := database.StartTableSession(table, "phone-1", "", "", "")
+	require.NoError(t, err)
+	_, secondToken, err := database.StartTableSession(table, "phone-1", "", "", "")
+	require.NoError(t, err)
+	_, err = database.ValidateTableSession(table.ID, firstToken)
+	assert.ErrorIs(t, err, database.ErrTableSessionInvalid, "the rescan replaced the first session")
+	_, err = database.ValidateTableSession(table.ID, secondToken)
+	require.NoError(t, err)
+
+	other, otherToken, err := database.StartTableSession(table, "phone-2", "", "", "")
+	require.NoError(t, err)
+	assert.Error(t, database.RevokeTableSession(2, table.ID, other.ID), "another business cannot revoke it")
+	require.NoError(t, database.RevokeTableSession(1, table.ID, other.ID))
+	_, err = database.ValidateTableSession(table.ID, otherToken)
+	assert.ErrorIs(t, err, database.ErrTableSessionInvalid)
+
+	// Rotating while keeping sessions leaves guests at the table
+	_, err = database.RotateTableCode(1, table.ID, guestURL("t"), false)
+	require.NoError(t, err)
+	_, err = database.ValidateTableSession(table.ID, secondToken)
+	require.NoError(t, err)
+
+	_, err = database.RotateTableCode(1, table.ID, guestURL("t"), true)
+	require.NoError(t, err)
+	_, err = database.ValidateTableSession(table.ID, secondToken)
+	assert.ErrorIs(t, err, database.ErrTableSessionInvalid)
+}
+
+// Guest routes that pay a bill only accept the table session of the bill's own table
+func TestBillRoutesRequireBillTableSession(t *testing.T) {
+	setupTableCodesDB(t)
+	table := createSessionTable(t, "Table 1")
+	other := createSessionTable(t, "Table 2")
+
+	bill := &database.Bill{BusinessID: 1, TableID: table.ID, BillNumber: "SESSION-PAY", Status: database.BillStatusOpen}
+	require.NoError(t, database.CreateBill(bill, []database.BillItem{}))
+	_, token, err := database.StartTableSession(table, "phone-1", "", "", "")
+	require.NoError(t, err)
+	otherBill := &database.Bill{BusinessID: 1, TableID: other.ID, BillNumber: "SESSION-OTHER", Status: database.BillStatusOpen}
+	require.NoError(t, database.CreateBill(otherBill, []database.BillItem{}))
+	_, otherToken, err := database.StartTableSession(other, "phone-2", "", "", "")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/bills/:bill_id/split/execute", server.RequireBillTableSession(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	execute := func(billID uint, token string) int {
+		req := httptest.NewRequest(http.MethodPost, "/bills/"+strconv.FormatUint(uint64(billID), 10)+"/split/execute", nil)
+		if token != "" {
+			req.Header.Set(server.TableSessionHeader, token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, execute(bill.ID, token))
+	assert.Equal(t, http.StatusUnauthorized, execute(bill.ID, ""))
+	assert.Equal(t, http.StatusUnauthorized, execute(bill.ID, otherToken), "a session at another table cannot pay this bill")
+	assert.Equal(t, http.StatusNotFound, execute(otherBill.ID+100, token))
+}
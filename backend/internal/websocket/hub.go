@@ -4,26 +4,57 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/gorilla/websocket"
 )
 
-// Hub maintains the set of active clients and broadcasts messages to the clients
+const (
+	// Maximum size of an inbound client message (subscribe/unsubscribe requests)
+	maxMessageSize = 1024
+
+	// Maximum number of rooms a single client may join
+	maxRoomsPerClient = 32
+
+	// Room name prefixes
+	businessRoomPrefix = "business_"
+	tableRoomPrefix    = "table_"
+	billRoomPrefix     = "bill_"
+	kitchenRoomPrefix  = "kitchen_"
+)
+
+// TokenVerifier validates a JWT and returns its claims
+type TokenVerifier func(token string) (map[string]interface{}, error)
+
+// BusinessAuthorizer reports whether the given token claims grant access to a business
+type BusinessAuthorizer func(claims map[string]interface{}, businessID uint) bool
+
+// BillAuthorizer reports whether a client may follow a bill, either as a guest holding
+// the table session of the bill or through token claims for the bill's business
+type BillAuthorizer func(claims map[string]interface{}, sessionToken string, billID uint) bool
+
+// Hub maintains the set of active clients and routes messages to the rooms they joined
 type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
-	// Inbound messages from the clients
-	broadcast chan []byte
+	// Room memberships, keyed by room name
+	rooms map[string]map[*Client]bool
 
-	// Register requests from the clients
-	register chan *Client
+	// Outbound messages, optionally scoped to a room
+	broadcast chan roomMessage
 
 	// Unregister requests from clients
 	unregister chan *Client
 
+	// Authentication hooks for business, kitchen and bill rooms
+	verifyToken       TokenVerifier
+	authorizeBusiness BusinessAuthorizer
+	authorizeKitchen  BusinessAuthorizer
+	authorizeBill     BillAuthorizer
+
 	// Mutex for thread safety
 	mutex sync.RWMutex
 }
@@ -34,6 +65,32 @@ type Client struct {
 	conn   *websocket.Conn
 	send   chan []byte
 	userID string
+
+	// Claims of the authenticated user, nil for guests
+	claims map[string]interface{}
+
+	// Rooms this client is subscribed to (guarded by hub.mutex)
+	rooms map[string]bool
+}
+
+// roomMessage is a message queued for delivery to the clients of a room
+type roomMessage struct {
+	room string
+	data []byte
+}
+
+// ClientMessage is a control message sent by a client
+type ClientMessage struct {
+	Action string `json:"action"` // subscribe | unsubscribe
+	Room   string `json:"room"`
+	Token  string `json:"token,omitempty"` // Table session token, required by guests for bill rooms
+}
+
+// SubscriptionResponse acknowledges or rejects a client control message
+type SubscriptionResponse struct {
+	Type  string `json:"type"` // subscribed | unsubscribed | error
+	Room  string `json:"room,omitempty"`
+	Error string `json:"error,omitempty"`
 }
 
 var upgrader = websocket.Upgrader{
@@ -45,65 +102,214 @@ var upgrader = websocket.Upgrader{
 // NewHub creates a new WebSocket hub
 func NewHub() *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
+		broadcast:  make(chan roomMessage),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
+		rooms:      make(map[string]map[*Client]bool),
 	}
 }
 
+// SetAuthorization configures how tokens are verified and business rooms are authorized.
+// Without it, business rooms cannot be joined.
+func (h *Hub) SetAuthorization(verifyToken TokenVerifier, authorizeBusiness BusinessAuthorizer) {
+	h.verifyToken = verifyToken
+	h.authorizeBusiness = authorizeBusiness
+}
+
+// SetKitchenAuthorization configures who may follow a business's kitchen display rooms.
+// Without it, kitchen rooms cannot be joined.
+func (h *Hub) SetKitchenAuthorization(authorizeKitchen BusinessAuthorizer) {
+	h.authorizeKitchen = authorizeKitchen
+}
+
+// SetBillAuthorization configures who may follow a bill's room.
+// Without it, bill rooms cannot be joined.
+func (h *Hub) SetBillAuthorization(authorizeBill BillAuthorizer) {
+	h.authorizeBill = authorizeBill
+}
+
 // Run starts the hub
 func (h *Hub) Run() {
 	for {
 		select {
-		case client := <-h.register:
-			h.mutex.Lock()
-			h.clients[client] = true
-			h.mutex.Unlock()
-
 		case client := <-h.unregister:
 			h.mutex.Lock()
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
+			h.removeClient(client)
 			h.mutex.Unlock()
 
 		case message := <-h.broadcast:
-			h.mutex.RLock()
-			for client := range h.clients {
+			h.mutex.Lock()
+			for client := range h.rooms[message.room] {
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
 				default:
-					close(client.send)
-					delete(h.clients, client)
+					h.removeClient(client)
 				}
 			}
-			h.mutex.RUnlock()
+			h.mutex.Unlock()
 		}
 	}
 }
 
-// BroadcastJSON broadcasts a JSON message to all connected clients
-func (h *Hub) BroadcastJSON(message interface{}) {
+// removeClient drops a client from the hub and all of its rooms. Caller must hold the write lock.
+func (h *Hub) removeClient(client *Client) {
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	for room := range client.rooms {
+		h.leaveRoom(client, room)
+	}
+	delete(h.clients, client)
+	close(client.send)
+}
+
+// leaveRoom removes a client from a room. Caller must hold the write lock.
+func (h *Hub) leaveRoom(client *Client, room string) {
+	delete(client.rooms, room)
+	if members, ok := h.rooms[room]; ok {
+		delete(members, client)
+		if len(members) == 0 {
+			delete(h.rooms, room)
+		}
+	}
+}
+
+// BroadcastToRoom sends a JSON message to the clients subscribed to a room
+func (h *Hub) BroadcastToRoom(room string, message interface{}) {
 	data, err := json.Marshal(message)
 	if err != nil {
 		return
 	}
-	h.broadcast <- data
+	h.broadcast <- roomMessage{room: room, data: data}
 }
 
-// BroadcastToRoom broadcasts a message to a specific room (simplified implementation)
-func (h *Hub) BroadcastToRoom(room string, message interface{}) {
-	// For now, broadcast to all clients (room-based routing can be added later)
-	h.BroadcastJSON(message)
+// RoomSize returns the number of clients subscribed to a room
+func (h *Hub) RoomSize(room string) int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.rooms[room])
+}
+
+// Subscribe adds a client to a room after checking it may access it. The session token
+// is the guest's table session, checked for bill rooms.
+func (h *Hub) Subscribe(client *Client, room, sessionToken string) error {
+	if err := h.authorizeRoom(client, room, sessionToken); err != nil {
+		return err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.clients[client]; !ok {
+		return fmt.Errorf("client is not connected")
+	}
+	if client.rooms[room] {
+		return nil
+	}
+	if len(client.rooms) >= maxRoomsPerClient {
+		return fmt.Errorf("too many subscriptions")
+	}
+
+	if h.rooms[room] == nil {
+		h.rooms[room] = make(map[*Client]bool)
+	}
+	h.rooms[room][client] = true
+	client.rooms[room] = true
+	return nil
+}
+
+// Unsubscribe removes a client from a room
+func (h *Hub) Unsubscribe(client *Client, room string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.leaveRoom(client, room)
+}
+
+// authorizeRoom validates the room name and checks access rights.
+// Table rooms are open to guests; bill rooms need the bill's table session or an owner
+// or staff token, business rooms an owner or staff token and kitchen rooms an owner or
+// kitchen staff token.
+func (h *Hub) authorizeRoom(client *Client, room, sessionToken string) error {
+	switch {
+	case strings.HasPrefix(room, tableRoomPrefix):
+		if len(room) == len(tableRoomPrefix) {
+			return fmt.Errorf("invalid room")
+		}
+		return nil
+
+	case strings.HasPrefix(room, billRoomPrefix):
+		billID, err := strconv.ParseUint(strings.TrimPrefix(room, billRoomPrefix), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid room")
+		}
+		if client.claims == nil && sessionToken == "" {
+			return fmt.Errorf("table session required")
+		}
+		if h.authorizeBill == nil || !h.authorizeBill(client.claims, sessionToken, uint(billID)) {
+			return fmt.Errorf("access denied")
+		}
+		return nil
+
+	case strings.HasPrefix(room, businessRoomPrefix):
+		businessID, err := strconv.ParseUint(strings.TrimPrefix(room, businessRoomPrefix), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid room")
+		}
+		if client.claims == nil {
+			return fmt.Errorf("authentication required")
+		}
+		if h.authorizeBusiness == nil || !h.authorizeBusiness(client.claims, uint(businessID)) {
+			return fmt.Errorf("access denied")
+		}
+		return nil
+
+	case strings.HasPrefix(room, kitchenRoomPrefix):
+		// kitchen_<business> carries every station, kitchen_<business>_<station> one station
+		businessPart, stationPart, hasStation := strings.Cut(strings.TrimPrefix(room, kitchenRoomPrefix), "_")
+		businessID, err := strconv.ParseUint(businessPart, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid room")
+		}
+		if hasStation {
+			if _, err := strconv.ParseUint(stationPart, 10, 32); err != nil {
+				return fmt.Errorf("invalid room")
+			}
+		}
+		if client.claims == nil {
+			return fmt.Errorf("authentication required")
+		}
+		if h.authorizeKitchen == nil || !h.authorizeKitchen(client.claims, uint(businessID)) {
+			return fmt.Errorf("access denied")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown room")
+}
+
+// sendToClient queues a message for a single client if it is still connected
+func (h *Hub) sendToClient(client *Client, message interface{}) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if _, ok := h.clients[client]; !ok {
+		return
+	}
+	select {
+	case client.send <- data:
+	default:
+	}
 }
 
 // AuthenticateWebSocket validates JWT token from query parameter or header
 func AuthenticateWebSocket(r *http.Request, verifyTokenFunc func(string) (map[string]interface{}, error)) (map[string]interface{}, error) {
 	// Try to get token from query parameter first (for WebSocket connections)
 	token := r.URL.Query().Get("token")
-	
+
 	// If not in query, try Authorization header
 	if token == "" {
 		authHeader := r.Header.Get("Authorization")
@@ -114,17 +320,30 @@ func AuthenticateWebSocket(r *http.Request, verifyTokenFunc func(string) (map[st
 			}
 		}
 	}
-	
+
 	if token == "" {
 		return nil, fmt.Errorf("no authentication token provided")
 	}
-	
+
 	// Use the provided token verification function
 	return verifyTokenFunc(token)
 }
 
-// ServeWS handles websocket requests from clients (authentication disabled for now)
+// ServeWS handles websocket requests from clients. A token is optional: guests may
+// join table rooms and the rooms of their bill, authenticated owners and staff may also
+// join business rooms.
 func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
+	var claims map[string]interface{}
+	userID := "guest"
+	if h.verifyToken != nil {
+		if verified, err := AuthenticateWebSocket(r, h.verifyToken); err == nil {
+			claims = verified
+			if address, ok := claims["address"].(string); ok && address != "" {
+				userID = address
+			}
+		}
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
@@ -134,28 +353,61 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 		hub:    h,
 		conn:   conn,
 		send:   make(chan []byte, 256),
-		userID: "guest", // Default for now since authentication is disabled
+		userID: userID,
+		claims: claims,
+		rooms:  make(map[string]bool),
 	}
 
-	client.hub.register <- client
+	// Register synchronously so subscriptions read right after the upgrade find the client
+	h.mutex.Lock()
+	h.clients[client] = true
+	h.mutex.Unlock()
 
 	// Start goroutines for reading and writing
 	go client.writePump()
 	go client.readPump()
 }
 
-// readPump pumps messages from the websocket connection to the hub
+// readPump reads subscription requests from the websocket connection
 func (c *Client) readPump() {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
 	}()
 
+	c.conn.SetReadLimit(maxMessageSize)
+
 	for {
-		_, _, err := c.conn.ReadMessage()
+		_, data, err := c.conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		c.handleMessage(data)
+	}
+}
+
+// handleMessage processes a single control message from the client
+func (c *Client) handleMessage(data []byte) {
+	var msg ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.hub.sendToClient(c, SubscriptionResponse{Type: "error", Error: "invalid message"})
+		return
+	}
+
+	switch msg.Action {
+	case "subscribe":
+		if err := c.hub.Subscribe(c, msg.Room, msg.Token); err != nil {
+			c.hub.sendToClient(c, SubscriptionResponse{Type: "error", Room: msg.Room, Error: err.Error()})
+			return
+		}
+		c.hub.sendToClient(c, SubscriptionResponse{Type: "subscribed", Room: msg.Room})
+
+	case "unsubscribe":
+		c.hub.Unsubscribe(c, msg.Room)
+		c.hub.sendToClient(c, SubscriptionResponse{Type: "unsubscribed", Room: msg.Room})
+
+	default:
+		c.hub.sendToClient(c, SubscriptionResponse{Type: "error", Error: "unknown action"})
 	}
 }
 
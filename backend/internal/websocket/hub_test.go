@@ -0,0 +1,233 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"payverge/internal/database"
+
+	gorillaws "github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHub(t *testing.T) (*Hub, *httptest.Server) {
+	hub := NewHub()
+	hub.SetAuthorization(
+		func(token string) (map[string]interface{}, error) {
+			switch token {
+			case "owner-token":
+				return map[string]interface{}{"address": "0xowner"}, nil
+			case "kitchen-token":
+				return map[string]interface{}{"staff_id": float64(7), "role": "kitchen"}, nil
+			case "server-token":
+				return map[string]interface{}{"staff_id": float64(8), "role": "server"}, nil
+			}
+			return nil, errors.New("invalid token")
+		},
+		func(claims map[string]interface{}, businessID uint) bool {
+			return claims["address"] == "0xowner" && businessID == 1
+		},
+	)
+	hub.SetKitchenAuthorization(func(claims map[string]interface{}, businessID uint) bool {
+		return businessID == 1 && (claims["address"] == "0xowner" || claims["role"] == "kitchen")
+	})
+	hub.SetBillAuthorization(func(claims map[string]interface{}, sessionToken string, billID uint) bool {
+		if claims != nil && claims["address"] == "0xowner" {
+			return true
+		}
+		return sessionToken == fmt.Sprintf("session-%d", billID)
+	})
+	go hub.Run()
+
+	srv := httptest.NewServer(http.HandlerFunc(hub.ServeWS))
+	t.Cleanup(srv.Close)
+	return hub, srv
+}
+
+func dial(t *testing.T, srv *httptest.Server, token string) *gorillaws.Conn {
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	if token != "" {
+		url += "?token=" + token
+	}
+	conn, _, err := gorillaws.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func request(t *testing.T, conn *gorillaws.Conn, action, room string) SubscriptionResponse {
+	return send(t, conn, ClientMessage{Action: action, Room: room})
+}
+
+// subscribeBill joins a bill room with a guest's table session token
+func subscribeBill(t *testing.T, conn *gorillaws.Conn, room, sessionToken string) SubscriptionResponse {
+	return send(t, conn, ClientMessage{Action: "subscribe", Room: room, Token: sessionToken})
+}
+
+func send(t *testing.T, conn *gorillaws.Conn, msg ClientMessage) SubscriptionResponse {
+	require.NoError(t, conn.WriteJSON(msg))
+	var resp SubscriptionResponse
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.NoError(t, conn.ReadJSON(&resp))
+	return resp
+}
+
+func TestGuestCanJoinTableAndBillRooms(t *testing.T) {
+	_, srv := newTestHub(t)
+	conn := dial(t, srv, "")
+
+	assert.Equal(t, "subscribed", request(t, conn, "subscribe", "table_ABC123").Type)
+	assert.Equal(t, "subscribed", subscribeBill(t, conn, "bill_42", "session-42").Type)
+	assert.Equal(t, "error", subscribeBill(t, conn, "bill_abc", "session-42").Type)
+	assert.Equal(t, "error", request(t, conn, "subscribe", "lobby").Type)
+}
+
+// Bill rooms carry payer addresses and payments, so guests must hold the bill's table
+// session rather than guess bill IDs
+func TestBillRoomRequiresTableSession(t *testing.T) {
+	_, srv := newTestHub(t)
+
+	guest := dial(t, srv, "")
+	assert.Equal(t, "table session required", request(t, guest, "subscribe", "bill_42").Error)
+	assert.Equal(t, "access denied", subscribeBill(t, guest, "bill_43", "session-42").Error)
+
+	owner := dial(t, srv, "owner-token")
+	assert.Equal(t, "subscribed", request(t, owner, "subscribe", "bill_43").Type)
+}
+
+func TestBusinessRoomRequiresAuthorizedToken(t *testing.T) {
+	_, srv := newTestHub(t)
+
+	guest := dial(t, srv, "")
+	resp := request(t, guest, "subscribe", "business_1")
+	assert.Equal(t, "error", resp.Type)
+	assert.Equal(t, "authentication required", resp.Error)
+
+	invalid := dial(t, srv, "forged")
+	assert.Equal(t, "error", request(t, invalid, "subscribe", "business_1").Type)
+
+	owner := dial(t, srv, "owner-token")
+	assert.Equal(t, "subscribed", request(t, owner, "subscribe", "business_1").Type)
+	resp = request(t, owner, "subscribe", "business_2")
+	assert.Equal(t, "error", resp.Type)
+	assert.Equal(t, "access denied", resp.Error)
+}
+
+func TestKitchenRoomsRequireKitchenAccess(t *testing.T) {
+	hub, srv := newTestHub(t)
+
+	guest := dial(t, srv, "")
+	assert.Equal(t, "authentication required", request(t, guest, "subscribe", "kitchen_1").Error)
+
+	server := dial(t, srv, "server-token")
+	assert.Equal(t, "access denied", request(t, server, "subscribe", "kitchen_1").Error)
+
+	owner := dial(t, srv, "owner-token")
+	assert.Equal(t, "subscribed", request(t, owner, "subscribe", "kitchen_1").Type)
+
+	kitchen := dial(t, srv, "kitchen-token")
+	assert.Equal(t, "subscribed", request(t, kitchen, "subscribe", KitchenStationRoom(1, 3)).Type)
+	assert.Equal(t, "access denied", request(t, kitchen, "subscribe", "kitchen_2").Error)
+	assert.Equal(t, "invalid room", request(t, kitchen, "subscribe", "kitchen_1_grill").Error)
+
+	hub.BroadcastToRoom(KitchenStationRoom(1, 3), map[string]interface{}{"type": "kitchen_ticket"})
+	var msg map[string]interface{}
+	require.NoError(t, kitchen.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.NoError(t, kitchen.ReadJSON(&msg))
+	assert.Equal(t, "kitchen_ticket", msg["type"])
+}
+
+func TestBroadcastToRoomOnlyReachesMembers(t *testing.T) {
+	hub, srv := newTestHub(t)
+
+	member := dial(t, srv, "")
+	outsider := dial(t, srv, "")
+	require.Equal(t, "subscribed", request(t, member, "subscribe", "table_T4").Type)
+	require.Equal(t, "subscribed", request(t, outsider, "subscribe", "table_T5").Type)
+
+	hub.BroadcastToRoom("table_T4", map[string]interface{}{"type": "bill_update", "bill_id": 7})
+
+	var msg map[string]interface{}
+	require.NoError(t, member.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.NoError(t, member.ReadJSON(&msg))
+	assert.Equal(t, "bill_update", msg["type"])
+
+	require.NoError(t, outsider.SetReadDeadline(time.Now().Add(200*time.Millisecond)))
+	_, _, err := outsider.ReadMessage()
+	assert.Error(t, err, "outsider should not receive messages for another room")
+}
+
+func TestUnsubscribeStopsDelivery(t *testing.T) {
+	hub, srv := newTestHub(t)
+
+	conn := dial(t, srv, "")
+	require.Equal(t, "subscribed", subscribeBill(t, conn, "bill_9", "session-9").Type)
+	assert.Equal(t, 1, hub.RoomSize("bill_9"))
+
+	require.Equal(t, "unsubscribed", request(t, conn, "unsubscribe", "bill_9").Type)
+	assert.Equal(t, 0, hub.RoomSize("bill_9"))
+
+	data, err := json.Marshal(ClientMessage{Action: "dance"})
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteMessage(gorillaws.TextMessage, data))
+	var resp SubscriptionResponse
+	require.NoError(t, conn.ReadJSON(&resp))
+	assert.Equal(t, "unknown action", resp.Error)
+}
+
+// Both tables hear which bill moved, but only the bill and business rooms get its items
+// and amounts
+func TestBillTransferReachesBothTables(t *testing.T) {
+	hub, srv := newTestHub(t)
+
+	from := dial(t, srv, "")
+	to := dial(t, srv, "")
+	require.Equal(t, "subscribed", request(t, from, "subscribe", "table_FROM").Type)
+	require.Equal(t, "subscribed", request(t, to, "subscribe", "table_TO").Type)
+	owner := dial(t, srv, "owner-token")
+	require.Equal(t, "subscribed", request(t, owner, "subscribe", "business_1").Type)
+
+	NewTableFeed(hub).NotifyBillTransfer(1, &database.BillTransfer{
+		Action: database.BillTransferMove,
+		Bills:  []database.Bill{{ID: 3, BusinessID: 1, TableID: 2, BillNumber: "B-3", Notes: "window seat"}},
+		Tables: []database.Table{{ID: 1, TableCode: "FROM"}, {ID: 2, TableCode: "TO"}},
+	})
+
+	for _, conn := range []*gorillaws.Conn{from, to} {
+		var msg map[string]interface{}
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+		require.NoError(t, conn.ReadJSON(&msg))
+		assert.Equal(t, "bill_transfer", msg["type"])
+		assert.Equal(t, database.BillTransferMove, msg["action"])
+		assert.Equal(t, []interface{}{map[string]interface{}{"id": float64(3), "bill_number": "B-3"}}, msg["bills"])
+	}
+
+	var msg BillTransferNotification
+	require.NoError(t, owner.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.NoError(t, owner.ReadJSON(&msg))
+	require.Len(t, msg.Bills, 1)
+	assert.Equal(t, "window seat", msg.Bills[0].Notes)
+}
+
+func TestTableStatusGoesToBusinessRoom(t *testing.T) {
+	hub, srv := newTestHub(t)
+
+	owner := dial(t, srv, "owner-token")
+	require.Equal(t, "subscribed", request(t, owner, "subscribe", "business_1").Type)
+
+	NewTableFeed(hub).NotifyTableStatus(1, []database.TableStatus{{TableID: 4, Status: database.TableStateSeated}})
+
+	var msg TableStatusNotification
+	require.NoError(t, owner.SetReadDeadline(time.Now().Add(2*time.Second)))
+	require.NoError(t, owner.ReadJSON(&msg))
+	assert.Equal(t, "table_status", msg.Type)
+	require.Len(t, msg.Tables, 1)
+	assert.Equal(t, database.TableStateSeated, msg.Tables[0].Status)
+}
@@ -0,0 +1,74 @@
+package websocket
+
+import (
+	"strconv"
+	"time"
+
+	"payverge/internal/database"
+)
+
+// KitchenFeed pushes kitchen display updates to the kitchen rooms of a business
+type KitchenFeed struct {
+	hub *Hub
+}
+
+// KitchenTicketNotification carries a new or updated kitchen ticket and its order's status
+type KitchenTicketNotification struct {
+	Type        string                  `json:"type"`
+	BusinessID  uint                    `json:"business_id"`
+	Ticket      *database.KitchenTicket `json:"ticket"`
+	OrderStatus database.OrderStatus    `json:"order_status"`
+	Timestamp   time.Time               `json:"timestamp"`
+}
+
+// OrderUpdateNotification tells staff that an order changed status
+type OrderUpdateNotification struct {
+	Type       string               `json:"type"`
+	BusinessID uint                 `json:"business_id"`
+	OrderID    uint                 `json:"order_id"`
+	BillID     uint                 `json:"bill_id"`
+	Status     database.OrderStatus `json:"status"`
+	Timestamp  time.Time            `json:"timestamp"`
+}
+
+// NewKitchenFeed creates a kitchen feed broadcasting through the hub
+func NewKitchenFeed(hub *Hub) *KitchenFeed {
+	return &KitchenFeed{hub: hub}
+}
+
+// KitchenRoom is the room carrying every station of a business's kitchen
+func KitchenRoom(businessID uint) string {
+	return kitchenRoomPrefix + strconv.FormatUint(uint64(businessID), 10)
+}
+
+// KitchenStationRoom is the room carrying the tickets of a single station
+func KitchenStationRoom(businessID, stationID uint) string {
+	return KitchenRoom(businessID) + "_" + strconv.FormatUint(uint64(stationID), 10)
+}
+
+// NotifyKitchenTicket sends a ticket to the kitchen and station rooms. When the order is
+// ready, the business room is told so the food can be served.
+func (f *KitchenFeed) NotifyKitchenTicket(ticket *database.KitchenTicket, order *database.Order) {
+	now := time.Now()
+	notification := KitchenTicketNotification{
+		Type:        "kitchen_ticket",
+		BusinessID:  ticket.BusinessID,
+		Ticket:      ticket,
+		OrderStatus: order.Status,
+		Timestamp:   now,
+	}
+	f.hub.BroadcastToRoom(KitchenRoom(ticket.BusinessID), notification)
+	f.hub.BroadcastToRoom(KitchenStationRoom(ticket.BusinessID, ticket.StationID), notification)
+
+	if order.Status == database.OrderStatusOrderReady {
+		businessRoom := businessRoomPrefix + strconv.FormatUint(uint64(order.BusinessID), 10)
+		f.hub.BroadcastToRoom(businessRoom, OrderUpdateNotification{
+			Type:       "order_ready",
+			BusinessID: order.BusinessID,
+			OrderID:    order.ID,
+			BillID:     order.BillID,
+			Status:     order.Status,
+			Timestamp:  now,
+		})
+	}
+}
@@ -1,66 +1,78 @@
 package websocket
 
 import (
-	"encoding/json"
+	"errors"
+	"log"
 	"strconv"
 	"time"
 
+	"gorm.io/gorm"
 	"payverge/internal/blockchain"
 	"payverge/internal/database"
+	"payverge/internal/money"
 )
 
 // PaymentMonitor handles real-time payment monitoring and notifications
 type PaymentMonitor struct {
-	hub        *Hub
-	db         *database.DB
-	blockchain *blockchain.BlockchainService
-	stopCh     chan struct{}
+	hub           *Hub
+	db            *database.DB
+	blockchain    *blockchain.BlockchainService
+	indexerConfig blockchain.IndexerConfig
+	indexer       *blockchain.PaymentIndexer
+	stopCh        chan struct{}
 }
 
 // PaymentNotification represents a payment notification message
 type PaymentNotification struct {
-	Type            string    `json:"type"`
-	BillID          uint      `json:"bill_id"`
-	TransactionHash string    `json:"transaction_hash"`
-	Amount          int64     `json:"amount"`
-	TipAmount       int64     `json:"tip_amount"`
-	PayerAddress    string    `json:"payer_address"`
-	BusinessID      uint      `json:"business_id"`
-	Status          string    `json:"status"`
-	Timestamp       time.Time `json:"timestamp"`
+	Type            string       `json:"type"`
+	BillID          uint         `json:"bill_id"`
+	TransactionHash string       `json:"transaction_hash"`
+	Amount          money.Amount `json:"amount"`
+	TipAmount       money.Amount `json:"tip_amount"`
+	PayerAddress    string       `json:"payer_address"`
+	BusinessID      uint         `json:"business_id"`
+	Status          string       `json:"status"`
+	Timestamp       time.Time    `json:"timestamp"`
 }
 
 // BillUpdateNotification represents a bill status update
 type BillUpdateNotification struct {
-	Type       string `json:"type"`
-	BillID     uint   `json:"bill_id"`
-	BusinessID uint   `json:"business_id"`
-	TableID    uint   `json:"table_id"`
-	Status     string `json:"status"`
-	PaidAmount int64  `json:"paid_amount"`
-	TipAmount  int64  `json:"tip_amount"`
-	Total      int64  `json:"total"`
-	Remaining  int64  `json:"remaining"`
-	Timestamp  time.Time `json:"timestamp"`
+	Type       string       `json:"type"`
+	BillID     uint         `json:"bill_id"`
+	BusinessID uint         `json:"business_id"`
+	TableID    uint         `json:"table_id"`
+	Status     string       `json:"status"`
+	PaidAmount money.Amount `json:"paid_amount"`
+	TipAmount  money.Amount `json:"tip_amount"`
+	Total      money.Amount `json:"total"`
+	Remaining  money.Amount `json:"remaining"`
+	Timestamp  time.Time    `json:"timestamp"`
+}
+
+// SplitUpdateNotification carries the per-share payment status of a bill's split
+type SplitUpdateNotification struct {
+	Type      string                 `json:"type"`
+	BillID    uint                   `json:"bill_id"`
+	Session   *database.SplitSession `json:"session"`
+	Timestamp time.Time              `json:"timestamp"`
 }
 
 // NewPaymentMonitor creates a new payment monitor
-func NewPaymentMonitor(hub *Hub, db *database.DB, blockchain *blockchain.BlockchainService) *PaymentMonitor {
+func NewPaymentMonitor(hub *Hub, db *database.DB, blockchainService *blockchain.BlockchainService, indexerConfig blockchain.IndexerConfig) *PaymentMonitor {
 	return &PaymentMonitor{
-		hub:        hub,
-		db:         db,
-		blockchain: blockchain,
-		stopCh:     make(chan struct{}),
+		hub:           hub,
+		db:            db,
+		blockchain:    blockchainService,
+		indexerConfig: indexerConfig,
+		stopCh:        make(chan struct{}),
 	}
 }
 
 // Start begins monitoring for payment events
 func (pm *PaymentMonitor) Start() error {
-	// Start blockchain event monitoring
-	err := pm.blockchain.MonitorPayments(pm.handlePaymentEvent)
-	if err != nil {
-		return err
-	}
+	// Consume confirmed payment events from the durable indexer
+	pm.indexer = pm.blockchain.NewPaymentIndexer(pm.db, pm.indexerConfig, pm.handlePaymentEvent)
+	pm.indexer.Start()
 
 	// Start periodic bill status checks
 	go pm.startPeriodicChecks()
@@ -70,38 +82,55 @@ func (pm *PaymentMonitor) Start() error {
 
 // Stop stops the payment monitor
 func (pm *PaymentMonitor) Stop() {
+	if pm.indexer != nil {
+		pm.indexer.Stop()
+	}
 	close(pm.stopCh)
 }
 
-// handlePaymentEvent processes incoming payment events from the blockchain
-func (pm *PaymentMonitor) handlePaymentEvent(payment blockchain.Payment) {
+// handlePaymentEvent processes confirmed payment events from the indexer. Events for
+// unknown bills are skipped; storage failures are returned so the indexer retries.
+func (pm *PaymentMonitor) handlePaymentEvent(payment blockchain.Payment) error {
 	// Parse bill ID
 	billID, err := strconv.ParseUint(payment.BillID, 10, 32)
 	if err != nil {
-		return
+		log.Printf("Skipping payment %s with invalid bill ID %q", payment.TransactionHash, payment.BillID)
+		return nil
 	}
 
-	// Get bill from database
-	bill, err := pm.db.GetBill(uint(billID))
-	if err != nil {
-		return
+	// Get bill from database. Only a bill that does not exist is skipped: any other
+	// failure is returned so the indexer retries the block range.
+	if _, err := pm.db.GetBill(uint(billID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Printf("Skipping payment %s for unknown bill %d", payment.TransactionHash, billID)
+			return nil
+		}
+		return err
 	}
 
-	// Update bill with payment information
-	bill.PaidAmount += float64(payment.Amount)
-	bill.TipAmount += float64(payment.TipAmount)
-
-	// Update bill status
-	if bill.PaidAmount >= bill.TotalAmount {
-		bill.Status = database.BillStatusPaid
-	} else if bill.PaidAmount > 0 {
-		bill.Status = "partial"
+	// Record the event in the ledger; redelivered events are no-ops. An event whose
+	// amounts the ledger cannot hold is kept as flagged so it is not lost, but it does
+	// not count towards the bill.
+	status := database.PaymentStatusConfirmed
+	if payment.AmountError != "" {
+		log.Printf("Flagging payment %s for bill %d for reconciliation: %s", payment.TransactionHash, billID, payment.AmountError)
+		status = database.PaymentStatusFlagged
 	}
-
-	// Save updated bill
-	err = pm.db.UpdateBill(bill)
+	logIndex := payment.LogIndex
+	bill, recorded, err := pm.db.RecordPayment(&database.Payment{
+		BillID:    uint(billID),
+		PayerAddr: payment.Payer,
+		Amount:    payment.Amount,
+		TipAmount: payment.TipAmount,
+		TxHash:    payment.TransactionHash,
+		LogIndex:  &logIndex,
+		Status:    status,
+	}, database.BillActorIndexer)
 	if err != nil {
-		return
+		return err
+	}
+	if !recorded || status != database.PaymentStatusConfirmed {
+		return nil
 	}
 
 	// Send payment notification to business dashboard
@@ -109,6 +138,10 @@ func (pm *PaymentMonitor) handlePaymentEvent(payment blockchain.Payment) {
 
 	// Send bill update notification to guests
 	pm.sendBillUpdateNotification(bill)
+
+	// Let the table see whose share is now paid
+	pm.NotifySplitSession(bill.ID)
+	return nil
 }
 
 // sendPaymentNotification sends a payment notification to the business dashboard
@@ -126,7 +159,7 @@ func (pm *PaymentMonitor) sendPaymentNotification(payment blockchain.Payment, bi
 	}
 
 	// Send to business dashboard room
-	businessRoom := "business_" + strconv.FormatUint(uint64(bill.BusinessID), 10)
+	businessRoom := businessRoomPrefix + strconv.FormatUint(uint64(bill.BusinessID), 10)
 	pm.sendToRoom(businessRoom, notification)
 }
 
@@ -138,10 +171,10 @@ func (pm *PaymentMonitor) sendBillUpdateNotification(bill *database.Bill) {
 		BusinessID: bill.BusinessID,
 		TableID:    bill.TableID,
 		Status:     string(bill.Status),
-		PaidAmount: int64(bill.PaidAmount),
-		TipAmount:  int64(bill.TipAmount),
-		Total:      int64(bill.TotalAmount),
-		Remaining:  int64(bill.TotalAmount - bill.PaidAmount),
+		PaidAmount: bill.PaidAmount,
+		TipAmount:  bill.TipAmount,
+		Total:      bill.TotalAmount,
+		Remaining:  bill.TotalAmount - bill.PaidAmount,
 		Timestamp:  time.Now(),
 	}
 
@@ -151,24 +184,40 @@ func (pm *PaymentMonitor) sendBillUpdateNotification(bill *database.Bill) {
 		return
 	}
 
-	tableRoom := "table_" + table.TableCode
+	tableRoom := tableRoomPrefix + table.TableCode
 	pm.sendToRoom(tableRoom, notification)
 
 	// Also send to bill-specific room
-	billRoom := "bill_" + strconv.FormatUint(uint64(bill.ID), 10)
+	billRoom := billRoomPrefix + strconv.FormatUint(uint64(bill.ID), 10)
 	pm.sendToRoom(billRoom, notification)
 }
 
-// sendToRoom sends a message to all clients in a specific room
-func (pm *PaymentMonitor) sendToRoom(room string, data interface{}) {
-	message, err := json.Marshal(data)
-	if err != nil {
+// NotifySplitSession pushes the per-share status of a bill's split to the bill room.
+// Bills that have not been split are skipped.
+func (pm *PaymentMonitor) NotifySplitSession(billID uint) {
+	session, err := pm.db.GetSplitSession(billID)
+	if err != nil || session == nil {
 		return
 	}
 
-	pm.hub.BroadcastToRoom(room, message)
+	billRoom := billRoomPrefix + strconv.FormatUint(uint64(billID), 10)
+	pm.sendToRoom(billRoom, SplitUpdateNotification{
+		Type:      "split_update",
+		BillID:    billID,
+		Session:   session,
+		Timestamp: time.Now(),
+	})
+}
+
+// sendToRoom sends a message to all clients in a specific room
+func (pm *PaymentMonitor) sendToRoom(room string, data interface{}) {
+	pm.hub.BroadcastToRoom(room, data)
 }
 
+// pendingPaymentExpiry is how long a reported payment may wait for the indexer to
+// confirm it before it is considered dropped from the chain
+const pendingPaymentExpiry = time.Hour
+
 // startPeriodicChecks starts periodic checks for payment confirmations
 func (pm *PaymentMonitor) startPeriodicChecks() {
 	ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds
@@ -178,16 +227,17 @@ func (pm *PaymentMonitor) startPeriodicChecks() {
 		select {
 		case <-ticker.C:
 			pm.checkPendingPayments()
+			pm.expirePendingPayments()
 		case <-pm.stopCh:
 			return
 		}
 	}
 }
 
-// checkPendingPayments checks for pending payments that may need status updates
+// checkPendingPayments reconciles open bills against the on-chain totals
 func (pm *PaymentMonitor) checkPendingPayments() {
-	// Get bills with partial or open status
-	bills, err := pm.db.GetBillsByStatus(database.BillStatusOpen)
+	// Get bills that are still open
+	bills, err := pm.db.GetBillsByStatus(database.ActiveBillStatuses...)
 	if err != nil {
 		return
 	}
@@ -196,30 +246,37 @@ func (pm *PaymentMonitor) checkPendingPayments() {
 		// Check blockchain for latest payment status
 		totalPaid, err := pm.blockchain.GetBillTotalPaid(strconv.FormatUint(uint64(bill.ID), 10))
 		if err != nil {
+			if errors.Is(err, money.ErrSubCentUSDC) {
+				log.Printf("Bill %d on-chain total cannot be compared with the ledger: %v", bill.ID, err)
+			}
 			continue
 		}
 
-		// Update if there's a discrepancy
-		if float64(totalPaid) != bill.PaidAmount {
-			bill.PaidAmount = float64(totalPaid)
-
-			// Update status
-			if bill.PaidAmount >= bill.TotalAmount {
-				bill.Status = database.BillStatusPaid
-			} else if bill.PaidAmount > 0 {
-				bill.Status = "partial"
-			}
-
-			if err := pm.db.UpdateBill(bill); err != nil {
-				continue
-			}
+		totals, err := database.GetBillPaymentTotals(bill.ID)
+		if err != nil {
+			continue
+		}
 
-			// Send notification
-			pm.sendBillUpdateNotification(bill)
+		// The ledger is fed by the indexer; a mismatch means events are still pending
+		// confirmation or were missed, so report it instead of overwriting the bill
+		if totalPaid != totals.CryptoAmount {
+			log.Printf("Bill %d ledger mismatch: on-chain %s, recorded %s", bill.ID, totalPaid, totals.CryptoAmount)
 		}
 	}
 }
 
+// expirePendingPayments fails reported payments the indexer has not confirmed in time
+func (pm *PaymentMonitor) expirePendingPayments() {
+	expired, err := pm.db.ExpirePendingPayments(time.Now().Add(-pendingPaymentExpiry))
+	if err != nil {
+		log.Printf("Failed to expire pending payments: %v", err)
+		return
+	}
+	if expired > 0 {
+		log.Printf("Expired %d payments the indexer did not confirm within %s", expired, pendingPaymentExpiry)
+	}
+}
+
 // NotifyPaymentConfirmation manually triggers a payment confirmation notification
 func (pm *PaymentMonitor) NotifyPaymentConfirmation(billID uint, txHash string) {
 	bill, err := pm.db.GetBill(billID)
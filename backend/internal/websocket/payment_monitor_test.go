@@ -0,0 +1,56 @@
+package websocket
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"payverge/internal/blockchain"
+	"payverge/internal/database"
+	"payverge/internal/database/dbtest"
+	"payverge/internal/money"
+)
+
+// An event for a bill that does not exist is skipped, but a failing database must
+// stop the indexer so the block range is retried
+func TestHandlePaymentEventRetriesOnDatabaseErrors(t *testing.T) {
+	conn := dbtest.Open(t)
+	database.InitTestDB(conn)
+
+	pm := &PaymentMonitor{hub: NewHub(), db: database.GetDBWrapper()}
+	event := blockchain.Payment{BillID: "404", Payer: "0xpayer", TransactionHash: "0xtx"}
+	assert.NoError(t, pm.handlePaymentEvent(event))
+
+	sqlDB, err := conn.DB()
+	require.NoError(t, err)
+	require.NoError(t, sqlDB.Close())
+	assert.Error(t, pm.handlePaymentEvent(event))
+}
+
+// An event whose amounts are not whole cents is kept for reconciliation without
+// counting towards the bill
+func TestHandlePaymentEventFlagsUnreadableAmounts(t *testing.T) {
+	conn := dbtest.Open(t)
+	database.InitTestDB(conn)
+
+	bill := &database.Bill{BusinessID: 1, TableID: 1, BillNumber: "B-1", TotalAmount: money.MustParse("10.00"), Status: database.BillStatusOpen}
+	require.NoError(t, conn.Create(bill).Error)
+
+	pm := &PaymentMonitor{hub: NewHub(), db: database.GetDBWrapper()}
+	require.NoError(t, pm.handlePaymentEvent(blockchain.Payment{
+		BillID:          strconv.FormatUint(uint64(bill.ID), 10),
+		Payer:           "0xpayer",
+		TransactionHash: "0xsubcent",
+		LogIndex:        2,
+		AmountError:     "invalid payment amount: USDC amount is not a whole number of cents",
+	}))
+
+	var payment database.Payment
+	require.NoError(t, conn.Where("tx_hash = ?", "0xsubcent").First(&payment).Error)
+	assert.Equal(t, database.PaymentStatusFlagged, payment.Status)
+
+	totals, err := database.GetBillPaymentTotals(bill.ID)
+	require.NoError(t, err)
+	assert.Equal(t, money.Zero, totals.CryptoAmount)
+}
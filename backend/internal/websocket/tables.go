@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"strconv"
+	"time"
+
+	"payverge/internal/database"
+)
+
+// TableFeed pushes bill changes to the guests of the tables involved and to staff
+type TableFeed struct {
+	hub *Hub
+}
+
+// BillTransferNotification tells a table that bills were moved, merged or split
+type BillTransferNotification struct {
+	Type       string          `json:"type"`
+	Action     string          `json:"action"`
+	BusinessID uint            `json:"business_id"`
+	Bills      []database.Bill `json:"bills"`
+	Timestamp  time.Time       `json:"timestamp"`
+}
+
+// TableTransferNotification tells a table's guests which bills were moved, merged or
+// split. Anyone holding the printed table code can join its room, so it names the
+// bills without their items or amounts.
+type TableTransferNotification struct {
+	Type       string            `json:"type"`
+	Action     string            `json:"action"`
+	BusinessID uint              `json:"business_id"`
+	Bills      []TransferredBill `json:"bills"`
+	Timestamp  time.Time         `json:"timestamp"`
+}
+
+// TransferredBill identifies a bill in a table transfer notification
+type TransferredBill struct {
+	ID         uint   `json:"id"`
+	BillNumber string `json:"bill_number"`
+}
+
+// TableStatusNotification carries the live status board of a business's tables
+type TableStatusNotification struct {
+	Type       string                 `json:"type"`
+	BusinessID uint                   `json:"business_id"`
+	Tables     []database.TableStatus `json:"tables"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// NewTableFeed creates a table feed broadcasting through the hub
+func NewTableFeed(hub *Hub) *TableFeed {
+	return &TableFeed{hub: hub}
+}
+
+// NotifyBillTransfer sends a bill transfer to the room of every bill it touched and to
+// the business room, and tells the rooms of the tables involved which bills changed
+func (f *TableFeed) NotifyBillTransfer(businessID uint, transfer *database.BillTransfer) {
+	now := time.Now()
+	notification := BillTransferNotification{
+		Type:       "bill_transfer",
+		Action:     transfer.Action,
+		BusinessID: businessID,
+		Bills:      transfer.Bills,
+		Timestamp:  now,
+	}
+	tableNotification := TableTransferNotification{
+		Type:       "bill_transfer",
+		Action:     transfer.Action,
+		BusinessID: businessID,
+		Bills:      make([]TransferredBill, 0, len(transfer.Bills)),
+		Timestamp:  now,
+	}
+	for _, bill := range transfer.Bills {
+		tableNotification.Bills = append(tableNotification.Bills, TransferredBill{ID: bill.ID, BillNumber: bill.BillNumber})
+	}
+
+	for _, table := range transfer.Tables {
+		f.hub.BroadcastToRoom(tableRoomPrefix+table.TableCode, tableNotification)
+	}
+	for _, bill := range transfer.Bills {
+		f.hub.BroadcastToRoom(billRoomPrefix+strconv.FormatUint(uint64(bill.ID), 10), notification)
+	}
+	f.hub.BroadcastToRoom(businessRoomPrefix+strconv.FormatUint(uint64(businessID), 10), notification)
+}
+
+// NotifyTableStatus sends the status board to the business room, where hosts and the
+// rest of the staff follow the floor
+func (f *TableFeed) NotifyTableStatus(businessID uint, board []database.TableStatus) {
+	f.hub.BroadcastToRoom(businessRoomPrefix+strconv.FormatUint(uint64(businessID), 10), TableStatusNotification{
+		Type:       "table_status",
+		BusinessID: businessID,
+		Tables:     board,
+		Timestamp:  time.Now(),
+	})
+}
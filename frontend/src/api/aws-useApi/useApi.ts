@@ -1,6 +1,6 @@
 // hooks/useApi.ts
 import { axiosInstance } from "@/api";
-import { destroyCookie } from "nookies";
+import { destroyCookie, parseCookies } from "nookies";
 
 // Función para obtener la sesión actual
 export async function getSession() {
@@ -42,11 +42,14 @@ export async function signIn(data: { message: string; signature: string }) {
 // Función para cerrar sesión
 export async function signOutFromSession() {
     try {
-        const response = await axiosInstance.post("/auth/signout");
+        const response = await axiosInstance.post("/auth/signout", {
+            refresh_token: parseCookies().refresh_token,
+        });
         if (response.status === 200) {
             // Clear all auth-related cookies
             destroyCookie(null, "token", { path: "/" });
             destroyCookie(null, "session_token", { path: "/" });
+            destroyCookie(null, "refresh_token", { path: "/" });
             destroyCookie(null, "persist-web3-login", { path: "/" });
             return response.data;
         }
@@ -55,6 +58,7 @@ export async function signOutFromSession() {
         // Still destroy cookies even if API call fails
         destroyCookie(null, "token", { path: "/" });
         destroyCookie(null, "session_token", { path: "/" });
+        destroyCookie(null, "refresh_token", { path: "/" });
         destroyCookie(null, "persist-web3-login", { path: "/" });
         throw error;
     }
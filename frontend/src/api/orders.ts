@@ -49,7 +49,6 @@ export interface CreateOrderRequest {
 
 export interface UpdateOrderStatusRequest {
   status: 'pending' | 'approved' | 'in_kitchen' | 'ready' | 'delivered' | 'cancelled';
-  approved_by?: string;
 }
 
 export interface OrdersResponse {
@@ -18,7 +18,7 @@ export interface PaymentHistoryItem {
   amount: number;
   tip_amount: number;
   tx_hash: string;
-  status: 'pending' | 'confirmed' | 'failed';
+  status: 'pending' | 'confirmed' | 'failed' | 'flagged';
   created_at: string;
   updated_at: string;
 }
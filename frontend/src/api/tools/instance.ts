@@ -1,9 +1,10 @@
 import axios, { AxiosError, InternalAxiosRequestConfig, AxiosResponse } from "axios";
-import { parseCookies } from "nookies";
+import { parseCookies, setCookie as setNookie, destroyCookie } from "nookies";
 import { sanitizeError } from "@/utils/errorMessages";
 import { apiCache } from "@/utils/cache";
 
 interface RetryConfig extends InternalAxiosRequestConfig {
+  _refreshed?: boolean;
   _retryCount?: number;
   _maxRetries?: number;
   _retryDelay?: number;
@@ -26,6 +27,35 @@ export const axiosInstance = axios.create({
     timeout: 30000, // Reduced from 1000000 to reasonable 30s
 });
 
+// Access tokens last minutes; the refresh token is traded for a new pair when one
+// expires. Concurrent requests share a single refresh, since a refresh token only
+// works once and reusing it signs the session out.
+let refreshing: Promise<string | null> | null = null;
+
+export function refreshAccessToken(): Promise<string | null> {
+    const refreshToken = parseCookies().refresh_token;
+    if (!refreshToken) return Promise.resolve(null);
+    if (!refreshing) {
+        refreshing = axios
+            .post(`${process.env.NEXT_PUBLIC_API_URL}/auth/refresh`, { refresh_token: refreshToken })
+            .then((response) => {
+                const { token, refresh_token } = response.data;
+                setNookie(null, "session_token", token, { path: "/", maxAge: 24 * 60 * 60, secure: true, sameSite: "strict" });
+                setNookie(null, "refresh_token", refresh_token, { path: "/", maxAge: 30 * 24 * 60 * 60, secure: true, sameSite: "strict" });
+                return token as string;
+            })
+            .catch(() => {
+                destroyCookie(null, "session_token", { path: "/" });
+                destroyCookie(null, "refresh_token", { path: "/" });
+                return null;
+            })
+            .finally(() => {
+                refreshing = null;
+            });
+    }
+    return refreshing;
+}
+
 axiosInstance.interceptors.request.use(
     (config: RetryConfig) => {
         // Set default retry configuration
@@ -92,6 +122,15 @@ axiosInstance.interceptors.response.use(
         }
         
         const config = error.config as RetryConfig;
+
+        // Refresh an expired access token once and replay the request
+        if (config && error.response?.status === 401 && !config._refreshed && !config.url?.startsWith("/auth/")) {
+            config._refreshed = true;
+            const token = await refreshAccessToken();
+            if (token) {
+                return axiosInstance(config);
+            }
+        }
         
         // Don't retry if no config or if we shouldn't retry this error
         if (!config || !shouldRetry(error)) {
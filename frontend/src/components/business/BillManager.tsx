@@ -112,8 +112,7 @@ export const BillManager: React.FC<BillManagerProps> = ({ businessId }) => {
     setActionLoading(orderId);
     try {
       await updateOrderStatus(businessId, orderId, {
-        status: 'approved',
-        approved_by: 'staff'
+        status: 'approved'
       });
       await loadBills();
     } catch (error) {
@@ -127,8 +126,7 @@ export const BillManager: React.FC<BillManagerProps> = ({ businessId }) => {
     setActionLoading(orderId);
     try {
       await updateOrderStatus(businessId, orderId, {
-        status: 'cancelled',
-        approved_by: 'staff'
+        status: 'cancelled'
       });
       await loadBills();
     } catch (error) {
@@ -78,13 +78,11 @@ const Kitchen: React.FC<KitchenProps> = ({ businessId }) => {
     try {
       console.log(`Updating order ${orderId} to status: ${status} for business ${businessId}`);
       console.log('Request data:', {
-        status: status,
-        approved_by: 'kitchen'
+        status: status
       });
       
       const result = await updateOrderStatus(businessId, orderId, {
-        status: status as any,
-        approved_by: 'kitchen'
+        status: status as any
       });
       console.log('Order status updated successfully:', result);
       
@@ -35,7 +35,7 @@ interface Payment {
   amount: number
   tip_amount: number
   tx_hash: string
-  status: 'pending' | 'confirmed' | 'failed'
+  status: 'pending' | 'confirmed' | 'failed' | 'flagged'
   created_at: string
   updated_at: string
 }
@@ -147,6 +147,7 @@ export default function PaymentHistory({ businessId }: PaymentHistoryProps) {
       case 'confirmed': return 'success'
       case 'pending': return 'warning'
       case 'failed': return 'danger'
+      case 'flagged': return 'warning'
       default: return 'default'
     }
   }
@@ -184,6 +185,7 @@ export default function PaymentHistory({ businessId }: PaymentHistoryProps) {
     { key: 'confirmed', label: tString('statusOptions.confirmed') },
     { key: 'pending', label: tString('statusOptions.pending') },
     { key: 'failed', label: tString('statusOptions.failed') },
+    { key: 'flagged', label: tString('statusOptions.flagged') },
   ]
 
   if (loading) {
@@ -369,6 +371,7 @@ export default function PaymentHistory({ businessId }: PaymentHistoryProps) {
                       <span className={`inline-flex items-center px-2 py-1 rounded-full text-xs font-medium ${
                         payment.status === 'confirmed' ? 'bg-green-100 text-green-700' :
                         payment.status === 'pending' ? 'bg-yellow-100 text-yellow-700' :
+                        payment.status === 'flagged' ? 'bg-orange-100 text-orange-700' :
                         'bg-red-100 text-red-700'
                       }`}>
                         {payment.status}
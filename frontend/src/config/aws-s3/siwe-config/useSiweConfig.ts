@@ -5,7 +5,7 @@ import {
     SIWEVerifyMessageArgs,
 } from "@reown/appkit-siwe";
 import { setCookie, getCookie } from "@/config/aws-s3/cookie-management/store.helpers";
-import { getCsrfToken, getSession, signIn, signOutFromSession } from "@/api";
+import { getCsrfToken, getSession, refreshAccessToken, signIn, signOutFromSession } from "@/api";
 import { getNetworkId } from "@/config/network";
 import { emitSiweVerified } from "./siweEvents";
 import { destroyCookie } from "nookies";
@@ -25,30 +25,26 @@ export const siweConfig = () => {
         createMessage: ({ address, nonce, chainId }: SIWECreateMessageArgs) => {
             const domain = window.location.host;
             const uri = window.location.origin;
-            const now = new Date();
-            const timestamp = now.toLocaleDateString('en-US', { 
-                month: 'short',
-                day: 'numeric',
-                hour: '2-digit',
-                minute: '2-digit'
-            });
+            const issuedAt = new Date();
+            const expiresAt = new Date(issuedAt.getTime() + 5 * 60 * 1000);
 
             // Extract only the wallet address part
             const cleanAddress = address.includes(':') 
                 ? address.split(':').pop() 
                 : address;
-            
-            return `🍽️ Welcome to Payverge!
 
-Sign to verify your wallet ownership and authenticate with our platform. No gas fees required.
+            // EIP-4361 layout; the backend checks every field
+            return `${domain} wants you to sign in with your Ethereum account:
+${cleanAddress}
 
-🔐 Authentication Details:
-Wallet: ${cleanAddress}
-Verification: ${nonce}
-Network: Base #${chainId}
-Time: ${timestamp}
+Welcome to Payverge! Sign to verify your wallet ownership and accept Payverge's terms of service. No gas fees required.
 
-By signing, you accept Payverge's terms of service and confirm ownership of this wallet.`;
+URI: ${uri}
+Version: 1
+Chain ID: ${chainId}
+Nonce: ${nonce}
+Issued At: ${issuedAt.toISOString()}
+Expiration Time: ${expiresAt.toISOString()}`;
         },
         getNonce: async (address) => {
             const nonce = await getCsrfToken({ address });
@@ -57,15 +53,21 @@ By signing, you accept Payverge's terms of service and confirm ownership of this
         },
         getSession: async () => {
             // First check for local session token
-            const token = getCookie("session_token");
+            let token = getCookie("session_token");
             if (!token) {
                 return null;
             }
 
-            // Validate token using utility function
+            // An expired access token is renewed while the session lasts
             if (!isTokenValid(token)) {
+                token = await refreshAccessToken();
+            }
+
+            // Validate token using utility function
+            if (!token || !isTokenValid(token)) {
                 // Token is expired or invalid, clear it
                 destroyCookie(null, "session_token", { path: "/" });
+                destroyCookie(null, "refresh_token", { path: "/" });
                 destroyCookie(null, "token", { path: "/" });
                 destroyCookie(null, "persist-web3-login", { path: "/" });
                 return null;
@@ -103,6 +105,8 @@ By signing, you accept Payverge's terms of service and confirm ownership of this
                     const token = response.data.token;
                     // Set the session token without quotes - they'll be added in the axios interceptor
                     setCookie("session_token", token, 1);
+                    // Traded for a new access token when this one expires
+                    setCookie("refresh_token", response.data.refresh_token, 30);
                     // Emit event after successful verification
                     emitSiweVerified();
                     // Return the token so the session is immediately available
@@ -123,6 +127,7 @@ By signing, you accept Payverge's terms of service and confirm ownership of this
                 await signOutFromSession();
                 destroyCookie(null, "token", { path: "/" });
                 destroyCookie(null, "session_token", { path: "/" });
+                destroyCookie(null, "refresh_token", { path: "/" });
                 destroyCookie(null, "persist-web3-login", { path: "/" });
                 return true;
             } catch (error) {
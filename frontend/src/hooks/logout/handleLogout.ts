@@ -23,6 +23,7 @@ export const useLogout = () => {
             // Clear all cookies
             destroyCookie(null, "token", { path: "/" });
             destroyCookie(null, "session_token", { path: "/" });
+            destroyCookie(null, "refresh_token", { path: "/" });
             destroyCookie(null, "persist-web3-login", { path: "/" });
             
             // Clear localStorage SIWE state